@@ -714,21 +714,60 @@ func (c *androidMkSingleton) GenerateBuildActions(ctx SingletonContext) {
 	})
 }
 
+// The number of shards the modules are split into to generate Android.mk. There are more shards
+// than CPUs to even out the cost of the modules, which varies a lot between module types.
+const androidMkShards = 64
+
+// androidMkShard is the part of Android.mk generated from a contiguous range of modules.
+type androidMkShard struct {
+	buf       bytes.Buffer
+	typeStats map[string]int
+	err       error
+}
+
 func translateAndroidMk(ctx SingletonContext, absMkFile string, mods []blueprint.Module) error {
+	// Translate the modules in shards concurrently, and concatenate the shards in order so that
+	// the output doesn't depend on the scheduling.
+	shardSize := (len(mods) + androidMkShards - 1) / androidMkShards
+	var modShards [][]blueprint.Module
+	for len(mods) > shardSize {
+		modShards = append(modShards, mods[:shardSize])
+		mods = mods[shardSize:]
+	}
+	if len(mods) > 0 {
+		modShards = append(modShards, mods)
+	}
+
+	shards := make([]androidMkShard, len(modShards))
+	ParallelForEach(len(modShards), func(i int) {
+		shard := &shards[i]
+		shard.typeStats = make(map[string]int)
+		for _, mod := range modShards[i] {
+			err := translateAndroidMkModule(ctx, &shard.buf, mod)
+			if err != nil {
+				shard.err = err
+				return
+			}
+
+			if amod, ok := mod.(Module); ok && ctx.PrimaryModule(amod) == amod {
+				shard.typeStats[ctx.ModuleType(amod)] += 1
+			}
+		}
+	})
+
 	buf := &bytes.Buffer{}
 
 	fmt.Fprintln(buf, "LOCAL_MODULE_MAKEFILE := $(lastword $(MAKEFILE_LIST))")
 
 	typeStats := make(map[string]int)
-	for _, mod := range mods {
-		err := translateAndroidMkModule(ctx, buf, mod)
-		if err != nil {
+	for i := range shards {
+		if shards[i].err != nil {
 			os.Remove(absMkFile)
-			return err
+			return shards[i].err
 		}
-
-		if amod, ok := mod.(Module); ok && ctx.PrimaryModule(amod) == amod {
-			typeStats[ctx.ModuleType(amod)] += 1
+		buf.Write(shards[i].buf.Bytes())
+		for k, v := range shards[i].typeStats {
+			typeStats[k] += v
 		}
 	}
 
@@ -180,6 +180,10 @@ var FirstPackedRelocationsVersion = uncheckedFinalApiLevel(23)
 // libandroid_support.
 var FirstNonLibAndroidSupportVersion = uncheckedFinalApiLevel(21)
 
+// The first API level where the runtime loads all of the dex files of an app, so that apps no
+// longer need legacy multidex with a main dex list.
+var FirstNativeMultidexVersion = uncheckedFinalApiLevel(21)
+
 // LastWithoutModuleLibCoreSystemModules is the last API level where prebuilts/sdk does not contain
 // a core-for-system-modules.jar for the module-lib API scope.
 var LastWithoutModuleLibCoreSystemModules = uncheckedFinalApiLevel(31)
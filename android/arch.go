@@ -318,6 +318,10 @@ var (
 	// Android is the OS for target devices that run all of Android, including the Linux kernel
 	// and the Bionic libc runtime.
 	Android = newOsType("android", Device, false, Arm, Arm64, X86, X86_64)
+	// Baremetal is the OS for firmware and other components that run on the device without an
+	// operating system, linked against a freestanding libc such as picolibc or LLVM libc. It is
+	// disabled by default, and only has targets if BaremetalArch is set in the product variables.
+	Baremetal = newOsType("baremetal", Device, true, Arm, Arm64)
 
 	// CommonOS is a pseudo OSType for a common OS variant, which is OsType agnostic and which
 	// has dependencies on all the OS variants.
@@ -983,9 +987,18 @@ func createArchPropTypeDesc(props reflect.Type) []archPropTypeDesc {
 // are valid for an Android.bp file.
 var variantReplacer = strings.NewReplacer("-", "_", ".", "_")
 
-// filterArchStruct returns true if the given field is an architecture specific property.
+// filterArchStruct returns true if the given field is an architecture specific property.  A field
+// tagged with `android:"arch_variant_nested"` is architecture specific along with all the
+// properties nested in it, whether or not they are tagged with `android:"arch_variant"`.
+//
+// Like arch_variant, arch_variant_nested only covers the arch, multilib, os and target blocks.
+// There is no generic image axis; properties specific to the vendor, product or recovery images
+// are still declared by each module type in its own target blocks, e.g. target.vendor in cc.
 func filterArchStruct(field reflect.StructField, prefix string) (bool, reflect.StructField) {
-	if proptools.HasTag(field, "android", "arch_variant") {
+	if proptools.HasTag(field, "android", "arch_variant_nested") {
+		field.Type = archVariantNestedType(field.Type)
+	}
+	if proptools.HasTag(field, "android", "arch_variant") || proptools.HasTag(field, "android", "arch_variant_nested") {
 		// The arch_variant field isn't necessary past this point
 		// Instead of wasting space, just remove it. Go also has a
 		// 16-bit limit on structure name length. The name is constructed
@@ -1000,7 +1013,7 @@ func filterArchStruct(field reflect.StructField, prefix string) (bool, reflect.S
 		}
 		// don't delete path tag as it is needed for bp2build
 		// these tags don't need to be present in the runtime generated struct type.
-		values = RemoveListFromList(values, []string{"arch_variant", "variant_prepend"})
+		values = RemoveListFromList(values, []string{"arch_variant", "arch_variant_nested", "variant_prepend"})
 		if len(values) > 0 && values[0] != "path" {
 			panic(fmt.Errorf("unknown tags %q in field %q", values, prefix+field.Name))
 		} else if len(values) == 1 {
@@ -1016,6 +1029,38 @@ func filterArchStruct(field reflect.StructField, prefix string) (bool, reflect.S
 	return false, field
 }
 
+// archVariantNestedType returns a copy of a struct type, or of a pointer to a struct type, in which
+// all the properties, including the properties of nested structs, are tagged with
+// `android:"arch_variant"`.  Properties that are mutated by Soong are not tagged, so that
+// filterArchStruct filters them out.
+func archVariantNestedType(typ reflect.Type) reflect.Type {
+	if typ.Kind() == reflect.Ptr && typ.Elem().Kind() == reflect.Struct {
+		return reflect.PtrTo(archVariantNestedType(typ.Elem()))
+	} else if typ.Kind() != reflect.Struct {
+		return typ
+	}
+
+	var fields []reflect.StructField
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if field.PkgPath != "" {
+			// Unexported fields aren't properties.
+			continue
+		}
+		if !field.Anonymous && !proptools.HasTag(field, "blueprint", "mutated") {
+			var values []string
+			if tag := field.Tag.Get("android"); tag != "" {
+				values = RemoveListFromList(strings.Split(tag, ","), []string{"arch_variant", "arch_variant_nested"})
+			}
+			values = append([]string{"arch_variant"}, values...)
+			field.Tag = reflect.StructTag(`android:"` + strings.Join(values, ",") + `"`)
+			field.Type = archVariantNestedType(field.Type)
+		}
+		fields = append(fields, field)
+	}
+	return reflect.StructOf(fields)
+}
+
 // archPropTypeMap contains a cache of the results of createArchPropTypeDesc for each type.  It is
 // shared across all Contexts, but is constructed based only on compile-time information so there
 // is no risk of contaminating one Context with data from another.
@@ -1628,6 +1673,17 @@ func decodeTargetProductVariables(config *config) (map[OsType][]Target, error) {
 		}
 	}
 
+	// An optional baremetal target, for firmware built alongside the device.
+	if String(variables.BaremetalArch) != "" {
+		addTarget(targetConfig{
+			os:                  Baremetal,
+			archName:            *variables.BaremetalArch,
+			archVariant:         variables.BaremetalArchVariant,
+			cpuVariant:          variables.BaremetalCpuVariant,
+			nativeBridgeEnabled: NativeBridgeDisabled,
+		})
+	}
+
 	// Optional device targets
 	if variables.DeviceArch != nil && *variables.DeviceArch != "" {
 		// The primary device target.
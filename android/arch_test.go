@@ -236,6 +236,47 @@ func TestFilterArchStruct(t *testing.T) {
 			}{},
 			filtered: false,
 		},
+
+		// Nested arch variant tests
+		{
+			name: "nested",
+			in: &struct {
+				A struct {
+					A *string
+					B *string `android:"path"`
+					C struct {
+						A []string
+					}
+					D bool `blueprint:"mutated"`
+				} `android:"arch_variant_nested"`
+				B *string
+			}{},
+			out: &struct {
+				A struct {
+					A *string
+					B *string `android:"path"`
+					C struct {
+						A []string
+					}
+				}
+			}{},
+			filtered: true,
+		},
+		{
+			name: "pointer nested",
+			in: &struct {
+				A *struct {
+					A *string
+					B bool `blueprint:"mutated"`
+				} `android:"arch_variant_nested"`
+			}{},
+			out: &struct {
+				A *struct {
+					A *string
+				}
+			}{},
+			filtered: true,
+		},
 	}
 
 	for _, test := range tests {
@@ -654,6 +654,19 @@ func (c *config) CpPreserveSymlinksFlags() string {
 	}
 }
 
+// ReadSourceFile returns the contents of a file in the source tree, read through the file system
+// of the config so that it can be mocked in tests. A ninja file dependency is added on the file so
+// that the build manifest is regenerated when it changes.
+func (c *config) ReadSourceFile(ctx PathContext, path string) ([]byte, error) {
+	ctx.AddNinjaFileDeps(path)
+	r, err := c.fs.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return ioutil.ReadAll(r)
+}
+
 func (c *config) Getenv(key string) string {
 	var val string
 	var exists bool
@@ -1006,6 +1019,25 @@ func (c *config) RunErrorProne() bool {
 	return c.IsEnvTrue("RUN_ERROR_PRONE")
 }
 
+// ModuleInfoJSONV2 returns true if module-info-v2.json, which lists the install paths and direct
+// dependencies of every variant of every Soong module, should be generated.
+func (c *config) ModuleInfoJSONV2() bool {
+	return c.IsEnvTrue("SOONG_MODULE_INFO_JSON_V2")
+}
+
+// AttributeBuildErrors returns true if module_owners.json, which maps the intermediates directory
+// of every module to its OWNERS and bug component, should be written for soong_ui to attribute
+// failed build actions.
+func (c *config) AttributeBuildErrors() bool {
+	return c.IsEnvTrue("ATTRIBUTE_BUILD_ERRORS")
+}
+
+// ThinArchives returns true if static libraries should be emitted as thin archives unless they
+// set thin_archive: false.
+func (c *config) ThinArchives() bool {
+	return c.IsEnvTrue("SOONG_THIN_ARCHIVES")
+}
+
 // XrefCorpusName returns the Kythe cross-reference corpus name.
 func (c *config) XrefCorpusName() string {
 	return c.Getenv("XREF_CORPUS")
@@ -1109,6 +1141,12 @@ func (c *config) ModulesLoadedByPrivilegedModules() []string {
 	return c.productVariables.ModulesLoadedByPrivilegedModules
 }
 
+// ApkV4Signature returns true if apps should generate the signature file of APK Signing Scheme V4
+// alongside their signed APK files unless they set v4_signature.
+func (c *config) ApkV4Signature() bool {
+	return Bool(c.productVariables.ApkV4Signature)
+}
+
 // DexpreoptGlobalConfigPath returns the path to the dexpreopt.config file in
 // the output directory, if it was created during the product configuration
 // phase by Kati.
@@ -1422,6 +1460,13 @@ func (c *config) CFIEnabledForPath(path string) bool {
 	return HasAnyPrefix(path, c.productVariables.CFIIncludePaths) && !c.CFIDisabledForPath(path)
 }
 
+func (c *config) KcfiEnabledForPath(path string) bool {
+	if len(c.productVariables.KcfiIncludePaths) == 0 {
+		return false
+	}
+	return HasAnyPrefix(path, c.productVariables.KcfiIncludePaths)
+}
+
 func (c *config) MemtagHeapDisabledForPath(path string) bool {
 	if len(c.productVariables.MemtagHeapExcludePaths) == 0 {
 		return false
@@ -1429,6 +1474,70 @@ func (c *config) MemtagHeapDisabledForPath(path string) bool {
 	return HasAnyPrefix(path, c.productVariables.MemtagHeapExcludePaths)
 }
 
+func (c *config) RustSanitizeDisabledForPath(path string) bool {
+	if len(c.productVariables.RustSanitizeExcludePaths) == 0 {
+		return false
+	}
+	return HasAnyPrefix(path, c.productVariables.RustSanitizeExcludePaths)
+}
+
+// ToolchainFlavorForModule returns the name of the cc toolchain flavor that the product maps the
+// module with the given name in dir to, or "" if it isn't mapped. Module mappings take precedence
+// over path mappings, and longer paths over shorter ones.  A path maps the modules in the directory
+// and its subdirectories.
+func (c *config) ToolchainFlavorForModule(dir, name string) string {
+	for _, entry := range c.productVariables.ToolchainFlavorModules {
+		if s := strings.SplitN(entry, ":", 2); len(s) == 2 && s[1] == name {
+			return s[0]
+		}
+	}
+
+	flavor, longest := "", -1
+	for _, entry := range c.productVariables.ToolchainFlavorPaths {
+		s := strings.SplitN(entry, ":", 2)
+		if len(s) != 2 {
+			continue
+		}
+		// Match whole path components, so that vendor/foo doesn't map vendor/foobar.
+		p := strings.TrimSuffix(s[1], "/")
+		if (dir == p || strings.HasPrefix(dir, p+"/")) && len(p) > longest {
+			flavor, longest = s[0], len(p)
+		}
+	}
+	return flavor
+}
+
+// CcTestStaticLibsForPath returns the static libraries that the product adds to the device
+// variants of every cc_test in dir.  A path matches dir and its subdirectories, but not the
+// directories whose name only starts with the same characters, e.g. vendor/foo doesn't match
+// vendor/foobar.
+func (c *config) CcTestStaticLibsForPath(dir string) []string {
+	var libs []string
+	for _, entry := range c.productVariables.CcTestPathStaticLibs {
+		s := strings.SplitN(entry, ":", 2)
+		if len(s) != 2 {
+			continue
+		}
+		path := strings.TrimSuffix(s[1], "/")
+		if dir == path || strings.HasPrefix(dir, path+"/") {
+			libs = append(libs, s[0])
+		}
+	}
+	return libs
+}
+
+// DefaultBuildIdStyle returns the style of the build id note of device cc modules that don't set
+// build_id, or "" for the toolchain default.
+func (c *config) DefaultBuildIdStyle() string {
+	return String(c.productVariables.DefaultBuildIdStyle)
+}
+
+// DefaultFilePrefixMap returns true if device cc modules that don't set file_prefix_map are
+// compiled with -ffile-prefix-map.
+func (c *config) DefaultFilePrefixMap() bool {
+	return Bool(c.productVariables.DefaultFilePrefixMap)
+}
+
 func (c *config) MemtagHeapAsyncEnabledForPath(path string) bool {
 	if len(c.productVariables.MemtagHeapAsyncIncludePaths) == 0 {
 		return false
@@ -1443,6 +1552,20 @@ func (c *config) MemtagHeapSyncEnabledForPath(path string) bool {
 	return HasAnyPrefix(path, c.productVariables.MemtagHeapSyncIncludePaths) && !c.MemtagHeapDisabledForPath(path)
 }
 
+func (c *config) MemtagHeapAsyncEnabledForPartition(partition, path string) bool {
+	return InList(partition, c.productVariables.MemtagHeapAsyncIncludePartitions) && !c.MemtagHeapDisabledForPath(path)
+}
+
+func (c *config) MemtagHeapSyncEnabledForPartition(partition, path string) bool {
+	return InList(partition, c.productVariables.MemtagHeapSyncIncludePartitions) && !c.MemtagHeapDisabledForPath(path)
+}
+
+// BranchProtectionEnabledForPartition returns true if the product enables PAuth and BTI for the
+// arm64 cc modules installed to the partition.
+func (c *config) BranchProtectionEnabledForPartition(partition string) bool {
+	return InList(partition, c.productVariables.BranchProtectionIncludePartitions)
+}
+
 func (c *config) VendorConfig(name string) VendorConfig {
 	return soongconfig.Config(c.productVariables.VendorVars[name])
 }
@@ -1475,6 +1598,22 @@ func (c *config) EnforceSystemCertificateAllowList() []string {
 	return c.productVariables.EnforceSystemCertificateAllowList
 }
 
+func (c *config) EnforceLicenseConstraints() bool {
+	return Bool(c.productVariables.EnforceLicenseConstraints)
+}
+
+func (c *config) LicenseConstraintsAllowedEdges() []string {
+	return c.productVariables.LicenseConstraintsAllowedEdges
+}
+
+func (c *config) EnforceApexExportedSymbols() bool {
+	return Bool(c.productVariables.EnforceApexExportedSymbols)
+}
+
+func (c *config) StrictPhonyTargets() bool {
+	return Bool(c.productVariables.StrictPhonyTargets)
+}
+
 func (c *config) EnforceProductPartitionInterface() bool {
 	return Bool(c.productVariables.EnforceProductPartitionInterface)
 }
@@ -1622,6 +1761,11 @@ func (c *deviceConfig) VendorSnapshotModules() map[string]bool {
 	return c.config.productVariables.VendorSnapshotModules
 }
 
+// ProductPackages returns the names of the modules in PRODUCT_PACKAGES.
+func (c *deviceConfig) ProductPackages() []string {
+	return c.config.productVariables.ProductPackages
+}
+
 func (c *deviceConfig) DirectedRecoverySnapshot() bool {
 	return c.config.productVariables.DirectedRecoverySnapshot
 }
@@ -1713,6 +1857,12 @@ func (c *deviceConfig) HostFakeSnapshotEnabled() bool {
 	return c.config.productVariables.HostFakeSnapshotEnabled
 }
 
+// HostSnapshotVersion returns the platform version of the host snapshot the host_snapshot_binary
+// modules must have been captured from, or an empty string if any version is accepted.
+func (c *deviceConfig) HostSnapshotVersion() string {
+	return c.config.productVariables.HostSnapshotVersion
+}
+
 func (c *deviceConfig) ShippingApiLevel() ApiLevel {
 	if c.config.productVariables.ShippingApiLevel == nil {
 		return NoneApiLevel
@@ -0,0 +1,128 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/google/blueprint"
+)
+
+func init() {
+	RegisterModuleType("content_addressed_file", ContentAddressedFileFactory)
+}
+
+var PrepareForTestWithContentAddressedFile = FixtureRegisterWithContext(func(ctx RegistrationContext) {
+	ctx.RegisterModuleType("content_addressed_file", ContentAddressedFileFactory)
+})
+
+// The environment variable that points to the content addressed store. The store is a directory,
+// possibly the mount point of a remote artifact store, that contains each blob at
+// sha256/<digest>.
+const contentAddressedStoreEnvVar = "SOONG_CONTENT_ADDRESSED_STORE"
+
+var sha256DigestRegexp = regexp.MustCompile("^[0-9a-f]{64}$")
+
+var contentAddressedFetch = pctx.AndroidStaticRule("contentAddressedFetch",
+	blueprint.RuleParams{
+		Command: `rm -f $out $out.tmp && ` +
+			`if [ ! -f $blob ]; then echo "$blob: not found in the content addressed store" >&2; exit 1; fi && ` +
+			`cp -f $blob $out.tmp && ` +
+			`{ echo "$sha256  $out.tmp" | sha256sum -c --status || ` +
+			`{ echo "$blob: content doesn't match sha256 digest $sha256" >&2; rm -f $out.tmp; exit 1; }; } && ` +
+			`mv $out.tmp $out`,
+		Description: "fetch $out",
+	},
+	"blob", "sha256")
+
+type contentAddressedFileProperties struct {
+	// The sha256 digest of the file, as 64 lowercase hex digits.
+	Sha256 *string
+
+	// Name of the output file. Defaults to the name of the module.
+	Filename *string
+}
+
+type contentAddressedFile struct {
+	ModuleBase
+
+	properties contentAddressedFileProperties
+
+	outputFile OutputPath
+}
+
+// content_addressed_file provides a file that is fetched from a content addressed store when it
+// is built, instead of living in the source tree, so that large prebuilt blobs don't need to be
+// checked into git. Other modules use the file like a source file, with the ":<module>" syntax.
+//
+// The file is pinned by its sha256 digest. It is looked up as sha256/<digest> in the directory
+// pointed to by SOONG_CONTENT_ADDRESSED_STORE, and the build fails if the content doesn't match
+// the digest. As the content can only ever be the one described by the digest, the file is only
+// fetched again when the digest changes.
+func ContentAddressedFileFactory() Module {
+	module := &contentAddressedFile{}
+	module.AddProperties(&module.properties)
+	InitAndroidModule(module)
+	return module
+}
+
+func (f *contentAddressedFile) GenerateAndroidBuildActions(ctx ModuleContext) {
+	digest := String(f.properties.Sha256)
+	if !sha256DigestRegexp.MatchString(digest) {
+		ctx.PropertyErrorf("sha256", "must be 64 lowercase hex digits, but was %q", digest)
+		return
+	}
+	filename := StringDefault(f.properties.Filename, ctx.ModuleName())
+	if filename == "" || strings.Contains(filename, "/") {
+		ctx.PropertyErrorf("filename", "%q is not a valid file name", filename)
+		return
+	}
+	f.outputFile = PathForModuleOut(ctx, filename).OutputPath
+
+	store := ctx.Config().Getenv(contentAddressedStoreEnvVar)
+	if store == "" {
+		// Only fail the build if the file is actually needed.
+		ctx.Build(pctx, BuildParams{
+			Rule:   ErrorRule,
+			Output: f.outputFile,
+			Args: map[string]string{
+				"error": fmt.Sprintf("%s: %s must be set to fetch sha256 %s",
+					ctx.ModuleName(), contentAddressedStoreEnvVar, digest),
+			},
+		})
+		return
+	}
+
+	ctx.Build(pctx, BuildParams{
+		Rule:   contentAddressedFetch,
+		Output: f.outputFile,
+		Args: map[string]string{
+			"blob":   strings.TrimSuffix(store, "/") + "/sha256/" + digest,
+			"sha256": digest,
+		},
+	})
+}
+
+var _ OutputFileProducer = (*contentAddressedFile)(nil)
+
+// Implements OutputFileProducer
+func (f *contentAddressedFile) OutputFiles(tag string) (Paths, error) {
+	if tag == "" {
+		return Paths{f.outputFile}, nil
+	}
+	return nil, fmt.Errorf("unsupported module reference tag %q", tag)
+}
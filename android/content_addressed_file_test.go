@@ -0,0 +1,105 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"testing"
+)
+
+const testSha256 = "2c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae"
+
+var prepareForContentAddressedFileTest = GroupFixturePreparers(
+	PrepareForTestWithFilegroup,
+	PrepareForTestWithContentAddressedFile,
+)
+
+func TestContentAddressedFile(t *testing.T) {
+	result := GroupFixturePreparers(
+		prepareForContentAddressedFileTest,
+		FixtureMergeEnv(map[string]string{
+			"SOONG_CONTENT_ADDRESSED_STORE": "/mnt/cas/",
+		}),
+	).RunTestWithBp(t, `
+		content_addressed_file {
+			name: "blob",
+			sha256: "`+testSha256+`",
+			filename: "blob.img",
+		}
+
+		filegroup {
+			name: "fg",
+			srcs: [":blob"],
+		}
+	`)
+
+	fetch := result.ModuleForTests("blob", "").Output("blob.img")
+	AssertStringEquals(t, "blob", "/mnt/cas/sha256/"+testSha256, fetch.Args["blob"])
+	AssertStringEquals(t, "sha256", testSha256, fetch.Args["sha256"])
+
+	fg := result.Module("fg", "").(*fileGroup)
+	AssertPathsRelativeToTopEquals(t, "filegroup srcs",
+		[]string{"out/soong/.intermediates/blob/blob.img"}, fg.Srcs())
+}
+
+func TestContentAddressedFileWithoutStore(t *testing.T) {
+	result := prepareForContentAddressedFileTest.RunTestWithBp(t, `
+		content_addressed_file {
+			name: "blob",
+			sha256: "`+testSha256+`",
+		}
+	`)
+
+	fetch := result.ModuleForTests("blob", "").Output("blob")
+	if fetch.Rule != ErrorRule {
+		t.Fatalf("expected error rule, got %s", fetch.Rule)
+	}
+	AssertStringDoesContain(t, "error", fetch.Args["error"],
+		"SOONG_CONTENT_ADDRESSED_STORE must be set to fetch sha256 "+testSha256)
+}
+
+func TestContentAddressedFileErrors(t *testing.T) {
+	testCases := []struct {
+		name string
+		bp   string
+		err  string
+	}{
+		{
+			name: "invalid digest",
+			bp: `
+				content_addressed_file {
+					name: "blob",
+					sha256: "2C26B46B",
+				}`,
+			err: `sha256: must be 64 lowercase hex digits, but was "2C26B46B"`,
+		},
+		{
+			name: "invalid filename",
+			bp: `
+				content_addressed_file {
+					name: "blob",
+					sha256: "` + testSha256 + `",
+					filename: "a/blob.img",
+				}`,
+			err: `filename: "a/blob.img" is not a valid file name`,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			prepareForContentAddressedFileTest.
+				ExtendWithErrorHandler(FixtureExpectsAtLeastOneErrorMatchingPattern(tc.err)).
+				RunTestWithBp(t, tc.bp)
+		})
+	}
+}
@@ -197,6 +197,10 @@ func InitDefaultsModule(module DefaultsModule) {
 	initArchModule(module)
 	InitDefaultableModule(module)
 
+	// Add the soong_config_conditional property, which may set any of the properties for which the
+	// module provides defaults.
+	initSoongConfigConditional(module)
+
 	// Add properties that will not have defaults applied to them.
 	base := module.base()
 	defaultsVisibility := &DefaultsVisibilityProperties{}
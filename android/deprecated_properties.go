@@ -0,0 +1,270 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/google/blueprint/proptools"
+)
+
+// Deprecated properties allow the author of a module type to phase out a property without breaking
+// the modules that still set it.  The module factory declares the deprecated properties:
+//
+//	AddDeprecatedProperties(module, DeprecatedProperty{
+//	    Property:    "sdclang",
+//	    Replacement: "toolchain_flavor",
+//	    Message:     `set toolchain_flavor: "sdclang" instead`,
+//	    Expiry:      "2023-06-30",
+//	})
+//
+// Every module that sets a deprecated property, in its module definition, in its defaults or in
+// its arch or target specific properties, gets a warning, and the warnings are aggregated into
+// deprecated_properties.json, which lists the modules to migrate for each deprecated property.
+// The warnings are printed by the build when they change, and by the deprecated-properties target.
+
+func init() {
+	RegisterDeprecatedPropertiesBuildComponents(InitRegistrationContext)
+}
+
+func RegisterDeprecatedPropertiesBuildComponents(ctx RegistrationContext) {
+	ctx.RegisterSingletonType("deprecated_properties", deprecatedPropertiesSingletonFactory)
+}
+
+// PrepareForTestWithDeprecatedProperties registers the mutator that finds the uses of deprecated
+// properties and the singleton that reports them.
+var PrepareForTestWithDeprecatedProperties = GroupFixturePreparers(
+	FixtureRegisterWithContext(func(ctx RegistrationContext) {
+		ctx.PreDepsMutators(registerDeprecatedPropertiesMutator)
+	}),
+	FixtureRegisterWithContext(RegisterDeprecatedPropertiesBuildComponents),
+)
+
+// DeprecatedProperty describes a deprecated property of a module type and how to migrate away from
+// it.
+type DeprecatedProperty struct {
+	// The name of the property, with the names of nested properties separated by dots.
+	Property string
+
+	// The property that replaces the deprecated property, if any.
+	Replacement string
+
+	// Explains how to migrate, printed with the warning.
+	Message string
+
+	// The date, as YYYY-MM-DD, after which the property may be removed.
+	Expiry string
+}
+
+// AddDeprecatedProperties declares properties of the module as deprecated.  It is called from the
+// module factory.
+func AddDeprecatedProperties(module Module, properties ...DeprecatedProperty) {
+	base := module.base()
+	base.deprecatedProperties = append(base.deprecatedProperties, properties...)
+}
+
+// deprecatedPropertyUse records a module that sets a deprecated property.
+type deprecatedPropertyUse struct {
+	DeprecatedProperty
+	Module         string
+	ModuleType     string
+	BlueprintsFile string
+}
+
+func (u deprecatedPropertyUse) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%s: %s %q: property %q is deprecated", u.BlueprintsFile, u.ModuleType, u.Module, u.Property)
+	if u.Replacement != "" {
+		fmt.Fprintf(&sb, ", use %q instead", u.Replacement)
+	}
+	if u.Message != "" {
+		fmt.Fprintf(&sb, ": %s", u.Message)
+	}
+	if u.Expiry != "" {
+		fmt.Fprintf(&sb, " (may be removed after %s)", u.Expiry)
+	}
+	return sb.String()
+}
+
+type deprecatedPropertyUses struct {
+	sync.Mutex
+	uses []deprecatedPropertyUse
+}
+
+var deprecatedPropertyUsesKey = NewOnceKey("deprecatedPropertyUses")
+
+func getDeprecatedPropertyUses(config Config) *deprecatedPropertyUses {
+	return config.Once(deprecatedPropertyUsesKey, func() interface{} {
+		return &deprecatedPropertyUses{}
+	}).(*deprecatedPropertyUses)
+}
+
+func registerDeprecatedPropertiesMutator(ctx RegisterMutatorsContext) {
+	ctx.BottomUp("deprecated_properties", deprecatedPropertiesMutator).Parallel()
+}
+
+// deprecatedPropertiesMutator records the deprecated properties that are set in each variant of
+// the module.  It runs after the defaults have been applied and the arch and target specific
+// properties have been squashed into the module properties, so it also finds the properties that
+// aren't set directly in the module definition.  The uses of the variants of a module are merged
+// by the singleton.
+func deprecatedPropertiesMutator(ctx BottomUpMutatorContext) {
+	deprecated := ctx.Module().base().deprecatedProperties
+	if len(deprecated) == 0 {
+		return
+	}
+
+	var uses []deprecatedPropertyUse
+	for _, property := range deprecated {
+		if isPropertySet(ctx.Module().GetProperties(), strings.Split(property.Property, ".")) {
+			uses = append(uses, deprecatedPropertyUse{
+				DeprecatedProperty: property,
+				Module:             ctx.ModuleName(),
+				ModuleType:         ctx.ModuleType(),
+				BlueprintsFile:     ctx.BlueprintsFile(),
+			})
+		}
+	}
+
+	if len(uses) > 0 {
+		all := getDeprecatedPropertyUses(ctx.Config())
+		all.Lock()
+		defer all.Unlock()
+		all.uses = append(all.uses, uses...)
+	}
+}
+
+// isPropertySet returns true if any of the property structs has a non-zero value for the property
+// with the given nested names.
+func isPropertySet(props []interface{}, names []string) bool {
+	for _, p := range props {
+		if isPropertyFieldSet(reflect.ValueOf(p).Elem(), names) {
+			return true
+		}
+	}
+	return false
+}
+
+func isPropertyFieldSet(v reflect.Value, names []string) bool {
+	field := v.FieldByName(proptools.FieldNameForProperty(names[0]))
+	if !field.IsValid() {
+		return false
+	}
+	if len(names) == 1 {
+		return !field.IsZero()
+	}
+
+	if field.Kind() == reflect.Ptr && field.Type().Elem().Kind() == reflect.Struct {
+		if field.IsNil() {
+			return false
+		}
+		field = field.Elem()
+	}
+	if field.Kind() != reflect.Struct {
+		return false
+	}
+	return isPropertyFieldSet(field, names[1:])
+}
+
+func deprecatedPropertiesSingletonFactory() Singleton {
+	return &deprecatedPropertiesSingleton{}
+}
+
+// deprecatedPropertiesSingleton writes the report of the modules that set deprecated properties.
+type deprecatedPropertiesSingleton struct {
+	outputFile WritablePath
+}
+
+type deprecatedPropertyReport struct {
+	ModuleType  string   `json:"module_type"`
+	Property    string   `json:"property"`
+	Replacement string   `json:"replacement,omitempty"`
+	Message     string   `json:"message,omitempty"`
+	Expiry      string   `json:"expiry,omitempty"`
+	Modules     []string `json:"modules"`
+}
+
+func (s *deprecatedPropertiesSingleton) GenerateBuildActions(ctx SingletonContext) {
+	uses := getDeprecatedPropertyUses(ctx.Config()).uses
+	if len(uses) == 0 {
+		return
+	}
+
+	// The mutator runs in parallel, sort the uses so that the outputs are deterministic.
+	sort.Slice(uses, func(i, j int) bool {
+		return uses[i].String() < uses[j].String()
+	})
+
+	var warnings []string
+	reports := make(map[string]*deprecatedPropertyReport)
+	for i, use := range uses {
+		// Skip the uses of the other variants of the same module.
+		if i > 0 && use.String() == uses[i-1].String() {
+			continue
+		}
+		warnings = append(warnings, "warning: "+use.String())
+
+		key := use.ModuleType + " " + use.Property
+		if reports[key] == nil {
+			reports[key] = &deprecatedPropertyReport{
+				ModuleType:  use.ModuleType,
+				Property:    use.Property,
+				Replacement: use.Replacement,
+				Message:     use.Message,
+				Expiry:      use.Expiry,
+			}
+		}
+		reports[key].Modules = append(reports[key].Modules, use.Module)
+	}
+
+	var report []*deprecatedPropertyReport
+	for _, key := range SortedStringKeys(reports) {
+		reports[key].Modules = SortedUniqueStrings(reports[key].Modules)
+		report = append(report, reports[key])
+	}
+
+	content, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		ctx.Errorf("failed to marshal deprecated properties report: %s", err)
+		return
+	}
+
+	s.outputFile = PathForOutput(ctx, "deprecated_properties.json")
+	WriteFileRule(ctx, s.outputFile, string(content))
+
+	warningsFile := PathForOutput(ctx, "deprecated_properties.txt")
+	WriteFileRule(ctx, warningsFile, strings.Join(warnings, "\n"))
+
+	// Print the warnings whenever they change, which is when the target is out of date.
+	stamp := PathForOutput(ctx, "deprecated_properties.stamp")
+	rule := NewRuleBuilder(pctx, ctx)
+	rule.Command().Text("cat").Input(warningsFile)
+	rule.Command().Text("touch").Output(stamp)
+	rule.Build("deprecated_properties", "deprecated properties")
+
+	ctx.Phony("deprecated-properties", stamp)
+	ctx.Phony("droidcore", stamp)
+}
+
+func (s *deprecatedPropertiesSingleton) MakeVars(ctx MakeVarsContext) {
+	if s.outputFile != nil {
+		ctx.DistForGoal("droidcore", s.outputFile)
+	}
+}
@@ -0,0 +1,151 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"testing"
+)
+
+type deprecatingModuleProperties struct {
+	Old_flags []string `android:"arch_variant"`
+	New_flags []string `android:"arch_variant"`
+	Nested    struct {
+		Old *bool
+	}
+}
+
+type deprecatingModule struct {
+	ModuleBase
+	DefaultableModuleBase
+	props deprecatingModuleProperties
+}
+
+func (m *deprecatingModule) GenerateAndroidBuildActions(ctx ModuleContext) {}
+
+func deprecatingModuleFactory() Module {
+	m := &deprecatingModule{}
+	m.AddProperties(&m.props)
+	InitAndroidArchModule(m, HostAndDeviceDefault, MultilibBoth)
+	InitDefaultableModule(m)
+	AddDeprecatedProperties(m,
+		DeprecatedProperty{
+			Property:    "old_flags",
+			Replacement: "new_flags",
+			Expiry:      "2023-06-30",
+		},
+		DeprecatedProperty{
+			Property: "nested.old",
+			Message:  "it has no effect",
+		})
+	return m
+}
+
+type deprecatingDefaults struct {
+	ModuleBase
+	DefaultsModuleBase
+}
+
+func deprecatingDefaultsFactory() Module {
+	d := &deprecatingDefaults{}
+	d.AddProperties(&deprecatingModuleProperties{})
+	InitDefaultsModule(d)
+	return d
+}
+
+func TestDeprecatedProperties(t *testing.T) {
+	result := GroupFixturePreparers(
+		PrepareForTestWithArchMutator,
+		PrepareForTestWithDefaults,
+		PrepareForTestWithDeprecatedProperties,
+		FixtureRegisterWithContext(func(ctx RegistrationContext) {
+			ctx.RegisterModuleType("deprecating", deprecatingModuleFactory)
+			ctx.RegisterModuleType("deprecating_defaults", deprecatingDefaultsFactory)
+		}),
+		FixtureAddFile("foo/Android.bp", []byte(`
+			deprecating {
+				name: "foo",
+				old_flags: ["-DFOO"],
+				nested: {
+					old: true,
+				},
+			}
+		`)),
+	).RunTestWithBp(t, `
+		deprecating {
+			name: "bar",
+			old_flags: ["-DBAR"],
+		}
+
+		deprecating {
+			name: "baz",
+			new_flags: ["-DBAZ"],
+		}
+
+		deprecating_defaults {
+			name: "qux_defaults",
+			old_flags: ["-DQUX"],
+		}
+
+		deprecating {
+			name: "qux",
+			defaults: ["qux_defaults"],
+		}
+
+		deprecating {
+			name: "quux",
+			arch: {
+				arm: {
+					old_flags: ["-DQUUX"],
+				},
+			},
+		}
+	`)
+
+	// The modules are only reported once, although they have variants for each arch, including
+	// the ones that set the property in their defaults or in arch specific properties.
+	singleton := result.SingletonForTests("deprecated_properties")
+	AssertStringEquals(t, "warnings",
+		`warning: Android.bp: deprecating "bar": property "old_flags" is deprecated, use "new_flags" instead (may be removed after 2023-06-30)
+warning: Android.bp: deprecating "quux": property "old_flags" is deprecated, use "new_flags" instead (may be removed after 2023-06-30)
+warning: Android.bp: deprecating "qux": property "old_flags" is deprecated, use "new_flags" instead (may be removed after 2023-06-30)
+warning: foo/Android.bp: deprecating "foo": property "nested.old" is deprecated: it has no effect
+warning: foo/Android.bp: deprecating "foo": property "old_flags" is deprecated, use "new_flags" instead (may be removed after 2023-06-30)
+`,
+		ContentFromFileRuleForTests(t, singleton.Output("deprecated_properties.txt")))
+
+	AssertStringEquals(t, "report", `[
+  {
+    "module_type": "deprecating",
+    "property": "nested.old",
+    "message": "it has no effect",
+    "modules": [
+      "foo"
+    ]
+  },
+  {
+    "module_type": "deprecating",
+    "property": "old_flags",
+    "replacement": "new_flags",
+    "expiry": "2023-06-30",
+    "modules": [
+      "bar",
+      "foo",
+      "quux",
+      "qux"
+    ]
+  }
+]
+`, ContentFromFileRuleForTests(t, singleton.Output("deprecated_properties.json")))
+}
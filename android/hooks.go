@@ -122,6 +122,33 @@ func (l *loadHookContext) CreateModule(factory ModuleFactory, props ...interface
 	return module
 }
 
+// PropertiesToCopyModule returns the property structs of module to pass to CreateModule, from a
+// context of the module, to create another module of the same type with the same properties,
+// including the arch specific ones.  The common properties are left out as CreateModule inherits
+// them, and so are the variable properties, which it copies, the name, and the defaults, which
+// have already been applied to the properties.
+func PropertiesToCopyModule(module Module) []interface{} {
+	base := module.base()
+	skip := map[interface{}]bool{
+		&base.nameProperties:   true,
+		&base.commonProperties: true,
+	}
+	if base.variableProperties != nil {
+		skip[base.variableProperties] = true
+	}
+	if defaultable, ok := module.(Defaultable); ok {
+		skip[defaultable.defaults()] = true
+	}
+
+	var props []interface{}
+	for _, p := range module.GetProperties() {
+		if !skip[p] {
+			props = append(props, p)
+		}
+	}
+	return props
+}
+
 func (l *loadHookContext) registerScopedModuleType(name string, factory blueprint.ModuleFactory) {
 	l.bp.RegisterScopedModuleType(name, factory)
 }
@@ -0,0 +1,211 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/google/blueprint"
+)
+
+// The installed_files singleton writes installed-files.json for every partition that the product
+// installs Soong device files to.  The files are those of the device variants of the modules in
+// PRODUCT_PACKAGES, and the files of their dependencies that are installed along with them.
+// Files installed by Make modules are not listed.  The list of files, with the module and the
+// variant that installs each of them, is known at analysis time; the installed_files_json tool
+// adds the size and the SHA-256 hash of each file once they are built:
+//
+//	[
+//	    {
+//	        "Name": "/system/bin/foo",
+//	        "Module": "foo",
+//	        "Variant": "android_arm64_armv8-a",
+//	        "Source": "out/soong/.intermediates/foo/android_arm64_armv8-a/foo",
+//	        "Size": 12345,
+//	        "SHA256": "..."
+//	    }
+//	]
+//
+// Symlinks have a "SymlinkTarget" instead of a source, size and hash.
+
+var (
+	_ = pctx.HostBinToolVariable("installedFilesJsonCmd", "installed_files_json")
+
+	installedFilesJsonRule = pctx.AndroidStaticRule("installedFilesJson", blueprint.RuleParams{
+		Command:     "${installedFilesJsonCmd} --manifest $in --output $out",
+		CommandDeps: []string{"${installedFilesJsonCmd}"},
+	})
+)
+
+func init() {
+	RegisterInstalledFilesBuildComponents(InitRegistrationContext)
+}
+
+func RegisterInstalledFilesBuildComponents(ctx RegistrationContext) {
+	ctx.RegisterSingletonType("installed_files", installedFilesSingletonFactory)
+}
+
+// PrepareForTestWithInstalledFiles registers the singleton that writes installed-files.json.
+var PrepareForTestWithInstalledFiles = FixtureRegisterWithContext(RegisterInstalledFilesBuildComponents)
+
+func installedFilesSingletonFactory() Singleton {
+	return &installedFilesSingleton{}
+}
+
+type installedFilesSingleton struct {
+	// installed-files.json of each partition, keyed by the name of the partition.
+	outputFiles map[string]WritablePath
+
+	// The reports of the size_budget modules.
+	sizeBudgetReports Paths
+}
+
+// installedFile is an entry of the manifest written at analysis time.
+type installedFile struct {
+	Name          string
+	Module        string
+	Variant       string
+	Source        string `json:",omitempty"`
+	SymlinkTarget string `json:",omitempty"`
+}
+
+// installedFilesPartitions returns the files that the product installs from the device variants
+// of the modules in PRODUCT_PACKAGES and from their dependencies, keyed by the partition they are
+// installed to.  Files installed by more than one variant are only listed for the first one.
+func installedFilesPartitions(ctx SingletonContext) (map[string][]installedFile, map[string]Paths) {
+	files := make(map[string][]installedFile)
+	sources := make(map[string]Paths)
+
+	productPackages := make(map[string]bool)
+	for _, name := range ctx.DeviceConfig().ProductPackages() {
+		productPackages[name] = true
+	}
+
+	// The module and the variant that install each file, and the files installed by the product.
+	type owner struct {
+		module, variant string
+	}
+	owners := make(map[string]owner)
+	var specs []PackagingSpec
+
+	installedFileName := func(spec PackagingSpec) string {
+		partition := spec.Partition()
+		if partition == "" || partition == "testcases" {
+			return ""
+		}
+		return "/" + filepath.Join(partition, spec.RelPathInPackage())
+	}
+
+	ctx.VisitAllModules(func(module Module) {
+		if !module.Enabled() || module.Target().Os.Class != Device {
+			return
+		}
+		for _, spec := range module.PackagingSpecs() {
+			if name := installedFileName(spec); name != "" {
+				if _, ok := owners[name]; !ok {
+					owners[name] = owner{ctx.ModuleName(module), ctx.ModuleSubDir(module)}
+				}
+			}
+		}
+		if productPackages[ctx.ModuleName(module)] {
+			specs = append(specs, module.TransitivePackagingSpecs()...)
+		}
+	})
+
+	seen := make(map[string]bool)
+	for _, spec := range specs {
+		name := installedFileName(spec)
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+
+		partition := spec.Partition()
+		file := installedFile{
+			Name:    name,
+			Module:  owners[name].module,
+			Variant: owners[name].variant,
+		}
+		if spec.SymlinkTarget() != "" {
+			file.SymlinkTarget = spec.SymlinkTarget()
+		} else if spec.SrcPath() != nil {
+			file.Source = spec.SrcPath().String()
+			sources[partition] = append(sources[partition], spec.SrcPath())
+		}
+		files[partition] = append(files[partition], file)
+	}
+
+	for _, partitionFiles := range files {
+		sort.Slice(partitionFiles, func(i, j int) bool {
+			return partitionFiles[i].Name < partitionFiles[j].Name
+		})
+	}
+
+	return files, sources
+}
+
+func (s *installedFilesSingleton) GenerateBuildActions(ctx SingletonContext) {
+	files, sources := installedFilesPartitions(ctx)
+
+	s.outputFiles = make(map[string]WritablePath)
+	for partition := range files {
+		dir := strings.ReplaceAll(partition, "/", "_")
+		s.outputFiles[partition] = PathForOutput(ctx, "installed_files", dir, "installed-files.json")
+	}
+
+	// The size budgets are checked by validations of installed-files.json, so that it can't be
+	// built for a partition that exceeds a budget.
+	var validations map[string]Paths
+	s.sizeBudgetReports, validations = buildSizeBudgetChecks(ctx, files, s.outputFiles)
+
+	var outputs Paths
+	for _, partition := range SortedStringKeys(files) {
+		content, err := json.MarshalIndent(files[partition], "", "  ")
+		if err != nil {
+			ctx.Errorf("failed to marshal installed files of %s: %s", partition, err)
+			return
+		}
+
+		dir := strings.ReplaceAll(partition, "/", "_")
+		manifest := PathForOutput(ctx, "installed_files", dir, "installed-files.manifest.json")
+		WriteFileRule(ctx, manifest, string(content))
+
+		output := s.outputFiles[partition]
+		ctx.Build(pctx, BuildParams{
+			Rule:        installedFilesJsonRule,
+			Description: "installed-files.json " + partition,
+			Input:       manifest,
+			Implicits:   sources[partition],
+			Output:      output,
+			Validations: validations[partition],
+		})
+		outputs = append(outputs, output)
+	}
+
+	ctx.Phony("installed-files-json", outputs...)
+
+	ctx.Phony("size-budgets", s.sizeBudgetReports...)
+}
+
+func (s *installedFilesSingleton) MakeVars(ctx MakeVarsContext) {
+	for _, partition := range SortedStringKeys(s.outputFiles) {
+		ctx.DistForGoalWithFilename("droidcore", s.outputFiles[partition],
+			"installed-files-"+strings.ReplaceAll(partition, "/", "_")+".json")
+	}
+	ctx.DistForGoal("droidcore", s.sizeBudgetReports...)
+}
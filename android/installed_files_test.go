@@ -0,0 +1,118 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+type installedFilesTestModule struct {
+	ModuleBase
+}
+
+func installedFilesTestModuleFactory() Module {
+	m := &installedFilesTestModule{}
+	InitAndroidArchModule(m, HostAndDeviceSupported, MultilibFirst)
+	return m
+}
+
+func (m *installedFilesTestModule) GenerateAndroidBuildActions(ctx ModuleContext) {
+	builtFile := PathForModuleOut(ctx, m.Name())
+	installDir := PathForModuleInstall(ctx, "bin")
+	installed := ctx.InstallFile(installDir, m.Name(), builtFile)
+	ctx.InstallSymlink(installDir, m.Name()+"_link", installed)
+}
+
+func TestInstalledFiles(t *testing.T) {
+	bp := `
+		installed_files_test_module {
+			name: "foo",
+			host_supported: true,
+		}
+
+		installed_files_test_module {
+			name: "bar",
+			soc_specific: true,
+		}
+
+		installed_files_test_module {
+			name: "baz",
+		}
+	`
+
+	result := GroupFixturePreparers(
+		PrepareForTestWithArchMutator,
+		PrepareForTestWithInstalledFiles,
+		FixtureRegisterWithContext(func(ctx RegistrationContext) {
+			ctx.RegisterModuleType("installed_files_test_module", installedFilesTestModuleFactory)
+		}),
+		// baz is not installed by the product, so its files are not listed.
+		FixtureModifyProductVariables(func(variables FixtureProductVariables) {
+			variables.ProductPackages = []string{"foo", "bar"}
+		}),
+	).RunTestWithBp(t, bp)
+
+	singleton := result.SingletonForTests("installed_files")
+
+	manifest := func(partition string) []installedFile {
+		t.Helper()
+		rule := singleton.Output("installed_files/" + partition + "/installed-files.manifest.json")
+		var files []installedFile
+		if err := json.Unmarshal([]byte(ContentFromFileRuleForTests(t, rule)), &files); err != nil {
+			t.Fatalf("failed to parse manifest of %s: %s", partition, err)
+		}
+		for i := range files {
+			if files[i].Source != "" {
+				files[i].Source = StringPathRelativeToTop(result.Config.soongOutDir, files[i].Source)
+			}
+		}
+		return files
+	}
+
+	AssertDeepEquals(t, "system installed files", []installedFile{
+		{
+			Name:    "/system/bin/foo",
+			Module:  "foo",
+			Variant: "android_arm64_armv8-a",
+			Source:  "out/soong/.intermediates/foo/android_arm64_armv8-a/foo",
+		},
+		{
+			Name:          "/system/bin/foo_link",
+			Module:        "foo",
+			Variant:       "android_arm64_armv8-a",
+			SymlinkTarget: "foo",
+		},
+	}, manifest("system"))
+
+	AssertDeepEquals(t, "vendor installed files", []installedFile{
+		{
+			Name:    "/vendor/bin/bar",
+			Module:  "bar",
+			Variant: "android_arm64_armv8-a",
+			Source:  "out/soong/.intermediates/bar/android_arm64_armv8-a/bar",
+		},
+		{
+			Name:          "/vendor/bin/bar_link",
+			Module:        "bar",
+			Variant:       "android_arm64_armv8-a",
+			SymlinkTarget: "bar",
+		},
+	}, manifest("vendor"))
+
+	vendorJson := singleton.Output("installed_files/vendor/installed-files.json")
+	AssertPathsRelativeToTopEquals(t, "vendor sources",
+		[]string{"out/soong/.intermediates/bar/android_arm64_armv8-a/bar"}, vendorJson.Implicits)
+}
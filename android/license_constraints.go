@@ -0,0 +1,184 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/google/blueprint"
+)
+
+// License constraints are checked across the dependencies whose code is linked into a module,
+// e.g. the static libraries of a native module: a module whose license conditions include
+// proprietary or by_exception_only must not link in code under a restricted license, like the GPL,
+// directly or through other static dependencies.  Each violation is reported in
+// license-constraints.txt, and is an error when the product sets EnforceLicenseConstraints unless
+// the product allows it with a <module>:<restricted module> entry in LicenseConstraintsAllowedEdges.
+
+func init() {
+	RegisterLicenseConstraintsBuildComponents(InitRegistrationContext)
+}
+
+func RegisterLicenseConstraintsBuildComponents(ctx RegistrationContext) {
+	ctx.RegisterSingletonType("license_constraints", licenseConstraintsSingletonFactory)
+}
+
+var PrepareForTestWithLicenseConstraints = FixtureRegisterWithContext(RegisterLicenseConstraintsBuildComponents)
+
+// LicenseStaticLinkDependencyTag is implemented by dependency tags to tell whether the code of the
+// dependency is linked into the module, and so whether the license constraints are checked across
+// the dependency.
+type LicenseStaticLinkDependencyTag interface {
+	LicenseStaticLink() bool
+}
+
+// LicenseConstraintsInfo is provided by the modules that link in code under a restricted license.
+type LicenseConstraintsInfo struct {
+	// The modules under a restricted license whose code is linked into the module, including the
+	// module itself, mapped to their restricted license conditions.
+	Restricted map[string][]string
+
+	// The violations of the license constraints of the module.
+	Violations []LicenseConstraintViolation
+}
+
+var LicenseConstraintsProvider = blueprint.NewProvider(LicenseConstraintsInfo{})
+
+// LicenseConstraintViolation is a module that links in code it is not allowed to by the license
+// conditions of the module and of the code.
+type LicenseConstraintViolation struct {
+	Module               string
+	Conditions           []string
+	Restricted           string
+	RestrictedConditions []string
+	Allowed              bool
+}
+
+func (v LicenseConstraintViolation) String() string {
+	s := fmt.Sprintf("%s (%s) links in %s (%s)", v.Module, strings.Join(v.Conditions, ", "),
+		v.Restricted, strings.Join(v.RestrictedConditions, ", "))
+	if v.Allowed {
+		s += " [allowed]"
+	}
+	return s
+}
+
+func restrictedLicenseConditions(conditions []string) []string {
+	return FilterListPred(conditions, func(c string) bool {
+		return strings.HasPrefix(c, "restricted")
+	})
+}
+
+func isProprietaryLicenseConditions(conditions []string) bool {
+	return InList("proprietary", conditions) || InList("by_exception_only", conditions)
+}
+
+// checkLicenseConstraints collects the code under a restricted license that is linked into the
+// module, and checks that the license conditions of the module allow it.
+func checkLicenseConstraints(ctx ModuleContext) {
+	base := ctx.Module().base()
+	if !base.Enabled() {
+		return
+	}
+
+	restricted := make(map[string][]string)
+	if conditions := restrictedLicenseConditions(base.commonProperties.Effective_license_conditions); len(conditions) > 0 {
+		restricted[ctx.ModuleName()] = conditions
+	}
+
+	ctx.VisitDirectDeps(func(dep Module) {
+		tag, ok := ctx.OtherModuleDependencyTag(dep).(LicenseStaticLinkDependencyTag)
+		if !ok || !tag.LicenseStaticLink() || !ctx.OtherModuleHasProvider(dep, LicenseConstraintsProvider) {
+			return
+		}
+		info := ctx.OtherModuleProvider(dep, LicenseConstraintsProvider).(LicenseConstraintsInfo)
+		for name, conditions := range info.Restricted {
+			restricted[name] = conditions
+		}
+	})
+
+	if len(restricted) == 0 {
+		return
+	}
+
+	var violations []LicenseConstraintViolation
+	conditions := base.commonProperties.Effective_license_conditions
+	if isProprietaryLicenseConditions(conditions) {
+		allowed := ctx.Config().LicenseConstraintsAllowedEdges()
+		for _, name := range SortedStringKeys(restricted) {
+			if name == ctx.ModuleName() {
+				continue
+			}
+			v := LicenseConstraintViolation{
+				Module:               ctx.ModuleName(),
+				Conditions:           conditions,
+				Restricted:           name,
+				RestrictedConditions: restricted[name],
+				Allowed:              InList(ctx.ModuleName()+":"+name, allowed),
+			}
+			if !v.Allowed && ctx.Config().EnforceLicenseConstraints() {
+				ctx.ModuleErrorf("license conditions %q don't allow linking in %q, whose license conditions are %q; "+
+					"add %q to LicenseConstraintsAllowedEdges if it is allowed",
+					conditions, name, restricted[name], ctx.ModuleName()+":"+name)
+			}
+			violations = append(violations, v)
+		}
+	}
+
+	ctx.SetProvider(LicenseConstraintsProvider, LicenseConstraintsInfo{
+		Restricted: restricted,
+		Violations: violations,
+	})
+}
+
+func licenseConstraintsSingletonFactory() Singleton {
+	return &licenseConstraintsSingleton{}
+}
+
+// licenseConstraintsSingleton writes license-constraints.txt, which lists the violations of the
+// license constraints of all modules, including the allowed ones.
+type licenseConstraintsSingleton struct {
+	outputFile WritablePath
+}
+
+func (s *licenseConstraintsSingleton) GenerateBuildActions(ctx SingletonContext) {
+	var lines []string
+	ctx.VisitAllModules(func(module Module) {
+		if !ctx.ModuleHasProvider(module, LicenseConstraintsProvider) {
+			return
+		}
+		info := ctx.ModuleProvider(module, LicenseConstraintsProvider).(LicenseConstraintsInfo)
+		for _, v := range info.Violations {
+			lines = append(lines, v.String())
+		}
+	})
+
+	// The variants of a module have the same violations.
+	lines = FirstUniqueStrings(lines)
+	sort.Strings(lines)
+
+	s.outputFile = PathForOutput(ctx, "license-constraints.txt")
+	WriteFileRule(ctx, s.outputFile, strings.Join(lines, "\n"))
+
+	ctx.Phony("license-constraints", s.outputFile)
+}
+
+func (s *licenseConstraintsSingleton) MakeVars(ctx MakeVarsContext) {
+	if s.outputFile != nil {
+		ctx.DistForGoals([]string{"droidcore", "license-constraints"}, s.outputFile)
+	}
+}
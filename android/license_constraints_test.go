@@ -0,0 +1,154 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"testing"
+
+	"github.com/google/blueprint"
+)
+
+type mockLinkingModuleProperties struct {
+	Static_libs []string
+	Shared_libs []string
+}
+
+type mockLinkingModule struct {
+	ModuleBase
+	properties mockLinkingModuleProperties
+}
+
+func newMockLinkingModule() Module {
+	m := &mockLinkingModule{}
+	m.AddProperties(&m.properties)
+	InitAndroidModule(m)
+	return m
+}
+
+type mockLinkDependencyTag struct {
+	blueprint.BaseDependencyTag
+	static bool
+}
+
+func (t mockLinkDependencyTag) LicenseStaticLink() bool {
+	return t.static
+}
+
+func (m *mockLinkingModule) DepsMutator(ctx BottomUpMutatorContext) {
+	ctx.AddDependency(ctx.Module(), mockLinkDependencyTag{static: true}, m.properties.Static_libs...)
+	ctx.AddDependency(ctx.Module(), mockLinkDependencyTag{static: false}, m.properties.Shared_libs...)
+}
+
+func (m *mockLinkingModule) GenerateAndroidBuildActions(ModuleContext) {
+}
+
+const licenseConstraintsBp = `
+	license_kind {
+		name: "gpl",
+		conditions: ["restricted"],
+	}
+
+	license_kind {
+		name: "notice",
+		conditions: ["notice"],
+	}
+
+	license_kind {
+		name: "vendor",
+		conditions: ["proprietary", "by_exception_only"],
+	}
+
+	license {
+		name: "gpl_license",
+		license_kinds: ["gpl"],
+	}
+
+	license {
+		name: "notice_license",
+		license_kinds: ["notice"],
+	}
+
+	license {
+		name: "vendor_license",
+		license_kinds: ["vendor"],
+	}
+
+	mock_linking {
+		name: "libgpl",
+		licenses: ["gpl_license"],
+	}
+
+	mock_linking {
+		name: "libshared_gpl",
+		licenses: ["gpl_license"],
+	}
+
+	mock_linking {
+		name: "libnotice",
+		licenses: ["notice_license"],
+		static_libs: ["libgpl"],
+	}
+
+	mock_linking {
+		name: "vendor_bin",
+		licenses: ["vendor_license"],
+		static_libs: ["libnotice"],
+		shared_libs: ["libshared_gpl"],
+	}
+
+	mock_linking {
+		name: "vendor_allowed",
+		licenses: ["vendor_license"],
+		static_libs: ["libgpl"],
+	}
+
+	mock_linking {
+		name: "notice_bin",
+		licenses: ["notice_license"],
+		static_libs: ["libgpl"],
+	}
+`
+
+var prepareForLicenseConstraintsTest = GroupFixturePreparers(
+	prepareForLicenseTest,
+	PrepareForTestWithLicenseConstraints,
+	FixtureRegisterWithContext(func(ctx RegistrationContext) {
+		ctx.RegisterModuleType("mock_linking", newMockLinkingModule)
+	}),
+	FixtureModifyProductVariables(func(variables FixtureProductVariables) {
+		variables.LicenseConstraintsAllowedEdges = []string{"vendor_allowed:libgpl"}
+	}),
+)
+
+func TestLicenseConstraints(t *testing.T) {
+	result := prepareForLicenseConstraintsTest.RunTestWithBp(t, licenseConstraintsBp)
+
+	report := result.SingletonForTests("license_constraints").Output("license-constraints.txt")
+	AssertStringEquals(t, "license-constraints.txt",
+		"vendor_allowed (by_exception_only, proprietary) links in libgpl (restricted) [allowed]\n"+
+			"vendor_bin (by_exception_only, proprietary) links in libgpl (restricted)\n",
+		ContentFromFileRuleForTests(t, report))
+}
+
+func TestLicenseConstraintsEnforced(t *testing.T) {
+	GroupFixturePreparers(
+		prepareForLicenseConstraintsTest,
+		FixtureModifyProductVariables(func(variables FixtureProductVariables) {
+			variables.EnforceLicenseConstraints = BoolPtr(true)
+		}),
+	).ExtendWithErrorHandler(FixtureExpectsAllErrorsToMatchAPattern([]string{
+		`module "vendor_bin": license conditions \["by_exception_only" "proprietary"\] don't allow linking in "libgpl"`,
+	})).RunTestWithBp(t, licenseConstraintsBp)
+}
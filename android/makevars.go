@@ -198,6 +198,11 @@ type makeVarsContext struct {
 	vars    []makeVarsVariable
 	phonies []phony
 	dists   []dist
+
+	// Calls to the SingletonContext that aren't safe to make from concurrent providers, which
+	// are replayed in order by replay once the provider returns.
+	deferred []func()
+	failed   bool
 }
 
 var _ MakeVarsContext = &makeVarsContext{}
@@ -246,30 +251,10 @@ func (s *makeVarsSingleton) GenerateBuildActions(ctx SingletonContext) {
 	providers := append([]makeVarsProvider(nil), makeVarsInitProviders...)
 	providers = append(providers, *getSingletonMakevarsProviders(ctx.Config())...)
 
-	for _, provider := range providers {
-		mctx := &makeVarsContext{
-			SingletonContext: ctx,
-			pctx:             provider.pctx,
-		}
-
-		provider.call(mctx)
-
-		vars = append(vars, mctx.vars...)
-		phonies = append(phonies, mctx.phonies...)
-		dists = append(dists, mctx.dists...)
-	}
-
+	var moduleProviders []ModuleMakeVarsProvider
 	ctx.VisitAllModules(func(m Module) {
 		if provider, ok := m.(ModuleMakeVarsProvider); ok && m.Enabled() {
-			mctx := &makeVarsContext{
-				SingletonContext: ctx,
-			}
-
-			provider.MakeVars(mctx)
-
-			vars = append(vars, mctx.vars...)
-			phonies = append(phonies, mctx.phonies...)
-			dists = append(dists, mctx.dists...)
+			moduleProviders = append(moduleProviders, provider)
 		}
 
 		if m.ExportedToMake() {
@@ -278,6 +263,29 @@ func (s *makeVarsSingleton) GenerateBuildActions(ctx SingletonContext) {
 		}
 	})
 
+	// Run the providers concurrently, each with its own context, and merge the contexts in order
+	// so that the output doesn't depend on the scheduling.
+	mctxs := make([]*makeVarsContext, len(providers)+len(moduleProviders))
+	ParallelForEach(len(mctxs), func(i int) {
+		mctx := &makeVarsContext{
+			SingletonContext: ctx,
+		}
+		if i < len(providers) {
+			mctx.pctx = providers[i].pctx
+			providers[i].call(mctx)
+		} else {
+			moduleProviders[i-len(providers)].MakeVars(mctx)
+		}
+		mctxs[i] = mctx
+	})
+
+	for _, mctx := range mctxs {
+		mctx.replay()
+		vars = append(vars, mctx.vars...)
+		phonies = append(phonies, mctx.phonies...)
+		dists = append(dists, mctx.dists...)
+	}
+
 	if ctx.Failed() {
 		return
 	}
@@ -519,6 +527,32 @@ func (s *makeVarsSingleton) writeInstalls(installs, symlinks []katiInstall) []by
 	return buf.Bytes()
 }
 
+func (c *makeVarsContext) Errorf(format string, args ...interface{}) {
+	c.failed = true
+	c.deferred = append(c.deferred, func() { c.SingletonContext.Errorf(format, args...) })
+}
+
+func (c *makeVarsContext) ModuleErrorf(module blueprint.Module, format string, args ...interface{}) {
+	c.failed = true
+	c.deferred = append(c.deferred, func() { c.SingletonContext.ModuleErrorf(module, format, args...) })
+}
+
+func (c *makeVarsContext) AddNinjaFileDeps(deps ...string) {
+	c.deferred = append(c.deferred, func() { c.SingletonContext.AddNinjaFileDeps(deps...) })
+}
+
+func (c *makeVarsContext) Failed() bool {
+	return c.failed || c.SingletonContext.Failed()
+}
+
+// replay makes the calls to the SingletonContext deferred while the provider was running.
+func (c *makeVarsContext) replay() {
+	for _, call := range c.deferred {
+		call()
+	}
+	c.deferred = nil
+}
+
 func (c *makeVarsContext) DeviceConfig() DeviceConfig {
 	return DeviceConfig{c.Config().deviceConfig}
 }
@@ -547,7 +581,7 @@ func (c *makeVarsContext) addVariableRaw(name, value string, strict, sort bool)
 func (c *makeVarsContext) addVariable(name, ninjaStr string, strict, sort bool) {
 	value, err := c.Eval(ninjaStr)
 	if err != nil {
-		c.SingletonContext.Errorf(err.Error())
+		c.Errorf(err.Error())
 	}
 	c.addVariableRaw(name, value, strict, sort)
 }
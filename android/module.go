@@ -450,7 +450,8 @@ type ModuleContext interface {
 	Build(pctx PackageContext, params BuildParams)
 	// Phony creates a Make-style phony rule, a rule with no commands that can depend on other
 	// phony rules or real files.  Phony can be called on the same name multiple times to add
-	// additional dependencies.
+	// additional dependencies.  The name is prefixed with "<phony_namespace>-" when the module
+	// sets phony_namespace, unless it is an aggregate phony target.
 	Phony(phony string, deps ...Path)
 
 	// GetMissingDependencies returns the list of dependencies that were passed to AddDependencies or related methods,
@@ -460,6 +461,11 @@ type ModuleContext interface {
 	// LicenseMetadataFile returns the path where the license metadata for this module will be
 	// generated.
 	LicenseMetadataFile() Path
+
+	// Getenv returns the value of an environment variable listed in the env_deps property of
+	// the module. It reports an error for variables that aren't listed, as the outputs of the
+	// module wouldn't be rebuilt when they change.
+	Getenv(key string) string
 }
 
 type Module interface {
@@ -777,6 +783,14 @@ type commonProperties struct {
 	// vendor who owns this module
 	Owner *string
 
+	// the bug component that issues with this module are filed under, reported with the OWNERS
+	// of the module when its build actions fail and ATTRIBUTE_BUILD_ERRORS=true.
+	Bug_component *string
+
+	// list of tags, such as "debug_tools", that select this module into the tagged_package
+	// modules with the same tag.
+	Tags []string
+
 	// whether this module is specific to an SoC (System-On-a-Chip). When set to true,
 	// it is installed into /vendor (or /system/vendor if vendor partition does not exist).
 	// Use `soc_specific` instead for better meaning.
@@ -822,6 +836,16 @@ type commonProperties struct {
 	// VINTF manifest fragments to be installed if this module is installed
 	Vintf_fragments []string `android:"path"`
 
+	// If set, the phony targets created by this module, other than the ones named after the
+	// module and the aggregate ones like droidcore, are named "<phony_namespace>-<target>", to
+	// avoid collisions with the phony targets of other modules.
+	Phony_namespace *string
+
+	// List of environment variables whose values affect the outputs of this module. Soong is
+	// rerun and the outputs of the module are rebuilt when any of them changes. Module types
+	// read them with ModuleContext.Getenv.
+	Env_deps []string
+
 	// names of other modules to install if this module is installed
 	Required []string `android:"arch_variant"`
 
@@ -1346,6 +1370,9 @@ type ModuleBase struct {
 	// The primary visibility property, may be nil, that controls access to the module.
 	primaryVisibilityProperty visibilityProperty
 
+	// The deprecated properties of the module type, see AddDeprecatedProperties.
+	deprecatedProperties []DeprecatedProperty
+
 	// The primary licenses property, may be nil, records license metadata for the module.
 	primaryLicensesProperty applicableLicensesProperty
 
@@ -1986,6 +2013,10 @@ func (m *ModuleBase) Owner() string {
 	return String(m.commonProperties.Owner)
 }
 
+func (m *ModuleBase) BugComponent() string {
+	return String(m.commonProperties.Bug_component)
+}
+
 func (m *ModuleBase) NoticeFiles() Paths {
 	return m.noticeFiles
 }
@@ -2074,6 +2105,8 @@ func (m *ModuleBase) generateModuleTarget(ctx ModuleContext) {
 
 	var deps Paths
 
+	// The phony targets named after the module are namespaced by the Soong namespace of the module
+	// rather than by phony_namespace, as other modules refer to them by the name of the module.
 	namespacePrefix := ctx.Namespace().id
 	if namespacePrefix != "" {
 		namespacePrefix = namespacePrefix + "-"
@@ -2081,14 +2114,14 @@ func (m *ModuleBase) generateModuleTarget(ctx ModuleContext) {
 
 	if len(allInstalledFiles) > 0 {
 		name := namespacePrefix + ctx.ModuleName() + "-install"
-		ctx.Phony(name, allInstalledFiles.Paths()...)
+		addPhony(ctx.Config(), modulePhonyOwner(ctx), name, allInstalledFiles.Paths()...)
 		m.installTarget = PathForPhony(ctx, name)
 		deps = append(deps, m.installTarget)
 	}
 
 	if len(allCheckbuildFiles) > 0 {
 		name := namespacePrefix + ctx.ModuleName() + "-checkbuild"
-		ctx.Phony(name, allCheckbuildFiles...)
+		addPhony(ctx.Config(), modulePhonyOwner(ctx), name, allCheckbuildFiles...)
 		m.checkbuildTarget = PathForPhony(ctx, name)
 		deps = append(deps, m.checkbuildTarget)
 	}
@@ -2099,7 +2132,7 @@ func (m *ModuleBase) generateModuleTarget(ctx ModuleContext) {
 			suffix = "-soong"
 		}
 
-		ctx.Phony(namespacePrefix+ctx.ModuleName()+suffix, deps...)
+		addPhony(ctx.Config(), modulePhonyOwner(ctx), namespacePrefix+ctx.ModuleName()+suffix, deps...)
 
 		m.blueprintDir = ctx.ModuleDir()
 	}
@@ -2275,6 +2308,8 @@ func (m *ModuleBase) GenerateBuildActions(blueprintCtx blueprint.ModuleContext)
 			return
 		}
 
+		ctx.envDepsFile = m.buildEnvDepsFile(ctx)
+
 		m.module.GenerateAndroidBuildActions(ctx)
 		if ctx.Failed() {
 			return
@@ -2325,12 +2360,42 @@ func (m *ModuleBase) GenerateBuildActions(blueprintCtx blueprint.ModuleContext)
 	m.packagingSpecsDepSet = newPackagingSpecsDepSet(m.packagingSpecs, dependencyPackagingSpecs)
 
 	buildLicenseMetadata(ctx, m.licenseMetadataFile)
+	checkLicenseConstraints(ctx)
 
 	m.buildParams = ctx.buildParams
 	m.ruleParams = ctx.ruleParams
 	m.variables = ctx.variables
 }
 
+// buildEnvDepsFile writes the values of the env_deps of the module to a file, and returns it. The
+// file only changes when one of the values changes, which reruns the rules that depend on it.
+func (m *ModuleBase) buildEnvDepsFile(ctx *moduleContext) Path {
+	if len(m.commonProperties.Env_deps) == 0 {
+		return nil
+	}
+
+	var lines []string
+	for _, key := range SortedUniqueStrings(m.commonProperties.Env_deps) {
+		if key == "" || strings.ContainsAny(key, "= ") {
+			ctx.PropertyErrorf("env_deps", "%q is not a valid environment variable name", key)
+			continue
+		}
+		lines = append(lines, key+"="+ctx.Config().Getenv(key))
+	}
+
+	envDepsFile := PathForModuleOut(ctx, "env_deps.txt")
+	WriteFileRule(ctx, envDepsFile, strings.Join(lines, "\n"))
+	return envDepsFile
+}
+
+func (m *moduleContext) Getenv(key string) string {
+	if !InList(key, m.module.base().commonProperties.Env_deps) {
+		m.ModuleErrorf("environment variable %q must be listed in env_deps to be used", key)
+		return ""
+	}
+	return m.Config().Getenv(key)
+}
+
 // Check the supplied dist structure to make sure that it is valid.
 //
 // property - the base property, e.g. dist or dists[1], which is combined with the
@@ -2508,6 +2573,14 @@ type moduleContext struct {
 	katiInstalls []katiInstall
 	katiSymlinks []katiInstall
 
+	// The number of post-install actions that were run, and whether one is running.
+	postInstallActions int
+	inPostInstall      bool
+
+	// The file recording the values of the env_deps of the module, which all of its rules
+	// depend on.
+	envDepsFile Path
+
 	// For tests
 	buildParams []BuildParams
 	ruleParams  map[blueprint.Rule]blueprint.RuleParams
@@ -2689,6 +2762,10 @@ func (m *moduleContext) Build(pctx PackageContext, params BuildParams) {
 			m.ModuleName(), strings.Join(missingDeps, ", ")))
 	}
 
+	if m.envDepsFile != nil {
+		params.Implicits = append(append(Paths(nil), params.Implicits...), m.envDepsFile)
+	}
+
 	if m.config.captureBuild {
 		m.buildParams = append(m.buildParams, params)
 	}
@@ -2705,7 +2782,10 @@ func (m *moduleContext) Build(pctx PackageContext, params BuildParams) {
 }
 
 func (m *moduleContext) Phony(name string, deps ...Path) {
-	addPhony(m.config, name, deps...)
+	if namespace := String(m.module.base().commonProperties.Phony_namespace); namespace != "" && !aggregatePhonies[name] {
+		name = namespace + "-" + name
+	}
+	addPhony(m.config, modulePhonyOwner(m), name, deps...)
 }
 
 func (m *moduleContext) GetMissingDependencies() []string {
@@ -3235,6 +3315,8 @@ func (m *moduleContext) installFile(installPath InstallPath, name string, srcPat
 
 	m.checkbuildFiles = append(m.checkbuildFiles, srcPath)
 
+	m.runPostInstallActions(installPath, name, fullInstallPath)
+
 	return fullInstallPath
 }
 
@@ -0,0 +1,174 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+func init() {
+	RegisterModuleInfoJSONBuildComponents(InitRegistrationContext)
+}
+
+func RegisterModuleInfoJSONBuildComponents(ctx RegistrationContext) {
+	ctx.RegisterSingletonType("module_info_json_v2", moduleInfoJSONSingletonFactory)
+}
+
+var PrepareForTestWithModuleInfoJSON = FixtureRegisterWithContext(RegisterModuleInfoJSONBuildComponents)
+
+// moduleInfoJSON is the schema of module-info-v2.json.  Unlike the module-info.json generated by
+// Make, which merges all the variants of a module into a single entry, it has an entry for every
+// variant of every Soong module, so that test infrastructure doesn't have to re-derive the
+// install paths and dependencies of the variants from the ninja files.
+//
+// The modules are keyed by their name, or by "//<namespace>:<name>" for the modules that are not
+// in the root namespace, as modules in different namespaces can have the same name.
+type moduleInfoJSON struct {
+	Path     string                  `json:"path"`
+	Type     string                  `json:"type"`
+	Variants []moduleInfoJSONVariant `json:"variants"`
+}
+
+type moduleInfoJSONVariant struct {
+	// Variant is the name of the variant, for example android_arm64_armv8-a_shared_hwasan.
+	Variant string `json:"variant"`
+
+	// Variations maps the name of each mutator that split the module, for example image, link or
+	// hwasan, to the variation of this variant.  The os and arch variations are reported
+	// separately in Os and Arch.
+	Variations map[string]string `json:"variations,omitempty"`
+
+	Os        string              `json:"os"`
+	Arch      string              `json:"arch"`
+	Image     string              `json:"image"`
+	Installed []string            `json:"installed,omitempty"`
+	Deps      []moduleInfoJSONDep `json:"deps,omitempty"`
+}
+
+type moduleInfoJSONDep struct {
+	// Name is the key of the dependency in module-info-v2.json.
+	Name    string `json:"name"`
+	Variant string `json:"variant"`
+}
+
+func moduleInfoJSONSingletonFactory() Singleton {
+	return &moduleInfoJSONSingleton{}
+}
+
+type moduleInfoJSONSingleton struct {
+	outputFile WritablePath
+}
+
+func (s *moduleInfoJSONSingleton) GenerateBuildActions(ctx SingletonContext) {
+	if !ctx.Config().ModuleInfoJSONV2() {
+		return
+	}
+
+	var namespaces []string
+	ctx.VisitAllModules(func(module Module) {
+		if n, ok := module.(*NamespaceModule); ok {
+			namespaces = append(namespaces, n.namespace.Path)
+		}
+	})
+	key := func(module Module) string {
+		return moduleInfoJSONKey(namespaces, ctx.ModuleDir(module), ctx.ModuleName(module))
+	}
+
+	modules := make(map[string]*moduleInfoJSON)
+
+	ctx.VisitAllModules(func(module Module) {
+		if _, ok := module.(*NamespaceModule); ok || !module.Enabled() {
+			return
+		}
+
+		name := key(module)
+		info := modules[name]
+		if info == nil {
+			info = &moduleInfoJSON{
+				Path: ctx.ModuleDir(module),
+				Type: ctx.ModuleType(module),
+			}
+			modules[name] = info
+		}
+
+		base := module.base()
+		variant := moduleInfoJSONVariant{
+			Variant: ctx.ModuleSubDir(module),
+			Os:      base.Os().String(),
+			Arch:    base.Arch().ArchType.String(),
+			Image:   base.commonProperties.ImageVariation,
+		}
+
+		for i, mutator := range base.commonProperties.DebugMutators {
+			if v := base.commonProperties.DebugVariations[i]; v != "" {
+				if variant.Variations == nil {
+					variant.Variations = make(map[string]string)
+				}
+				variant.Variations[mutator] = v
+			}
+		}
+
+		for _, installed := range module.FilesToInstall() {
+			variant.Installed = append(variant.Installed, installed.String())
+		}
+
+		seen := make(map[moduleInfoJSONDep]bool)
+		ctx.VisitDirectDeps(module, func(dep Module) {
+			d := moduleInfoJSONDep{
+				Name:    key(dep),
+				Variant: ctx.ModuleSubDir(dep),
+			}
+			if !seen[d] {
+				seen[d] = true
+				variant.Deps = append(variant.Deps, d)
+			}
+		})
+
+		info.Variants = append(info.Variants, variant)
+	})
+
+	content, err := json.MarshalIndent(modules, "", "  ")
+	if err != nil {
+		ctx.Errorf("failed to marshal module-info-v2.json: %s", err)
+		return
+	}
+
+	s.outputFile = PathForOutput(ctx, "module-info-v2.json")
+	WriteFileRule(ctx, s.outputFile, string(content))
+
+	ctx.Phony("module-info-v2", s.outputFile)
+}
+
+// moduleInfoJSONKey returns the key of a module in module-info-v2.json from the directory and name
+// of the module, given the directories of the namespaces.
+func moduleInfoJSONKey(namespaces []string, dir, name string) string {
+	namespace := ""
+	for _, ns := range namespaces {
+		if (dir == ns || strings.HasPrefix(dir, ns+"/")) && len(ns) > len(namespace) {
+			namespace = ns
+		}
+	}
+	if namespace == "" || namespace == "." {
+		return name
+	}
+	return "//" + namespace + ":" + name
+}
+
+func (s *moduleInfoJSONSingleton) MakeVars(ctx MakeVarsContext) {
+	if s.outputFile != nil {
+		ctx.DistForGoal("module-info-v2", s.outputFile)
+	}
+}
@@ -0,0 +1,104 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestModuleInfoJSON(t *testing.T) {
+	bp := `
+		deps {
+			name: "foo",
+			deps: ["bar"],
+		}
+
+		deps {
+			name: "bar",
+		}
+	`
+
+	prepareForModuleInfoJSONTest := GroupFixturePreparers(
+		prepareForModuleTests,
+		PrepareForTestWithArchMutator,
+		PrepareForTestWithNamespace,
+		PrepareForTestWithModuleInfoJSON,
+		FixtureAddTextFile("vendor/a/Android.bp", `
+			soong_namespace {
+			}
+
+			deps {
+				name: "foo",
+			}
+		`),
+	)
+
+	t.Run("disabled", func(t *testing.T) {
+		result := prepareForModuleInfoJSONTest.RunTestWithBp(t, bp)
+		output := result.SingletonForTests("module_info_json_v2").MaybeOutput("module-info-v2.json")
+		if output.Rule != nil {
+			t.Errorf("expected module-info-v2.json not to be generated")
+		}
+	})
+
+	t.Run("enabled", func(t *testing.T) {
+		result := GroupFixturePreparers(
+			prepareForModuleInfoJSONTest,
+			FixtureMergeEnv(map[string]string{
+				"SOONG_MODULE_INFO_JSON_V2": "true",
+			}),
+		).RunTestWithBp(t, bp)
+
+		output := result.SingletonForTests("module_info_json_v2").Output("module-info-v2.json")
+
+		var modules map[string]moduleInfoJSON
+		if err := json.Unmarshal([]byte(ContentFromFileRuleForTests(t, output)), &modules); err != nil {
+			t.Fatalf("failed to parse module-info-v2.json: %s", err)
+		}
+
+		foo, ok := modules["foo"]
+		if !ok {
+			t.Fatalf("missing foo in module-info-v2.json")
+		}
+		AssertStringEquals(t, "type", "deps", foo.Type)
+
+		var device *moduleInfoJSONVariant
+		for i := range foo.Variants {
+			if foo.Variants[i].Variant == "android_common" {
+				device = &foo.Variants[i]
+			}
+		}
+		if device == nil {
+			t.Fatalf("missing android_common variant of foo in %v", foo.Variants)
+		}
+
+		AssertStringEquals(t, "os", "android", device.Os)
+		AssertStringEquals(t, "arch", "common", device.Arch)
+		AssertStringListContains(t, "installed",
+			StringPathsRelativeToTop(result.Config.soongOutDir, device.Installed),
+			"out/soong/target/product/test_device/system/foo")
+		AssertDeepEquals(t, "deps",
+			[]moduleInfoJSONDep{{Name: "bar", Variant: "android_common"}}, device.Deps)
+
+		// The module with the same name in another namespace has its own entry.
+		namespacedFoo, ok := modules["//vendor/a:foo"]
+		if !ok {
+			t.Fatalf("missing //vendor/a:foo in module-info-v2.json")
+		}
+		AssertStringEquals(t, "path", "vendor/a", namespacedFoo.Path)
+		AssertIntEquals(t, "variants", len(foo.Variants), len(namespacedFoo.Variants))
+	})
+}
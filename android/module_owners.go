@@ -0,0 +1,149 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"strings"
+)
+
+func init() {
+	RegisterModuleOwnersBuildComponents(InitRegistrationContext)
+}
+
+func RegisterModuleOwnersBuildComponents(ctx RegistrationContext) {
+	ctx.RegisterSingletonType("module_owners", moduleOwnersSingletonFactory)
+}
+
+// ModuleOwnersFileName is the name of the file in the Soong output directory that maps the
+// intermediates directory of every module to its owners.  It is read by soong_ui to attribute
+// failed build actions to the owners of the module that they belong to.
+const ModuleOwnersFileName = "module_owners.json"
+
+// moduleOwners is the attribution of a module in module_owners.json.
+type moduleOwners struct {
+	Name string `json:"name"`
+	Path string `json:"path"`
+
+	// OwnersFile is the OWNERS file closest to the module directory, and Owners are the owners
+	// listed in it.
+	OwnersFile string   `json:"owners_file,omitempty"`
+	Owners     []string `json:"owners,omitempty"`
+
+	BugComponent string `json:"bug_component,omitempty"`
+}
+
+func moduleOwnersSingletonFactory() Singleton {
+	return &moduleOwnersSingleton{}
+}
+
+type moduleOwnersSingleton struct{}
+
+func (s *moduleOwnersSingleton) GenerateBuildActions(ctx SingletonContext) {
+	if !ctx.Config().AttributeBuildErrors() {
+		return
+	}
+
+	content, err := json.MarshalIndent(collectModuleOwners(ctx), "", "  ")
+	if err != nil {
+		ctx.Errorf("failed to marshal %s: %s", ModuleOwnersFileName, err)
+		return
+	}
+
+	// The file is written by Soong rather than by a rule so that it is available to soong_ui
+	// when any of the build actions fail.
+	outputFile := PathForOutput(ctx, ModuleOwnersFileName)
+	if err := WriteFileToOutputDir(outputFile, content, 0666); err != nil {
+		ctx.Errorf("failed to write %s: %s", outputFile, err)
+	}
+}
+
+// collectModuleOwners returns the attribution of every module keyed by its intermediates
+// directory.
+func collectModuleOwners(ctx SingletonContext) map[string]moduleOwners {
+	resolver := &ownersResolver{ctx: ctx, dirs: make(map[string]string)}
+	owners := make(map[string]moduleOwners)
+	ctx.VisitAllModules(func(module Module) {
+		dir := ctx.ModuleDir(module)
+		name := ctx.ModuleName(module)
+		key := PathForOutput(ctx, ".intermediates", dir, name).String()
+		if _, exists := owners[key]; exists {
+			return
+		}
+
+		info := moduleOwners{
+			Name:         name,
+			Path:         dir,
+			BugComponent: module.base().BugComponent(),
+		}
+		if ownersFile := resolver.ownersFile(dir); ownersFile != "" {
+			info.OwnersFile = ownersFile
+			info.Owners = resolver.owners(ownersFile)
+		}
+		owners[key] = info
+	})
+	return owners
+}
+
+// ownersResolver finds the OWNERS file closest to a directory, caching the result for every
+// directory that it visits.
+type ownersResolver struct {
+	ctx    SingletonContext
+	dirs   map[string]string
+	parsed map[string][]string
+}
+
+func (r *ownersResolver) ownersFile(dir string) string {
+	if ownersFile, ok := r.dirs[dir]; ok {
+		return ownersFile
+	}
+
+	ownersFile := ""
+	candidate := filepath.Join(dir, "OWNERS")
+	if exists, isDir, err := r.ctx.Config().fs.Exists(candidate); err == nil && exists && !isDir {
+		ownersFile = candidate
+	} else if dir != "." && dir != "" {
+		ownersFile = r.ownersFile(filepath.Dir(dir))
+	}
+	r.dirs[dir] = ownersFile
+	return ownersFile
+}
+
+// owners returns the email addresses listed in an OWNERS file, ignoring comments, per-file rules
+// and included files.
+func (r *ownersResolver) owners(ownersFile string) []string {
+	if owners, ok := r.parsed[ownersFile]; ok {
+		return owners
+	}
+	if r.parsed == nil {
+		r.parsed = make(map[string][]string)
+	}
+
+	var owners []string
+	if data, err := r.ctx.Config().ReadSourceFile(r.ctx, ownersFile); err == nil {
+		for _, line := range strings.Split(string(data), "\n") {
+			if i := strings.Index(line, "#"); i >= 0 {
+				line = line[:i]
+			}
+			line = strings.TrimSpace(line)
+			if strings.Contains(line, "@") && !strings.ContainsAny(line, " :=") {
+				owners = append(owners, line)
+			}
+		}
+	}
+	r.parsed[ownersFile] = owners
+	return owners
+}
@@ -0,0 +1,82 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"testing"
+)
+
+// testModuleOwnersSingleton collects the module owners without writing module_owners.json, which
+// is written outside of the build rules.
+type testModuleOwnersSingleton struct {
+	owners map[string]moduleOwners
+}
+
+func (s *testModuleOwnersSingleton) GenerateBuildActions(ctx SingletonContext) {
+	s.owners = collectModuleOwners(ctx)
+}
+
+func TestModuleOwners(t *testing.T) {
+	result := GroupFixturePreparers(
+		prepareForModuleTests,
+		PrepareForTestWithArchMutator,
+		FixtureRegisterWithContext(func(ctx RegistrationContext) {
+			ctx.RegisterSingletonType("test_module_owners", func() Singleton {
+				return &testModuleOwnersSingleton{}
+			})
+		}),
+		FixtureAddTextFile("foo/OWNERS", "# Owners of foo\n"+
+			"alice@example.com\n"+
+			"bob@example.com # backup\n"+
+			"per-file *.bp = carol@example.com\n"+
+			"file:/build/OWNERS\n"),
+		FixtureAddTextFile("foo/Android.bp", `
+			deps {
+				name: "foo",
+				bug_component: "12345",
+			}
+		`),
+		FixtureAddTextFile("foo/bar/Android.bp", `
+			deps {
+				name: "bar",
+			}
+		`),
+		FixtureAddTextFile("baz/Android.bp", `
+			deps {
+				name: "baz",
+			}
+		`),
+	).RunTest(t)
+
+	owners := result.SingletonForTests("test_module_owners").Singleton().(*testModuleOwnersSingleton).owners
+	ctx := PathContextForTesting(result.Config)
+	ownersOf := func(dir, name string) moduleOwners {
+		return owners[PathForOutput(ctx, ".intermediates", dir, name).String()]
+	}
+
+	foo := ownersOf("foo", "foo")
+	AssertStringEquals(t, "foo owners file", "foo/OWNERS", foo.OwnersFile)
+	AssertArrayString(t, "foo owners", []string{"alice@example.com", "bob@example.com"}, foo.Owners)
+	AssertStringEquals(t, "foo bug component", "12345", foo.BugComponent)
+
+	bar := ownersOf("foo/bar", "bar")
+	AssertStringEquals(t, "bar path", "foo/bar", bar.Path)
+	AssertStringEquals(t, "bar owners file", "foo/OWNERS", bar.OwnersFile)
+	AssertStringEquals(t, "bar bug component", "", bar.BugComponent)
+
+	baz := ownersOf("baz", "baz")
+	AssertStringEquals(t, "baz name", "baz", baz.Name)
+	AssertStringEquals(t, "baz owners file", "", baz.OwnersFile)
+}
@@ -0,0 +1,201 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/google/blueprint/proptools"
+)
+
+// Module property patches allow a product to set properties of named modules from the product
+// config, for example to enable sanitize.memtag_heap on a list of modules, without forking the
+// Android.bp files that define them.  A patch is specified in the product variables as:
+//
+//	"ModulePropertyPatches": [
+//	    {
+//	        "Modules": ["libfoo", "libbar"],
+//	        "Property": "sanitize.memtag_heap",
+//	        "Value": true
+//	    }
+//	]
+//
+// The value is decoded from JSON into the type of the property, and replaces the value set in
+// the Android.bp file, including any value set in its arch specific or product variable
+// properties.  Properties that select the variants of a module, for example vendor_available,
+// can't be patched as the patches are applied after the variants are created.
+
+func init() {
+	RegisterModulePropertyPatchesBuildComponents(InitRegistrationContext)
+}
+
+func RegisterModulePropertyPatchesBuildComponents(ctx RegistrationContext) {
+	ctx.RegisterSingletonType("module_property_patches", modulePropertyPatchesSingletonFactory)
+}
+
+// PrepareForTestWithModulePropertyPatches registers the mutator and the singleton that apply and
+// report the module property patches.  The mutator is registered with the variable mutator, which
+// it must run after.
+var PrepareForTestWithModulePropertyPatches = GroupFixturePreparers(
+	PrepareForTestWithVariables,
+	FixtureRegisterWithContext(RegisterModulePropertyPatchesBuildComponents),
+)
+
+// ModulePropertyPatch sets a property of the named modules to a value.
+type ModulePropertyPatch struct {
+	// The names of the modules to patch.
+	Modules []string
+
+	// The name of the property to set, with the names of nested properties separated by dots.
+	Property string
+
+	// The JSON encoded value to set the property to.
+	Value json.RawMessage
+}
+
+func registerModulePropertyPatchesMutator(ctx RegisterMutatorsContext) {
+	ctx.BottomUp("module_property_patches", modulePropertyPatchesMutator).Parallel()
+}
+
+func modulePropertyPatchesMutator(ctx BottomUpMutatorContext) {
+	for _, patch := range ctx.Config().productVariables.ModulePropertyPatches {
+		if !InList(ctx.ModuleName(), patch.Modules) {
+			continue
+		}
+		if err := applyModulePropertyPatch(ctx.Module().GetProperties(), patch); err != nil {
+			ctx.ModuleErrorf("module property patch of %q: %s", patch.Property, err)
+		}
+	}
+}
+
+// applyModulePropertyPatch sets the property of the patch in every property struct that has it,
+// and returns an error if none of them has it or if the value can't be decoded into it.
+func applyModulePropertyPatch(props []interface{}, patch ModulePropertyPatch) error {
+	found := false
+	for _, p := range props {
+		field, err := modulePropertyPatchField(reflect.ValueOf(p).Elem(), strings.Split(patch.Property, "."))
+		if err != nil {
+			return err
+		}
+		if !field.IsValid() {
+			continue
+		}
+		found = true
+
+		value := reflect.New(field.Type())
+		if err := json.Unmarshal(patch.Value, value.Interface()); err != nil {
+			return fmt.Errorf("invalid value %s for property of type %s: %s", string(patch.Value), field.Type(), err)
+		}
+		field.Set(value.Elem())
+	}
+	if !found {
+		return fmt.Errorf("no such property")
+	}
+	return nil
+}
+
+// modulePropertyPatchField returns the field of the property struct for the property with the
+// given nested names, allocating the nil pointers to structs on the way, or an invalid value if
+// the struct doesn't have the property.
+func modulePropertyPatchField(v reflect.Value, names []string) (reflect.Value, error) {
+	field := v.FieldByName(proptools.FieldNameForProperty(names[0]))
+	if !field.IsValid() || !field.CanSet() {
+		return reflect.Value{}, nil
+	}
+	if len(names) == 1 {
+		if field.Kind() == reflect.Struct || field.Kind() == reflect.Interface {
+			return reflect.Value{}, fmt.Errorf("can only patch properties that are not property structs")
+		}
+		return field, nil
+	}
+
+	if field.Kind() == reflect.Ptr && field.Type().Elem().Kind() == reflect.Struct {
+		if field.IsNil() {
+			field.Set(reflect.New(field.Type().Elem()))
+		}
+		field = field.Elem()
+	}
+	if field.Kind() != reflect.Struct {
+		return reflect.Value{}, nil
+	}
+	return modulePropertyPatchField(field, names[1:])
+}
+
+func modulePropertyPatchesSingletonFactory() Singleton {
+	return &modulePropertyPatchesSingleton{}
+}
+
+// modulePropertyPatchesSingleton checks that the modules named by the module property patches
+// exist and writes a report of the modules they were applied to.
+type modulePropertyPatchesSingleton struct {
+	outputFile WritablePath
+}
+
+type modulePropertyPatchReport struct {
+	Property string          `json:"property"`
+	Value    json.RawMessage `json:"value"`
+	Modules  []string        `json:"modules"`
+}
+
+func (s *modulePropertyPatchesSingleton) GenerateBuildActions(ctx SingletonContext) {
+	patches := ctx.Config().productVariables.ModulePropertyPatches
+	if len(patches) == 0 {
+		return
+	}
+
+	modules := make(map[string]bool)
+	ctx.VisitAllModules(func(module Module) {
+		modules[ctx.ModuleName(module)] = true
+	})
+
+	var report []modulePropertyPatchReport
+	for _, patch := range patches {
+		var missing []string
+		for _, name := range patch.Modules {
+			if !modules[name] {
+				missing = append(missing, name)
+			}
+		}
+		if len(missing) > 0 {
+			sort.Strings(missing)
+			ctx.Errorf("module property patch of %q: unknown modules %q", patch.Property, missing)
+			continue
+		}
+		report = append(report, modulePropertyPatchReport{
+			Property: patch.Property,
+			Value:    patch.Value,
+			Modules:  SortedUniqueStrings(patch.Modules),
+		})
+	}
+
+	content, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		ctx.Errorf("failed to marshal module property patches report: %s", err)
+		return
+	}
+
+	s.outputFile = PathForOutput(ctx, "module_property_patches.json")
+	WriteFileRule(ctx, s.outputFile, string(content))
+}
+
+func (s *modulePropertyPatchesSingleton) MakeVars(ctx MakeVarsContext) {
+	if s.outputFile != nil {
+		ctx.DistForGoal("droidcore", s.outputFile)
+	}
+}
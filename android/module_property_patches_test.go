@@ -0,0 +1,172 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/google/blueprint/proptools"
+)
+
+type patchedModule struct {
+	ModuleBase
+	props struct {
+		Cflags   []string
+		Sanitize *struct {
+			Memtag_heap *bool
+		}
+	}
+}
+
+func (m *patchedModule) GenerateAndroidBuildActions(ctx ModuleContext) {}
+
+func patchedModuleFactory() Module {
+	m := &patchedModule{}
+	m.AddProperties(&m.props)
+	InitAndroidArchModule(m, HostAndDeviceDefault, MultilibCommon)
+	return m
+}
+
+func TestModulePropertyPatches(t *testing.T) {
+	bp := `
+		patched {
+			name: "foo",
+			cflags: ["-DFOO"],
+			product_variables: {
+				debuggable: {
+					cflags: ["-DDEBUG"],
+				},
+			},
+		}
+
+		patched {
+			name: "bar",
+		}
+	`
+
+	prepareForModulePropertyPatchesTest := GroupFixturePreparers(
+		PrepareForTestWithArchMutator,
+		PrepareForTestWithModulePropertyPatches,
+		FixtureRegisterWithContext(func(ctx RegistrationContext) {
+			ctx.RegisterModuleType("patched", patchedModuleFactory)
+		}),
+	)
+
+	fixtureModulePropertyPatches := func(patches ...ModulePropertyPatch) FixturePreparer {
+		return FixtureModifyProductVariables(func(variables FixtureProductVariables) {
+			variables.ModulePropertyPatches = patches
+			variables.Debuggable = proptools.BoolPtr(true)
+		})
+	}
+
+	t.Run("applied", func(t *testing.T) {
+		result := GroupFixturePreparers(
+			prepareForModulePropertyPatchesTest,
+			fixtureModulePropertyPatches(
+				ModulePropertyPatch{
+					Modules:  []string{"foo"},
+					Property: "cflags",
+					Value:    json.RawMessage(`["-DBAR"]`),
+				},
+				ModulePropertyPatch{
+					Modules:  []string{"foo", "bar"},
+					Property: "sanitize.memtag_heap",
+					Value:    json.RawMessage(`true`),
+				},
+			),
+		).RunTestWithBp(t, bp)
+
+		// The patch overrides the cflags set in product_variables too.
+		foo := result.ModuleForTests("foo", "android_common").Module().(*patchedModule)
+		AssertArrayString(t, "foo cflags", []string{"-DBAR"}, foo.props.Cflags)
+		AssertBoolEquals(t, "foo memtag_heap", true, proptools.Bool(foo.props.Sanitize.Memtag_heap))
+
+		bar := result.ModuleForTests("bar", "android_common").Module().(*patchedModule)
+		AssertArrayString(t, "bar cflags", nil, bar.props.Cflags)
+		AssertBoolEquals(t, "bar memtag_heap", true, proptools.Bool(bar.props.Sanitize.Memtag_heap))
+
+		output := result.SingletonForTests("module_property_patches").Output("module_property_patches.json")
+		var report []modulePropertyPatchReport
+		if err := json.Unmarshal([]byte(ContentFromFileRuleForTests(t, output)), &report); err != nil {
+			t.Fatalf("failed to parse module_property_patches.json: %s", err)
+		}
+		AssertIntEquals(t, "number of patches", 2, len(report))
+		AssertStringEquals(t, "property", "sanitize.memtag_heap", report[1].Property)
+		AssertArrayString(t, "modules", []string{"bar", "foo"}, report[1].Modules)
+	})
+
+	t.Run("no patches", func(t *testing.T) {
+		result := prepareForModulePropertyPatchesTest.RunTestWithBp(t, bp)
+		output := result.SingletonForTests("module_property_patches").MaybeOutput("module_property_patches.json")
+		if output.Rule != nil {
+			t.Errorf("expected module_property_patches.json not to be generated")
+		}
+	})
+
+	errorTestCases := []struct {
+		name  string
+		patch ModulePropertyPatch
+		err   string
+	}{
+		{
+			name: "unknown property",
+			patch: ModulePropertyPatch{
+				Modules:  []string{"foo"},
+				Property: "sanitize.memtag_stack",
+				Value:    json.RawMessage(`true`),
+			},
+			err: `module "foo" variant "android_common": module property patch of "sanitize.memtag_stack": no such property`,
+		},
+		{
+			name: "invalid value",
+			patch: ModulePropertyPatch{
+				Modules:  []string{"foo"},
+				Property: "cflags",
+				Value:    json.RawMessage(`"-DBAR"`),
+			},
+			err: `module property patch of "cflags": invalid value "-DBAR" for property of type \[\]string`,
+		},
+		{
+			name: "property struct",
+			patch: ModulePropertyPatch{
+				Modules:  []string{"foo"},
+				Property: "sanitize",
+				Value:    json.RawMessage(`{}`),
+			},
+			err: `module property patch of "sanitize": can only patch properties that are not property structs`,
+		},
+		{
+			name: "unknown module",
+			patch: ModulePropertyPatch{
+				Modules:  []string{"foo", "baz"},
+				Property: "cflags",
+				Value:    json.RawMessage(`[]`),
+			},
+			err: `module property patch of "cflags": unknown modules \["baz"\]`,
+		},
+	}
+
+	for _, tc := range errorTestCases {
+		t.Run(tc.name, func(t *testing.T) {
+			GroupFixturePreparers(
+				prepareForModulePropertyPatchesTest,
+				fixtureModulePropertyPatches(tc.patch),
+			).
+				ExtendWithErrorHandler(FixtureExpectsAtLeastOneErrorMatchingPattern(tc.err)).
+				RunTestWithBp(t, bp)
+		})
+	}
+}
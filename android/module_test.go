@@ -911,3 +911,85 @@ func TestSortedUniqueNamedPaths(t *testing.T) {
 		})
 	}
 }
+
+type envDepsTestModule struct {
+	ModuleBase
+	props struct {
+		Env *string
+	}
+	value string
+}
+
+func (m *envDepsTestModule) GenerateAndroidBuildActions(ctx ModuleContext) {
+	if env := String(m.props.Env); env != "" {
+		m.value = ctx.Getenv(env)
+	}
+	ctx.Build(pctx, BuildParams{
+		Rule:   Touch,
+		Output: PathForModuleOut(ctx, "out"),
+	})
+}
+
+func envDepsTestModuleFactory() Module {
+	m := &envDepsTestModule{}
+	m.AddProperties(&m.props)
+	InitAndroidModule(m)
+	return m
+}
+
+func TestEnvDeps(t *testing.T) {
+	prepareForEnvDepsTest := GroupFixturePreparers(
+		PrepareForTestWithArchMutator,
+		FixtureRegisterWithContext(func(ctx RegistrationContext) {
+			ctx.RegisterModuleType("test", envDepsTestModuleFactory)
+		}),
+		FixtureMergeEnv(map[string]string{
+			"FOO": "foo value",
+			"BAR": "bar",
+		}),
+	)
+
+	result := prepareForEnvDepsTest.RunTestWithBp(t, `
+		test {
+			name: "foo",
+			env: "FOO",
+			env_deps: ["FOO", "BAR"],
+		}
+		test {
+			name: "bar",
+		}
+	`)
+
+	foo := result.ModuleForTests("foo", "android_common")
+	AssertStringEquals(t, "value of FOO", "foo value", foo.Module().(*envDepsTestModule).value)
+
+	envDepsFile := foo.Output("env_deps.txt")
+	AssertStringEquals(t, "env_deps.txt", "BAR=bar\nFOO=foo value\n",
+		ContentFromFileRuleForTests(t, envDepsFile))
+
+	// All the rules of the module depend on the values of its env_deps.
+	AssertPathsRelativeToTopEquals(t, "implicits", []string{"out/soong/.intermediates/foo/android_common/env_deps.txt"},
+		foo.Output("out").Implicits)
+	AssertPathsRelativeToTopEquals(t, "implicits", nil,
+		result.ModuleForTests("bar", "android_common").Output("out").Implicits)
+
+	prepareForEnvDepsTest.
+		ExtendWithErrorHandler(FixtureExpectsAtLeastOneErrorMatchingPattern(
+			`environment variable "FOO" must be listed in env_deps to be used`)).
+		RunTestWithBp(t, `
+			test {
+				name: "foo",
+				env: "FOO",
+			}
+		`)
+
+	prepareForEnvDepsTest.
+		ExtendWithErrorHandler(FixtureExpectsAtLeastOneErrorMatchingPattern(
+			`env_deps: "FOO=bar" is not a valid environment variable name`)).
+		RunTestWithBp(t, `
+			test {
+				name: "foo",
+				env_deps: ["FOO=bar"],
+			}
+		`)
+}
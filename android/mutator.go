@@ -182,6 +182,12 @@ func registerArchMutator(ctx RegisterMutatorsContext) {
 
 var preDeps = []RegisterMutatorFunc{
 	registerArchMutator,
+
+	// Record the deprecated properties that are set in modules.
+	//
+	// This must run after the arch mutator so that the properties set in arch and target specific
+	// properties are found.
+	registerDeprecatedPropertiesMutator,
 }
 
 var postDeps = []RegisterMutatorFunc{
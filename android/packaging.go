@@ -62,6 +62,16 @@ func (p *PackagingSpec) SetRelPathInPackage(relPathInPackage string) {
 	p.relPathInPackage = relPathInPackage
 }
 
+// The path to the built artifact, or nil for a symlink
+func (p *PackagingSpec) SrcPath() Path {
+	return p.srcPath
+}
+
+// The target of the symlink, or "" if this is not a symlink
+func (p *PackagingSpec) SymlinkTarget() string {
+	return p.symlinkTarget
+}
+
 func (p *PackagingSpec) EffectiveLicenseFiles() Paths {
 	if p.effectiveLicenseFiles == nil {
 		return Paths{}
@@ -210,12 +220,19 @@ func (PackagingItemAlwaysDepTag) IsPackagingItem() bool {
 
 // See PackageModule.AddDeps
 func (p *PackagingBase) AddDeps(ctx BottomUpMutatorContext, depTag blueprint.DependencyTag) {
+	p.AddDepsWithVariations(ctx, depTag, nil)
+}
+
+// AddDepsWithVariations is like AddDeps, but the dependencies are added with the given variations
+// (e.g. an image variation) in addition to the variations of each supported target.
+func (p *PackagingBase) AddDepsWithVariations(ctx BottomUpMutatorContext, depTag blueprint.DependencyTag,
+	variations []blueprint.Variation) {
 	for _, t := range p.getSupportedTargets(ctx) {
 		for _, dep := range p.getDepsForArch(ctx, t.Arch.ArchType) {
 			if p.IgnoreMissingDependencies && !ctx.OtherModuleExists(dep) {
 				continue
 			}
-			ctx.AddFarVariationDependencies(t.Variations(), depTag, dep)
+			ctx.AddFarVariationDependencies(append(t.Variations(), variations...), depTag, dep)
 		}
 	}
 }
@@ -15,33 +15,91 @@
 package android
 
 import (
+	"fmt"
+	"strings"
 	"sync"
 
 	"github.com/google/blueprint"
 )
 
 var phonyMapOnceKey = NewOnceKey("phony")
+var phonyOwnersOnceKey = NewOnceKey("phony_owners")
 
 type phonyMap map[string]Paths
 
+// phonyOwners maps each phony target to the modules and singletons that create it, which are
+// "//<dir>:<module>" for modules and "singleton <name>" for singletons.
+type phonyOwners map[string][]string
+
 var phonyMapLock sync.Mutex
 
+// aggregatePhonies are the phony targets that are expected to be created by more than one
+// module or singleton.
+var aggregatePhonies = make(map[string]bool)
+
+func init() {
+	RegisterAggregatePhony("droidcore")
+}
+
+// RegisterAggregatePhony declares phony targets that aggregate the outputs of more than one
+// module or singleton, like droidcore, so that creating them from more than one module or
+// singleton isn't reported as a collision.  It must be called from init().
+func RegisterAggregatePhony(names ...string) {
+	for _, name := range names {
+		aggregatePhonies[name] = true
+	}
+}
+
 func getPhonyMap(config Config) phonyMap {
 	return config.Once(phonyMapOnceKey, func() interface{} {
 		return make(phonyMap)
 	}).(phonyMap)
 }
 
-func addPhony(config Config, name string, deps ...Path) {
+func getPhonyOwners(config Config) phonyOwners {
+	return config.Once(phonyOwnersOnceKey, func() interface{} {
+		return make(phonyOwners)
+	}).(phonyOwners)
+}
+
+func addPhony(config Config, owner string, name string, deps ...Path) {
 	phonyMap := getPhonyMap(config)
+	phonyOwners := getPhonyOwners(config)
 	phonyMapLock.Lock()
 	defer phonyMapLock.Unlock()
 	phonyMap[name] = append(phonyMap[name], deps...)
+	if !InList(owner, phonyOwners[name]) {
+		phonyOwners[name] = append(phonyOwners[name], owner)
+	}
+}
+
+// modulePhonyOwner returns the owner of the phony targets created by a module, which is the same
+// for all the variants of the module.
+func modulePhonyOwner(ctx BaseModuleContext) string {
+	dir := ctx.ModuleDir()
+	if dir == "." {
+		dir = ""
+	}
+	return "//" + dir + ":" + ctx.ModuleName()
+}
+
+// phonyCollisions returns the phony targets that are created by more than one module or singleton
+// and aren't aggregate phony targets, as "<phony>: <owner>, <owner>..." lines.
+func phonyCollisions(phonyList []string, owners phonyOwners) []string {
+	var collisions []string
+	for _, phony := range phonyList {
+		if len(owners[phony]) > 1 && !aggregatePhonies[phony] {
+			collisions = append(collisions,
+				fmt.Sprintf("%s: %s", phony, strings.Join(SortedUniqueStrings(owners[phony]), ", ")))
+		}
+	}
+	return collisions
 }
 
 type phonySingleton struct {
-	phonyMap  phonyMap
-	phonyList []string
+	phonyMap       phonyMap
+	phonyList      []string
+	collisionsFile WritablePath
 }
 
 var _ SingletonMakeVarsProvider = (*phonySingleton)(nil)
@@ -53,6 +111,19 @@ func (p *phonySingleton) GenerateBuildActions(ctx SingletonContext) {
 		p.phonyMap[phony] = SortedUniquePaths(p.phonyMap[phony])
 	}
 
+	// Phony targets with the same name from unrelated modules or singletons are merged silently,
+	// or fail later with confusing ninja errors when the name is also used for a file.  Report
+	// them, and fail the build when the product asks for strict phony targets.
+	collisions := phonyCollisions(p.phonyList, getPhonyOwners(ctx.Config()))
+	if ctx.Config().StrictPhonyTargets() {
+		for _, collision := range collisions {
+			ctx.Errorf("phony target collision %s; set phony_namespace on the modules, rename the "+
+				"targets, or register them with RegisterAggregatePhony", collision)
+		}
+	}
+	p.collisionsFile = PathForOutput(ctx, "phony-collisions.txt")
+	WriteFileRule(ctx, p.collisionsFile, strings.Join(collisions, "\n"))
+
 	if !ctx.Config().KatiEnabled() {
 		for _, phony := range p.phonyList {
 			ctx.Build(pctx, BuildParams{
@@ -0,0 +1,105 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"testing"
+)
+
+type phonyTestModule struct {
+	ModuleBase
+	properties struct {
+		Phonies []string
+	}
+}
+
+func phonyTestModuleFactory() Module {
+	m := &phonyTestModule{}
+	m.AddProperties(&m.properties)
+	InitAndroidModule(m)
+	return m
+}
+
+func (m *phonyTestModule) GenerateAndroidBuildActions(ctx ModuleContext) {
+	for _, phony := range m.properties.Phonies {
+		ctx.Phony(phony, PathForModuleOut(ctx, phony))
+	}
+}
+
+type phonyTestSingleton struct{}
+
+func (s *phonyTestSingleton) GenerateBuildActions(ctx SingletonContext) {
+	ctx.Phony("other-target", PathForOutput(ctx, "other"))
+}
+
+var prepareForPhonyTest = GroupFixturePreparers(
+	FixtureRegisterWithContext(func(ctx RegistrationContext) {
+		ctx.RegisterModuleType("phony_test_module", phonyTestModuleFactory)
+		ctx.RegisterSingletonType("phony_test_singleton", func() Singleton {
+			return &phonyTestSingleton{}
+		})
+	}),
+	FixtureWithRootAndroidBp(`
+		phony_test_module {
+			name: "a",
+			phonies: ["shared-target", "droidcore"],
+		}
+
+		phony_test_module {
+			name: "b",
+			phonies: ["shared-target", "other-target", "droidcore"],
+		}
+
+		phony_test_module {
+			name: "c",
+			phonies: ["shared-target", "droidcore"],
+			phony_namespace: "c_ns",
+		}
+	`),
+)
+
+func TestPhonyCollisions(t *testing.T) {
+	result := prepareForPhonyTest.RunTest(t)
+
+	phony := result.SingletonForTests("phony")
+	AssertStringEquals(t, "phony-collisions.txt",
+		"other-target: //:b, singleton phony_test_singleton\n"+
+			"shared-target: //:a, //:b\n",
+		ContentFromFileRuleForTests(t, phony.Output("phony-collisions.txt")))
+
+	// The namespaced phony target of c doesn't collide, and the aggregate droidcore target isn't
+	// namespaced.
+	phonies := getPhonyMap(result.Config)
+	AssertPathsRelativeToTopEquals(t, "c_ns-shared-target", []string{
+		"out/soong/.intermediates/c/shared-target",
+	}, phonies["c_ns-shared-target"])
+	AssertPathsRelativeToTopEquals(t, "droidcore", []string{
+		"out/soong/.intermediates/a/droidcore",
+		"out/soong/.intermediates/b/droidcore",
+		"out/soong/.intermediates/c/droidcore",
+	}, phonies["droidcore"])
+}
+
+func TestStrictPhonyTargets(t *testing.T) {
+	GroupFixturePreparers(
+		prepareForPhonyTest,
+		FixtureModifyProductVariables(func(variables FixtureProductVariables) {
+			variables.StrictPhonyTargets = BoolPtr(true)
+		}),
+	).ExtendWithErrorHandler(FixtureExpectsAllErrorsToMatchAPattern([]string{
+		`phony target collision other-target: //:b, singleton phony_test_singleton`,
+		`phony target collision shared-target: //:a, //:b`,
+	})).RunTest(t)
+}
@@ -0,0 +1,174 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Plugins are Go packages outside of build/soong that add module types to soong_build by setting
+// pluginFor: ["soong_build"] in their bootstrap_go_package.  Most of the android package changes
+// frequently, so plugins that use ModuleContext or ModuleBase directly break whenever the code
+// they rely on is refactored.  The stable plugin API below is the subset of the android package
+// that plugins can rely on:
+//
+//   - Plugins register themselves with RegisterPlugin from an init function, passing the version
+//     of the plugin API they were written against.
+//   - Plugin module types embed PluginModuleBase, are initialized with InitPluginModule and
+//     implement GeneratePluginBuildActions instead of GenerateAndroidBuildActions.
+//   - GeneratePluginBuildActions receives a PluginModuleContext, whose methods keep their meaning
+//     for all supported versions of the plugin API.
+//
+// Methods are only added to the plugin interfaces when PluginApiVersion is incremented, and only
+// removed when MinPluginApiVersion is incremented past the version that added them.  soong_build
+// calls CheckPlugins at startup to reject plugins written against unsupported versions.
+
+const (
+	// PluginApiVersion is the current version of the stable plugin API.
+	PluginApiVersion = 1
+
+	// MinPluginApiVersion is the oldest version of the stable plugin API that is still supported.
+	MinPluginApiVersion = 1
+)
+
+// PluginModuleContext is the context passed to GeneratePluginBuildActions.  It also implements
+// ModuleOutPathContext, so plugins can use PathForModuleOut and PathForModuleGen.
+type PluginModuleContext interface {
+	ModuleOutPathContext
+
+	ModuleType() string
+	Os() OsType
+	Arch() Arch
+	Host() bool
+	Device() bool
+
+	// Getenv returns the value of an environment variable listed in the env_deps property of
+	// the module.
+	Getenv(key string) string
+
+	ModuleErrorf(fmt string, args ...interface{})
+	PropertyErrorf(property, fmt string, args ...interface{})
+	Failed() bool
+
+	// ExpandSources expands the source files and module references in a path property.
+	ExpandSources(srcFiles, excludes []string) Paths
+
+	// ExpandSource expands a single source file or module reference in a path property.
+	ExpandSource(srcFile, prop string) Path
+
+	Build(pctx PackageContext, params BuildParams)
+
+	// PathForModuleInstall returns the install path of the module in its partition.
+	PathForModuleInstall(pathComponents ...string) InstallPath
+
+	InstallFile(installPath InstallPath, name string, srcPath Path, deps ...Path) InstallPath
+	InstallExecutable(installPath InstallPath, name string, srcPath Path, deps ...Path) InstallPath
+}
+
+// pluginModuleContext adapts a ModuleContext to the PluginModuleContext interface.
+type pluginModuleContext struct {
+	ModuleContext
+}
+
+var _ PluginModuleContext = pluginModuleContext{}
+
+func (ctx pluginModuleContext) PathForModuleInstall(pathComponents ...string) InstallPath {
+	return PathForModuleInstall(ctx.ModuleContext, pathComponents...)
+}
+
+// PluginModule is the interface implemented by the module types of plugins.
+type PluginModule interface {
+	Module
+
+	// GeneratePluginBuildActions generates the build actions of the module.
+	GeneratePluginBuildActions(ctx PluginModuleContext)
+}
+
+// PluginModuleBase is embedded in the module types of plugins.
+type PluginModuleBase struct {
+	ModuleBase
+}
+
+func (p *PluginModuleBase) GenerateAndroidBuildActions(ctx ModuleContext) {
+	p.module.(PluginModule).GeneratePluginBuildActions(pluginModuleContext{ctx})
+}
+
+// InitPluginModule initializes a plugin module.  Property structs must be added with
+// AddProperties before calling it.
+func InitPluginModule(m PluginModule, hod HostOrDeviceSupported, defaultMultilib Multilib) {
+	InitAndroidArchModule(m, hod, defaultMultilib)
+}
+
+// PluginModuleFactory is a factory for the module types of plugins.
+type PluginModuleFactory func() PluginModule
+
+// PluginRegistrationContext is passed to the register function of RegisterPlugin.
+type PluginRegistrationContext interface {
+	RegisterModuleType(name string, factory PluginModuleFactory)
+}
+
+type pluginRegistrationContext struct {
+	ctx RegistrationContext
+}
+
+func (p pluginRegistrationContext) RegisterModuleType(name string, factory PluginModuleFactory) {
+	p.ctx.RegisterModuleType(name, func() Module { return factory() })
+}
+
+type registeredPlugin struct {
+	name       string
+	apiVersion int
+}
+
+var plugins []registeredPlugin
+
+// RegisterPlugin registers the module types of a plugin written against the given version of the
+// stable plugin API.  It must be called from an init function of the plugin.  The module types of
+// a plugin written against an unsupported version are not registered, and CheckPlugins reports
+// the plugin.
+func RegisterPlugin(name string, apiVersion int, register func(ctx PluginRegistrationContext)) {
+	registerPlugin(InitRegistrationContext, name, apiVersion, register)
+}
+
+func registerPlugin(ctx RegistrationContext, name string, apiVersion int, register func(ctx PluginRegistrationContext)) {
+	plugins = append(plugins, registeredPlugin{name, apiVersion})
+	if checkPluginApiVersion(name, apiVersion) == nil {
+		register(pluginRegistrationContext{ctx})
+	}
+}
+
+func checkPluginApiVersion(name string, apiVersion int) error {
+	if apiVersion < MinPluginApiVersion || apiVersion > PluginApiVersion {
+		return fmt.Errorf("plugin %q uses version %d of the plugin API, supported versions are %d to %d",
+			name, apiVersion, MinPluginApiVersion, PluginApiVersion)
+	}
+	return nil
+}
+
+// CheckPlugins returns an error listing the plugins that were written against unsupported versions
+// of the stable plugin API.
+func CheckPlugins() error {
+	var errs []string
+	for _, p := range plugins {
+		if err := checkPluginApiVersion(p.name, p.apiVersion); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("incompatible soong_build plugins:\n  %s", strings.Join(errs, "\n  "))
+	}
+	return nil
+}
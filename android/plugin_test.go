@@ -0,0 +1,102 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"testing"
+)
+
+type testPluginModule struct {
+	PluginModuleBase
+
+	properties struct {
+		Src *string `android:"path"`
+	}
+}
+
+func (m *testPluginModule) GeneratePluginBuildActions(ctx PluginModuleContext) {
+	src := ctx.ExpandSource(String(m.properties.Src), "src")
+	out := PathForModuleOut(ctx, ctx.ModuleName()+".txt")
+	ctx.Build(pctx, BuildParams{
+		Rule:   Cp,
+		Input:  src,
+		Output: out,
+	})
+	ctx.InstallFile(ctx.PathForModuleInstall("etc"), out.Base(), out)
+}
+
+func testPluginModuleFactory() PluginModule {
+	m := &testPluginModule{}
+	m.AddProperties(&m.properties)
+	InitPluginModule(m, DeviceSupported, MultilibFirst)
+	return m
+}
+
+func withTestPlugins(t *testing.T) {
+	saved := plugins
+	plugins = nil
+	t.Cleanup(func() { plugins = saved })
+}
+
+func TestPluginModule(t *testing.T) {
+	withTestPlugins(t)
+
+	result := GroupFixturePreparers(
+		PrepareForTestWithArchMutator,
+		FixtureRegisterWithContext(func(ctx RegistrationContext) {
+			registerPlugin(ctx, "test_plugin", PluginApiVersion, func(ctx PluginRegistrationContext) {
+				ctx.RegisterModuleType("test_plugin_module", testPluginModuleFactory)
+			})
+		}),
+		FixtureWithRootAndroidBp(`
+			test_plugin_module {
+				name: "foo",
+				src: "foo.txt",
+			}
+		`),
+		FixtureAddFile("foo.txt", nil),
+	).RunTest(t)
+
+	foo := result.ModuleForTests("foo", "android_arm64_armv8-a")
+	cp := foo.Output("foo.txt")
+	AssertPathRelativeToTopEquals(t, "input", "foo.txt", cp.Input)
+
+	install := foo.Output("out/soong/target/product/test_device/system/etc/foo.txt")
+	AssertPathRelativeToTopEquals(t, "installed input", "out/soong/.intermediates/foo/android_arm64_armv8-a/foo.txt", install.Input)
+
+	AssertDeepEquals(t, "CheckPlugins", nil, CheckPlugins())
+}
+
+func TestPluginIncompatibleApiVersion(t *testing.T) {
+	withTestPlugins(t)
+
+	registered := false
+	ctx := NewTestContext(TestConfig(t.TempDir(), nil, "", nil))
+	registerPlugin(ctx, "old_plugin", MinPluginApiVersion-1, func(ctx PluginRegistrationContext) {
+		registered = true
+	})
+	registerPlugin(ctx, "new_plugin", PluginApiVersion+1, func(ctx PluginRegistrationContext) {
+		registered = true
+	})
+
+	AssertBoolEquals(t, "module types of incompatible plugins registered", false, registered)
+
+	err := CheckPlugins()
+	if err == nil {
+		t.Fatal("expected CheckPlugins to fail")
+	}
+	AssertStringDoesContain(t, "error", err.Error(), `plugin "old_plugin" uses version 0 of the plugin API`)
+	AssertStringDoesContain(t, "error", err.Error(), `plugin "new_plugin" uses version 2 of the plugin API`)
+}
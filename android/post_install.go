@@ -0,0 +1,80 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// PostInstallActionsProvider is implemented by module types that need to run actions on the files
+// they install, e.g. to sign them or to compute their checksums, instead of relying on custom make
+// rules.
+type PostInstallActionsProvider interface {
+	// PostInstallActions returns the actions to run on the file installed at installed. It is
+	// called by ModuleContext.InstallFile and its variants, for every file that is installed.
+	PostInstallActions(ctx ModuleContext, installed InstallPath) []PostInstallAction
+}
+
+// PostInstallAction generates a file from an installed file. The generated file is installed next
+// to the installed file, and is built whenever the installed file is.
+type PostInstallAction struct {
+	// Name of the generated file.
+	Name string
+
+	// Build adds the command that generates out from installed to cmd. The command depends on
+	// installed even if Build doesn't add it as an input, and must write out.
+	Build func(cmd *RuleBuilderCommand, installed InstallPath, out WritablePath)
+}
+
+// runPostInstallActions runs the PostInstallActions of the module on the file it installed at
+// installed, as name in the installDir directory.
+func (m *moduleContext) runPostInstallActions(installDir InstallPath, name string, installed InstallPath) {
+	provider, ok := m.module.(PostInstallActionsProvider)
+	if !ok || m.inPostInstall || m.skipInstall() {
+		return
+	}
+
+	// The files generated by the actions are installed with InstallFile, which must not run
+	// the actions on them again.
+	m.inPostInstall = true
+	defer func() { m.inPostInstall = false }()
+
+	// The name may include subdirectories of installDir, which the generated files are installed
+	// in too.
+	if dir := filepath.Dir(name); dir != "." {
+		installDir = installDir.Join(m, dir)
+	}
+
+	for _, action := range provider.PostInstallActions(m, installed) {
+		if action.Name == "" || strings.Contains(action.Name, "/") {
+			m.ModuleErrorf("post-install action for %s: %q is not a valid file name",
+				installed.Base(), action.Name)
+			continue
+		}
+
+		out := PathForModuleOut(m, "post_install", installed.Rel(), action.Name)
+		rule := NewRuleBuilder(pctx, m)
+		cmd := rule.Command()
+		action.Build(cmd, installed, out)
+		cmd.Implicit(installed)
+		rule.Build(fmt.Sprintf("post_install_%d", m.postInstallActions),
+			fmt.Sprintf("post-install %s %s", installed.Base(), action.Name))
+		m.postInstallActions++
+
+		m.InstallFile(installDir, action.Name, out)
+	}
+}
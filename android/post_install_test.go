@@ -0,0 +1,119 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"testing"
+)
+
+type postInstallModule struct {
+	ModuleBase
+	props struct {
+		Action_name  *string
+		Install_name *string
+	}
+}
+
+func (m *postInstallModule) GenerateAndroidBuildActions(ctx ModuleContext) {
+	outputFile := PathForModuleOut(ctx, ctx.ModuleName())
+	ctx.Build(pctx, BuildParams{
+		Rule:   Touch,
+		Output: outputFile,
+	})
+	ctx.InstallFile(PathForModuleInstall(ctx, "bin"), StringDefault(m.props.Install_name, ctx.ModuleName()), outputFile)
+}
+
+func (m *postInstallModule) PostInstallActions(ctx ModuleContext, installed InstallPath) []PostInstallAction {
+	return []PostInstallAction{{
+		Name: StringDefault(m.props.Action_name, installed.Base()+".sha256"),
+		Build: func(cmd *RuleBuilderCommand, installed InstallPath, out WritablePath) {
+			cmd.Text("sha256sum").Input(installed).Text(">").Output(out)
+		},
+	}}
+}
+
+func postInstallModuleFactory() Module {
+	m := &postInstallModule{}
+	m.AddProperties(&m.props)
+	InitAndroidModule(m)
+	return m
+}
+
+var prepareForPostInstallTest = FixtureRegisterWithContext(func(ctx RegistrationContext) {
+	ctx.RegisterModuleType("post_install", postInstallModuleFactory)
+})
+
+func TestPostInstallActions(t *testing.T) {
+	result := GroupFixturePreparers(
+		PrepareForTestWithArchMutator,
+		prepareForPostInstallTest,
+	).RunTestWithBp(t, `
+		post_install {
+			name: "foo",
+		}
+	`)
+
+	foo := result.ModuleForTests("foo", "android_common")
+	installed := "out/soong/target/product/test_device/system/bin/foo"
+
+	action := foo.Rule("post_install_0")
+	AssertPathRelativeToTopEquals(t, "post-install action output",
+		"out/soong/.intermediates/foo/android_common/post_install/bin/foo/foo.sha256", action.Output)
+	AssertStringListContains(t, "post-install action inputs",
+		PathsRelativeToTop(action.Implicits), installed)
+
+	// The generated file is installed next to the installed file.
+	install := foo.Output(installed + ".sha256")
+	AssertPathRelativeToTopEquals(t, "installed post-install file",
+		action.Output, install.Input)
+
+	// The generated file doesn't get post-install actions of its own.
+	if rule := foo.MaybeRule("post_install_1"); rule.Rule != nil {
+		t.Errorf("unexpected post-install action on %s", rule.Output)
+	}
+	AssertDeepEquals(t, "install files", []string{installed, installed + ".sha256"},
+		PathsRelativeToTop(foo.Module().FilesToInstall().Paths()))
+}
+
+func TestPostInstallActionsInSubdir(t *testing.T) {
+	result := GroupFixturePreparers(
+		PrepareForTestWithArchMutator,
+		prepareForPostInstallTest,
+	).RunTestWithBp(t, `
+		post_install {
+			name: "foo",
+			install_name: "sub/foo",
+		}
+	`)
+
+	foo := result.ModuleForTests("foo", "android_common")
+	installed := "out/soong/target/product/test_device/system/bin/sub/foo"
+	AssertDeepEquals(t, "install files", []string{installed, installed + ".sha256"},
+		PathsRelativeToTop(foo.Module().FilesToInstall().Paths()))
+}
+
+func TestPostInstallActionsInvalidName(t *testing.T) {
+	GroupFixturePreparers(
+		PrepareForTestWithArchMutator,
+		prepareForPostInstallTest,
+	).ExtendWithErrorHandler(FixtureExpectsAtLeastOneErrorMatchingPattern(
+		`post-install action for foo: "sub/foo.sha256" is not a valid file name`)).
+		RunTestWithBp(t, `
+			post_install {
+				name: "foo",
+				action_name: "sub/foo.sha256",
+			}
+		`)
+}
@@ -0,0 +1,105 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"encoding/json"
+)
+
+func init() {
+	RegisterProductConfigJSONBuildComponents(InitRegistrationContext)
+}
+
+func RegisterProductConfigJSONBuildComponents(ctx RegistrationContext) {
+	ctx.RegisterSingletonType("product_config_json", productConfigJSONSingletonFactory)
+}
+
+var PrepareForTestWithProductConfigJSON = FixtureRegisterWithContext(RegisterProductConfigJSONBuildComponents)
+
+// productConfigJSONSchemaVersion is incremented when fields of product-config.json are renamed or
+// removed, so that tools can detect incompatible changes.  Adding fields doesn't change it.
+const productConfigJSONSchemaVersion = 1
+
+// productConfigJSON is the schema of product-config.json, which records the product configuration
+// that the build used, so that external tools can reason about what the build was configured to
+// do without re-implementing how Soong reads and resolves it.
+type productConfigJSON struct {
+	SchemaVersion int `json:"schema_version"`
+
+	// ProductVariables are the product variables after Soong applied its defaults, including the
+	// path lists that select the modules built with sanitizers such as CFI and memtag.
+	ProductVariables productVariables `json:"product_variables"`
+
+	// Targets maps each OS to the targets that modules are built for.
+	Targets map[string][]string `json:"targets"`
+
+	// BuildOSTarget is the target of the tools that run on the build machine.
+	BuildOSTarget string `json:"build_os_target"`
+
+	PlatformSdkVersion       string `json:"platform_sdk_version"`
+	AllowMissingDependencies bool   `json:"allow_missing_dependencies"`
+	UnbundledBuild           bool   `json:"unbundled_build"`
+	Debuggable               bool   `json:"debuggable"`
+	Eng                      bool   `json:"eng"`
+}
+
+func productConfigJSONSingletonFactory() Singleton {
+	return &productConfigJSONSingleton{}
+}
+
+type productConfigJSONSingleton struct {
+	outputFile WritablePath
+}
+
+func (s *productConfigJSONSingleton) GenerateBuildActions(ctx SingletonContext) {
+	config := ctx.Config()
+
+	productConfig := productConfigJSON{
+		SchemaVersion:            productConfigJSONSchemaVersion,
+		ProductVariables:         config.productVariables,
+		Targets:                  make(map[string][]string),
+		BuildOSTarget:            config.BuildOSTarget.String(),
+		PlatformSdkVersion:       config.PlatformSdkVersion().String(),
+		AllowMissingDependencies: config.AllowMissingDependencies(),
+		UnbundledBuild:           config.UnbundledBuild(),
+		Debuggable:               config.Debuggable(),
+		Eng:                      config.Eng(),
+	}
+	for os, targets := range config.Targets {
+		for _, target := range targets {
+			productConfig.Targets[os.String()] = append(productConfig.Targets[os.String()], target.String())
+		}
+	}
+
+	// encoding/json writes the struct fields in declaration order and the map keys sorted, so the
+	// output only changes when the configuration does.  The struct is marshaled through a pointer
+	// so that the pointer receiver MarshalJSON of ConfiguredJarList is used for the boot jars.
+	content, err := json.MarshalIndent(&productConfig, "", "  ")
+	if err != nil {
+		ctx.Errorf("failed to marshal product-config.json: %s", err)
+		return
+	}
+
+	s.outputFile = PathForOutput(ctx, "product-config.json")
+	WriteFileRule(ctx, s.outputFile, string(content))
+
+	ctx.Phony("product-config-json", s.outputFile)
+}
+
+func (s *productConfigJSONSingleton) MakeVars(ctx MakeVarsContext) {
+	if s.outputFile != nil {
+		ctx.DistForGoals([]string{"droidcore", "product-config-json"}, s.outputFile)
+	}
+}
@@ -0,0 +1,50 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestProductConfigJSON(t *testing.T) {
+	result := GroupFixturePreparers(
+		PrepareForTestWithArchMutator,
+		PrepareForTestWithProductConfigJSON,
+		FixtureModifyProductVariables(func(variables FixtureProductVariables) {
+			variables.CFIIncludePaths = []string{"external/foo"}
+			variables.MemtagHeapExcludePaths = []string{"external/bar"}
+			variables.BootJars = CreateTestConfiguredJarList([]string{"platform:framework", "com.android.art:core-oj"})
+		}),
+	).RunTest(t)
+
+	output := result.SingletonForTests("product_config_json").Output("product-config.json")
+
+	var productConfig productConfigJSON
+	if err := json.Unmarshal([]byte(ContentFromFileRuleForTests(t, output)), &productConfig); err != nil {
+		t.Fatalf("failed to parse product-config.json: %s", err)
+	}
+
+	AssertIntEquals(t, "schema_version", productConfigJSONSchemaVersion, productConfig.SchemaVersion)
+	AssertDeepEquals(t, "CFIIncludePaths", []string{"external/foo"},
+		productConfig.ProductVariables.CFIIncludePaths)
+	AssertDeepEquals(t, "MemtagHeapExcludePaths", []string{"external/bar"},
+		productConfig.ProductVariables.MemtagHeapExcludePaths)
+	AssertDeepEquals(t, "BootJars", []string{"platform:framework", "com.android.art:core-oj"},
+		productConfig.ProductVariables.BootJars.CopyOfApexJarPairs())
+	AssertDeepEquals(t, "android targets", []string{"android_arm64_armv8-a", "android_arm_armv7-a-neon"},
+		productConfig.Targets["android"])
+	AssertStringEquals(t, "build_os_target", result.Config.BuildOSTarget.String(), productConfig.BuildOSTarget)
+}
@@ -0,0 +1,190 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/google/blueprint"
+	"github.com/google/blueprint/proptools"
+)
+
+// A size_budget module declares the maximum total size of the files installed to a partition,
+// or installed by a set of modules:
+//
+//	size_budget {
+//	    name: "vendor_budget",
+//	    partition: "vendor",
+//	    max_bytes: 524288000,
+//	}
+//
+// The budget is checked against the installed-files.json of the partitions written by the
+// installed_files singleton, by a validation of the installed-files.json of each partition the
+// budget applies to, and when building size-budgets or the name of the size_budget module.  The
+// report lists the largest files, and their size deltas from the installed-files.json of a
+// previous build if baseline is set.
+
+var (
+	_ = pctx.HostBinToolVariable("checkSizeBudgetCmd", "check_size_budget")
+
+	checkSizeBudgetRule = pctx.AndroidStaticRule("checkSizeBudget", blueprint.RuleParams{
+		Command:     "${checkSizeBudgetCmd} --name $name $args --output $out $in",
+		CommandDeps: []string{"${checkSizeBudgetCmd}"},
+	}, "name", "args")
+)
+
+func init() {
+	RegisterSizeBudgetBuildComponents(InitRegistrationContext)
+}
+
+func RegisterSizeBudgetBuildComponents(ctx RegistrationContext) {
+	ctx.RegisterModuleType("size_budget", SizeBudgetFactory)
+}
+
+// PrepareForTestWithSizeBudget registers the size_budget module type and the singleton that
+// checks the budgets.
+var PrepareForTestWithSizeBudget = GroupFixturePreparers(
+	PrepareForTestWithInstalledFiles,
+	FixtureRegisterWithContext(RegisterSizeBudgetBuildComponents),
+)
+
+type sizeBudgetProperties struct {
+	// The partition whose installed files count against the budget, e.g. "system" or "vendor".
+	Partition *string
+
+	// The modules whose installed files count against the budget.
+	Modules []string
+
+	// The maximum total size of the installed files in bytes.
+	Max_bytes *int64
+
+	// The installed-files.json files of a previous build to report the size deltas against.
+	Baseline []string `android:"path"`
+
+	// The number of the largest files to list in the report.  Defaults to 10.
+	Top_offenders *int64
+}
+
+type sizeBudget struct {
+	ModuleBase
+
+	properties sizeBudgetProperties
+
+	baseline Paths
+}
+
+func SizeBudgetFactory() Module {
+	module := &sizeBudget{}
+	module.AddProperties(&module.properties)
+	InitAndroidModule(module)
+	return module
+}
+
+func (b *sizeBudget) GenerateAndroidBuildActions(ctx ModuleContext) {
+	if (b.properties.Partition == nil) == (len(b.properties.Modules) == 0) {
+		ctx.ModuleErrorf("exactly one of partition and modules must be set")
+	}
+	if b.properties.Max_bytes == nil || *b.properties.Max_bytes <= 0 {
+		ctx.PropertyErrorf("max_bytes", "must be set to a positive number of bytes")
+	}
+	if b.properties.Top_offenders != nil && *b.properties.Top_offenders < 0 {
+		ctx.PropertyErrorf("top_offenders", "must not be negative")
+	}
+	b.baseline = PathsForModuleSrc(ctx, b.properties.Baseline)
+}
+
+// buildSizeBudgetChecks creates a rule for every size_budget module that checks the sizes of the
+// installed files in the installed-files.json of the partitions, and returns the reports, along
+// with the reports that validate the installed-files.json of each partition.
+func buildSizeBudgetChecks(ctx SingletonContext, files map[string][]installedFile,
+	installedFilesJson map[string]WritablePath) (Paths, map[string]Paths) {
+
+	modulePartitions := make(map[string][]string)
+	for _, partition := range SortedStringKeys(files) {
+		for _, file := range files[partition] {
+			if !InList(partition, modulePartitions[file.Module]) {
+				modulePartitions[file.Module] = append(modulePartitions[file.Module], partition)
+			}
+		}
+	}
+
+	var reports Paths
+	validations := make(map[string]Paths)
+	ctx.VisitAllModules(func(module Module) {
+		budget, ok := module.(*sizeBudget)
+		if !ok || !budget.Enabled() {
+			return
+		}
+
+		var partitions []string
+		var args []string
+		if budget.properties.Partition != nil {
+			partition := *budget.properties.Partition
+			if _, exists := installedFilesJson[partition]; !exists {
+				ctx.ModuleErrorf(budget, "no files are installed to partition %q", partition)
+				return
+			}
+			partitions = []string{partition}
+		} else {
+			var missing []string
+			for _, name := range budget.properties.Modules {
+				if len(modulePartitions[name]) == 0 {
+					missing = append(missing, name)
+				}
+				partitions = append(partitions, modulePartitions[name]...)
+				args = append(args, "--module "+proptools.ShellEscape(name))
+			}
+			if len(missing) > 0 {
+				ctx.ModuleErrorf(budget, "modules %q don't install any device files", missing)
+				return
+			}
+			partitions = SortedUniqueStrings(partitions)
+		}
+
+		var inputs Paths
+		for _, partition := range partitions {
+			inputs = append(inputs, installedFilesJson[partition])
+		}
+
+		args = append(args, "--max-bytes "+strconv.FormatInt(*budget.properties.Max_bytes, 10))
+		if budget.properties.Top_offenders != nil {
+			args = append(args, "--top "+strconv.FormatInt(*budget.properties.Top_offenders, 10))
+		}
+		for _, baseline := range budget.baseline {
+			args = append(args, "--baseline "+baseline.String())
+		}
+
+		report := PathForOutput(ctx, "size_budgets", ctx.ModuleName(budget)+".txt")
+		ctx.Build(pctx, BuildParams{
+			Rule:        checkSizeBudgetRule,
+			Description: "size budget " + ctx.ModuleName(budget),
+			Inputs:      inputs,
+			Implicits:   budget.baseline,
+			Output:      report,
+			Args: map[string]string{
+				"name": ctx.ModuleName(budget),
+				"args": strings.Join(args, " "),
+			},
+		})
+		reports = append(reports, report)
+		for _, partition := range partitions {
+			validations[partition] = append(validations[partition], report)
+		}
+		ctx.Phony(ctx.ModuleName(budget), report)
+	})
+
+	return reports, validations
+}
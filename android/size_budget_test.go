@@ -0,0 +1,152 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"testing"
+)
+
+var prepareForSizeBudgetTest = GroupFixturePreparers(
+	PrepareForTestWithArchMutator,
+	PrepareForTestWithSizeBudget,
+	FixtureRegisterWithContext(func(ctx RegistrationContext) {
+		ctx.RegisterModuleType("installed_files_test_module", installedFilesTestModuleFactory)
+	}),
+	FixtureModifyProductVariables(func(variables FixtureProductVariables) {
+		variables.ProductPackages = []string{"foo", "bar"}
+	}),
+)
+
+func TestSizeBudget(t *testing.T) {
+	bp := `
+		installed_files_test_module {
+			name: "foo",
+		}
+
+		installed_files_test_module {
+			name: "bar",
+			soc_specific: true,
+		}
+
+		size_budget {
+			name: "system_budget",
+			partition: "system",
+			max_bytes: 1024,
+		}
+
+		size_budget {
+			name: "modules_budget",
+			modules: ["foo", "bar"],
+			max_bytes: 2048,
+			baseline: ["previous/installed-files-system.json"],
+			top_offenders: 5,
+		}
+	`
+
+	result := GroupFixturePreparers(
+		prepareForSizeBudgetTest,
+		FixtureAddFile("previous/installed-files-system.json", nil),
+	).RunTestWithBp(t, bp)
+
+	singleton := result.SingletonForTests("installed_files")
+
+	systemBudget := singleton.Output("size_budgets/system_budget.txt")
+	AssertPathsRelativeToTopEquals(t, "system budget inputs",
+		[]string{"out/soong/installed_files/system/installed-files.json"}, systemBudget.Inputs)
+	AssertStringEquals(t, "system budget args", "--max-bytes 1024", systemBudget.Args["args"])
+
+	modulesBudget := singleton.Output("size_budgets/modules_budget.txt")
+	AssertPathsRelativeToTopEquals(t, "modules budget inputs", []string{
+		"out/soong/installed_files/system/installed-files.json",
+		"out/soong/installed_files/vendor/installed-files.json",
+	}, modulesBudget.Inputs)
+	AssertPathsRelativeToTopEquals(t, "modules budget implicits",
+		[]string{"previous/installed-files-system.json"}, modulesBudget.Implicits)
+	AssertStringEquals(t, "modules budget args",
+		"--module foo --module bar --max-bytes 2048 --top 5 --baseline previous/installed-files-system.json",
+		modulesBudget.Args["args"])
+
+	// The budgets validate installed-files.json of the partitions they apply to.
+	AssertArrayString(t, "system installed-files.json validations", []string{
+		"out/soong/size_budgets/modules_budget.txt",
+		"out/soong/size_budgets/system_budget.txt",
+	}, SortedUniqueStrings(PathsRelativeToTop(singleton.Output("installed_files/system/installed-files.json").Validations)))
+	AssertPathsRelativeToTopEquals(t, "vendor installed-files.json validations", []string{
+		"out/soong/size_budgets/modules_budget.txt",
+	}, singleton.Output("installed_files/vendor/installed-files.json").Validations)
+}
+
+func TestSizeBudgetErrors(t *testing.T) {
+	testCases := []struct {
+		name  string
+		bp    string
+		error string
+	}{
+		{
+			name: "neither partition nor modules",
+			bp: `
+				size_budget {
+					name: "budget",
+					max_bytes: 1024,
+				}
+			`,
+			error: `exactly one of partition and modules must be set`,
+		},
+		{
+			name: "missing max_bytes",
+			bp: `
+				size_budget {
+					name: "budget",
+					partition: "system",
+				}
+			`,
+			error: `max_bytes: must be set to a positive number of bytes`,
+		},
+		{
+			name: "empty partition",
+			bp: `
+				size_budget {
+					name: "budget",
+					partition: "odm",
+					max_bytes: 1024,
+				}
+			`,
+			error: `no files are installed to partition "odm"`,
+		},
+		{
+			name: "module without installed files",
+			bp: `
+				size_budget {
+					name: "budget",
+					modules: ["baz"],
+					max_bytes: 1024,
+				}
+			`,
+			error: `modules \["baz"\] don't install any device files`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			prepareForSizeBudgetTest.
+				ExtendWithErrorHandler(FixtureExpectsAtLeastOneErrorMatchingPattern(tc.error)).
+				RunTestWithBp(t, `
+					installed_files_test_module {
+						name: "foo",
+					}
+				`+tc.bp)
+		})
+	}
+}
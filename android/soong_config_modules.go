@@ -20,6 +20,7 @@ package android
 import (
 	"fmt"
 	"path/filepath"
+	"reflect"
 	"strings"
 	"text/scanner"
 
@@ -460,3 +461,96 @@ func configModuleFactory(factory blueprint.ModuleFactory, moduleType *soongconfi
 		return module, props
 	}
 }
+
+// soongConfigConditionalProperties contains the soong_config_conditional property of defaults
+// modules, which applies properties to the defaults module depending on a bool Soong config
+// variable without having to define a soong_config_module_type that wraps the defaults module
+// type.  For example, an Android.bp file could have:
+//
+//	cc_defaults {
+//	    name: "acme_defaults",
+//	    cflags: ["-DGENERIC"],
+//	    soong_config_conditional: {
+//	        config_namespace: "acme",
+//	        bool_variable: "feature",
+//	        properties: {
+//	            cflags: ["-DFEATURE"],
+//	        },
+//	        conditions_default: {
+//	            cflags: ["-DFEATURE_DEFAULT"],
+//	        },
+//	    },
+//	}
+//
+// If an acme BoardConfig.mk file contained:
+//
+//	$(call add_soong_config_var_value, acme, feature, true)
+//
+// Then modules using acme_defaults would build with cflags "-DGENERIC -DFEATURE".
+type soongConfigConditionalProperties struct {
+	Soong_config_conditional struct {
+		// the SOONG_CONFIG_NAMESPACE value from a BoardConfig.mk that the variable is read from.
+		Config_namespace *string
+
+		// the boolean SOONG_CONFIG variable that the properties depend on.
+		Bool_variable *string
+
+		// the properties that are applied if the variable is set to a true value.
+		Properties interface{}
+
+		// the properties that are applied if the variable is unspecified or not set to a true
+		// value.
+		Conditions_default interface{}
+	}
+}
+
+var soongConfigConditionalPropTypeMap OncePer
+
+// initSoongConfigConditional adds the soong_config_conditional property to a defaults module.  The
+// conditional properties can be any of the properties for which the defaults module provides
+// defaults.
+func initSoongConfigConditional(module DefaultsModule) {
+	props := module.properties()
+
+	// Use the soongConfigConditionalPropTypeMap OncePer to cache the type for each set of property
+	// struct types.
+	typ, _ := soongConfigConditionalPropTypeMap.Once(NewCustomOnceKey(sliceToTypeArray(props)), func() interface{} {
+		return soongconfig.AllPropertiesType(props)
+	}).(reflect.Type)
+
+	if typ == nil {
+		return
+	}
+
+	// The conditional properties are nil pointers until they are set in the Android.bp file.
+	conditionalProps := &soongConfigConditionalProperties{}
+	conditional := &conditionalProps.Soong_config_conditional
+	conditional.Properties = reflect.Zero(typ).Interface()
+	conditional.Conditions_default = reflect.Zero(typ).Interface()
+	module.AddProperties(conditionalProps)
+
+	isSet := func(props interface{}) bool {
+		return !reflect.ValueOf(props).IsNil()
+	}
+
+	AddLoadHook(module, func(ctx LoadHookContext) {
+		if conditional.Config_namespace == nil && conditional.Bool_variable == nil &&
+			!isSet(conditional.Properties) && !isSet(conditional.Conditions_default) {
+			return
+		}
+
+		if conditional.Config_namespace == nil || conditional.Bool_variable == nil {
+			ctx.PropertyErrorf("soong_config_conditional", "config_namespace and bool_variable must be set")
+			return
+		}
+
+		config := ctx.Config().VendorConfig(*conditional.Config_namespace)
+		if config.Bool(*conditional.Bool_variable) {
+			if isSet(conditional.Properties) {
+				ctx.AppendProperties(conditional.Properties)
+			}
+		} else if isSet(conditional.Conditions_default) {
+			ctx.AppendProperties(conditional.Conditions_default)
+		}
+	})
+}
@@ -426,6 +426,95 @@ func TestDuplicateStringValueInSoongConfigStringVariable(t *testing.T) {
 	})).RunTest(t)
 }
 
+func TestSoongConfigConditionalDefaults(t *testing.T) {
+	bp := `
+		test_defaults {
+			name: "foo_defaults",
+			cflags: ["-DGENERIC"],
+			soong_config_conditional: {
+				config_namespace: "acme",
+				bool_variable: "feature",
+				properties: {
+					cflags: ["-DFEATURE"],
+				},
+				conditions_default: {
+					cflags: ["-DFEATURE_DEFAULT"],
+				},
+			},
+		}
+
+		test {
+			name: "foo",
+			defaults: ["foo_defaults"],
+			cflags: ["-DFOO"],
+		}
+	`
+
+	testCases := []struct {
+		name          string
+		vendorVars    map[string]map[string]string
+		expectedFlags []string
+	}{
+		{
+			name:          "set",
+			vendorVars:    map[string]map[string]string{"acme": {"feature": "true"}},
+			expectedFlags: []string{"-DGENERIC", "-DFEATURE", "-DFOO"},
+		},
+		{
+			name:          "false",
+			vendorVars:    map[string]map[string]string{"acme": {"feature": "false"}},
+			expectedFlags: []string{"-DGENERIC", "-DFEATURE_DEFAULT", "-DFOO"},
+		},
+		{
+			name:          "unset",
+			expectedFlags: []string{"-DGENERIC", "-DFEATURE_DEFAULT", "-DFOO"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result := GroupFixturePreparers(
+				FixtureModifyProductVariables(func(variables FixtureProductVariables) {
+					variables.VendorVars = tc.vendorVars
+				}),
+				PrepareForTestWithDefaults,
+				FixtureRegisterWithContext(func(ctx RegistrationContext) {
+					ctx.RegisterModuleType("test_defaults", soongConfigTestDefaultsModuleFactory)
+					ctx.RegisterModuleType("test", soongConfigTestModuleFactory)
+				}),
+				FixtureWithRootAndroidBp(bp),
+			).RunTest(t)
+
+			foo := result.ModuleForTests("foo", "").Module().(*soongConfigTestModule)
+			AssertDeepEquals(t, "foo cflags", tc.expectedFlags, foo.props.Cflags)
+		})
+	}
+}
+
+func TestSoongConfigConditionalDefaultsMissingVariable(t *testing.T) {
+	bp := `
+		test_defaults {
+			name: "foo_defaults",
+			soong_config_conditional: {
+				config_namespace: "acme",
+				properties: {
+					cflags: ["-DFEATURE"],
+				},
+			},
+		}
+	`
+
+	GroupFixturePreparers(
+		PrepareForTestWithDefaults,
+		FixtureRegisterWithContext(func(ctx RegistrationContext) {
+			ctx.RegisterModuleType("test_defaults", soongConfigTestDefaultsModuleFactory)
+		}),
+		FixtureWithRootAndroidBp(bp),
+	).ExtendWithErrorHandler(FixtureExpectsAtLeastOneErrorMatchingPattern(
+		`soong_config_conditional: config_namespace and bool_variable must be set`,
+	)).RunTest(t)
+}
+
 func testConfigWithVendorVars(buildDir, bp string, fs map[string][]byte, vendorVars map[string]map[string]string) Config {
 	config := TestConfig(buildDir, nil, bp, fs)
 
@@ -462,6 +462,43 @@ func createAffectablePropertiesType(affectableProperties []string, factoryProps
 	return nil
 }
 
+// AllPropertiesType returns a pointer to a struct type with a field for each top level property
+// in a list of property structs, or nil if there are none.  Properties that are mutated by Soong
+// and properties of type interface{} are skipped.
+func AllPropertiesType(props []interface{}) reflect.Type {
+	seen := make(map[string]bool)
+	var properties []string
+
+	var collect func(typ reflect.Type)
+	collect = func(typ reflect.Type) {
+		for i := 0; i < typ.NumField(); i++ {
+			field := typ.Field(i)
+			if field.Anonymous && field.Type.Kind() == reflect.Struct {
+				// The fields of embedded structs are top level properties.
+				collect(field.Type)
+				continue
+			}
+			if field.PkgPath != "" || field.Type.Kind() == reflect.Interface ||
+				proptools.HasTag(field, "blueprint", "mutated") {
+				continue
+			}
+			property := proptools.PropertyNameForField(field.Name)
+			if !seen[property] {
+				seen[property] = true
+				properties = append(properties, property)
+			}
+		}
+	}
+
+	for _, ps := range props {
+		if typ := reflect.TypeOf(ps); typ.Kind() == reflect.Ptr && typ.Elem().Kind() == reflect.Struct {
+			collect(typ.Elem())
+		}
+	}
+
+	return createAffectablePropertiesType(properties, props)
+}
+
 func typeForPropertyFromPropertyStructs(psList []interface{}, property string) reflect.Type {
 	for _, ps := range psList {
 		if typ := typeForPropertyFromPropertyStruct(ps, property); typ != nil {
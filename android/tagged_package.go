@@ -0,0 +1,254 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+
+	"github.com/google/blueprint"
+	"github.com/google/blueprint/proptools"
+)
+
+// A tagged_package module packages all the modules whose tags property contains its tag, together
+// with their transitive dependencies, without a hand-maintained list of modules:
+//
+//	tagged_package {
+//	    name: "debug_tools_package",
+//	    tag: "debug_tools",
+//	}
+//
+// By default the files are packaged into a <name>.tar laid out like their partitions. With
+// type: "dir" they are installed instead into the partition of the tagged_package module, under
+// relative_install_path.
+
+func init() {
+	RegisterTaggedPackageBuildComponents(InitRegistrationContext)
+}
+
+func RegisterTaggedPackageBuildComponents(ctx RegistrationContext) {
+	ctx.RegisterModuleType("tagged_package", TaggedPackageFactory)
+	ctx.PreDepsMutators(func(ctx RegisterMutatorsContext) {
+		ctx.BottomUp("tagged_modules", taggedModulesMutator).Parallel()
+	})
+}
+
+// PrepareForTestWithTaggedPackage registers the tagged_package module type and the mutator that
+// collects the tagged modules.
+var PrepareForTestWithTaggedPackage = FixtureRegisterWithContext(RegisterTaggedPackageBuildComponents)
+
+// taggedModules maps each tag to the names of the modules that have it.
+type taggedModules struct {
+	lock    sync.Mutex
+	modules map[string][]string
+}
+
+var taggedModulesKey = NewOnceKey("taggedModules")
+
+func getTaggedModules(config Config) *taggedModules {
+	return config.Once(taggedModulesKey, func() interface{} {
+		return &taggedModules{modules: make(map[string][]string)}
+	}).(*taggedModules)
+}
+
+// names returns the sorted names of the modules that have the given tag.
+func (t *taggedModules) names(tag string) []string {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	return SortedUniqueStrings(t.modules[tag])
+}
+
+// taggedModulesMutator records the tags of each module, so that the tagged_package modules can
+// depend on the modules with their tag in their DepsMutator.
+func taggedModulesMutator(ctx BottomUpMutatorContext) {
+	m, ok := ctx.Module().(Module)
+	if !ok {
+		return
+	}
+	if _, ok := m.(Defaults); ok {
+		return
+	}
+	tags := m.base().commonProperties.Tags
+	if len(tags) == 0 {
+		return
+	}
+
+	t := getTaggedModules(ctx.Config())
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	for _, tag := range tags {
+		if tag == "" {
+			ctx.PropertyErrorf("tags", "tags must not be empty")
+			continue
+		}
+		t.modules[tag] = append(t.modules[tag], ctx.ModuleName())
+	}
+}
+
+type taggedPackageProperties struct {
+	// The tag of the modules to package.
+	Tag *string
+
+	// How to package the modules, either "tar" or "dir". Defaults to "tar".
+	Type *string
+
+	// Name of the output file when type is "tar". Defaults to <module_name>.tar.
+	Stem *string
+
+	// The directory in the partition of this module to install the files into when type is "dir".
+	Relative_install_path *string
+}
+
+type taggedPackage struct {
+	ModuleBase
+	PackagingBase
+
+	properties taggedPackageProperties
+
+	output OutputPath
+}
+
+type taggedPackageDepTag struct {
+	blueprint.BaseDependencyTag
+	PackagingItemAlwaysDepTag
+}
+
+// AllowDisabledModuleDependency skips the variants of the tagged modules that are disabled, as
+// they weren't listed explicitly.
+func (taggedPackageDepTag) AllowDisabledModuleDependency(target Module) bool {
+	return true
+}
+
+var taggedPackageTag = taggedPackageDepTag{}
+
+func TaggedPackageFactory() Module {
+	module := &taggedPackage{}
+	module.AddProperties(&module.properties)
+	InitPackageModule(module)
+	InitAndroidMultiTargetsArchModule(module, DeviceSupported, MultilibCommon)
+	return module
+}
+
+func (p *taggedPackage) DepsMutator(ctx BottomUpMutatorContext) {
+	tag := proptools.String(p.properties.Tag)
+	if tag == "" {
+		ctx.PropertyErrorf("tag", "must be set")
+		return
+	}
+
+	// Depend on the variants of the tagged modules for each of the targets of this module that
+	// exist, e.g. a module that is only built for 64-bit isn't packaged for the 32-bit target.
+	for _, name := range getTaggedModules(ctx.Config()).names(tag) {
+		if name == ctx.ModuleName() {
+			continue
+		}
+		for _, t := range p.getSupportedTargets(ctx) {
+			if ctx.OtherModuleFarDependencyVariantExists(t.Variations(), name) {
+				ctx.AddFarVariationDependencies(t.Variations(), taggedPackageTag, name)
+			}
+		}
+	}
+}
+
+func (p *taggedPackage) installFileName() string {
+	return proptools.StringDefault(p.properties.Stem, p.BaseModuleName()+".tar")
+}
+
+func (p *taggedPackage) GenerateAndroidBuildActions(ctx ModuleContext) {
+	switch typ := proptools.StringDefault(p.properties.Type, "tar"); typ {
+	case "tar":
+		p.buildTar(ctx, p.gatherPartitionedSpecs(ctx))
+	case "dir":
+		p.installToDir(ctx, p.GatherPackagingSpecs(ctx))
+	default:
+		ctx.PropertyErrorf("type", "must be either \"tar\" or \"dir\", but was %q", typ)
+	}
+}
+
+// gatherPartitionedSpecs returns the packaging specs of the tagged modules and their dependencies
+// with the partition they are installed to prepended to their path in the package, so that the
+// tarball is laid out like the partitions and files installed to the same path of different
+// partitions are all packaged.
+func (p *taggedPackage) gatherPartitionedSpecs(ctx ModuleContext) map[string]PackagingSpec {
+	m := make(map[string]PackagingSpec)
+	ctx.VisitDirectDeps(func(child Module) {
+		if pi, ok := ctx.OtherModuleDependencyTag(child).(PackagingItem); !ok || !pi.IsPackagingItem() {
+			return
+		}
+		for _, ps := range child.TransitivePackagingSpecs() {
+			ps.relPathInPackage = filepath.Join(ps.partition, ps.relPathInPackage)
+			if _, ok := m[ps.relPathInPackage]; !ok {
+				m[ps.relPathInPackage] = ps
+			}
+		}
+	})
+	return m
+}
+
+// buildTar packages the files into a tarball with reproducible timestamps and owners.
+func (p *taggedPackage) buildTar(ctx ModuleContext, specs map[string]PackagingSpec) {
+	builder := NewRuleBuilder(pctx, ctx)
+	rootDir := PathForModuleOut(ctx, "root")
+	builder.Command().Text("rm").Flag("-rf").Text(rootDir.String())
+	builder.Command().Text("mkdir").Flag("-p").Text(rootDir.String())
+	p.CopySpecsToDir(ctx, builder, specs, rootDir)
+
+	p.output = PathForModuleOut(ctx, p.installFileName()).OutputPath
+	builder.Command().
+		Text("tar").
+		Flag("--sort=name").
+		Flag("--mtime=@0").
+		Flag("--owner=0").
+		Flag("--group=0").
+		Flag("--numeric-owner").
+		FlagWithOutput("-cf ", p.output).
+		FlagWithArg("-C ", rootDir.String()).
+		Text(".")
+	builder.Command().Text("rm").Flag("-rf").Text(rootDir.String())
+
+	builder.Build("tagged_package", fmt.Sprintf("Packaging %s", p.BaseModuleName()))
+	ctx.CheckbuildFile(p.output)
+}
+
+// installToDir installs the files into relative_install_path in the partition of this module.
+func (p *taggedPackage) installToDir(ctx ModuleContext, specs map[string]PackagingSpec) {
+	relDir := proptools.String(p.properties.Relative_install_path)
+	for _, k := range SortedStringKeys(specs) {
+		ps := specs[k]
+		dir := PathForModuleInstall(ctx, relDir, filepath.Dir(ps.relPathInPackage))
+		name := filepath.Base(ps.relPathInPackage)
+		if ps.symlinkTarget != "" {
+			ctx.InstallAbsoluteSymlink(dir, name, ps.symlinkTarget)
+		} else if ps.executable {
+			ctx.InstallExecutable(dir, name, ps.srcPath)
+		} else {
+			ctx.InstallFile(dir, name, ps.srcPath)
+		}
+	}
+}
+
+var _ OutputFileProducer = (*taggedPackage)(nil)
+
+// Implements OutputFileProducer
+func (p *taggedPackage) OutputFiles(tag string) (Paths, error) {
+	if tag == "" {
+		if proptools.StringDefault(p.properties.Type, "tar") != "tar" {
+			return nil, nil
+		}
+		return Paths{p.output}, nil
+	}
+	return nil, fmt.Errorf("unsupported module reference tag %q", tag)
+}
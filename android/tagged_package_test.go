@@ -0,0 +1,124 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"testing"
+)
+
+var prepareForTaggedPackageTest = GroupFixturePreparers(
+	PrepareForTestWithArchMutator,
+	PrepareForTestWithTaggedPackage,
+	FixtureRegisterWithContext(func(ctx RegistrationContext) {
+		ctx.RegisterModuleType("component", componentTestModuleFactory)
+	}),
+)
+
+const taggedPackageTestComponents = `
+	component {
+		name: "foo",
+		tags: ["debug_tools"],
+		deps: ["bar"],
+	}
+
+	component {
+		name: "bar",
+	}
+
+	component {
+		name: "baz",
+		tags: ["other_tools"],
+	}
+
+	component {
+		name: "qux",
+		tags: ["debug_tools"],
+		compile_multilib: "64",
+	}
+`
+
+func TestTaggedPackageTar(t *testing.T) {
+	result := prepareForTaggedPackageTest.RunTestWithBp(t, taggedPackageTestComponents+`
+		component {
+			name: "quux",
+			tags: ["debug_tools"],
+			compile_multilib: "64",
+			vendor: true,
+		}
+
+		tagged_package {
+			name: "debug_tools_package",
+			tag: "debug_tools",
+		}
+	`)
+
+	rule := result.ModuleForTests("debug_tools_package", "android_common").Rule("tagged_package")
+	AssertDeepEquals(t, "packaged files", []string{
+		"out/soong/.intermediates/bar/android_arm64_armv8-a/bar",
+		"out/soong/.intermediates/bar/android_arm_armv7-a-neon/bar",
+		"out/soong/.intermediates/foo/android_arm64_armv8-a/foo",
+		"out/soong/.intermediates/foo/android_arm_armv7-a-neon/foo",
+		"out/soong/.intermediates/quux/android_arm64_armv8-a/quux",
+		"out/soong/.intermediates/qux/android_arm64_armv8-a/qux",
+	}, SortedUniqueStrings(PathsRelativeToTop(rule.Inputs)))
+	AssertStringEquals(t, "output", "debug_tools_package.tar", rule.Output.Base())
+
+	// The files are laid out like the partitions they are installed to.
+	rootDir := "out/soong/.intermediates/debug_tools_package/android_common/root/"
+	command := StringRelativeToTop(result.Config, rule.RuleParams.Command)
+	AssertStringDoesContain(t, "system file", command, rootDir+"system/lib64/foo")
+	AssertStringDoesContain(t, "system file", command, rootDir+"system/lib32/foo")
+	AssertStringDoesContain(t, "vendor file", command, rootDir+"vendor/lib64/quux")
+}
+
+func TestTaggedPackageDir(t *testing.T) {
+	result := prepareForTaggedPackageTest.RunTestWithBp(t, taggedPackageTestComponents+`
+		tagged_package {
+			name: "debug_tools_package",
+			tag: "debug_tools",
+			type: "dir",
+			relative_install_path: "debug",
+		}
+	`)
+
+	module := result.ModuleForTests("debug_tools_package", "android_common")
+	for _, f := range []string{"lib64/foo", "lib32/foo", "lib64/bar", "lib32/bar", "lib64/qux"} {
+		module.Output("out/soong/target/product/test_device/system/debug/" + f)
+	}
+	if module.MaybeOutput("out/soong/target/product/test_device/system/debug/lib64/baz").Rule != nil {
+		t.Errorf("baz is installed but isn't tagged debug_tools")
+	}
+}
+
+func TestTaggedPackageErrors(t *testing.T) {
+	prepareForTaggedPackageTest.
+		ExtendWithErrorHandler(FixtureExpectsAtLeastOneErrorMatchingPattern(`tag: must be set`)).
+		RunTestWithBp(t, `
+			tagged_package {
+				name: "debug_tools_package",
+			}
+		`)
+
+	prepareForTaggedPackageTest.
+		ExtendWithErrorHandler(FixtureExpectsAtLeastOneErrorMatchingPattern(
+			`type: must be either "tar" or "dir", but was "zip"`)).
+		RunTestWithBp(t, `
+			tagged_package {
+				name: "debug_tools_package",
+				tag: "debug_tools",
+				type: "zip",
+			}
+		`)
+}
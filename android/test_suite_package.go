@@ -0,0 +1,217 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/google/blueprint"
+	"github.com/google/blueprint/proptools"
+)
+
+// Test suites like device-tests or general-tests are packaged by test_suite_package modules into
+// zips with the layout of the testcases directories of the suite, without the Make packaging step.
+// Each installed variant of a test that is part of the suite is packaged in
+// host/testcases/<module>/<arch> or target/testcases/<module>/<arch>, with its data files and the
+// shared libraries it was installed with, at the paths they are installed at relative to the
+// partition, so the sanitized variants of the libraries are packaged with sanitized tests.  The
+// test configs are packaged in the <module> directory, as <module>.config for the main one.
+
+// TestSuiteInfo is provided by the variants of the tests that are part of test suites.
+type TestSuiteInfo struct {
+	// The test suites the test is part of.
+	TestSuites []string
+
+	// The built test.
+	OutputFile Path
+
+	// The file name the test is packaged with, the base name of OutputFile if empty.
+	OutputFileName string
+
+	// The data files of the test, mapped to their paths relative to the test.
+	Data map[string]Path
+
+	// The test config of the test, packaged as <module>.config.
+	TestConfig Path
+
+	// The extra test configs of the test, packaged with their base names.
+	ExtraTestConfigs Paths
+}
+
+var TestSuiteInfoProvider = blueprint.NewProvider(TestSuiteInfo{})
+
+// TestSuiteData returns the data files of a test mapped to their paths relative to the test, for
+// the Data field of its TestSuiteInfo.
+func TestSuiteData(data []DataPath) map[string]Path {
+	ret := make(map[string]Path)
+	for _, d := range data {
+		ret[filepath.Join(d.RelativeInstallPath, d.SrcPath.Rel())] = d.SrcPath
+	}
+	return ret
+}
+
+type testSuitePackageProperties struct {
+	// The test suite to package, e.g. device-tests.
+	Suite *string
+
+	// The file name of the zip.  Defaults to <suite>.zip.
+	Stem *string
+}
+
+type TestSuitePackage struct {
+	ModuleBase
+
+	properties testSuitePackageProperties
+}
+
+// test_suite_package packages the tests that are part of a test suite, along with their data files,
+// shared libraries and test configs, into a zip that is built and disted by the goal named after
+// the module.
+func TestSuitePackageFactory() Module {
+	module := &TestSuitePackage{}
+	module.AddProperties(&module.properties)
+	InitAndroidModule(module)
+	return module
+}
+
+func (p *TestSuitePackage) suite() string {
+	return proptools.String(p.properties.Suite)
+}
+
+func (p *TestSuitePackage) stem() string {
+	return proptools.StringDefault(p.properties.Stem, p.suite()+".zip")
+}
+
+func (p *TestSuitePackage) GenerateAndroidBuildActions(ctx ModuleContext) {
+	if p.suite() == "" {
+		ctx.PropertyErrorf("suite", "missing test suite")
+	}
+}
+
+// testSuiteTest is an installed variant of a test that is part of test suites.
+type testSuiteTest struct {
+	name   string
+	target Target
+	info   TestSuiteInfo
+
+	// The shared libraries installed with the test.
+	sharedLibs []PackagingSpec
+}
+
+func testSuiteTestForModule(ctx SingletonContext, m Module) (testSuiteTest, bool) {
+	if !m.Enabled() || m.IsSkipInstall() || !ctx.ModuleHasProvider(m, TestSuiteInfoProvider) {
+		return testSuiteTest{}, false
+	}
+
+	own := make(map[string]bool)
+	for _, spec := range m.base().packagingSpecs {
+		own[spec.relPathInPackage] = true
+	}
+	var sharedLibs []PackagingSpec
+	for _, spec := range m.base().TransitivePackagingSpecs() {
+		// The symlinks to the libraries are not packaged, the tests load the libraries by their
+		// sonames.
+		if !own[spec.relPathInPackage] && strings.HasSuffix(spec.relPathInPackage, ".so") &&
+			spec.symlinkTarget == "" {
+			sharedLibs = append(sharedLibs, spec)
+		}
+	}
+
+	return testSuiteTest{
+		name:       ctx.ModuleName(m),
+		target:     m.Target(),
+		info:       ctx.ModuleProvider(m, TestSuiteInfoProvider).(TestSuiteInfo),
+		sharedLibs: sharedLibs,
+	}, true
+}
+
+// testSuitePackageZip is the zip built for a test_suite_package module.
+type testSuitePackageZip struct {
+	output WritablePath
+	stem   string
+}
+
+// buildTestSuitePackage builds the zip of a test_suite_package module with the given tests.  The
+// paths of the files in the zip are passed to soong_zip with -e in a response file, as there can be
+// too many of them for the command line.
+func buildTestSuitePackage(ctx SingletonContext, p *TestSuitePackage, tests []testSuiteTest) testSuitePackageZip {
+	entries := make(map[string]Path)
+	// The first variant of a test wins for the files shared by the variants, like the test configs.
+	add := func(dest string, src Path) {
+		if _, exists := entries[dest]; !exists {
+			entries[dest] = src
+		}
+	}
+
+	for _, test := range tests {
+		testCasesDir := "target/testcases"
+		if test.target.Os.Class == Host {
+			testCasesDir = "host/testcases"
+		}
+		moduleDir := filepath.Join(testCasesDir, test.name)
+		archDir := moduleDir
+		if test.target.Arch.ArchType != Common {
+			archDir = filepath.Join(moduleDir, test.target.Arch.ArchType.String())
+		}
+
+		if test.info.OutputFile != nil {
+			fileName := test.info.OutputFileName
+			if fileName == "" {
+				fileName = test.info.OutputFile.Base()
+			}
+			add(filepath.Join(archDir, fileName), test.info.OutputFile)
+		}
+		for _, rel := range SortedStringKeys(test.info.Data) {
+			add(filepath.Join(archDir, rel), test.info.Data[rel])
+		}
+		for _, lib := range test.sharedLibs {
+			add(filepath.Join(archDir, lib.relPathInPackage), lib.srcPath)
+		}
+		if test.info.TestConfig != nil {
+			add(filepath.Join(moduleDir, test.name+".config"), test.info.TestConfig)
+		}
+		for _, config := range test.info.ExtraTestConfigs {
+			add(filepath.Join(moduleDir, config.Base()), config)
+		}
+	}
+
+	name := ctx.ModuleName(p)
+	output := PathForOutput(ctx, "packaging", "test_suites", name, p.stem())
+	zip := testSuitePackageZip{
+		output: output,
+		stem:   p.stem(),
+	}
+
+	var args strings.Builder
+	var inputs Paths
+	for _, dest := range SortedStringKeys(entries) {
+		src := entries[dest]
+		fmt.Fprintf(&args, "-e %s -f %s\n", proptools.ShellEscape(dest), proptools.ShellEscape(src.String()))
+		inputs = append(inputs, src)
+	}
+	rspFile := output.ReplaceExtension(ctx, "rsp")
+	WriteFileRule(ctx, rspFile, args.String())
+
+	rule := NewRuleBuilder(pctx, ctx)
+	rule.Command().BuiltTool("soong_zip").
+		FlagWithOutput("-o ", zip.output).
+		FlagWithInput("@", rspFile).
+		Implicits(inputs)
+	rule.Build("test_suite_package_"+name, "test suite package "+name)
+
+	return zip
+}
@@ -0,0 +1,137 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/google/blueprint"
+)
+
+type mockSuiteTestModule struct {
+	ModuleBase
+	properties struct {
+		Test_suites []string
+		Shared_libs []string
+		Data        []string `android:"path"`
+		Test_config *string  `android:"path"`
+	}
+}
+
+func newMockSuiteTestModule() Module {
+	m := &mockSuiteTestModule{}
+	m.AddProperties(&m.properties)
+	InitAndroidArchModule(m, HostAndDeviceSupported, MultilibFirst)
+	return m
+}
+
+type mockSuiteSharedLibTag struct {
+	blueprint.BaseDependencyTag
+	InstallAlwaysNeededDependencyTag
+}
+
+func (m *mockSuiteTestModule) DepsMutator(ctx BottomUpMutatorContext) {
+	ctx.AddVariationDependencies(nil, mockSuiteSharedLibTag{}, m.properties.Shared_libs...)
+}
+
+func (m *mockSuiteTestModule) GenerateAndroidBuildActions(ctx ModuleContext) {
+	if len(m.properties.Test_suites) == 0 {
+		// A shared library.
+		lib := PathForModuleOut(ctx, m.Name()+".so")
+		ctx.InstallFile(PathForModuleInstall(ctx, ctx.Arch().ArchType.Multilib), m.Name()+".so", lib)
+		return
+	}
+
+	test := PathForModuleOut(ctx, m.Name())
+	ctx.InstallFile(PathForModuleInstall(ctx, "nativetest", m.Name()), m.Name(), test)
+
+	data := make(map[string]Path)
+	for _, d := range PathsForModuleSrc(ctx, m.properties.Data) {
+		data[d.Rel()] = d
+	}
+	ctx.SetProvider(TestSuiteInfoProvider, TestSuiteInfo{
+		TestSuites: m.properties.Test_suites,
+		OutputFile: test,
+		Data:       data,
+		TestConfig: OptionalPathForModuleSrc(ctx, m.properties.Test_config).Path(),
+	})
+}
+
+func TestTestSuitePackage(t *testing.T) {
+	bp := `
+		mock_suite_test {
+			name: "foo_test",
+			host_supported: true,
+			test_suites: ["device-tests"],
+			shared_libs: ["libfoo"],
+			data: ["testdata/foo.txt"],
+			test_config: "AndroidTest.xml",
+		}
+
+		mock_suite_test {
+			name: "bar_test",
+			test_suites: ["general-tests"],
+		}
+
+		mock_suite_test {
+			name: "libfoo",
+			host_supported: true,
+		}
+
+		test_suite_package {
+			name: "device_tests_package",
+			suite: "device-tests",
+		}
+	`
+
+	result := GroupFixturePreparers(
+		PrepareForTestWithArchMutator,
+		PrepareForTestWithTestSuites,
+		FixtureRegisterWithContext(func(ctx RegistrationContext) {
+			ctx.RegisterModuleType("mock_suite_test", newMockSuiteTestModule)
+		}),
+		FixtureWithRootAndroidBp(bp),
+		FixtureAddTextFile("testdata/foo.txt", ""),
+		FixtureAddTextFile("AndroidTest.xml", ""),
+	).RunTest(t)
+
+	singleton := result.SingletonForTests("testsuites")
+	zip := singleton.Output("packaging/test_suites/device_tests_package/device-tests.zip")
+	rsp := singleton.Output("packaging/test_suites/device_tests_package/device-tests.rsp")
+	AssertStringDoesContain(t, "soong_zip args", zip.RuleParams.Command, "@"+rsp.Output.String())
+	AssertStringListContains(t, "soong_zip inputs", PathsRelativeToTop(zip.Implicits),
+		"out/soong/.intermediates/foo_test/android_arm64_armv8-a/foo_test")
+	AssertStringDoesNotContain(t, "soong_zip args", zip.RuleParams.Command, "foo.txt")
+	args := ContentFromFileRuleForTests(t, rsp)
+
+	var got []string
+	for _, match := range regexp.MustCompile(`-e (\S+) -f \S+`).FindAllStringSubmatch(args, -1) {
+		got = append(got, match[1])
+	}
+
+	AssertArrayString(t, "packaged files", []string{
+		"host/testcases/foo_test/foo_test.config",
+		"host/testcases/foo_test/x86_64/foo_test",
+		"host/testcases/foo_test/x86_64/lib64/libfoo.so",
+		"host/testcases/foo_test/x86_64/testdata/foo.txt",
+		"target/testcases/foo_test/arm64/foo_test",
+		"target/testcases/foo_test/arm64/lib64/libfoo.so",
+		"target/testcases/foo_test/arm64/testdata/foo.txt",
+		"target/testcases/foo_test/foo_test.config",
+	}, got)
+
+	AssertStringDoesNotContain(t, "other test suites", args, "bar_test")
+}
@@ -15,15 +15,25 @@
 package android
 
 func init() {
-	RegisterSingletonType("testsuites", testSuiteFilesFactory)
+	RegisterTestSuitesBuildComponents(InitRegistrationContext)
 }
 
+func RegisterTestSuitesBuildComponents(ctx RegistrationContext) {
+	ctx.RegisterModuleType("test_suite_package", TestSuitePackageFactory)
+	ctx.RegisterSingletonType("testsuites", testSuiteFilesFactory)
+}
+
+var PrepareForTestWithTestSuites = FixtureRegisterWithContext(RegisterTestSuitesBuildComponents)
+
 func testSuiteFilesFactory() Singleton {
 	return &testSuiteFiles{}
 }
 
 type testSuiteFiles struct {
 	robolectric WritablePath
+
+	// The zips of the test_suite_package modules, mapped to the names of the modules.
+	packages map[string]testSuitePackageZip
 }
 
 type TestSuiteModule interface {
@@ -33,8 +43,18 @@ type TestSuiteModule interface {
 
 func (t *testSuiteFiles) GenerateBuildActions(ctx SingletonContext) {
 	files := make(map[string]map[string]InstallPaths)
+	tests := make(map[string][]testSuiteTest)
+	var packages []*TestSuitePackage
 
 	ctx.VisitAllModules(func(m Module) {
+		if p, ok := m.(*TestSuitePackage); ok && p.Enabled() {
+			packages = append(packages, p)
+		}
+		if test, ok := testSuiteTestForModule(ctx, m); ok {
+			for _, testSuite := range test.info.TestSuites {
+				tests[testSuite] = append(tests[testSuite], test)
+			}
+		}
 		if tsm, ok := m.(TestSuiteModule); ok {
 			for _, testSuite := range tsm.TestSuites() {
 				if files[testSuite] == nil {
@@ -49,10 +69,22 @@ func (t *testSuiteFiles) GenerateBuildActions(ctx SingletonContext) {
 	t.robolectric = robolectricTestSuite(ctx, files["robolectric-tests"])
 
 	ctx.Phony("robolectric-tests", t.robolectric)
+
+	t.packages = make(map[string]testSuitePackageZip)
+	for _, p := range packages {
+		name := ctx.ModuleName(p)
+		zip := buildTestSuitePackage(ctx, p, tests[p.suite()])
+		t.packages[name] = zip
+		ctx.Phony(name, zip.output)
+	}
 }
 
 func (t *testSuiteFiles) MakeVars(ctx MakeVarsContext) {
 	ctx.DistForGoal("robolectric-tests", t.robolectric)
+	for _, name := range SortedStringKeys(t.packages) {
+		zip := t.packages[name]
+		ctx.DistForGoalWithFilename(name, zip.output, zip.stem)
+	}
 }
 
 func robolectricTestSuite(ctx SingletonContext, files map[string]InstallPaths) WritablePath {
@@ -22,6 +22,7 @@ import (
 	"runtime"
 	"sort"
 	"strings"
+	"sync"
 )
 
 // CopyOf returns a new slice that has the same contents as s.
@@ -459,6 +460,45 @@ func ShardStrings(s []string, shardSize int) [][]string {
 	return ret
 }
 
+// ParallelForEach calls f for every index in [0, n) on up to GOMAXPROCS goroutines, and returns once
+// all the calls have returned. Callers that need a deterministic result should have f write to
+// the i-th element of a slice, and merge the slice afterwards. If any call panics, the panic of the
+// call with the lowest index is re-raised on the calling goroutine.
+func ParallelForEach(n int, f func(i int)) {
+	panics := make([]interface{}, n)
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	workers := runtime.GOMAXPROCS(0)
+	if workers > n {
+		workers = n
+	}
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				func() {
+					defer func() {
+						panics[i] = recover()
+					}()
+					f(i)
+				}()
+			}
+		}()
+	}
+	for i := 0; i < n; i++ {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+
+	for _, p := range panics {
+		if p != nil {
+			panic(p)
+		}
+	}
+}
+
 // CheckDuplicate checks if there are duplicates in given string list.
 // If there are, it returns first such duplicate and true.
 func CheckDuplicate(values []string) (duplicate string, found bool) {
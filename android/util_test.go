@@ -754,3 +754,33 @@ func TestSortedUniqueStringValues(t *testing.T) {
 		})
 	}
 }
+
+func TestParallelForEach(t *testing.T) {
+	for _, n := range []int{0, 1, 1000} {
+		t.Run(strconv.Itoa(n), func(t *testing.T) {
+			got := make([]int, n)
+			ParallelForEach(n, func(i int) {
+				got[i] = i * i
+			})
+			for i := range got {
+				if got[i] != i*i {
+					t.Fatalf("index %d: wanted %d, got %d", i, i*i, got[i])
+				}
+			}
+		})
+	}
+
+	t.Run("panic", func(t *testing.T) {
+		defer func() {
+			if r := recover(); r != "panic 3" {
+				t.Errorf("wanted the panic of the lowest index, got %v", r)
+			}
+		}()
+		ParallelForEach(100, func(i int) {
+			if i >= 3 {
+				panic(fmt.Sprintf("panic %d", i))
+			}
+		})
+		t.Errorf("ParallelForEach didn't panic")
+	})
+}
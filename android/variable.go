@@ -33,6 +33,13 @@ func init() {
 func registerVariableBuildComponents(ctx RegistrationContext) {
 	ctx.PreDepsMutators(func(ctx RegisterMutatorsContext) {
 		ctx.BottomUp("variable", VariableMutator).Parallel()
+
+		// Apply the module property patches of the product.
+		//
+		// This must run after the arch and variable mutators so that the patched properties
+		// override the arch specific and product variable properties that are squashed into the
+		// module properties.
+		registerModulePropertyPatchesMutator(ctx)
 	})
 }
 
@@ -274,6 +281,10 @@ type productVariables struct {
 	CrossHostArch          *string `json:",omitempty"`
 	CrossHostSecondaryArch *string `json:",omitempty"`
 
+	BaremetalArch        *string `json:",omitempty"`
+	BaremetalArchVariant *string `json:",omitempty"`
+	BaremetalCpuVariant  *string `json:",omitempty"`
+
 	DeviceResourceOverlays     []string `json:",omitempty"`
 	ProductResourceOverlays    []string `json:",omitempty"`
 	EnforceRROTargets          []string `json:",omitempty"`
@@ -325,6 +336,8 @@ type productVariables struct {
 	UncompressPrivAppDex             *bool    `json:",omitempty"`
 	ModulesLoadedByPrivilegedModules []string `json:",omitempty"`
 
+	ApkV4Signature *bool `json:",omitempty"`
+
 	BootJars     ConfiguredJarList `json:",omitempty"`
 	ApexBootJars ConfiguredJarList `json:",omitempty"`
 
@@ -338,12 +351,43 @@ type productVariables struct {
 	CFIExcludePaths []string `json:",omitempty"`
 	CFIIncludePaths []string `json:",omitempty"`
 
+	// Paths whose components are compiled with kcfi, the type-hash based CFI scheme, instead of
+	// cross-DSO CFI.
+	KcfiIncludePaths []string `json:",omitempty"`
+
 	DisableScudo *bool `json:",omitempty"`
 
 	MemtagHeapExcludePaths      []string `json:",omitempty"`
 	MemtagHeapAsyncIncludePaths []string `json:",omitempty"`
 	MemtagHeapSyncIncludePaths  []string `json:",omitempty"`
 
+	// Partitions ("system", "system_ext", "product", "vendor" or "odm") whose components enable
+	// memtag heap in async or sync mode.  Include paths take precedence over these lists.
+	MemtagHeapAsyncIncludePartitions []string `json:",omitempty"`
+	MemtagHeapSyncIncludePartitions  []string `json:",omitempty"`
+
+	// Partitions whose arm64 cc modules are compiled with -mbranch-protection=standard, which
+	// enables pointer authentication (PAuth) and branch target identification (BTI).
+	BranchProtectionIncludePartitions []string `json:",omitempty"`
+
+	// Paths whose Rust modules are not instrumented with the address and hwaddress sanitizers
+	// enabled by SANITIZE_TARGET.
+	RustSanitizeExcludePaths []string `json:",omitempty"`
+
+	// Map paths and modules to the alternate clang toolchains their device modules are built with,
+	// as <flavor>:<path> and <flavor>:<module name> entries.
+	ToolchainFlavorPaths   []string `json:",omitempty"`
+	ToolchainFlavorModules []string `json:",omitempty"`
+
+	// Static libraries added to the device variants of every cc_test in a path, as
+	// <library>:<path> entries.
+	CcTestPathStaticLibs []string `json:",omitempty"`
+
+	// Reproducibility controls applied to device cc modules that don't set build_id and
+	// file_prefix_map.
+	DefaultBuildIdStyle  *string `json:",omitempty"`
+	DefaultFilePrefixMap *bool   `json:",omitempty"`
+
 	VendorPath    *string `json:",omitempty"`
 	OdmPath       *string `json:",omitempty"`
 	ProductPath   *string `json:",omitempty"`
@@ -392,6 +436,10 @@ type productVariables struct {
 	DirectedVendorSnapshot bool            `json:",omitempty"`
 	VendorSnapshotModules  map[string]bool `json:",omitempty"`
 
+	// The names of the modules in PRODUCT_PACKAGES, whose installed files are listed in
+	// installed-files.json.
+	ProductPackages []string `json:",omitempty"`
+
 	DirectedRecoverySnapshot bool            `json:",omitempty"`
 	RecoverySnapshotModules  map[string]bool `json:",omitempty"`
 
@@ -405,6 +453,7 @@ type productVariables struct {
 	RamdiskSnapshotDirsExcluded  []string `json:",omitempty"`
 	RamdiskSnapshotDirsIncluded  []string `json:",omitempty"`
 	HostFakeSnapshotEnabled      bool     `json:",omitempty"`
+	HostSnapshotVersion          string   `json:",omitempty"`
 
 	BoardVendorSepolicyDirs           []string `json:",omitempty"`
 	BoardOdmSepolicyDirs              []string `json:",omitempty"`
@@ -449,6 +498,18 @@ type productVariables struct {
 	EnforceSystemCertificate          *bool    `json:",omitempty"`
 	EnforceSystemCertificateAllowList []string `json:",omitempty"`
 
+	// Fail the build when a proprietary module links in code under a restricted license, unless
+	// the edge is listed as <module>:<restricted module> in LicenseConstraintsAllowedEdges.
+	EnforceLicenseConstraints      *bool    `json:",omitempty"`
+	LicenseConstraintsAllowedEdges []string `json:",omitempty"`
+
+	// Fail the build when the APEX variant of a library with stubs exports symbols that are not
+	// in its stubs.
+	EnforceApexExportedSymbols *bool `json:",omitempty"`
+
+	// Fail the build when a phony target is created by more than one module or singleton.
+	StrictPhonyTargets *bool `json:",omitempty"`
+
 	ProductHiddenAPIStubs       []string `json:",omitempty"`
 	ProductHiddenAPIStubsSystem []string `json:",omitempty"`
 	ProductHiddenAPIStubsTest   []string `json:",omitempty"`
@@ -503,6 +564,8 @@ type productVariables struct {
 	ForceMultilibFirstOnDevice bool `json:",omitempty"`
 
 	IncludeTags []string `json:",omitempty"`
+
+	ModulePropertyPatches []ModulePropertyPatch `json:",omitempty"`
 }
 
 func boolPtr(v bool) *bool {
@@ -198,6 +198,9 @@ type apexBundleProperties struct {
 
 	IsCoverageVariant bool `blueprint:"mutated"`
 
+	// Name of the apex module that this apex_test was created from by its test_variant property.
+	TestVariantOf string `blueprint:"mutated"`
+
 	// List of sanitizer names that this APEX is enabled for
 	SanitizerNames []string `blueprint:"mutated"`
 
@@ -248,6 +251,33 @@ type apexMultilibProperties struct {
 	Lib64 ApexNativeDependencies
 }
 
+type apexTestVariantProperties struct {
+	// Creates an apex_test module from the same definition as this APEX, with a distinct name and
+	// extra payload such as debug binaries and test configs, so that test builds don't need to
+	// duplicate the definition of the APEX.
+	Test_variant struct {
+		// Whether to create the test variant of this APEX. Default is false.
+		Enabled *bool
+
+		// Name of the apex_test module. Default is <name>.test.
+		Name *string
+
+		// Canonical name of the test variant, used to determine the path to the activated APEX
+		// on device. Default is the name of the apex_test module.
+		Apex_name *string
+
+		// Native modules that are only embedded inside the test variant.
+		ApexNativeDependencies
+
+		// List of prebuilt files that are only embedded inside the test variant, e.g. test
+		// configs.
+		Prebuilts []string
+
+		// List of sh binaries that are only embedded inside the test variant.
+		Sh_binaries []string
+	}
+}
+
 type apexTargetBundleProperties struct {
 	Target struct {
 		// Multilib properties only for android.
@@ -359,7 +389,8 @@ type apexBundle struct {
 	targetProperties      apexTargetBundleProperties
 	archProperties        apexArchBundleProperties
 	overridableProperties overridableProperties
-	vndkProperties        apexVndkProperties // only for apex_vndk modules
+	vndkProperties        apexVndkProperties        // only for apex_vndk modules
+	testVariantProperties apexTestVariantProperties // only for apex modules
 
 	///////////////////////////////////////////////////////////////////////////////////////////
 	// Inputs
@@ -1798,6 +1829,9 @@ func (a *apexBundle) GenerateAndroidBuildActions(ctx android.ModuleContext) {
 	var provideNativeLibs []string
 	var requireNativeLibs []string
 
+	// modules that this APEX exports to other modules, by the sdk property that lists them
+	sdkMembers := make(map[string][]string)
+
 	handleSpecialLibs := !android.Bool(a.properties.Ignore_system_library_special_case)
 
 	// Collect the module directory for IDE info in java/jdeps.go.
@@ -1827,6 +1861,7 @@ func (a *apexBundle) GenerateAndroidBuildActions(ctx android.ModuleContext) {
 					// - bootstrap bionic libs are treated as provided by system
 					if c.HasStubsVariants() && !a.vndkApex && !cc.InstallToBootstrap(c.BaseModuleName(), ctx.Config()) {
 						provideNativeLibs = append(provideNativeLibs, fi.stem())
+						sdkMembers["native_shared_libs"] = append(sdkMembers["native_shared_libs"], c.BaseModuleName())
 					}
 					return true // track transitive dependencies
 				} else if r, ok := child.(*rust.Module); ok {
@@ -1871,6 +1906,7 @@ func (a *apexBundle) GenerateAndroidBuildActions(ctx android.ModuleContext) {
 
 					filesToAdd := apexBootclasspathFragmentFiles(ctx, child)
 					filesInfo = append(filesInfo, filesToAdd...)
+					sdkMembers["bootclasspath_fragments"] = append(sdkMembers["bootclasspath_fragments"], bcpfModule.BaseModuleName())
 					for _, makeModuleName := range bcpfModule.BootImageDeviceInstallMakeModules() {
 						a.requiredDeps = append(a.requiredDeps, makeModuleName)
 					}
@@ -1878,10 +1914,12 @@ func (a *apexBundle) GenerateAndroidBuildActions(ctx android.ModuleContext) {
 				}
 			case sscpfTag:
 				{
-					if _, ok := child.(*java.SystemServerClasspathModule); !ok {
+					sscpfModule, ok := child.(*java.SystemServerClasspathModule)
+					if !ok {
 						ctx.PropertyErrorf("systemserverclasspath_fragments", "%q is not a systemserverclasspath_fragment module", depName)
 						return false
 					}
+					sdkMembers["systemserverclasspath_fragments"] = append(sdkMembers["systemserverclasspath_fragments"], sscpfModule.BaseModuleName())
 					if af := apexClasspathFragmentProtoFile(ctx, child); af != nil {
 						filesInfo = append(filesInfo, *af)
 					}
@@ -1896,6 +1934,9 @@ func (a *apexBundle) GenerateAndroidBuildActions(ctx android.ModuleContext) {
 						return false
 					}
 					filesInfo = append(filesInfo, af)
+					if _, ok := child.(java.SdkLibraryDependency); ok {
+						sdkMembers["java_sdk_libs"] = append(sdkMembers["java_sdk_libs"], android.RemoveOptionalPrebuiltPrefix(depName))
+					}
 					return true // track transitive dependencies
 				default:
 					ctx.PropertyErrorf("java_libs", "%q of type %q is not supported", depName, ctx.OtherModuleType(child))
@@ -2253,6 +2294,7 @@ func (a *apexBundle) GenerateAndroidBuildActions(ctx android.ModuleContext) {
 		a.buildUnflattenedApex(ctx)
 	}
 	a.buildApexDependencyInfo(ctx)
+	a.buildSdkMembers(ctx, sdkMembers)
 	a.buildLintReports(ctx)
 
 	// Append meta-files to the filesInfo list so that they are reflected in Android.mk as well.
@@ -2372,7 +2414,14 @@ func apexFileForBootclasspathFragmentContentModule(ctx android.ModuleContext, fr
 
 func newApexBundle() *apexBundle {
 	module := &apexBundle{}
+	initApexBundle(module)
+	return module
+}
 
+// initApexBundle adds the properties common to all the APEX module types and initializes the
+// module. The properties specific to a module type must be added before, so that they can be set
+// by apex_defaults.
+func initApexBundle(module *apexBundle) {
 	module.AddProperties(&module.properties)
 	module.AddProperties(&module.targetProperties)
 	module.AddProperties(&module.archProperties)
@@ -2383,7 +2432,6 @@ func newApexBundle() *apexBundle {
 	android.InitSdkAwareModule(module)
 	android.InitOverridableModule(module, &module.overridableProperties.Overrides)
 	android.InitBazelModule(module)
-	return module
 }
 
 func ApexBundleFactory(testApex bool) android.Module {
@@ -2403,7 +2451,53 @@ func testApexBundleFactory() android.Module {
 // apex packages other modules into an APEX file which is a packaging format for system-level
 // components like binaries, shared libraries, etc.
 func BundleFactory() android.Module {
-	return newApexBundle()
+	bundle := &apexBundle{}
+	bundle.AddProperties(&bundle.testVariantProperties)
+	initApexBundle(bundle)
+	bundle.SetDefaultableHook(bundle.createTestVariant)
+	return bundle
+}
+
+// createTestVariant creates the apex_test module requested by the test_variant property. It is
+// called after the defaults are applied, so the apex_test is created with the resolved properties
+// of this APEX plus the extra payload of the test variant.
+func (a *apexBundle) createTestVariant(ctx android.DefaultableHookContext) {
+	testVariant := &a.testVariantProperties.Test_variant
+	if !proptools.Bool(testVariant.Enabled) {
+		return
+	}
+
+	name := proptools.StringDefault(testVariant.Name, ctx.ModuleName()+".test")
+	nameProps := struct {
+		Name *string
+	}{
+		Name: proptools.StringPtr(name),
+	}
+
+	// Appended after the properties of this APEX: the lists are concatenated and the apex_name is
+	// replaced.
+	extraProps := apexBundleProperties{
+		Apex_name:              proptools.StringPtr(proptools.StringDefault(testVariant.Apex_name, name)),
+		ApexNativeDependencies: testVariant.ApexNativeDependencies,
+		Sh_binaries:            testVariant.Sh_binaries,
+	}
+	extraOverridableProps := overridableProperties{
+		Prebuilts: testVariant.Prebuilts,
+	}
+
+	// All the properties of this APEX are copied, including the arch specific ones, except for
+	// test_variant that apex_test doesn't have.  CreateModule inherits the common properties such
+	// as compile_multilib.
+	var props []interface{}
+	for _, p := range android.PropertiesToCopyModule(a) {
+		if p != &a.testVariantProperties {
+			props = append(props, p)
+		}
+	}
+	props = append(props, &extraProps, &extraOverridableProps, &nameProps)
+
+	module := ctx.CreateModule(testApexBundleFactory, props...)
+	module.(*apexBundle).properties.TestVariantOf = ctx.ModuleName()
 }
 
 type Defaults struct {
@@ -2424,6 +2518,7 @@ func DefaultsFactory(props ...interface{}) android.Module {
 		&apexBundleProperties{},
 		&apexTargetBundleProperties{},
 		&overridableProperties{},
+		&apexTestVariantProperties{},
 	)
 
 	android.InitDefaultsModule(module)
@@ -4097,6 +4097,74 @@ func TestApexName(t *testing.T) {
 	ensureNotContains(t, androidMk, "LOCAL_MODULE := mylib.com.android.myapex\n")
 }
 
+func TestApexTestVariant(t *testing.T) {
+	ctx := testApex(t, `
+		apex_defaults {
+			name: "myapex-defaults",
+			test_variant: {
+				enabled: true,
+				binaries: ["mydebug"],
+			},
+		}
+
+		apex {
+			name: "myapex",
+			defaults: ["myapex-defaults"],
+			key: "myapex.key",
+			native_shared_libs: ["mylib"],
+			updatable: false,
+			compile_multilib: "first",
+			test_variant: {
+				prebuilts: ["mytestconfig"],
+			},
+		}
+
+		apex_key {
+			name: "myapex.key",
+			public_key: "testkey.avbpubkey",
+			private_key: "testkey.pem",
+		}
+
+		cc_library {
+			name: "mylib",
+			srcs: ["mylib.cpp"],
+			system_shared_libs: [],
+			stl: "none",
+			apex_available: ["myapex"],
+		}
+
+		cc_binary {
+			name: "mydebug",
+			srcs: ["mylib.cpp"],
+			system_shared_libs: [],
+			static_executable: true,
+			stl: "none",
+		}
+
+		prebuilt_etc {
+			name: "mytestconfig",
+			src: "myprebuilt",
+		}
+	`)
+
+	// The extra payload is only embedded inside the test variant, which is built for the same
+	// targets as the APEX.
+	ensureExactContents(t, ctx, "myapex", "android_common_myapex_image", []string{
+		"lib64/mylib.so",
+	})
+	ensureExactContents(t, ctx, "myapex.test", "android_common_myapex.test_image", []string{
+		"bin/mydebug",
+		"etc/mytestconfig",
+		"lib64/mylib.so",
+	})
+
+	module := ctx.ModuleForTests("myapex.test", "android_common_myapex.test_image")
+	ensureContains(t, module.Rule("apexManifestRule").Args["opt"], "-v name myapex.test")
+	if !module.Module().(*apexBundle).testApex {
+		t.Errorf("expected myapex.test to be an apex_test")
+	}
+}
+
 func TestNonTestApex(t *testing.T) {
 	ctx := testApex(t, `
 		apex {
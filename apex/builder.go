@@ -246,7 +246,12 @@ func (a *apexBundle) buildFileContexts(ctx android.ModuleContext) android.Output
 	var fileContexts android.Path
 	var fileContextsDir string
 	if a.properties.File_contexts == nil {
-		fileContexts = android.PathForSource(ctx, "system/sepolicy/apex", ctx.ModuleName()+"-file_contexts")
+		// The test variant of an APEX uses the file contexts of the APEX it was created from.
+		name := ctx.ModuleName()
+		if a.properties.TestVariantOf != "" {
+			name = a.properties.TestVariantOf
+		}
+		fileContexts = android.PathForSource(ctx, "system/sepolicy/apex", name+"-file_contexts")
 	} else {
 		if m, t := android.SrcIsModuleWithTag(*a.properties.File_contexts); m != "" {
 			otherModule := android.GetModuleFromPathDep(ctx, m, t)
@@ -1011,6 +1016,50 @@ func (a *apexBundle) buildApexDependencyInfo(ctx android.ModuleContext) {
 	})
 }
 
+// ApexSdkMembersInfo lists the modules that an APEX exports to other modules, i.e. the native
+// libraries with stubs, the java_sdk_library modules and the classpath fragments. They are keyed
+// by the name of the sdk property that lists them as members, e.g. native_shared_libs.
+type ApexSdkMembersInfo struct {
+	Members map[string][]string
+}
+
+var ApexSdkMembersInfoProvider = blueprint.NewProvider(ApexSdkMembersInfo{})
+
+// buildSdkMembers generates sdk_members.bp, an sdk module that lists the modules exported by this
+// APEX, as a starting point for the sdk that publishes the snapshot of this APEX. It can be built
+// with the <name>-sdk-members phony target.
+func (a *apexBundle) buildSdkMembers(ctx android.ModuleContext, members map[string][]string) {
+	if !a.primaryApexType || a.properties.IsCoverageVariant || ctx.Host() {
+		return
+	}
+
+	var properties []string
+	for property, names := range members {
+		members[property] = android.SortedUniqueStrings(names)
+		properties = append(properties, property)
+	}
+	sort.Strings(properties)
+
+	ctx.SetProvider(ApexSdkMembersInfoProvider, ApexSdkMembersInfo{Members: members})
+
+	var content strings.Builder
+	fmt.Fprintf(&content, "// Members of the sdk of %s, generated from the modules that it exports.\n", a.Name())
+	fmt.Fprintf(&content, "sdk {\n    name: %q,\n", a.Name()+"-sdk")
+	for _, property := range properties {
+		fmt.Fprintf(&content, "    %s: [\n", property)
+		for _, name := range members[property] {
+			fmt.Fprintf(&content, "        %q,\n", name)
+		}
+		fmt.Fprintf(&content, "    ],\n")
+	}
+	// WriteFileRule adds the final newline.
+	fmt.Fprintf(&content, "}")
+
+	output := android.PathForModuleOut(ctx, "sdk_members.bp")
+	android.WriteFileRule(ctx, output, content.String())
+	ctx.Phony(a.Name()+"-sdk-members", output)
+}
+
 func (a *apexBundle) buildLintReports(ctx android.ModuleContext) {
 	depSetsBuilder := java.NewLintDepSetBuilder()
 	for _, fi := range a.filesInfo {
@@ -49,6 +49,11 @@ type AfdoProperties struct {
 	// automatic feedback-directed optimization using profile data.
 	Afdo bool
 
+	// When set to true, the symbols of this module are ordered at link time with a symbol
+	// ordering file derived from its afdo profile, placing the functions with the most samples
+	// first. Ignored when symbol_ordering_file is set.
+	Afdo_symbol_ordering *bool
+
 	AfdoTarget *string  `blueprint:"mutated"`
 	AfdoDeps   []string `blueprint:"mutated"`
 }
@@ -123,6 +128,14 @@ func (afdo *afdo) flags(ctx ModuleContext, flags Flags) Flags {
 			// if profileFile gets updated
 			flags.CFlagsDeps = append(flags.CFlagsDeps, profileFilePath)
 			flags.LdFlagsDeps = append(flags.LdFlagsDeps, profileFilePath)
+
+			// Only modules that are linked use their ordering file, and an explicit
+			// symbol_ordering_file takes precedence.
+			linked := !ctx.static() || ctx.staticBinary()
+			hasOrderingFile := android.PrefixInList(flags.Local.LdFlags, "-Wl,--symbol-ordering-file=")
+			if proptools.Bool(afdo.Properties.Afdo_symbol_ordering) && linked && !hasOrderingFile {
+				flags = addSymbolOrderingFile(flags, transformAfdoProfileToSymbolOrder(ctx, profileFilePath))
+			}
 		}
 	}
 
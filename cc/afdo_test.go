@@ -68,3 +68,44 @@ func TestAfdoDeps(t *testing.T) {
 		t.Errorf("libTest missing dependency on afdo variant of libBar")
 	}
 }
+
+func TestAfdoSymbolOrdering(t *testing.T) {
+	bp := `
+	cc_library_shared {
+		name: "libTest",
+		srcs: ["foo.c"],
+		afdo: true,
+		afdo_symbol_ordering: true,
+	}
+
+	cc_library_shared {
+		name: "libOrdered",
+		srcs: ["foo.c"],
+		afdo: true,
+		afdo_symbol_ordering: true,
+		symbol_ordering_file: "libOrdered.txt",
+	}
+	`
+	result := android.GroupFixturePreparers(
+		prepareForCcTest,
+		android.FixtureAddTextFile("toolchain/pgo-profiles/sampling/libTest.afdo", "TEST"),
+		android.FixtureAddTextFile("toolchain/pgo-profiles/sampling/libOrdered.afdo", "TEST"),
+		android.FixtureAddTextFile("libOrdered.txt", ""),
+	).RunTestWithBp(t, bp)
+
+	libTest := result.ModuleForTests("libTest", "android_arm64_armv8-a_shared")
+	order := libTest.Output("afdo_symbol_order.txt")
+	android.AssertPathRelativeToTopEquals(t, "profile", "toolchain/pgo-profiles/sampling/libTest.afdo", order.Input)
+	ld := libTest.Rule("ld")
+	android.AssertStringDoesContain(t, "ldFlags", android.StringRelativeToTop(result.Config, ld.Args["ldFlags"]),
+		"-Wl,--symbol-ordering-file=out/soong/.intermediates/libTest/android_arm64_armv8-a_shared/afdo_symbol_order.txt")
+	android.AssertStringListContains(t, "implicits", android.PathsRelativeToTop(ld.Implicits),
+		"out/soong/.intermediates/libTest/android_arm64_armv8-a_shared/afdo_symbol_order.txt")
+
+	libOrdered := result.ModuleForTests("libOrdered", "android_arm64_armv8-a_shared")
+	android.AssertStringDoesContain(t, "ldFlags", libOrdered.Rule("ld").Args["ldFlags"],
+		"-Wl,--symbol-ordering-file=libOrdered.txt")
+	if libOrdered.MaybeOutput("afdo_symbol_order.txt").Rule != nil {
+		t.Errorf("symbol_ordering_file should take precedence over afdo_symbol_ordering")
+	}
+}
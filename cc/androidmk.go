@@ -547,6 +547,9 @@ func (c *snapshotLibraryDecorator) AndroidMkEntries(ctx AndroidMkContext, entrie
 
 	entries.SubName = ""
 
+	if c.sanitizerProperties.ScsEnabled {
+		entries.SubName += ".scs"
+	}
 	if c.sanitizerProperties.CfiEnabled {
 		entries.SubName += ".cfi"
 	}
@@ -0,0 +1,123 @@
+#!/usr/bin/env python
+#
+# Copyright (C) 2022 The Android Open Source Project
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#      http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+#
+"""Generates a machine readable description of the API of a library."""
+import argparse
+import json
+from pathlib import Path
+import sys
+from typing import Any, Dict, Iterable, List
+
+import symbolfile
+from symbolfile import Arch, Version
+
+
+def describe_versions(versions: Iterable[Version],
+                      arch: Arch) -> List[Dict[str, Any]]:
+    """Returns the description of the symbols of the given versions.
+
+    Symbols that aren't available for the given architecture are omitted.
+    """
+    described = []
+    for version in versions:
+        described.append({
+            'name': version.name,
+            'base': version.base,
+            'private': version.is_private,
+            'tags': list(version.tags),
+            'symbols': [{
+                'name': symbol.name,
+                'tags': list(symbol.tags),
+            } for symbol in version.symbols
+                        if symbolfile.symbol_in_arch(symbol.tags, arch)],
+        })
+    return described
+
+
+def describe_api(name: str, include_dirs: Iterable[str],
+                 headers: Iterable[str], dependencies: Iterable[str],
+                 versions: Iterable[Version], arch: Arch) -> Dict[str, Any]:
+    """Returns the description of the API of a library."""
+    return {
+        'name': name,
+        'arch': arch,
+        'include_dirs': sorted(include_dirs),
+        'headers': sorted(headers),
+        'dependencies': sorted(dependencies),
+        'versions': describe_versions(versions, arch),
+    }
+
+
+def parse_args() -> argparse.Namespace:
+    """Parses and returns command line arguments."""
+    parser = argparse.ArgumentParser()
+
+    def resolved_path(raw: str) -> Path:
+        """Returns a resolved Path for the given string."""
+        return Path(raw).resolve()
+
+    parser.add_argument('--name', required=True, help='Name of the library.')
+    parser.add_argument(
+        '--arch', choices=symbolfile.ALL_ARCHITECTURES, required=True,
+        help='Architecture being targeted.')
+    parser.add_argument('--api-map',
+                        type=resolved_path,
+                        required=True,
+                        help='Path to the API level map JSON file.')
+    parser.add_argument('--include-dir', action='append', default=[],
+                        dest='include_dirs',
+                        help='Exported include directory.')
+    parser.add_argument('--header', action='append', default=[],
+                        dest='headers', help='Exported header.')
+    parser.add_argument('--dep', action='append', default=[],
+                        dest='dependencies',
+                        help='Shared library the library depends on.')
+
+    parser.add_argument('symbol_file',
+                        type=resolved_path,
+                        help='Path to symbol file.')
+    parser.add_argument('output',
+                        type=resolved_path,
+                        help='Path to output JSON description.')
+
+    return parser.parse_args()
+
+
+def main() -> None:
+    """Program entry point."""
+    args = parse_args()
+
+    with args.api_map.open() as map_file:
+        api_map = json.load(map_file)
+
+    with args.symbol_file.open() as symbol_file:
+        try:
+            versions = symbolfile.SymbolFileParser(
+                symbol_file, api_map, args.arch, symbolfile.FUTURE_API_LEVEL,
+                False, False).parse()
+        except (symbolfile.ParseError,
+                symbolfile.MultiplyDefinedSymbolError) as ex:
+            sys.exit(f'{args.symbol_file}: error: {ex}')
+
+    description = describe_api(args.name, args.include_dirs, args.headers,
+                               args.dependencies, versions, args.arch)
+    with args.output.open('w') as output:
+        json.dump(description, output, indent=2)
+        output.write('\n')
+
+
+if __name__ == '__main__':
+    main()
@@ -0,0 +1,89 @@
+#!/usr/bin/env python
+#
+# Copyright (C) 2022 The Android Open Source Project
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#      http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+#
+"""Tests for apidescription."""
+import io
+import textwrap
+import unittest
+
+import symbolfile
+from symbolfile import Arch
+
+import apidescription
+
+
+# pylint: disable=missing-docstring
+
+
+class DescribeApiTest(unittest.TestCase):
+    def test_describe_api(self) -> None:
+        input_file = io.StringIO(textwrap.dedent("""\
+            LIBFOO { # introduced=29
+                global:
+                    foo;
+                    bar; # arm64
+                    baz; # x86
+                local:
+                    *;
+            };
+
+            LIBFOO_PRIVATE {
+                global:
+                    qux;
+            } LIBFOO;
+        """))
+        versions = symbolfile.SymbolFileParser(input_file, {}, Arch('arm64'),
+                                               symbolfile.FUTURE_API_LEVEL,
+                                               False, False).parse()
+
+        description = apidescription.describe_api(
+            'libfoo', ['foo/include'], ['foo/include/foo.h'], ['libc'],
+            versions, Arch('arm64'))
+
+        self.assertEqual('libfoo', description['name'])
+        self.assertEqual(['foo/include'], description['include_dirs'])
+        self.assertEqual(['foo/include/foo.h'], description['headers'])
+        self.assertEqual(['libc'], description['dependencies'])
+        self.assertEqual([
+            {
+                'name': 'LIBFOO',
+                'base': None,
+                'private': False,
+                'tags': ['introduced=29'],
+                'symbols': [
+                    {'name': 'foo', 'tags': []},
+                    {'name': 'bar', 'tags': ['arm64']},
+                ],
+            },
+            {
+                'name': 'LIBFOO_PRIVATE',
+                'base': 'LIBFOO',
+                'private': True,
+                'tags': [],
+                'symbols': [
+                    {'name': 'qux', 'tags': []},
+                ],
+            },
+        ], description['versions'])
+
+
+def main() -> None:
+    suite = unittest.TestLoader().loadTestsFromName(__name__)
+    unittest.TextTestRunner(verbosity=3).run(suite)
+
+
+if __name__ == '__main__':
+    main()
@@ -149,6 +149,9 @@ func (binary *binaryDecorator) getStem(ctx BaseModuleContext) string {
 // modules common to most binaries, such as bionic libraries.
 func (binary *binaryDecorator) linkerDeps(ctx DepsContext, deps Deps) Deps {
 	deps = binary.baseLinker.linkerDeps(ctx, deps)
+	if binary.freestanding() && !binary.static() {
+		ctx.PropertyErrorf("freestanding", "freestanding binaries must be static_executable")
+	}
 	if !Bool(binary.baseLinker.Properties.Nocrt) {
 		if binary.static() {
 			deps.CrtBegin = ctx.toolchain().CrtBeginStaticBinary()
@@ -232,6 +235,11 @@ func (binary *binaryDecorator) linkerInit(ctx BaseModuleContext) {
 		// Static executables are not supported on Darwin or Windows
 		binary.Properties.Static_executable = nil
 	}
+
+	if ctx.Os() == android.Baremetal {
+		// There is no dynamic linker on baremetal targets
+		binary.Properties.Static_executable = BoolPtr(true)
+	}
 }
 
 func (binary *binaryDecorator) static() bool {
@@ -369,6 +377,7 @@ func (binary *binaryDecorator) link(ctx ModuleContext,
 	}
 
 	binary.unstrippedOutputFile = outputFile
+	binary.breakpadSymbols(ctx, outputFile)
 
 	if String(binary.Properties.Prefix_symbols) != "" {
 		afterPrefixSymbols := outputFile
@@ -428,12 +437,20 @@ func (binary *binaryDecorator) link(ctx ModuleContext,
 	}
 
 	validations = append(validations, objs.tidyDepFiles...)
+	validations = append(validations, objs.stdMatrixFiles...)
+	validations = append(validations, objs.warningBaselineFiles...)
+	validations = append(validations, binary.odrCheck(ctx, deps, objs)...)
+	validations = append(validations, ifuncCheck(ctx, outputFile)...)
+	validations = append(validations, branchProtectionPrebuiltsCheck(ctx)...)
+	implicitOutputs, unusedDepsReport := binary.unusedDepsCheck(ctx, &builderFlags, outputFile)
+	binary.memoryUsage(ctx, &builderFlags)
+	validations = append(validations, unusedDepsReport...)
 	linkerDeps = append(linkerDeps, flags.LdFlagsDeps...)
 
 	// Register link action.
 	transformObjToDynamicBinary(ctx, objs.objFiles, sharedLibs, deps.StaticLibs,
 		deps.LateStaticLibs, deps.WholeStaticLibs, linkerDeps, deps.CrtBegin, deps.CrtEnd, true,
-		builderFlags, outputFile, nil, validations)
+		builderFlags, outputFile, implicitOutputs, validations)
 
 	objs.coverageFiles = append(objs.coverageFiles, deps.StaticLibObjs.coverageFiles...)
 	objs.coverageFiles = append(objs.coverageFiles, deps.WholeStaticLibObjs.coverageFiles...)
@@ -686,7 +686,8 @@ func (la *linkerAttributes) bp2buildForAxisAndConfig(ctx android.BazelConversion
 			axisFeatures = append(axisFeatures, "-static_flag")
 		}
 	}
-	if props.Version_script != nil {
+	// Automatic version scripts are generated from the symbol file, which isn't supported in Bazel.
+	if props.Version_script != nil && *props.Version_script != versionScriptAuto {
 		label := android.BazelLabelForModuleSrcSingle(ctx, *props.Version_script)
 		la.additionalLinkerInputs.SetSelectValue(axis, config, bazel.LabelList{Includes: []bazel.Label{label}})
 		linkerFlags = append(linkerFlags, fmt.Sprintf("-Wl,--version-script,$(location %s)", label.Label))
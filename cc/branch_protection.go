@@ -0,0 +1,150 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cc
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/google/blueprint"
+
+	"android/soong/android"
+)
+
+const branchProtectionFlag = "-mbranch-protection=standard"
+
+var (
+	// Rule to list the linked outputs that don't have the BTI property, from a list of
+	// "<partition> <module> <file>" lines.
+	btiAudit = pctx.AndroidStaticRule("btiAudit",
+		blueprint.RuleParams{
+			Command: `rm -f $out && while read partition module file; do ` +
+				`if ! ${config.ClangBin}/llvm-readelf --notes $$file | grep -q 'AArch64 feature:.*BTI'; then ` +
+				`echo "$$partition $$module $$file"; fi; done < $list > $out`,
+			CommandDeps: []string{"${config.ClangBin}/llvm-readelf"},
+		}, "list")
+)
+
+// branchProtection returns true if the module is compiled with -mbranch-protection=standard,
+// which is the case for the arm64 device variants when the branch_protection property is set or
+// when the product enables branch protection for the partition the module is installed to.
+func (c *Module) branchProtection(ctx BaseModuleContext) bool {
+	return BranchProtectionEnabled(ctx, c, c.Properties.Branch_protection)
+}
+
+// BranchProtectionEnabled returns true if the variant of the module should be compiled with
+// branch protection, given the value of its branch_protection property. It applies the same
+// per-partition policy to the modules of other packages implementing LinkableInterface, like the
+// rust modules.
+func BranchProtectionEnabled(ctx android.BaseModuleContext, m LinkableInterface, property *bool) bool {
+	if !ctx.Device() || ctx.Arch().ArchType != android.Arm64 {
+		return false
+	}
+	if property != nil {
+		return *property
+	}
+	return ctx.Config().BranchProtectionEnabledForPartition(PolicyPartition(ctx, m))
+}
+
+// branchProtectionPrebuiltsCheck returns the stamp files of the checks that the prebuilt libraries
+// linked into a module compiled with branch protection were built with BTI, to be used as
+// validations of the link.  Otherwise the linker silently drops the BTI property of the output.
+func branchProtectionPrebuiltsCheck(ctx ModuleContext) android.Paths {
+	if !ctx.Module().(*Module).branchProtection(ctx) {
+		return nil
+	}
+
+	var checks android.Paths
+	ctx.VisitDirectDeps(func(dep android.Module) {
+		tag, ok := ctx.OtherModuleDependencyTag(dep).(libraryDependencyTag)
+		if !ok || tag.header() {
+			return
+		}
+		ccDep, ok := dep.(*Module)
+		if !ok || !ccDep.IsPrebuilt() || !ccDep.OutputFile().Valid() {
+			return
+		}
+		checks = append(checks, transformPrebuiltToBtiCheck(ctx, ccDep.OutputFile().Path(),
+			ctx.OtherModuleName(dep)))
+	})
+	return checks
+}
+
+func btiAuditSingletonFactory() android.Singleton {
+	return &btiAuditSingleton{}
+}
+
+// btiAuditSingleton writes bti-audit.txt, which lists the cc and rust binaries and shared libraries
+// installed to the device whose arm64 variant doesn't have the BTI property, as
+// "<partition> <module> <file>" lines, to find the modules that prevent enabling branch protection
+// for a partition.
+type btiAuditSingleton struct {
+	outputFile android.WritablePath
+}
+
+func (s *btiAuditSingleton) GenerateBuildActions(ctx android.SingletonContext) {
+	lines := make(map[string]string)
+	var files android.Paths
+	ctx.VisitAllModules(func(module android.Module) {
+		c, ok := module.(LinkableInterface)
+		if !ok || !module.Enabled() || module.IsSkipInstall() || !c.EverInstallable() || !c.OutputFile().Valid() {
+			return
+		}
+		if module.Os() != android.Android || module.Target().Arch.ArchType != android.Arm64 {
+			return
+		}
+		if !c.Binary() && !c.Dylib() && (!c.CcLibraryInterface() || !c.Shared()) {
+			return
+		}
+
+		file := c.OutputFile().Path()
+		if _, exists := lines[file.String()]; !exists {
+			lines[file.String()] = fmt.Sprintf("%s %s %s",
+				module.PartitionTag(ctx.DeviceConfig()), ctx.ModuleName(module), file)
+			files = append(files, file)
+		}
+	})
+
+	if len(files) == 0 {
+		return
+	}
+
+	var content []string
+	for _, file := range android.SortedStringKeys(lines) {
+		content = append(content, lines[file])
+	}
+	list := android.PathForOutput(ctx, "bti-audit.list")
+	android.WriteFileRule(ctx, list, strings.Join(content, "\n"))
+
+	s.outputFile = android.PathForOutput(ctx, "bti-audit.txt")
+	ctx.Build(pctx, android.BuildParams{
+		Rule:        btiAudit,
+		Description: "bti audit",
+		Output:      s.outputFile,
+		Inputs:      android.SortedUniquePaths(files),
+		Implicit:    list,
+		Args: map[string]string{
+			"list": list.String(),
+		},
+	})
+
+	ctx.Phony("bti-audit", s.outputFile)
+}
+
+func (s *btiAuditSingleton) MakeVars(ctx android.MakeVarsContext) {
+	if s.outputFile != nil {
+		ctx.DistForGoal("bti-audit", s.outputFile)
+	}
+}
@@ -0,0 +1,95 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cc
+
+import (
+	"strings"
+	"testing"
+
+	"android/soong/android"
+)
+
+func TestBranchProtection(t *testing.T) {
+	t.Parallel()
+	result := android.GroupFixturePreparers(
+		prepareForCcTest,
+		android.FixtureAddFile("libprebuilt.so", nil),
+		android.FixtureModifyProductVariables(func(variables android.FixtureProductVariables) {
+			variables.BranchProtectionIncludePartitions = []string{"vendor"}
+		}),
+	).RunTestWithBp(t, `
+		cc_library_shared {
+			name: "libvendor",
+			srcs: ["foo.c"],
+			vendor: true,
+			shared_libs: ["libprebuilt"],
+		}
+		cc_library_shared {
+			name: "libvendor_opt_out",
+			srcs: ["foo.c"],
+			vendor: true,
+			shared_libs: ["libprebuilt"],
+			branch_protection: false,
+		}
+		cc_library_shared {
+			name: "libsystem",
+			srcs: ["foo.c"],
+		}
+		cc_library_shared {
+			name: "libsystem_opt_in",
+			srcs: ["foo.c"],
+			branch_protection: true,
+		}
+		cc_prebuilt_library_shared {
+			name: "libprebuilt",
+			srcs: ["libprebuilt.so"],
+			vendor: true,
+		}
+	`)
+
+	vendor := "android_vendor.29_arm64_armv8-a_shared"
+	system := "android_arm64_armv8-a_shared"
+
+	checkBranchProtection := func(module, variant string, expected bool) {
+		t.Helper()
+		cFlags := result.ModuleForTests(module, variant).Rule("cc").Args["cFlags"]
+		android.AssertBoolEquals(t, module+" ["+variant+"] branch protection", expected,
+			strings.Contains(cFlags, branchProtectionFlag))
+	}
+	checkBranchProtection("libvendor", vendor, true)
+	checkBranchProtection("libvendor", "android_vendor.29_arm_armv7-a-neon_shared", false)
+	checkBranchProtection("libvendor_opt_out", vendor, false)
+	checkBranchProtection("libsystem", system, false)
+	checkBranchProtection("libsystem_opt_in", system, true)
+
+	libvendor := result.ModuleForTests("libvendor", vendor)
+	check := libvendor.Output("bti_check/libprebuilt.stamp")
+	android.AssertStringListContains(t, "libvendor validations",
+		libvendor.Rule("ld").Validations.Strings(), check.Output.String())
+	android.AssertStringEquals(t, "bti check module", "libvendor", check.Args["module"])
+
+	libvendorOptOut := result.ModuleForTests("libvendor_opt_out", vendor)
+	android.AssertBoolEquals(t, "libvendor_opt_out checks prebuilts", false,
+		libvendorOptOut.MaybeOutput("bti_check/libprebuilt.stamp").Rule != nil)
+
+	audit := result.SingletonForTests("bti_audit")
+	list := android.StringRelativeToTop(result.Config,
+		android.ContentFromFileRuleForTests(t, audit.Output("bti-audit.list")))
+	android.AssertStringDoesContain(t, "bti-audit.list", list,
+		"vendor libvendor out/soong/.intermediates/libvendor/"+vendor+"/libvendor.so")
+	android.AssertStringDoesContain(t, "bti-audit.list", list,
+		"system libsystem out/soong/.intermediates/libsystem/"+system+"/libsystem.so")
+	android.AssertStringDoesNotContain(t, "bti-audit.list", list, "android_arm_armv7-a-neon")
+}
@@ -0,0 +1,70 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cc
+
+import (
+	"android/soong/android"
+)
+
+func breakpadSymbolsSingletonFactory() android.Singleton {
+	return &breakpadSymbolsSingleton{}
+}
+
+// breakpadSymbolsSingleton packages the Breakpad symbol files of all the modules that set
+// breakpad_symbols into breakpad-symbols.zip, laid out as <name>/<id>/<name>.sym so that crash
+// ingestion can look them up by the build id of the crashing binary.
+type breakpadSymbolsSingleton struct {
+	outputFile android.WritablePath
+}
+
+type breakpadSymbolsProducer interface {
+	breakpadSymbolsZipPath() android.OptionalPath
+}
+
+func (s *breakpadSymbolsSingleton) GenerateBuildActions(ctx android.SingletonContext) {
+	var zips android.Paths
+	ctx.VisitAllModules(func(module android.Module) {
+		if ccModule, ok := module.(*Module); ok && ccModule.linker != nil {
+			if producer, ok := ccModule.linker.(breakpadSymbolsProducer); ok {
+				if zip := producer.breakpadSymbolsZipPath(); zip.Valid() {
+					zips = append(zips, zip.Path())
+				}
+			}
+		}
+	})
+
+	if len(zips) == 0 {
+		return
+	}
+
+	// Variants that share an unstripped output, e.g. the platform and apex variants of a library,
+	// have the same build id and so the same symbol file.
+	s.outputFile = android.PathForOutput(ctx, "breakpad-symbols.zip")
+	rule := android.NewRuleBuilder(pctx, ctx)
+	rule.Command().BuiltTool("merge_zips").
+		Flag("-s").
+		Flag("-ignore-duplicates").
+		Output(s.outputFile).
+		Inputs(android.SortedUniquePaths(zips))
+	rule.Build("breakpad_symbols_zip", "breakpad-symbols.zip")
+
+	ctx.Phony("breakpad-symbols", s.outputFile)
+}
+
+func (s *breakpadSymbolsSingleton) MakeVars(ctx android.MakeVarsContext) {
+	if s.outputFile != nil {
+		ctx.DistForGoal("breakpad-symbols", s.outputFile)
+	}
+}
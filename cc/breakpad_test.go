@@ -0,0 +1,63 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cc
+
+import (
+	"testing"
+
+	"android/soong/android"
+)
+
+func TestBreakpadSymbols(t *testing.T) {
+	result := prepareForCcTest.RunTestWithBp(t, `
+	cc_library_shared {
+		name: "libTest",
+		srcs: ["foo.c"],
+		breakpad_symbols: true,
+	}
+
+	cc_binary {
+		name: "binTest",
+		srcs: ["foo.c"],
+		breakpad_symbols: true,
+	}
+
+	cc_library_shared {
+		name: "libNoSymbols",
+		srcs: ["bar.c"],
+	}
+	`)
+
+	lib := result.ModuleForTests("libTest", "android_arm64_armv8-a_shared")
+	syms := lib.Rule("dumpBreakpadSymbols")
+	android.AssertStringEquals(t, "libTest breakpad input",
+		lib.Module().(*Module).UnstrippedOutputFile().String(), syms.Input.String())
+
+	if result.ModuleForTests("libNoSymbols", "android_arm64_armv8-a_shared").MaybeRule("dumpBreakpadSymbols").Rule != nil {
+		t.Errorf("expected no breakpad symbols for libNoSymbols")
+	}
+
+	zip := result.SingletonForTests("breakpad_symbols").Output("breakpad-symbols.zip")
+	inputs := android.PathsRelativeToTop(zip.Implicits)
+	for _, input := range []string{
+		"out/soong/.intermediates/libTest/android_arm64_armv8-a_shared/breakpad/libTest.so.sym.zip",
+		"out/soong/.intermediates/libTest/android_arm_armv7-a-neon_shared/breakpad/libTest.so.sym.zip",
+		"out/soong/.intermediates/binTest/android_arm64_armv8-a/breakpad/binTest.sym.zip",
+	} {
+		android.AssertStringListContains(t, "breakpad-symbols.zip inputs", inputs, input)
+	}
+	android.AssertStringListDoesNotContain(t, "breakpad-symbols.zip inputs", inputs,
+		"out/soong/.intermediates/libNoSymbols/android_arm64_armv8-a_shared/breakpad/libNoSymbols.so.sym.zip")
+}
@@ -26,6 +26,7 @@ import (
 
 	"github.com/google/blueprint"
 	"github.com/google/blueprint/pathtools"
+	"github.com/google/blueprint/proptools"
 
 	"android/soong/android"
 	"android/soong/cc/config"
@@ -45,11 +46,33 @@ var (
 		blueprint.RuleParams{
 			Depfile:     "${out}.d",
 			Deps:        blueprint.DepsGCC,
-			Command:     "$relPwd ${config.CcWrapper}$ccCmd -c $cFlags -MD -MF ${out}.d -o $out $in",
+			Command:     "$relPwd ${config.LocalActionCache}${config.CcWrapper}$ccCmd -c $cFlags -MD -MF ${out}.d -o $out $in",
 			CommandDeps: []string{"$ccCmd"},
 		},
 		"ccCmd", "cFlags")
 
+	// Rule to invoke gcc with given command, flags, and dependencies like cc, also saving the
+	// warnings in $warnings for the warning_baseline check.  Outputs a .d depfile.
+	ccWarnings = pctx.AndroidRemoteStaticRule("ccWarnings", android.RemoteRuleSupports{Goma: true, RBE: true},
+		blueprint.RuleParams{
+			Depfile:     "${out}.d",
+			Deps:        blueprint.DepsGCC,
+			Command:     "$relPwd ${config.LocalActionCache}${config.CcWrapper}$ccCmd -c $cFlags -MD -MF ${out}.d -o $out $in 2> $warnings; code=$$?; cat $warnings >&2; exit $$code",
+			CommandDeps: []string{"$ccCmd"},
+		},
+		"ccCmd", "cFlags", "warnings")
+
+	_ = pctx.SourcePathVariable("checkWarningBaselinePath", "build/soong/scripts/check_warning_baseline.sh")
+
+	// Rule to check the counts of the warnings saved by ccWarnings against the warning_baseline of
+	// a module.
+	checkWarningBaseline = pctx.AndroidStaticRule("checkWarningBaseline",
+		blueprint.RuleParams{
+			Command:     "$checkWarningBaselinePath $out $baselines -- $in",
+			CommandDeps: []string{"$checkWarningBaselinePath"},
+		},
+		"baselines")
+
 	// Rule to invoke gcc with given command and flags, but no dependencies.
 	ccNoDeps = pctx.AndroidStaticRule("ccNoDeps",
 		blueprint.RuleParams{
@@ -80,6 +103,19 @@ var (
 			Platform:        map[string]string{remoteexec.PoolKey: "${config.RECXXLinksPool}"},
 		}, []string{"ldCmd", "crtBegin", "libFlags", "crtEnd", "ldFlags", "extraLibFlags"}, []string{"implicitInputs", "implicitOutputs"})
 
+	// Rule to invoke ld like ld, also saving the usage of the memory regions of the linker script
+	// in $memoryUsage for the stack usage report.
+	ldMemoryUsage = pctx.AndroidStaticRule("ldMemoryUsage",
+		blueprint.RuleParams{
+			Command: "$ldCmd ${crtBegin} @${out}.rsp ${libFlags} ${crtEnd} -o ${out} ${ldFlags} ${extraLibFlags} " +
+				"-Wl,--print-memory-usage > ${memoryUsage}",
+			CommandDeps:    []string{"$ldCmd"},
+			Rspfile:        "${out}.rsp",
+			RspfileContent: "${in}",
+			Restat:         true,
+		},
+		"ldCmd", "crtBegin", "libFlags", "crtEnd", "ldFlags", "extraLibFlags", "memoryUsage")
+
 	// Rules for .o files to combine to other .o files, using ld partial linking.
 	partialLd, partialLdRE = pctx.RemoteStaticRules("partialLd",
 		blueprint.RuleParams{
@@ -241,6 +277,21 @@ var (
 			Platform: map[string]string{remoteexec.PoolKey: "${config.REClangTidyPool}"},
 		}, []string{"cFlags", "tidyFlags", "tidyVars"}, []string{})
 
+	// Rule for invoking clang-tidy only if the source file was changed since a git revision, used
+	// when TIDY_CHANGED_SINCE is set.  Source files that are not tracked by git are always checked.
+	clangTidyChanged = pctx.AndroidStaticRule("clangTidyChanged",
+		blueprint.RuleParams{
+			Depfile: "${out}.d",
+			Deps:    blueprint.DepsGCC,
+			Command: "cp ${out}.dep ${out}.d && " +
+				"if git -C $$(dirname $in) ls-files --error-unmatch $$(basename $in) >/dev/null 2>&1 && " +
+				"git -C $$(dirname $in) diff --quiet $changedSince -- $$(basename $in); then true; " +
+				"else $tidyVars${config.ClangBin}/clang-tidy $tidyFlags $in -- $cFlags; fi && " +
+				"touch $out",
+			CommandDeps: []string{"${config.ClangBin}/clang-tidy"},
+		},
+		"cFlags", "tidyFlags", "tidyVars", "changedSince")
+
 	_ = pctx.SourcePathVariable("yasmCmd", "prebuilts/misc/${config.HostPrebuiltTag}/yasm/yasm")
 
 	// Rule for invoking yasm to compile .asm assembly files.
@@ -342,6 +393,192 @@ var (
 			CommandDeps: []string{"$cxxExtractor", "$kytheVnames"},
 		},
 		"cFlags")
+
+	_ = pctx.HostBinToolVariable("afdoSymbolOrderCmd", "afdo_symbol_order")
+
+	// Rule to derive a symbol ordering file for lld from an afdo profile.
+	afdoSymbolOrder = pctx.AndroidStaticRule("afdoSymbolOrder",
+		blueprint.RuleParams{
+			Command: "${config.ClangBin}/llvm-profdata show --sample --all-functions $in > $out.tmp && " +
+				"$afdoSymbolOrderCmd --output $out $out.tmp && rm -f $out.tmp",
+			CommandDeps: []string{"${config.ClangBin}/llvm-profdata", "$afdoSymbolOrderCmd"},
+		})
+
+	_ = pctx.HostBinToolVariable("stackUsageReportCmd", "stack_usage_report")
+
+	// Rule to combine the .su stack usage files of a module into a single JSON report.
+	stackUsageReport = pctx.AndroidStaticRule("stackUsageReport",
+		blueprint.RuleParams{
+			Command:        "$stackUsageReportCmd --output $out $memoryUsageFlags @$out.rsp",
+			CommandDeps:    []string{"$stackUsageReportCmd"},
+			Rspfile:        "$out.rsp",
+			RspfileContent: "$in",
+		},
+		"memoryUsageFlags")
+
+	_ = pctx.HostBinToolVariable("checkOdrCmd", "check_odr")
+
+	// Rule to check that the structs and classes in the debug info of a set of objects and
+	// archives have a single definition.
+	checkOdr = pctx.AndroidStaticRule("checkOdr",
+		blueprint.RuleParams{
+			Command: "$checkOdrCmd --dwarfdump ${config.ClangBin}/llvm-dwarfdump " +
+				"--output $out @$out.rsp",
+			CommandDeps:    []string{"$checkOdrCmd", "${config.ClangBin}/llvm-dwarfdump"},
+			Rspfile:        "$out.rsp",
+			RspfileContent: "$in",
+		})
+
+	_ = pctx.HostBinToolVariable("checkUnusedDepsCmd", "check_unused_deps")
+
+	// Rule to report the shared and static libraries that contributed no symbols to a linked
+	// binary or shared library, using its link map.
+	checkUnusedDeps = pctx.AndroidStaticRule("checkUnusedDeps",
+		blueprint.RuleParams{
+			Command: "$checkUnusedDepsCmd --readelf ${config.ClangBin}/llvm-readelf " +
+				"--link-map $linkMap --linked $linked $errorFlag --output $out @$out.rsp",
+			CommandDeps:    []string{"$checkUnusedDepsCmd", "${config.ClangBin}/llvm-readelf"},
+			Rspfile:        "$out.rsp",
+			RspfileContent: "$libs",
+		},
+		"linkMap", "linked", "errorFlag", "libs")
+
+	// Rule to check that a linked binary or shared library doesn't define ifuncs, which crash
+	// at load time when it is built with asan or hwasan.
+	checkIfunc = pctx.AndroidStaticRule("checkIfunc",
+		blueprint.RuleParams{
+			Command: `set -o pipefail && ${config.ClangBin}/llvm-readelf --symbols --wide $in | ` +
+				`awk '$$4 == "IFUNC" && $$7 != "UND" { print $$8 }' | sort -u > $out && ` +
+				`if [ -s $out ]; then ` +
+				`echo "error: $in defines ifuncs, which are not supported with asan and hwasan:" >&2 && ` +
+				`cat $out >&2 && ` +
+				`echo "Resolve the implementation without an ifunc in sanitized builds, or remove sanitize: { config: { ifunc_check: true } }." >&2 && ` +
+				`rm $out && exit 1; fi`,
+			CommandDeps: []string{"${config.ClangBin}/llvm-readelf"},
+		})
+
+	// Rule to check that a prebuilt binary or shared library has no text relocations, which the
+	// dynamic linker refuses to load.
+	checkTextRelocations = pctx.AndroidStaticRule("checkTextRelocations",
+		blueprint.RuleParams{
+			Command: `rm -f $out && ` +
+				`if ${config.ClangBin}/llvm-readelf --dynamic-table $in | grep -qw TEXTREL; then ` +
+				`echo "error: $in has text relocations, which are not supported by the dynamic linker." >&2 && ` +
+				`echo "Rebuild it with -fPIC, or set allow_text_relocations: true if it can't be." >&2 && ` +
+				`exit 1; fi && touch $out`,
+			CommandDeps: []string{"${config.ClangBin}/llvm-readelf"},
+		})
+
+	// Rule to check that a prebuilt library linked into a module compiled with branch protection has
+	// the BTI property, in all of its objects in the case of a static library.
+	checkBti = pctx.AndroidStaticRule("checkBti",
+		blueprint.RuleParams{
+			Command: `rm -f $out && notes=$$(${config.ClangBin}/llvm-readelf --notes $in) && ` +
+				`files=$$(echo "$$notes" | grep -c '^File: ' || true) && ` +
+				`bti=$$(echo "$$notes" | grep -c 'AArch64 feature:.*BTI' || true) && ` +
+				`if [ $$bti -eq 0 ] || [ $$bti -lt $$files ]; then ` +
+				`echo "error: $in is linked into $module, which is compiled with branch protection, but it was not built with BTI." >&2 && ` +
+				`echo "Rebuild the prebuilt with -mbranch-protection=standard, or set branch_protection: false in $module." >&2 && ` +
+				`exit 1; fi && touch $out`,
+			CommandDeps: []string{"${config.ClangBin}/llvm-readelf"},
+		}, "module")
+
+	// Rule to check that the symbols kept by gc_sections_keep are exported by the version script
+	// of a shared library, that is that they are defined in its dynamic symbol table.
+	checkGcSectionsKeep = pctx.AndroidStaticRule("checkGcSectionsKeep",
+		blueprint.RuleParams{
+			Command: `rm -f $out && ${config.ClangBin}/llvm-readelf --dyn-syms --wide $in | ` +
+				`awk '$$7 != "UND" && $$5 != "LOCAL" { sub(/@.*/, "", $$8); print $$8 }' | ` +
+				`LC_ALL=C sort -u > ${out}.exported && ` +
+				`printf '%s\n' $symbols | LC_ALL=C sort -u | LC_ALL=C comm -23 - ${out}.exported > ${out}.missing && ` +
+				`if [ -s ${out}.missing ]; then ` +
+				`echo "error: gc_sections_keep symbols of $in are not exported by $versionScript:" >&2 && ` +
+				`cat ${out}.missing >&2 && ` +
+				`rm -f ${out}.exported ${out}.missing && exit 1; fi && ` +
+				`rm -f ${out}.exported ${out}.missing && touch $out`,
+			CommandDeps: []string{"${config.ClangBin}/llvm-readelf"},
+		}, "versionScript", "symbols")
+
+	// Rule to check that a shared library that links libc++ statically doesn't define libc++
+	// symbols, those in the std::__1 or std::__ndk1 namespaces, in its dynamic symbol table.
+	checkStaticStlExports = pctx.AndroidStaticRule("checkStaticStlExports",
+		blueprint.RuleParams{
+			Command: `rm -f $out && ${config.ClangBin}/llvm-readelf --dyn-syms --wide $in | ` +
+				`awk '$$7 != "UND" && $$5 != "LOCAL" { sub(/@.*/, "", $$8); print $$8 }' | ` +
+				`grep -E '^_Z(N|NK|T[ISVT]N)St(3__1|6__ndk1)' > ${out}.exported ; ` +
+				`if [ -s ${out}.exported ]; then ` +
+				`echo "error: $in links libc++ statically and exports libc++ symbols:" >&2 && ` +
+				`head -n 20 ${out}.exported >&2 && ` +
+				`echo "Hide them with a version script, or set allow_static_stl_exports: true if they must be exported." >&2 && ` +
+				`rm -f ${out}.exported && exit 1; fi && ` +
+				`rm -f ${out}.exported && touch $out`,
+			CommandDeps: []string{"${config.ClangBin}/llvm-readelf"},
+		})
+
+	// Rule to dump the Breakpad symbols of an unstripped binary or shared library and zip them at
+	// <name>/<id>/<name>.sym, the layout expected by symbol servers, where <id> is the Breakpad
+	// module id derived from the ELF build id.
+	dumpBreakpadSymbols = pctx.AndroidStaticRule("dumpBreakpadSymbols",
+		blueprint.RuleParams{
+			Command: `rm -rf $out ${out}.tmp && mkdir -p ${out}.tmp && ` +
+				`${dumpSymsCmd} $in > ${out}.tmp/sym && ` +
+				`read -r _ _ _ id name < ${out}.tmp/sym && ` +
+				`if [ -z "$$id" ] || [ -z "$$name" ]; then ` +
+				`echo "error: dump_syms didn't write a MODULE record for $in" >&2 && exit 1; fi && ` +
+				`mkdir -p "${out}.tmp/$$name/$$id" && mv ${out}.tmp/sym "${out}.tmp/$$name/$$id/$$name.sym" && ` +
+				`${SoongZipCmd} -o $out -C ${out}.tmp -D ${out}.tmp && rm -rf ${out}.tmp`,
+			CommandDeps: []string{"${dumpSymsCmd}", "${SoongZipCmd}"},
+		})
+
+	// Rule to turn the version script generated from a symbol file into one that hides every
+	// symbol that isn't listed in it, by adding a local: *; pattern to the first version node.
+	autoVersionScript = pctx.AndroidStaticRule("autoVersionScript",
+		blueprint.RuleParams{
+			Command: `awk '!done && /^}/ { print "    local:"; print "        *;"; done = 1 } { print } ` +
+				`END { if (!done) print "{ local: *; };" }' $in > $out`,
+		})
+
+	// Rule to check that the symbols exported by an automatic version script are a subset of
+	// the checked-in list of the symbols exported by the library.
+	checkExportedSymbols = pctx.AndroidStaticRule("checkExportedSymbols",
+		blueprint.RuleParams{
+			Command: `rm -f $out && ` +
+				`grep -v '^#' $exportedSymbols | LC_ALL=C sort -u > ${out}.expected && ` +
+				`LC_ALL=C sort -u $in | LC_ALL=C comm -13 ${out}.expected - > ${out}.added && ` +
+				`if [ -s ${out}.added ]; then ` +
+				`echo "error: $in exports symbols that are not in $exportedSymbols:" >&2 && ` +
+				`cat ${out}.added >&2 && ` +
+				`echo "If the ABI growth is intended, add them to $exportedSymbols." >&2 && ` +
+				`exit 1; fi && ` +
+				`rm -f ${out}.expected ${out}.added && touch $out`,
+		}, "exportedSymbols")
+
+	// Rule to check that a library with stubs that is built for an APEX exports no symbols that are
+	// not in the symbol list of its stubs, as the APEX only exports its stubs to other modules.
+	checkApexExportedSymbols = pctx.AndroidStaticRule("checkApexExportedSymbols",
+		blueprint.RuleParams{
+			Command: `rm -f $out && ` +
+				`${config.ClangBin}/llvm-nm -D --defined-only -j $in | sed 's/@.*//' | LC_ALL=C sort -u > ${out}.exported && ` +
+				`grep -v '^\[' $symbolList | LC_ALL=C sort -u > ${out}.stubs && ` +
+				`LC_ALL=C comm -23 ${out}.exported ${out}.stubs > ${out}.extra && ` +
+				`if [ -s ${out}.extra ]; then ` +
+				`echo "error: $in is exported by an APEX but exports symbols that are not in its stubs:" >&2 && ` +
+				`cat ${out}.extra >&2 && ` +
+				`echo "Add them to the stubs symbol file, or hide them with a version script." >&2 && ` +
+				`exit 1; fi && ` +
+				`rm -f ${out}.exported ${out}.stubs ${out}.extra && touch $out`,
+			CommandDeps: []string{"${config.ClangBin}/llvm-nm"},
+		}, "symbolList")
+
+	_ = pctx.HostBinToolVariable("apiDescriptionCmd", "apidescription")
+
+	// Rule to generate a JSON description of the API of a library from its symbol file.
+	apiDescription = pctx.AndroidStaticRule("apiDescription",
+		blueprint.RuleParams{
+			Command: "$apiDescriptionCmd --name $name --arch $arch --api-map $apiMap " +
+				"$flags $in $out",
+			CommandDeps: []string{"$apiDescriptionCmd"},
+		}, "name", "arch", "apiMap", "flags")
 )
 
 func PwdPrefix() string {
@@ -359,6 +596,7 @@ func init() {
 	pctx.StaticVariable("relPwd", PwdPrefix())
 
 	pctx.HostBinToolVariable("SoongZipCmd", "soong_zip")
+	pctx.HostBinToolVariable("dumpSymsCmd", "dump_syms")
 }
 
 // builderFlags contains various types of command line flags (and settings) for use in building
@@ -395,18 +633,29 @@ type builderFlags struct {
 	rsFlags       string // Flags that apply to renderscript source files
 	toolchain     config.Toolchain
 
+	// The alternate clang toolchain to build with, or nil for the default one.
+	toolchainFlavor *config.ToolchainFlavor
+
 	// True if these extra features are enabled.
-	sdclang      bool
 	tidy         bool
 	needTidyFiles bool
 	gcovCoverage bool
+	stackUsage   bool
 	sAbiDump     bool
 	emitXrefs    bool
 
 	assemblerWithCpp bool // True if .s files should be processed with the c preprocessor.
 
+	memoryUsageFile android.WritablePath // Where to save the memory usage printed by the linker, or nil.
+
 	systemIncludeFlags string
 
+	tidyBaselineChecks map[string][]string // clang-tidy checks to disable per source file path
+
+	countedWarnings []string // warnings to count for the warning_baseline check, without -W
+
+	thinArchive bool // True if static libraries should be emitted as thin archives.
+
 	proto            android.ProtoFlags
 	protoC           bool // If true, compile protos as `.c` files. Otherwise, output as `.cc`.
 	protoOptionsFile bool // If true, output a proto options file.
@@ -429,33 +678,48 @@ type StripFlags struct {
 
 // Objects is a collection of file paths corresponding to outputs for C++ related build statements.
 type Objects struct {
-	objFiles      android.Paths
-	tidyFiles     android.Paths
-	tidyDepFiles  android.Paths // link dependent .tidy files
-	coverageFiles android.Paths
-	sAbiDumpFiles android.Paths
-	kytheFiles    android.Paths
+	objFiles        android.Paths
+	tidyFiles       android.Paths
+	tidyDepFiles    android.Paths // link dependent .tidy files
+	coverageFiles   android.Paths
+	stackUsageFiles android.Paths
+	sAbiDumpFiles   android.Paths
+	kytheFiles      android.Paths
+	stdMatrixFiles  android.Paths // objects compiled for std_matrix, only used as validations
+
+	warningFiles         android.Paths // warnings of the sources counted for warning_baseline
+	warningBaselineFiles android.Paths // warning_baseline check stamps, only used as validations
 }
 
 func (a Objects) Copy() Objects {
 	return Objects{
-		objFiles:      append(android.Paths{}, a.objFiles...),
-		tidyFiles:     append(android.Paths{}, a.tidyFiles...),
-		tidyDepFiles:  append(android.Paths{}, a.tidyDepFiles...),
-		coverageFiles: append(android.Paths{}, a.coverageFiles...),
-		sAbiDumpFiles: append(android.Paths{}, a.sAbiDumpFiles...),
-		kytheFiles:    append(android.Paths{}, a.kytheFiles...),
+		objFiles:        append(android.Paths{}, a.objFiles...),
+		tidyFiles:       append(android.Paths{}, a.tidyFiles...),
+		tidyDepFiles:    append(android.Paths{}, a.tidyDepFiles...),
+		coverageFiles:   append(android.Paths{}, a.coverageFiles...),
+		stackUsageFiles: append(android.Paths{}, a.stackUsageFiles...),
+		sAbiDumpFiles:   append(android.Paths{}, a.sAbiDumpFiles...),
+		kytheFiles:      append(android.Paths{}, a.kytheFiles...),
+		stdMatrixFiles:  append(android.Paths{}, a.stdMatrixFiles...),
+
+		warningFiles:         append(android.Paths{}, a.warningFiles...),
+		warningBaselineFiles: append(android.Paths{}, a.warningBaselineFiles...),
 	}
 }
 
 func (a Objects) Append(b Objects) Objects {
 	return Objects{
-		objFiles:      append(a.objFiles, b.objFiles...),
-		tidyFiles:     append(a.tidyFiles, b.tidyFiles...),
-		tidyDepFiles:  append(a.tidyDepFiles, b.tidyDepFiles...),
-		coverageFiles: append(a.coverageFiles, b.coverageFiles...),
-		sAbiDumpFiles: append(a.sAbiDumpFiles, b.sAbiDumpFiles...),
-		kytheFiles:    append(a.kytheFiles, b.kytheFiles...),
+		objFiles:        append(a.objFiles, b.objFiles...),
+		tidyFiles:       append(a.tidyFiles, b.tidyFiles...),
+		tidyDepFiles:    append(a.tidyDepFiles, b.tidyDepFiles...),
+		coverageFiles:   append(a.coverageFiles, b.coverageFiles...),
+		stackUsageFiles: append(a.stackUsageFiles, b.stackUsageFiles...),
+		sAbiDumpFiles:   append(a.sAbiDumpFiles, b.sAbiDumpFiles...),
+		kytheFiles:      append(a.kytheFiles, b.kytheFiles...),
+		stdMatrixFiles:  append(a.stdMatrixFiles, b.stdMatrixFiles...),
+
+		warningFiles:         append(a.warningFiles, b.warningFiles...),
+		warningBaselineFiles: append(a.warningBaselineFiles, b.warningBaselineFiles...),
 	}
 }
 
@@ -485,10 +749,23 @@ func transformSourceToObj(ctx ModuleContext, subdir string, srcFiles, noTidySrcs
 	if flags.gcovCoverage {
 		coverageFiles = make(android.Paths, 0, len(srcFiles))
 	}
+	var stackUsageFiles android.Paths
+	if flags.stackUsage {
+		stackUsageFiles = make(android.Paths, 0, len(srcFiles))
+	}
 	var kytheFiles android.Paths
 	if flags.emitXrefs {
 		kytheFiles = make(android.Paths, 0, len(srcFiles))
 	}
+	var warningFiles android.Paths
+	var countedWarningsFlags string
+	if len(flags.countedWarnings) > 0 {
+		warningFiles = make(android.Paths, 0, len(srcFiles))
+		// The counted warnings are enabled even if the cflags disable them, and never errors.
+		for _, warning := range flags.countedWarnings {
+			countedWarningsFlags += " -W" + warning + " -Wno-error=" + warning
+		}
+	}
 
 	// Produce fully expanded flags for use by C tools, C compiles, C++ tools, C++ compiles, and asm compiles
 	// respectively.
@@ -548,6 +825,9 @@ func transformSourceToObj(ctx ModuleContext, subdir string, srcFiles, noTidySrcs
 		toolingCppflags += " ${config.NoOverrideExternalGlobalCflags}"
 	}
 
+	// The compiles depend on the action_cache tool they are wrapped with, if any.
+	localActionCache := config.LocalActionCacheTool(ctx)
+
 	// Multiple source files have build rules usually share the same cFlags or tidyFlags.
 	// Define only one version in this module and share it in multiple build rules.
 	// To simplify the code, the shared variables are all named as $flags<nnn>.
@@ -604,9 +884,11 @@ func transformSourceToObj(ctx ModuleContext, subdir string, srcFiles, noTidySrcs
 		var ccCmd string
 		tidy := flags.tidy
 		coverage := flags.gcovCoverage
+		stackUsage := flags.stackUsage
 		dump := flags.sAbiDump
 		rule := cc
 		emitXref := flags.emitXrefs
+		countWarnings := len(flags.countedWarnings) > 0
 
 		switch srcFile.Ext() {
 		case ".s":
@@ -619,8 +901,10 @@ func transformSourceToObj(ctx ModuleContext, subdir string, srcFiles, noTidySrcs
 			moduleFlags = asflags
 			tidy = false
 			coverage = false
+			stackUsage = false
 			dump = false
 			emitXref = false
+			countWarnings = false
 		case ".c":
 			ccCmd = "clang"
 			moduleFlags = cflags
@@ -639,13 +923,8 @@ func transformSourceToObj(ctx ModuleContext, subdir string, srcFiles, noTidySrcs
 
 		ccDesc := ccCmd
 
-		var extraFlags string
-		if flags.sdclang {
-			ccCmd = "${config.SDClangBin}/" + ccCmd
-			extraFlags = " ${config.SDClangFlags}"
-		} else {
-			ccCmd = "${config.ClangBin}/" + ccCmd
-		}
+		ccCmd = flags.clangBin() + "/" + ccCmd
+		extraFlags := flags.toolchainFlavorFlags()
 
 		var implicitOutputs android.WritablePaths
 		if coverage {
@@ -653,6 +932,31 @@ func transformSourceToObj(ctx ModuleContext, subdir string, srcFiles, noTidySrcs
 			implicitOutputs = append(implicitOutputs, gcnoFile)
 			coverageFiles = append(coverageFiles, gcnoFile)
 		}
+		if stackUsage {
+			// -fstack-usage writes the stack usage of each function next to the object file.
+			suFile := android.ObjPathWithExt(ctx, subdir, srcFile, "su")
+			implicitOutputs = append(implicitOutputs, suFile)
+			stackUsageFiles = append(stackUsageFiles, suFile)
+		}
+
+		args := map[string]string{
+			"cFlags": shareFlags("cFlags", moduleFlags + extraFlags),
+			"ccCmd":  ccCmd, // short and not shared
+		}
+		if countWarnings {
+			// The warnings of the compile are saved for the warning_baseline check.
+			warningFile := android.ObjPathWithExt(ctx, subdir, srcFile, "warnings")
+			implicitOutputs = append(implicitOutputs, warningFile)
+			warningFiles = append(warningFiles, warningFile)
+			rule = ccWarnings
+			args["cFlags"] += countedWarningsFlags
+			args["warnings"] = warningFile.String()
+		}
+
+		implicits := cFlagsDeps
+		if (rule == cc || rule == ccWarnings) && localActionCache.Valid() {
+			implicits = append(android.Paths{localActionCache.Path()}, cFlagsDeps...)
+		}
 
 		ctx.Build(pctx, android.BuildParams{
 			Rule:            rule,
@@ -660,15 +964,13 @@ func transformSourceToObj(ctx ModuleContext, subdir string, srcFiles, noTidySrcs
 			Output:          objFile,
 			ImplicitOutputs: implicitOutputs,
 			Input:           srcFile,
-			Implicits:       cFlagsDeps,
+			Implicits:       implicits,
 			OrderOnly:       pathDeps,
-			Args: map[string]string{
-				"cFlags": shareFlags("cFlags", moduleFlags + extraFlags),
-				"ccCmd":  ccCmd, // short and not shared
-			},
+			Args:            args,
 		})
 
 		// Register post-process build statements (such as for tidy or kythe).
+
 		if emitXref {
 			kytheFile := android.ObjPathWithExt(ctx, subdir, srcFile, "kzip")
 			ctx.Build(pctx, android.BuildParams{
@@ -693,7 +995,11 @@ func transformSourceToObj(ctx ModuleContext, subdir string, srcFiles, noTidySrcs
 
 			ruleDep := clangTidyDep
 			rule := clangTidy
-			if ctx.Config().UseRBE() && ctx.Config().IsEnvTrue("RBE_CLANG_TIDY") {
+			changedSince := ctx.Config().Getenv("TIDY_CHANGED_SINCE")
+			if changedSince != "" {
+				// The git checkout is only available locally.
+				rule = clangTidyChanged
+			} else if ctx.Config().UseRBE() && ctx.Config().IsEnvTrue("RBE_CLANG_TIDY") {
 				ruleDep = clangTidyDepRE
 				rule = clangTidyRE
 			}
@@ -715,6 +1021,16 @@ func transformSourceToObj(ctx ModuleContext, subdir string, srcFiles, noTidySrcs
 					"tidyFile": tidyFile.String(),
 				},
 			})
+			tidyFlags := config.TidyFlagsForSrcFile(srcFile, flags.tidyFlags)
+			tidyFlags = config.TidyFlagsWithDisabledChecks(tidyFlags, flags.tidyBaselineChecks[srcFile.String()])
+			args := map[string]string{
+				"cFlags":    sharedCFlags,
+				"tidyFlags": shareFlags("tidyFlags", tidyFlags),
+				"tidyVars":  tidyVars, // short and not shared
+			}
+			if changedSince != "" {
+				args["changedSince"] = proptools.ShellEscape(changedSince)
+			}
 			// Add the .tidy rule with order only dependency on the .tidy.d file
 			ctx.Build(pctx, android.BuildParams{
 				Rule:        rule,
@@ -723,11 +1039,7 @@ func transformSourceToObj(ctx ModuleContext, subdir string, srcFiles, noTidySrcs
 				Input:       srcFile,
 				Implicits:   cFlagsDeps,
 				OrderOnly:   append(android.Paths{}, tidyDepFile),
-				Args: map[string]string{
-					"cFlags":    sharedCFlags,
-					"tidyFlags": shareFlags("tidyFlags", config.TidyFlagsForSrcFile(srcFile, flags.tidyFlags)),
-					"tidyVars":  tidyVars, // short and not shared
-				},
+				Args:        args,
 			})
 		}
 
@@ -759,23 +1071,48 @@ func transformSourceToObj(ctx ModuleContext, subdir string, srcFiles, noTidySrcs
 		tidyDepFiles = tidyFiles
 	}
 	return Objects{
-		objFiles:      objFiles,
-		tidyFiles:     tidyFiles,
-		tidyDepFiles:  tidyDepFiles,
-		coverageFiles: coverageFiles,
-		sAbiDumpFiles: sAbiDumpFiles,
-		kytheFiles:    kytheFiles,
+		objFiles:        objFiles,
+		tidyFiles:       tidyFiles,
+		tidyDepFiles:    tidyDepFiles,
+		coverageFiles:   coverageFiles,
+		stackUsageFiles: stackUsageFiles,
+		sAbiDumpFiles:   sAbiDumpFiles,
+		kytheFiles:      kytheFiles,
+		warningFiles:    warningFiles,
 	}
 }
 
+// Generate a rule for checking the counts of the warnings of the sources of a module against its
+// warning_baseline.
+func transformWarningsToBaselineCheck(ctx android.ModuleContext, warningFiles android.Paths,
+	baselines map[string]int, outputFile android.WritablePath) {
+
+	var args []string
+	for _, warning := range android.SortedStringKeys(baselines) {
+		args = append(args, warning+":"+strconv.Itoa(baselines[warning]))
+	}
+
+	ctx.Build(pctx, android.BuildParams{
+		Rule:        checkWarningBaseline,
+		Description: "check warning baseline",
+		Output:      outputFile,
+		Inputs:      warningFiles,
+		Args: map[string]string{
+			"baselines": strings.Join(args, " "),
+		},
+	})
+}
+
 // Generate a rule for compiling multiple .o files to a static library (.a)
 func transformObjToStaticLib(ctx android.ModuleContext,
 	objFiles android.Paths, wholeStaticLibs android.Paths,
 	flags builderFlags, outputFile android.ModuleOutPath, deps android.Paths, validations android.Paths) {
 
-	arCmd := "${config.ClangBin}/llvm-ar"
-	if flags.sdclang {
-		arCmd = "${config.SDClangBin}/llvm-ar"
+	arCmd := flags.clangBin() + "/llvm-ar"
+	arMods := "crsPD"
+	if flags.thinArchive {
+		// Thin archives reference the object files by path instead of copying them.
+		arMods += "T"
 	}
 	arFlags := ""
 	if !ctx.Darwin() {
@@ -791,7 +1128,7 @@ func transformObjToStaticLib(ctx android.ModuleContext,
 			Implicits:   deps,
 			Validations: validations,
 			Args: map[string]string{
-				"arFlags": "crsPD" + arFlags,
+				"arFlags": arMods + arFlags,
 				"arCmd":   arCmd,
 			},
 		})
@@ -805,7 +1142,7 @@ func transformObjToStaticLib(ctx android.ModuleContext,
 			Implicits:   deps,
 			Args: map[string]string{
 				"arCmd":      arCmd,
-				"arObjFlags": "crsPD" + arFlags,
+				"arObjFlags": arMods + arFlags,
 				"arObjs":     strings.Join(objFiles.Strings(), " "),
 				"arLibFlags": "cqsL" + arFlags,
 				"arLibs":     strings.Join(wholeStaticLibs.Strings(), " "),
@@ -821,14 +1158,8 @@ func transformObjToDynamicBinary(ctx android.ModuleContext,
 	groupLate bool, flags builderFlags, outputFile android.WritablePath,
 	implicitOutputs android.WritablePaths, validations android.Paths) {
 
-	var ldCmd string
-	var extraFlags string
-	if flags.sdclang {
-		ldCmd = "${config.SDClangBin}/clang++"
-		extraFlags = " ${config.SDClangFlags}"
-	} else {
-		ldCmd = "${config.ClangBin}/clang++"
-	}
+	ldCmd := flags.clangBin() + "/clang++"
+	extraFlags := flags.toolchainFlavorFlags()
 
 	var libFlagsList []string
 
@@ -879,7 +1210,11 @@ func transformObjToDynamicBinary(ctx android.ModuleContext,
 		"ldFlags":       flags.globalLdFlags + " " + flags.localLdFlags + " " + extraFlags,
 		"crtEnd":        strings.Join(crtEnd.Strings(), " "),
 	}
-	if ctx.Config().UseRBE() && ctx.Config().IsEnvTrue("RBE_CXX_LINKS") {
+	if flags.memoryUsageFile != nil {
+		rule = ldMemoryUsage
+		args["memoryUsage"] = flags.memoryUsageFile.String()
+		implicitOutputs = append(implicitOutputs, flags.memoryUsageFile)
+	} else if ctx.Config().UseRBE() && ctx.Config().IsEnvTrue("RBE_CXX_LINKS") {
 		rule = ldRE
 		args["implicitOutputs"] = strings.Join(implicitOutputs.Strings(), ",")
 		args["implicitInputs"] = strings.Join(deps.Strings(), ",")
@@ -898,6 +1233,264 @@ func transformObjToDynamicBinary(ctx android.ModuleContext,
 	})
 }
 
+// Generate a rule to derive a symbol ordering file from an afdo profile, listing the functions in
+// the profile from the most to the least sampled.
+func transformAfdoProfileToSymbolOrder(ctx android.ModuleContext, profile android.Path) android.Path {
+	outputFile := android.PathForModuleOut(ctx, "afdo_symbol_order.txt")
+	ctx.Build(pctx, android.BuildParams{
+		Rule:        afdoSymbolOrder,
+		Description: "afdo symbol order " + profile.Base(),
+		Output:      outputFile,
+		Input:       profile,
+	})
+	return outputFile
+}
+
+// Generate a rule to combine the .su stack usage files of the source files of a module into a
+// JSON report of the stack usage of each function, sorted from the largest, along with the memory
+// usage printed by the linker if the module was linked.
+func transformStackUsageToReport(ctx android.ModuleContext, suFiles android.Paths,
+	memoryUsage android.OptionalPath) android.Path {
+
+	outputFile := android.PathForModuleOut(ctx, ctx.ModuleName()+".stack_usage.json")
+	var implicits android.Paths
+	var memoryUsageFlags string
+	if memoryUsage.Valid() {
+		implicits = append(implicits, memoryUsage.Path())
+		memoryUsageFlags = "--memory-usage " + memoryUsage.String()
+	}
+	ctx.Build(pctx, android.BuildParams{
+		Rule:        stackUsageReport,
+		Description: "stack usage report " + outputFile.Base(),
+		Output:      outputFile,
+		Inputs:      suFiles,
+		Implicits:   implicits,
+		Args: map[string]string{
+			"memoryUsageFlags": memoryUsageFlags,
+		},
+	})
+	return outputFile
+}
+
+// Generate a rule to check the objects and static libraries linked into a module for structs and
+// classes with mismatched definitions, and return the stamp file written when there are none.
+func transformObjsToOdrCheck(ctx android.ModuleContext, inputs android.Paths) android.Path {
+	outputFile := android.PathForModuleOut(ctx, "odr_check.stamp")
+	ctx.Build(pctx, android.BuildParams{
+		Rule:        checkOdr,
+		Description: "check odr " + ctx.ModuleName(),
+		Output:      outputFile,
+		Inputs:      inputs,
+	})
+	return outputFile
+}
+
+// Generate a rule to report the shared and static libraries that contributed no symbols to a linked
+// binary or shared library according to its link map, and return the report.
+func transformLinkMapToUnusedDepsReport(ctx android.ModuleContext, linkMap android.Path, linked android.Path,
+	sharedLibs, staticLibs android.Paths) android.Path {
+	outputFile := android.PathForModuleOut(ctx, "unused_deps.txt")
+
+	var libs []string
+	for _, lib := range sharedLibs {
+		libs = append(libs, "--shared-lib "+lib.String())
+	}
+	for _, lib := range staticLibs {
+		libs = append(libs, "--static-lib "+lib.String())
+	}
+	errorFlag := ""
+	if ctx.Config().IsEnvTrue("UNUSED_DEPS_CHECK_AS_ERRORS") {
+		errorFlag = "--error"
+	}
+
+	ctx.Build(pctx, android.BuildParams{
+		Rule:        checkUnusedDeps,
+		Description: "check unused deps " + linked.Base(),
+		Output:      outputFile,
+		Inputs:      append(android.Paths{linkMap, linked}, append(sharedLibs, staticLibs...)...),
+		Args: map[string]string{
+			"linkMap":   linkMap.String(),
+			"linked":    linked.String(),
+			"errorFlag": errorFlag,
+			"libs":      strings.Join(libs, " "),
+		},
+	})
+	return outputFile
+}
+
+// Generate a rule to check that a linked binary or shared library doesn't define ifuncs, and return
+// the stamp file written when it doesn't.
+func transformLinkedToIfuncCheck(ctx android.ModuleContext, linked android.Path) android.Path {
+	outputFile := android.PathForModuleOut(ctx, "ifunc_check.stamp")
+	ctx.Build(pctx, android.BuildParams{
+		Rule:        checkIfunc,
+		Description: "check ifuncs " + linked.Base(),
+		Output:      outputFile,
+		Input:       linked,
+	})
+	return outputFile
+}
+
+// Generate a rule to check that a prebuilt binary or shared library has no text relocations, and
+// return the stamp file written when it doesn't.
+func transformLinkedToTextRelocationsCheck(ctx android.ModuleContext, linked android.Path) android.Path {
+	outputFile := android.PathForModuleOut(ctx, "text_relocations_check.stamp")
+	ctx.Build(pctx, android.BuildParams{
+		Rule:        checkTextRelocations,
+		Description: "check text relocations " + linked.Base(),
+		Output:      outputFile,
+		Input:       linked,
+	})
+	return outputFile
+}
+
+// Generate a rule to check that a shared library that links libc++ statically doesn't export
+// libc++ symbols, and return the stamp file written when it doesn't.
+func transformSharedLibToStaticStlExportsCheck(ctx android.ModuleContext, linked android.Path) android.Path {
+	outputFile := android.PathForModuleOut(ctx, "static_stl_exports_check.stamp")
+	ctx.Build(pctx, android.BuildParams{
+		Rule:        checkStaticStlExports,
+		Description: "check static stl exports " + linked.Base(),
+		Output:      outputFile,
+		Input:       linked,
+	})
+	return outputFile
+}
+
+// Generate a rule to check that a prebuilt library linked into a module compiled with branch
+// protection was built with BTI, and return the stamp file written when it was.
+func transformPrebuiltToBtiCheck(ctx android.ModuleContext, prebuilt android.Path, name string) android.Path {
+	outputFile := android.PathForModuleOut(ctx, "bti_check", name+".stamp")
+	ctx.Build(pctx, android.BuildParams{
+		Rule:        checkBti,
+		Description: "check bti " + prebuilt.Base(),
+		Output:      outputFile,
+		Input:       prebuilt,
+		Args: map[string]string{
+			"module": ctx.ModuleName(),
+		},
+	})
+	return outputFile
+}
+
+// Generate a rule to check that the gc_sections_keep symbols of a linked shared library are exported
+// by its version script, and return the stamp file written when they are.
+func transformSharedLibToGcSectionsKeepCheck(ctx android.ModuleContext, linked, versionScript android.Path,
+	symbols []string) android.Path {
+	outputFile := android.PathForModuleOut(ctx, "gc_sections_keep_check.stamp")
+	ctx.Build(pctx, android.BuildParams{
+		Rule:        checkGcSectionsKeep,
+		Description: "check gc_sections_keep " + linked.Base(),
+		Output:      outputFile,
+		Input:       linked,
+		Implicit:    versionScript,
+		Args: map[string]string{
+			"versionScript": versionScript.String(),
+			"symbols":       strings.Join(symbols, " "),
+		},
+	})
+	return outputFile
+}
+
+// Generate a rule to dump the Breakpad symbols of an unstripped binary or shared library, and return
+// the zip that contains them.
+func transformUnstrippedToBreakpadSymbols(ctx android.ModuleContext, unstripped android.Path) android.Path {
+	outputFile := android.PathForModuleOut(ctx, "breakpad", unstripped.Base()+".sym.zip")
+	ctx.Build(pctx, android.BuildParams{
+		Rule:        dumpBreakpadSymbols,
+		Description: "dump breakpad symbols " + unstripped.Base(),
+		Output:      outputFile,
+		Input:       unstripped,
+	})
+	return outputFile
+}
+
+// Generate a rule to add a catch-all local pattern to a version script generated from a symbol
+// file, and return the resulting version script.
+func transformVersionScriptToAuto(ctx android.ModuleContext, versionScript android.Path) android.Path {
+	outputFile := android.PathForModuleOut(ctx, "auto_version_script.map")
+	ctx.Build(pctx, android.BuildParams{
+		Rule:        autoVersionScript,
+		Description: "auto version script " + ctx.ModuleName(),
+		Output:      outputFile,
+		Input:       versionScript,
+	})
+	return outputFile
+}
+
+// Generate a rule to check that the symbols exported by an automatic version script are all in the
+// checked-in list of exported symbols, and return the stamp file written when they are.
+func transformSymbolListToExportedSymbolsCheck(ctx android.ModuleContext, symbolList android.Path,
+	exportedSymbols android.Path) android.Path {
+
+	outputFile := android.PathForModuleOut(ctx, "exported_symbols_check.stamp")
+	ctx.Build(pctx, android.BuildParams{
+		Rule:        checkExportedSymbols,
+		Description: "check exported symbols " + ctx.ModuleName(),
+		Output:      outputFile,
+		Input:       symbolList,
+		Implicit:    exportedSymbols,
+		Args: map[string]string{
+			"exportedSymbols": exportedSymbols.String(),
+		},
+	})
+	return outputFile
+}
+
+// Generate a rule to check that a library with stubs that is built for an APEX exports no symbols
+// that are not in the symbol list of its stubs, and return the stamp file written when it doesn't.
+func transformSharedLibToApexExportedSymbolsCheck(ctx android.ModuleContext, linked android.Path,
+	symbolList android.Path) android.Path {
+
+	outputFile := android.PathForModuleOut(ctx, "apex_exported_symbols_check.stamp")
+	ctx.Build(pctx, android.BuildParams{
+		Rule:        checkApexExportedSymbols,
+		Description: "check apex exported symbols " + linked.Base(),
+		Output:      outputFile,
+		Input:       linked,
+		Implicit:    symbolList,
+		Args: map[string]string{
+			"symbolList": symbolList.String(),
+		},
+	})
+	return outputFile
+}
+
+// Generate a rule to describe the API of a library, that is its exported headers, the symbols in
+// its symbol file and its shared library dependencies, in a JSON file.
+func transformSymbolFileToApiDescription(ctx android.ModuleContext, symbolFile android.Path,
+	includeDirs, headers, deps []string) android.Path {
+
+	outputFile := android.PathForModuleOut(ctx, ctx.ModuleName()+".api.json")
+	apiLevelsJson := android.GetApiLevelsJson(ctx)
+
+	var flags []string
+	for _, dir := range includeDirs {
+		flags = append(flags, "--include-dir "+dir)
+	}
+	for _, header := range headers {
+		flags = append(flags, "--header "+header)
+	}
+	for _, dep := range deps {
+		flags = append(flags, "--dep "+dep)
+	}
+
+	ctx.Build(pctx, android.BuildParams{
+		Rule:        apiDescription,
+		Description: "api description " + outputFile.Base(),
+		Output:      outputFile,
+		Input:       symbolFile,
+		Implicit:    apiLevelsJson,
+		Args: map[string]string{
+			"name":   ctx.ModuleName(),
+			"arch":   ctx.Arch().ArchType.String(),
+			"apiMap": apiLevelsJson.String(),
+			"flags":  strings.Join(flags, " "),
+		},
+	})
+	return outputFile
+}
+
 // Generate a rule to combine .dump sAbi dump files from multiple source files
 // into a single .ldump sAbi dump file
 func transformDumpToLinkedDump(ctx android.ModuleContext, sAbiDumps android.Paths, soFile android.Path,
@@ -1054,14 +1647,8 @@ func TransformSharedObjectToToc(ctx android.ModuleContext, inputFile android.Pat
 func transformObjsToObj(ctx android.ModuleContext, objFiles android.Paths,
 	flags builderFlags, outputFile android.WritablePath, deps android.Paths) {
 
-	var ldCmd string
-	var extraFlags string
-	if flags.sdclang {
-		ldCmd = "${config.SDClangBin}/clang++"
-		extraFlags = " ${config.SDClangFlags}"
-	} else {
-		ldCmd = "${config.ClangBin}/clang++"
-	}
+	ldCmd := flags.clangBin() + "/clang++"
+	extraFlags := flags.toolchainFlavorFlags()
 
 	rule := partialLd
 	args := map[string]string{
@@ -80,6 +80,9 @@ func RegisterCCBuildComponents(ctx android.RegistrationContext) {
 	})
 
 	ctx.RegisterSingletonType("kythe_extract_all", kytheExtractAllFactory)
+	ctx.RegisterSingletonType("lto_bitcode", ltoBitcodeSingletonFactory)
+	ctx.RegisterSingletonType("breakpad_symbols", breakpadSymbolsSingletonFactory)
+	ctx.RegisterSingletonType("bti_audit", btiAuditSingletonFactory)
 }
 
 // Deps is a struct containing module names of dependencies, separated by the kind of dependency.
@@ -206,15 +209,19 @@ type Flags struct {
 	TidyFlags     []string // Flags that apply to clang-tidy
 	SAbiFlags     []string // Flags that apply to header-abi-dumper
 
+	// clang-tidy checks to disable per source file path, from the tidy_baseline file.
+	TidyBaselineChecks map[string][]string
+
 	// Global include flags that apply to C, C++, and assembly source files
 	// These must be after any module include flags, which will be in CommonFlags.
 	SystemIncludeFlags []string
 
-	Toolchain    config.Toolchain
-	Sdclang      bool
+	Toolchain       config.Toolchain
+	ToolchainFlavor *config.ToolchainFlavor // The alternate clang toolchain, or nil for the default one.
 	Tidy         bool // True if clang-tidy is enabled.
 	NeedTidyFiles bool // True if module link should depend on .tidy files
 	GcovCoverage bool // True if coverage files should be generated.
+	StackUsage   bool // True if stack usage files should be generated.
 	SAbiDump     bool // True if header abi dumps should be generated.
 	EmitXrefs    bool // If true, generate Ninja rules to generate emitXrefs input files for Kythe
 
@@ -242,9 +249,20 @@ type BaseProperties struct {
 	// Deprecated. true is the default, false is invalid.
 	Clang *bool `android:"arch_variant"`
 
-	// compile module with SDLLVM instead of AOSP LLVM
+	// compile module with SDLLVM instead of AOSP LLVM. Equivalent to toolchain_flavor: "sdclang"
+	// when true, and to toolchain_flavor: "default" when false. Deprecated, use toolchain_flavor.
 	Sdclang *bool `android:"arch_variant"`
 
+	// The alternate clang toolchain to compile and link device variants of the module with, one of
+	// the registered toolchain flavors, or "default" to use the default clang toolchain even if the
+	// product maps the module to a flavor or a flavor is enabled by default.
+	Toolchain_flavor *string `android:"arch_variant"`
+
+	// Whether the arm64 device variants of the module are compiled with
+	// -mbranch-protection=standard. Defaults to the product's policy for the partition the module
+	// is installed to, set to false to opt out of it.
+	Branch_protection *bool `android:"arch_variant"`
+
 	// The API level that this module is built against. The APIs of this API level will be
 	// visible at build time, but use of any APIs newer than min_sdk_version will render the
 	// module unloadable on older devices.  In the future it will be possible to weakly-link new
@@ -525,6 +543,7 @@ type ModuleContextIntf interface {
 	directlyInAnyApex() bool
 	isPreventInstall() bool
 	isCfiAssemblySupportEnabled() bool
+	isIfuncCheckEnabled() bool
 	getSharedFlags() *SharedFlags
 }
 
@@ -584,6 +603,10 @@ type linker interface {
 
 	nativeCoverage() bool
 	coverageOutputFilePath() android.OptionalPath
+	memoryUsageFilePath() android.OptionalPath
+
+	// Whether the module doesn't use the C runtime of the target.
+	freestanding() bool
 
 	// Get the deps that have been explicitly specified in the properties.
 	linkerSpecifiedDeps(specifiedDeps specifiedDeps) specifiedDeps
@@ -703,6 +726,12 @@ func (d libraryDependencyTag) static() bool {
 	return d.Kind == staticLibraryDependency
 }
 
+// LicenseStaticLink returns true if the code of the dependency is linked into the module, for
+// the license constraint checks.
+func (d libraryDependencyTag) LicenseStaticLink() bool {
+	return d.static()
+}
+
 func (d libraryDependencyTag) LicenseAnnotations() []android.LicenseAnnotation {
 	if d.shared() {
 		return []android.LicenseAnnotation{android.LicenseAnnotationSharedDependency}
@@ -821,6 +850,10 @@ type Module struct {
 	bazelHandler android.BazelHandler
 
 	features []feature
+
+	// hooks that generate sources of the module, added with AddSourceGenerator
+	sourceGenerators []SourceGeneratorHook
+
 	stl      *stl
 	sanitize *sanitize
 	coverage *coverage
@@ -854,6 +887,10 @@ type Module struct {
 	objFiles android.Paths
 	// Tidy .tidy file output paths for this compilation module
 	tidyFiles android.Paths
+	// Object .o file output paths that contain LLVM bitcode, saved when SAVE_LTO_BITCODE is set
+	ltoBitcodeFiles android.Paths
+	// JSON report of the stack usage of the functions in this compilation module
+	stackUsageReport android.OptionalPath
 
 	// For apex variants, this is set as apex.min_sdk_version
 	apexSdkVersion android.ApiLevel
@@ -1174,6 +1211,11 @@ func (c *Module) Init() android.Module {
 	android.InitApexModule(c)
 	android.InitSdkAwareModule(c)
 	android.InitDefaultableModule(c)
+	android.AddDeprecatedProperties(c, android.DeprecatedProperty{
+		Property:    "sdclang",
+		Replacement: "toolchain_flavor",
+		Message:     `set toolchain_flavor: "sdclang" or toolchain_flavor: "default"`,
+	})
 
 	return c
 }
@@ -1409,6 +1451,15 @@ func (c *Module) isCfiAssemblySupportEnabled() bool {
 		Bool(c.sanitize.Properties.Sanitize.Config.Cfi_assembly_support)
 }
 
+// isIfuncCheckEnabled returns true if the module opted in to checking that its linked output
+// doesn't define ifuncs, because it is built with a sanitizer whose runtime isn't initialized when
+// they are resolved.
+func (c *Module) isIfuncCheckEnabled() bool {
+	return c.sanitize != nil &&
+		(c.sanitize.isSanitizerEnabled(Asan) || c.sanitize.isSanitizerEnabled(Hwasan)) &&
+		Bool(c.sanitize.Properties.Sanitize.Config.Ifunc_check)
+}
+
 func (c *Module) InstallInRoot() bool {
 	return c.installer != nil && c.installer.installInRoot()
 }
@@ -1643,6 +1694,10 @@ func (ctx *moduleContextImpl) isCfiAssemblySupportEnabled() bool {
 	return ctx.mod.isCfiAssemblySupportEnabled()
 }
 
+func (ctx *moduleContextImpl) isIfuncCheckEnabled() bool {
+	return ctx.mod.isIfuncCheckEnabled()
+}
+
 func newBaseModule(hod android.HostOrDeviceSupported, multilib android.Multilib) *Module {
 	return &Module{
 		hod:      hod,
@@ -1839,34 +1894,54 @@ func (c *Module) GenerateAndroidBuildActions(actx android.ModuleContext) {
 	flags := Flags{
 		Toolchain: c.toolchain(ctx),
 		EmitXrefs: ctx.Config().EmitXrefRules(),
-		Sdclang:   c.sdclang(ctx),
 	}
+	flags.ToolchainFlavor = c.toolchainFlavor(ctx)
+	if ctx.Failed() {
+		return
+	}
+	provenance := newFlagProvenance(ctx, flags)
 	if c.compiler != nil {
 		flags = c.compiler.compilerFlags(ctx, flags, deps)
+		if c.linker != nil && c.linker.freestanding() {
+			flags.Local.CFlags = append(flags.Local.CFlags, "-ffreestanding")
+		}
+		provenance.record(ctx, "compiler.compilerFlags", flags)
 	}
 	if c.linker != nil {
 		flags = c.linker.linkerFlags(ctx, flags)
+		provenance.record(ctx, "linker.linkerFlags", flags)
 	}
 	if c.stl != nil {
 		flags = c.stl.flags(ctx, flags)
+		provenance.record(ctx, "stl.flags", flags)
 	}
 	if c.sanitize != nil {
 		flags = c.sanitize.flags(ctx, flags)
+		provenance.record(ctx, "sanitize.flags", flags)
 	}
 	if c.coverage != nil {
 		flags, deps = c.coverage.flags(ctx, flags, deps)
+		provenance.record(ctx, "coverage.flags", flags)
 	}
 	if c.lto != nil {
 		flags = c.lto.flags(ctx, flags)
+		provenance.record(ctx, "lto.flags", flags)
 	}
 	if c.afdo != nil {
 		flags = c.afdo.flags(ctx, flags)
+		provenance.record(ctx, "afdo.flags", flags)
 	}
 	if c.pgo != nil {
 		flags = c.pgo.flags(ctx, flags)
+		provenance.record(ctx, "pgo.flags", flags)
 	}
 	for _, feature := range c.features {
 		flags = feature.flags(ctx, flags)
+		provenance.record(ctx, strings.TrimPrefix(fmt.Sprintf("%T", feature), "*cc.")+".flags", flags)
+	}
+	if c.branchProtection(ctx) {
+		flags.Local.CommonFlags = append(flags.Local.CommonFlags, branchProtectionFlag)
+		provenance.record(ctx, "branch protection", flags)
 	}
 	if ctx.Failed() {
 		return
@@ -1875,8 +1950,13 @@ func (c *Module) GenerateAndroidBuildActions(actx android.ModuleContext) {
 	flags.Local.CFlags, _ = filterList(flags.Local.CFlags, config.IllegalFlags)
 	flags.Local.CppFlags, _ = filterList(flags.Local.CppFlags, config.IllegalFlags)
 	flags.Local.ConlyFlags, _ = filterList(flags.Local.ConlyFlags, config.IllegalFlags)
+	provenance.record(ctx, "config.IllegalFlags", flags)
+
+	flags = filterToolchainFlavorUnsupportedFlags(flags)
+	provenance.record(ctx, "toolchain flavor unsupported flags", flags)
 
 	flags.Local.CommonFlags = append(flags.Local.CommonFlags, deps.Flags...)
+	provenance.record(ctx, "exported flags of dependencies", flags)
 
 	for _, dir := range deps.IncludeDirs {
 		flags.Local.CommonFlags = append(flags.Local.CommonFlags, "-I"+dir.String())
@@ -1884,12 +1964,23 @@ func (c *Module) GenerateAndroidBuildActions(actx android.ModuleContext) {
 	for _, dir := range deps.SystemIncludeDirs {
 		flags.Local.CommonFlags = append(flags.Local.CommonFlags, "-isystem "+dir.String())
 	}
+	provenance.record(ctx, "include dirs of dependencies", flags)
 
 	c.flags = flags
+	if c.compiler != nil {
+		ctx.SetProvider(CompilerFlagsInfoProvider, CompilerFlagsInfo{
+			CommonFlags: append(android.CopyOf(flags.Local.CommonFlags), flags.Local.CFlags...),
+			ConlyFlags:  android.CopyOf(flags.Local.ConlyFlags),
+			CppFlags:    android.CopyOf(flags.Local.CppFlags),
+			Deps:        append(android.CopyOfPaths(deps.GeneratedDeps), flags.CFlagsDeps...),
+		})
+	}
 	// We need access to all the flags seen by a source file.
 	if c.sabi != nil {
 		flags = c.sabi.flags(ctx, flags)
+		provenance.record(ctx, "sabi.flags", flags)
 	}
+	provenance.write(ctx)
 
 	flags.AssemblerWithCpp = inList("-xassembler-with-cpp", flags.Local.AsFlags)
 
@@ -1902,6 +1993,9 @@ func (c *Module) GenerateAndroidBuildActions(actx android.ModuleContext) {
 		c.kytheFiles = objs.kytheFiles
 		c.objFiles = objs.objFiles
 		c.tidyFiles = objs.tidyFiles
+		if c.lto.savesBitcode(ctx, flags) {
+			c.ltoBitcodeFiles = objs.objFiles
+		}
 	}
 
 	if c.linker != nil {
@@ -1922,6 +2016,16 @@ func (c *Module) GenerateAndroidBuildActions(actx android.ModuleContext) {
 		}
 	}
 
+	if len(objs.stackUsageFiles) > 0 {
+		var memoryUsage android.OptionalPath
+		if c.linker != nil {
+			memoryUsage = c.linker.memoryUsageFilePath()
+		}
+		report := transformStackUsageToReport(ctx, objs.stackUsageFiles, memoryUsage)
+		c.stackUsageReport = android.OptionalPathForPath(report)
+		ctx.CheckbuildFile(report)
+	}
+
 	c.maybeInstall(ctx, apexInfo)
 }
 
@@ -1978,6 +2082,12 @@ func (c *Module) begin(ctx BaseModuleContext) {
 		c.linker.linkerInit(ctx)
 	}
 	if c.stl != nil {
+		if c.linker != nil && c.linker.freestanding() {
+			if stl := c.stl.Properties.Stl; stl != nil && *stl != "none" {
+				ctx.PropertyErrorf("stl", "must be \"none\" for freestanding modules")
+			}
+			c.stl.Properties.Stl = StringPtr("none")
+		}
 		c.stl.begin(ctx)
 	}
 	if c.sanitize != nil {
@@ -2640,21 +2750,6 @@ func checkDoubleLoadableLibraries(ctx android.TopDownMutatorContext) {
 	}
 }
 
-func (c *Module) sdclang(ctx BaseModuleContext) bool {
-	sdclang := Bool(c.Properties.Sdclang)
-
-	// SDLLVM is not for host build
-	if ctx.Host() || config.ForceSDClangOff {
-		return false
-	}
-
-	if c.Properties.Sdclang == nil && config.SDClang {
-		return true
-	}
-
-	return sdclang
-}
-
 // Convert dependencies to paths.  Returns a PathDeps containing paths
 func (c *Module) depsToPaths(ctx android.ModuleContext) PathDeps {
 	var depPaths PathDeps
@@ -3242,15 +3337,43 @@ func (c *Module) IntermPathForModuleOut() android.OptionalPath {
 func (c *Module) OutputFiles(tag string) (android.Paths, error) {
 	switch tag {
 	case "":
+		// Thin archives only reference object files in the intermediates directory, hand out
+		// the regular archive instead when one is built for dist or packaging.
+		if fullArchive := c.fullArchiveFile(); fullArchive.Valid() {
+			return android.Paths{fullArchive.Path()}, nil
+		}
 		if c.outputFile.Valid() {
 			return android.Paths{c.outputFile.Path()}, nil
 		}
 		return android.Paths{}, nil
+	case ".stack_usage":
+		if c.stackUsageReport.Valid() {
+			return android.Paths{c.stackUsageReport.Path()}, nil
+		}
+		return nil, fmt.Errorf("%q does not have stack_usage_report enabled", c.Name())
+	case ".api_description":
+		if l, ok := c.linker.(interface {
+			apiDescription() android.OptionalPath
+		}); ok && l.apiDescription().Valid() {
+			return android.Paths{l.apiDescription().Path()}, nil
+		}
+		return nil, fmt.Errorf("%q does not have export_api enabled", c.Name())
 	default:
 		return nil, fmt.Errorf("unsupported module reference tag %q", tag)
 	}
 }
 
+// fullArchiveFile returns the regular archive built alongside the output file of a static
+// library emitted as a thin archive, if any.
+func (c *Module) fullArchiveFile() android.OptionalPath {
+	if l, ok := c.linker.(interface {
+		fullArchiveFile() android.OptionalPath
+	}); ok {
+		return l.fullArchiveFile()
+	}
+	return android.OptionalPath{}
+}
+
 func (c *Module) static() bool {
 	if static, ok := c.linker.(interface {
 		static() bool
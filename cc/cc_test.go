@@ -811,6 +811,192 @@ func TestTestBinaryTestSuites(t *testing.T) {
 	}
 }
 
+func TestTestBinaryShards(t *testing.T) {
+	bp := `
+		cc_library_static {
+			name: "libgtest_main",
+		}
+
+		cc_library_static {
+			name: "libgtest",
+		}
+
+		cc_test {
+			name: "filter_test",
+			srcs: ["filter_test.cpp"],
+			test_options: {
+				shard_gtest_filters: ["FooTest.*", "BarTest.*"],
+			},
+		}
+	`
+
+	result := prepareForCcTest.RunTestWithBp(t, bp)
+	ctx := result.TestContext
+
+	checkShard := func(module, shard string, expectedConfig string) {
+		t.Helper()
+		m := ctx.ModuleForTests(module, "android_arm_armv7-a-neon")
+		config := m.Output(shard + ".config")
+		android.AssertStringDoesContain(t, shard+" extra configs", config.Args["extraConfigs"], expectedConfig)
+		entries := android.AndroidMkEntriesForTest(t, ctx, m.Module())[0]
+		android.AssertStringPathsRelativeToTopEquals(t, "LOCAL_EXTRA_FULL_TEST_CONFIGS", result.Config,
+			[]string{
+				"out/soong/.intermediates/" + module + "/android_arm_armv7-a-neon/" + module + "_shard0.config",
+				"out/soong/.intermediates/" + module + "/android_arm_armv7-a-neon/" + module + "_shard1.config",
+			},
+			entries.EntryMap["LOCAL_EXTRA_FULL_TEST_CONFIGS"])
+	}
+
+	checkShard("filter_test", "filter_test_shard0",
+		`<option name="native-test-flag" value="--gtest_filter=FooTest.*" />`)
+	checkShard("filter_test", "filter_test_shard1",
+		`<option name="native-test-flag" value="--gtest_filter=BarTest.*" />`)
+}
+
+func TestTestBinaryShardsErrors(t *testing.T) {
+	testCcError(t, `test_options.shard_gtest_filters: must have at least 2 filters, found 1`, `
+		cc_library_static {
+			name: "libgtest_main",
+		}
+
+		cc_library_static {
+			name: "libgtest",
+		}
+
+		cc_test {
+			name: "main_test",
+			gtest: true,
+			test_options: {
+				shard_gtest_filters: ["FooTest.*"],
+			},
+		}
+	`)
+
+	testCcError(t, `test_options.shard_gtest_filters: is only supported for gtest tests`, `
+		cc_test {
+			name: "main_test",
+			gtest: false,
+			test_options: {
+				shard_gtest_filters: ["FooTest.*", "BarTest.*"],
+			},
+		}
+	`)
+}
+
+func TestBenchmarkOptions(t *testing.T) {
+	bp := `
+		cc_benchmark {
+			name: "json_benchmark",
+			srcs: ["json_benchmark.cpp"],
+			host_supported: true,
+			benchmark_options: {
+				result_format: "json",
+				cpu_affinity: "4-7",
+			},
+		}
+	`
+
+	ctx := testCc(t, bp)
+
+	device := ctx.ModuleForTests("json_benchmark", "android_arm64_armv8-a")
+	runner := android.ContentFromFileRuleForTests(t, device.Output("benchmark_runner/json_benchmark_runner.sh.in"))
+	android.AssertStringDoesContain(t, "device runner", runner,
+		`exec taskset f0 "$dir/json_benchmark" --benchmark_out=/data/local/tmp/benchmark_results/json_benchmark.json --benchmark_out_format=json "$@"`)
+
+	config := device.Output("json_benchmark.config")
+	for _, c := range []string{
+		`<option name="file-exclusion-filter-regex" value=".*/json_benchmark$$" />`,
+		`<option name="directory-keys" value="/data/local/tmp/benchmark_results" />`,
+	} {
+		android.AssertStringDoesContain(t, "extra configs", config.Args["extraConfigs"], c)
+	}
+	android.AssertStringEquals(t, "template", "${NativeBenchmarkTestConfigTemplate}", config.Args["template"])
+	android.AssertStringEquals(t, "name", "json_benchmark", config.Args["name"])
+
+	host := ctx.ModuleForTests("json_benchmark", ctx.Config().BuildOSTarget.String())
+	runner = android.ContentFromFileRuleForTests(t, host.Output("benchmark_runner/json_benchmark_runner.sh.in"))
+	android.AssertStringDoesContain(t, "host runner", runner,
+		`exec taskset f0 "$dir/json_benchmark" --benchmark_format=json "$@"`)
+
+	config = host.Output("json_benchmark.config")
+	android.AssertStringEquals(t, "host template", "${NativeHostBenchmarkTestConfigTemplate}", config.Args["template"])
+	android.AssertStringEquals(t, "host name", "json_benchmark_runner.sh", config.Args["name"])
+}
+
+func TestBenchmarkOptionsErrors(t *testing.T) {
+	testCcError(t, `benchmark_options.result_format: must be "json" or "csv", got "xml"`, `
+		cc_benchmark {
+			name: "xml_benchmark",
+			benchmark_options: {
+				result_format: "xml",
+			},
+		}
+	`)
+
+	testCcError(t, `benchmark_options.cpu_affinity: must be a list of CPU numbers and ranges like "0,4-7", got "big"`, `
+		cc_benchmark {
+			name: "big_benchmark",
+			benchmark_options: {
+				cpu_affinity: "big",
+			},
+		}
+	`)
+
+	testCcError(t, `benchmark_options.cpu_affinity: invalid CPU range "7-4", CPUs must be below 64 and ranges increasing`, `
+		cc_benchmark {
+			name: "reversed_benchmark",
+			benchmark_options: {
+				cpu_affinity: "7-4",
+			},
+		}
+	`)
+}
+
+func TestTestSuiteInfo(t *testing.T) {
+	result := android.GroupFixturePreparers(
+		prepareForCcTest,
+		android.FixtureAddTextFile("data/foo.txt", ""),
+	).RunTestWithBp(t, `
+		cc_test {
+			name: "main_test",
+			srcs: ["main_test.cpp"],
+			test_suites: ["device-tests"],
+			data: ["data/foo.txt"],
+		}
+
+		cc_benchmark {
+			name: "main_benchmark",
+			srcs: ["main_benchmark.cpp"],
+			test_suites: ["device-tests"],
+		}
+
+		cc_test {
+			name: "other_test",
+			srcs: ["other_test.cpp"],
+		}
+	`)
+
+	test := result.ModuleForTests("main_test", "android_arm64_armv8-a")
+	info := result.ModuleProvider(test.Module(), android.TestSuiteInfoProvider).(android.TestSuiteInfo)
+	android.AssertArrayString(t, "test suites", []string{"device-tests"}, info.TestSuites)
+	android.AssertPathRelativeToTopEquals(t, "output file",
+		"out/soong/.intermediates/main_test/android_arm64_armv8-a/main_test", info.OutputFile)
+	android.AssertStringEquals(t, "output file name", "main_test", info.OutputFileName)
+	android.AssertPathRelativeToTopEquals(t, "data", "data/foo.txt", info.Data["data/foo.txt"])
+	android.AssertPathRelativeToTopEquals(t, "test config",
+		"out/soong/.intermediates/main_test/android_arm64_armv8-a/main_test.config", info.TestConfig)
+
+	benchmark := result.ModuleForTests("main_benchmark", "android_arm64_armv8-a")
+	info = result.ModuleProvider(benchmark.Module(), android.TestSuiteInfoProvider).(android.TestSuiteInfo)
+	android.AssertArrayString(t, "benchmark test suites", []string{"device-tests"}, info.TestSuites)
+	android.AssertPathRelativeToTopEquals(t, "benchmark test config",
+		"out/soong/.intermediates/main_benchmark/android_arm64_armv8-a/main_benchmark.config", info.TestConfig)
+
+	other := result.ModuleForTests("other_test", "android_arm64_armv8-a")
+	android.AssertBoolEquals(t, "test without test suites provides TestSuiteInfo", false,
+		result.ModuleHasProvider(other.Module(), android.TestSuiteInfoProvider))
+}
+
 func TestTestLibraryTestSuites(t *testing.T) {
 	bp := `
 		cc_test_library {
@@ -842,6 +1028,63 @@ func TestTestLibraryTestSuites(t *testing.T) {
 	}
 }
 
+func TestTestBinaryPathStaticLibs(t *testing.T) {
+	t.Parallel()
+	result := android.GroupFixturePreparers(
+		prepareForCcTest,
+		android.FixtureAddTextFile("vendor/foo/Android.bp", `
+			cc_test_library {
+				name: "libfoo_test_common",
+				srcs: ["common.cpp"],
+				gtest: false,
+				host_supported: true,
+			}
+			cc_test {
+				name: "foo_test",
+				srcs: ["foo_test.cpp"],
+				gtest: false,
+				host_supported: true,
+			}
+		`),
+		android.FixtureAddTextFile("vendor/foobar/Android.bp", `
+			cc_test {
+				name: "foobar_test",
+				srcs: ["foobar_test.cpp"],
+				gtest: false,
+			}
+		`),
+		android.FixtureModifyProductVariables(func(variables android.FixtureProductVariables) {
+			variables.CcTestPathStaticLibs = []string{"libfoo_test_common:vendor/foo"}
+		}),
+	).RunTestWithBp(t, `
+		cc_test {
+			name: "main_test",
+			srcs: ["main_test.cpp"],
+			gtest: false,
+		}
+	`)
+
+	// Static libraries are implicit inputs of the link rule, passed through libFlags.
+	linksCommon := func(module, variant string) bool {
+		ld := result.ModuleForTests(module, variant).Rule("ld")
+		for _, implicit := range ld.Implicits {
+			if implicit.Base() == "libfoo_test_common.a" {
+				return true
+			}
+		}
+		return false
+	}
+
+	common := "out/soong/.intermediates/vendor/foo/libfoo_test_common/android_arm64_armv8-a_static/libfoo_test_common.a"
+	fooLd := result.ModuleForTests("foo_test", "android_arm64_armv8-a").Rule("ld")
+	android.AssertStringListContains(t, "foo_test implicits", android.PathsRelativeToTop(fooLd.Implicits), common)
+	android.AssertStringDoesContain(t, "foo_test libFlags",
+		android.StringRelativeToTop(result.Config, fooLd.Args["libFlags"]), common)
+	android.AssertBoolEquals(t, "foo_test host links libfoo_test_common", false, linksCommon("foo_test", "linux_glibc_x86_64"))
+	android.AssertBoolEquals(t, "main_test links libfoo_test_common", false, linksCommon("main_test", "android_arm64_armv8-a"))
+	android.AssertBoolEquals(t, "foobar_test links libfoo_test_common", false, linksCommon("foobar_test", "android_arm64_armv8-a"))
+}
+
 func TestVndkWhenVndkVersionIsNotSet(t *testing.T) {
 	ctx := testCcNoVndk(t, `
 		cc_library {
@@ -2816,6 +3059,91 @@ func TestLlndkHeaders(t *testing.T) {
 	}
 }
 
+func TestLlndkGeneratedSymbolFileAndHeaders(t *testing.T) {
+	t.Parallel()
+	result := android.GroupFixturePreparers(
+		prepareForCcTest,
+		android.FixtureAddFile("include/foo.h", nil),
+		android.FixtureAddFile("include/foo/bar.h", nil),
+		android.FixtureAddFile("include/foo_internal.h", nil),
+		android.FixtureAddFile("system_include/foo_system.h", nil),
+		android.FixtureAddFile("system_include/foo_system_internal.h", nil),
+	).RunTestWithBp(t, `
+	cc_library {
+		name: "libllndk-gen",
+		llndk: {
+			symbols: [
+				"foo_init",
+				"foo_new:33",
+			],
+			export_headers: [
+				"include/foo.h",
+				"include/foo/bar.h",
+				"system_include/foo_system.h",
+			],
+		},
+		export_include_dirs: ["include"],
+		export_system_include_dirs: ["system_include"],
+	}
+	`)
+
+	vendor := result.ModuleForTests("libllndk-gen", "android_vendor.29_arm64_armv8-a_shared")
+	android.AssertStringEquals(t, "generated symbol file", `LIBLLNDK_GEN {
+  global:
+    foo_init; # llndk
+    foo_new; # llndk introduced=33
+  local:
+    *;
+};
+`, android.ContentFromFileRuleForTests(t, vendor.Output("llndk.map.txt")))
+
+	stub := vendor.Description("generate stub")
+	android.AssertPathRelativeToTopEquals(t, "stub input",
+		"out/soong/.intermediates/libllndk-gen/android_vendor.29_arm64_armv8-a_shared/gen/llndk.map.txt", stub.Input)
+
+	genDir := "out/soong/.intermediates/libllndk-gen/android_vendor.29_arm64_armv8-a_shared/gen/llndk_include"
+	genSystemDir := "out/soong/.intermediates/libllndk-gen/android_vendor.29_arm64_armv8-a_shared/gen/llndk_system_include"
+	vendor.Output("llndk_include/foo.h")
+	vendor.Output("llndk_include/foo/bar.h")
+	vendor.Output("llndk_system_include/foo_system.h")
+	android.AssertBoolEquals(t, "internal system header is filtered", false,
+		vendor.MaybeOutput("llndk_system_include/foo_system_internal.h").Rule != nil)
+
+	checkExportedIncludeDirs := func(variant string, expectedDirs, expectedSystemDirs []string) {
+		t.Helper()
+		m := result.ModuleForTests("libllndk-gen", variant).Module()
+		f := result.ModuleProvider(m, FlagExporterInfoProvider).(FlagExporterInfo)
+		android.AssertPathsRelativeToTopEquals(t, "exported include dirs for "+variant,
+			expectedDirs, f.IncludeDirs)
+		android.AssertPathsRelativeToTopEquals(t, "exported system include dirs for "+variant,
+			expectedSystemDirs, f.SystemIncludeDirs)
+	}
+	checkExportedIncludeDirs("android_arm64_armv8-a_shared", []string{"include"}, []string{"system_include"})
+	checkExportedIncludeDirs("android_vendor.29_arm64_armv8-a_shared", []string{genDir}, []string{genSystemDir})
+}
+
+func TestLlndkGeneratedSymbolFileErrors(t *testing.T) {
+	t.Parallel()
+	testCcError(t, `llndk.symbols: cannot be set together with llndk.symbol_file`, `
+	cc_library {
+		name: "libllndk",
+		llndk: {
+			symbol_file: "libllndk.map.txt",
+			symbols: ["foo"],
+		},
+	}`)
+
+	testCcError(t, `llndk.export_headers: "other/foo.h" is not in any of the exported include directories`, `
+	cc_library {
+		name: "libllndk",
+		llndk: {
+			symbols: ["foo"],
+			export_headers: ["other/foo.h"],
+		},
+		export_include_dirs: ["include"],
+	}`)
+}
+
 func checkRuntimeLibs(t *testing.T, expected []string, module *Module) {
 	actual := module.Properties.AndroidMkRuntimeLibs
 	if !reflect.DeepEqual(actual, expected) {
@@ -3391,6 +3719,32 @@ func TestFuzzTarget(t *testing.T) {
 	ctx.ModuleForTests("fuzz_smoke_test", variant).Rule("cc")
 }
 
+func TestFuzzTargetSanitizerRuntime(t *testing.T) {
+	ctx := testCc(t, `
+		cc_fuzz {
+			name: "fuzz_runtime_test",
+			srcs: ["foo.c"],
+			shared_libs: ["libfuzzed"],
+		}
+
+		cc_library_shared {
+			name: "libfuzzed",
+			srcs: ["foo.c"],
+		}`)
+
+	variant := "android_arm64_armv8-a_fuzzer"
+	fuzzModule := ctx.ModuleForTests("fuzz_runtime_test", variant).Module().(*Module)
+	android.AssertStringEquals(t, "runtime library", "libclang_rt.ubsan_standalone",
+		fuzzModule.sanitize.Properties.RuntimeLibrary)
+
+	runtime := ctx.ModuleForTests("libclang_rt.ubsan_standalone", "android_arm64_armv8-a_shared").Module().(*Module)
+	installed := fuzzModule.installer.(*fuzzBinary).installedSharedDeps
+	android.AssertStringListContains(t, "installed shared deps", installed,
+		sharedLibraryInstallLocation(runtime.UnstrippedOutputFile(), false, "arm64"))
+	android.AssertStringListContains(t, "installed shared deps", installed,
+		sharedLibrarySymbolsInstallLocation(runtime.UnstrippedOutputFile(), "arm64"))
+}
+
 func TestAidl(t *testing.T) {
 }
 
@@ -4234,3 +4588,661 @@ func TestIncludeDirectoryOrdering(t *testing.T) {
 	}
 
 }
+
+func TestStackUsageReport(t *testing.T) {
+	ctx := testCc(t, `
+		cc_library_shared {
+			name: "libfoo",
+			srcs: ["foo.c", "bar.S"],
+			stack_usage_report: true,
+		}
+		cc_library_shared {
+			name: "libbar",
+			srcs: ["foo.c"],
+		}`)
+
+	libfoo := ctx.ModuleForTests("libfoo", "android_arm64_armv8-a_shared")
+	fooObj := libfoo.Output("obj/foo.o")
+	android.AssertStringDoesContain(t, "cFlags", fooObj.Args["cFlags"], "-fstack-usage")
+	android.AssertPathsRelativeToTopEquals(t, "implicit outputs",
+		[]string{"out/soong/.intermediates/libfoo/android_arm64_armv8-a_shared/obj/foo.su"},
+		fooObj.ImplicitOutputs.Paths())
+
+	memoryUsage := libfoo.Output("memory_usage.txt")
+	android.AssertStringEquals(t, "memory usage rule", ldMemoryUsage.String(), memoryUsage.Rule.String())
+	android.AssertPathRelativeToTopEquals(t, "memory usage link output",
+		"out/soong/.intermediates/libfoo/android_arm64_armv8-a_shared/unstripped/libfoo.so", memoryUsage.Output)
+
+	report := libfoo.Output("libfoo.stack_usage.json")
+	android.AssertPathsRelativeToTopEquals(t, "report inputs",
+		[]string{"out/soong/.intermediates/libfoo/android_arm64_armv8-a_shared/obj/foo.su"},
+		report.Inputs)
+	android.AssertPathsRelativeToTopEquals(t, "report implicits",
+		[]string{"out/soong/.intermediates/libfoo/android_arm64_armv8-a_shared/memory_usage.txt"},
+		report.Implicits)
+	android.AssertStringEquals(t, "report memory usage flags",
+		"--memory-usage out/soong/.intermediates/libfoo/android_arm64_armv8-a_shared/memory_usage.txt",
+		android.StringRelativeToTop(ctx.Config(), report.Args["memoryUsageFlags"]))
+
+	outputs, err := libfoo.Module().(*Module).OutputFiles(".stack_usage")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	android.AssertPathsRelativeToTopEquals(t, "output files",
+		[]string{"out/soong/.intermediates/libfoo/android_arm64_armv8-a_shared/libfoo.stack_usage.json"},
+		outputs)
+
+	libbar := ctx.ModuleForTests("libbar", "android_arm64_armv8-a_shared")
+	android.AssertStringDoesNotContain(t, "cFlags", libbar.Output("obj/foo.o").Args["cFlags"], "-fstack-usage")
+	if libbar.MaybeOutput("memory_usage.txt").Rule != nil {
+		t.Errorf("unexpected memory usage output for libbar")
+	}
+	if libbar.MaybeOutput("libbar.stack_usage.json").Rule != nil {
+		t.Errorf("unexpected stack usage report for libbar")
+	}
+}
+
+func TestOdrCheck(t *testing.T) {
+	ctx := testCc(t, `
+		cc_binary {
+			name: "foo",
+			srcs: ["foo.cpp"],
+			static_libs: ["libbar"],
+			odr_check: true,
+		}
+		cc_library {
+			name: "libbar",
+			srcs: ["bar.cpp"],
+			odr_check: true,
+		}
+		cc_binary {
+			name: "baz",
+			srcs: ["foo.cpp"],
+		}`)
+
+	foo := ctx.ModuleForTests("foo", "android_arm64_armv8-a")
+	check := foo.Output("odr_check.stamp")
+	inputs := android.PathsRelativeToTop(check.Inputs)
+	android.AssertStringListContains(t, "odr check inputs", inputs,
+		"out/soong/.intermediates/foo/android_arm64_armv8-a/obj/foo.o")
+	android.AssertStringListContains(t, "odr check inputs", inputs,
+		"out/soong/.intermediates/libbar/android_arm64_armv8-a_static/libbar.a")
+	android.AssertStringListContains(t, "link validations",
+		android.PathsRelativeToTop(foo.Rule("ld").Validations),
+		"out/soong/.intermediates/foo/android_arm64_armv8-a/odr_check.stamp")
+
+	libbarShared := ctx.ModuleForTests("libbar", "android_arm64_armv8-a_shared")
+	android.AssertStringListContains(t, "link validations",
+		android.PathsRelativeToTop(libbarShared.Rule("ld").Validations),
+		"out/soong/.intermediates/libbar/android_arm64_armv8-a_shared/odr_check.stamp")
+
+	baz := ctx.ModuleForTests("baz", "android_arm64_armv8-a")
+	if baz.MaybeOutput("odr_check.stamp").Rule != nil {
+		t.Errorf("unexpected odr check for baz")
+	}
+}
+
+func TestUnusedDepsCheck(t *testing.T) {
+	bp := `
+		cc_binary {
+			name: "foo",
+			srcs: ["foo.cpp"],
+			shared_libs: ["libqux"],
+			static_libs: ["libbar"],
+			unused_deps_check: true,
+		}
+		cc_library {
+			name: "libbar",
+			srcs: ["bar.cpp"],
+		}
+		cc_library_shared {
+			name: "libqux",
+			srcs: ["qux.cpp"],
+			stem: "libquux",
+			unused_deps_check: true,
+		}
+		cc_binary {
+			name: "baz",
+			srcs: ["foo.cpp"],
+		}`
+
+	ctx := testCc(t, bp)
+
+	foo := ctx.ModuleForTests("foo", "android_arm64_armv8-a")
+	ld := foo.Rule("ld")
+	android.AssertStringDoesContain(t, "ldFlags", ld.Args["ldFlags"],
+		"-Wl,-Map=out/soong/.intermediates/foo/android_arm64_armv8-a/link.map")
+	android.AssertStringListContains(t, "link implicit outputs",
+		android.PathsRelativeToTop(ld.ImplicitOutputs.Paths()),
+		"out/soong/.intermediates/foo/android_arm64_armv8-a/link.map")
+	android.AssertStringListContains(t, "link validations",
+		android.PathsRelativeToTop(ld.Validations),
+		"out/soong/.intermediates/foo/android_arm64_armv8-a/unused_deps.txt")
+
+	check := foo.Output("unused_deps.txt")
+	android.AssertStringEquals(t, "checked libs",
+		"--shared-lib out/soong/.intermediates/libqux/android_arm64_armv8-a_shared/libquux.so "+
+			"--static-lib out/soong/.intermediates/libbar/android_arm64_armv8-a_static/libbar.a",
+		check.Args["libs"])
+	android.AssertStringEquals(t, "error flag", "", check.Args["errorFlag"])
+
+	libqux := ctx.ModuleForTests("libqux", "android_arm64_armv8-a_shared")
+	android.AssertStringListContains(t, "link validations",
+		android.PathsRelativeToTop(libqux.Rule("ld").Validations),
+		"out/soong/.intermediates/libqux/android_arm64_armv8-a_shared/unused_deps.txt")
+
+	baz := ctx.ModuleForTests("baz", "android_arm64_armv8-a")
+	if baz.MaybeOutput("unused_deps.txt").Rule != nil {
+		t.Errorf("unexpected unused deps check for baz")
+	}
+
+	result := android.GroupFixturePreparers(
+		prepareForCcTest,
+		android.FixtureMergeEnv(map[string]string{
+			"UNUSED_DEPS_CHECK_AS_ERRORS": "true",
+		}),
+	).RunTestWithBp(t, bp)
+	check = result.ModuleForTests("foo", "android_arm64_armv8-a").Output("unused_deps.txt")
+	android.AssertStringEquals(t, "error flag", "--error", check.Args["errorFlag"])
+}
+
+func TestLocalActionCache(t *testing.T) {
+	bp := `
+		cc_library_static {
+			name: "libfoo",
+			srcs: ["foo.c", "bar.s"],
+		}`
+
+	result := prepareForCcTest.RunTestWithBp(t, bp)
+	foo := result.ModuleForTests("libfoo", "android_arm64_armv8-a_static")
+	android.AssertStringListDoesNotContain(t, "compile implicits without the local action cache",
+		android.PathsRelativeToTop(foo.Output("obj/foo.o").Implicits), "out/soong/host/linux-x86/bin/action_cache")
+
+	result = android.GroupFixturePreparers(
+		prepareForCcTest,
+		android.FixtureMergeEnv(map[string]string{
+			"USE_LOCAL_ACTION_CACHE": "true",
+		}),
+	).RunTestWithBp(t, bp)
+	foo = result.ModuleForTests("libfoo", "android_arm64_armv8-a_static")
+	android.AssertStringListContains(t, "compile implicits",
+		android.PathsRelativeToTop(foo.Output("obj/foo.o").Implicits), "out/soong/host/linux-x86/bin/action_cache")
+	// Assembly without the preprocessor isn't wrapped with the local action cache.
+	android.AssertStringListDoesNotContain(t, "assembly implicits",
+		android.PathsRelativeToTop(foo.Output("obj/bar.o").Implicits), "out/soong/host/linux-x86/bin/action_cache")
+}
+
+func TestWrapSymbols(t *testing.T) {
+	ctx := testCc(t, `
+		cc_binary {
+			name: "foo",
+			srcs: ["foo.c"],
+			static_libs: ["libwrap"],
+			wrap_symbols: ["malloc", "free"],
+			wrap_symbols_lib: "libwrap",
+		}
+		cc_library_static {
+			name: "libwrap",
+			srcs: ["wrap.c"],
+		}`)
+
+	ldFlags := ctx.ModuleForTests("foo", "android_arm64_armv8-a").Rule("ld").Args["ldFlags"]
+	android.AssertStringDoesContain(t, "ldFlags", ldFlags, "-Wl,--wrap=malloc -Wl,--wrap=free")
+}
+
+func TestWrapSymbolsErrors(t *testing.T) {
+	testCcError(t, `wrap_symbols_lib: "libwrap" must be listed in static_libs, whole_static_libs or shared_libs`, `
+		cc_binary {
+			name: "foo",
+			srcs: ["foo.c"],
+			wrap_symbols: ["malloc"],
+			wrap_symbols_lib: "libwrap",
+		}`)
+
+	testCcError(t, `wrap_symbols: "-Wl,--foo" is not a valid symbol name`, `
+		cc_binary {
+			name: "foo",
+			srcs: ["foo.c"],
+			wrap_symbols: ["-Wl,--foo"],
+		}`)
+}
+
+func TestLinkerSizeControls(t *testing.T) {
+	result := PrepareForIntegrationTestWithCc.RunTestWithBp(t, `
+		cc_library_shared {
+			name: "libfoo",
+			srcs: ["foo.c"],
+			version_script: "foo.map.txt",
+			icf: "all",
+			gc_sections_keep: ["foo_plugin_init"],
+			linker_relaxation: false,
+		}
+		cc_binary {
+			name: "bar",
+			srcs: ["bar.c"],
+			icf: "none",
+			gc_sections: false,
+		}`)
+
+	libfoo := result.ModuleForTests("libfoo", "android_arm64_armv8-a_shared")
+	ldFlags := libfoo.Rule("ld").Args["ldFlags"]
+	android.AssertStringDoesContain(t, "ldFlags", ldFlags, "-Wl,--icf=all")
+	android.AssertStringDoesContain(t, "ldFlags", ldFlags, "-Wl,--undefined=foo_plugin_init")
+	android.AssertStringDoesContain(t, "ldFlags", ldFlags, "-Wl,--no-relax")
+
+	check := libfoo.Output("gc_sections_keep_check.stamp")
+	android.AssertStringEquals(t, "version script", "foo.map.txt", check.Args["versionScript"])
+	android.AssertStringEquals(t, "symbols", "foo_plugin_init", check.Args["symbols"])
+	android.AssertStringListContains(t, "validations", libfoo.Rule("ld").Validations.Strings(), check.Output.String())
+
+	ldFlags = result.ModuleForTests("bar", "android_arm64_armv8-a").Rule("ld").Args["ldFlags"]
+	android.AssertStringDoesContain(t, "ldFlags", ldFlags, "-Wl,--icf=none")
+	android.AssertStringDoesContain(t, "ldFlags", ldFlags, "-Wl,--no-gc-sections")
+}
+
+func TestAllowTextRelocations(t *testing.T) {
+	ctx := testCc(t, `
+		cc_library_shared {
+			name: "libfoo",
+			srcs: ["foo.c"],
+		}
+		cc_library_shared {
+			name: "libtextrel",
+			srcs: ["textrel.S"],
+			allow_text_relocations: true,
+		}`)
+
+	// lld rejects text relocations by default, linked modules are not checked after the link.
+	libfoo := ctx.ModuleForTests("libfoo", "android_arm64_armv8-a_shared")
+	if libfoo.MaybeOutput("text_relocations_check.stamp").Rule != nil {
+		t.Errorf("unexpected text relocations check for a linked module")
+	}
+	android.AssertStringDoesNotContain(t, "ldFlags", libfoo.Rule("ld").Args["ldFlags"], "-Wl,-z,notext")
+
+	libtextrel := ctx.ModuleForTests("libtextrel", "android_arm64_armv8-a_shared")
+	android.AssertStringDoesContain(t, "ldFlags", libtextrel.Rule("ld").Args["ldFlags"], "-Wl,-z,notext")
+
+	testCcError(t, `Bad flag: .-Wl,-z,notext., use allow_text_relocations instead`, `
+		cc_library_shared {
+			name: "libfoo",
+			srcs: ["foo.c"],
+			ldflags: ["-Wl,-z,notext"],
+		}`)
+}
+
+func TestLinkerSizeControlsErrors(t *testing.T) {
+	testCcError(t, `icf: must be "safe", "all" or "none", got "some"`, `
+		cc_binary {
+			name: "foo",
+			srcs: ["foo.c"],
+			icf: "some",
+		}`)
+
+	testCcError(t, `gc_sections_keep: can only be set when gc_sections is enabled`, `
+		cc_binary {
+			name: "foo",
+			srcs: ["foo.c"],
+			gc_sections: false,
+			gc_sections_keep: ["foo_init"],
+		}`)
+
+	testCcError(t, `gc_sections_keep: "-Wl,--foo" is not a valid symbol name`, `
+		cc_binary {
+			name: "foo",
+			srcs: ["foo.c"],
+			gc_sections_keep: ["-Wl,--foo"],
+		}`)
+}
+
+func TestFreestanding(t *testing.T) {
+	ctx := testCc(t, `
+		cc_binary {
+			name: "bootloader",
+			srcs: ["main.c"],
+			static_executable: true,
+			freestanding: true,
+			crt_begin: ["bootloader_crt0"],
+		}
+		cc_object {
+			name: "bootloader_crt0",
+			srcs: ["crt0.S"],
+			stl: "none",
+			system_shared_libs: [],
+		}`)
+
+	bootloader := ctx.ModuleForTests("bootloader", "android_arm64_armv8-a")
+	android.AssertStringDoesContain(t, "cFlags", bootloader.Rule("cc").Args["cFlags"], "-ffreestanding")
+
+	ld := bootloader.Rule("ld")
+	android.AssertStringDoesNotContain(t, "ldFlags", ld.Args["ldFlags"], "-ffreestanding")
+	android.AssertStringEquals(t, "crtBegin",
+		"out/soong/.intermediates/bootloader_crt0/android_arm64_armv8-a/bootloader_crt0.o",
+		android.StringRelativeToTop(ctx.Config(), ld.Args["crtBegin"]))
+	android.AssertStringEquals(t, "crtEnd", "", ld.Args["crtEnd"])
+	for _, lib := range []string{"libc.a", "libc++", "libclang_rt.builtins"} {
+		android.AssertStringDoesNotContain(t, "libFlags", ld.Args["libFlags"], lib)
+	}
+}
+
+func TestFreestandingErrors(t *testing.T) {
+	testCcError(t, `freestanding: freestanding binaries must be static_executable`, `
+		cc_binary {
+			name: "bootloader",
+			srcs: ["main.c"],
+			freestanding: true,
+		}`)
+
+	testCcError(t, `stl: must be "none" for freestanding modules`, `
+		cc_binary {
+			name: "bootloader",
+			srcs: ["main.c"],
+			static_executable: true,
+			freestanding: true,
+			stl: "libc++_static",
+		}`)
+
+	testCcError(t, `freestanding: must be set to use crt_begin or crt_end`, `
+		cc_binary {
+			name: "bootloader",
+			srcs: ["main.c"],
+			crt_begin: ["bootloader_crt0"],
+		}
+		cc_object {
+			name: "bootloader_crt0",
+			srcs: ["crt0.S"],
+		}`)
+}
+
+var prepareForTestWithBaremetal = android.FixtureModifyConfig(func(config android.Config) {
+	config.Targets[android.Baremetal] = []android.Target{
+		{android.Baremetal, android.Arch{ArchType: android.Arm64, ArchVariant: "armv8-a"}, android.NativeBridgeDisabled, "", "", false},
+	}
+})
+
+func TestBaremetal(t *testing.T) {
+	bp := `
+		cc_binary {
+			name: "firmware",
+			srcs: ["main.c"],
+			static_libs: ["libfirmware"],
+			target: {
+				baremetal: {
+					enabled: true,
+				},
+			},
+		}
+		cc_binary {
+			name: "firmware_llvmlibc",
+			srcs: ["main.c"],
+			target: {
+				baremetal: {
+					enabled: true,
+					baremetal_libc: "llvm-libc",
+				},
+			},
+		}
+		cc_library_static {
+			name: "libfirmware",
+			srcs: ["lib.c"],
+			target: {
+				baremetal: {
+					enabled: true,
+				},
+			},
+		}
+		cc_library_static {
+			name: "libpicolibc",
+			srcs: ["picolibc.c"],
+			target: {
+				baremetal: {
+					enabled: true,
+				},
+			},
+		}
+		cc_library_static {
+			name: "libllvmlibc",
+			srcs: ["llvmlibc.c"],
+			target: {
+				baremetal: {
+					enabled: true,
+				},
+			},
+		}`
+	ctx := android.GroupFixturePreparers(prepareForCcTest, prepareForTestWithBaremetal).
+		RunTestWithBp(t, bp).TestContext
+
+	const variant = "baremetal_arm64_armv8-a"
+	firmware := ctx.ModuleForTests("firmware", variant)
+	cc := firmware.Rule("cc")
+	android.AssertStringDoesContain(t, "cFlags", cc.Args["cFlags"], "-ffreestanding")
+	android.AssertStringDoesContain(t, "cFlags", cc.Args["cFlags"], "aarch64-none-elf")
+
+	ld := firmware.Rule("ld")
+	android.AssertStringEquals(t, "crtBegin", "", ld.Args["crtBegin"])
+	android.AssertStringDoesContain(t, "libFlags", ld.Args["libFlags"], "libfirmware.a")
+	android.AssertStringDoesContain(t, "libFlags", ld.Args["libFlags"], "libpicolibc.a")
+	for _, lib := range []string{"libc.a", "libc++", "libclang_rt.builtins"} {
+		android.AssertStringDoesNotContain(t, "libFlags", ld.Args["libFlags"], lib)
+	}
+	android.AssertBoolEquals(t, "static_executable", true,
+		firmware.Module().(*Module).linker.(*binaryDecorator).static())
+
+	llvmLibcLd := ctx.ModuleForTests("firmware_llvmlibc", variant).Rule("ld")
+	android.AssertStringDoesContain(t, "libFlags", llvmLibcLd.Args["libFlags"], "libllvmlibc.a")
+	android.AssertStringDoesNotContain(t, "libFlags", llvmLibcLd.Args["libFlags"], "libpicolibc.a")
+
+	// Baremetal variants are disabled unless enabled explicitly.
+	android.AssertBoolEquals(t, "libc baremetal variant enabled", false,
+		ctx.ModuleForTests("libc", variant+"_shared").Module().Enabled())
+}
+
+func TestBaremetalErrors(t *testing.T) {
+	android.GroupFixturePreparers(prepareForCcTest, prepareForTestWithBaremetal).
+		ExtendWithErrorHandler(android.FixtureExpectsAtLeastOneErrorMatchingPattern(
+			`baremetal_libc: unknown libc "newlib"`)).
+		RunTestWithBp(t, `
+			cc_binary {
+				name: "firmware",
+				srcs: ["main.c"],
+				target: {
+					baremetal: {
+						enabled: true,
+						baremetal_libc: "newlib",
+					},
+				},
+			}`)
+
+	testCcError(t, `baremetal_libc: only supported for baremetal targets`, `
+		cc_binary {
+			name: "firmware",
+			srcs: ["main.c"],
+			baremetal_libc: "picolibc",
+		}`)
+}
+
+func TestInstallNameTemplate(t *testing.T) {
+	ctx := testCc(t, `
+		cc_library_shared {
+			name: "libfoo",
+			srcs: ["foo.c"],
+			install_name_template: "$(stem)$(sanitizers)_$(arch)$(suffix)",
+		}
+		cc_binary {
+			name: "bar",
+			srcs: ["foo.c"],
+			install_name_template: "$(stem)-test",
+		}`)
+
+	installPath := func(module string, variant string) android.InstallPath {
+		switch installer := ctx.ModuleForTests(module, variant).Module().(*Module).installer.(type) {
+		case *libraryDecorator:
+			return installer.baseInstaller.path
+		case *binaryDecorator:
+			return installer.baseInstaller.path
+		}
+		t.Fatalf("unexpected installer for %s", module)
+		return android.InstallPath{}
+	}
+
+	android.AssertPathRelativeToTopEquals(t, "arm64 install path",
+		"out/target/product/test_device/system/lib64/libfoo_arm64.so",
+		installPath("libfoo", "android_arm64_armv8-a_shared"))
+	android.AssertPathRelativeToTopEquals(t, "arm install path",
+		"out/target/product/test_device/system/lib/libfoo_arm.so",
+		installPath("libfoo", "android_arm_armv7-a-neon_shared"))
+	android.AssertPathRelativeToTopEquals(t, "binary install path",
+		"out/target/product/test_device/system/bin/bar-test",
+		installPath("bar", "android_arm64_armv8-a"))
+}
+
+func TestInstallNameTemplateErrors(t *testing.T) {
+	testCcError(t, `install_name_template: unknown variable \$\(variant\)`, `
+		cc_library_shared {
+			name: "libfoo",
+			srcs: ["foo.c"],
+			install_name_template: "$(stem)_$(variant)$(suffix)",
+		}`)
+
+	testCcError(t, `install_name_template: "arm64/libfoo.so" is not a valid file name`, `
+		cc_library_shared {
+			name: "libfoo",
+			srcs: ["foo.c"],
+			install_name_template: "$(arch)/$(stem)$(suffix)",
+		}`)
+}
+
+func TestToolchainFlavor(t *testing.T) {
+	t.Parallel()
+	result := android.GroupFixturePreparers(
+		prepareForCcTest,
+		android.FixtureAddFile("vendor/foo/foo.c", nil),
+		android.FixtureAddTextFile("vendor/foo/Android.bp", `
+			cc_library_shared {
+				name: "libvendor_mapped",
+				srcs: ["foo.c"],
+			}
+			cc_library_shared {
+				name: "libvendor_opt_out",
+				srcs: ["foo.c"],
+				toolchain_flavor: "default",
+			}
+		`),
+		android.FixtureAddFile("vendor/foobar/foo.c", nil),
+		android.FixtureAddTextFile("vendor/foobar/Android.bp", `
+			cc_library_shared {
+				name: "libvendor_sibling",
+				srcs: ["foo.c"],
+			}
+		`),
+		android.FixtureModifyProductVariables(func(variables android.FixtureProductVariables) {
+			variables.ToolchainFlavorPaths = []string{"sdclang:vendor/foo"}
+			variables.ToolchainFlavorModules = []string{"sdclang:libmodule_mapped"}
+		}),
+	).RunTestWithBp(t, `
+		cc_library_shared {
+			name: "libflavor",
+			srcs: ["foo.c"],
+			toolchain_flavor: "sdclang",
+			host_supported: true,
+		}
+		cc_library_shared {
+			name: "liblegacy",
+			srcs: ["foo.c"],
+			sdclang: true,
+		}
+		cc_library_shared {
+			name: "libmodule_mapped",
+			srcs: ["foo.c"],
+		}
+		cc_library_shared {
+			name: "libdefault",
+			srcs: ["foo.c"],
+		}
+	`)
+
+	checkClangBin := func(module, variant, expected string) {
+		t.Helper()
+		m := result.ModuleForTests(module, variant)
+		android.AssertStringEquals(t, module+" ccCmd", expected+"/clang", m.Rule("cc").Args["ccCmd"])
+		android.AssertStringEquals(t, module+" ldCmd", expected+"/clang++", m.Rule("ld").Args["ldCmd"])
+	}
+
+	device := "android_arm64_armv8-a_shared"
+	checkClangBin("libflavor", device, "${config.SDClangBin}")
+	checkClangBin("libflavor", "linux_glibc_x86_64_shared", "${config.ClangBin}")
+	checkClangBin("liblegacy", device, "${config.SDClangBin}")
+	checkClangBin("libmodule_mapped", device, "${config.SDClangBin}")
+	checkClangBin("libvendor_mapped", device, "${config.SDClangBin}")
+	checkClangBin("libvendor_opt_out", device, "${config.ClangBin}")
+	checkClangBin("libvendor_sibling", device, "${config.ClangBin}")
+	checkClangBin("libdefault", device, "${config.ClangBin}")
+}
+
+func TestToolchainFlavorErrors(t *testing.T) {
+	t.Parallel()
+	testCcError(t, `toolchain_flavor: unknown toolchain flavor "gcc"`, `
+		cc_library_shared {
+			name: "libfoo",
+			srcs: ["foo.c"],
+			toolchain_flavor: "gcc",
+		}`)
+
+	testCcError(t, `sdclang: conflicts with toolchain_flavor: "default"`, `
+		cc_library_shared {
+			name: "libfoo",
+			srcs: ["foo.c"],
+			sdclang: true,
+			toolchain_flavor: "default",
+		}`)
+}
+
+func TestBuildIdAndFilePrefixMap(t *testing.T) {
+	t.Parallel()
+	result := android.GroupFixturePreparers(
+		prepareForCcTest,
+		android.FixtureModifyProductVariables(func(variables android.FixtureProductVariables) {
+			variables.DefaultBuildIdStyle = StringPtr("sha1")
+			variables.DefaultFilePrefixMap = BoolPtr(true)
+		}),
+	).RunTestWithBp(t, `
+		cc_library_shared {
+			name: "libdefault",
+			srcs: ["foo.c"],
+			host_supported: true,
+		}
+		cc_library_shared {
+			name: "liboverride",
+			srcs: ["foo.c"],
+			build_id: "none",
+			file_prefix_map: false,
+		}
+	`)
+
+	ldFlags := func(module, variant string) string {
+		return result.ModuleForTests(module, variant).Rule("ld").Args["ldFlags"]
+	}
+	cFlags := func(module, variant string) string {
+		return result.ModuleForTests(module, variant).Rule("cc").Args["cFlags"]
+	}
+
+	device := "android_arm64_armv8-a_shared"
+	host := "linux_glibc_x86_64_shared"
+
+	android.AssertStringDoesContain(t, "libdefault device ldflags", ldFlags("libdefault", device), "-Wl,--build-id=sha1")
+	android.AssertStringDoesNotContain(t, "libdefault host ldflags", ldFlags("libdefault", host), "-Wl,--build-id=sha1")
+	android.AssertStringDoesContain(t, "liboverride ldflags", ldFlags("liboverride", device), "-Wl,--build-id=none")
+
+	android.AssertStringDoesContain(t, "libdefault device cflags", cFlags("libdefault", device), "-ffile-prefix-map=/proc/self/cwd=")
+	android.AssertStringDoesNotContain(t, "libdefault host cflags", cFlags("libdefault", host), "-ffile-prefix-map=")
+	android.AssertStringDoesNotContain(t, "liboverride cflags", cFlags("liboverride", device), "-ffile-prefix-map=")
+}
+
+func TestBuildIdErrors(t *testing.T) {
+	t.Parallel()
+	testCcError(t, `build_id: must be one of md5, sha1, fast, uuid, none, got "sha256"`, `
+		cc_library_shared {
+			name: "libfoo",
+			srcs: ["foo.c"],
+			build_id: "sha256",
+		}`)
+}
@@ -89,6 +89,8 @@ func CheckBadLinkerFlags(ctx BaseModuleContext, prop string, flags []string) {
 			ctx.PropertyErrorf(prop, "Bad flag: `%s`, use version_script instead", flag)
 		} else if flag == "--coverage" {
 			ctx.PropertyErrorf(prop, "Bad flag: `%s`, use native_coverage instead", flag)
+		} else if flag == "-Wl,-z,notext" || flag == "-z notext" {
+			ctx.PropertyErrorf(prop, "Bad flag: `%s`, use allow_text_relocations instead", flag)
 		} else if strings.Contains(flag, " ") {
 			args := strings.Split(flag, " ")
 			if args[0] == "-z" {
@@ -116,6 +116,21 @@ type BaseCompilerProperties struct {
 	// if set to false, use -std=c++* instead of -std=gnu++*
 	Gnu_extensions *bool
 
+	// list of additional C++ standard versions, like "c++17" and "c++20", to compile the C++
+	// sources of the module with to validate that they, and the headers they include, are
+	// compatible with all of them.  The objects aren't linked, but a failure to compile them
+	// fails the build of the module.
+	Std_matrix []string
+
+	// File listing the baseline counts of warnings of the module, to clean up warnings
+	// incrementally in large legacy modules.  Each line is in the form "<warning>: <count>",
+	// where the warning is the name of an individual clang warning flag without -W, e.g.
+	// "unused-parameter: 120", not of a group like "unused".  A warning in a header is counted
+	// once, however many sources include it.  The warnings are counted in the C and C++ sources of the module,
+	// even if the cflags disable them, and the build fails if a count exceeds its baseline.  Empty
+	// lines and lines starting with '#' are ignored.
+	Warning_baseline *string `android:"path"`
+
 	Yacc *YaccProperties
 	Lex  *LexProperties
 
@@ -229,6 +244,18 @@ type BaseCompilerProperties struct {
 
 	// Build and link with OpenMP
 	Openmp *bool `android:"arch_variant"`
+
+	// Compile with -fstack-usage and combine the stack usage of each function into a JSON
+	// report, which can be referenced with the ".stack_usage" output tag of this module.  Linked
+	// modules also include the usage of the memory regions of the linker script printed by
+	// --print-memory-usage in the report.
+	Stack_usage_report *bool `android:"arch_variant"`
+
+	// Compile with -ffile-prefix-map to remove the working directory from the paths recorded in
+	// the outputs, including the __FILE__ macros and the coverage data and not just the debug
+	// info, so that the outputs don't depend on where the tree is checked out. Defaults to the
+	// product's DefaultFilePrefixMap on device targets.
+	File_prefix_map *bool `android:"arch_variant"`
 }
 
 func NewBaseCompiler() *baseCompiler {
@@ -250,6 +277,9 @@ type baseCompiler struct {
 	// C/C++ (.aidl, .proto, etc.)
 	srcsBeforeGen android.Paths
 
+	// Headers generated by the source generator hooks of the module
+	sourceGeneratorHeaders android.Paths
+
 	generatedSourceInfo
 }
 
@@ -363,6 +393,13 @@ func (compiler *baseCompiler) compilerFlags(ctx ModuleContext, flags Flags, deps
 	compiler.srcsBeforeGen = android.PathsForModuleSrcExcludes(ctx, compiler.Properties.Srcs, compiler.Properties.Exclude_srcs)
 	compiler.srcsBeforeGen = append(compiler.srcsBeforeGen, deps.GeneratedSources...)
 
+	generated := runSourceGenerators(ctx)
+	compiler.srcsBeforeGen = append(compiler.srcsBeforeGen, generated.Srcs...)
+	compiler.sourceGeneratorHeaders = generated.Headers
+	if len(generated.IncludeDirs) > 0 {
+		flags.Local.CommonFlags = append(flags.Local.CommonFlags, includeDirsToFlags(generated.IncludeDirs))
+	}
+
 	CheckBadCompilerFlags(ctx, "cflags", compiler.Properties.Cflags)
 	CheckBadCompilerFlags(ctx, "cppflags", compiler.Properties.Cppflags)
 	CheckBadCompilerFlags(ctx, "conlyflags", compiler.Properties.Conlyflags)
@@ -480,7 +517,8 @@ func (compiler *baseCompiler) compilerFlags(ctx ModuleContext, flags Flags, deps
 	flags.Local.LdFlags = config.ClangFilterUnknownCflags(flags.Local.LdFlags)
 
 	target := "-target " + tc.ClangTriple()
-	if ctx.Os().Class == android.Device {
+	// Baremetal triples have no API level.
+	if ctx.Os().Class == android.Device && ctx.Os() != android.Baremetal {
 		version := ctx.minSdkVersion()
 		if version == "" || version == "current" {
 			target += strconv.Itoa(android.FutureApiLevelInt)
@@ -637,6 +675,16 @@ func (compiler *baseCompiler) compilerFlags(ctx ModuleContext, flags Flags, deps
 		flags.Local.CFlags = append(flags.Local.CFlags, "-fopenmp")
 	}
 
+	if Bool(compiler.Properties.Stack_usage_report) {
+		flags.Local.CFlags = append(flags.Local.CFlags, "-fstack-usage")
+		flags.StackUsage = true
+	}
+
+	if BoolDefault(compiler.Properties.File_prefix_map, ctx.Device() && ctx.Config().DefaultFilePrefixMap()) {
+		// Compiles run in /proc/self/cwd, see PwdPrefix.
+		flags.Local.CommonFlags = append(flags.Local.CommonFlags, "-ffile-prefix-map=/proc/self/cwd=")
+	}
+
 	// Exclude directories from manual binder interface allowed list.
 	//TODO(b/145621474): Move this check into IInterface.h when clang-tidy no longer uses absolute paths.
 	if android.HasAnyPrefix(ctx.ModuleDir(), allowedManualInterfacePaths) {
@@ -695,6 +743,7 @@ func (compiler *baseCompiler) compile(ctx ModuleContext, flags Flags, deps PathD
 
 	srcs, genDeps, info := genSources(ctx, srcs, buildFlags)
 	pathDeps = append(pathDeps, genDeps...)
+	pathDeps = append(pathDeps, compiler.sourceGeneratorHeaders...)
 
 	compiler.pathDeps = pathDeps
 	compiler.generatedSourceInfo = info
@@ -703,12 +752,26 @@ func (compiler *baseCompiler) compile(ctx ModuleContext, flags Flags, deps PathD
 	// Save src, buildFlags and context
 	compiler.srcs = srcs
 
+	var warningBaselines map[string]int
+	if compiler.Properties.Warning_baseline != nil {
+		warningBaselines = parseWarningBaseline(ctx, android.PathForModuleSrc(ctx, *compiler.Properties.Warning_baseline))
+		buildFlags.countedWarnings = android.SortedStringKeys(warningBaselines)
+	}
+
 	// Compile files listed in c.Properties.Srcs into objects
 	objs := compileObjs(ctx, buildFlags, "", srcs,
 		android.PathsForModuleSrc(ctx, compiler.Properties.Tidy_disabled_srcs),
 		android.PathsForModuleSrc(ctx, compiler.Properties.Tidy_timeout_srcs),
 		pathDeps, compiler.cFlagsDeps)
 
+	objs.stdMatrixFiles = compiler.compileStdMatrix(ctx, flags, srcs, pathDeps)
+
+	if len(warningBaselines) > 0 && len(objs.warningFiles) > 0 {
+		stamp := android.PathForModuleOut(ctx, "warning_baseline.stamp")
+		transformWarningsToBaselineCheck(ctx, objs.warningFiles, warningBaselines, stamp)
+		objs.warningBaselineFiles = android.Paths{stamp}
+	}
+
 	if ctx.Failed() {
 		return Objects{}
 	}
@@ -716,6 +779,113 @@ func (compiler *baseCompiler) compile(ctx ModuleContext, flags Flags, deps PathD
 	return objs
 }
 
+// compileStdMatrix compiles the C++ sources of the module with each of the C++ standard versions in
+// std_matrix, without tidy, coverage or ABI dumps, and returns the objects to be used as
+// validations of the module.
+func (compiler *baseCompiler) compileStdMatrix(ctx ModuleContext, flags Flags, srcs, pathDeps android.Paths) android.Paths {
+	if len(compiler.Properties.Std_matrix) == 0 {
+		return nil
+	}
+
+	var cppSrcs android.Paths
+	for _, src := range srcs {
+		switch src.Ext() {
+		case ".cpp", ".cc", ".cxx", ".mm":
+			cppSrcs = append(cppSrcs, src)
+		}
+	}
+	if len(cppSrcs) == 0 {
+		ctx.PropertyErrorf("std_matrix", "module has no C++ sources")
+		return nil
+	}
+
+	var objFiles android.Paths
+	for _, std := range android.FirstUniqueStrings(compiler.Properties.Std_matrix) {
+		if !strings.HasPrefix(std, "c++") && !strings.HasPrefix(std, "gnu++") {
+			ctx.PropertyErrorf("std_matrix", "%q is not a C++ standard version", std)
+			continue
+		}
+
+		stdFlags := flags
+		stdFlags.Local.CppFlags = append([]string{"-std=" + std}, android.FilterListPred(flags.Local.CppFlags,
+			func(flag string) bool { return !strings.HasPrefix(flag, "-std=") })...)
+
+		buildFlags := flagsToBuilderFlags(stdFlags)
+		buildFlags.tidy = false
+		buildFlags.needTidyFiles = false
+		buildFlags.gcovCoverage = false
+		buildFlags.stackUsage = false
+		buildFlags.sAbiDump = false
+		buildFlags.emitXrefs = false
+
+		objs := compileObjs(ctx, buildFlags, filepath.Join("std_matrix", std), cppSrcs, nil, nil,
+			pathDeps, compiler.cFlagsDeps)
+		objFiles = append(objFiles, objs.objFiles...)
+	}
+	return objFiles
+}
+
+var warningNameRegexp = regexp.MustCompile(`^[a-z0-9][a-z0-9+-]*$`)
+
+// warningGroups are the common clang warning flags that enable groups of other warnings.  Clang
+// reports each warning with the flag of the individual warning, so the warnings of a group can't
+// be counted by the name of the group.
+var warningGroups = []string{
+	"all",
+	"comments",
+	"conversion",
+	"deprecated",
+	"everything",
+	"extra",
+	"format",
+	"most",
+	"pedantic",
+	"shadow-all",
+	"thread-safety",
+	"uninitialized",
+	"unused",
+}
+
+// parseWarningBaseline returns the baseline counts of the warnings listed in the warning_baseline
+// file, keyed by the name of the warning flag without -W.
+func parseWarningBaseline(ctx ModuleContext, baseline android.Path) map[string]int {
+	data, err := ctx.Config().ReadSourceFile(ctx, baseline.String())
+	if err != nil {
+		ctx.PropertyErrorf("warning_baseline", "failed to read %s: %s", baseline, err)
+		return nil
+	}
+
+	counts := make(map[string]int)
+	for i, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.SplitN(line, ":", 2)
+		warning := strings.TrimPrefix(strings.TrimSpace(fields[0]), "-W")
+		var count int
+		if len(fields) == 2 {
+			count, err = strconv.Atoi(strings.TrimSpace(fields[1]))
+		}
+		if len(fields) != 2 || err != nil || count < 0 || !warningNameRegexp.MatchString(warning) {
+			ctx.PropertyErrorf("warning_baseline", "%s:%d: expected \"<warning>: <count>\", got %q",
+				baseline, i+1, line)
+			continue
+		}
+		if android.InList(warning, warningGroups) {
+			ctx.PropertyErrorf("warning_baseline", "%s:%d: -W%s is a group of warnings, list the individual warnings instead",
+				baseline, i+1, warning)
+			continue
+		}
+		if _, exists := counts[warning]; exists {
+			ctx.PropertyErrorf("warning_baseline", "%s:%d: duplicate baseline of %q", baseline, i+1, warning)
+			continue
+		}
+		counts[warning] = count
+	}
+	return counts
+}
+
 // Compile a list of source files into objects a specified subdirectory
 func compileObjs(ctx ModuleContext, flags builderFlags, subdir string,
 	srcFiles, noTidySrcs, timeoutTidySrcs, pathDeps android.Paths, cFlagsDeps android.Paths) Objects {
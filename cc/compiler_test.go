@@ -15,6 +15,7 @@
 package cc
 
 import (
+	"strings"
 	"testing"
 
 	"android/soong/android"
@@ -44,3 +45,118 @@ func TestIsThirdParty(t *testing.T) {
 		}
 	}
 }
+
+func TestStdMatrix(t *testing.T) {
+	result := prepareForCcTest.RunTestWithBp(t, `
+		cc_library {
+			name: "libfoo",
+			srcs: ["foo.cpp", "bar.c"],
+			std_matrix: ["c++17", "c++20"],
+			tidy: true,
+		}
+	`)
+
+	for _, variant := range []string{"android_arm64_armv8-a_shared", "android_arm64_armv8-a_static"} {
+		libfoo := result.ModuleForTests("libfoo", variant)
+		for _, std := range []string{"c++17", "c++20"} {
+			obj := libfoo.Output("obj/std_matrix/" + std + "/foo.o")
+			cFlags := obj.Args["cFlags"]
+			android.AssertStringDoesContain(t, variant+" "+std+" cflags", cFlags, "-std="+std)
+			android.AssertStringDoesNotContain(t, variant+" "+std+" cflags", cFlags, "-std=gnu++")
+			android.AssertBoolEquals(t, variant+" "+std+" tidy", false, libfoo.MaybeOutput("obj/std_matrix/"+std+"/foo.tidy").Rule != nil)
+		}
+		android.AssertBoolEquals(t, variant+" C sources", false, libfoo.MaybeOutput("obj/std_matrix/c++17/bar.o").Rule != nil)
+	}
+
+	ld := result.ModuleForTests("libfoo", "android_arm64_armv8-a_shared").Rule("ld")
+	android.AssertArrayString(t, "ld validations of std_matrix objects",
+		[]string{
+			"out/soong/.intermediates/libfoo/android_arm64_armv8-a_shared/obj/std_matrix/c++17/foo.o",
+			"out/soong/.intermediates/libfoo/android_arm64_armv8-a_shared/obj/std_matrix/c++20/foo.o",
+		},
+		android.FilterListPred(android.PathsRelativeToTop(ld.Validations), func(s string) bool {
+			return strings.Contains(s, "std_matrix")
+		}))
+}
+
+func TestStdMatrixErrors(t *testing.T) {
+	prepareForCcTest.ExtendWithErrorHandler(android.FixtureExpectsAllErrorsToMatchAPattern([]string{
+		`module "libc_only" .*: std_matrix: module has no C\+\+ sources`,
+		`module "libbad_std" .*: std_matrix: "gnu11" is not a C\+\+ standard version`,
+	})).RunTestWithBp(t, `
+		cc_library_shared {
+			name: "libc_only",
+			srcs: ["bar.c"],
+			std_matrix: ["c++20"],
+		}
+
+		cc_library_shared {
+			name: "libbad_std",
+			srcs: ["foo.cpp"],
+			std_matrix: ["gnu11"],
+		}
+	`)
+}
+
+func TestWarningBaseline(t *testing.T) {
+	result := android.GroupFixturePreparers(
+		prepareForCcTest,
+		android.FixtureAddTextFile("warning_baseline.txt", "# Existing warnings\n"+
+			"unused-parameter: 12\n"+
+			"\n"+
+			"-Wsign-compare: 3\n"),
+	).RunTestWithBp(t, `
+		cc_library_shared {
+			name: "libfoo",
+			srcs: ["foo.cpp", "bar.c", "baz.S"],
+			cflags: ["-Wno-unused-parameter"],
+			warning_baseline: "warning_baseline.txt",
+		}
+	`)
+
+	libfoo := result.ModuleForTests("libfoo", "android_arm64_armv8-a_shared")
+	for _, src := range []string{"foo", "bar"} {
+		// The warnings are saved by the compile of the object.
+		obj := libfoo.Output("obj/" + src + ".o")
+		android.AssertStringEquals(t, src+" rule", ccWarnings.String(), obj.Rule.String())
+		android.AssertStringListContains(t, src+" implicit outputs", android.PathsRelativeToTop(obj.ImplicitOutputs.Paths()),
+			"out/soong/.intermediates/libfoo/android_arm64_armv8-a_shared/obj/"+src+".warnings")
+		android.AssertStringDoesContain(t, src+" cflags", obj.Args["cFlags"],
+			"-Wsign-compare -Wno-error=sign-compare -Wunused-parameter -Wno-error=unused-parameter")
+		android.AssertStringDoesNotContain(t, src+" cflags", obj.Args["cFlags"], "-Wno-error ")
+	}
+	android.AssertBoolEquals(t, "assembly warnings", false, libfoo.MaybeOutput("obj/baz.warnings").Rule != nil)
+
+	check := libfoo.Output("warning_baseline.stamp")
+	android.AssertStringEquals(t, "baselines", "sign-compare:3 unused-parameter:12", check.Args["baselines"])
+	android.AssertPathsRelativeToTopEquals(t, "checked warnings", []string{
+		"out/soong/.intermediates/libfoo/android_arm64_armv8-a_shared/obj/foo.warnings",
+		"out/soong/.intermediates/libfoo/android_arm64_armv8-a_shared/obj/bar.warnings",
+	}, check.Inputs)
+
+	ld := libfoo.Rule("ld")
+	android.AssertStringListContains(t, "ld validations", android.PathsRelativeToTop(ld.Validations),
+		"out/soong/.intermediates/libfoo/android_arm64_armv8-a_shared/warning_baseline.stamp")
+}
+
+func TestWarningBaselineErrors(t *testing.T) {
+	android.GroupFixturePreparers(
+		prepareForCcTest,
+		android.FixtureAddTextFile("warning_baseline.txt", "unused-parameter\n"+
+			"sign-compare: many\n"+
+			"shadow: 1\n"+
+			"shadow: 2\n"+
+			"unused: 5\n"),
+	).ExtendWithErrorHandler(android.FixtureExpectsAllErrorsToMatchAPattern([]string{
+		`warning_baseline: warning_baseline.txt:1: expected "<warning>: <count>", got "unused-parameter"`,
+		`warning_baseline: warning_baseline.txt:2: expected "<warning>: <count>", got "sign-compare: many"`,
+		`warning_baseline: warning_baseline.txt:4: duplicate baseline of "shadow"`,
+		`warning_baseline: warning_baseline.txt:5: -Wunused is a group of warnings, list the individual warnings instead`,
+	})).RunTestWithBp(t, `
+		cc_library_shared {
+			name: "libfoo",
+			srcs: ["foo.cpp"],
+			warning_baseline: "warning_baseline.txt",
+		}
+	`)
+}
@@ -0,0 +1,147 @@
+// Copyright 2022 The Android Open Source Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"android/soong/android"
+)
+
+// The baremetal toolchains reuse the arch and cpu variant handling of the Android toolchains, but
+// target an ELF triple without an operating system and don't use bionic.
+
+var (
+	baremetalCflags = []string{
+		"-ffreestanding",
+	}
+
+	baremetalLdflags = []string{
+		"-nostdlib",
+		"-static",
+	}
+
+	// BaremetalLibcs maps the values of the baremetal_libc property to the modules providing
+	// the libc runtime.
+	BaremetalLibcs = map[string]string{
+		"picolibc":  "libpicolibc",
+		"llvm-libc": "libllvmlibc",
+	}
+
+	// BaremetalDefaultLibc is the libc runtime used when baremetal_libc isn't set.
+	BaremetalDefaultLibc = "picolibc"
+)
+
+func init() {
+	exportedVars.ExportStringListStaticVariable("BaremetalCflags", baremetalCflags)
+	exportedVars.ExportStringListStaticVariable("BaremetalLdflags", baremetalLdflags)
+
+	registerToolchainFactory(android.Baremetal, android.Arm64, baremetalArm64ToolchainFactory)
+	registerToolchainFactory(android.Baremetal, android.Arm, baremetalArmToolchainFactory)
+}
+
+type toolchainBaremetal struct {
+}
+
+func (toolchainBaremetal) Bionic() bool { return false }
+
+func (toolchainBaremetal) DefaultSharedLibraries() []string { return nil }
+
+func (toolchainBaremetal) AvailableLibraries() []string { return nil }
+
+func (toolchainBaremetal) CrtBeginStaticBinary() []string  { return nil }
+func (toolchainBaremetal) CrtBeginSharedBinary() []string  { return nil }
+func (toolchainBaremetal) CrtBeginSharedLibrary() []string { return nil }
+func (toolchainBaremetal) CrtEndStaticBinary() []string    { return nil }
+func (toolchainBaremetal) CrtEndSharedBinary() []string    { return nil }
+func (toolchainBaremetal) CrtEndSharedLibrary() []string   { return nil }
+
+func (toolchainBaremetal) Cflags() string {
+	return "${config.BaremetalCflags}"
+}
+
+type toolchainBaremetalArm64 struct {
+	toolchainBaremetal
+	*toolchainArm64
+}
+
+func (t *toolchainBaremetalArm64) Name() string {
+	return "arm64_baremetal"
+}
+
+func (t *toolchainBaremetalArm64) GccTriple() string {
+	return "aarch64-none-elf"
+}
+
+func (t *toolchainBaremetalArm64) ClangTriple() string {
+	return t.GccTriple()
+}
+
+func (t *toolchainBaremetalArm64) Cflags() string {
+	return t.toolchainArm64.Cflags() + " " + t.toolchainBaremetal.Cflags()
+}
+
+func (t *toolchainBaremetalArm64) Ldflags() string {
+	return t.toolchainArm64.Ldflags() + " ${config.BaremetalLdflags}"
+}
+
+func (t *toolchainBaremetalArm64) Lldflags() string {
+	return t.toolchainArm64.Lldflags() + " ${config.BaremetalLdflags}"
+}
+
+func baremetalArm64ToolchainFactory(arch android.Arch) Toolchain {
+	if arch.ArchVariant == "" {
+		arch.ArchVariant = "armv8-a"
+	}
+	return &toolchainBaremetalArm64{
+		toolchainArm64: arm64ToolchainFactory(arch).(*toolchainArm64),
+	}
+}
+
+type toolchainBaremetalArm struct {
+	toolchainBaremetal
+	*toolchainArm
+}
+
+func (t *toolchainBaremetalArm) Name() string {
+	return "arm_baremetal"
+}
+
+func (t *toolchainBaremetalArm) GccTriple() string {
+	return "arm-none-eabi"
+}
+
+func (t *toolchainBaremetalArm) ClangTriple() string {
+	return "armv7a-none-eabi"
+}
+
+func (t *toolchainBaremetalArm) Cflags() string {
+	return t.toolchainArm.Cflags() + " " + t.toolchainBaremetal.Cflags()
+}
+
+func (t *toolchainBaremetalArm) Ldflags() string {
+	return t.toolchainArm.Ldflags() + " ${config.BaremetalLdflags}"
+}
+
+func (t *toolchainBaremetalArm) Lldflags() string {
+	return t.toolchainArm.Lldflags() + " ${config.BaremetalLdflags}"
+}
+
+func baremetalArmToolchainFactory(arch android.Arch) Toolchain {
+	if arch.ArchVariant == "" {
+		arch.ArchVariant = "armv7-a"
+	}
+	return &toolchainBaremetalArm{
+		toolchainArm: armToolchainFactory(arch).(*toolchainArm),
+	}
+}
@@ -512,6 +512,29 @@ func init() {
 		return ""
 	})
 
+	// When USE_LOCAL_ACTION_CACHE=true is set, compiles are wrapped with action_cache, which
+	// restores the outputs of compiles whose command line and inputs were seen before, e.g. when
+	// switching back and forth between SANITIZE_TARGET values.  LOCAL_ACTION_CACHE_MAX_SIZE
+	// overrides the size in bytes above which the least recently used results are evicted.
+	pctx.VariableFunc("LocalActionCache", func(ctx android.PackageVarContext) string {
+		tool := LocalActionCacheTool(ctx)
+		if !tool.Valid() {
+			return ""
+		}
+		cacheDir := ctx.Config().Getenv("LOCAL_ACTION_CACHE_DIR")
+		if cacheDir == "" {
+			cacheDir = android.PathForOutput(ctx, "action_cache").String()
+		}
+		cmd := tool.String() + " --cache_dir " + cacheDir
+		if maxSize := ctx.Config().Getenv("LOCAL_ACTION_CACHE_MAX_SIZE"); maxSize != "" {
+			cmd += " --max_size " + maxSize
+		}
+		// Only the output directory is normalized in the cache keys, so that products built in
+		// different output directories share their results, but variants never do.
+		cmd += " --out_dir " + ctx.Config().OutDir()
+		return cmd + " -- "
+	})
+
 	pctx.StaticVariableWithEnvOverride("RECXXPool", "RBE_CXX_POOL", remoteexec.DefaultPool)
 	pctx.StaticVariableWithEnvOverride("RECXXLinksPool", "RBE_CXX_LINKS_POOL", remoteexec.DefaultPool)
 	pctx.StaticVariableWithEnvOverride("REClangTidyPool", "RBE_CLANG_TIDY_POOL", remoteexec.DefaultPool)
@@ -521,6 +544,15 @@ func init() {
 	pctx.StaticVariableWithEnvOverride("REAbiLinkerExecStrategy", "RBE_ABI_LINKER_EXEC_STRATEGY", remoteexec.LocalExecStrategy)
 }
 
+// LocalActionCacheTool returns the action_cache tool that compiles are wrapped with when
+// USE_LOCAL_ACTION_CACHE=true is set, for the compiles to depend on.
+func LocalActionCacheTool(ctx android.PathContext) android.OptionalPath {
+	if !ctx.Config().IsEnvTrue("USE_LOCAL_ACTION_CACHE") {
+		return android.OptionalPath{}
+	}
+	return android.OptionalPathForPath(ctx.Config().HostToolPath(ctx, "action_cache"))
+}
+
 func setSdclangVars() {
 	sdclangPath := ""
 	sdclangAEFlag := ""
@@ -16,6 +16,7 @@ package config
 
 import (
 	"android/soong/android"
+	"regexp"
 	"strings"
 )
 
@@ -145,6 +146,20 @@ func TidyChecksForDir(dir string) string {
 	return tidyDefault
 }
 
+var tidyChecksFlagRegexp = regexp.MustCompile(`-checks=[^ ]*`)
+
+// TidyFlagsWithDisabledChecks returns the clang-tidy flags with the given checks disabled in
+// the -checks= flag.
+func TidyFlagsWithDisabledChecks(flags string, checks []string) string {
+	if len(checks) == 0 {
+		return flags
+	}
+	disabled := ",-" + strings.Join(checks, ",-")
+	return tidyChecksFlagRegexp.ReplaceAllStringFunc(flags, func(s string) string {
+		return s + disabled
+	})
+}
+
 func TidyFlagsForSrcFile(srcFile android.Path, flags string) string {
 	// Disable clang-analyzer-* checks globally for generated source files
 	// because some of them are too huge. Local .bp files can add wanted
@@ -39,3 +39,27 @@ func TestTidyChecksForDir(t *testing.T) {
 		})
 	}
 }
+
+func TestTidyFlagsWithDisabledChecks(t *testing.T) {
+	testCases := []struct {
+		name     string
+		flags    string
+		checks   []string
+		expected string
+	}{
+		{"no checks", "-quiet -checks=-*,cert-*", nil, "-quiet -checks=-*,cert-*"},
+		{"one check", "-quiet -checks=-*,cert-* -header-filter=foo/", []string{"cert-err58-cpp"},
+			"-quiet -checks=-*,cert-*,-cert-err58-cpp -header-filter=foo/"},
+		{"two checks", "-checks=-*,cert-*", []string{"cert-err58-cpp", "cert-env33-c"},
+			"-checks=-*,cert-*,-cert-err58-cpp,-cert-env33-c"},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			output := TidyFlagsWithDisabledChecks(testCase.flags, testCase.checks)
+			if output != testCase.expected {
+				t.Error("Output doesn't match expected", output, testCase.expected)
+			}
+		})
+	}
+}
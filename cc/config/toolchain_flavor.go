@@ -0,0 +1,91 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"fmt"
+	"sort"
+)
+
+// A ToolchainFlavor is an alternate clang toolchain that device modules can be compiled and
+// linked with instead of the default one, selected with the toolchain_flavor property or mapped
+// to paths and modules by the product.
+type ToolchainFlavor struct {
+	// Name of the flavor, as used in the toolchain_flavor property and the product variables.
+	Name string
+
+	// Directory of the clang binaries of the flavor, usually a ninja variable.
+	ClangBin string
+
+	// Flags passed to every compile and link with the flavor, usually a ninja variable.
+	Flags string
+
+	// Flags the flavor doesn't support, removed from the flags of the modules built with it.
+	UnsupportedFlags []string
+
+	// Returns true if device modules that don't select a flavor are built with this one.
+	EnabledByDefault func() bool
+
+	// Returns true if the flavor is turned off for the whole build, in which case the modules
+	// that select it are built with the default toolchain.
+	ForcedOff func() bool
+}
+
+// DefaultToolchainFlavor is the name of the default clang toolchain, which modules can select to
+// opt out of the flavor mapped to them or enabled by default.
+const DefaultToolchainFlavor = "default"
+
+var toolchainFlavors = make(map[string]*ToolchainFlavor)
+
+// RegisterToolchainFlavor registers an alternate clang toolchain. It must be called from init.
+func RegisterToolchainFlavor(flavor *ToolchainFlavor) {
+	if flavor.Name == "" || flavor.Name == DefaultToolchainFlavor {
+		panic(fmt.Errorf("invalid toolchain flavor name %q", flavor.Name))
+	}
+	if _, exists := toolchainFlavors[flavor.Name]; exists {
+		panic(fmt.Errorf("toolchain flavor %q is already registered", flavor.Name))
+	}
+	toolchainFlavors[flavor.Name] = flavor
+}
+
+// FindToolchainFlavor returns the registered toolchain flavor with the given name.
+func FindToolchainFlavor(name string) (*ToolchainFlavor, bool) {
+	flavor, ok := toolchainFlavors[name]
+	return flavor, ok
+}
+
+// ToolchainFlavorNames returns the sorted names of the registered toolchain flavors.
+func ToolchainFlavorNames() []string {
+	var names []string
+	for name := range toolchainFlavors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// SDClangToolchainFlavor is the name of the Snapdragon LLVM toolchain flavor, configured by
+// SDCLANG_CONFIG.
+const SDClangToolchainFlavor = "sdclang"
+
+func init() {
+	RegisterToolchainFlavor(&ToolchainFlavor{
+		Name:             SDClangToolchainFlavor,
+		ClangBin:         "${config.SDClangBin}",
+		Flags:            "${config.SDClangFlags}",
+		EnabledByDefault: func() bool { return SDClang },
+		ForcedOff:        func() bool { return ForceSDClangOff },
+	})
+}
@@ -25,7 +25,7 @@ import (
 const profileInstrFlag = "-fprofile-instr-generate=/data/misc/trace/clang-%p-%m.profraw"
 
 type CoverageProperties struct {
-	Native_coverage *bool
+	Native_coverage *bool `android:"arch_variant"`
 
 	NeedCoverageVariant bool `blueprint:"mutated"`
 	NeedCoverageBuild   bool `blueprint:"mutated"`
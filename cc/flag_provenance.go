@@ -0,0 +1,204 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cc
+
+import (
+	"fmt"
+	"strings"
+
+	"android/soong/android"
+)
+
+// This file implements a debugging aid that explains where the flags of a module come from.
+// Setting SOONG_EXPLAIN_FLAGS to a comma separated list of module names writes, for every
+// variant of those modules, a flag_provenance.txt file next to the other intermediates of the
+// variant, e.g. out/soong/.intermediates/<dir>/<module>/<variant>/flag_provenance.txt. Each line
+// names a flag that was added to (+) or removed from (-) a flag list, the step of
+// GenerateAndroidBuildActions that did it and, when known, why:
+//
+//   + Local.CFlags -fsanitize=cfi from sanitize.flags via SANITIZE_TARGET=cfi
+//   + Local.CFlags -fsanitize=bounds from sanitize.flags via sanitize property
+
+const explainFlagsEnvVar = "SOONG_EXPLAIN_FLAGS"
+
+// flagProvenance records the changes each step makes to the flags of a module. A nil
+// *flagProvenance records nothing, so that the steps don't need to check whether the module
+// is being explained.
+type flagProvenance struct {
+	lines []string
+	// Copies of the flag lists after the previous step, as steps may modify the lists in place.
+	prevLists [][]string
+}
+
+// newFlagProvenance returns a flagProvenance starting from the given flags if the module is
+// listed in SOONG_EXPLAIN_FLAGS, or nil otherwise.
+func newFlagProvenance(ctx ModuleContext, flags Flags) *flagProvenance {
+	modules := strings.Split(ctx.Config().Getenv(explainFlagsEnvVar), ",")
+	if !android.InList(ctx.ModuleName(), modules) {
+		return nil
+	}
+	p := &flagProvenance{}
+	p.setPrev(flags)
+	return p
+}
+
+// flagLists returns the flag lists of flags that are recorded, with their names.
+func flagLists(flags Flags) ([]string, [][]string) {
+	names := []string{}
+	lists := [][]string{}
+	for _, f := range []struct {
+		name  string
+		flags LocalOrGlobalFlags
+	}{{"Global", flags.Global}, {"Local", flags.Local}} {
+		names = append(names,
+			f.name+".CommonFlags", f.name+".AsFlags", f.name+".YasmFlags", f.name+".CFlags",
+			f.name+".ToolingCFlags", f.name+".ConlyFlags", f.name+".CppFlags",
+			f.name+".ToolingCppFlags", f.name+".LdFlags")
+		lists = append(lists,
+			f.flags.CommonFlags, f.flags.AsFlags, f.flags.YasmFlags, f.flags.CFlags,
+			f.flags.ToolingCFlags, f.flags.ConlyFlags, f.flags.CppFlags,
+			f.flags.ToolingCppFlags, f.flags.LdFlags)
+	}
+	names = append(names, "TidyFlags", "SAbiFlags")
+	lists = append(lists, flags.TidyFlags, flags.SAbiFlags)
+	return names, lists
+}
+
+// subtractFlags returns the flags in a that are not in b, counting duplicates.
+func subtractFlags(a, b []string) []string {
+	count := make(map[string]int)
+	for _, flag := range b {
+		count[flag]++
+	}
+	var ret []string
+	for _, flag := range a {
+		if count[flag] > 0 {
+			count[flag]--
+		} else {
+			ret = append(ret, flag)
+		}
+	}
+	return ret
+}
+
+// record attributes the changes to flags since the previous step to the given step.
+func (p *flagProvenance) record(ctx ModuleContext, step string, flags Flags) {
+	if p == nil {
+		return
+	}
+	names, lists := flagLists(flags)
+	for i, name := range names {
+		for _, flag := range subtractFlags(lists[i], p.prevLists[i]) {
+			line := fmt.Sprintf("+ %s %s from %s", name, flag, step)
+			if reason := flagReason(ctx, step, flag); reason != "" {
+				line += " via " + reason
+			}
+			p.lines = append(p.lines, line)
+		}
+		for _, flag := range subtractFlags(p.prevLists[i], lists[i]) {
+			p.lines = append(p.lines, fmt.Sprintf("- %s %s by %s", name, flag, step))
+		}
+	}
+	p.setPrev(flags)
+}
+
+func (p *flagProvenance) setPrev(flags Flags) {
+	_, lists := flagLists(flags)
+	p.prevLists = make([][]string, len(lists))
+	for i, list := range lists {
+		p.prevLists[i] = android.CopyOf(list)
+	}
+}
+
+// flagReason returns why the given step added the flag, or an empty string if it isn't known.
+func flagReason(ctx ModuleContext, step string, flag string) string {
+	c := ctx.Module().(*Module)
+	switch step {
+	case "compiler.compilerFlags":
+		for _, props := range c.compiler.compilerProps() {
+			if props, ok := props.(*BaseCompilerProperties); ok {
+				switch {
+				case inList(flag, props.Cflags):
+					return "cflags property"
+				case inList(flag, props.Cppflags):
+					return "cppflags property"
+				case inList(flag, props.Conlyflags):
+					return "conlyflags property"
+				case inList(flag, props.Asflags):
+					return "asflags property"
+				}
+			}
+		}
+	case "linker.linkerFlags":
+		for _, props := range c.linker.linkerProps() {
+			if props, ok := props.(*BaseLinkerProperties); ok && inList(flag, props.Ldflags) {
+				return "ldflags property"
+			}
+		}
+	case "sanitize.flags":
+		if c.sanitize != nil {
+			return sanitizerFlagReason(c.sanitize.Properties.SanitizerSources, flag)
+		}
+	}
+	return ""
+}
+
+// sanitizerFlagMarkers maps substrings of the sanitizer flags to the sanitizer that adds them, as
+// recorded in SanitizerSources.  The more specific markers come first, e.g. kcfi before cfi.
+var sanitizerFlagMarkers = []struct {
+	marker    string
+	sanitizer string
+}{
+	{"hwaddress", "hwaddress"},
+	{"address", "address"},
+	{"kcfi", "kcfi"},
+	{"cfi", "cfi"},
+	{"integer-overflow", "integer_overflow"},
+	{"integer_overflow", "integer_overflow"},
+	{"memtag", "memtag_heap"},
+	{"thread", "thread"},
+	{"fuzzer", "fuzzer"},
+	{"safe-stack", "safe-stack"},
+	{"scudo", "scudo"},
+}
+
+// sanitizerFlagReason returns why sanitize.flags added the flag, given the sources of the
+// sanitizers that were not enabled by the sanitize property. Flags that don't name one of the
+// sanitizers are attributed to the undefined behavior sanitizers.
+func sanitizerFlagReason(sources []string, flag string) string {
+	sanitizer := "undefined"
+	for _, m := range sanitizerFlagMarkers {
+		if strings.Contains(flag, m.marker) {
+			sanitizer = m.sanitizer
+			break
+		}
+	}
+	for _, source := range sources {
+		if strings.HasPrefix(source, sanitizer+":") {
+			return strings.TrimPrefix(source, sanitizer+":")
+		}
+	}
+	return "sanitize property"
+}
+
+// write writes the recorded changes to flag_provenance.txt.
+func (p *flagProvenance) write(ctx ModuleContext) {
+	if p == nil {
+		return
+	}
+	out := android.PathForModuleOut(ctx, "flag_provenance.txt")
+	android.WriteFileRule(ctx, out, strings.Join(p.lines, "\n"))
+	ctx.CheckbuildFile(out)
+}
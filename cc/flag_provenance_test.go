@@ -0,0 +1,80 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cc
+
+import (
+	"strings"
+	"testing"
+
+	"android/soong/android"
+)
+
+func TestFlagProvenance(t *testing.T) {
+	t.Parallel()
+	bp := `
+		cc_library_shared {
+			name: "libfoo",
+			srcs: ["foo.c"],
+			cflags: ["-DFOO"],
+			ldflags: ["-Wl,--foo"],
+			sanitize: {
+				misc_undefined: ["bounds"],
+			},
+		}
+
+		cc_library_shared {
+			name: "libbar",
+			srcs: ["bar.c"],
+		}
+	`
+	result := android.GroupFixturePreparers(
+		prepareForCcTest,
+		android.FixtureMergeEnv(map[string]string{
+			"SOONG_EXPLAIN_FLAGS": "libfoo,libbaz",
+		}),
+		android.FixtureAddTextFile("overflow/Android.bp", `
+			cc_library_shared {
+				name: "libbaz",
+				srcs: ["baz.c"],
+			}
+		`),
+		android.FixtureModifyProductVariables(func(variables android.FixtureProductVariables) {
+			variables.IntegerOverflowIncludePaths = []string{"overflow"}
+		}),
+	).RunTestWithBp(t, bp)
+
+	libfoo := result.ModuleForTests("libfoo", "android_arm64_armv8-a_shared")
+	content := android.ContentFromFileRuleForTests(t, libfoo.Output("flag_provenance.txt"))
+	lines := strings.Split(content, "\n")
+
+	android.AssertStringListContains(t, "cflags", lines,
+		"+ Local.CFlags -DFOO from compiler.compilerFlags via cflags property")
+	android.AssertStringListContains(t, "ldflags", lines,
+		"+ Local.LdFlags -Wl,--foo from linker.linkerFlags via ldflags property")
+	android.AssertStringListContains(t, "sanitizer", lines,
+		"+ Local.CFlags -fsanitize=bounds from sanitize.flags via sanitize property")
+
+	// The sanitizers enabled by the product are attributed to the product config.
+	libbaz := result.ModuleForTests("libbaz", "android_arm64_armv8-a_shared")
+	lines = strings.Split(android.ContentFromFileRuleForTests(t, libbaz.Output("flag_provenance.txt")), "\n")
+	android.AssertStringListContains(t, "product sanitizer", lines,
+		"+ Local.CFlags -fsanitize-ignorelist=build/soong/cc/config/integer_overflow_blocklist.txt "+
+			"from sanitize.flags via product integer_overflow include paths")
+
+	libbar := result.ModuleForTests("libbar", "android_arm64_armv8-a_shared")
+	if libbar.MaybeOutput("flag_provenance.txt").Rule != nil {
+		t.Errorf("libbar is not listed in SOONG_EXPLAIN_FLAGS, but has a flag_provenance.txt")
+	}
+}
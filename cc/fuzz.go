@@ -148,6 +148,44 @@ func IsValidSharedDependency(dependency android.Module) bool {
 	return true
 }
 
+// isSanitizerRuntimeDependency returns true if the dependency is the sanitizer runtime shared
+// library linked by the parent module, as recorded by sanitizerRuntimeMutator.  The runtimes are
+// always packaged with the fuzz targets that link them, whatever the sanitizers and the version of
+// the clang runtime are.
+func isSanitizerRuntimeDependency(parent, dependency android.Module) bool {
+	c, ok := parent.(*Module)
+	if !ok || c.sanitize == nil || c.sanitize.Properties.RuntimeLibrary == "" {
+		return false
+	}
+	linkable, ok := dependency.(LinkableInterface)
+	return ok && linkable.Shared() &&
+		android.RemoveOptionalPrebuiltPrefix(dependency.Name()) == c.sanitize.Properties.RuntimeLibrary
+}
+
+// sanitizerRuntimeLibraries returns the sanitizer runtime shared libraries linked by a fuzz target
+// and by the shared libraries it depends on.
+func sanitizerRuntimeLibraries(ctx android.SingletonContext, module android.Module) android.Paths {
+	var runtimes android.Paths
+	seen := make(map[string]bool)
+	fringe := []android.Module{module}
+	for i := 0; i < len(fringe); i++ {
+		parent := fringe[i]
+		ctx.VisitDirectDeps(parent, func(dep android.Module) {
+			if seen[dep.Name()] {
+				return
+			}
+			if isSanitizerRuntimeDependency(parent, dep) {
+				seen[dep.Name()] = true
+				runtimes = append(runtimes, UnstrippedOutputFile(dep))
+			} else if IsValidSharedDependency(dep) {
+				seen[dep.Name()] = true
+				fringe = append(fringe, dep)
+			}
+		})
+	}
+	return runtimes
+}
+
 func sharedLibraryInstallLocation(
 	libraryPath android.Path, isHost bool, archString string) string {
 	installLocation := "$(PRODUCT_OUT)/data"
@@ -171,42 +209,7 @@ func (fuzz *fuzzBinary) install(ctx ModuleContext, file android.Path) {
 		"fuzz", ctx.Target().Arch.ArchType.String(), ctx.ModuleName())
 	fuzz.binaryDecorator.baseInstaller.install(ctx, file)
 
-	fuzz.fuzzPackagedModule.Corpus = android.PathsForModuleSrc(ctx, fuzz.fuzzPackagedModule.FuzzProperties.Corpus)
-	builder := android.NewRuleBuilder(pctx, ctx)
-	intermediateDir := android.PathForModuleOut(ctx, "corpus")
-	for _, entry := range fuzz.fuzzPackagedModule.Corpus {
-		builder.Command().Text("cp").
-			Input(entry).
-			Output(intermediateDir.Join(ctx, entry.Base()))
-	}
-	builder.Build("copy_corpus", "copy corpus")
-	fuzz.fuzzPackagedModule.CorpusIntermediateDir = intermediateDir
-
-	fuzz.fuzzPackagedModule.Data = android.PathsForModuleSrc(ctx, fuzz.fuzzPackagedModule.FuzzProperties.Data)
-	builder = android.NewRuleBuilder(pctx, ctx)
-	intermediateDir = android.PathForModuleOut(ctx, "data")
-	for _, entry := range fuzz.fuzzPackagedModule.Data {
-		builder.Command().Text("cp").
-			Input(entry).
-			Output(intermediateDir.Join(ctx, entry.Rel()))
-	}
-	builder.Build("copy_data", "copy data")
-	fuzz.fuzzPackagedModule.DataIntermediateDir = intermediateDir
-
-	if fuzz.fuzzPackagedModule.FuzzProperties.Dictionary != nil {
-		fuzz.fuzzPackagedModule.Dictionary = android.PathForModuleSrc(ctx, *fuzz.fuzzPackagedModule.FuzzProperties.Dictionary)
-		if fuzz.fuzzPackagedModule.Dictionary.Ext() != ".dict" {
-			ctx.PropertyErrorf("dictionary",
-				"Fuzzer dictionary %q does not have '.dict' extension",
-				fuzz.fuzzPackagedModule.Dictionary.String())
-		}
-	}
-
-	if fuzz.fuzzPackagedModule.FuzzProperties.Fuzz_config != nil {
-		configPath := android.PathForModuleOut(ctx, "config").Join(ctx, "config.json")
-		android.WriteFileRule(ctx, configPath, fuzz.fuzzPackagedModule.FuzzProperties.Fuzz_config.String())
-		fuzz.fuzzPackagedModule.Config = configPath
-	}
+	fuzz.fuzzPackagedModule.StageArtifacts(ctx, pctx)
 
 	// Grab the list of required shared libraries.
 	seen := make(map[string]bool)
@@ -217,8 +220,8 @@ func (fuzz *fuzzBinary) install(ctx ModuleContext, file android.Path) {
 		}
 		seen[child.Name()] = true
 
-		if IsValidSharedDependency(child) {
-			sharedLibraries = append(sharedLibraries, child.(*Module).UnstrippedOutputFile())
+		if isSanitizerRuntimeDependency(parent, child) || IsValidSharedDependency(child) {
+			sharedLibraries = append(sharedLibraries, UnstrippedOutputFile(child))
 			return true
 		}
 		return false
@@ -322,6 +325,7 @@ func (s *ccFuzzPackager) GenerateBuildActions(ctx android.SingletonContext) {
 
 		// Grab the list of required shared libraries.
 		sharedLibraries := fuzz.CollectAllSharedDependencies(ctx, module, UnstrippedOutputFile, IsValidSharedDependency)
+		sharedLibraries = android.FirstUniquePaths(append(sharedLibraries, sanitizerRuntimeLibraries(ctx, module)...))
 
 		var files []fuzz.FileToZip
 		builder := android.NewRuleBuilder(pctx, ctx)
@@ -15,6 +15,7 @@
 package cc
 
 import (
+	"fmt"
 	"path/filepath"
 	"strings"
 
@@ -306,3 +307,47 @@ func genSources(ctx android.ModuleContext, srcFiles android.Paths,
 
 	return srcFiles, deps, info
 }
+
+// SourceGeneratorHook registers the build rules that generate sources of a module in rule, and
+// returns the generated files.  The commands of the rule declare the files that they read besides
+// their inputs with DepFile or ImplicitDepFile, and the files that must be built before they run
+// with OrderOnly, so that the generated sources are rebuilt when and only when they need to be.
+type SourceGeneratorHook func(ctx ModuleContext, rule *android.RuleBuilder) GeneratedSources
+
+// GeneratedSources are the files generated by a SourceGeneratorHook.
+type GeneratedSources struct {
+	// Sources compiled into the module.  They may need further generation, e.g. .proto or .aidl
+	// files.
+	Srcs android.Paths
+
+	// Headers included by the sources of the module.  They are order only dependencies of the
+	// compiles, which then track the headers they include with their own depfiles.
+	Headers android.Paths
+
+	// Directories added to the include path of the module with -I.
+	IncludeDirs android.Paths
+}
+
+// AddSourceGenerator adds a hook that generates sources of the module, for module types built on
+// cc modules that generate sources from their own properties rather than from a genrule.  It is
+// called from the module factory.
+func (c *Module) AddSourceGenerator(hook SourceGeneratorHook) {
+	c.sourceGenerators = append(c.sourceGenerators, hook)
+}
+
+// runSourceGenerators runs the source generator hooks of the module, each with its own rule.
+func runSourceGenerators(ctx ModuleContext) GeneratedSources {
+	var generated GeneratedSources
+	for i, hook := range ctx.Module().(*Module).sourceGenerators {
+		rule := android.NewRuleBuilder(pctx, ctx)
+		g := hook(ctx, rule)
+		if len(rule.Commands()) > 0 {
+			name := fmt.Sprintf("source_generator_%d", i)
+			rule.Build(name, "generate sources "+name)
+		}
+		generated.Srcs = append(generated.Srcs, g.Srcs...)
+		generated.Headers = append(generated.Headers, g.Headers...)
+		generated.IncludeDirs = append(generated.IncludeDirs, g.IncludeDirs...)
+	}
+	return generated
+}
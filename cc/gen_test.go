@@ -75,3 +75,58 @@ func TestGen(t *testing.T) {
 	})
 
 }
+
+func TestSourceGenerator(t *testing.T) {
+	generatedLibraryFactory := func() android.Module {
+		module := LibraryStaticFactory()
+		module.(*Module).AddSourceGenerator(func(ctx ModuleContext, rule *android.RuleBuilder) GeneratedSources {
+			genDir := android.PathForModuleGen(ctx, "defs")
+			src := genDir.Join(ctx, "defs.c")
+			header := genDir.Join(ctx, "defs.h")
+			rule.Command().
+				Text("gen_defs").
+				Input(android.PathForModuleSrc(ctx, "defs.txt")).
+				OrderOnly(android.PathForModuleSrc(ctx, "defs_config.txt")).
+				FlagWithOutput("--src ", src).
+				FlagWithOutput("--header ", header).
+				FlagWithDepFile("--depfile ", genDir.Join(ctx, "defs.d"))
+			return GeneratedSources{
+				Srcs:        android.Paths{src},
+				Headers:     android.Paths{header},
+				IncludeDirs: android.Paths{genDir},
+			}
+		})
+		return module
+	}
+
+	result := android.GroupFixturePreparers(
+		prepareForCcTest,
+		android.FixtureRegisterWithContext(func(ctx android.RegistrationContext) {
+			ctx.RegisterModuleType("cc_library_static_generated", generatedLibraryFactory)
+		}),
+		android.FixtureAddFile("defs.txt", nil),
+		android.FixtureAddFile("defs_config.txt", nil),
+	).RunTestWithBp(t, `
+		cc_library_static_generated {
+			name: "libdefs",
+			srcs: ["foo.c"],
+		}
+	`)
+
+	libdefs := result.ModuleForTests("libdefs", "android_arm64_armv8-a_static")
+	gen := libdefs.Output("defs/defs.c")
+	genDir := "out/soong/.intermediates/libdefs/android_arm64_armv8-a_static/gen/defs"
+	android.AssertPathRelativeToTopEquals(t, "depfile", genDir+"/defs.d", gen.Depfile)
+	android.AssertPathsRelativeToTopEquals(t, "order only deps", []string{"defs_config.txt"}, gen.OrderOnly)
+
+	var cc android.TestingBuildParams
+	for _, output := range libdefs.AllOutputs() {
+		if strings.HasSuffix(output, "/defs.o") {
+			cc = libdefs.Output(output)
+		}
+	}
+	android.AssertPathRelativeToTopEquals(t, "compiled source", genDir+"/defs.c", cc.Input)
+	android.AssertStringListContains(t, "compile order only deps",
+		android.PathsRelativeToTop(cc.OrderOnly), genDir+"/defs.h")
+	android.AssertStringDoesContain(t, "cflags", android.StringRelativeToTop(result.Config, cc.Args["cFlags"]), "-I"+genDir)
+}
@@ -15,7 +15,9 @@
 package cc
 
 import (
+	"fmt"
 	"path/filepath"
+	"strings"
 
 	"android/soong/android"
 )
@@ -32,6 +34,14 @@ type InstallerProperties struct {
 
 	// Install output directly in {partition}/xbin
 	Install_in_xbin *bool `android:"arch_vvariant"`
+
+	// Template for the name of the installed file, to install variants of the module side by
+	// side. $(stem) and $(suffix) are replaced by the stem and the extension of the output file,
+	// $(arch) by the architecture of the variant and $(sanitizers) by the names of the sanitizers
+	// enabled in the variant, each prefixed with "_" (e.g. "_hwasan"). For example
+	// "$(stem)$(sanitizers)_$(arch)$(suffix)" installs the hwasan variant of libfoo for arm64 as
+	// libfoo_hwasan_arm64.so. Defaults to the name of the output file.
+	Install_name_template *string `android:"arch_variant"`
 }
 
 type installLocation int
@@ -97,7 +107,47 @@ func (installer *baseInstaller) installDir(ctx ModuleContext) android.InstallPat
 }
 
 func (installer *baseInstaller) install(ctx ModuleContext, file android.Path) {
-	installer.path = ctx.InstallFile(installer.installDir(ctx), file.Base(), file)
+	installer.path = ctx.InstallFile(installer.installDir(ctx), installer.installFileName(ctx, file), file)
+}
+
+// installFileName returns the name of the installed file, expanding install_name_template if
+// it is set.
+func (installer *baseInstaller) installFileName(ctx ModuleContext, file android.Path) string {
+	template := String(installer.Properties.Install_name_template)
+	if template == "" {
+		return file.Base()
+	}
+
+	stem, suffix, _ := android.SplitFileExt(file.Base())
+	name, err := android.Expand(template, func(variable string) (string, error) {
+		switch variable {
+		case "stem":
+			return stem, nil
+		case "suffix":
+			return suffix, nil
+		case "arch":
+			return ctx.Arch().ArchType.String(), nil
+		case "sanitizers":
+			var sanitizers string
+			for _, t := range Sanitizers {
+				if ctx.Module().(*Module).sanitize.isSanitizerEnabled(t) {
+					sanitizers += "_" + t.variationName()
+				}
+			}
+			return sanitizers, nil
+		default:
+			return "", fmt.Errorf("unknown variable $(%s)", variable)
+		}
+	})
+	if err != nil {
+		ctx.PropertyErrorf("install_name_template", "%s", err)
+		return file.Base()
+	}
+	if name == "" || strings.Contains(name, "/") {
+		ctx.PropertyErrorf("install_name_template", "%q is not a valid file name", name)
+		return file.Base()
+	}
+	return name
 }
 
 func (installer *baseInstaller) everInstallable() bool {
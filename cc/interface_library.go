@@ -0,0 +1,113 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cc
+
+import (
+	"strings"
+
+	"android/soong/android"
+)
+
+func init() {
+	RegisterInterfaceLibraryBuildComponents(android.InitRegistrationContext)
+}
+
+func RegisterInterfaceLibraryBuildComponents(ctx android.RegistrationContext) {
+	ctx.RegisterModuleType("cc_interface_library", InterfaceLibraryFactory)
+}
+
+type interfaceLibraryProperties struct {
+	// Relative path to the symbol map describing the symbols exported by the library.
+	Symbol_file *string
+
+	// Whether the library doesn't use symbol versions.
+	Unversioned *bool
+}
+
+// interfaceLibraryDecorator builds a linkable stub of a shared library from its symbol file.
+type interfaceLibraryDecorator struct {
+	*libraryDecorator
+
+	properties interfaceLibraryProperties
+}
+
+func (library *interfaceLibraryDecorator) compilerFlags(ctx ModuleContext, flags Flags, deps PathDeps) Flags {
+	flags = library.baseCompiler.compilerFlags(ctx, flags, deps)
+	return addStubLibraryCompilerFlags(flags)
+}
+
+func (library *interfaceLibraryDecorator) compile(ctx ModuleContext, flags Flags, deps PathDeps) Objects {
+	if len(library.baseCompiler.Properties.Srcs) > 0 {
+		ctx.PropertyErrorf("srcs", "cc_interface_library must not have any srcs")
+		return Objects{}
+	}
+	symbolFile := String(library.properties.Symbol_file)
+	if !strings.HasSuffix(symbolFile, ".map.txt") {
+		ctx.PropertyErrorf("symbol_file", "%q doesn't have .map.txt suffix", symbolFile)
+		return Objects{}
+	}
+
+	nativeAbiResult := parseNativeAbiDefinition(ctx, symbolFile, android.FutureApiLevel, "")
+	objs := compileStubLibrary(ctx, flags, nativeAbiResult.stubSrc)
+	if !Bool(library.properties.Unversioned) {
+		library.versionScriptPath = android.OptionalPathForPath(nativeAbiResult.versionScript)
+	}
+	return objs
+}
+
+func (library *interfaceLibraryDecorator) nativeCoverage() bool {
+	return false
+}
+
+// The real library is provided by the device, the stub is only used to link against it.
+func (library *interfaceLibraryDecorator) everInstallable() bool {
+	return false
+}
+
+func (library *interfaceLibraryDecorator) install(ctx ModuleContext, path android.Path) {
+}
+
+// cc_interface_library creates a build-time only stub of a shared library that is provided by
+// the device instead of being built from source, e.g. a proprietary library that can't be
+// shipped in the source tree. Modules link against it with shared_libs like against any other
+// shared library. The stub is generated from the symbol file of the library, and exports its
+// headers with export_include_dirs or export_header_lib_headers.
+//
+// Example:
+//
+//	cc_interface_library {
+//	    name: "libfoo",
+//	    symbol_file: "libfoo.map.txt",
+//	    export_include_dirs: ["include"],
+//	}
+func InterfaceLibraryFactory() android.Module {
+	module, library := NewLibrary(android.DeviceSupported)
+	library.BuildOnlyShared()
+	module.stl = nil
+	module.sanitize = nil
+	library.disableStripping()
+
+	interfaceLibrary := &interfaceLibraryDecorator{
+		libraryDecorator: library,
+	}
+	module.compiler = interfaceLibrary
+	module.linker = interfaceLibrary
+	module.installer = interfaceLibrary
+	module.library = interfaceLibrary
+
+	module.AddProperties(&interfaceLibrary.properties)
+
+	return module.Init()
+}
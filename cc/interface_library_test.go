@@ -0,0 +1,100 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cc
+
+import (
+	"testing"
+
+	"android/soong/android"
+)
+
+func TestInterfaceLibrary(t *testing.T) {
+	ctx := testCc(t, `
+	cc_interface_library {
+		name: "libproprietary",
+		symbol_file: "libproprietary.map.txt",
+		export_include_dirs: ["my_include"],
+	}
+	cc_library_shared {
+		name: "libclient",
+		srcs: ["foo.c"],
+		shared_libs: ["libproprietary"],
+	}
+	cc_interface_library {
+		name: "libunversioned",
+		symbol_file: "libunversioned.map.txt",
+		unversioned: true,
+	}
+	`)
+
+	variant := "android_arm64_armv8-a_shared"
+	libproprietary := ctx.ModuleForTests("libproprietary", variant)
+
+	stubSrc := libproprietary.Output("stub.c")
+	android.AssertPathRelativeToTopEquals(t, "symbol file", "libproprietary.map.txt", stubSrc.Input)
+	android.AssertStringDoesContain(t, "ldFlags", libproprietary.Rule("ld").Args["ldFlags"],
+		"-Wl,--version-script,out/soong/.intermediates/libproprietary/"+variant+"/gen/stub.map")
+
+	// The stub is only used at build time.
+	if libproprietary.MaybeOutput("out/soong/target/product/test_device/system/lib64/libproprietary.so").Rule != nil {
+		t.Errorf("libproprietary must not be installed")
+	}
+
+	libclient := ctx.ModuleForTests("libclient", variant)
+	android.AssertStringListContains(t, "libclient links against the stub",
+		android.PathsRelativeToTop(libclient.Rule("ld").Implicits),
+		"out/soong/.intermediates/libproprietary/"+variant+"/libproprietary.so")
+	android.AssertStringDoesContain(t, "libclient includes the exported headers",
+		libclient.Rule("cc").Args["cFlags"],
+		"-Imy_include")
+
+	android.AssertStringDoesNotContain(t, "ldFlags",
+		ctx.ModuleForTests("libunversioned", variant).Rule("ld").Args["ldFlags"], "--version-script")
+}
+
+func TestInterfaceLibraryErrors(t *testing.T) {
+	testCases := []struct {
+		name string
+		bp   string
+		err  string
+	}{
+		{
+			name: "srcs",
+			bp: `
+				cc_interface_library {
+					name: "libfoo",
+					srcs: ["foo.c"],
+					symbol_file: "libfoo.map.txt",
+				}`,
+			err: `srcs: cc_interface_library must not have any srcs`,
+		},
+		{
+			name: "symbol_file",
+			bp: `
+				cc_interface_library {
+					name: "libfoo",
+					symbol_file: "libfoo.txt",
+				}`,
+			err: `symbol_file: "libfoo.txt" doesn't have .map.txt suffix`,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			prepareForCcTest.
+				ExtendWithErrorHandler(android.FixtureExpectsAtLeastOneErrorMatchingPattern(tc.err)).
+				RunTestWithBp(t, tc.bp)
+		})
+	}
+}
@@ -70,6 +70,11 @@ type LibraryProperties struct {
 		// List versions to generate stubs libs for. The version name "current" is always
 		// implicitly added.
 		Versions []string
+
+		// Relative path to the checked-in list of the symbols exported by the library when
+		// version_script is "auto", one per line.  The build fails when the library exports
+		// symbols that are not in the list, to catch accidental growth of its ABI.
+		Exported_symbols_file *string `android:"path"`
 	}
 
 	// set the name of the output
@@ -124,6 +129,18 @@ type LibraryProperties struct {
 
 	// If this is a vendor public library, properties to describe the vendor public library stubs.
 	Vendor_public_library vendorPublicLibraryProperties
+
+	// If true, generate a JSON description of the API of the shared library: its exported
+	// headers, the symbols listed in stubs.symbol_file (or version_script if not set) and its
+	// shared library dependencies. The description can be referenced with the
+	// ":<module>{.api_description}" syntax.
+	Export_api *bool
+
+	// If true, emit the static variant as a thin archive that references the object files in
+	// the intermediates directory instead of copying them.  Snapshots and dist still receive a
+	// regular archive, which is only built for libraries that are disted or packaged into an sdk
+	// or a snapshot.  Defaults to true if the SOONG_THIN_ARCHIVES environment variable is set.
+	Thin_archive *bool
 }
 
 // StaticProperties is a properties stanza to affect only attributes of the "static" variants of a
@@ -613,6 +630,10 @@ type libraryDecorator struct {
 	// Source Abi Diff
 	sAbiDiff android.OptionalPath
 
+	// Symbol map of the LLNDK stubs, either llndk.symbol_file or the one generated from
+	// llndk.symbols
+	llndkSymbolFile android.Path
+
 	// Location of the static library in the sysroot. Empty if the library is
 	// not included in the NDK.
 	ndkSysrootPath android.Path
@@ -623,8 +644,23 @@ type libraryDecorator struct {
 	// Location of the file that should be copied to dist dir when requested
 	distFile android.Path
 
+	// A regular archive with the same contents as the output file when the static library is
+	// emitted as a thin archive, for packaging outside of the intermediates directory.
+	fullArchive android.OptionalPath
+
 	versionScriptPath android.OptionalPath
 
+	// Location of the stamp file of the check that the symbols exported by the library are all in
+	// stubs.exported_symbols_file, set when version_script is "auto" and the file is set
+	exportedSymbolsCheck android.OptionalPath
+
+	// The outputs generated from the stubs symbol file for the APEX API, shared by the automatic
+	// version script and the check of the symbols exported by the APEX variants
+	apexAbiDefinition *ndkApiOutputs
+
+	// Location of the JSON description of the API of the library, set when export_api is true
+	apiDescriptionFile android.OptionalPath
+
 	postInstallCmds []string
 
 	// If useCoreVariant is true, the vendor variant of a VNDK library is
@@ -902,6 +938,11 @@ func (library *libraryDecorator) linkerProps() []interface{} {
 // library, or that are implied by attributes of this library (such as whether this library is a
 // shared library).
 func (library *libraryDecorator) linkerFlags(ctx ModuleContext, flags Flags) Flags {
+	if library.shared() && String(library.baseLinker.Properties.Version_script) == versionScriptAuto &&
+		!library.buildStubs() && !ctx.IsLlndk() && !ctx.IsVendorPublicLibrary() {
+		library.baseLinker.autoVersionScript = library.generateAutoVersionScript(ctx)
+	}
+
 	flags = library.baseLinker.linkerFlags(ctx, flags)
 
 	// MinGW spits out warnings about -fPIC even for -fpie?!) being ignored because
@@ -1023,8 +1064,8 @@ func (library *libraryDecorator) compile(ctx ModuleContext, flags Flags, deps Pa
 		if library.stubsVersion() != "" {
 			vndkVer = library.stubsVersion()
 		}
-		nativeAbiResult := parseNativeAbiDefinition(ctx,
-			String(library.Properties.Llndk.Symbol_file),
+		library.llndkSymbolFile = llndkSymbolFile(ctx, &library.Properties.Llndk)
+		nativeAbiResult := parseNativeAbiDefinitionFromPath(ctx, library.llndkSymbolFile,
 			android.ApiLevelOrPanic(ctx, vndkVer), "--llndk")
 		objs := compileStubLibrary(ctx, flags, nativeAbiResult.stubSrc)
 		if !Bool(library.Properties.Llndk.Unversioned) {
@@ -1364,6 +1405,36 @@ func (library *libraryDecorator) linkerSpecifiedDeps(specifiedDeps specifiedDeps
 	return specifiedDeps
 }
 
+// thinArchive returns true if the static library should be emitted as a thin archive.  Thin
+// archives can't hold the members of regular archives or be rewritten by symbol_inject, and are
+// only supported in the GNU archive format.
+func (library *libraryDecorator) thinArchive(ctx ModuleContext, deps PathDeps) bool {
+	if !BoolDefault(library.Properties.Thin_archive, ctx.Config().ThinArchives()) {
+		return false
+	}
+	if len(deps.WholeStaticLibsFromPrebuilts) > 0 || Bool(library.baseLinker.Properties.Use_version_lib) {
+		return false
+	}
+	return !ctx.Darwin()
+}
+
+// needsFullArchive returns true if a regular archive must be built alongside the thin archive,
+// because the library is disted or packaged into an sdk or a snapshot, which copy the archive out
+// of the intermediates directory.
+func (library *libraryDecorator) needsFullArchive(ctx ModuleContext) bool {
+	m := ctx.Module().(*Module)
+	if len(m.Dists()) > 0 || m.IsInAnySdk() {
+		return true
+	}
+	apexInfo := ctx.Provider(android.ApexInfoProvider).(android.ApexInfo)
+	return ShouldCollectHeadersForSnapshot(ctx, m, apexInfo)
+}
+
+// fullArchiveFile returns the regular archive built alongside a thin archive, if any.
+func (library *libraryDecorator) fullArchiveFile() android.OptionalPath {
+	return library.fullArchive
+}
+
 func (library *libraryDecorator) linkStatic(ctx ModuleContext,
 	flags Flags, deps PathDeps, objs Objects) android.Path {
 
@@ -1387,7 +1458,20 @@ func (library *libraryDecorator) linkStatic(ctx ModuleContext,
 		}
 	}
 
-	transformObjToStaticLib(ctx, library.objects.objFiles, deps.WholeStaticLibsFromPrebuilts, builderFlags, outputFile, nil, objs.tidyDepFiles)
+	if library.thinArchive(ctx, deps) {
+		builderFlags.thinArchive = true
+		if library.needsFullArchive(ctx) {
+			fullArchive := android.PathForModuleOut(ctx, "full", fileName)
+			transformObjToStaticLib(ctx, library.objects.objFiles, nil, flagsToBuilderFlags(flags), fullArchive, nil, nil)
+			library.fullArchive = android.OptionalPathForPath(fullArchive)
+			library.distFile = fullArchive
+		}
+	}
+
+	validations := append(android.Paths{}, objs.tidyDepFiles...)
+	validations = append(validations, objs.stdMatrixFiles...)
+	validations = append(validations, objs.warningBaselineFiles...)
+	transformObjToStaticLib(ctx, library.objects.objFiles, deps.WholeStaticLibsFromPrebuilts, builderFlags, outputFile, nil, validations)
 
 	library.coverageOutputFile = transformCoverageFilesToZip(ctx, library.objects, ctx.ModuleName())
 
@@ -1504,6 +1588,7 @@ func (library *libraryDecorator) linkShared(ctx ModuleContext,
 		library.stripper.StripExecutableOrSharedLib(ctx, outputFile, strippedOutputFile, stripFlags)
 	}
 	library.unstrippedOutputFile = outputFile
+	library.breakpadSymbols(ctx, outputFile)
 
 	outputFile = maybeInjectBoringSSLHash(ctx, outputFile, library.Properties.Inject_bssl_hash, fileName)
 
@@ -1533,9 +1618,28 @@ func (library *libraryDecorator) linkShared(ctx ModuleContext,
 	linkerDeps = append(linkerDeps, deps.EarlySharedLibsDeps...)
 	linkerDeps = append(linkerDeps, deps.SharedLibsDeps...)
 	linkerDeps = append(linkerDeps, deps.LateSharedLibsDeps...)
+
+	validations := append(android.Paths{}, objs.tidyDepFiles...)
+	validations = append(validations, objs.stdMatrixFiles...)
+	validations = append(validations, objs.warningBaselineFiles...)
+	validations = append(validations, library.odrCheck(ctx, deps, objs)...)
+	validations = append(validations, ifuncCheck(ctx, outputFile)...)
+	validations = append(validations, library.apexExportedSymbolsCheck(ctx, outputFile)...)
+	validations = append(validations, library.gcSectionsKeepCheck(ctx, outputFile)...)
+	if stl := ctx.Module().(*Module).stl; stl != nil {
+		validations = append(validations, stl.staticStlExportsCheck(ctx, outputFile)...)
+	}
+	validations = append(validations, branchProtectionPrebuiltsCheck(ctx)...)
+	linkMap, unusedDepsReport := library.unusedDepsCheck(ctx, &builderFlags, outputFile)
+	library.memoryUsage(ctx, &builderFlags)
+	implicitOutputs = append(implicitOutputs, linkMap...)
+	validations = append(validations, unusedDepsReport...)
+	if library.exportedSymbolsCheck.Valid() {
+		validations = append(validations, library.exportedSymbolsCheck.Path())
+	}
 	transformObjToDynamicBinary(ctx, objs.objFiles, sharedLibs,
 		deps.StaticLibs, deps.LateStaticLibs, deps.WholeStaticLibs,
-		linkerDeps, deps.CrtBegin, deps.CrtEnd, false, builderFlags, outputFile, implicitOutputs, objs.tidyDepFiles)
+		linkerDeps, deps.CrtBegin, deps.CrtEnd, false, builderFlags, outputFile, implicitOutputs, validations)
 
 	objs.coverageFiles = append(objs.coverageFiles, deps.StaticLibObjs.coverageFiles...)
 	objs.coverageFiles = append(objs.coverageFiles, deps.WholeStaticLibObjs.coverageFiles...)
@@ -1645,7 +1749,7 @@ func (library *libraryDecorator) linkSAbiDumpFiles(ctx ModuleContext, objs Objec
 		}
 		exportedHeaderFlags := strings.Join(SourceAbiFlags, " ")
 		library.sAbiOutputFile = transformDumpToLinkedDump(ctx, objs.sAbiDumpFiles, soFile, fileName, exportedHeaderFlags,
-			android.OptionalPathForModuleSrc(ctx, library.symbolFileForAbiCheck(ctx)),
+			library.symbolFileForAbiCheck(ctx),
 			library.Properties.Header_abi_checker.Exclude_symbol_versions,
 			library.Properties.Header_abi_checker.Exclude_symbol_tags)
 
@@ -1714,6 +1818,32 @@ func (library *libraryDecorator) link(ctx ModuleContext,
 			library.flagExporter.Properties.Export_include_dirs = override
 		}
 
+		// export only the headers listed in llndk.export_headers from the exported include
+		// directories if it is set.
+		if headers := library.Properties.Llndk.Export_headers; len(headers) > 0 {
+			genHeaderOutDir := android.PathForModuleGen(ctx, "llndk_include")
+			genSystemHeaderOutDir := android.PathForModuleGen(ctx, "llndk_system_include")
+			filtered, filteredSystem := filterLLNDKHeaders(ctx, headers,
+				library.flagExporter.Properties.Export_include_dirs,
+				library.flagExporter.Properties.Export_system_include_dirs,
+				genHeaderOutDir, genSystemHeaderOutDir)
+			library.flagExporter.Properties.Export_include_dirs = nil
+			library.flagExporter.Properties.Export_system_include_dirs = nil
+
+			if len(filtered) > 0 {
+				if Bool(library.Properties.Llndk.Export_headers_as_system) {
+					library.reexportSystemDirs(genHeaderOutDir)
+				} else {
+					library.reexportDirs(genHeaderOutDir)
+				}
+			}
+			if len(filteredSystem) > 0 {
+				library.reexportSystemDirs(genSystemHeaderOutDir)
+			}
+			library.addExportedGeneratedHeaders(append(filtered, filteredSystem...)...)
+			library.reexportDeps(append(filtered, filteredSystem...)...)
+		}
+
 		if Bool(library.Properties.Llndk.Export_headers_as_system) {
 			library.flagExporter.Properties.Export_system_include_dirs = append(
 				library.flagExporter.Properties.Export_system_include_dirs,
@@ -1808,9 +1938,86 @@ func (library *libraryDecorator) link(ctx ModuleContext,
 	// Propagate a Provider containing information about exported flags, deps, and include paths.
 	library.flagExporter.setProvider(ctx)
 
+	if Bool(library.Properties.Export_api) && library.shared() && !library.buildStubs() {
+		library.apiDescriptionFile = library.buildApiDescription(ctx)
+	}
+
 	return out
 }
 
+// generateAutoVersionScript generates the version script of a shared library with version_script
+// "auto" from its stubs symbol file, exporting only the symbols exported by its stubs.
+func (library *libraryDecorator) generateAutoVersionScript(ctx ModuleContext) android.OptionalPath {
+	symbolFile := String(library.Properties.Stubs.Symbol_file)
+	if symbolFile == "" {
+		ctx.PropertyErrorf("version_script", "%q requires stubs.symbol_file", versionScriptAuto)
+		return android.OptionalPath{}
+	}
+	nativeAbiResult := library.apexNativeAbiDefinition(ctx)
+	if exportedSymbols := android.OptionalPathForModuleSrc(ctx, library.Properties.Stubs.Exported_symbols_file); exportedSymbols.Valid() {
+		library.exportedSymbolsCheck = android.OptionalPathForPath(
+			transformSymbolListToExportedSymbolsCheck(ctx, nativeAbiResult.symbolList, exportedSymbols.Path()))
+	}
+	return android.OptionalPathForPath(transformVersionScriptToAuto(ctx, nativeAbiResult.versionScript))
+}
+
+// apexNativeAbiDefinition returns the stubs, version script and symbol list generated from the
+// stubs symbol file of the library for the APEX API, generating them on the first call.
+func (library *libraryDecorator) apexNativeAbiDefinition(ctx ModuleContext) ndkApiOutputs {
+	if library.apexAbiDefinition == nil {
+		nativeAbiResult := parseNativeAbiDefinition(ctx, String(library.Properties.Stubs.Symbol_file),
+			android.FutureApiLevel, "--apex")
+		library.apexAbiDefinition = &nativeAbiResult
+	}
+	return *library.apexAbiDefinition
+}
+
+// apexExportedSymbolsCheck returns the stamp file of the check that the variant of a library with
+// stubs that is built for an APEX exports no symbols that are not in its stubs, to be used as a
+// validation of the link.  Existing libraries often export more than their stubs, so the check
+// only runs when the product sets EnforceApexExportedSymbols.
+func (library *libraryDecorator) apexExportedSymbolsCheck(ctx ModuleContext, linked android.Path) android.Paths {
+	if !ctx.Config().EnforceApexExportedSymbols() || library.Properties.Stubs.Symbol_file == nil ||
+		library.buildStubs() || !ctx.Device() || ctx.isForPlatform() {
+		return nil
+	}
+	symbolList := library.apexNativeAbiDefinition(ctx).symbolList
+	return android.Paths{transformSharedLibToApexExportedSymbolsCheck(ctx, linked, symbolList)}
+}
+
+// buildApiDescription generates the JSON description of the API of this library from its symbol
+// file, exported headers and shared library dependencies.
+func (library *libraryDecorator) buildApiDescription(ctx ModuleContext) android.OptionalPath {
+	symbolFile := library.Properties.Stubs.Symbol_file
+	if symbolFile == nil {
+		symbolFile = library.baseLinker.Properties.Version_script
+	}
+	if symbolFile == nil {
+		ctx.PropertyErrorf("export_api", "requires stubs.symbol_file or version_script")
+		return android.OptionalPath{}
+	}
+
+	exportedDirs := append(android.CopyOfPaths(library.flagExporter.dirs), library.flagExporter.systemDirs...)
+	headers := GlobHeadersForSnapshot(ctx, exportedDirs)
+
+	var deps []string
+	ctx.VisitDirectDeps(func(dep android.Module) {
+		if IsSharedDepTag(ctx.OtherModuleDependencyTag(dep)) {
+			deps = append(deps, ctx.OtherModuleName(dep))
+		}
+	})
+
+	apiDescription := transformSymbolFileToApiDescription(ctx, android.PathForModuleSrc(ctx, *symbolFile),
+		android.FirstUniqueStrings(exportedDirs.Strings()), android.FirstUniqueStrings(headers.Strings()),
+		android.SortedUniqueStrings(deps))
+	ctx.CheckbuildFile(apiDescription)
+	return android.OptionalPathForPath(apiDescription)
+}
+
+func (library *libraryDecorator) apiDescription() android.OptionalPath {
+	return library.apiDescriptionFile
+}
+
 func (library *libraryDecorator) exportVersioningMacroIfNeeded(ctx android.BaseModuleContext) {
 	if library.buildStubs() && library.stubsVersion() != "" && !library.skipAPIDefine {
 		name := versioningMacroName(ctx.Module().(*Module).ImplementationModuleName(ctx))
@@ -1991,7 +2198,7 @@ func (library *libraryDecorator) HeaderOnly() {
 
 // hasLLNDKStubs returns true if this cc_library module has a variant that will build LLNDK stubs.
 func (library *libraryDecorator) hasLLNDKStubs() bool {
-	return String(library.Properties.Llndk.Symbol_file) != ""
+	return String(library.Properties.Llndk.Symbol_file) != "" || len(library.Properties.Llndk.Symbols) > 0
 }
 
 // hasLLNDKStubs returns true if this cc_library module has a variant that will build LLNDK stubs.
@@ -2013,17 +2220,18 @@ func (library *libraryDecorator) buildStubs() bool {
 	return library.MutatedProperties.BuildStubs
 }
 
-func (library *libraryDecorator) symbolFileForAbiCheck(ctx ModuleContext) *string {
+func (library *libraryDecorator) symbolFileForAbiCheck(ctx ModuleContext) android.OptionalPath {
 	if library.Properties.Header_abi_checker.Symbol_file != nil {
-		return library.Properties.Header_abi_checker.Symbol_file
+		return android.OptionalPathForModuleSrc(ctx, library.Properties.Header_abi_checker.Symbol_file)
 	}
 	if ctx.Module().(*Module).IsLlndk() {
-		return library.Properties.Llndk.Symbol_file
+		// The symbol file of the LLNDK stubs, which is generated from llndk.symbols if it is set.
+		return android.OptionalPathForPath(library.llndkSymbolFile)
 	}
 	if library.hasStubsVariants() && library.Properties.Stubs.Symbol_file != nil {
-		return library.Properties.Stubs.Symbol_file
+		return android.OptionalPathForModuleSrc(ctx, library.Properties.Stubs.Symbol_file)
 	}
-	return nil
+	return android.OptionalPath{}
 }
 
 func (library *libraryDecorator) hasStubsVariants() bool {
@@ -571,7 +571,10 @@ func (p *nativeLibInfoProperties) PopulateFromVariant(ctx android.SdkMemberConte
 func getRequiredMemberOutputFile(ctx android.SdkMemberContext, ccModule *Module) android.Path {
 	var path android.Path
 	outputFile := ccModule.OutputFile()
-	if outputFile.Valid() {
+	if fullArchive := ccModule.fullArchiveFile(); fullArchive.Valid() {
+		// Thin archives can't be copied out of the intermediates directory.
+		path = fullArchive.Path()
+	} else if outputFile.Valid() {
 		path = outputFile.Path()
 	} else {
 		ctx.SdkModuleContext().ModuleErrorf("member variant %s does not have a valid output file", ccModule)
@@ -318,6 +318,164 @@ func TestLibraryVersionScript(t *testing.T) {
 
 }
 
+func TestLibraryThinArchive(t *testing.T) {
+	bp := `
+		cc_library_static {
+			name: "libthin",
+			srcs: ["foo.c"],
+			thin_archive: true,
+		}
+
+		cc_library_static {
+			name: "libthin_dist",
+			srcs: ["foo.c"],
+			thin_archive: true,
+			dist: {
+				targets: ["dist_target"],
+			},
+		}
+
+		cc_library_static {
+			name: "libfat",
+			srcs: ["foo.c"],
+		}
+
+		cc_library_static {
+			name: "libnotthin",
+			srcs: ["foo.c"],
+			thin_archive: false,
+		}`
+
+	t.Run("per-module", func(t *testing.T) {
+		result := PrepareForIntegrationTestWithCc.RunTestWithBp(t, bp)
+
+		// The regular archive is only built for libraries that are disted or packaged.
+		libthin := result.ModuleForTests("libthin", "android_arm64_armv8-a_static")
+		thin := libthin.Output("libthin.a")
+		android.AssertStringDoesContain(t, "thin archive flags", thin.Args["arFlags"], "crsPDT")
+		if libthin.MaybeOutput("full/libthin.a").Rule != nil {
+			t.Errorf("unexpected full archive for libthin")
+		}
+
+		outputFiles, err := libthin.Module().(android.OutputFileProducer).OutputFiles("")
+		android.FailIfErrored(t, []error{err})
+		android.AssertPathsRelativeToTopEquals(t, "output files",
+			[]string{android.PathRelativeToTop(thin.Output)}, outputFiles)
+
+		libthinDist := result.ModuleForTests("libthin_dist", "android_arm64_armv8-a_static")
+		android.AssertStringDoesContain(t, "thin archive flags",
+			libthinDist.Output("libthin_dist.a").Args["arFlags"], "crsPDT")
+		full := libthinDist.Output("full/libthin_dist.a")
+		android.AssertStringDoesNotContain(t, "full archive flags", full.Args["arFlags"], "T")
+
+		outputFiles, err = libthinDist.Module().(android.OutputFileProducer).OutputFiles("")
+		android.FailIfErrored(t, []error{err})
+		android.AssertPathsRelativeToTopEquals(t, "output files",
+			[]string{android.PathRelativeToTop(full.Output)}, outputFiles)
+
+		libfat := result.ModuleForTests("libfat", "android_arm64_armv8-a_static")
+		android.AssertStringDoesNotContain(t, "regular archive flags",
+			libfat.Output("libfat.a").Args["arFlags"], "T")
+		if libfat.MaybeOutput("full/libfat.a").Rule != nil {
+			t.Errorf("unexpected full archive for libfat")
+		}
+	})
+
+	t.Run("global", func(t *testing.T) {
+		result := android.GroupFixturePreparers(
+			PrepareForIntegrationTestWithCc,
+			android.FixtureMergeEnv(map[string]string{"SOONG_THIN_ARCHIVES": "true"}),
+		).RunTestWithBp(t, bp)
+
+		libfat := result.ModuleForTests("libfat", "android_arm64_armv8-a_static")
+		android.AssertStringDoesContain(t, "thin archive flags",
+			libfat.Output("libfat.a").Args["arFlags"], "crsPDT")
+
+		libnotthin := result.ModuleForTests("libnotthin", "android_arm64_armv8-a_static")
+		android.AssertStringDoesNotContain(t, "regular archive flags",
+			libnotthin.Output("libnotthin.a").Args["arFlags"], "T")
+	})
+}
+
+func TestLibraryAutoVersionScript(t *testing.T) {
+	result := PrepareForIntegrationTestWithCc.RunTestWithBp(t, `
+		cc_library {
+			name: "libfoo",
+			srcs: ["foo.c"],
+			version_script: "auto",
+			stubs: {
+				symbol_file: "libfoo.map.txt",
+				versions: ["29"],
+				exported_symbols_file: "libfoo.exported_symbols.txt",
+			},
+		}
+
+		cc_library {
+			name: "libbar",
+			srcs: ["bar.c"],
+			version_script: "auto",
+			stubs: {
+				symbol_file: "libbar.map.txt",
+				versions: ["29"],
+			},
+		}`)
+
+	libfoo := result.ModuleForTests("libfoo", "android_arm64_armv8-a_shared")
+
+	stubMap := libfoo.Output("stub.map")
+	android.AssertPathRelativeToTopEquals(t, "stub.map input",
+		"libfoo.map.txt", stubMap.Input)
+
+	autoVersionScript := libfoo.Output("auto_version_script.map")
+	android.AssertPathRelativeToTopEquals(t, "auto version script input",
+		android.PathRelativeToTop(stubMap.Output), autoVersionScript.Input)
+
+	ld := libfoo.Rule("ld")
+	android.AssertStringListContains(t, "missing dependency on auto version script",
+		android.PathsRelativeToTop(ld.Implicits),
+		"out/soong/.intermediates/libfoo/android_arm64_armv8-a_shared/auto_version_script.map")
+	android.AssertStringDoesContain(t, "missing flag for auto version script",
+		ld.Args["ldFlags"],
+		"-Wl,--version-script,out/soong/.intermediates/libfoo/android_arm64_armv8-a_shared/auto_version_script.map")
+	android.AssertStringListContains(t, "link validations",
+		android.PathsRelativeToTop(ld.Validations),
+		"out/soong/.intermediates/libfoo/android_arm64_armv8-a_shared/exported_symbols_check.stamp")
+
+	check := libfoo.Output("exported_symbols_check.stamp")
+	android.AssertPathRelativeToTopEquals(t, "exported symbols check input",
+		"out/soong/.intermediates/libfoo/android_arm64_armv8-a_shared/gen/abi_symbol_list.txt", check.Input)
+	android.AssertPathRelativeToTopEquals(t, "exported symbols check list",
+		"libfoo.exported_symbols.txt", check.Implicit)
+
+	// Without a checked-in list of exported symbols there is nothing to check against.
+	libbar := result.ModuleForTests("libbar", "android_arm64_armv8-a_shared")
+	if libbar.MaybeOutput("exported_symbols_check.stamp").Rule != nil {
+		t.Errorf("expected no exported symbols check without stubs.exported_symbols_file")
+	}
+
+	// The stubs are linked with the version script generated for them, not the automatic one.
+	stubs := result.ModuleForTests("libfoo", "android_arm64_armv8-a_shared_29")
+	if stubs.MaybeOutput("auto_version_script.map").Rule != nil {
+		t.Errorf("expected stubs not to generate an automatic version script")
+	}
+}
+
+func TestLibraryAutoVersionScriptErrors(t *testing.T) {
+	testCcError(t, `version_script: "auto" requires stubs.symbol_file`, `
+		cc_library_shared {
+			name: "libfoo",
+			srcs: ["foo.c"],
+			version_script: "auto",
+		}`)
+
+	testCcError(t, `version_script: "auto" is only supported by shared libraries`, `
+		cc_binary {
+			name: "foo",
+			srcs: ["foo.c"],
+			version_script: "auto",
+		}`)
+}
+
 func TestLibraryDynamicList(t *testing.T) {
 	result := PrepareForIntegrationTestWithCc.RunTestWithBp(t, `
 		cc_library {
@@ -453,3 +611,71 @@ func TestWholeStaticLibPrebuilts(t *testing.T) {
 	android.AssertStringDoesContain(t, "missing flag for baz.o",
 		libtransitiveWithSrcs.Args["arObjs"], bazObj.Output.String())
 }
+
+func TestLibraryExportApi(t *testing.T) {
+	result := android.GroupFixturePreparers(
+		PrepareForIntegrationTestWithCc,
+		android.FixtureMergeMockFs(android.MockFS{
+			"include/foo.h": nil,
+		}),
+	).RunTestWithBp(t, `
+		cc_library {
+			name: "libfoo",
+			srcs: ["foo.c"],
+			export_include_dirs: ["include"],
+			shared_libs: ["libbar"],
+			stubs: {
+				symbol_file: "foo.map.txt",
+			},
+			export_api: true,
+		}
+
+		cc_library {
+			name: "libbar",
+			srcs: ["bar.c"],
+			version_script: "bar.map.txt",
+			export_api: true,
+		}
+
+		cc_library {
+			name: "libbaz",
+			srcs: ["baz.c"],
+		}`)
+
+	libfoo := result.ModuleForTests("libfoo", "android_arm64_armv8-a_shared")
+	apiDescription := libfoo.Output("libfoo.api.json")
+	android.AssertStringEquals(t, "input", "foo.map.txt", apiDescription.Input.String())
+	android.AssertStringEquals(t, "arch", "arm64", apiDescription.Args["arch"])
+	android.AssertStringDoesContain(t, "flags", apiDescription.Args["flags"], "--include-dir include")
+	android.AssertStringDoesContain(t, "flags", apiDescription.Args["flags"], "--header include/foo.h")
+	android.AssertStringDoesContain(t, "flags", apiDescription.Args["flags"], "--dep libbar")
+
+	outputs, err := libfoo.Module().(*Module).OutputFiles(".api_description")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	android.AssertPathsRelativeToTopEquals(t, "output files",
+		[]string{"out/soong/.intermediates/libfoo/android_arm64_armv8-a_shared/libfoo.api.json"},
+		outputs)
+
+	libbar := result.ModuleForTests("libbar", "android_arm64_armv8-a_shared")
+	android.AssertStringEquals(t, "input", "bar.map.txt", libbar.Output("libbar.api.json").Input.String())
+
+	libbaz := result.ModuleForTests("libbaz", "android_arm64_armv8-a_shared")
+	if libbaz.MaybeOutput("libbaz.api.json").Rule != nil {
+		t.Errorf("unexpected api description for libbaz")
+	}
+	libfooStatic := result.ModuleForTests("libfoo", "android_arm64_armv8-a_static")
+	if libfooStatic.MaybeOutput("libfoo.api.json").Rule != nil {
+		t.Errorf("unexpected api description for static variant")
+	}
+}
+
+func TestLibraryExportApiRequiresSymbolFile(t *testing.T) {
+	testCcError(t, `export_api: requires stubs.symbol_file or version_script`, `
+		cc_library_shared {
+			name: "libfoo",
+			srcs: ["foo.c"],
+			export_api: true,
+		}`)
+}
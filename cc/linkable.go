@@ -384,6 +384,17 @@ type FlagExporterInfo struct {
 
 var FlagExporterInfoProvider = blueprint.NewProvider(FlagExporterInfo{})
 
+// CompilerFlagsInfo is a provider to propagate the local compiler flags used to build a module's
+// sources, so that tools which parse the module's headers (e.g. bindgen) can use the same flags.
+type CompilerFlagsInfo struct {
+	CommonFlags []string      // Flags for C and C++ sources, including include paths and sanitizer flags.
+	ConlyFlags  []string      // Flags for C sources only, including -std.
+	CppFlags    []string      // Flags for C++ sources only, including -std.
+	Deps        android.Paths // Files depended on by the compiler flags, including generated headers.
+}
+
+var CompilerFlagsInfoProvider = blueprint.NewProvider(CompilerFlagsInfo{})
+
 // flagExporterInfoFromCcInfo populates FlagExporterInfo provider with information from Bazel.
 func flagExporterInfoFromCcInfo(ctx android.ModuleContext, ccInfo cquery.CcInfo) FlagExporterInfo {
 
@@ -16,6 +16,8 @@ package cc
 
 import (
 	"fmt"
+	"regexp"
+	"strings"
 
 	"android/soong/android"
 	"android/soong/cc/config"
@@ -29,6 +31,9 @@ import (
 
 const (
 	packRelocationsDefault = true
+
+	// The value of version_script that generates the version script from the symbol file.
+	versionScriptAuto = "auto"
 )
 
 type BaseLinkerProperties struct {
@@ -229,7 +234,10 @@ type BaseLinkerProperties struct {
 	// Generate compact dynamic relocation table, default true.
 	Pack_relocations *bool `android:"arch_variant"`
 
-	// local file name to pass to the linker as --version_script
+	// local file name to pass to the linker as --version_script, or "auto" for shared libraries
+	// with stubs to generate a version script from stubs.symbol_file that hides every symbol
+	// that isn't exported by the stubs.  The build fails when an "auto" version script exports
+	// symbols that aren't in stubs.exported_symbols_file, if it is set.
 	Version_script *string `android:"path,arch_variant"`
 
 	// local file name to pass to the linker as --dynamic-list
@@ -240,6 +248,85 @@ type BaseLinkerProperties struct {
 
 	// list of shared libs that should not be used to build this module
 	Exclude_shared_libs []string `android:"arch_variant"`
+
+	// list of symbols whose references are redirected to __wrap_<symbol> using -Wl,--wrap. The
+	// original definitions remain available as __real_<symbol>. The __wrap_ definitions are
+	// provided by wrap_symbols_lib, or by the sources of this module if it isn't set.
+	Wrap_symbols []string `android:"arch_variant"`
+
+	// module that defines the __wrap_ functions for wrap_symbols. Must also be listed in
+	// static_libs, whole_static_libs or shared_libs.
+	Wrap_symbols_lib *string `android:"arch_variant"`
+
+	// build a freestanding module, such as a bootloader or firmware, that doesn't use the C
+	// runtime of the target. Implies nocrt, no_libcrt, stl: "none" and system_shared_libs: [],
+	// and compiles with -ffreestanding. Only supported on device targets, and binaries must be
+	// static_executable.
+	Freestanding *bool `android:"arch_variant"`
+
+	// list of cc_object modules providing the startup code of this freestanding module, linked
+	// before its own objects in place of the default crtbegin objects.
+	Crt_begin []string `android:"arch_variant"`
+
+	// list of cc_object modules linked after the objects of this freestanding module in place
+	// of the default crtend objects.
+	Crt_end []string `android:"arch_variant"`
+
+	// the libc runtime linked into modules built for baremetal targets, which are always
+	// freestanding. Can be "picolibc" (the default), "llvm-libc" or "none".
+	Baremetal_libc *string `android:"arch_variant"`
+
+	// file listing symbols, one per line, that lld places first in the output in the listed
+	// order using -Wl,--symbol-ordering-file. Grouping the hot functions of a library together
+	// reduces the number of pages touched at startup.
+	Symbol_ordering_file *string `android:"path,arch_variant"`
+
+	// identical code folding mode passed to lld as -Wl,--icf: "safe" (the default on device
+	// targets), "all" or "none". "all" also folds functions whose address is taken, and is not
+	// supported together with CFI, whose jump tables rely on distinct function addresses.
+	Icf *string `android:"arch_variant"`
+
+	// style of the build id note written by the linker with -Wl,--build-id: "md5" (the default),
+	// "sha1", "fast", "uuid" or "none". "uuid" makes the output differ in every build, and "none"
+	// drops the note, which debuggers and crash reporters use to find the symbols. Defaults to the
+	// product's DefaultBuildIdStyle on device targets. Not supported on Darwin and Windows.
+	Build_id *string `android:"arch_variant"`
+
+	// remove unreferenced sections from the output with -Wl,--gc-sections. Defaults to true for
+	// binaries and shared libraries linked against bionic, and false otherwise.
+	Gc_sections *bool `android:"arch_variant"`
+
+	// list of symbols that are kept when unreferenced sections are removed. The symbols of a
+	// shared library that has a version script must be exported by it, which is checked when
+	// the library is linked.
+	Gc_sections_keep []string `android:"arch_variant"`
+
+	// let lld relax instruction sequences, such as GOT accesses on x86_64 or calls on riscv64,
+	// into shorter ones. Defaults to true; set to false for code that is patched at runtime.
+	Linker_relaxation *bool `android:"arch_variant"`
+
+	// allow text relocations in this module by linking it with -Wl,-z,notext, which is otherwise
+	// rejected in ldflags. lld refuses to create text relocations by default, as the dynamic
+	// linker refuses to load them. Device prebuilt binaries and shared libraries, which aren't
+	// linked by the build, are checked for text relocations instead unless this is set.
+	Allow_text_relocations *bool `android:"arch_variant"`
+
+	// generate the Breakpad symbol file of the unstripped binary or shared library, so that crash
+	// reports can be symbolized without a separate symbol pipeline. The symbol files of all the
+	// modules that set it are packaged into breakpad-symbols.zip, keyed by build id. Only supported
+	// on ELF targets.
+	Breakpad_symbols *bool `android:"arch_variant"`
+
+	// check the debug info of the objects of this module and of its static dependencies for
+	// structs and classes that are defined differently in different translation units. Such one
+	// definition rule violations are otherwise hard to root-cause when they break CFI or LTO.
+	// Only supported on binaries and shared libraries.
+	Odr_check *bool `android:"arch_variant"`
+
+	// check the link map of this module for shared_libs and static_libs that contributed no
+	// symbols to it, and list them in unused_deps.txt. The unused libraries are reported as errors
+	// when UNUSED_DEPS_CHECK_AS_ERRORS=true. Only supported on binaries and shared libraries.
+	Unused_deps_check *bool `android:"arch_variant"`
 }
 
 func invertBoolPtr(value *bool) *bool {
@@ -279,6 +366,19 @@ type baseLinker struct {
 		BuildStubs bool     `blueprint:"mutated"`
 	}
 
+	// The version script generated from the symbol file of the module when version_script is
+	// "auto", set by the module's linker before calling linkerFlags.
+	autoVersionScript android.OptionalPath
+
+	// The version script passed to the linker, set by linkerFlags.
+	versionScript android.OptionalPath
+
+	// The zip of the Breakpad symbol file of the module, set by breakpadSymbols.
+	breakpadSymbolsZip android.OptionalPath
+
+	// The memory usage printed by the linker for the stack usage report, set by memoryUsage.
+	memoryUsageFile android.OptionalPath
+
 	sanitize *sanitize
 }
 
@@ -293,6 +393,31 @@ func (linker *baseLinker) linkerInit(ctx BaseModuleContext) {
 	} else {
 		linker.dynamicProperties.RunPaths = append(linker.dynamicProperties.RunPaths, "../lib", "lib")
 	}
+
+	if ctx.Os() == android.Baremetal {
+		linker.Properties.Freestanding = BoolPtr(true)
+	} else if linker.Properties.Baremetal_libc != nil {
+		ctx.PropertyErrorf("baremetal_libc", "only supported for baremetal targets")
+	}
+
+	if linker.freestanding() {
+		if !ctx.Device() {
+			ctx.PropertyErrorf("freestanding", "only supported for device targets")
+		}
+		if len(linker.Properties.System_shared_libs) > 0 {
+			ctx.PropertyErrorf("system_shared_libs", "must be empty for freestanding modules")
+		}
+		linker.Properties.Nocrt = BoolPtr(true)
+		linker.Properties.No_libcrt = BoolPtr(true)
+		linker.Properties.System_shared_libs = []string{}
+	} else if len(linker.Properties.Crt_begin) > 0 || len(linker.Properties.Crt_end) > 0 {
+		ctx.PropertyErrorf("freestanding", "must be set to use crt_begin or crt_end")
+	}
+}
+
+// freestanding returns true if this module doesn't use the C runtime of the target.
+func (linker *baseLinker) freestanding() bool {
+	return Bool(linker.Properties.Freestanding)
 }
 
 func (linker *baseLinker) linkerProps() []interface{} {
@@ -305,6 +430,8 @@ func (linker *baseLinker) linkerDeps(ctx DepsContext, deps Deps) Deps {
 	deps.StaticLibs = append(deps.StaticLibs, linker.Properties.Static_libs...)
 	deps.SharedLibs = append(deps.SharedLibs, linker.Properties.Shared_libs...)
 	deps.RuntimeLibs = append(deps.RuntimeLibs, linker.Properties.Runtime_libs...)
+	deps.CrtBegin = append(deps.CrtBegin, linker.Properties.Crt_begin...)
+	deps.CrtEnd = append(deps.CrtEnd, linker.Properties.Crt_end...)
 
 	deps.ReexportHeaderLibHeaders = append(deps.ReexportHeaderLibHeaders, linker.Properties.Export_header_lib_headers...)
 	deps.ReexportStaticLibHeaders = append(deps.ReexportStaticLibHeaders, linker.Properties.Export_static_lib_headers...)
@@ -434,10 +561,30 @@ func (linker *baseLinker) linkerDeps(ctx DepsContext, deps Deps) Deps {
 		if !Bool(linker.Properties.No_libcrt) && !ctx.header() {
 			deps.LateStaticLibs = append(deps.LateStaticLibs, config.BuiltinsRuntimeLibrary(ctx.toolchain()))
 		}
+	} else if ctx.Os() == android.Baremetal {
+		libc := proptools.StringDefault(linker.Properties.Baremetal_libc, config.BaremetalDefaultLibc)
+		if module, ok := config.BaremetalLibcs[libc]; ok {
+			// The libc runtime must not depend on itself.
+			if ctx.ModuleName() != module {
+				deps.LateStaticLibs = append(deps.LateStaticLibs, module)
+			}
+		} else if libc != "none" {
+			ctx.PropertyErrorf("baremetal_libc", "unknown libc %q, must be one of %q or \"none\"",
+				libc, android.SortedStringKeys(config.BaremetalLibcs))
+		}
 	}
 
 	deps.LateSharedLibs = append(deps.LateSharedLibs, deps.SystemSharedLibs...)
 
+	if lib := String(linker.Properties.Wrap_symbols_lib); lib != "" {
+		if len(linker.Properties.Wrap_symbols) == 0 {
+			ctx.PropertyErrorf("wrap_symbols_lib", "can only be set together with wrap_symbols")
+		} else if !inList(lib, deps.StaticLibs) && !inList(lib, deps.WholeStaticLibs) && !inList(lib, deps.SharedLibs) {
+			ctx.PropertyErrorf("wrap_symbols_lib",
+				"%q must be listed in static_libs, whole_static_libs or shared_libs", lib)
+		}
+	}
+
 	if ctx.Windows() && ctx.ModuleName() != "libwinpthread" {
 		deps.LateStaticLibs = append(deps.LateStaticLibs, "libwinpthread")
 	}
@@ -445,6 +592,19 @@ func (linker *baseLinker) linkerDeps(ctx DepsContext, deps Deps) Deps {
 	return deps
 }
 
+// addSymbolOrderingFile adds the linker flags to order symbols with the given file. Symbols in the
+// file may be missing from the output, e.g. when they are inlined or garbage collected, so the
+// warnings for them are disabled.
+func addSymbolOrderingFile(flags Flags, orderingFile android.Path) Flags {
+	flags.Local.LdFlags = append(flags.Local.LdFlags,
+		"-Wl,--symbol-ordering-file="+orderingFile.String(),
+		"-Wl,--no-warn-symbol-ordering")
+	flags.LdFlagsDeps = append(flags.LdFlagsDeps, orderingFile)
+	return flags
+}
+
+var validSymbolRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.]*$`)
+
 func (linker *baseLinker) useClangLld(ctx ModuleContext) bool {
 	// Clang lld is not ready for for Darwin host executables yet.
 	// See https://lld.llvm.org/AtomLLD.html for status of lld for Mach-O.
@@ -548,6 +708,94 @@ func (linker *baseLinker) linkerFlags(ctx ModuleContext, flags Flags) Flags {
 
 	flags.Local.LdFlags = append(flags.Local.LdFlags, proptools.NinjaAndShellEscapeList(linker.Properties.Ldflags)...)
 
+	if orderingFile := ctx.ExpandOptionalSource(linker.Properties.Symbol_ordering_file, "symbol_ordering_file"); orderingFile.Valid() {
+		if !linker.useClangLld(ctx) {
+			ctx.PropertyErrorf("symbol_ordering_file", "only supported when linking with lld")
+		}
+		flags = addSymbolOrderingFile(flags, orderingFile.Path())
+	}
+
+	if len(linker.Properties.Wrap_symbols) > 0 {
+		if ctx.Darwin() {
+			ctx.PropertyErrorf("wrap_symbols", "Not supported on Darwin")
+		}
+		for _, symbol := range linker.Properties.Wrap_symbols {
+			if !validSymbolRegex.MatchString(symbol) {
+				ctx.PropertyErrorf("wrap_symbols", "%q is not a valid symbol name", symbol)
+				continue
+			}
+			flags.Local.LdFlags = append(flags.Local.LdFlags, "-Wl,--wrap="+symbol)
+		}
+	}
+
+	if icf := String(linker.Properties.Icf); icf != "" {
+		if !linker.useClangLld(ctx) {
+			ctx.PropertyErrorf("icf", "only supported when linking with lld")
+		} else if !inList(icf, []string{"safe", "all", "none"}) {
+			ctx.PropertyErrorf("icf", "must be \"safe\", \"all\" or \"none\", got %q", icf)
+		} else if icf == "all" && linker.sanitize.isSanitizerEnabled(cfi) {
+			ctx.PropertyErrorf("icf", "\"all\" is not supported with CFI, use \"safe\"")
+		} else {
+			flags.Local.LdFlags = append(flags.Local.LdFlags, "-Wl,--icf="+icf)
+		}
+	}
+
+	if style, property := linker.buildIdStyle(ctx); style != "" {
+		if ctx.Darwin() || ctx.Windows() {
+			if property {
+				ctx.PropertyErrorf("build_id", "not supported on Darwin and Windows")
+			}
+		} else if !inList(style, buildIdStyles) {
+			if property {
+				ctx.PropertyErrorf("build_id", "must be one of %s, got %q", strings.Join(buildIdStyles, ", "), style)
+			} else {
+				ctx.ModuleErrorf("DefaultBuildIdStyle must be one of %s, got %q", strings.Join(buildIdStyles, ", "), style)
+			}
+		} else {
+			// Appended after the global -Wl,--build-id=md5, so that it takes precedence.
+			flags.Local.LdFlags = append(flags.Local.LdFlags, "-Wl,--build-id="+style)
+		}
+	}
+
+	if linker.Properties.Gc_sections != nil {
+		if ctx.Darwin() {
+			ctx.PropertyErrorf("gc_sections", "Not supported on Darwin")
+		} else if Bool(linker.Properties.Gc_sections) {
+			flags.Local.LdFlags = append(flags.Local.LdFlags, "-Wl,--gc-sections")
+		} else {
+			flags.Local.LdFlags = append(flags.Local.LdFlags, "-Wl,--no-gc-sections")
+		}
+	}
+
+	if len(linker.Properties.Gc_sections_keep) > 0 {
+		if !BoolDefault(linker.Properties.Gc_sections, ctx.toolchain().Bionic()) {
+			ctx.PropertyErrorf("gc_sections_keep", "can only be set when gc_sections is enabled")
+		}
+		for _, symbol := range linker.Properties.Gc_sections_keep {
+			if !validSymbolRegex.MatchString(symbol) {
+				ctx.PropertyErrorf("gc_sections_keep", "%q is not a valid symbol name", symbol)
+				continue
+			}
+			flags.Local.LdFlags = append(flags.Local.LdFlags, "-Wl,--undefined="+symbol)
+		}
+	}
+
+	if linker.Properties.Linker_relaxation != nil && !Bool(linker.Properties.Linker_relaxation) {
+		if !linker.useClangLld(ctx) {
+			ctx.PropertyErrorf("linker_relaxation", "only supported when linking with lld")
+		} else {
+			flags.Local.LdFlags = append(flags.Local.LdFlags, "-Wl,--no-relax")
+		}
+	}
+
+	if Bool(linker.Properties.Allow_text_relocations) {
+		if !ctx.Device() {
+			ctx.PropertyErrorf("allow_text_relocations", "only supported on device targets")
+		} else {
+			flags.Local.LdFlags = append(flags.Local.LdFlags, "-Wl,-z,notext")
+		}
+	}
+
 	if ctx.Host() && !ctx.Windows() {
 		rpathPrefix := `\$$ORIGIN/`
 		if ctx.Darwin() {
@@ -573,8 +821,16 @@ func (linker *baseLinker) linkerFlags(ctx ModuleContext, flags Flags) Flags {
 	// Version_script is not needed when linking stubs lib where the version
 	// script is created from the symbol map file.
 	if !linker.dynamicProperties.BuildStubs {
-		versionScript := ctx.ExpandOptionalSource(
-			linker.Properties.Version_script, "version_script")
+		var versionScript android.OptionalPath
+		if String(linker.Properties.Version_script) == versionScriptAuto {
+			versionScript = linker.autoVersionScript
+			if ctx.binary() {
+				ctx.PropertyErrorf("version_script", "%q is only supported by shared libraries", versionScriptAuto)
+			}
+		} else {
+			versionScript = ctx.ExpandOptionalSource(
+				linker.Properties.Version_script, "version_script")
+		}
 
 		if ctx.inVendor() && linker.Properties.Target.Vendor.Version_script != nil {
 			versionScript = ctx.ExpandOptionalSource(
@@ -590,6 +846,7 @@ func (linker *baseLinker) linkerFlags(ctx ModuleContext, flags Flags) Flags {
 			if ctx.Darwin() {
 				ctx.PropertyErrorf("version_script", "Not supported on Darwin")
 			} else {
+				linker.versionScript = versionScript
 				flags.Local.LdFlags = append(flags.Local.LdFlags,
 					"-Wl,--version-script,"+versionScript.String())
 				flags.LdFlagsDeps = append(flags.LdFlagsDeps, versionScript.Path())
@@ -637,6 +894,139 @@ func (linker *baseLinker) linkerSpecifiedDeps(specifiedDeps specifiedDeps) speci
 	return specifiedDeps
 }
 
+// odrCheck returns the stamp file of the one definition rule check of the objects linked into
+// the module when odr_check is set, to be used as a validation of the link.
+func (linker *baseLinker) odrCheck(ctx ModuleContext, deps PathDeps, objs Objects) android.Paths {
+	if !Bool(linker.Properties.Odr_check) {
+		return nil
+	}
+	inputs := append(android.Paths{}, objs.objFiles...)
+	inputs = append(inputs, deps.WholeStaticLibs...)
+	inputs = append(inputs, deps.StaticLibs...)
+	inputs = append(inputs, deps.LateStaticLibs...)
+	return android.Paths{transformObjsToOdrCheck(ctx, android.FirstUniquePaths(inputs))}
+}
+
+// unusedDepsCheck adds a link map to the link of the module when unused_deps_check is set, and
+// returns it as an implicit output of the link along with the report of the unused libraries, to
+// be used as a validation of the link.
+func (linker *baseLinker) unusedDepsCheck(ctx ModuleContext, flags *builderFlags,
+	linked android.Path) (android.WritablePaths, android.Paths) {
+	if !Bool(linker.Properties.Unused_deps_check) || ctx.Darwin() || ctx.Windows() {
+		return nil, nil
+	}
+	linkMap := android.PathForModuleOut(ctx, "link.map")
+	flags.localLdFlags += " -Wl,-Map=" + linkMap.String()
+
+	// Only check the libraries listed by the module, not the ones added implicitly like the STL.
+	// The libraries are matched by the modules they come from, as their file names may differ
+	// from the names of the modules, e.g. when they have a stem.
+	declaredNames := func(names []string) map[string]bool {
+		ret := make(map[string]bool)
+		for _, name := range names {
+			name, _ = StubsLibNameAndVersion(name)
+			ret[name] = true
+		}
+		return ret
+	}
+	sharedNames := declaredNames(linker.Properties.Shared_libs)
+	staticNames := declaredNames(linker.Properties.Static_libs)
+	apexInfo := ctx.Provider(android.ApexInfoProvider).(android.ApexInfo)
+
+	var sharedLibs, staticLibs android.Paths
+	ctx.VisitDirectDeps(func(dep android.Module) {
+		tag, ok := ctx.OtherModuleDependencyTag(dep).(libraryDependencyTag)
+		if !ok || tag.Order != normalLibraryDependency || tag.staticUnwinder ||
+			(tag.excludeInApex && !apexInfo.IsForPlatform()) {
+			return
+		}
+		name := android.RemoveOptionalPrebuiltPrefix(ctx.OtherModuleName(dep))
+		switch {
+		case tag.shared() && sharedNames[name] && ctx.OtherModuleHasProvider(dep, SharedLibraryInfoProvider):
+			sharedLibraryInfo, _ := ChooseStubOrImpl(ctx, dep)
+			sharedLibs = append(sharedLibs, sharedLibraryInfo.SharedLibrary)
+		case tag.static() && !tag.wholeStatic && staticNames[name] &&
+			ctx.OtherModuleHasProvider(dep, StaticLibraryInfoProvider):
+			staticLibraryInfo := ctx.OtherModuleProvider(dep, StaticLibraryInfoProvider).(StaticLibraryInfo)
+			staticLibs = append(staticLibs, staticLibraryInfo.StaticLibrary)
+		}
+	})
+
+	report := transformLinkMapToUnusedDepsReport(ctx, linkMap, linked, sharedLibs, staticLibs)
+	return android.WritablePaths{linkMap}, android.Paths{report}
+}
+
+// memoryUsage makes the link of a module built with stack_usage_report save the usage of the
+// memory regions of the linker script, which bounds the stack of bare-metal binaries.
+func (linker *baseLinker) memoryUsage(ctx ModuleContext, flags *builderFlags) {
+	if !flags.stackUsage || ctx.Darwin() || ctx.Windows() {
+		return
+	}
+	memoryUsageFile := android.PathForModuleOut(ctx, "memory_usage.txt")
+	flags.memoryUsageFile = memoryUsageFile
+	linker.memoryUsageFile = android.OptionalPathForPath(memoryUsageFile)
+}
+
+func (linker *baseLinker) memoryUsageFilePath() android.OptionalPath {
+	return linker.memoryUsageFile
+}
+
+// ifuncCheck returns the stamp file of the check that the linked output of the module doesn't
+// define ifuncs when it is built with asan or hwasan, to be used as a validation of the link.
+func ifuncCheck(ctx ModuleContext, linked android.Path) android.Paths {
+	if !ctx.isIfuncCheckEnabled() {
+		return nil
+	}
+	return android.Paths{transformLinkedToIfuncCheck(ctx, linked)}
+}
+
+// gcSectionsKeepCheck returns the stamp file of the check that the gc_sections_keep symbols of a
+// shared library are exported by its version script, to be used as a validation of the link.
+func (linker *baseLinker) gcSectionsKeepCheck(ctx ModuleContext, linked android.Path) android.Paths {
+	if len(linker.Properties.Gc_sections_keep) == 0 || !linker.versionScript.Valid() {
+		return nil
+	}
+	return android.Paths{transformSharedLibToGcSectionsKeepCheck(ctx, linked,
+		linker.versionScript.Path(), linker.Properties.Gc_sections_keep)}
+}
+
+// textRelocationsCheck returns the stamp file of the check that a prebuilt binary or shared
+// library of a device module has no text relocations unless allow_text_relocations is set, to be
+// used as a validation of the copy of the prebuilt.
+func (linker *baseLinker) textRelocationsCheck(ctx ModuleContext, linked android.Path) android.Paths {
+	if !ctx.Device() || Bool(linker.Properties.Allow_text_relocations) {
+		return nil
+	}
+	return android.Paths{transformLinkedToTextRelocationsCheck(ctx, linked)}
+}
+
+var buildIdStyles = []string{"md5", "sha1", "fast", "uuid", "none"}
+
+// buildIdStyle returns the build id style of the module, and whether it was set by the build_id
+// property rather than by the product default, or "" to keep the toolchain default.
+func (linker *baseLinker) buildIdStyle(ctx ModuleContext) (string, bool) {
+	if linker.Properties.Build_id != nil {
+		return String(linker.Properties.Build_id), true
+	}
+	if ctx.Device() {
+		return ctx.Config().DefaultBuildIdStyle(), false
+	}
+	return "", false
+}
+
+// breakpadSymbols generates the Breakpad symbol file of the unstripped output of the module when
+// breakpad_symbols is set.
+func (linker *baseLinker) breakpadSymbols(ctx ModuleContext, unstripped android.Path) {
+	if !Bool(linker.Properties.Breakpad_symbols) || ctx.Darwin() || ctx.Windows() {
+		return
+	}
+	linker.breakpadSymbolsZip = android.OptionalPathForPath(transformUnstrippedToBreakpadSymbols(ctx, unstripped))
+}
+
+func (linker *baseLinker) breakpadSymbolsZipPath() android.OptionalPath {
+	return linker.breakpadSymbolsZip
+}
+
 // Injecting version symbols
 // Some host modules want a version number, but we don't want to rebuild it every time.  Optionally add a step
 // after linking that injects a constant placeholder with the current version number.
@@ -14,6 +14,14 @@
 
 package cc
 
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"android/soong/android"
+)
+
 var (
 	llndkLibrarySuffix = ".llndk"
 	llndkHeadersSuffix = ".llndk"
@@ -25,6 +33,16 @@ type llndkLibraryProperties struct {
 	// An example file can be seen here: TODO(danalbert): Make an example.
 	Symbol_file *string
 
+	// Symbols exported by the LLNDK stubs, used to generate the symbol map instead of listing
+	// them in symbol_file.  A symbol that was added after the first VNDK version of the library
+	// is suffixed with the version it was introduced in, for example "foo_init" and "foo_new:33".
+	Symbols []string
+
+	// Headers that the LLNDK variant exports, relative to the Blueprints file.  Each header must be
+	// in one of the exported include directories, the other headers in these directories are
+	// hidden from the modules that link against the LLNDK variant.
+	Export_headers []string
+
 	// Whether to export any headers as -isystem instead of -I. Mainly for use by
 	// bionic/libc.
 	Export_headers_as_system *bool
@@ -55,3 +73,96 @@ type llndkLibraryProperties struct {
 	// llndk.symbol_file.
 	Llndk_headers *bool
 }
+
+// llndkSymbolFile returns the symbol map of the LLNDK stubs, generating it from llndk.symbols if it
+// is set.
+func llndkSymbolFile(ctx ModuleContext, props *llndkLibraryProperties) android.Path {
+	if len(props.Symbols) == 0 {
+		return android.PathForModuleSrc(ctx, String(props.Symbol_file))
+	}
+	if props.Symbol_file != nil {
+		ctx.PropertyErrorf("llndk.symbols", "cannot be set together with llndk.symbol_file")
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%s {\n", llndkVersionTag(ctx.baseModuleName()))
+	sb.WriteString("  global:\n")
+	for _, symbol := range props.Symbols {
+		s := strings.SplitN(symbol, ":", 2)
+		name := s[0]
+		if name == "" {
+			ctx.PropertyErrorf("llndk.symbols", "invalid symbol %q", symbol)
+			continue
+		}
+		if len(s) == 1 {
+			fmt.Fprintf(&sb, "    %s; # llndk\n", name)
+			continue
+		}
+		apiLevel, err := android.ApiLevelFromUser(ctx, s[1])
+		if err != nil {
+			ctx.PropertyErrorf("llndk.symbols", "invalid version of %q: %s", name, err)
+			continue
+		}
+		fmt.Fprintf(&sb, "    %s; # llndk introduced=%s\n", name, apiLevel)
+	}
+	sb.WriteString("  local:\n")
+	sb.WriteString("    *;\n")
+	// WriteFileRule adds the final newline.
+	sb.WriteString("};")
+
+	symbolFile := android.PathForModuleGen(ctx, "llndk.map.txt")
+	android.WriteFileRule(ctx, symbolFile, sb.String())
+	return symbolFile
+}
+
+// llndkVersionTag returns the name of the version node of the generated symbol map, e.g. LIBFOO
+// for libfoo.
+func llndkVersionTag(name string) string {
+	return strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return '_'
+	}, strings.ToUpper(name))
+}
+
+// filterLLNDKHeaders copies the headers listed in llndk.export_headers into generated include
+// directories, keeping their paths relative to the exported include directory they are in.  The
+// headers in the exported system include directories are copied into systemOutDir.
+func filterLLNDKHeaders(ctx ModuleContext, headers, includeDirs, systemIncludeDirs []string,
+	outDir, systemOutDir android.ModuleGenPath) (filtered, filteredSystem android.Paths) {
+
+	for _, header := range headers {
+		var out android.WritablePath
+		if rel, ok := llndkHeaderRel(header, includeDirs); ok {
+			out = outDir.Join(ctx, rel)
+			filtered = append(filtered, out)
+		} else if rel, ok := llndkHeaderRel(header, systemIncludeDirs); ok {
+			out = systemOutDir.Join(ctx, rel)
+			filteredSystem = append(filteredSystem, out)
+		} else {
+			ctx.PropertyErrorf("llndk.export_headers", "%q is not in any of the exported include directories %q",
+				header, append(append([]string(nil), includeDirs...), systemIncludeDirs...))
+			continue
+		}
+
+		ctx.Build(pctx, android.BuildParams{
+			Rule:        android.Cp,
+			Description: "copy llndk header " + header,
+			Input:       android.PathForModuleSrc(ctx, header),
+			Output:      out,
+		})
+	}
+	return filtered, filteredSystem
+}
+
+// llndkHeaderRel returns the path of the header relative to the first of the include directories
+// it is in.
+func llndkHeaderRel(header string, includeDirs []string) (string, bool) {
+	for _, dir := range includeDirs {
+		if r, err := filepath.Rel(dir, header); err == nil && !strings.HasPrefix(r, "../") && r != ".." {
+			return r, true
+		}
+	}
+	return "", false
+}
@@ -15,6 +15,8 @@
 package cc
 
 import (
+	"encoding/json"
+
 	"github.com/google/blueprint/proptools"
 
 	"android/soong/android"
@@ -83,10 +85,16 @@ func (lto *lto) useClangLld(ctx BaseModuleContext) bool {
 	return true
 }
 
-func (lto *lto) flags(ctx BaseModuleContext, flags Flags) Flags {
+// ltoDisabledByFlags returns true if LTO is disabled for a module that would otherwise use it
+// because of its flags.
+func ltoDisabledByFlags(flags Flags) bool {
 	// TODO(b/131771163): Disable LTO when using explicit fuzzing configurations.
 	// LTO breaks fuzzer builds.
-	if inList("-fsanitize=fuzzer-no-link", flags.Local.CFlags) {
+	return inList("-fsanitize=fuzzer-no-link", flags.Local.CFlags)
+}
+
+func (lto *lto) flags(ctx BaseModuleContext, flags Flags) Flags {
+	if ltoDisabledByFlags(flags) {
 		return flags
 	}
 
@@ -96,7 +104,8 @@ func (lto *lto) flags(ctx BaseModuleContext, flags Flags) Flags {
 		if lto.ThinLTO() {
 			// TODO(b/129607781) sdclang does not currently support
 			// the "-fsplit-lto-unit" option
-			if flags.Sdclang && !strings.Contains(config.SDClangPath, "9.0") {
+			if flags.ToolchainFlavor != nil && flags.ToolchainFlavor.Name == config.SDClangToolchainFlavor &&
+				!strings.Contains(config.SDClangPath, "9.0") {
 				ltoCFlag = "-flto=thin"
 			} else {
 				ltoCFlag = "-flto=thin -fsplit-lto-unit"
@@ -165,6 +174,17 @@ func GlobalThinLTO(ctx android.BaseModuleContext) bool {
 	return ctx.Config().IsEnvTrue("GLOBAL_THINLTO")
 }
 
+// SaveLtoBitcode returns true if the bitcode objects of the modules built with LTO should be
+// collected into lto-bitcode.zip for offline whole-program analysis.
+func SaveLtoBitcode(config android.Config) bool {
+	return config.IsEnvTrue("SAVE_LTO_BITCODE")
+}
+
+// savesBitcode returns true if the objects of the module contain bitcode that should be saved.
+func (lto *lto) savesBitcode(ctx BaseModuleContext, flags Flags) bool {
+	return lto != nil && SaveLtoBitcode(ctx.Config()) && lto.LTO(ctx) && !ltoDisabledByFlags(flags)
+}
+
 // Propagate lto requirements down from binaries
 func ltoDepsMutator(mctx android.TopDownMutatorContext) {
 	globalThinLTO := GlobalThinLTO(mctx)
@@ -271,3 +291,82 @@ func ltoMutator(mctx android.BottomUpMutatorContext) {
 		}
 	}
 }
+
+func ltoBitcodeSingletonFactory() android.Singleton {
+	return &ltoBitcodeSingleton{}
+}
+
+// ltoBitcodeSingleton collects the bitcode objects of all the modules built with LTO into
+// lto-bitcode.zip when SAVE_LTO_BITCODE is set, so that offline whole-program analysis tools can
+// consume the exact IR that was used by the build.  The zip contains the objects at their paths
+// relative to the intermediates directory, and lto-bitcode-index.json, which lists the objects
+// of each variant of each module.  Objects assembled from assembly sources contain native code
+// rather than bitcode.
+type ltoBitcodeSingleton struct {
+	outputFile android.WritablePath
+}
+
+type ltoBitcodeIndexEntry struct {
+	Module  string   `json:"module"`
+	Variant string   `json:"variant"`
+	Objects []string `json:"objects"`
+}
+
+func (s *ltoBitcodeSingleton) GenerateBuildActions(ctx android.SingletonContext) {
+	if !SaveLtoBitcode(ctx.Config()) {
+		return
+	}
+
+	intermediates := android.PathForOutput(ctx, ".intermediates")
+
+	var index []ltoBitcodeIndexEntry
+	var objects android.Paths
+	ctx.VisitAllModules(func(module android.Module) {
+		if ccModule, ok := module.(*Module); ok && len(ccModule.ltoBitcodeFiles) > 0 {
+			entry := ltoBitcodeIndexEntry{
+				Module:  ctx.ModuleName(module),
+				Variant: ctx.ModuleSubDir(module),
+			}
+			for _, obj := range ccModule.ltoBitcodeFiles {
+				rel, isRel := android.MaybeRel(ctx, intermediates.String(), obj.String())
+				if !isRel {
+					ctx.ModuleErrorf(module, "bitcode object %q is not in %q", obj, intermediates)
+					return
+				}
+				entry.Objects = append(entry.Objects, rel)
+			}
+			index = append(index, entry)
+			objects = append(objects, ccModule.ltoBitcodeFiles...)
+		}
+	})
+
+	if len(index) == 0 {
+		return
+	}
+
+	content, err := json.MarshalIndent(index, "", "  ")
+	if err != nil {
+		ctx.Errorf("failed to marshal lto-bitcode-index.json: %s", err)
+		return
+	}
+	indexFile := android.PathForOutput(ctx, "lto-bitcode", "lto-bitcode-index.json")
+	android.WriteFileRule(ctx, indexFile, string(content))
+
+	s.outputFile = android.PathForOutput(ctx, "lto-bitcode.zip")
+	rule := android.NewRuleBuilder(pctx, ctx)
+	rule.Command().BuiltTool("soong_zip").
+		FlagWithOutput("-o ", s.outputFile).
+		FlagWithArg("-C ", indexFile.Dir().String()).
+		FlagWithInput("-f ", indexFile).
+		FlagWithArg("-C ", intermediates.String()).
+		FlagWithRspFileInputList("-r ", android.PathForOutput(ctx, "lto-bitcode", "lto-bitcode.rsp"), objects)
+	rule.Build("lto_bitcode_zip", "lto-bitcode.zip")
+
+	ctx.Phony("lto-bitcode", s.outputFile)
+}
+
+func (s *ltoBitcodeSingleton) MakeVars(ctx android.MakeVarsContext) {
+	if s.outputFile != nil {
+		ctx.DistForGoal("lto-bitcode", s.outputFile)
+	}
+}
@@ -0,0 +1,89 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cc
+
+import (
+	"encoding/json"
+	"testing"
+
+	"android/soong/android"
+)
+
+func TestSaveLtoBitcode(t *testing.T) {
+	bp := `
+	cc_library_shared {
+		name: "libTest",
+		srcs: ["foo.c"],
+		lto: {
+			thin: true,
+		},
+	}
+
+	cc_library_shared {
+		name: "libNoLto",
+		srcs: ["bar.c"],
+	}
+	`
+
+	t.Run("disabled", func(t *testing.T) {
+		result := prepareForCcTest.RunTestWithBp(t, bp)
+
+		zip := result.SingletonForTests("lto_bitcode").MaybeOutput("lto-bitcode.zip")
+		if zip.Rule != nil {
+			t.Errorf("expected lto-bitcode.zip not to be generated")
+		}
+	})
+
+	t.Run("enabled", func(t *testing.T) {
+		result := android.GroupFixturePreparers(
+			prepareForCcTest,
+			android.FixtureMergeEnv(map[string]string{
+				"SAVE_LTO_BITCODE": "true",
+			}),
+		).RunTestWithBp(t, bp)
+
+		singleton := result.SingletonForTests("lto_bitcode")
+		zip := singleton.Output("lto-bitcode.zip")
+		inputs := android.PathsRelativeToTop(zip.Implicits)
+		for _, input := range []string{
+			"out/soong/lto-bitcode/lto-bitcode-index.json",
+			"out/soong/.intermediates/libTest/android_arm64_armv8-a_shared/obj/foo.o",
+			"out/soong/.intermediates/libTest/android_arm_armv7-a-neon_shared/obj/foo.o",
+		} {
+			android.AssertStringListContains(t, "lto-bitcode.zip inputs", inputs, input)
+		}
+		android.AssertStringListDoesNotContain(t, "lto-bitcode.zip inputs", inputs,
+			"out/soong/.intermediates/libNoLto/android_arm64_armv8-a_shared/obj/bar.o")
+
+		var index []ltoBitcodeIndexEntry
+		content := android.ContentFromFileRuleForTests(t, singleton.Output("lto-bitcode/lto-bitcode-index.json"))
+		if err := json.Unmarshal([]byte(content), &index); err != nil {
+			t.Fatalf("failed to parse lto-bitcode-index.json: %s", err)
+		}
+
+		android.AssertDeepEquals(t, "lto-bitcode-index.json", []ltoBitcodeIndexEntry{
+			{
+				Module:  "libTest",
+				Variant: "android_arm64_armv8-a_shared",
+				Objects: []string{"libTest/android_arm64_armv8-a_shared/obj/foo.o"},
+			},
+			{
+				Module:  "libTest",
+				Variant: "android_arm_armv7-a-neon_shared",
+				Objects: []string{"libTest/android_arm_armv7-a-neon_shared/obj/foo.o"},
+			},
+		}, index)
+	})
+}
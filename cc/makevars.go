@@ -119,8 +119,12 @@ func makeVarsProvider(ctx android.MakeVarsContext) {
 	sort.Strings(exportedVendorPublicLibraries)
 	ctx.Strict("VENDOR_PUBLIC_LIBRARIES", strings.Join(exportedVendorPublicLibraries, " "))
 
-	sort.Strings(lsdumpPaths)
-	ctx.Strict("LSDUMP_PATHS", strings.Join(lsdumpPaths, " "))
+	// The make vars providers run concurrently, sort a copy of the shared list under its lock.
+	lsdumpPathsLock.Lock()
+	sortedLsdumpPaths := android.CopyOf(lsdumpPaths)
+	sort.Strings(sortedLsdumpPaths)
+	lsdumpPathsLock.Unlock()
+	ctx.Strict("LSDUMP_PATHS", strings.Join(sortedLsdumpPaths, " "))
 
 	ctx.Strict("ANDROID_WARNING_ALLOWED_PROJECTS", makeStringOfWarningAllowedProjects())
 	ctx.Strict("SOONG_MODULES_ADDED_WALL", makeStringOfKeys(ctx, modulesAddedWallKey))
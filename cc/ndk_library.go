@@ -255,10 +255,16 @@ type ndkApiOutputs struct {
 
 func parseNativeAbiDefinition(ctx ModuleContext, symbolFile string,
 	apiLevel android.ApiLevel, genstubFlags string) ndkApiOutputs {
+	return parseNativeAbiDefinitionFromPath(ctx, android.PathForModuleSrc(ctx, symbolFile), apiLevel, genstubFlags)
+}
+
+// parseNativeAbiDefinitionFromPath is parseNativeAbiDefinition for a symbol file that may be
+// generated.
+func parseNativeAbiDefinitionFromPath(ctx ModuleContext, symbolFilePath android.Path,
+	apiLevel android.ApiLevel, genstubFlags string) ndkApiOutputs {
 
 	stubSrcPath := android.PathForModuleGen(ctx, "stub.c")
 	versionScriptPath := android.PathForModuleGen(ctx, "stub.map")
-	symbolFilePath := android.PathForModuleSrc(ctx, symbolFile)
 	symbolListPath := android.PathForModuleGen(ctx, "abi_symbol_list.txt")
 	apiLevelsJson := android.GetApiLevelsJson(ctx)
 	ctx.Build(pctx, android.BuildParams{
@@ -177,6 +177,7 @@ func (p *prebuiltLibraryLinker) link(ctx ModuleContext,
 				Implicits:   implicits,
 				Input:       in,
 				Output:      outputFile,
+				Validations: p.textRelocationsCheck(ctx, p.unstrippedOutputFile),
 				Args: map[string]string{
 					"cpFlags": "-L",
 				},
@@ -632,6 +633,7 @@ func (p *prebuiltBinaryLinker) link(ctx ModuleContext,
 				Description: "prebuilt",
 				Output:      outputFile,
 				Input:       in,
+				Validations: p.textRelocationsCheck(ctx, p.unstrippedOutputFile),
 			})
 		}
 
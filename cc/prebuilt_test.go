@@ -273,6 +273,52 @@ func TestPrebuiltLibrarySharedStem(t *testing.T) {
 	assertString(t, shared.OutputFile().Path().Base(), "libbar.so")
 }
 
+func TestPrebuiltTextRelocationsCheck(t *testing.T) {
+	ctx := testPrebuilt(t, `
+	cc_prebuilt_library_shared {
+		name: "libfoo",
+		srcs: ["libfoo.so"],
+		host_supported: true,
+	}
+
+	cc_prebuilt_library_shared {
+		name: "libtextrel",
+		srcs: ["libtextrel.so"],
+		allow_text_relocations: true,
+	}
+
+	cc_prebuilt_binary {
+		name: "foo",
+		srcs: ["foo"],
+	}
+	`, map[string][]byte{
+		"libfoo.so":     nil,
+		"libtextrel.so": nil,
+		"foo":           nil,
+	})
+
+	libfoo := ctx.ModuleForTests("libfoo", "android_arm64_armv8-a_shared")
+	check := libfoo.Output("text_relocations_check.stamp")
+	android.AssertPathRelativeToTopEquals(t, "check input", "libfoo.so", check.Input)
+	android.AssertPathsRelativeToTopEquals(t, "validations",
+		[]string{android.PathRelativeToTop(check.Output)}, libfoo.Output("libfoo.so").Validations)
+
+	foo := ctx.ModuleForTests("foo", "android_arm64_armv8-a")
+	check = foo.Output("text_relocations_check.stamp")
+	android.AssertPathsRelativeToTopEquals(t, "validations",
+		[]string{android.PathRelativeToTop(check.Output)}, foo.Output("foo").Validations)
+
+	hostVariant := ctx.Config().BuildOSTarget.String() + "_shared"
+	if ctx.ModuleForTests("libfoo", hostVariant).MaybeOutput("text_relocations_check.stamp").Rule != nil {
+		t.Errorf("unexpected text relocations check for host variant")
+	}
+
+	libtextrel := ctx.ModuleForTests("libtextrel", "android_arm64_armv8-a_shared")
+	if libtextrel.MaybeOutput("text_relocations_check.stamp").Rule != nil {
+		t.Errorf("unexpected text relocations check with allow_text_relocations: true")
+	}
+}
+
 func TestPrebuiltSymlinkedHostBinary(t *testing.T) {
 	if runtime.GOOS != "linux" {
 		t.Skipf("Skipping host prebuilt testing that is only supported on linux not %s", runtime.GOOS)
@@ -0,0 +1,141 @@
+// Copyright 2022 The Android Open Source Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cc
+
+import (
+	"strings"
+
+	"github.com/google/blueprint/proptools"
+
+	"android/soong/android"
+)
+
+func init() {
+	RegisterPythonCextBuildComponents(android.InitRegistrationContext)
+}
+
+func RegisterPythonCextBuildComponents(ctx android.RegistrationContext) {
+	ctx.RegisterModuleType("python_cext", PythonCextFactory)
+}
+
+// DefaultHermeticPythonVersion is the hermetic CPython version used by python_cext modules and by
+// Python modules with embedded_launcher when they don't select one.
+const DefaultHermeticPythonVersion = "3.10"
+
+// HermeticPythonVersions maps the supported hermetic CPython versions to the name prefix of the
+// modules built from that version's sources: the headers, e.g. py3.11-headers, and the launcher and
+// standard library used by Python modules with embedded_launcher.
+var HermeticPythonVersions = map[string]string{
+	"3.10": "py3",
+	"3.11": "py3.11",
+}
+
+// pythonCextArchs maps architectures to the names used for them in CPython's platform tags.
+var pythonCextArchs = map[android.ArchType]string{
+	android.Arm:    "arm",
+	android.Arm64:  "aarch64",
+	android.X86:    "i386",
+	android.X86_64: "x86_64",
+}
+
+// pythonCextOses maps operating systems to the names used for them in CPython's platform tags.
+var pythonCextOses = map[android.OsType]string{
+	android.Android:     "linux-android",
+	android.LinuxBionic: "linux-android",
+	android.LinuxGlibc:  "linux-gnu",
+	android.LinuxMusl:   "linux-musl",
+}
+
+type PythonCextProperties struct {
+	// the version of the hermetic CPython interpreter whose headers and ABI the extension is built
+	// against, either "3.10" or "3.11". Defaults to "3.10".
+	Hermetic_version *string
+}
+
+type pythonCextDecorator struct {
+	*libraryDecorator
+
+	Properties PythonCextProperties
+}
+
+func (cext *pythonCextDecorator) hermeticVersion() string {
+	return proptools.StringDefault(cext.Properties.Hermetic_version, DefaultHermeticPythonVersion)
+}
+
+func (cext *pythonCextDecorator) linkerProps() []interface{} {
+	return append(cext.libraryDecorator.linkerProps(), &cext.Properties)
+}
+
+func (cext *pythonCextDecorator) linkerInit(ctx BaseModuleContext) {
+	cext.libraryDecorator.linkerInit(ctx)
+
+	// CPython only imports native extensions whose file name is tagged with its ABI, e.g.
+	// foo.cpython-310-x86_64-linux-gnu.so.
+	name := String(cext.libraryDecorator.Properties.Stem)
+	if name == "" {
+		name = ctx.baseModuleName()
+	}
+	cext.libName = name + "." + pythonCextAbiTag(cext.hermeticVersion(), ctx.Target())
+}
+
+func (cext *pythonCextDecorator) linkerDeps(ctx DepsContext, deps Deps) Deps {
+	deps = cext.libraryDecorator.linkerDeps(ctx, deps)
+
+	prefix, ok := HermeticPythonVersions[cext.hermeticVersion()]
+	if !ok {
+		ctx.PropertyErrorf("hermetic_version", "unsupported version %q, must be one of %q",
+			cext.hermeticVersion(), android.SortedStringKeys(HermeticPythonVersions))
+		return deps
+	}
+	deps.HeaderLibs = append(deps.HeaderLibs, prefix+"-headers")
+	return deps
+}
+
+// pythonCextAbiTag returns the tag CPython expects in the file name of a native extension built
+// for the given interpreter version and target.
+func pythonCextAbiTag(version string, target android.Target) string {
+	tag := "cpython-" + strings.Replace(version, ".", "", 1)
+	if target.Os == android.Darwin {
+		return tag + "-darwin"
+	}
+
+	arch, ok := pythonCextArchs[target.Arch.ArchType]
+	if !ok {
+		arch = target.Arch.ArchType.Name
+	}
+	os, ok := pythonCextOses[target.Os]
+	if !ok {
+		os = target.Os.Name
+	}
+	return tag + "-" + arch + "-" + os
+}
+
+// python_cext builds a CPython native extension from C/C++ sources against the headers of the
+// hermetic interpreter selected by hermetic_version. The extension is a shared library named with
+// CPython's ABI tag, e.g. foo.cpython-310-x86_64-linux-gnu.so, and may be packaged into Python
+// modules using their cexts property. Symbols from the interpreter are resolved when the extension
+// is imported, so undefined symbols are allowed by default.
+func PythonCextFactory() android.Module {
+	module, library := NewLibrary(android.HostAndDeviceSupported)
+	library.BuildOnlyShared()
+	library.baseLinker.Properties.Allow_undefined_symbols = proptools.BoolPtr(true)
+
+	cext := &pythonCextDecorator{
+		libraryDecorator: library,
+	}
+	module.linker = cext
+
+	return module.Init()
+}
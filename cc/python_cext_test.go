@@ -0,0 +1,83 @@
+// Copyright 2022 The Android Open Source Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cc
+
+import (
+	"strings"
+	"testing"
+
+	"android/soong/android"
+)
+
+var prepareForPythonCextTest = android.GroupFixturePreparers(
+	prepareForCcTest,
+	android.FixtureRegisterWithContext(RegisterPythonCextBuildComponents),
+	android.FixtureAddTextFile("python/Android.bp", `
+		cc_library_headers {
+			name: "py3-headers",
+			host_supported: true,
+			export_include_dirs: ["py3_include"],
+		}
+		cc_library_headers {
+			name: "py3.11-headers",
+			host_supported: true,
+			export_include_dirs: ["py3.11_include"],
+		}
+	`),
+)
+
+func TestPythonCext(t *testing.T) {
+	result := prepareForPythonCextTest.RunTestWithBp(t, `
+		python_cext {
+			name: "foo",
+			srcs: ["foo.c"],
+			host_supported: true,
+		}
+		python_cext {
+			name: "bar",
+			srcs: ["foo.c"],
+			host_supported: true,
+			hermetic_version: "3.11",
+		}
+	`)
+
+	buildOS := result.Config.BuildOS.String()
+	foo := result.ModuleForTests("foo", buildOS+"_x86_64_shared")
+	foo.Output("foo.cpython-310-x86_64-linux-gnu.so")
+	android.AssertStringDoesContain(t, "foo cflags", foo.Rule("cc").Args["cFlags"], "-Ipython/py3_include")
+
+	bar := result.ModuleForTests("bar", buildOS+"_x86_64_shared")
+	bar.Output("bar.cpython-311-x86_64-linux-gnu.so")
+	android.AssertStringDoesContain(t, "bar cflags", bar.Rule("cc").Args["cFlags"], "-Ipython/py3.11_include")
+
+	device := result.ModuleForTests("foo", "android_arm64_armv8-a_shared")
+	device.Output("foo.cpython-310-aarch64-linux-android.so")
+	if android.InList("-Wl,--no-undefined", strings.Fields(device.Rule("ld").Args["ldFlags"])) {
+		t.Errorf("python_cext should allow undefined symbols, got ldFlags %q", device.Rule("ld").Args["ldFlags"])
+	}
+}
+
+func TestPythonCextUnsupportedVersion(t *testing.T) {
+	prepareForPythonCextTest.
+		ExtendWithErrorHandler(android.FixtureExpectsAtLeastOneErrorMatchingPattern(
+			`hermetic_version: unsupported version "3.9"`)).
+		RunTestWithBp(t, `
+			python_cext {
+				name: "foo",
+				srcs: ["foo.c"],
+				hermetic_version: "3.9",
+			}
+		`)
+}
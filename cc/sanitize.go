@@ -89,6 +89,7 @@ const (
 	scs
 	Fuzzer
 	Memtag_heap
+	Kcfi
 	cfi // cfi is last to prevent it running before incompatible mutators
 )
 
@@ -100,6 +101,7 @@ var Sanitizers = []SanitizerType{
 	scs,
 	Fuzzer,
 	Memtag_heap,
+	Kcfi,
 	cfi, // cfi is last to prevent it running before incompatible mutators
 }
 
@@ -120,6 +122,8 @@ func (t SanitizerType) variationName() string {
 		return "scs"
 	case Memtag_heap:
 		return "memtag_heap"
+	case Kcfi:
+		return "kcfi"
 	case Fuzzer:
 		return "fuzzer"
 	default:
@@ -142,6 +146,8 @@ func (t SanitizerType) name() string {
 		return "integer_overflow"
 	case cfi:
 		return "cfi"
+	case Kcfi:
+		return "kcfi"
 	case scs:
 		return "shadow-call-stack"
 	case Fuzzer:
@@ -153,7 +159,7 @@ func (t SanitizerType) name() string {
 
 func (t SanitizerType) registerMutators(ctx android.RegisterMutatorsContext) {
 	switch t {
-	case Asan, Hwasan, Fuzzer, scs, tsan, cfi:
+	case Asan, Hwasan, Fuzzer, scs, tsan, Kcfi, cfi:
 		ctx.TopDown(t.variationName()+"_deps", sanitizerDepsMutator(t))
 		ctx.BottomUp(t.variationName(), sanitizerMutator(t))
 	case Memtag_heap, intOverflow:
@@ -181,6 +187,8 @@ func (*Module) SanitizerSupported(t SanitizerType) bool {
 		return true
 	case Memtag_heap:
 		return true
+	case Kcfi:
+		return true
 	default:
 		return false
 	}
@@ -188,7 +196,7 @@ func (*Module) SanitizerSupported(t SanitizerType) bool {
 
 // incompatibleWithCfi returns true if a sanitizer is incompatible with CFI.
 func (t SanitizerType) incompatibleWithCfi() bool {
-	return t == Asan || t == Fuzzer || t == Hwasan
+	return t == Asan || t == Fuzzer || t == Hwasan || t == Kcfi
 }
 
 type SanitizeUserProps struct {
@@ -221,6 +229,10 @@ type SanitizeUserProps struct {
 	Safestack *bool `android:"arch_variant"`
 	// cfi sanitizer, incompatible with asan, hwasan, fuzzer, or Darwin
 	Cfi *bool `android:"arch_variant"`
+	// kcfi sanitizer, the type-hash based CFI scheme, only available on arm64 and x86_64.
+	// Incompatible with cfi, which it takes precedence over unless cfi is set explicitly.
+	// Static dependencies are built with kcfi in a kcfi variant, like with cfi.
+	Kcfi *bool `android:"arch_variant"`
 	// signed/unsigned integer overflow sanitizer, incompatible with Darwin.
 	Integer_overflow *bool `android:"arch_variant"`
 	// scudo sanitizer, incompatible with asan, hwasan, tsan
@@ -262,6 +274,11 @@ type SanitizeUserProps struct {
 	Config struct {
 		// Enables CFI support flags for assembly-heavy libraries
 		Cfi_assembly_support *bool `android:"arch_variant"`
+
+		// Checks that binaries and shared libraries built with address or hwaddress don't
+		// define ifuncs, whose resolvers run before the sanitizer runtime is initialized and
+		// crash at load time. Defaults to false.
+		Ifunc_check *bool `android:"arch_variant"`
 	} `android:"arch_variant"`
 
 	// List of sanitizers to pass to -fsanitize-recover
@@ -274,7 +291,7 @@ type SanitizeUserProps struct {
 }
 
 type SanitizeProperties struct {
-	Sanitize          SanitizeUserProps `android:"arch_variant"`
+	Sanitize          SanitizeUserProps `android:"arch_variant_nested"`
 	SanitizerEnabled  bool              `blueprint:"mutated"`
 	SanitizeDep       bool              `blueprint:"mutated"`
 	MinimalRuntimeDep bool              `blueprint:"mutated"`
@@ -283,6 +300,17 @@ type SanitizeProperties struct {
 	InSanitizerDir    bool              `blueprint:"mutated"`
 	Sanitizers        []string          `blueprint:"mutated"`
 	DiagSanitizers    []string          `blueprint:"mutated"`
+
+	// The name of the sanitizer runtime shared library linked by the module, if any.
+	RuntimeLibrary string `blueprint:"mutated"`
+
+	// Names of the sanitizers to build a variant with only to be packaged by a
+	// cc_sanitizer_smoke_package or as a JNI library of an android_app.
+	PackagedSanitizers []string `blueprint:"mutated"`
+
+	// The sanitizers that were enabled by the product or by default rather than by the sanitize
+	// property, as <sanitizer>:<source> entries, to explain the flags of the module.
+	SanitizerSources []string `blueprint:"mutated"`
 }
 
 type sanitize struct {
@@ -305,6 +333,73 @@ func (sanitize *sanitize) props() []interface{} {
 	return []interface{}{&sanitize.Properties}
 }
 
+// policyPartition returns the partition the module is installed to for the purpose of the
+// per-partition product policies, like the memtag heap and branch protection partition lists, or
+// "" for images that are not covered by them.
+func policyPartition(ctx BaseModuleContext) string {
+	return PolicyPartition(ctx, ctx.Module().(LinkableInterface))
+}
+
+// PolicyPartition is policyPartition for the modules of other packages implementing
+// LinkableInterface, like the rust modules.
+func PolicyPartition(ctx android.BaseModuleContext, m LinkableInterface) string {
+	switch {
+	case m.InRamdisk(), m.InVendorRamdisk(), m.InRecovery():
+		return ""
+	case m.InVendor() && ctx.DeviceSpecific():
+		return "odm"
+	case m.InVendor():
+		return "vendor"
+	case m.InProduct():
+		return "product"
+	case ctx.SystemExtSpecific():
+		return "system_ext"
+	default:
+		return "system"
+	}
+}
+
+// enabledSanitizerProps returns the names of the sanitize properties that enable a sanitizer and
+// whether they are set to true.
+func enabledSanitizerProps(s *SanitizeUserProps) map[string]bool {
+	return map[string]bool{
+		"address":          Bool(s.Address),
+		"hwaddress":        Bool(s.Hwaddress),
+		"thread":           Bool(s.Thread),
+		"fuzzer":           Bool(s.Fuzzer),
+		"safe-stack":       Bool(s.Safestack),
+		"cfi":              Bool(s.Cfi),
+		"kcfi":             Bool(s.Kcfi),
+		"integer_overflow": Bool(s.Integer_overflow),
+		"scudo":            Bool(s.Scudo),
+		"memtag_heap":      Bool(s.Memtag_heap),
+		"undefined":        Bool(s.Undefined) || Bool(s.All_undefined),
+	}
+}
+
+// recordSanitizerSource records that the sanitizer was enabled by source rather than by the
+// sanitize property.
+func (sanitize *sanitize) recordSanitizerSource(sanitizer, source string) {
+	entry := sanitizer + ":" + source
+	if !inList(entry, sanitize.Properties.SanitizerSources) {
+		sanitize.Properties.SanitizerSources = append(sanitize.Properties.SanitizerSources, entry)
+	}
+}
+
+// sanitizerSourceRecorder returns a function that records source for the sanitizers that were
+// enabled since it was created.
+func (sanitize *sanitize) sanitizerSourceRecorder(s *SanitizeUserProps, source string) func() {
+	before := enabledSanitizerProps(s)
+	return func() {
+		after := enabledSanitizerProps(s)
+		for _, sanitizer := range android.SortedStringKeys(after) {
+			if after[sanitizer] && !before[sanitizer] {
+				sanitize.recordSanitizerSource(sanitizer, source)
+			}
+		}
+	}
+}
+
 func (sanitize *sanitize) begin(ctx BaseModuleContext) {
 	s := &sanitize.Properties.Sanitize
 
@@ -318,32 +413,47 @@ func (sanitize *sanitize) begin(ctx BaseModuleContext) {
 		return
 	}
 
+	// kcfi and cross-DSO CFI check indirect calls with incompatible schemes.  kcfi takes precedence
+	// over CFI that is enabled globally or for the path of the module, but not over CFI that the
+	// module enables explicitly.
+	if Bool(s.Kcfi) && Bool(s.Cfi) {
+		ctx.PropertyErrorf("sanitize.kcfi", "is incompatible with sanitize.cfi")
+	}
+	cfiRequested := Bool(s.Cfi)
+
 	// cc_test targets default to SYNC MemTag unless explicitly set to ASYNC (via diag: {memtag_heap}).
 	if ctx.testBinary() {
+		recordTestDefault := sanitize.sanitizerSourceRecorder(s, "cc_test default")
 		if s.Memtag_heap == nil {
 			s.Memtag_heap = proptools.BoolPtr(true)
 		}
 		if s.Diag.Memtag_heap == nil {
 			s.Diag.Memtag_heap = proptools.BoolPtr(true)
 		}
+		recordTestDefault()
 	}
 
 	var globalSanitizers []string
 	var globalSanitizersDiag []string
+	var globalSource string
 
 	if ctx.Host() {
 		if !ctx.Windows() {
 			globalSanitizers = ctx.Config().SanitizeHost()
+			globalSource = "SANITIZE_HOST=" + strings.Join(globalSanitizers, ",")
 		}
 	} else {
 		arches := ctx.Config().SanitizeDeviceArch()
 		if len(arches) == 0 || inList(ctx.Arch().ArchType.Name, arches) {
 			globalSanitizers = ctx.Config().SanitizeDevice()
 			globalSanitizersDiag = ctx.Config().SanitizeDeviceDiag()
+			globalSource = "SANITIZE_TARGET=" + strings.Join(globalSanitizers, ",")
 		}
 	}
 
 	if len(globalSanitizers) > 0 {
+		recordGlobalSanitizers := sanitize.sanitizerSourceRecorder(s, globalSource)
+
 		var found bool
 		if found, globalSanitizers = removeFromList("undefined", globalSanitizers); found && s.All_undefined == nil {
 			s.All_undefined = proptools.BoolPtr(true)
@@ -369,6 +479,10 @@ func (sanitize *sanitize) begin(ctx BaseModuleContext) {
 			s.Safestack = proptools.BoolPtr(true)
 		}
 
+		if found, globalSanitizers = removeFromList("kcfi", globalSanitizers); found && s.Kcfi == nil && !cfiRequested {
+			s.Kcfi = proptools.BoolPtr(true)
+		}
+
 		if found, globalSanitizers = removeFromList("cfi", globalSanitizers); found && s.Cfi == nil {
 			if !ctx.Config().CFIDisabledForPath(ctx.ModuleDir()) {
 				s.Cfi = proptools.BoolPtr(true)
@@ -427,30 +541,45 @@ func (sanitize *sanitize) begin(ctx BaseModuleContext) {
 		if len(globalSanitizersDiag) > 0 {
 			ctx.ModuleErrorf("unknown global sanitizer diagnostics option %s", globalSanitizersDiag[0])
 		}
+
+		recordGlobalSanitizers()
 	}
 
-	// Enable Memtag for all components in the include paths (for Aarch64 only)
+	// Enable Memtag for all components in the include paths or partitions (for Aarch64 only).
+	// Include paths take precedence over partitions so that e.g. platform dogfood paths can
+	// use sync mode on a device whose vendor partition uses async mode.
 	if ctx.Arch().ArchType == android.Arm64 {
-		if ctx.Config().MemtagHeapSyncEnabledForPath(ctx.ModuleDir()) {
+		recordMemtagHeap := sanitize.sanitizerSourceRecorder(s, "product memtag_heap include paths or partitions")
+		partition := policyPartition(ctx)
+		sync := ctx.Config().MemtagHeapSyncEnabledForPath(ctx.ModuleDir())
+		async := ctx.Config().MemtagHeapAsyncEnabledForPath(ctx.ModuleDir())
+		if !sync && !async && partition != "" {
+			sync = ctx.Config().MemtagHeapSyncEnabledForPartition(partition, ctx.ModuleDir())
+			async = ctx.Config().MemtagHeapAsyncEnabledForPartition(partition, ctx.ModuleDir())
+		}
+		if sync {
 			if s.Memtag_heap == nil {
 				s.Memtag_heap = proptools.BoolPtr(true)
 			}
 			if s.Diag.Memtag_heap == nil {
 				s.Diag.Memtag_heap = proptools.BoolPtr(true)
 			}
-		} else if ctx.Config().MemtagHeapAsyncEnabledForPath(ctx.ModuleDir()) {
+		} else if async {
 			if s.Memtag_heap == nil {
 				s.Memtag_heap = proptools.BoolPtr(true)
 			}
 		}
+		recordMemtagHeap()
 	}
 
 	if s.Integer_overflow == nil && ctx.Config().IntegerOverflowEnabledForPath(ctx.ModuleDir()) && ctx.Arch().ArchType == android.Arm64 {
 		s.Integer_overflow = proptools.BoolPtr(true)
+		sanitize.recordSanitizerSource("integer_overflow", "product integer_overflow include paths")
 	}
 
 	if  ctx.Config().BoundSanitizerEnabledForPath(ctx.ModuleDir()) && ctx.Arch().ArchType == android.Arm64 {
 		s.Misc_undefined = append(s.Misc_undefined, "bounds")
+		sanitize.recordSanitizerSource("undefined", "product bounds sanitizer include paths")
 	}
 
 	if ctx.Config().BoundSanitizerDisabledForPath(ctx.ModuleDir()) && ctx.Arch().ArchType == android.Arm64 {
@@ -474,9 +603,16 @@ func (sanitize *sanitize) begin(ctx BaseModuleContext) {
         s.Integer_overflow = nil
 	}
 
+	// Enable kcfi for components in the include paths
+	if s.Kcfi == nil && !cfiRequested && ctx.Config().KcfiEnabledForPath(ctx.ModuleDir()) {
+		s.Kcfi = proptools.BoolPtr(true)
+		sanitize.recordSanitizerSource("kcfi", "product kcfi include paths")
+	}
+
 	// Enable CFI for non-host components in the include paths
 	if s.Cfi == nil && ctx.Config().CFIEnabledForPath(ctx.ModuleDir()) && !ctx.Host() {
 		s.Cfi = proptools.BoolPtr(true)
+		sanitize.recordSanitizerSource("cfi", "product cfi include paths")
 		if inList("cfi", ctx.Config().SanitizeDeviceDiag()) {
 			s.Diag.Cfi = proptools.BoolPtr(true)
 		}
@@ -510,6 +646,16 @@ func (sanitize *sanitize) begin(ctx BaseModuleContext) {
 		s.Memtag_heap = nil
 	}
 
+	// kcfi is only implemented on AArch64 and x86_64 Linux.
+	if (ctx.Arch().ArchType != android.Arm64 && ctx.Arch().ArchType != android.X86_64) || !ctx.Os().Linux() {
+		s.Kcfi = nil
+	}
+
+	if Bool(s.Kcfi) {
+		s.Cfi = nil
+		s.Diag.Cfi = nil
+	}
+
 	// Also disable CFI if ASAN is enabled.
 	if Bool(s.Address) || Bool(s.Hwaddress) {
 		s.Cfi = nil
@@ -570,7 +716,7 @@ func (sanitize *sanitize) begin(ctx BaseModuleContext) {
 
 	if ctx.Os() != android.Windows && (Bool(s.All_undefined) || Bool(s.Undefined) || Bool(s.Address) || Bool(s.Thread) ||
 		Bool(s.Fuzzer) || Bool(s.Safestack) || Bool(s.Cfi) || Bool(s.Integer_overflow) || len(s.Misc_undefined) > 0 ||
-		Bool(s.Scudo) || Bool(s.Hwaddress) || Bool(s.Scs) || Bool(s.Memtag_heap)) {
+		Bool(s.Scudo) || Bool(s.Hwaddress) || Bool(s.Scs) || Bool(s.Memtag_heap) || Bool(s.Kcfi)) {
 		sanitize.Properties.SanitizerEnabled = true
 	}
 
@@ -842,6 +988,8 @@ func (sanitize *sanitize) getSanitizerBoolPtr(t SanitizerType) *bool {
 		return sanitize.Properties.Sanitize.Scs
 	case Memtag_heap:
 		return sanitize.Properties.Sanitize.Memtag_heap
+	case Kcfi:
+		return sanitize.Properties.Sanitize.Kcfi
 	case Fuzzer:
 		return sanitize.Properties.Sanitize.Fuzzer
 	default:
@@ -888,6 +1036,8 @@ func (sanitize *sanitize) SetSanitizer(t SanitizerType, b bool) {
 		sanitize.Properties.Sanitize.Scs = bPtr
 	case Memtag_heap:
 		sanitize.Properties.Sanitize.Memtag_heap = bPtr
+	case Kcfi:
+		sanitize.Properties.Sanitize.Kcfi = bPtr
 	case Fuzzer:
 		sanitize.Properties.Sanitize.Fuzzer = bPtr
 	default:
@@ -991,7 +1141,7 @@ func sanitizerDepsMutator(t SanitizerType) func(android.TopDownMutatorContext) {
 					if d, ok := child.(PlatformSanitizeable); ok && d.SanitizePropDefined() &&
 						!d.SanitizeNever() &&
 						!d.IsSanitizerExplicitlyDisabled(t) {
-						if t == cfi || t == Hwasan || t == scs || t == Asan {
+						if t == cfi || t == Kcfi || t == Hwasan || t == scs || t == Asan {
 							if d.StaticallyLinked() && d.SanitizerSupported(t) {
 								// Rust does not support some of these sanitizers, so we need to check if it's
 								// supported before setting this true.
@@ -1004,6 +1154,10 @@ func sanitizerDepsMutator(t SanitizerType) func(android.TopDownMutatorContext) {
 					return true
 				})
 			}
+		} else if p, ok := mctx.Module().(*sanitizerSmokePackage); ok {
+			if s, ok := p.sanitizer(); ok && s == t {
+				p.markSanitizerSmokeDeps(mctx, t)
+			}
 		} else if sanitizeable, ok := mctx.Module().(Sanitizeable); ok {
 			// If an APEX module includes a lib which is enabled for a sanitizer T, then
 			// the APEX module is also enabled for the same sanitizer type.
@@ -1012,6 +1166,31 @@ func sanitizerDepsMutator(t SanitizerType) func(android.TopDownMutatorContext) {
 					sanitizeable.EnableSanitizer(t.name())
 				}
 			})
+		} else if jniSanitizeable, ok := mctx.Module().(JniSanitizeable); ok &&
+			jniSanitizeable.IsSanitizerEnabledForJni(mctx, t.name()) {
+			// Mark the JNI libraries so that the sanitizer mutator creates sanitized variants of
+			// them next to the unsanitized ones. Only this module, which the sanitizer mutator puts
+			// in the sanitized variation, depends on them; other users of the libraries keep the
+			// unsanitized variants.
+			mctx.VisitDirectDeps(func(child android.Module) {
+				if !jniSanitizeable.IsJniDependencyTag(mctx.OtherModuleDependencyTag(child)) {
+					return
+				}
+				c, ok := child.(*Module)
+				if !ok || !c.SanitizePropDefined() || !c.Shared() {
+					return
+				}
+				if t == Hwasan && c.Target().Arch.ArchType != android.Arm64 {
+					return
+				}
+				if c.SanitizeNever() || c.IsSanitizerExplicitlyDisabled(t) || !c.SanitizerSupported(t) {
+					return
+				}
+				if !android.InList(t.variationName(), c.sanitize.Properties.PackagedSanitizers) {
+					c.sanitize.Properties.PackagedSanitizers = append(
+						c.sanitize.Properties.PackagedSanitizers, t.variationName())
+				}
+			})
 		}
 	}
 }
@@ -1147,6 +1326,12 @@ func sanitizerRuntimeMutator(mctx android.BottomUpMutatorContext) {
 			}
 		}
 
+		// The cfi variants of static libraries that enable kcfi, created for the modules that use
+		// CFI, are only built with CFI.
+		if Bool(c.sanitize.Properties.Sanitize.Kcfi) && !Bool(c.sanitize.Properties.Sanitize.Cfi) {
+			sanitizers = append(sanitizers, "kcfi")
+		}
+
 		if Bool(c.sanitize.Properties.Sanitize.Integer_overflow) {
 			sanitizers = append(sanitizers, "unsigned-integer-overflow")
 			sanitizers = append(sanitizers, "signed-integer-overflow")
@@ -1301,6 +1486,7 @@ func sanitizerRuntimeMutator(mctx android.BottomUpMutatorContext) {
 						blueprint.Variation{Mutator: "sdk", Variation: "sdk"})
 				}
 				AddSharedLibDependenciesWithVersions(mctx, c, variations, depTag, runtimeLibrary, "", true)
+				c.sanitize.Properties.RuntimeLibrary = runtimeLibrary
 			}
 			// static lib does not have dependency to the runtime library. The
 			// dependency will be added to the executables or shared libs using
@@ -1316,6 +1502,14 @@ type Sanitizeable interface {
 	AddSanitizerDependencies(ctx android.BottomUpMutatorContext, sanitizerName string)
 }
 
+// JniSanitizeable is implemented by modules that package JNI libraries, like android_app, and
+// can require the sanitized variants of their JNI libraries.
+type JniSanitizeable interface {
+	android.Module
+	IsSanitizerEnabledForJni(ctx android.BaseModuleContext, sanitizerName string) bool
+	IsJniDependencyTag(tag blueprint.DependencyTag) bool
+}
+
 func (c *Module) MinimalRuntimeDep() bool {
 	return c.sanitize.Properties.MinimalRuntimeDep
 }
@@ -1440,12 +1634,38 @@ func sanitizerMutator(t SanitizerType) func(android.BottomUpMutatorContext) {
 						modules[0].(PlatformSanitizeable).SetSanitizer(cfi, false)
 					}
 				}
+			} else if m, ok := c.(*Module); ok && android.InList(t.variationName(), m.sanitize.Properties.PackagedSanitizers) {
+				// Shared libs packaged by a cc_sanitizer_smoke_package or by an android_app are split
+				// into non-sanitized and sanitized variants. The sanitized variant is only used by the
+				// package, so it is neither installed nor exported to Make.
+				modules := mctx.CreateVariations("", t.variationName())
+				sanitized := modules[1].(*Module)
+				sanitized.SetSanitizer(t, true)
+				sanitized.SetPreventInstall()
+				sanitized.SetHideFromMake()
+
+				// locate the asan libraries under /data/asan
+				if t == Asan {
+					sanitized.SetInSanitizerDir()
+				}
+
+				if mctx.Device() && t.incompatibleWithCfi() && cfiSupported {
+					sanitized.SetSanitizer(cfi, false)
+				}
 			}
 			c.SetSanitizeDep(false)
 		} else if sanitizeable, ok := mctx.Module().(Sanitizeable); ok && sanitizeable.IsSanitizerEnabled(mctx, t.name()) {
 			// APEX modules fall here
 			sanitizeable.AddSanitizerDependencies(mctx, t.name())
 			mctx.CreateVariations(t.variationName())
+		} else if j, ok := mctx.Module().(JniSanitizeable); ok && j.IsSanitizerEnabledForJni(mctx, t.name()) {
+			// The module depends on the sanitized variants of its JNI libraries.
+			mctx.CreateVariations(t.variationName())
+		} else if p, ok := mctx.Module().(*sanitizerSmokePackage); ok {
+			// The package depends on the sanitized variants of the libraries it packages.
+			if s, ok := p.sanitizer(); ok && s == t {
+				mctx.CreateVariations(t.variationName())
+			}
 		} else if c, ok := mctx.Module().(*Module); ok {
 			//TODO: When Rust modules have vendor support, enable this path for PlatformSanitizeable
 
@@ -1507,21 +1727,35 @@ func (s *sanitizerStaticLibsMap) add(c LinkableInterface, name string) {
 // These are to be used by use_soong_sanitized_static_libraries.
 // See build/make/core/binary.mk for more details.
 func (s *sanitizerStaticLibsMap) exportToMake(ctx android.MakeVarsContext) {
+	// Copy the lists while holding the lock, so that exporting doesn't modify the map other
+	// providers may be reading concurrently.
+	type shard struct {
+		key  string
+		libs []string
+	}
+	var shards []shard
+	s.libsMapLock.Lock()
 	for _, image := range android.SortedStringKeys(s.libsMap) {
 		archMap := s.libsMap[ImageVariantType(image)]
 		for _, arch := range android.SortedStringKeys(archMap) {
-			libs := archMap[arch]
-			sort.Strings(libs)
-
 			key := fmt.Sprintf(
 				"SOONG_%s_%s_%s_STATIC_LIBRARIES",
 				s.sanitizerType.variationName(),
 				image, // already upper
 				arch)
-
-			ctx.Strict(key, strings.Join(libs, " "))
+			shards = append(shards, shard{key, android.CopyOf(archMap[arch])})
 		}
 	}
+	s.libsMapLock.Unlock()
+
+	// The lists can hold thousands of libraries, sort them concurrently.
+	android.ParallelForEach(len(shards), func(i int) {
+		sort.Strings(shards[i].libs)
+	})
+
+	for _, shard := range shards {
+		ctx.Strict(shard.key, strings.Join(shard.libs, " "))
+	}
 }
 
 var cfiStaticLibsKey = android.NewOnceKey("cfiStaticLibs")
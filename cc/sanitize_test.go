@@ -201,6 +201,54 @@ func TestAsan(t *testing.T) {
 	t.Run("device", func(t *testing.T) { check(t, result, "android_arm64_armv8-a") })
 }
 
+func TestHostAsanTestRunner(t *testing.T) {
+	bp := `
+		cc_test {
+			name: "test_with_asan",
+			host_supported: true,
+			gtest: false,
+			sanitize: {
+				address: true,
+			}
+		}
+
+		cc_test {
+			name: "test_no_asan",
+			host_supported: true,
+			gtest: false,
+		}
+	`
+
+	result := android.GroupFixturePreparers(
+		prepareForCcTest,
+		prepareForAsanTest,
+	).RunTestWithBp(t, bp)
+
+	buildOS := result.Config.BuildOSTarget.String()
+
+	withAsan := result.ModuleForTests("test_with_asan", buildOS+"_asan")
+	runner := withAsan.Output("sanitizer_runner/test_with_asan_runner.sh.in")
+	content := android.ContentFromFileRuleForTests(t, runner)
+	android.AssertStringDoesContain(t, "runner symbolizer path", content,
+		`export ASAN_SYMBOLIZER_PATH="$dir/llvm-symbolizer"`)
+	android.AssertStringDoesContain(t, "runner asan options", content,
+		`export ASAN_OPTIONS="symbolize=1:external_symbolizer_path=$dir/llvm-symbolizer${ASAN_OPTIONS:+:${ASAN_OPTIONS}}"`)
+	android.AssertStringDoesContain(t, "runner exec", content, `exec "$dir/test_with_asan" "$@"`)
+
+	var data []string
+	for _, d := range withAsan.Module().(*Module).linker.(*testBinary).data {
+		data = append(data, d.SrcPath.Base())
+	}
+	android.AssertArrayString(t, "test data", []string{"test_with_asan_runner.sh", "llvm-symbolizer"}, data)
+	android.AssertStringEquals(t, "test config runs", "test_with_asan_runner.sh",
+		withAsan.Output("test_with_asan.config").Args["name"])
+
+	noAsan := result.ModuleForTests("test_no_asan", buildOS)
+	android.AssertIntEquals(t, "test data without asan", 0, len(noAsan.Module().(*Module).linker.(*testBinary).data))
+	android.AssertStringEquals(t, "test config runs", "test_no_asan",
+		noAsan.Output("test_no_asan.config").Args["name"])
+}
+
 type MemtagNoteType int
 
 const (
@@ -331,6 +379,71 @@ var prepareForTestWithMemtagHeap = android.GroupFixturePreparers(
 	}),
 )
 
+func TestIfuncCheck(t *testing.T) {
+	bp := `
+		cc_binary {
+			name: "bin_with_asan",
+			sanitize: {
+				address: true,
+				config: {
+					ifunc_check: true,
+				},
+			},
+		}
+
+		cc_library_shared {
+			name: "libhwasan",
+			sanitize: {
+				hwaddress: true,
+				config: {
+					ifunc_check: true,
+				},
+			},
+		}
+
+		cc_binary {
+			name: "bin_with_asan_no_check",
+			sanitize: {
+				address: true,
+			},
+		}
+
+		cc_binary {
+			name: "bin_no_asan",
+			sanitize: {
+				config: {
+					ifunc_check: true,
+				},
+			},
+		}
+	`
+
+	result := android.GroupFixturePreparers(
+		prepareForCcTest,
+		prepareForAsanTest,
+	).RunTestWithBp(t, bp)
+
+	const variant = "android_arm64_armv8-a"
+	checkIfunc := func(module, variant string, expected bool) {
+		t.Helper()
+		m := result.ModuleForTests(module, variant)
+		check := m.MaybeRule("checkIfunc")
+		android.AssertBoolEquals(t, module+" ifunc check", expected, check.Rule != nil)
+		if expected {
+			ld := m.Rule("ld")
+			android.AssertStringEquals(t, module+" ifunc check input", ld.Output.String(), check.Input.String())
+			android.AssertPathsRelativeToTopEquals(t, module+" link validations",
+				[]string{"out/soong/.intermediates/" + module + "/" + variant + "/ifunc_check.stamp"},
+				ld.Validations)
+		}
+	}
+
+	checkIfunc("bin_with_asan", variant+"_asan", true)
+	checkIfunc("libhwasan", variant+"_shared_hwasan", true)
+	checkIfunc("bin_with_asan_no_check", variant+"_asan", false)
+	checkIfunc("bin_no_asan", variant, false)
+}
+
 func TestSanitizeMemtagHeap(t *testing.T) {
 	variant := "android_arm64_armv8-a"
 
@@ -551,3 +664,152 @@ func TestSanitizeMemtagHeapWithSanitizeDeviceDiag(t *testing.T) {
 	checkHasMemtagNote(t, ctx.ModuleForTests("unset_test_override_default_disable", variant), Sync)
 	checkHasMemtagNote(t, ctx.ModuleForTests("unset_test_override_default_sync", variant), Sync)
 }
+
+func TestSanitizeMemtagHeapPartitions(t *testing.T) {
+	bp := `
+		cc_binary {
+			name: "system_binary",
+		}
+
+		cc_binary {
+			name: "system_ext_binary",
+			system_ext_specific: true,
+		}
+
+		cc_binary {
+			name: "vendor_binary",
+			vendor: true,
+		}
+
+		cc_binary {
+			name: "odm_binary",
+			device_specific: true,
+		}
+	`
+
+	result := android.GroupFixturePreparers(
+		prepareForCcTest,
+		prepareForTestWithMemtagHeap,
+		android.FixtureModifyProductVariables(func(variables android.FixtureProductVariables) {
+			variables.MemtagHeapSyncIncludePartitions = []string{"system"}
+			variables.MemtagHeapAsyncIncludePartitions = []string{"vendor"}
+		}),
+	).RunTestWithBp(t, bp)
+	ctx := result.TestContext
+
+	const variant = "android_arm64_armv8-a"
+	const vendorVariant = "android_vendor.29_arm64_armv8-a"
+
+	checkHasMemtagNote(t, ctx.ModuleForTests("system_binary", variant), Sync)
+	checkHasMemtagNote(t, ctx.ModuleForTests("system_ext_binary", variant), None)
+	checkHasMemtagNote(t, ctx.ModuleForTests("vendor_binary", vendorVariant), Async)
+	checkHasMemtagNote(t, ctx.ModuleForTests("odm_binary", vendorVariant), None)
+
+	// Include paths take precedence over partitions, and exclude paths disable both.
+	checkHasMemtagNote(t, ctx.ModuleForTests("unset_binary_no_override", variant), Sync)
+	checkHasMemtagNote(t, ctx.ModuleForTests("unset_binary_override_default_async", variant), Async)
+	checkHasMemtagNote(t, ctx.ModuleForTests("unset_binary_override_default_disable", variant), None)
+	checkHasMemtagNote(t, ctx.ModuleForTests("no_memtag_binary_no_override", variant), None)
+}
+
+func TestSanitizeArchVariantNested(t *testing.T) {
+	bp := `
+		cc_library_shared {
+			name: "libfoo",
+			arch: {
+				arm64: {
+					sanitize: {
+						recover: ["undefined"],
+					},
+					native_coverage: false,
+				},
+			},
+		}
+	`
+
+	result := prepareForCcTest.RunTestWithBp(t, bp)
+
+	arm64 := result.ModuleForTests("libfoo", "android_arm64_armv8-a_shared").Module().(*Module)
+	android.AssertDeepEquals(t, "arm64 sanitize.recover", []string{"undefined"}, arm64.sanitize.Properties.Sanitize.Recover)
+	android.AssertBoolEquals(t, "arm64 native_coverage", false, BoolDefault(arm64.coverage.Properties.Native_coverage, true))
+
+	arm := result.ModuleForTests("libfoo", "android_arm_armv7-a-neon_shared").Module().(*Module)
+	android.AssertDeepEquals(t, "arm sanitize.recover", []string(nil), arm.sanitize.Properties.Sanitize.Recover)
+	android.AssertBoolEquals(t, "arm native_coverage", true, BoolDefault(arm.coverage.Properties.Native_coverage, true))
+}
+
+func TestSanitizeKcfi(t *testing.T) {
+	bp := `
+		cc_library_shared {
+			name: "libkcfi",
+			srcs: ["foo.c"],
+			static_libs: ["libkcfi_static"],
+			sanitize: {
+				kcfi: true,
+			},
+		}
+
+		cc_library_static {
+			name: "libkcfi_static",
+			srcs: ["foo.c"],
+		}
+	`
+
+	result := android.GroupFixturePreparers(
+		prepareForCcTest,
+		android.FixtureAddFile("kcfi/Android.bp", []byte(`
+			cc_library_shared {
+				name: "libkcfi_path",
+				srcs: ["foo.c"],
+			}
+
+			cc_library_shared {
+				name: "libkcfi_path_cfi",
+				srcs: ["foo.c"],
+				sanitize: {
+					cfi: true,
+				},
+			}
+		`)),
+		android.FixtureModifyProductVariables(func(variables android.FixtureProductVariables) {
+			variables.CFIIncludePaths = []string{"kcfi"}
+			variables.KcfiIncludePaths = []string{"kcfi"}
+		}),
+	).RunTestWithBp(t, bp)
+
+	checkFlags := func(module, variant string, kcfi, cfi bool) {
+		t.Helper()
+		cFlags := result.ModuleForTests(module, variant).Rule("cc").Args["cFlags"]
+		android.AssertBoolEquals(t, module+" "+variant+" kcfi", kcfi, strings.Contains(cFlags, "-fsanitize=kcfi"))
+		android.AssertBoolEquals(t, module+" "+variant+" cfi", cfi, strings.Contains(cFlags, "-fsanitize=cfi"))
+	}
+
+	checkFlags("libkcfi", "android_arm64_armv8-a_shared_kcfi", true, false)
+	// kcfi is not implemented on arm.
+	checkFlags("libkcfi", "android_arm_armv7-a-neon_shared", false, false)
+
+	// Static dependencies are linked in their kcfi variant.
+	checkFlags("libkcfi_static", "android_arm64_armv8-a_static_kcfi", true, false)
+	checkFlags("libkcfi_static", "android_arm64_armv8-a_static", false, false)
+	libkcfi := result.ModuleForTests("libkcfi", "android_arm64_armv8-a_shared_kcfi")
+	android.AssertStringListContains(t, "libkcfi static deps",
+		android.PathsRelativeToTop(libkcfi.Rule("ld").Implicits),
+		"out/soong/.intermediates/libkcfi_static/android_arm64_armv8-a_static_kcfi/libkcfi_static.a")
+	// kcfi takes precedence over CFI enabled for the path, but not over CFI enabled explicitly.
+	checkFlags("libkcfi_path", "android_arm64_armv8-a_shared_kcfi", true, false)
+	checkFlags("libkcfi_path_cfi", "android_arm64_armv8-a_shared_cfi", false, true)
+}
+
+func TestSanitizeKcfiWithCfi(t *testing.T) {
+	prepareForCcTest.ExtendWithErrorHandler(android.FixtureExpectsAtLeastOneErrorMatchingPattern(
+		`sanitize.kcfi: is incompatible with sanitize.cfi`)).RunTestWithBp(t, `
+		cc_library_shared {
+			name: "libboth",
+			srcs: ["foo.c"],
+			sanitize: {
+				kcfi: true,
+				cfi: true,
+			},
+		}
+	`)
+}
@@ -0,0 +1,214 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cc
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/google/blueprint"
+	"github.com/google/blueprint/proptools"
+
+	"android/soong/android"
+	"android/soong/cc/config"
+)
+
+// This file contains the module type cc_sanitizer_smoke_package, which packages the sanitized
+// variants of an allowlist of shared libraries, so that they can be pushed to a device that was
+// not built with SANITIZE_TARGET.
+
+func init() {
+	android.RegisterModuleType("cc_sanitizer_smoke_package", SanitizerSmokePackageFactory)
+}
+
+type sanitizerSmokePackageProperties struct {
+	// Sanitizer to build the libraries listed in deps with. Either "hwaddress" or "address".
+	// Defaults to "hwaddress".
+	Sanitizer *string
+
+	// Name of the output file. Defaults to <module_name>.tar.
+	Stem *string
+}
+
+type sanitizerSmokePackage struct {
+	android.ModuleBase
+	android.PackagingBase
+
+	properties sanitizerSmokePackageProperties
+
+	output android.OutputPath
+}
+
+type sanitizerSmokePackageDepTag struct {
+	blueprint.BaseDependencyTag
+	android.PackagingItemAlwaysDepTag
+	name string
+}
+
+var (
+	sanitizerSmokeLibTag     = sanitizerSmokePackageDepTag{name: "lib"}
+	sanitizerSmokeRuntimeTag = sanitizerSmokePackageDepTag{name: "runtime"}
+)
+
+// cc_sanitizer_smoke_package builds the hwaddress (or address) sanitized variants of the shared
+// libraries listed in deps, without affecting the variants installed to the partitions, and
+// packages them together with the sanitizer runtime library into a tarball laid out like
+// /data/asan. Extracting the tarball to the root of a device makes the dynamic linker pick up
+// the sanitized libraries, without a full SANITIZE_TARGET build. hwaddress sanitized variants
+// are only built for arm64.
+func SanitizerSmokePackageFactory() android.Module {
+	module := &sanitizerSmokePackage{}
+	module.AddProperties(&module.properties)
+	android.InitPackageModule(module)
+	android.InitAndroidMultiTargetsArchModule(module, android.DeviceSupported, android.MultilibCommon)
+	return module
+}
+
+// sanitizer returns the sanitizer type set by the sanitizer property, or false if it isn't
+// supported.
+func (p *sanitizerSmokePackage) sanitizer() (SanitizerType, bool) {
+	switch proptools.StringDefault(p.properties.Sanitizer, Hwasan.name()) {
+	case Hwasan.name():
+		return Hwasan, true
+	case Asan.name():
+		return Asan, true
+	}
+	return 0, false
+}
+
+// supportsTarget returns true if the sanitized variants can be built for the given target.
+func (p *sanitizerSmokePackage) supportsTarget(t SanitizerType, target android.Target) bool {
+	return t != Hwasan || target.Arch.ArchType == android.Arm64
+}
+
+func (p *sanitizerSmokePackage) DepsMutator(ctx android.BottomUpMutatorContext) {
+	t, ok := p.sanitizer()
+	if !ok {
+		ctx.PropertyErrorf("sanitizer", "must be either %q or %q, but was %q",
+			Hwasan.name(), Asan.name(), *p.properties.Sanitizer)
+		return
+	}
+
+	variations := []blueprint.Variation{
+		{Mutator: "image", Variation: android.CoreVariation},
+		{Mutator: "link", Variation: "shared"},
+	}
+	p.AddDepsWithVariations(ctx, sanitizerSmokeLibTag, variations)
+
+	for _, target := range ctx.MultiTargets() {
+		if !p.supportsTarget(t, target) {
+			continue
+		}
+		toolchain := config.FindToolchain(target.Os, target.Arch)
+		runtime := config.HWAddressSanitizerRuntimeLibrary(toolchain)
+		if t == Asan {
+			runtime = config.AddressSanitizerRuntimeLibrary(toolchain)
+		}
+		ctx.AddFarVariationDependencies(append(target.Variations(), variations...),
+			sanitizerSmokeRuntimeTag, runtime)
+	}
+}
+
+// markSanitizerSmokeDeps requests the sanitizer mutator to create the sanitized variants of the
+// libraries packaged by this module.
+func (p *sanitizerSmokePackage) markSanitizerSmokeDeps(mctx android.TopDownMutatorContext, t SanitizerType) {
+	mctx.VisitDirectDepsWithTag(sanitizerSmokeLibTag, func(child android.Module) {
+		c, ok := child.(*Module)
+		if !ok || !c.SanitizePropDefined() || !c.Shared() || !p.supportsTarget(t, c.Target()) {
+			return
+		}
+		if c.SanitizeNever() || c.IsSanitizerExplicitlyDisabled(t) || !c.SanitizerSupported(t) {
+			return
+		}
+		if !android.InList(t.variationName(), c.sanitize.Properties.PackagedSanitizers) {
+			c.sanitize.Properties.PackagedSanitizers = append(
+				c.sanitize.Properties.PackagedSanitizers, t.variationName())
+		}
+	})
+}
+
+func (p *sanitizerSmokePackage) installFileName() string {
+	return proptools.StringDefault(p.properties.Stem, p.BaseModuleName()+".tar")
+}
+
+func (p *sanitizerSmokePackage) GenerateAndroidBuildActions(ctx android.ModuleContext) {
+	t, _ := p.sanitizer()
+
+	specs := make(map[string]android.PackagingSpec)
+	addSpecs := func(child android.Module) {
+		for _, ps := range child.PackagingSpecs() {
+			dir := ps.Partition()
+			if !strings.HasPrefix(dir, "data/asan/") {
+				dir = filepath.Join("data/asan", dir)
+			}
+			ps.SetRelPathInPackage(filepath.Join(dir, ps.RelPathInPackage()))
+			specs[ps.RelPathInPackage()] = ps
+		}
+	}
+
+	ctx.VisitDirectDepsWithTag(sanitizerSmokeLibTag, func(child android.Module) {
+		c, ok := child.(*Module)
+		if !ok || !c.Shared() {
+			ctx.PropertyErrorf("deps", "%q is not a cc shared library", ctx.OtherModuleName(child))
+			return
+		}
+		if !p.supportsTarget(t, c.Target()) {
+			return
+		}
+		if !c.IsSanitizerEnabled(t) {
+			ctx.PropertyErrorf("deps", "%q can't be built with the %s sanitizer",
+				ctx.OtherModuleName(child), t.name())
+			return
+		}
+		addSpecs(child)
+	})
+	ctx.VisitDirectDepsWithTag(sanitizerSmokeRuntimeTag, addSpecs)
+	if ctx.Failed() {
+		return
+	}
+
+	builder := android.NewRuleBuilder(pctx, ctx)
+	rootDir := android.PathForModuleOut(ctx, "root")
+	builder.Command().Text("rm").Flag("-rf").Text(rootDir.String())
+	builder.Command().Text("mkdir").Flag("-p").Text(rootDir.String())
+	p.CopySpecsToDir(ctx, builder, specs, rootDir)
+
+	p.output = android.PathForModuleOut(ctx, p.installFileName()).OutputPath
+	builder.Command().
+		Text("tar").
+		Flag("--sort=name").
+		Flag("--mtime=@0").
+		Flag("--owner=0").
+		Flag("--group=0").
+		Flag("--numeric-owner").
+		FlagWithOutput("-cf ", p.output).
+		FlagWithArg("-C ", rootDir.String()).
+		Text(".")
+	builder.Command().Text("rm").Flag("-rf").Text(rootDir.String())
+
+	builder.Build("sanitizer_smoke_package", fmt.Sprintf("Packaging %s", p.BaseModuleName()))
+	ctx.CheckbuildFile(p.output)
+}
+
+var _ android.OutputFileProducer = (*sanitizerSmokePackage)(nil)
+
+// Implements android.OutputFileProducer
+func (p *sanitizerSmokePackage) OutputFiles(tag string) (android.Paths, error) {
+	if tag == "" {
+		return []android.Path{p.output}, nil
+	}
+	return nil, fmt.Errorf("unsupported module reference tag %q", tag)
+}
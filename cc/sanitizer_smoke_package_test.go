@@ -0,0 +1,95 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cc
+
+import (
+	"testing"
+
+	"github.com/google/blueprint"
+
+	"android/soong/android"
+)
+
+var prepareForSanitizerSmokePackageTest = android.GroupFixturePreparers(
+	prepareForCcTest,
+	android.FixtureRegisterWithContext(func(ctx android.RegistrationContext) {
+		ctx.RegisterModuleType("cc_sanitizer_smoke_package", SanitizerSmokePackageFactory)
+	}),
+)
+
+func TestSanitizerSmokePackage(t *testing.T) {
+	result := prepareForSanitizerSmokePackageTest.RunTestWithBp(t, `
+		cc_library_shared {
+			name: "libfoo",
+			srcs: ["foo.c"],
+		}
+
+		cc_sanitizer_smoke_package {
+			name: "smoke",
+			deps: ["libfoo"],
+		}`)
+
+	// The installed variant of libfoo isn't sanitized.
+	libfoo := result.ModuleForTests("libfoo", "android_arm64_armv8-a_shared")
+	android.AssertStringDoesNotContain(t, "cFlags", libfoo.Rule("cc").Args["cFlags"], "-fsanitize=hwaddress")
+	android.AssertBoolEquals(t, "installed variant hidden from make", false,
+		libfoo.Module().(*Module).HiddenFromMake())
+
+	// The sanitized variant is only used by the package.
+	libfooHwasan := result.ModuleForTests("libfoo", "android_arm64_armv8-a_shared_hwasan")
+	android.AssertStringDoesContain(t, "cFlags", libfooHwasan.Rule("cc").Args["cFlags"], "-fsanitize=hwaddress")
+	android.AssertBoolEquals(t, "sanitized variant hidden from make", true,
+		libfooHwasan.Module().(*Module).HiddenFromMake())
+
+	smoke := result.ModuleForTests("smoke", "android_common_hwasan")
+	cmd := smoke.Rule("sanitizer_smoke_package").RuleParams.Command
+	android.AssertStringDoesContain(t, "packaged library", cmd, "root/data/asan/system/lib64/libfoo.so")
+	android.AssertStringDoesNotContain(t, "32-bit library", cmd, "root/data/asan/system/lib/libfoo.so")
+	android.AssertStringDoesContain(t, "tarball", cmd, "-cf out/soong/.intermediates/smoke/android_common_hwasan/smoke.tar")
+
+	var deps []string
+	result.VisitDirectDeps(smoke.Module(), func(m blueprint.Module) {
+		deps = append(deps, m.Name())
+	})
+	android.AssertStringListContains(t, "runtime dependency", deps, "libclang_rt.hwasan")
+}
+
+func TestSanitizerSmokePackageErrors(t *testing.T) {
+	prepareForSanitizerSmokePackageTest.
+		ExtendWithErrorHandler(android.FixtureExpectsAtLeastOneErrorMatchingPattern(
+			`sanitizer: must be either "hwaddress" or "address", but was "thread"`)).
+		RunTestWithBp(t, `
+			cc_sanitizer_smoke_package {
+				name: "smoke",
+				sanitizer: "thread",
+			}`)
+
+	prepareForSanitizerSmokePackageTest.
+		ExtendWithErrorHandler(android.FixtureExpectsAtLeastOneErrorMatchingPattern(
+			`deps: "libfoo" can't be built with the hwaddress sanitizer`)).
+		RunTestWithBp(t, `
+			cc_library_shared {
+				name: "libfoo",
+				srcs: ["foo.c"],
+				sanitize: {
+					never: true,
+				},
+			}
+
+			cc_sanitizer_smoke_package {
+				name: "smoke",
+				deps: ["libfoo"],
+			}`)
+}
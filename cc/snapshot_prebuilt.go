@@ -438,9 +438,13 @@ type snapshotLibraryDecorator struct {
 	properties          SnapshotLibraryProperties
 	sanitizerProperties struct {
 		CfiEnabled bool `blueprint:"mutated"`
+		ScsEnabled bool `blueprint:"mutated"`
 
 		// Library flags for cfi variant.
 		Cfi SnapshotLibraryProperties `android:"arch_variant"`
+
+		// Library flags for scs variant.
+		Scs SnapshotLibraryProperties `android:"arch_variant"`
 	}
 }
 
@@ -479,8 +483,12 @@ func (p *snapshotLibraryDecorator) link(ctx ModuleContext, flags Flags, deps Pat
 		return p.libraryDecorator.link(ctx, flags, deps, objs)
 	}
 
+	// The snapshot has a single sanitized variant of each library. cfi takes precedence over scs,
+	// because cfi libraries can't be linked from non-cfi modules, while scs libraries can.
 	if p.sanitizerProperties.CfiEnabled {
 		p.properties = p.sanitizerProperties.Cfi
+	} else if p.sanitizerProperties.ScsEnabled {
+		p.properties = p.sanitizerProperties.Scs
 	}
 
 	if !p.MatchesWithDevice(ctx.DeviceConfig()) {
@@ -547,6 +555,8 @@ func (p *snapshotLibraryDecorator) isSanitizerEnabled(t SanitizerType) bool {
 	switch t {
 	case cfi:
 		return p.sanitizerProperties.Cfi.Src != nil
+	case scs:
+		return p.sanitizerProperties.Scs.Src != nil
 	default:
 		return false
 	}
@@ -559,6 +569,8 @@ func (p *snapshotLibraryDecorator) setSanitizerVariation(t SanitizerType, enable
 	switch t {
 	case cfi:
 		p.sanitizerProperties.CfiEnabled = true
+	case scs:
+		p.sanitizerProperties.ScsEnabled = true
 	default:
 		return
 	}
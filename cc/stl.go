@@ -43,11 +43,57 @@ func getNdkStlFamilyAndLinkType(m LinkableInterface) (string, string) {
 
 type StlProperties struct {
 	// Select the STL library to use.  Possible values are "libc++",
-	// "libc++_static", "libstdc++", or "none". Leave blank to select the
-	// default.
+	// "libc++_static", "libstdc++", "none" or "custom". Leave blank to select the
+	// default.  "custom" builds like "none" and links against the libraries
+	// listed in custom_stl instead.
 	Stl *string `android:"arch_variant"`
 
+	Target struct {
+		Vendor struct {
+			// Select the STL library to use for the vendor variant, overriding stl.
+			Stl *string
+		}
+		Product struct {
+			// Select the STL library to use for the product variant, overriding stl.
+			Stl *string
+		}
+		Recovery struct {
+			// Select the STL library to use for the recovery variant, overriding stl.
+			Stl *string
+		}
+		Ramdisk struct {
+			// Select the STL library to use for the ramdisk variant, overriding stl.
+			Stl *string
+		}
+		Vendor_ramdisk struct {
+			// Select the STL library to use for the vendor ramdisk variant, overriding stl.
+			Stl *string
+		}
+	} `android:"arch_variant"`
+
+	// The libraries that provide the STL when stl is "custom", for example a reduced
+	// C++ runtime for the recovery image.
+	Custom_stl struct {
+		// list of static libraries that provide the STL.
+		Static_libs []string `android:"arch_variant"`
+
+		// list of shared libraries that provide the STL.
+		Shared_libs []string `android:"arch_variant"`
+
+		// list of header libraries that provide the STL headers.
+		Header_libs []string `android:"arch_variant"`
+	} `android:"arch_variant"`
+
+	// Allow a shared library that links the STL statically, with stl: "libc++_static", to export
+	// libc++ symbols.  By default the build fails if it does, because the exported symbols may
+	// be preempted by those of another copy of libc++ in the same process, which violates the
+	// one definition rule and usually only shows as a crash at runtime.
+	Allow_static_stl_exports *bool `android:"arch_variant"`
+
 	SelectedStl string `blueprint:"mutated"`
+
+	// Set if stl is "custom" for this variant.  SelectedStl is empty in that case.
+	SelectedCustomStl bool `blueprint:"mutated"`
 }
 
 type stl struct {
@@ -58,7 +104,51 @@ func (stl *stl) props() []interface{} {
 	return []interface{}{&stl.Properties}
 }
 
+// imageVariantStl returns the stl property for the image variant of the module, or nil if it
+// doesn't override stl.
+func (stl *stl) imageVariantStl(ctx BaseModuleContext) *string {
+	switch {
+	case ctx.inVendor():
+		return stl.Properties.Target.Vendor.Stl
+	case ctx.inProduct():
+		return stl.Properties.Target.Product.Stl
+	case ctx.inRecovery():
+		return stl.Properties.Target.Recovery.Stl
+	case ctx.inRamdisk():
+		return stl.Properties.Target.Ramdisk.Stl
+	case ctx.inVendorRamdisk():
+		return stl.Properties.Target.Vendor_ramdisk.Stl
+	}
+	return nil
+}
+
+// The libraries that make up the STLs selected with the stl property, which can't be used as a
+// custom STL.
+var builtinStlLibs = []string{
+	"libc++",
+	"libc++_static",
+	"libc++demangle",
+	"libstdc++",
+	"ndk_libc++_shared",
+	"ndk_libc++_static",
+	"ndk_libc++abi",
+}
+
+func (stl *stl) checkCustomStl(ctx BaseModuleContext) {
+	custom := stl.Properties.Custom_stl
+	libs := append(append(append([]string(nil), custom.Static_libs...), custom.Shared_libs...), custom.Header_libs...)
+	if len(libs) == 0 {
+		ctx.PropertyErrorf("custom_stl", "must list the libraries that provide the STL when stl is \"custom\"")
+	}
+	for _, lib := range libs {
+		if android.InList(lib, builtinStlLibs) {
+			ctx.PropertyErrorf("custom_stl", "%q is a builtin STL library, select it with stl instead", lib)
+		}
+	}
+}
+
 func (stl *stl) begin(ctx BaseModuleContext) {
+	stl.Properties.SelectedCustomStl = false
 	stl.Properties.SelectedStl = func() string {
 		s := ""
 		if stl.Properties.Stl != nil {
@@ -66,6 +156,9 @@ func (stl *stl) begin(ctx BaseModuleContext) {
 		} else if ctx.header() {
 			s = "none"
 		}
+		if imageStl := stl.imageVariantStl(ctx); imageStl != nil {
+			s = *imageStl
+		}
 		if ctx.useSdk() && ctx.Device() {
 			switch s {
 			case "", "system":
@@ -103,6 +196,10 @@ func (stl *stl) begin(ctx BaseModuleContext) {
 				return "libc++_static"
 			case "none":
 				return ""
+			case "custom":
+				stl.checkCustomStl(ctx)
+				stl.Properties.SelectedCustomStl = true
+				return ""
 			case "", "system":
 				if ctx.static() {
 					return "libc++_static"
@@ -164,10 +261,15 @@ func (stl *stl) deps(ctx BaseModuleContext, deps Deps) Deps {
 			}
 		}
 	case "":
-		// None or error.
+		// None, custom or error.
 		if ctx.toolchain().Bionic() && ctx.Module().Name() == "libc++" {
 			deps.StaticUnwinderIfLegacy = true
 		}
+		if stl.Properties.SelectedCustomStl {
+			deps.StaticLibs = append(deps.StaticLibs, stl.Properties.Custom_stl.Static_libs...)
+			deps.SharedLibs = append(deps.SharedLibs, stl.Properties.Custom_stl.Shared_libs...)
+			deps.HeaderLibs = append(deps.HeaderLibs, stl.Properties.Custom_stl.Header_libs...)
+		}
 	case "ndk_system":
 		// TODO: Make a system STL prebuilt for the NDK.
 		// The system STL doesn't have a prebuilt (it uses the system's libstdc++), but it does have
@@ -242,3 +344,17 @@ func (stl *stl) flags(ctx ModuleContext, flags Flags) Flags {
 
 	return flags
 }
+
+// staticStlExportsCheck returns the stamp file of the check that a shared library that links
+// libc++ statically doesn't export libc++ symbols, to be used as a validation of the link.
+func (stl *stl) staticStlExportsCheck(ctx ModuleContext, linked android.Path) android.Paths {
+	switch stl.Properties.SelectedStl {
+	case "libc++_static", "ndk_libc++_static":
+	default:
+		return nil
+	}
+	if ctx.Darwin() || ctx.Windows() || Bool(stl.Properties.Allow_static_stl_exports) {
+		return nil
+	}
+	return android.Paths{transformSharedLibToStaticStlExportsCheck(ctx, linked)}
+}
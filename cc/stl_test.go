@@ -0,0 +1,141 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cc
+
+import (
+	"testing"
+
+	"android/soong/android"
+)
+
+func TestStlImageVariants(t *testing.T) {
+	ctx := testCc(t, `
+		cc_library_shared {
+			name: "libfoo",
+			srcs: ["foo.cpp"],
+			stl: "libc++",
+			recovery_available: true,
+			vendor_available: true,
+			target: {
+				recovery: {
+					stl: "custom",
+				},
+				vendor: {
+					stl: "libc++_static",
+				},
+			},
+			custom_stl: {
+				static_libs: ["libminicxx"],
+			},
+		}
+
+		cc_library_static {
+			name: "libminicxx",
+			srcs: ["minicxx.cpp"],
+			stl: "none",
+			recovery_available: true,
+			vendor_available: true,
+		}
+	`)
+
+	selectedStl := func(variant string) *stl {
+		return ctx.ModuleForTests("libfoo", variant).Module().(*Module).stl
+	}
+
+	core := selectedStl(coreVariant)
+	android.AssertStringEquals(t, "core stl", "libc++", core.Properties.SelectedStl)
+	android.AssertBoolEquals(t, "core custom stl", false, core.Properties.SelectedCustomStl)
+
+	vendor := selectedStl(vendorVariant)
+	android.AssertStringEquals(t, "vendor stl", "libc++_static", vendor.Properties.SelectedStl)
+
+	recovery := selectedStl(recoveryVariant)
+	android.AssertStringEquals(t, "recovery stl", "", recovery.Properties.SelectedStl)
+	android.AssertBoolEquals(t, "recovery custom stl", true, recovery.Properties.SelectedCustomStl)
+
+	ld := ctx.ModuleForTests("libfoo", recoveryVariant).Rule("ld")
+	android.AssertStringListContains(t, "recovery link inputs",
+		android.PathsRelativeToTop(ld.Implicits),
+		"out/soong/.intermediates/libminicxx/android_recovery_arm64_armv8-a_static/libminicxx.a")
+
+	coreLd := ctx.ModuleForTests("libfoo", coreVariant).Rule("ld")
+	android.AssertStringListDoesNotContain(t, "core link inputs",
+		android.PathsRelativeToTop(coreLd.Implicits),
+		"out/soong/.intermediates/libminicxx/android_arm64_armv8-a_static/libminicxx.a")
+}
+
+func TestCustomStlErrors(t *testing.T) {
+	testCcError(t, `custom_stl: must list the libraries that provide the STL when stl is "custom"`, `
+		cc_library_shared {
+			name: "libfoo",
+			stl: "custom",
+		}
+	`)
+
+	testCcError(t, `custom_stl: "libc\+\+_static" is a builtin STL library, select it with stl instead`, `
+		cc_library_shared {
+			name: "libfoo",
+			stl: "custom",
+			custom_stl: {
+				static_libs: ["libc++_static"],
+			},
+		}
+	`)
+
+	testCcError(t, `stl: "custom" is not a supported STL with sdk_version set`, `
+		cc_library_shared {
+			name: "libfoo",
+			sdk_version: "current",
+			stl: "custom",
+			custom_stl: {
+				static_libs: ["libminicxx"],
+			},
+		}
+	`)
+}
+
+func TestStaticStlExportsCheck(t *testing.T) {
+	result := prepareForCcTest.RunTestWithBp(t, `
+		cc_library_shared {
+			name: "libstatic_stl",
+			srcs: ["foo.cpp"],
+			stl: "libc++_static",
+		}
+
+		cc_library_shared {
+			name: "libstatic_stl_allowed",
+			srcs: ["foo.cpp"],
+			stl: "libc++_static",
+			allow_static_stl_exports: true,
+		}
+
+		cc_library_shared {
+			name: "libshared_stl",
+			srcs: ["foo.cpp"],
+			stl: "libc++",
+		}
+	`)
+
+	variant := "android_arm64_armv8-a_shared"
+	libstatic := result.ModuleForTests("libstatic_stl", variant)
+	check := libstatic.Output("static_stl_exports_check.stamp")
+	android.AssertStringListContains(t, "validations", libstatic.Rule("ld").Validations.Strings(), check.Output.String())
+
+	for _, module := range []string{"libstatic_stl_allowed", "libshared_stl"} {
+		m := result.ModuleForTests(module, variant)
+		android.AssertBoolEquals(t, module+" checks stl exports", false,
+			m.MaybeOutput("static_stl_exports_check.stamp").Rule != nil)
+	}
+}
@@ -15,13 +15,16 @@
 package cc
 
 import (
+	"fmt"
 	"path/filepath"
+	"regexp"
 	"strconv"
 	"strings"
 
 	"github.com/google/blueprint/proptools"
 
 	"android/soong/android"
+	"android/soong/cc/config"
 	"android/soong/tradefed"
 )
 
@@ -68,6 +71,13 @@ type TestOptions struct {
 	// Add MinApiLevelModuleController with ro.vndk.version property. If ro.vndk.version has an
 	// integer value and the value is less than the min_vndk_version, skip this module.
 	Min_vndk_version *int64
+
+	// Split the test into shards, one for each gtest filter, for example "FooTest.*:BarTest.*".  A
+	// test config that passes the filter to the test with the native-test-flag option of the
+	// GTest runner is generated for each shard, in addition to the test config of the whole test,
+	// so that test harnesses can run the shards on different devices in parallel.  Only supported
+	// for gtest tests with auto generated test configs.
+	Shard_gtest_filters []string
 }
 
 type TestBinaryProperties struct {
@@ -340,6 +350,9 @@ type testBinary struct {
 	data             []android.DataPath
 	testConfig       android.Path
 	extraTestConfigs android.Paths
+
+	// sanitizerRunner is the wrapper that runs a host test built with a sanitizer, or nil.
+	sanitizerRunner android.Path
 }
 
 func (test *testBinary) linkerProps() []interface{} {
@@ -356,6 +369,9 @@ func (test *testBinary) linkerInit(ctx BaseModuleContext) {
 func (test *testBinary) linkerDeps(ctx DepsContext, deps Deps) Deps {
 	deps = test.testDecorator.linkerDeps(ctx, deps)
 	deps = test.binaryDecorator.linkerDeps(ctx, deps)
+	if ctx.Device() {
+		deps.StaticLibs = append(deps.StaticLibs, ctx.Config().CcTestStaticLibsForPath(ctx.ModuleDir())...)
+	}
 	deps.DataLibs = append(deps.DataLibs, test.Properties.Data_libs...)
 	deps.DataBins = append(deps.DataBins, test.Properties.Data_bins...)
 	return deps
@@ -409,6 +425,13 @@ func (test *testBinary) install(ctx ModuleContext, file android.Path) {
 		}
 	})
 
+	if c, ok := ctx.Module().(*Module); ok && ctx.Host() {
+		var runnerData []android.DataPath
+		test.sanitizerRunner, runnerData = hostSanitizerTestRunner(ctx, file,
+			c.IsSanitizerEnabled(Asan), c.IsSanitizerEnabled(tsan))
+		test.data = append(test.data, runnerData...)
+	}
+
 	var configs []tradefed.Config
 	for _, module := range test.Properties.Test_mainline_modules {
 		configs = append(configs, tradefed.Option{Name: "config-descriptor:metadata", Key: "mainline-param", Value: module})
@@ -454,9 +477,11 @@ func (test *testBinary) install(ctx ModuleContext, file android.Path) {
 	}
 
 	test.testConfig = tradefed.AutoGenNativeTestConfig(ctx, test.Properties.Test_config,
-		test.Properties.Test_config_template, test.testDecorator.InstallerProperties.Test_suites, configs, test.Properties.Auto_gen_config, testInstallBase)
+		test.Properties.Test_config_template, test.testDecorator.InstallerProperties.Test_suites, configs, test.Properties.Auto_gen_config, testInstallBase,
+		test.testName(ctx))
 
 	test.extraTestConfigs = android.PathsForModuleSrc(ctx, test.Properties.Test_options.Extra_test_configs)
+	test.extraTestConfigs = append(test.extraTestConfigs, test.shardTestConfigs(ctx, configs, testInstallBase)...)
 
 	test.binaryDecorator.baseInstaller.dir = "nativetest"
 	test.binaryDecorator.baseInstaller.dir64 = "nativetest64"
@@ -471,6 +496,107 @@ func (test *testBinary) install(ctx ModuleContext, file android.Path) {
 		test.Properties.Test_options.Unit_test = proptools.BoolPtr(true)
 	}
 	test.binaryDecorator.baseInstaller.install(ctx, file)
+
+	if testSuites := test.testDecorator.InstallerProperties.Test_suites; len(testSuites) > 0 {
+		ctx.SetProvider(android.TestSuiteInfoProvider, android.TestSuiteInfo{
+			TestSuites:       testSuites,
+			OutputFile:       file,
+			OutputFileName:   test.binaryDecorator.baseInstaller.path.Base(),
+			Data:             android.TestSuiteData(test.data),
+			TestConfig:       test.testConfig,
+			ExtraTestConfigs: test.extraTestConfigs,
+		})
+	}
+}
+
+// testName returns the name of the file that the test config runs: the sanitizer runner of a host
+// test built with a sanitizer, or the module name.
+func (test *testBinary) testName(ctx ModuleContext) string {
+	if test.sanitizerRunner != nil {
+		return test.sanitizerRunner.Base()
+	}
+	return ctx.ModuleName()
+}
+
+// hostSanitizerTestRunner generates a wrapper script, installed next to a host test binary built
+// with ASan or TSan, that points the sanitizer runtime at the prebuilt llvm-symbolizer so that
+// reports are symbolized when the test is run from the host.  The test config runs the wrapper
+// instead of the binary.  It returns the wrapper and the data files that must be installed
+// alongside the test, or nil if no sanitizer needing a symbolizer is enabled.  Rust host tests
+// are never built with sanitizers, so they have no wrapper.
+func hostSanitizerTestRunner(ctx android.ModuleContext, binary android.Path, asan, tsan bool) (android.Path, []android.DataPath) {
+	if !asan && !tsan {
+		return nil, nil
+	}
+
+	symbolizerOptions := "symbolize=1:external_symbolizer_path=$dir/llvm-symbolizer"
+	var optionVars []string
+	if asan {
+		optionVars = append(optionVars, "ASAN_OPTIONS")
+	}
+	if tsan {
+		optionVars = append(optionVars, "TSAN_OPTIONS")
+	}
+
+	lines := []string{
+		"#!/bin/bash",
+		"# Generated by Soong for " + ctx.ModuleName() + ", do not edit.",
+		`dir="$(cd "$(dirname "${BASH_SOURCE[0]}")" && pwd)"`,
+		`export ASAN_SYMBOLIZER_PATH="$dir/llvm-symbolizer"`,
+	}
+	for _, v := range optionVars {
+		lines = append(lines, fmt.Sprintf(`export %s="%s${%s:+:${%s}}"`, v, symbolizerOptions, v, v))
+	}
+	lines = append(lines, fmt.Sprintf(`exec "$dir/%s" "$@"`, binary.Base()))
+
+	script := android.PathForModuleOut(ctx, "sanitizer_runner", binary.Base()+"_runner.sh.in")
+	android.WriteFileRule(ctx, script, strings.Join(lines, "\n"))
+
+	runner := android.PathForModuleOut(ctx, "sanitizer_runner", binary.Base()+"_runner.sh")
+	ctx.Build(pctx, android.BuildParams{
+		Rule:   android.CpExecutable,
+		Input:  script,
+		Output: runner,
+	})
+
+	return runner, []android.DataPath{
+		{SrcPath: runner},
+		{SrcPath: config.ClangPath(ctx, "bin/llvm-symbolizer")},
+	}
+}
+
+// shardTestConfigs generates the test configs of the shards requested by
+// test_options.shard_gtest_filters.
+func (test *testBinary) shardTestConfigs(ctx ModuleContext, configs []tradefed.Config, testInstallBase string) android.Paths {
+	filters := test.Properties.Test_options.Shard_gtest_filters
+	if len(filters) == 0 {
+		return nil
+	}
+	if len(filters) < 2 {
+		ctx.PropertyErrorf("test_options.shard_gtest_filters", "must have at least 2 filters, found %d", len(filters))
+		return nil
+	}
+	if !test.gtest() {
+		ctx.PropertyErrorf("test_options.shard_gtest_filters", "is only supported for gtest tests")
+		return nil
+	}
+
+	var shardConfigs android.Paths
+	for i, filter := range filters {
+		shardConfig := append([]tradefed.Config(nil), configs...)
+		shardConfig = append(shardConfig, tradefed.Option{Name: "native-test-flag", Value: "--gtest_filter=" + filter})
+
+		shardName := ctx.ModuleName() + "_shard" + strconv.Itoa(i)
+		path := tradefed.AutoGenNativeTestShardConfig(ctx, test.Properties.Test_config,
+			test.Properties.Test_config_template, test.testDecorator.InstallerProperties.Test_suites,
+			shardName, shardConfig, test.Properties.Auto_gen_config, testInstallBase, test.testName(ctx))
+		if path == nil {
+			ctx.PropertyErrorf("test_options.shard_gtest_filters", "requires an auto generated test config")
+			return nil
+		}
+		shardConfigs = append(shardConfigs, path)
+	}
+	return shardConfigs
 }
 
 func NewTest(hod android.HostOrDeviceSupported) *Module {
@@ -564,8 +690,28 @@ type BenchmarkProperties struct {
 	// doesn't exist next to the Android.bp, this attribute doesn't need to be set to true
 	// explicitly.
 	Auto_gen_config *bool
+
+	// Benchmark options.
+	Benchmark_options BenchmarkOptions
+}
+
+// Benchmark option struct.
+type BenchmarkOptions struct {
+	// The format of the results of the benchmark, "json" or "csv".  On devices the results are
+	// written to /data/local/tmp/benchmark_results in addition to the console output, and pulled
+	// by the test harness.  On hosts they replace the console output, which the test harness
+	// saves with the logs of the test.
+	Result_format *string
+
+	// The CPUs to pin the benchmark to, as a list of CPU numbers below 64 and ranges, for example
+	// "4-7" or "0,2".
+	Cpu_affinity *string
 }
 
+const benchmarkDeviceResultsDir = "/data/local/tmp/benchmark_results"
+
+var cpuAffinityRegexp = regexp.MustCompile(`^[0-9]+(-[0-9]+)?(,[0-9]+(-[0-9]+)?)*$`)
+
 type benchmarkDecorator struct {
 	*binaryDecorator
 	Properties BenchmarkProperties
@@ -605,12 +751,137 @@ func (benchmark *benchmarkDecorator) install(ctx ModuleContext, file android.Pat
 	if Bool(benchmark.Properties.Require_root) {
 		configs = append(configs, tradefed.Object{"target_preparer", "com.android.tradefed.targetprep.RootTargetPreparer", nil})
 	}
+	testName := ctx.ModuleName()
+	if runner := benchmark.benchmarkRunner(ctx, file); runner != nil {
+		benchmark.data = append(benchmark.data, runner)
+		if ctx.Device() {
+			// GoogleBenchmarkTest runs every executable in the install directory of the benchmark,
+			// only run the wrapper.
+			configs = append(configs, tradefed.Option{Name: "file-exclusion-filter-regex",
+				Value: ".*/" + regexp.QuoteMeta(file.Base()) + "$"})
+			if String(benchmark.Properties.Benchmark_options.Result_format) != "" {
+				configs = append(configs, tradefed.Object{"metrics_collector", "com.android.tradefed.device.metric.FilePullerLogCollector", []tradefed.Option{
+					{Name: "directory-keys", Value: benchmarkDeviceResultsDir},
+					{Name: "collect-on-run-ended-only", Value: "true"},
+				}})
+			}
+		} else {
+			testName = runner.Base()
+		}
+	}
 	benchmark.testConfig = tradefed.AutoGenNativeBenchmarkTestConfig(ctx, benchmark.Properties.Test_config,
-		benchmark.Properties.Test_config_template, benchmark.Properties.Test_suites, configs, benchmark.Properties.Auto_gen_config)
+		benchmark.Properties.Test_config_template, benchmark.Properties.Test_suites, configs, benchmark.Properties.Auto_gen_config,
+		testName)
 
 	benchmark.binaryDecorator.baseInstaller.dir = filepath.Join("benchmarktest", ctx.ModuleName())
 	benchmark.binaryDecorator.baseInstaller.dir64 = filepath.Join("benchmarktest64", ctx.ModuleName())
 	benchmark.binaryDecorator.baseInstaller.install(ctx, file)
+
+	if len(benchmark.Properties.Test_suites) > 0 {
+		data := make(map[string]android.Path)
+		for _, d := range benchmark.data {
+			data[d.Rel()] = d
+		}
+		ctx.SetProvider(android.TestSuiteInfoProvider, android.TestSuiteInfo{
+			TestSuites:     benchmark.Properties.Test_suites,
+			OutputFile:     file,
+			OutputFileName: benchmark.binaryDecorator.baseInstaller.path.Base(),
+			Data:           data,
+			TestConfig:     benchmark.testConfig,
+		})
+	}
+}
+
+// benchmarkRunner generates a wrapper script, installed next to the benchmark, that runs it on the
+// CPUs of benchmark_options.cpu_affinity and writes its results in the format of
+// benchmark_options.result_format, as the test harness can't pass flags to the benchmark.  It
+// returns nil if neither option is set.
+func (benchmark *benchmarkDecorator) benchmarkRunner(ctx ModuleContext, binary android.Path) android.Path {
+	options := benchmark.Properties.Benchmark_options
+	format := String(options.Result_format)
+	cpus := String(options.Cpu_affinity)
+	if format == "" && cpus == "" {
+		return nil
+	}
+
+	var command []string
+	if cpus != "" {
+		mask, err := cpuAffinityMask(cpus)
+		if err != nil {
+			ctx.PropertyErrorf("benchmark_options.cpu_affinity", "%s", err)
+			return nil
+		}
+		command = append(command, "taskset", mask)
+	}
+	command = append(command, `"$dir/`+binary.Base()+`"`)
+	if format != "" {
+		if !android.InList(format, []string{"json", "csv"}) {
+			ctx.PropertyErrorf("benchmark_options.result_format", "must be \"json\" or \"csv\", got %q", format)
+			return nil
+		}
+		if ctx.Device() {
+			command = append(command,
+				"--benchmark_out="+benchmarkDeviceResultsDir+"/"+ctx.ModuleName()+"."+format,
+				"--benchmark_out_format="+format)
+		} else {
+			command = append(command, "--benchmark_format="+format)
+		}
+	}
+	command = append(command, `"$@"`)
+
+	shell := "/bin/bash"
+	if ctx.Device() {
+		shell = "/system/bin/sh"
+	}
+	lines := []string{
+		"#!" + shell,
+		"# Generated by Soong for " + ctx.ModuleName() + ", do not edit.",
+		`dir="${0%/*}"`,
+	}
+	if ctx.Device() && format != "" {
+		lines = append(lines, "mkdir -p "+benchmarkDeviceResultsDir)
+	}
+	lines = append(lines, "exec "+strings.Join(command, " "))
+
+	script := android.PathForModuleOut(ctx, "benchmark_runner", binary.Base()+"_runner.sh.in")
+	android.WriteFileRule(ctx, script, strings.Join(lines, "\n"))
+
+	runner := android.PathForModuleOut(ctx, binary.Base()+"_runner.sh")
+	ctx.Build(pctx, android.BuildParams{
+		Rule:   android.CpExecutable,
+		Input:  script,
+		Output: runner,
+	})
+	return runner
+}
+
+// cpuAffinityMask returns the hexadecimal CPU mask passed to taskset for a list of CPU numbers and
+// ranges.
+func cpuAffinityMask(cpus string) (string, error) {
+	if !cpuAffinityRegexp.MatchString(cpus) {
+		return "", fmt.Errorf("must be a list of CPU numbers and ranges like \"0,4-7\", got %q", cpus)
+	}
+	var mask uint64
+	for _, r := range strings.Split(cpus, ",") {
+		bounds := strings.SplitN(r, "-", 2)
+		first, err := strconv.Atoi(bounds[0])
+		if err != nil {
+			return "", err
+		}
+		last := first
+		if len(bounds) == 2 {
+			if last, err = strconv.Atoi(bounds[1]); err != nil {
+				return "", err
+			}
+		}
+		if last < first || last > 63 {
+			return "", fmt.Errorf("invalid CPU range %q, CPUs must be below 64 and ranges increasing", r)
+		}
+		for cpu := first; cpu <= last; cpu++ {
+			mask |= 1 << uint(cpu)
+		}
+	}
+	return strconv.FormatUint(mask, 16), nil
 }
 
 func NewBenchmark(hod android.HostOrDeviceSupported) *Module {
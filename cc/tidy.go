@@ -37,6 +37,12 @@ type TidyProperties struct {
 
 	// Checks that should be treated as errors.
 	Tidy_checks_as_errors []string
+
+	// File listing existing clang-tidy findings to suppress, so that only new findings are
+	// reported.  Each line lists the checks to disable for a source file, relative to the
+	// module directory, in the form "<source file>: <check>[,<check>...]".  Empty lines and
+	// lines starting with '#' are ignored.
+	Tidy_baseline *string `android:"path"`
 }
 
 type tidyFeature struct {
@@ -167,6 +173,10 @@ func (tidy *tidyFeature) flags(ctx ModuleContext, flags Flags) Flags {
 	tidyChecks = tidyChecks + ",-cert-err33-c"
 	flags.TidyFlags = append(flags.TidyFlags, tidyChecks)
 
+	if tidy.Properties.Tidy_baseline != nil {
+		flags.TidyBaselineChecks = parseTidyBaseline(ctx, android.PathForModuleSrc(ctx, *tidy.Properties.Tidy_baseline))
+	}
+
 	if ctx.Config().IsEnvTrue("WITH_TIDY") {
 		// WITH_TIDY=1 enables clang-tidy globally. There could be many unexpected
 		// warnings from new checks and many local tidy_checks_as_errors and
@@ -348,3 +358,35 @@ func genObjTidyPhonyTargets(ctx android.SingletonContext, module android.Module,
 		targetGroups[group] = android.PathForPhony(ctx, groupName)
 	}
 }
+
+// parseTidyBaseline returns the clang-tidy checks to disable for each source file listed in the
+// tidy_baseline file, keyed by the path of the source file.
+func parseTidyBaseline(ctx ModuleContext, baseline android.Path) map[string][]string {
+	data, err := ctx.Config().ReadSourceFile(ctx, baseline.String())
+	if err != nil {
+		ctx.PropertyErrorf("tidy_baseline", "failed to read %s: %s", baseline, err)
+		return nil
+	}
+
+	checks := make(map[string][]string)
+	for i, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.SplitN(line, ":", 2)
+		src := strings.TrimSpace(fields[0])
+		if len(fields) != 2 || src == "" {
+			ctx.PropertyErrorf("tidy_baseline", "%s:%d: expected \"<source file>: <check>[,<check>...]\", got %q",
+				baseline, i+1, line)
+			continue
+		}
+		path := android.PathForModuleSrc(ctx, src).String()
+		for _, check := range strings.Split(fields[1], ",") {
+			if check = strings.TrimSpace(check); check != "" {
+				checks[path] = append(checks[path], check)
+			}
+		}
+	}
+	return checks
+}
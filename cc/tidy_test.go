@@ -0,0 +1,102 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cc
+
+import (
+	"strings"
+	"testing"
+
+	"android/soong/android"
+)
+
+func TestTidyBaseline(t *testing.T) {
+	bp := `
+		cc_library_shared {
+			name: "libfoo",
+			srcs: ["foo.c", "bar.c"],
+			tidy: true,
+			tidy_baseline: "tidy_baseline.txt",
+		}`
+
+	testCases := []struct {
+		name     string
+		baseline string
+		err      string
+	}{
+		{
+			name: "valid",
+			baseline: "# Existing findings\n" +
+				"foo.c: cert-err58-cpp, cert-env33-c\n" +
+				"\n" +
+				"bar.c: bugprone-macro-parentheses\n",
+		},
+		{
+			name:     "missing checks",
+			baseline: "foo.c\n",
+			err:      `tidy_baseline: tidy_baseline.txt:1: expected "<source file>: <check>\[,<check>...\]", got "foo.c"`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			errorHandler := android.FixtureExpectsNoErrors
+			if tc.err != "" {
+				errorHandler = android.FixtureExpectsAtLeastOneErrorMatchingPattern(tc.err)
+			}
+			result := android.GroupFixturePreparers(
+				prepareForCcTest,
+				android.FixtureAddTextFile("tidy_baseline.txt", tc.baseline),
+			).
+				ExtendWithErrorHandler(errorHandler).
+				RunTestWithBp(t, bp)
+			if tc.err != "" {
+				return
+			}
+
+			libfoo := result.ModuleForTests("libfoo", "android_arm64_armv8-a_shared")
+			tidyFlags := func(src string) string {
+				flags := libfoo.Output("obj/" + src + ".tidy").Args["tidyFlags"]
+				// Long flags are shared through module variables.
+				if strings.HasPrefix(flags, "$") {
+					flags = libfoo.VariablesForTestsRelativeToTop()[strings.TrimPrefix(flags, "$")]
+				}
+				return flags
+			}
+			android.AssertStringDoesContain(t, "foo.c tidy flags", tidyFlags("foo"),
+				",-cert-err58-cpp,-cert-env33-c")
+			android.AssertStringDoesContain(t, "bar.c tidy flags", tidyFlags("bar"),
+				",-bugprone-macro-parentheses")
+			android.AssertStringDoesNotContain(t, "bar.c tidy flags", tidyFlags("bar"),
+				"-cert-err58-cpp")
+		})
+	}
+}
+
+func TestTidyChangedSince(t *testing.T) {
+	result := android.GroupFixturePreparers(
+		prepareForCcTest,
+		android.FixtureMergeEnv(map[string]string{
+			"TIDY_CHANGED_SINCE": "HEAD~1; rm -rf out",
+		}),
+	).RunTestWithBp(t, `
+		cc_library_shared {
+			name: "libfoo",
+			srcs: ["foo.c"],
+			tidy: true,
+		}`)
+
+	tidy := result.ModuleForTests("libfoo", "android_arm64_armv8-a_shared").Output("obj/foo.tidy")
+	android.AssertStringEquals(t, "changedSince", "'HEAD~1; rm -rf out'", tidy.Args["changedSince"])
+}
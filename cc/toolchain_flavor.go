@@ -0,0 +1,107 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cc
+
+import (
+	"strings"
+
+	"android/soong/cc/config"
+)
+
+// toolchainFlavor returns the alternate clang toolchain that the module is built with, or nil
+// for the default clang toolchain. In order of precedence, the flavor is selected by the
+// toolchain_flavor property, the legacy sdclang property, the product variables that map
+// modules and paths to flavors, and the flavors that are enabled by default.
+func (c *Module) toolchainFlavor(ctx BaseModuleContext) *config.ToolchainFlavor {
+	name, property := "", ""
+	if c.Properties.Toolchain_flavor != nil {
+		name, property = *c.Properties.Toolchain_flavor, "toolchain_flavor"
+		if c.Properties.Sdclang != nil && Bool(c.Properties.Sdclang) != (name == config.SDClangToolchainFlavor) {
+			ctx.PropertyErrorf("sdclang", "conflicts with toolchain_flavor: %q", name)
+			return nil
+		}
+	} else if c.Properties.Sdclang != nil {
+		name, property = config.DefaultToolchainFlavor, "sdclang"
+		if Bool(c.Properties.Sdclang) {
+			name = config.SDClangToolchainFlavor
+		}
+	} else if mapped := ctx.Config().ToolchainFlavorForModule(ctx.ModuleDir(), ctx.ModuleName()); mapped != "" {
+		name = mapped
+	}
+
+	var flavor *config.ToolchainFlavor
+	if name == "" {
+		for _, n := range config.ToolchainFlavorNames() {
+			if f, _ := config.FindToolchainFlavor(n); f.EnabledByDefault != nil && f.EnabledByDefault() {
+				flavor = f
+				break
+			}
+		}
+	} else if name != config.DefaultToolchainFlavor {
+		f, ok := config.FindToolchainFlavor(name)
+		if !ok {
+			msg := "unknown toolchain flavor %q, must be %q or one of %s"
+			args := []interface{}{name, config.DefaultToolchainFlavor, strings.Join(config.ToolchainFlavorNames(), ", ")}
+			if property != "" {
+				ctx.PropertyErrorf(property, msg, args...)
+			} else {
+				ctx.ModuleErrorf("product maps the module to "+msg, args...)
+			}
+			return nil
+		}
+		flavor = f
+	}
+
+	// Toolchain flavors are not for host builds
+	if flavor == nil || ctx.Host() || (flavor.ForcedOff != nil && flavor.ForcedOff()) {
+		return nil
+	}
+	return flavor
+}
+
+// filterToolchainFlavorUnsupportedFlags removes the flags that the toolchain flavor of the module
+// doesn't support.
+func filterToolchainFlavorUnsupportedFlags(flags Flags) Flags {
+	if flags.ToolchainFlavor == nil || len(flags.ToolchainFlavor.UnsupportedFlags) == 0 {
+		return flags
+	}
+	unsupported := flags.ToolchainFlavor.UnsupportedFlags
+	for _, f := range []*LocalOrGlobalFlags{&flags.Global, &flags.Local} {
+		f.CommonFlags, _ = filterList(f.CommonFlags, unsupported)
+		f.AsFlags, _ = filterList(f.AsFlags, unsupported)
+		f.CFlags, _ = filterList(f.CFlags, unsupported)
+		f.ConlyFlags, _ = filterList(f.ConlyFlags, unsupported)
+		f.CppFlags, _ = filterList(f.CppFlags, unsupported)
+		f.LdFlags, _ = filterList(f.LdFlags, unsupported)
+	}
+	return flags
+}
+
+// clangBin returns the directory of the clang binaries to build with.
+func (flags builderFlags) clangBin() string {
+	if flags.toolchainFlavor != nil {
+		return flags.toolchainFlavor.ClangBin
+	}
+	return "${config.ClangBin}"
+}
+
+// toolchainFlavorFlags returns the flags to pass to every compile and link with the toolchain
+// flavor, prefixed with a space, or "" for the default toolchain.
+func (flags builderFlags) toolchainFlavorFlags() string {
+	if flags.toolchainFlavor == nil || flags.toolchainFlavor.Flags == "" {
+		return ""
+	}
+	return " " + flags.toolchainFlavor.Flags
+}
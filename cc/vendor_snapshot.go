@@ -95,17 +95,24 @@ func isSnapshotAware(cfg android.DeviceConfig, m LinkableInterface, inProprietar
 	// Libraries
 	if sanitizable, ok := m.(PlatformSanitizeable); ok && sanitizable.IsSnapshotLibrary() {
 		if sanitizable.SanitizePropDefined() {
-			// scs and hwasan export both sanitized and unsanitized variants for static and header
+			// hwasan exports both sanitized and unsanitized variants for static and header
 			// Always use unsanitized variants of them.
-			for _, t := range []SanitizerType{scs, Hwasan} {
-				if !sanitizable.Shared() && sanitizable.IsSanitizerEnabled(t) {
+			if !sanitizable.Shared() && sanitizable.IsSanitizerEnabled(Hwasan) {
+				return false
+			}
+			// cfi and scs also export both variants. But for static, we capture both.
+			// This is because cfi static libraries can't be linked from non-cfi modules,
+			// and vice versa. scs static libraries can, but modules built with scs against
+			// the snapshot would silently lose the scs instrumentation of their static libraries.
+			// This isn't the case for hwasan sanitizer.
+			for _, t := range []SanitizerType{cfi, scs} {
+				if !sanitizable.Static() && !sanitizable.Shared() && sanitizable.IsSanitizerEnabled(t) {
 					return false
 				}
 			}
-			// cfi also exports both variants. But for static, we capture both.
-			// This is because cfi static libraries can't be linked from non-cfi modules,
-			// and vice versa. This isn't the case for scs and hwasan sanitizers.
-			if !sanitizable.Static() && !sanitizable.Shared() && sanitizable.IsSanitizerEnabled(cfi) {
+			// The snapshot has a single sanitized variant of each static library, so variants
+			// with both cfi and scs enabled are not captured.
+			if sanitizable.Static() && sanitizable.IsSanitizerEnabled(cfi) && sanitizable.IsSanitizerEnabled(scs) {
 				return false
 			}
 		}
@@ -300,16 +307,25 @@ var ccSnapshotAction snapshot.GenerateSnapshotAction = func(s snapshot.SnapshotS
 			// install .a or .so
 			if libType != "header" {
 				libPath := m.OutputFile().Path()
+				if c, ok := m.(*Module); ok && c.fullArchiveFile().Valid() {
+					// Thin archives can't be copied out of the intermediates directory.
+					libPath = c.fullArchiveFile().Path()
+				}
 				stem = libPath.Base()
 				if sanitizable, ok := m.(PlatformSanitizeable); ok {
-					if (sanitizable.Static() || sanitizable.Rlib()) && sanitizable.SanitizePropDefined() && sanitizable.IsSanitizerEnabled(cfi) {
-						// both cfi and non-cfi variant for static libraries can exist.
-						// attach .cfi to distinguish between cfi and non-cfi.
-						// e.g. libbase.a -> libbase.cfi.a
-						ext := filepath.Ext(stem)
-						stem = strings.TrimSuffix(stem, ext) + ".cfi" + ext
-						prop.Sanitize = "cfi"
-						prop.ModuleName += ".cfi"
+					// memtag_heap isn't captured as it doesn't create variants of libraries, and
+					// memtag_stack isn't supported by this tree, so neither is recorded.
+					for _, t := range []SanitizerType{cfi, scs} {
+						if (sanitizable.Static() || sanitizable.Rlib()) && sanitizable.SanitizePropDefined() && sanitizable.IsSanitizerEnabled(t) {
+							// both sanitized and non-sanitized variant for static libraries can exist.
+							// attach the sanitizer name to distinguish between them.
+							// e.g. libbase.a -> libbase.cfi.a, libbase.scs.a
+							ext := filepath.Ext(stem)
+							stem = strings.TrimSuffix(stem, ext) + "." + t.variationName() + ext
+							prop.Sanitize = t.variationName()
+							prop.ModuleName += "." + t.variationName()
+							break
+						}
 					}
 				}
 				snapshotLibOut := filepath.Join(snapshotArchDir, targetArch, libType, stem)
@@ -984,7 +984,10 @@ func TestVendorSnapshotSanitizer(t *testing.T) {
 				src: "libsnapshot.a",
 				cfi: {
 					src: "libsnapshot.cfi.a",
-				}
+				},
+				scs: {
+					src: "libsnapshot.scs.a",
+				},
 			},
 		},
 	}
@@ -1019,6 +1022,7 @@ func TestVendorSnapshotSanitizer(t *testing.T) {
 		"vendor/libc++demangle.a":        nil,
 		"vendor/libsnapshot.a":           nil,
 		"vendor/libsnapshot.cfi.a":       nil,
+		"vendor/libsnapshot.scs.a":       nil,
 		"vendor/note_memtag_heap_sync.a": nil,
 	}
 
@@ -1036,6 +1040,51 @@ func TestVendorSnapshotSanitizer(t *testing.T) {
 
 	staticCfiModule := ctx.ModuleForTests("libsnapshot.vendor_static.28.arm64", staticCfiVariant).Module().(*Module)
 	assertString(t, staticCfiModule.outputFile.Path().Base(), "libsnapshot.cfi.a")
+
+	// Check scs variant.
+	staticScsVariant := "android_vendor.28_arm64_armv8-a_static_scs"
+	staticScsModule := ctx.ModuleForTests("libsnapshot.vendor_static.28.arm64", staticScsVariant).Module().(*Module)
+	assertString(t, staticScsModule.outputFile.Path().Base(), "libsnapshot.scs.a")
+}
+
+func TestVendorSnapshotCaptureScs(t *testing.T) {
+	bp := `
+	cc_library_static {
+		name: "libvendor_scs",
+		vendor: true,
+		nocrt: true,
+	}
+
+	cc_binary {
+		name: "vendor_scs_bin",
+		vendor: true,
+		nocrt: true,
+		compile_multilib: "64",
+		static_libs: ["libvendor_scs"],
+		sanitize: {
+			scs: true,
+		},
+	}
+`
+
+	config := TestConfig(t.TempDir(), android.Android, nil, bp, nil)
+	config.TestProductVariables.DeviceVndkVersion = StringPtr("current")
+	config.TestProductVariables.Platform_vndk_version = StringPtr("29")
+	ctx := testCcWithConfig(t, config)
+
+	snapshotSingleton := ctx.SingletonForTests("vendor-snapshot")
+	staticDir := "out/soong/vendor-snapshot/arm64/arch-arm64-armv8-a/static"
+
+	// Both the non-scs and the scs variants of the static library are captured.
+	CheckSnapshot(t, ctx, snapshotSingleton, "libvendor_scs", "libvendor_scs.a", staticDir,
+		"android_vendor.29_arm64_armv8-a_static")
+	CheckSnapshot(t, ctx, snapshotSingleton, "libvendor_scs", "libvendor_scs.scs.a", staticDir,
+		"android_vendor.29_arm64_armv8-a_static_scs")
+
+	jsonFile := filepath.Join(staticDir, "libvendor_scs.scs.a.json")
+	if snapshotSingleton.MaybeOutput(jsonFile).Rule == nil {
+		t.Errorf("%q expected but not found", jsonFile)
+	}
 }
 
 func TestVendorSnapshotExclude(t *testing.T) {
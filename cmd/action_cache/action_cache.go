@@ -0,0 +1,591 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// action_cache is a utility that wraps a compile command and caches its results in a local
+// directory, keyed on the command line and the contents of its inputs. Switching between build
+// configurations changes the command lines of the actions, which makes ninja run them again when
+// switching back; with the cache, the outputs of the previous configuration are restored instead.
+//
+// The output and depfile of the command are found from its -o and -MF arguments. The inputs are
+// the arguments that name existing files, and the files listed in the depfile, whose contents are
+// recorded in a manifest the first time the command is run.
+//
+// The output directory given with --out_dir is replaced with a placeholder in the cache, so that
+// the same compile in the output directories of two products, e.g. out_a and out_b, shares its
+// results. The paths below it are kept, so that the objects of different variants of a module,
+// whose intermediates directories differ, are never restored in place of each other. Only the keys
+// of this cache are normalized, not those of ccache or of remote execution.
+
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"android/soong/makedeps"
+)
+
+var (
+	cacheDir = flag.String("cache_dir", "", "directory to store the cached results in")
+	maxSize  = flag.Int64("max_size", 10<<30,
+		"maximum size in bytes of the cached results, above which the least recently used ones are evicted")
+	outDir = flag.String("out_dir", "", "output directory of the build, replaced with a placeholder in the cache keys")
+)
+
+// The number of sets of dependencies recorded for a command line and its inputs. Older sets are
+// dropped when a new set is recorded.
+const maxManifestEntries = 16
+
+// The minimum time between two evictions, as they walk the whole cache.
+const evictionInterval = 10 * time.Minute
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: %s --cache_dir DIR [--max_size BYTES] [--out_dir DIR] -- command [args...]\n", os.Args[0])
+	flag.PrintDefaults()
+	fmt.Fprintln(os.Stderr, "action_cache wraps a compile command and caches its results in a local directory,")
+	fmt.Fprintln(os.Stderr, "keyed on the command line and the contents of its inputs.")
+
+	os.Exit(2)
+}
+
+func main() {
+	flag.Usage = usage
+	flag.Parse()
+
+	if *cacheDir == "" {
+		fmt.Fprintf(os.Stderr, "%s: error: --cache_dir is required\n", os.Args[0])
+		usage()
+	}
+	if flag.NArg() < 1 {
+		fmt.Fprintf(os.Stderr, "%s: error: command is required\n", os.Args[0])
+		usage()
+	}
+
+	os.Exit(runCached(cache{dir: *cacheDir, maxSize: *maxSize, outDir: *outDir}, flag.Args(), os.Stdout, os.Stderr))
+}
+
+// action is a command whose results can be cached.
+type action struct {
+	args    []string
+	output  string
+	depfile string
+
+	// outDir is the output directory of the build, or "" if it is not normalized.
+	outDir string
+}
+
+const outDirPlaceholder = "__ACTION_CACHE_OUT_DIR__"
+
+// replacePathPrefix replaces old with new in s where old starts a path: at the start of s, after a
+// separator, or after a flag such as -I or -MF.
+func replacePathPrefix(s, old, new string) string {
+	var b strings.Builder
+	for {
+		i := strings.Index(s, old)
+		if i < 0 {
+			b.WriteString(s)
+			return b.String()
+		}
+		b.WriteString(s[:i])
+		prefix := s[:i]
+		if j := strings.LastIndexAny(prefix, " \t\n=:,"); j >= 0 {
+			prefix = prefix[j+1:]
+		}
+		if prefix == "" || (strings.HasPrefix(prefix, "-") && !strings.Contains(prefix, "/")) {
+			b.WriteString(new)
+		} else {
+			b.WriteString(old)
+		}
+		s = s[i+len(old):]
+	}
+}
+
+// normalize replaces the output directory of the build in s with a placeholder.
+func (a action) normalize(s string) string {
+	if a.outDir == "" {
+		return s
+	}
+	return replacePathPrefix(s, a.outDir+"/", outDirPlaceholder+"/")
+}
+
+// denormalize replaces the placeholder inserted by normalize with the output directory of the build.
+func (a action) denormalize(s string) string {
+	if a.outDir == "" {
+		return s
+	}
+	return replacePathPrefix(s, outDirPlaceholder+"/", a.outDir+"/")
+}
+
+// passthroughFlags are the flags of the compiler whose argument is passed on to another tool, so it
+// may look like -o or -MF without being the output or the depfile of the command.
+var passthroughFlags = map[string]bool{
+	"-Xassembler":    true,
+	"-Xclang":        true,
+	"-Xlinker":       true,
+	"-Xpreprocessor": true,
+	"-mllvm":         true,
+}
+
+// isJoinedOutputFlag returns true if arg is -o joined with the output file.  The other flags of
+// the compiler that start with -o all start with -obj, e.g. -objcmt-migrate-all or
+// -object-file-name=.
+func isJoinedOutputFlag(arg string) bool {
+	return len(arg) > len("-o") && strings.HasPrefix(arg, "-o") && !strings.HasPrefix(arg, "-obj")
+}
+
+// parseAction finds the output and depfile of a compile command.
+func parseAction(args []string) (action, error) {
+	a := action{args: args}
+	for i := 1; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case passthroughFlags[arg]:
+			i++
+		case arg == "-o" || arg == "-MF":
+			if i+1 == len(args) {
+				return action{}, fmt.Errorf("missing argument to %s", arg)
+			}
+			i++
+			if arg == "-o" {
+				a.output = args[i]
+			} else {
+				a.depfile = args[i]
+			}
+		case isJoinedOutputFlag(arg):
+			a.output = strings.TrimPrefix(arg, "-o")
+		case strings.HasPrefix(arg, "-MF"):
+			a.depfile = strings.TrimPrefix(arg, "-MF")
+		}
+	}
+	if a.output == "" {
+		return action{}, errors.New("no output file (-o) in the command")
+	}
+	return a, nil
+}
+
+// runCached runs the action, or restores its results from the cache, and returns its exit code.
+// Failures to use the cache are reported as warnings, and the action is run instead.
+func runCached(c cache, args []string, stdout, stderr io.Writer) int {
+	warn := func(err error) {
+		fmt.Fprintf(stderr, "action_cache: warning: %s\n", err)
+	}
+
+	a, err := parseAction(args)
+	if err != nil {
+		warn(err)
+		return run(args, stdout, stderr, nil, nil)
+	}
+	a.outDir = strings.TrimSuffix(c.outDir, "/")
+
+	key, err := a.key()
+	if err != nil {
+		warn(err)
+		return run(args, stdout, stderr, nil, nil)
+	}
+
+	if result, ok := c.lookup(key, a); ok {
+		err := c.restore(result, a, stdout, stderr)
+		if err == nil {
+			return 0
+		}
+		warn(fmt.Errorf("failed to restore cached result: %w", err))
+	}
+
+	var outBuf, errBuf bytes.Buffer
+	if exitCode := run(args, stdout, stderr, &outBuf, &errBuf); exitCode != 0 {
+		return exitCode
+	}
+	if err := c.store(key, a, outBuf.Bytes(), errBuf.Bytes()); err != nil {
+		warn(fmt.Errorf("failed to store result: %w", err))
+	}
+	if err := c.maybeEvict(); err != nil {
+		warn(fmt.Errorf("failed to evict results: %w", err))
+	}
+	return 0
+}
+
+// run runs the command, copying its output to stdout and stderr, and also to outCopy and errCopy
+// if they are not nil, and returns its exit code.
+func run(args []string, stdout, stderr io.Writer, outCopy, errCopy io.Writer) int {
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	if outCopy != nil {
+		cmd.Stdout = io.MultiWriter(stdout, outCopy)
+	}
+	if errCopy != nil {
+		cmd.Stderr = io.MultiWriter(stderr, errCopy)
+	}
+
+	if err := cmd.Run(); err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() > 0 {
+			return exitErr.ExitCode()
+		}
+		fmt.Fprintf(stderr, "action_cache: error: %s\n", err)
+		return 1
+	}
+	return 0
+}
+
+// key returns the digest of the normalized command line and of the contents of the files it
+// names, except the command itself and its outputs.
+func (a action) key() (string, error) {
+	h := sha256.New()
+	for _, arg := range a.args {
+		fmt.Fprintf(h, "%s\x00", a.normalize(arg))
+	}
+	for _, arg := range a.args[1:] {
+		if arg == a.output || arg == a.depfile {
+			continue
+		}
+		if fi, err := os.Stat(arg); err != nil || !fi.Mode().IsRegular() {
+			continue
+		}
+		digest, err := fileDigest(arg)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(h, "%s\x00%s\x00", a.normalize(arg), digest)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func fileDigest(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// manifest records, for a key, the results of the action for the contents of the dependencies
+// listed in its depfile.
+type manifest struct {
+	Entries []manifestEntry
+}
+
+type manifestEntry struct {
+	// Digests of the contents of the dependencies, by path.
+	Deps map[string]string
+	// Digest of the result in the cache.
+	Result string
+}
+
+// cache is a directory containing the manifests of the actions, by key, and the results they
+// refer to. The results are evicted in least recently used order when their total size is above
+// maxSize, the modification times of their directories recording when they were last used.
+type cache struct {
+	dir     string
+	maxSize int64
+	outDir  string
+}
+
+func (c cache) manifestPath(key string) string {
+	return filepath.Join(c.dir, "manifests", key[:2], key+".json")
+}
+
+func (c cache) resultDir(result string) string {
+	return filepath.Join(c.dir, "results", result[:2], result)
+}
+
+func (c cache) readManifest(key string) manifest {
+	var m manifest
+	if data, err := ioutil.ReadFile(c.manifestPath(key)); err == nil {
+		// A corrupt manifest is treated as an empty one, and is replaced by the next store.
+		if json.Unmarshal(data, &m) != nil {
+			m = manifest{}
+		}
+	}
+	return m
+}
+
+// lookup returns the result of the first entry of the manifest for key whose dependencies have
+// the same contents as the current ones.
+func (c cache) lookup(key string, a action) (string, bool) {
+	digests := make(map[string]string)
+	matches := func(deps map[string]string) bool {
+		for path, want := range deps {
+			path = a.denormalize(path)
+			got, ok := digests[path]
+			if !ok {
+				var err error
+				if got, err = fileDigest(path); err != nil {
+					got = ""
+				}
+				digests[path] = got
+			}
+			if got != want {
+				return false
+			}
+		}
+		return true
+	}
+
+	for _, entry := range c.readManifest(key).Entries {
+		// The result may have been evicted.
+		if _, err := os.Stat(c.resultDir(entry.Result)); err != nil {
+			continue
+		}
+		if matches(entry.Deps) {
+			return entry.Result, true
+		}
+	}
+	return "", false
+}
+
+// restore copies the cached result to the outputs of the action, and replays its output.
+func (c cache) restore(result string, a action, stdout, stderr io.Writer) error {
+	dir := c.resultDir(result)
+	if err := copyFileAtomic(filepath.Join(dir, "output"), a.output); err != nil {
+		return err
+	}
+	if a.depfile != "" {
+		data, err := ioutil.ReadFile(filepath.Join(dir, "depfile"))
+		if err != nil {
+			return err
+		}
+		if err := writeFileAtomic(a.depfile, []byte(a.denormalize(string(data)))); err != nil {
+			return err
+		}
+	}
+	for name, w := range map[string]io.Writer{"stdout": stdout, "stderr": stderr} {
+		data, err := ioutil.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			return err
+		}
+		w.Write(data)
+	}
+	now := time.Now()
+	return os.Chtimes(dir, now, now)
+}
+
+// store records the results of the action, which was just run, in the cache.
+func (c cache) store(key string, a action, stdout, stderr []byte) error {
+	deps := make(map[string]string)
+	if a.depfile != "" {
+		data, err := ioutil.ReadFile(a.depfile)
+		if err != nil {
+			return err
+		}
+		d, err := makedeps.Parse(a.depfile, bytes.NewReader(data))
+		if err != nil {
+			return err
+		}
+		for _, input := range d.Inputs {
+			if deps[a.normalize(input)], err = fileDigest(input); err != nil {
+				return err
+			}
+		}
+	}
+
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\x00", key)
+	paths := make([]string, 0, len(deps))
+	for path := range deps {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		fmt.Fprintf(h, "%s\x00%s\x00", path, deps[path])
+	}
+	result := hex.EncodeToString(h.Sum(nil))
+
+	if err := c.storeResult(result, a, stdout, stderr); err != nil {
+		return err
+	}
+
+	m := c.readManifest(key)
+	entries := []manifestEntry{{Deps: deps, Result: result}}
+	for _, entry := range m.Entries {
+		if entry.Result != result && len(entries) < maxManifestEntries {
+			entries = append(entries, entry)
+		}
+	}
+	data, err := json.Marshal(manifest{Entries: entries})
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(c.manifestPath(key), data)
+}
+
+// storeResult copies the outputs of the action into the directory of the result. The directory
+// is populated next to its final location and renamed, so that concurrent actions never see a
+// partial result.
+func (c cache) storeResult(result string, a action, stdout, stderr []byte) error {
+	dir := c.resultDir(result)
+	if _, err := os.Stat(dir); err == nil {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(dir), 0777); err != nil {
+		return err
+	}
+	tmpDir, err := ioutil.TempDir(filepath.Dir(dir), ".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	if err := copyFile(a.output, filepath.Join(tmpDir, "output")); err != nil {
+		return err
+	}
+	if a.depfile != "" {
+		data, err := ioutil.ReadFile(a.depfile)
+		if err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(filepath.Join(tmpDir, "depfile"), []byte(a.normalize(string(data))), 0666); err != nil {
+			return err
+		}
+	}
+	if err := ioutil.WriteFile(filepath.Join(tmpDir, "stdout"), stdout, 0666); err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(filepath.Join(tmpDir, "stderr"), stderr, 0666); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmpDir, dir); err != nil {
+		// Another action stored the same result first.
+		if _, statErr := os.Stat(dir); statErr == nil {
+			return nil
+		}
+		return err
+	}
+	return nil
+}
+
+// maybeEvict evicts results from the cache if it wasn't done in the last evictionInterval.
+func (c cache) maybeEvict() error {
+	if c.maxSize <= 0 {
+		return nil
+	}
+	stamp := filepath.Join(c.dir, "last_eviction")
+	if fi, err := os.Stat(stamp); err == nil && time.Since(fi.ModTime()) < evictionInterval {
+		return nil
+	}
+	if err := writeFileAtomic(stamp, nil); err != nil {
+		return err
+	}
+	return c.evict()
+}
+
+// evict removes the least recently used results until the total size of the results is at most
+// maxSize. The manifest entries that refer to the removed results are skipped by lookup.
+func (c cache) evict() error {
+	type result struct {
+		dir  string
+		size int64
+		used time.Time
+	}
+	var results []result
+	var total int64
+
+	dirs, err := filepath.Glob(filepath.Join(c.dir, "results", "*", "*"))
+	if err != nil {
+		return err
+	}
+	for _, dir := range dirs {
+		// Skip the results that are being stored.
+		if strings.HasPrefix(filepath.Base(dir), ".") {
+			continue
+		}
+		fi, err := os.Stat(dir)
+		if err != nil {
+			continue
+		}
+		r := result{dir: dir, used: fi.ModTime()}
+		files, err := ioutil.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, f := range files {
+			r.size += f.Size()
+		}
+		results = append(results, r)
+		total += r.size
+	}
+
+	sort.Slice(results, func(i, j int) bool { return results[i].used.Before(results[j].used) })
+	for _, r := range results {
+		if total <= c.maxSize {
+			break
+		}
+		if err := os.RemoveAll(r.dir); err != nil {
+			return err
+		}
+		total -= r.size
+	}
+	return nil
+}
+
+func copyFile(from, to string) error {
+	data, err := ioutil.ReadFile(from)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(to, data, 0666)
+}
+
+func copyFileAtomic(from, to string) error {
+	data, err := ioutil.ReadFile(from)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(to, data)
+}
+
+// writeFileAtomic writes data to a temporary file next to path and renames it to path.
+func writeFileAtomic(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0777); err != nil {
+		return err
+	}
+	f, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Chmod(0644); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
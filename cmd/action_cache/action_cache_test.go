@@ -0,0 +1,318 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseAction(t *testing.T) {
+	testCases := []struct {
+		name    string
+		args    []string
+		output  string
+		depfile string
+		err     bool
+	}{
+		{
+			name:    "separate arguments",
+			args:    []string{"clang", "-c", "-MD", "-MF", "out/a.o.d", "-o", "out/a.o", "a.c"},
+			output:  "out/a.o",
+			depfile: "out/a.o.d",
+		},
+		{
+			name:    "joined arguments",
+			args:    []string{"clang", "-c", "-MFout/a.o.d", "-oout/a.o", "a.c"},
+			output:  "out/a.o",
+			depfile: "out/a.o.d",
+		},
+		{
+			name: "flags starting with -o",
+			args: []string{"clang", "-c", "-objcmt-migrate-all", "-o", "out/a.o",
+				"-Xclang", "-object-file-name=out/b.o", "a.c"},
+			output: "out/a.o",
+		},
+		{
+			name:   "passthrough arguments",
+			args:   []string{"clang", "-c", "-o", "out/a.o", "-Xclang", "-oout/b.o", "-Xlinker", "-o", "a.c"},
+			output: "out/a.o",
+		},
+		{
+			name:   "no depfile",
+			args:   []string{"clang", "-c", "-o", "out/a.o", "a.c"},
+			output: "out/a.o",
+		},
+		{
+			name: "no output",
+			args: []string{"clang", "-c", "a.c"},
+			err:  true,
+		},
+		{
+			name: "missing output",
+			args: []string{"clang", "-c", "a.c", "-o"},
+			err:  true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			a, err := parseAction(tc.args)
+			if tc.err {
+				if err == nil {
+					t.Fatalf("expected error, got %+v", a)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if a.output != tc.output || a.depfile != tc.depfile {
+				t.Errorf("expected output %q and depfile %q, got %q and %q",
+					tc.output, tc.depfile, a.output, a.depfile)
+			}
+			if !reflect.DeepEqual(a.args, tc.args) {
+				t.Errorf("expected args %q, got %q", tc.args, a.args)
+			}
+		})
+	}
+}
+
+func TestRunCached(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "action_cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	write := func(name, contents string) {
+		t.Helper()
+		if err := ioutil.WriteFile(filepath.Join(tmpDir, name), []byte(contents), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	read := func(name string) string {
+		t.Helper()
+		data, err := ioutil.ReadFile(filepath.Join(tmpDir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(data)
+	}
+
+	write("a.c", "#include \"a.h\"\n")
+	write("a.h", "int a;\n")
+
+	// A fake compiler that concatenates its source and header, writes a depfile, prints a warning
+	// and records how many times it ran.
+	src := filepath.Join(tmpDir, "a.c")
+	hdr := filepath.Join(tmpDir, "a.h")
+	out := filepath.Join(tmpDir, "a.o")
+	depfile := filepath.Join(tmpDir, "a.o.d")
+	count := filepath.Join(tmpDir, "count")
+	script := `cat "$5" ` + hdr + ` > "$4" && echo "$4: $5 ` + hdr + `" > "$2" && ` +
+		`echo warning >&2 && echo x >> ` + count
+	args := []string{"sh", "-c", script, "cc", "-MF", depfile, "-o", out, src}
+	cacheDir := filepath.Join(tmpDir, "cache")
+
+	build := func(wantOutput string, wantRuns int) {
+		t.Helper()
+		os.Remove(out)
+		os.Remove(depfile)
+
+		var stdout, stderr bytes.Buffer
+		if exitCode := runCached(cache{dir: cacheDir}, args, &stdout, &stderr); exitCode != 0 {
+			t.Fatalf("expected exit code 0, got %d: %s", exitCode, stderr.String())
+		}
+		if got := read("a.o"); got != wantOutput {
+			t.Errorf("expected output %q, got %q", wantOutput, got)
+		}
+		if got, want := read("a.o.d"), out+": "+src+" "+hdr+"\n"; got != want {
+			t.Errorf("expected depfile %q, got %q", want, got)
+		}
+		if got := stderr.String(); got != "warning\n" {
+			t.Errorf("expected stderr %q, got %q", "warning\n", got)
+		}
+		if got := len(read("count")) / 2; got != wantRuns {
+			t.Errorf("expected the command to have run %d times, got %d", wantRuns, got)
+		}
+	}
+
+	build("#include \"a.h\"\nint a;\n", 1)
+	// Rebuilding with the same inputs restores the cached result.
+	build("#include \"a.h\"\nint a;\n", 1)
+
+	// Changing a dependency from the depfile runs the command again.
+	write("a.h", "int b;\n")
+	build("#include \"a.h\"\nint b;\n", 2)
+
+	// Switching back to the previous contents restores the first result.
+	write("a.h", "int a;\n")
+	build("#include \"a.h\"\nint a;\n", 2)
+
+	// Changing the source, which is on the command line, runs the command again.
+	write("a.c", "#include \"a.h\"\n\n")
+	build("#include \"a.h\"\n\nint a;\n", 3)
+}
+
+func TestRunCachedFailure(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "action_cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	cacheDir := filepath.Join(tmpDir, "cache")
+	out := filepath.Join(tmpDir, "a.o")
+	args := []string{"sh", "-c", `echo error >&2; exit 3`, "cc", "-o", out}
+
+	var stdout, stderr bytes.Buffer
+	if exitCode := runCached(cache{dir: cacheDir}, args, &stdout, &stderr); exitCode != 3 {
+		t.Errorf("expected exit code 3, got %d", exitCode)
+	}
+	if got := stderr.String(); got != "error\n" {
+		t.Errorf("expected stderr %q, got %q", "error\n", got)
+	}
+	if _, err := os.Stat(filepath.Join(cacheDir, "manifests")); !os.IsNotExist(err) {
+		t.Errorf("expected no manifests to be stored for a failed command, got %v", err)
+	}
+}
+
+func TestRunCachedOutputPaths(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "action_cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	src := filepath.Join(tmpDir, "a.c")
+	if err := ioutil.WriteFile(src, []byte("int a;\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	count := filepath.Join(tmpDir, "count")
+	cacheDir := filepath.Join(tmpDir, "cache")
+
+	testCases := []struct {
+		name    string
+		outDir  string
+		variant string
+		runs    int
+	}{
+		{
+			name:    "product1",
+			outDir:  "out_product1",
+			variant: "android_arm64_armv8-a_static",
+			runs:    1,
+		},
+		{
+			// The same compile in the output directory of another product shares its result.
+			name:    "product2",
+			outDir:  "out_product2",
+			variant: "android_arm64_armv8-a_static",
+			runs:    1,
+		},
+		{
+			// Another variant of the module must not restore the object of the first one.
+			name:    "asan variant",
+			outDir:  "out_product1",
+			variant: "android_arm64_armv8-a_static_asan",
+			runs:    2,
+		},
+	}
+
+	for _, tc := range testCases {
+		outDir := filepath.Join(tmpDir, tc.outDir)
+		objDir := filepath.Join(outDir, "soong/.intermediates/a", tc.variant, "obj")
+		if err := os.MkdirAll(objDir, 0777); err != nil {
+			t.Fatal(err)
+		}
+		out := filepath.Join(objDir, "a.o")
+		depfile := out + ".d"
+		genDir := filepath.Join(outDir, "soong/.intermediates/a", tc.variant, "gen")
+		script := `cat "$7" > "$4" && echo "$4: $7" > "$2" && echo x >> ` + count
+		args := []string{"sh", "-c", script, "cc", "-MF", depfile, "-o", out, "-I" + genDir,
+			"-fdebug-prefix-map=" + outDir + "/=", src}
+
+		var stdout, stderr bytes.Buffer
+		if exitCode := runCached(cache{dir: cacheDir, outDir: outDir}, args, &stdout, &stderr); exitCode != 0 {
+			t.Fatalf("%s: expected exit code 0, got %d: %s", tc.name, exitCode, stderr.String())
+		}
+
+		if data, err := ioutil.ReadFile(out); err != nil {
+			t.Fatal(err)
+		} else if string(data) != "int a;\n" {
+			t.Errorf("%s: expected output %q, got %q", tc.name, "int a;\n", string(data))
+		}
+		// The restored depfile must name the output of this action, not the cached one.
+		if data, err := ioutil.ReadFile(depfile); err != nil {
+			t.Fatal(err)
+		} else if want := out + ": " + src + "\n"; string(data) != want {
+			t.Errorf("%s: expected depfile %q, got %q", tc.name, want, string(data))
+		}
+		if data, err := ioutil.ReadFile(count); err != nil {
+			t.Fatal(err)
+		} else if len(data)/2 != tc.runs {
+			t.Errorf("%s: expected the command to have run %d times, got %d", tc.name, tc.runs, len(data)/2)
+		}
+	}
+}
+
+func TestEvict(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "action_cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	c := cache{dir: filepath.Join(tmpDir, "cache"), maxSize: 20}
+	// Three results of 10 bytes, used from the oldest to the most recent one.
+	now := time.Now()
+	for i, result := range []string{"aa00", "bb00", "cc00"} {
+		dir := c.resultDir(result)
+		if err := os.MkdirAll(dir, 0777); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(dir, "output"), []byte("0123456789"), 0666); err != nil {
+			t.Fatal(err)
+		}
+		used := now.Add(time.Duration(i-3) * time.Hour)
+		if err := os.Chtimes(dir, used, used); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := c.maybeEvict(); err != nil {
+		t.Fatal(err)
+	}
+	for result, want := range map[string]bool{"aa00": false, "bb00": true, "cc00": true} {
+		if _, err := os.Stat(c.resultDir(result)); (err == nil) != want {
+			t.Errorf("expected result %s to be kept: %v, got error %v", result, want, err)
+		}
+	}
+
+	// The cache isn't walked again within the eviction interval.
+	c.maxSize = 1
+	if err := c.maybeEvict(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(c.resultDir("bb00")); err != nil {
+		t.Errorf("expected no eviction within the eviction interval, got %v", err)
+	}
+}
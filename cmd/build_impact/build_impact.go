@@ -0,0 +1,148 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// build_impact reports the modules and outputs that a set of changed files would dirty, without
+// building anything, from the build graph of a previous build, e.g. out/combined-<product>.ninja.
+// The actions are followed through their explicit and implicit inputs, and through the headers
+// and other dependencies ninja discovered from depfiles when given the output of `ninja -t deps`,
+// so CI can select the tests to run from the real dependencies instead of path heuristics.
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"android/soong/ninja_graph"
+)
+
+var (
+	topDir     = flag.String("C", ".", "directory that ninja runs in, which the paths in the manifest are relative to")
+	ninjaFile  = flag.String("f", "", "ninja manifest of the build, e.g. out/combined-<product>.ninja")
+	depsFile   = flag.String("deps", "", "output of `ninja -t deps` for the discovered dependencies of the build")
+	filesFrom  = flag.String("files_from", "", "file that lists the changed files one per line, or - for stdin")
+	jsonOutput = flag.Bool("json", false, "print the impact as JSON")
+)
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: build_impact -f <build.ninja> [flags] [changed files...]\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if *ninjaFile == "" {
+		fmt.Fprintf(os.Stderr, "Error, -f is required\n")
+		os.Exit(1)
+	}
+
+	changed := flag.Args()
+	if *filesFrom != "" {
+		files, err := readFileList(*filesFrom)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading changed files from %v: %v\n", *filesFrom, err)
+			os.Exit(1)
+		}
+		changed = append(changed, files...)
+	}
+
+	graph, err := ninja_graph.Parse(*ninjaFile, *topDir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error parsing ninja file %v: %v\n", *ninjaFile, err)
+		os.Exit(1)
+	}
+
+	var deps map[string][]string
+	if *depsFile != "" {
+		f, err := os.Open(*depsFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error opening deps file: %v\n", err)
+			os.Exit(1)
+		}
+		deps, err = parseNinjaDeps(f)
+		f.Close()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error parsing deps file %v: %v\n", *depsFile, err)
+			os.Exit(1)
+		}
+	}
+
+	paths, err := relativePaths(changed, *topDir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	result := computeImpact(graph, deps, paths)
+
+	if *jsonOutput {
+		data, err := json.MarshalIndent(result, "", "  ")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Println(string(data))
+	} else {
+		fmt.Print(result.summary())
+	}
+}
+
+// readFileList reads the non-empty lines of the file, or of stdin if the path is "-".
+func readFileList(path string) ([]string, error) {
+	var r io.Reader = os.Stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	var files []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			files = append(files, line)
+		}
+	}
+	return files, scanner.Err()
+}
+
+// relativePaths converts the changed files to the paths used by the manifest, which are relative to
+// the directory ninja runs in.
+func relativePaths(files []string, topDir string) ([]string, error) {
+	absTopDir, err := filepath.Abs(topDir)
+	if err != nil {
+		return nil, err
+	}
+
+	var ret []string
+	for _, file := range files {
+		if filepath.IsAbs(file) {
+			rel, err := filepath.Rel(absTopDir, file)
+			if err != nil {
+				return nil, err
+			}
+			file = rel
+		}
+		ret = append(ret, filepath.Clean(file))
+	}
+	return ret, nil
+}
@@ -0,0 +1,180 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"android/soong/ninja_graph"
+)
+
+// impactModule is a module variant, or a singleton, with actions dirtied by the changed files.
+type impactModule struct {
+	Name    string `json:"name"`
+	Variant string `json:"variant,omitempty"`
+}
+
+func (m impactModule) String() string {
+	if m.Variant == "" {
+		return m.Name
+	}
+	return m.Name + " (" + m.Variant + ")"
+}
+
+type impact struct {
+	// The number of actions, excluding phony ones, that would rerun.
+	Actions int `json:"actions"`
+
+	Modules []impactModule `json:"modules"`
+	Outputs []string       `json:"outputs"`
+
+	// The dirtied outputs that are ninja manifests.  When the manifest of the build would be
+	// regenerated the changes can also add or modify actions, which the analysis can't predict.
+	Manifests []string `json:"manifests,omitempty"`
+}
+
+// computeImpact returns the actions that would rerun if the changed files were modified, following
+// the explicit and implicit inputs of the actions and the discovered deps, which map outputs to the
+// dependencies ninja read from their depfiles.  Order-only inputs are not followed, as ninja doesn't
+// rerun an action when they change.  Phony actions only forward the changes to the actions that use
+// them, and are not reported.
+func computeImpact(graph *ninja_graph.Graph, deps map[string][]string, changed []string) impact {
+	users := make(map[string][]*ninja_graph.Action)
+	producers := make(map[string]*ninja_graph.Action)
+	for _, action := range graph.Actions {
+		for _, input := range action.Inputs {
+			users[filepath.Clean(input)] = append(users[filepath.Clean(input)], action)
+		}
+		for _, input := range action.Implicits {
+			users[filepath.Clean(input)] = append(users[filepath.Clean(input)], action)
+		}
+		for _, output := range action.Outputs {
+			producers[filepath.Clean(output)] = action
+		}
+	}
+	for output, outputDeps := range deps {
+		if action := producers[filepath.Clean(output)]; action != nil {
+			for _, dep := range outputDeps {
+				users[filepath.Clean(dep)] = append(users[filepath.Clean(dep)], action)
+			}
+		}
+	}
+
+	dirty := make(map[*ninja_graph.Action]bool)
+	queue := append([]string(nil), changed...)
+	for len(queue) > 0 {
+		path := queue[0]
+		queue = queue[1:]
+		for _, action := range users[path] {
+			if dirty[action] {
+				continue
+			}
+			dirty[action] = true
+			for _, output := range action.Outputs {
+				queue = append(queue, filepath.Clean(output))
+			}
+		}
+	}
+
+	var ret impact
+	modules := make(map[impactModule]bool)
+	for _, action := range graph.Actions {
+		if !dirty[action] || action.Rule == "phony" {
+			continue
+		}
+		ret.Actions++
+		if action.Module != "" {
+			modules[impactModule{Name: action.Module, Variant: action.Variant}] = true
+		}
+		for _, output := range action.Outputs {
+			ret.Outputs = append(ret.Outputs, output)
+			if strings.HasSuffix(output, ".ninja") {
+				ret.Manifests = append(ret.Manifests, output)
+			}
+		}
+	}
+
+	for module := range modules {
+		ret.Modules = append(ret.Modules, module)
+	}
+	sort.Slice(ret.Modules, func(i, j int) bool {
+		return ret.Modules[i].String() < ret.Modules[j].String()
+	})
+	sort.Strings(ret.Outputs)
+	sort.Strings(ret.Manifests)
+
+	return ret
+}
+
+func (i impact) summary() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "dirty actions: %d\n", i.Actions)
+	if len(i.Manifests) > 0 {
+		fmt.Fprintf(&sb, "\nregenerated manifests, the actions they declare may change:\n")
+		for _, manifest := range i.Manifests {
+			fmt.Fprintf(&sb, "  %s\n", manifest)
+		}
+	}
+	if len(i.Modules) > 0 {
+		fmt.Fprintf(&sb, "\nmodules:\n")
+		for _, module := range i.Modules {
+			fmt.Fprintf(&sb, "  %s\n", module)
+		}
+	}
+	if len(i.Outputs) > 0 {
+		fmt.Fprintf(&sb, "\noutputs:\n")
+		for _, output := range i.Outputs {
+			fmt.Fprintf(&sb, "  %s\n", output)
+		}
+	}
+	return sb.String()
+}
+
+// parseNinjaDeps parses the output of `ninja -t deps`, which lists the dependencies discovered from
+// the depfile of each output:
+//
+//	out/foo.o: #deps 2, deps mtime 1234 (VALID)
+//	    foo.c
+//	    foo.h
+func parseNinjaDeps(r io.Reader) (map[string][]string, error) {
+	deps := make(map[string][]string)
+	output := ""
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		text := scanner.Text()
+		switch {
+		case strings.TrimSpace(text) == "":
+			output = ""
+		case text[0] == ' ' || text[0] == '\t':
+			if output == "" {
+				return nil, fmt.Errorf("line %d: dependency without an output", line)
+			}
+			deps[output] = append(deps[output], strings.TrimSpace(text))
+		default:
+			i := strings.Index(text, ": #deps")
+			if i < 0 {
+				return nil, fmt.Errorf("line %d: expected \"<output>: #deps\", got %q", line, text)
+			}
+			output = text[:i]
+		}
+	}
+	return deps, scanner.Err()
+}
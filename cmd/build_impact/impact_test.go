@@ -0,0 +1,122 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"android/soong/ninja_graph"
+)
+
+func TestComputeImpact(t *testing.T) {
+	graph := &ninja_graph.Graph{Actions: []*ninja_graph.Action{
+		{Module: "libfoo", Variant: "shared", Rule: "cc", Outputs: []string{"out/foo.o"}, Inputs: []string{"foo.c"}},
+		{Module: "libfoo", Variant: "shared", Rule: "ld", Outputs: []string{"out/libfoo.so"}, Inputs: []string{"out/foo.o"}},
+		{Module: "libbar", Variant: "shared", Rule: "cc", Outputs: []string{"out/bar.o"}, Inputs: []string{"bar.c"}},
+		{Module: "libbar", Variant: "shared", Rule: "ld", Outputs: []string{"out/libbar.so"},
+			Inputs: []string{"out/bar.o"}, Implicits: []string{"out/libfoo.so"}},
+		{Module: "libbaz", Variant: "shared", Rule: "cc", Outputs: []string{"out/baz.o"},
+			Inputs: []string{"baz.c"}, OrderOnly: []string{"out/libfoo.so"}},
+		{Module: "libfoo", Rule: "phony", Outputs: []string{"libfoo"}, Inputs: []string{"out/libfoo.so"}},
+		{Module: "installed_files", Rule: "touch", Outputs: []string{"out/installed.txt"}, Implicits: []string{"libfoo"}},
+		{Module: "soong_build", Rule: "soong_build", Outputs: []string{"out/soong/build.ninja"}, Implicits: []string{"Android.bp"}},
+	}}
+
+	testCases := []struct {
+		name    string
+		deps    map[string][]string
+		changed []string
+		want    impact
+	}{
+		{
+			name:    "source",
+			changed: []string{"foo.c"},
+			want: impact{
+				Actions: 4,
+				Modules: []impactModule{
+					{Name: "installed_files"},
+					{Name: "libbar", Variant: "shared"},
+					{Name: "libfoo", Variant: "shared"},
+				},
+				Outputs: []string{"out/foo.o", "out/installed.txt", "out/libbar.so", "out/libfoo.so"},
+			},
+		},
+		{
+			name:    "discovered header",
+			deps:    map[string][]string{"out/bar.o": {"bar.c", "bar.h"}},
+			changed: []string{"./bar.h"},
+			want: impact{
+				Actions: 2,
+				Modules: []impactModule{{Name: "libbar", Variant: "shared"}},
+				Outputs: []string{"out/bar.o", "out/libbar.so"},
+			},
+		},
+		{
+			name:    "blueprint file",
+			changed: []string{"Android.bp"},
+			want: impact{
+				Actions:   1,
+				Modules:   []impactModule{{Name: "soong_build"}},
+				Outputs:   []string{"out/soong/build.ninja"},
+				Manifests: []string{"out/soong/build.ninja"},
+			},
+		},
+		{
+			name:    "unused file",
+			changed: []string{"README.md"},
+			want:    impact{},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			changed, err := relativePaths(tc.changed, ".")
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := computeImpact(graph, tc.deps, changed)
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got %+v\nwant %+v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestParseNinjaDeps(t *testing.T) {
+	deps, err := parseNinjaDeps(strings.NewReader(`out/foo.o: #deps 2, deps mtime 1234 (VALID)
+    foo.c
+    include/foo.h
+
+out/bar.o: #deps 1, deps mtime 1234 (STALE)
+    bar.c
+
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string][]string{
+		"out/foo.o": {"foo.c", "include/foo.h"},
+		"out/bar.o": {"bar.c"},
+	}
+	if !reflect.DeepEqual(deps, want) {
+		t.Errorf("got %q, want %q", deps, want)
+	}
+
+	if _, err := parseNinjaDeps(strings.NewReader("    foo.c\n")); err == nil {
+		t.Errorf("expected error for a dependency without an output")
+	}
+}
@@ -0,0 +1,317 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"android/soong/ninja_graph"
+)
+
+// The causes of a changed action.
+const (
+	causeRule   = "rule"
+	causeInputs = "inputs"
+	causeFlags  = "flags"
+	causeEnv    = "env"
+)
+
+var allCauses = []string{causeRule, causeInputs, causeFlags, causeEnv}
+
+// envAssignmentRe matches the environment variable assignments in commands, e.g.
+// "ANDROID_RUST_VERSION=1.59.0" or "export PATH=...".
+var envAssignmentRe = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*=`)
+
+// changedAction is an action that exists in both graphs with a different command or inputs.
+type changedAction struct {
+	a, b   *ninja_graph.Action
+	causes []string
+
+	// The command-line words only in the command of a, and only in the command of b.
+	removedWords []string
+	addedWords   []string
+}
+
+type graphDiff struct {
+	actionsA, actionsB int
+
+	changed []changedAction
+	onlyInA []*ninja_graph.Action
+	onlyInB []*ninja_graph.Action
+}
+
+func (d graphDiff) empty() bool {
+	return len(d.changed) == 0 && len(d.onlyInA) == 0 && len(d.onlyInB) == 0
+}
+
+// compareGraphs matches the actions of the two graphs by their first output and compares them.
+func compareGraphs(a, b *ninja_graph.Graph) graphDiff {
+	diff := graphDiff{
+		actionsA: len(a.Actions),
+		actionsB: len(b.Actions),
+	}
+
+	key := func(action *ninja_graph.Action) string {
+		if len(action.Outputs) > 0 {
+			return action.Outputs[0]
+		}
+		return ""
+	}
+
+	actionsB := make(map[string]*ninja_graph.Action)
+	for _, action := range b.Actions {
+		actionsB[key(action)] = action
+	}
+
+	matched := make(map[*ninja_graph.Action]bool)
+	for _, actionA := range a.Actions {
+		actionB := actionsB[key(actionA)]
+		if actionB == nil {
+			diff.onlyInA = append(diff.onlyInA, actionA)
+			continue
+		}
+		matched[actionB] = true
+		if c := compareActions(actionA, actionB); len(c.causes) > 0 {
+			diff.changed = append(diff.changed, c)
+		}
+	}
+
+	for _, actionB := range b.Actions {
+		if !matched[actionB] {
+			diff.onlyInB = append(diff.onlyInB, actionB)
+		}
+	}
+
+	return diff
+}
+
+// compareActions compares two actions that build the same output and classifies the changes.
+func compareActions(a, b *ninja_graph.Action) changedAction {
+	ret := changedAction{a: a, b: b}
+	causes := make(map[string]bool)
+
+	if a.Rule != b.Rule {
+		causes[causeRule] = true
+	}
+
+	if !stringSetsEqual(a.AllInputs(), b.AllInputs()) {
+		causes[causeInputs] = true
+	}
+
+	if a.Command != b.Command {
+		ret.removedWords, ret.addedWords = wordDiff(a.Command, b.Command)
+
+		// Words that are inputs or outputs of either action are accounted for by the input change,
+		// or by the change to the output that matched the actions.
+		paths := make(map[string]bool)
+		for _, action := range []*ninja_graph.Action{a, b} {
+			for _, path := range action.AllInputs() {
+				paths[path] = true
+			}
+			for _, path := range action.Outputs {
+				paths[path] = true
+			}
+		}
+
+		for _, word := range append(append([]string(nil), ret.removedWords...), ret.addedWords...) {
+			switch {
+			case envAssignmentRe.MatchString(word):
+				causes[causeEnv] = true
+			case paths[word]:
+				causes[causeInputs] = true
+			default:
+				causes[causeFlags] = true
+			}
+		}
+
+		// The command changed without changing any words, e.g. only its whitespace.
+		if len(causes) == 0 {
+			causes[causeFlags] = true
+		}
+	}
+
+	for _, cause := range allCauses {
+		if causes[cause] {
+			ret.causes = append(ret.causes, cause)
+		}
+	}
+	return ret
+}
+
+// wordDiff returns the words of command a that aren't in command b, and the words of command b
+// that aren't in command a, counting repeated words.
+func wordDiff(a, b string) (removed, added []string) {
+	counts := make(map[string]int)
+	for _, word := range strings.Fields(a) {
+		counts[word]++
+	}
+	for _, word := range strings.Fields(b) {
+		if counts[word] > 0 {
+			counts[word]--
+		} else {
+			added = append(added, word)
+		}
+	}
+	for _, word := range strings.Fields(a) {
+		if counts[word] > 0 {
+			counts[word]--
+			removed = append(removed, word)
+		}
+	}
+	return removed, added
+}
+
+func stringSetsEqual(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	a = append([]string(nil), a...)
+	b = append([]string(nil), b...)
+	sort.Strings(a)
+	sort.Strings(b)
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// moduleName returns the name used to group the actions of a module in the summary.
+func moduleName(action *ninja_graph.Action) string {
+	switch {
+	case action.Module == "":
+		return "<unknown>"
+	case action.Variant == "":
+		return action.Module
+	default:
+		return action.Module + " (" + action.Variant + ")"
+	}
+}
+
+// moduleSummary counts the changed, new and removed actions of a module.
+type moduleSummary struct {
+	name                      string
+	changed, onlyInA, onlyInB int
+	causes                    map[string]int
+}
+
+func (s *moduleSummary) total() int {
+	return s.changed + s.onlyInA + s.onlyInB
+}
+
+// summary formats the number of changed actions by cause and by module, listing at most
+// maxModules modules, or all of them if maxModules is 0.  If verbose is set the changed actions
+// are listed with the words that changed in their commands.
+func (d graphDiff) summary(maxModules int, verbose bool) string {
+	var sb strings.Builder
+
+	fmt.Fprintf(&sb, "actions: %d in A, %d in B\n", d.actionsA, d.actionsB)
+	fmt.Fprintf(&sb, "changed: %d, only in A: %d, only in B: %d\n",
+		len(d.changed), len(d.onlyInA), len(d.onlyInB))
+
+	causeCounts := make(map[string]int)
+	modules := make(map[string]*moduleSummary)
+	module := func(action *ninja_graph.Action) *moduleSummary {
+		name := moduleName(action)
+		if modules[name] == nil {
+			modules[name] = &moduleSummary{name: name, causes: make(map[string]int)}
+		}
+		return modules[name]
+	}
+
+	for _, c := range d.changed {
+		m := module(c.b)
+		m.changed++
+		for _, cause := range c.causes {
+			causeCounts[cause]++
+			m.causes[cause]++
+		}
+	}
+	for _, action := range d.onlyInA {
+		module(action).onlyInA++
+	}
+	for _, action := range d.onlyInB {
+		module(action).onlyInB++
+	}
+
+	if len(d.changed) > 0 {
+		fmt.Fprintf(&sb, "\nchanged actions by cause:\n")
+		for _, cause := range allCauses {
+			if causeCounts[cause] > 0 {
+				fmt.Fprintf(&sb, "  %-7s %d\n", cause+":", causeCounts[cause])
+			}
+		}
+	}
+
+	if len(modules) > 0 {
+		var sorted []*moduleSummary
+		for _, m := range modules {
+			sorted = append(sorted, m)
+		}
+		sort.Slice(sorted, func(i, j int) bool {
+			if sorted[i].total() != sorted[j].total() {
+				return sorted[i].total() > sorted[j].total()
+			}
+			return sorted[i].name < sorted[j].name
+		})
+
+		fmt.Fprintf(&sb, "\nactions by module:\n")
+		for i, m := range sorted {
+			if maxModules > 0 && i == maxModules {
+				fmt.Fprintf(&sb, "  ... %d more modules\n", len(sorted)-maxModules)
+				break
+			}
+			var details []string
+			if m.changed > 0 {
+				var causes []string
+				for _, cause := range allCauses {
+					if m.causes[cause] > 0 {
+						causes = append(causes, fmt.Sprintf("%s %d", cause, m.causes[cause]))
+					}
+				}
+				details = append(details, fmt.Sprintf("%d changed (%s)", m.changed, strings.Join(causes, ", ")))
+			}
+			if m.onlyInA > 0 {
+				details = append(details, fmt.Sprintf("%d only in A", m.onlyInA))
+			}
+			if m.onlyInB > 0 {
+				details = append(details, fmt.Sprintf("%d only in B", m.onlyInB))
+			}
+			fmt.Fprintf(&sb, "  %s: %s\n", m.name, strings.Join(details, ", "))
+		}
+	}
+
+	if verbose && len(d.changed) > 0 {
+		fmt.Fprintf(&sb, "\nchanged actions:\n")
+		for _, c := range d.changed {
+			fmt.Fprintf(&sb, "  %s [%s] %s\n", c.b.Outputs[0], strings.Join(c.causes, ", "), moduleName(c.b))
+			if c.a.Rule != c.b.Rule {
+				fmt.Fprintf(&sb, "    rule: %s -> %s\n", c.a.Rule, c.b.Rule)
+			}
+			for _, word := range c.removedWords {
+				fmt.Fprintf(&sb, "    - %s\n", word)
+			}
+			for _, word := range c.addedWords {
+				fmt.Fprintf(&sb, "    + %s\n", word)
+			}
+		}
+	}
+
+	return sb.String()
+}
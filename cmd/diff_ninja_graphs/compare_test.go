@@ -0,0 +1,162 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"android/soong/ninja_graph"
+)
+
+func TestCompareActions(t *testing.T) {
+	base := ninja_graph.Action{
+		Module:  "libfoo",
+		Rule:    "cc",
+		Outputs: []string{"out/foo.o"},
+		Inputs:  []string{"foo.c"},
+		Command: "clang -O2 -c foo.c -o out/foo.o",
+	}
+
+	testCases := []struct {
+		name    string
+		modify  func(a *ninja_graph.Action)
+		causes  []string
+		removed []string
+		added   []string
+	}{
+		{
+			name:   "unchanged",
+			modify: func(a *ninja_graph.Action) {},
+		},
+		{
+			name: "flag change",
+			modify: func(a *ninja_graph.Action) {
+				a.Command = "clang -O3 -c foo.c -o out/foo.o"
+			},
+			causes:  []string{causeFlags},
+			removed: []string{"-O2"},
+			added:   []string{"-O3"},
+		},
+		{
+			name: "implicit input change",
+			modify: func(a *ninja_graph.Action) {
+				a.Implicits = []string{"foo.h"}
+			},
+			causes: []string{causeInputs},
+		},
+		{
+			name: "input change",
+			modify: func(a *ninja_graph.Action) {
+				a.Inputs = []string{"bar.c"}
+				a.Command = "clang -O2 -c bar.c -o out/foo.o"
+			},
+			causes:  []string{causeInputs},
+			removed: []string{"foo.c"},
+			added:   []string{"bar.c"},
+		},
+		{
+			name: "env change",
+			modify: func(a *ninja_graph.Action) {
+				a.Command = "CCACHE_DIR=/tmp clang -O2 -c foo.c -o out/foo.o"
+			},
+			causes: []string{causeEnv},
+			added:  []string{"CCACHE_DIR=/tmp"},
+		},
+		{
+			name: "rule change",
+			modify: func(a *ninja_graph.Action) {
+				a.Rule = "cc_rbe"
+				a.Command = "rewrapper clang -O2 -c foo.c -o out/foo.o"
+			},
+			causes: []string{causeRule, causeFlags},
+			added:  []string{"rewrapper"},
+		},
+		{
+			name: "whitespace change",
+			modify: func(a *ninja_graph.Action) {
+				a.Command = "clang  -O2 -c foo.c -o out/foo.o"
+			},
+			causes: []string{causeFlags},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			a := base
+			b := base
+			tc.modify(&b)
+
+			got := compareActions(&a, &b)
+			if !reflect.DeepEqual(got.causes, tc.causes) {
+				t.Errorf("causes: want %q, got %q", tc.causes, got.causes)
+			}
+			if !reflect.DeepEqual(got.removedWords, tc.removed) {
+				t.Errorf("removed words: want %q, got %q", tc.removed, got.removedWords)
+			}
+			if !reflect.DeepEqual(got.addedWords, tc.added) {
+				t.Errorf("added words: want %q, got %q", tc.added, got.addedWords)
+			}
+		})
+	}
+}
+
+func TestCompareGraphs(t *testing.T) {
+	a := &ninja_graph.Graph{Actions: []*ninja_graph.Action{
+		{Module: "libfoo", Variant: "shared", Rule: "cc", Outputs: []string{"foo.o"}, Command: "cc -O2"},
+		{Module: "libfoo", Variant: "shared", Rule: "cc", Outputs: []string{"bar.o"}, Command: "cc -O2"},
+		{Module: "libbar", Variant: "static", Rule: "cc", Outputs: []string{"baz.o"}, Command: "cc"},
+		{Module: "libold", Rule: "cc", Outputs: []string{"old.o"}, Command: "cc"},
+	}}
+	b := &ninja_graph.Graph{Actions: []*ninja_graph.Action{
+		{Module: "libfoo", Variant: "shared", Rule: "cc", Outputs: []string{"foo.o"}, Command: "cc -O3"},
+		{Module: "libfoo", Variant: "shared", Rule: "cc", Outputs: []string{"bar.o"}, Command: "FOO=1 cc -O2"},
+		{Module: "libbar", Variant: "static", Rule: "cc", Outputs: []string{"baz.o"}, Command: "cc"},
+		{Module: "libnew", Rule: "cc", Outputs: []string{"new.o"}, Command: "cc"},
+	}}
+
+	diff := compareGraphs(a, b)
+
+	want := `actions: 4 in A, 4 in B
+changed: 2, only in A: 1, only in B: 1
+
+changed actions by cause:
+  flags:  1
+  env:    1
+
+actions by module:
+  libfoo (shared): 2 changed (flags 1, env 1)
+  libnew: 1 only in B
+  ... 1 more modules
+
+changed actions:
+  foo.o [flags] libfoo (shared)
+    - -O2
+    + -O3
+  bar.o [env] libfoo (shared)
+    + FOO=1
+`
+
+	if got := diff.summary(2, true); got != want {
+		t.Errorf("want summary:\n%s\ngot:\n%s", want, got)
+	}
+
+	if diff.empty() {
+		t.Errorf("expected differences")
+	}
+	if !compareGraphs(a, a).empty() {
+		t.Errorf("expected no differences comparing a graph to itself")
+	}
+}
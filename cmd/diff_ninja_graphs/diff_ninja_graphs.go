@@ -0,0 +1,67 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// diff_ninja_graphs compares the ninja manifests generated by two Soong runs, e.g. a copy of
+// out/soong/build.ninja saved before a change and the one generated after it, and summarizes the
+// actions whose commands or inputs changed by module and by cause, to find out why a change
+// caused a large rebuild.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"android/soong/ninja_graph"
+)
+
+var (
+	topDir     = flag.String("C", ".", "directory that include and subninja paths are relative to")
+	maxModules = flag.Int("max_modules", 50, "maximum number of modules to list, or 0 for all")
+	verbose    = flag.Bool("v", false, "list the changed actions and the words that changed in their commands")
+)
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: diff_ninja_graphs [flags] <build.ninja A> <build.ninja B>\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() != 2 {
+		fmt.Fprintf(os.Stderr, "Error, exactly two arguments are required\n")
+		os.Exit(1)
+	}
+
+	graphA, err := ninja_graph.Parse(flag.Arg(0), *topDir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error parsing ninja file %v: %v\n", flag.Arg(0), err)
+		os.Exit(1)
+	}
+
+	graphB, err := ninja_graph.Parse(flag.Arg(1), *topDir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error parsing ninja file %v: %v\n", flag.Arg(1), err)
+		os.Exit(1)
+	}
+
+	diff := compareGraphs(graphA, graphB)
+
+	fmt.Print(diff.summary(*maxModules, *verbose))
+
+	if !diff.empty() {
+		fmt.Fprintln(os.Stderr, "differences found")
+		os.Exit(1)
+	}
+}
@@ -113,6 +113,11 @@ func newNameResolver(config android.Config) *android.NameResolver {
 }
 
 func newContext(configuration android.Config) *android.Context {
+	if err := android.CheckPlugins(); err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		os.Exit(1)
+	}
+
 	ctx := android.NewContext(configuration)
 	ctx.Register()
 	ctx.SetNameInterface(newNameResolver(configuration))
@@ -200,6 +200,9 @@ func main() {
 	stat.AddOutput(status.NewProtoErrorLog(log, buildErrorFile))
 	stat.AddOutput(status.NewCriticalPath(log))
 	stat.AddOutput(status.NewBuildProgressLog(log, filepath.Join(logsDir, c.logsPrefix+"build_progress.pb")))
+	if config.Environment().IsEnvTrue("ATTRIBUTE_BUILD_ERRORS") {
+		stat.AddErrorAnnotator(status.NewModuleOwnersAnnotator(log, filepath.Join(config.SoongOutDir(), "module_owners.json")))
+	}
 
 	buildCtx.Verbosef("Detected %.3v GB total RAM", float32(config.TotalRAM())/(1024*1024*1024))
 	buildCtx.Verbosef("Parallelism (local/remote/highmem): %v/%v/%v",
@@ -31,12 +31,14 @@ type GlobalConfig struct {
 	DisablePreopt           bool     // disable preopt for all modules (excluding boot images)
 	DisablePreoptBootImages bool     // disable prepot for boot images
 	DisablePreoptModules    []string // modules with preopt disabled by product-specific config
+	DisablePreoptPartitions []string // partitions (e.g. system_ext, product, odm) with preopt disabled by product-specific config
 
 	OnlyPreoptBootImageAndSystemServer bool // only preopt jars in the boot image or system server
 
 	PreoptWithUpdatableBcp bool // If updatable boot jars are included in dexpreopt or not.
 
-	UseArtImage bool // use the art image (use other boot class path dex files without image)
+	UseArtImage  bool // use the art image (use other boot class path dex files without image)
+	UseApexImage bool // use the JIT-zygote image, which contains the whole boot class path, instead of the framework boot image extension
 
 	HasSystemOther        bool     // store odex files that match PatternsOnSystemOther on the system_other partition
 	PatternsOnSystemOther []string // patterns (using '%' to denote a prefix match) to put odex on the system_other partition
@@ -90,6 +92,11 @@ type GlobalConfig struct {
 	Dex2oatImageXmx   string        // max heap size for dex2oat for the boot image
 	Dex2oatImageXms   string        // initial heap size for dex2oat for the boot image
 
+	SkipSecondaryArchBootImage   bool   // don't build the boot images for the secondary architecture of the device
+	SecondaryArchBootImageFilter string // compiler filter to pass to dex2oat for the boot images for the secondary architecture of the device, e.g. space-profile
+
+	VerifyOdexBootClassPath bool // check that odex files only reference jars on the boot class path of the product
+
 	// If true, downgrade the compiler filter of dexpreopt to "verify" when verify_uses_libraries
 	// check fails, instead of failing the build. This will disable any AOT-compilation.
 	//
@@ -146,13 +153,15 @@ func (g *GlobalConfig) AllSystemServerJars(ctx android.PathContext) *android.Con
 // stored in dexpreopt_soong.config.
 type GlobalSoongConfig struct {
 	// Paths to tools possibly used by the generated commands.
-	Profman          android.Path
-	Dex2oat          android.Path
-	Aapt             android.Path
-	SoongZip         android.Path
-	Zip2zip          android.Path
-	ManifestCheck    android.Path
-	ConstructContext android.Path
+	Profman                android.Path
+	Dex2oat                android.Path
+	Aapt                   android.Path
+	SoongZip               android.Path
+	Zip2zip                android.Path
+	ManifestCheck          android.Path
+	ConstructContext       android.Path
+	Oatdump                android.Path
+	CheckOdexBootclasspath android.Path
 }
 
 type ModuleConfig struct {
@@ -492,13 +501,15 @@ func dex2oatPathFromDep(ctx android.ModuleContext) android.Path {
 // Should not be used in dexpreopt_gen.
 func createGlobalSoongConfig(ctx android.ModuleContext) *GlobalSoongConfig {
 	return &GlobalSoongConfig{
-		Profman:          ctx.Config().HostToolPath(ctx, "profman"),
-		Dex2oat:          dex2oatPathFromDep(ctx),
-		Aapt:             ctx.Config().HostToolPath(ctx, "aapt"),
-		SoongZip:         ctx.Config().HostToolPath(ctx, "soong_zip"),
-		Zip2zip:          ctx.Config().HostToolPath(ctx, "zip2zip"),
-		ManifestCheck:    ctx.Config().HostToolPath(ctx, "manifest_check"),
-		ConstructContext: ctx.Config().HostToolPath(ctx, "construct_context"),
+		Profman:                ctx.Config().HostToolPath(ctx, "profman"),
+		Dex2oat:                dex2oatPathFromDep(ctx),
+		Aapt:                   ctx.Config().HostToolPath(ctx, "aapt"),
+		SoongZip:               ctx.Config().HostToolPath(ctx, "soong_zip"),
+		Zip2zip:                ctx.Config().HostToolPath(ctx, "zip2zip"),
+		ManifestCheck:          ctx.Config().HostToolPath(ctx, "manifest_check"),
+		ConstructContext:       ctx.Config().HostToolPath(ctx, "construct_context"),
+		Oatdump:                ctx.Config().HostToolPath(ctx, "oatdump"),
+		CheckOdexBootclasspath: ctx.Config().HostToolPath(ctx, "check_odex_bootclasspath"),
 	}
 }
 
@@ -543,13 +554,15 @@ func GetCachedGlobalSoongConfig(ctx android.PathContext) *GlobalSoongConfig {
 }
 
 type globalJsonSoongConfig struct {
-	Profman          string
-	Dex2oat          string
-	Aapt             string
-	SoongZip         string
-	Zip2zip          string
-	ManifestCheck    string
-	ConstructContext string
+	Profman                string
+	Dex2oat                string
+	Aapt                   string
+	SoongZip               string
+	Zip2zip                string
+	ManifestCheck          string
+	ConstructContext       string
+	Oatdump                string
+	CheckOdexBootclasspath string
 }
 
 // ParseGlobalSoongConfig parses the given data assumed to be read from the
@@ -564,13 +577,15 @@ func ParseGlobalSoongConfig(ctx android.PathContext, data []byte) (*GlobalSoongC
 	}
 
 	config := &GlobalSoongConfig{
-		Profman:          constructPath(ctx, jc.Profman),
-		Dex2oat:          constructPath(ctx, jc.Dex2oat),
-		Aapt:             constructPath(ctx, jc.Aapt),
-		SoongZip:         constructPath(ctx, jc.SoongZip),
-		Zip2zip:          constructPath(ctx, jc.Zip2zip),
-		ManifestCheck:    constructPath(ctx, jc.ManifestCheck),
-		ConstructContext: constructPath(ctx, jc.ConstructContext),
+		Profman:                constructPath(ctx, jc.Profman),
+		Dex2oat:                constructPath(ctx, jc.Dex2oat),
+		Aapt:                   constructPath(ctx, jc.Aapt),
+		SoongZip:               constructPath(ctx, jc.SoongZip),
+		Zip2zip:                constructPath(ctx, jc.Zip2zip),
+		ManifestCheck:          constructPath(ctx, jc.ManifestCheck),
+		ConstructContext:       constructPath(ctx, jc.ConstructContext),
+		Oatdump:                constructPath(ctx, jc.Oatdump),
+		CheckOdexBootclasspath: constructPath(ctx, jc.CheckOdexBootclasspath),
 	}
 
 	return config, nil
@@ -609,13 +624,15 @@ func (s *globalSoongConfigSingleton) GenerateBuildActions(ctx android.SingletonC
 	}
 
 	jc := globalJsonSoongConfig{
-		Profman:          config.Profman.String(),
-		Dex2oat:          config.Dex2oat.String(),
-		Aapt:             config.Aapt.String(),
-		SoongZip:         config.SoongZip.String(),
-		Zip2zip:          config.Zip2zip.String(),
-		ManifestCheck:    config.ManifestCheck.String(),
-		ConstructContext: config.ConstructContext.String(),
+		Profman:                config.Profman.String(),
+		Dex2oat:                config.Dex2oat.String(),
+		Aapt:                   config.Aapt.String(),
+		SoongZip:               config.SoongZip.String(),
+		Zip2zip:                config.Zip2zip.String(),
+		ManifestCheck:          config.ManifestCheck.String(),
+		ConstructContext:       config.ConstructContext.String(),
+		Oatdump:                config.Oatdump.String(),
+		CheckOdexBootclasspath: config.CheckOdexBootclasspath.String(),
 	}
 
 	data, err := json.Marshal(jc)
@@ -646,6 +663,8 @@ func (s *globalSoongConfigSingleton) MakeVars(ctx android.MakeVarsContext) {
 		config.Zip2zip.String(),
 		config.ManifestCheck.String(),
 		config.ConstructContext.String(),
+		config.Oatdump.String(),
+		config.CheckOdexBootclasspath.String(),
 	}, " "))
 }
 
@@ -653,6 +672,7 @@ func GlobalConfigForTests(ctx android.PathContext) *GlobalConfig {
 	return &GlobalConfig{
 		DisablePreopt:                      false,
 		DisablePreoptModules:               nil,
+		DisablePreoptPartitions:            nil,
 		OnlyPreoptBootImageAndSystemServer: false,
 		HasSystemOther:                     false,
 		PatternsOnSystemOther:              nil,
@@ -689,17 +709,22 @@ func GlobalConfigForTests(ctx android.PathContext) *GlobalConfig {
 		BootFlags:                          "",
 		Dex2oatImageXmx:                    "",
 		Dex2oatImageXms:                    "",
+		SkipSecondaryArchBootImage:         false,
+		SecondaryArchBootImageFilter:       "",
+		VerifyOdexBootClassPath:            false,
 	}
 }
 
 func globalSoongConfigForTests() *GlobalSoongConfig {
 	return &GlobalSoongConfig{
-		Profman:          android.PathForTesting("profman"),
-		Dex2oat:          android.PathForTesting("dex2oat"),
-		Aapt:             android.PathForTesting("aapt"),
-		SoongZip:         android.PathForTesting("soong_zip"),
-		Zip2zip:          android.PathForTesting("zip2zip"),
-		ManifestCheck:    android.PathForTesting("manifest_check"),
-		ConstructContext: android.PathForTesting("construct_context"),
+		Profman:                android.PathForTesting("profman"),
+		Dex2oat:                android.PathForTesting("dex2oat"),
+		Aapt:                   android.PathForTesting("aapt"),
+		SoongZip:               android.PathForTesting("soong_zip"),
+		Zip2zip:                android.PathForTesting("zip2zip"),
+		ManifestCheck:          android.PathForTesting("manifest_check"),
+		ConstructContext:       android.PathForTesting("construct_context"),
+		Oatdump:                android.PathForTesting("oatdump"),
+		CheckOdexBootclasspath: android.PathForTesting("check_odex_bootclasspath"),
 	}
 }
@@ -105,6 +105,10 @@ func dexpreoptDisabled(ctx android.PathContext, global *GlobalConfig, module *Mo
 		return true
 	}
 
+	if contains(global.DisablePreoptPartitions, dexLocationPartition(module.DexLocation)) {
+		return true
+	}
+
 	// Don't preopt individual boot jars, they will be preopted together.
 	if global.BootJars.ContainsJar(module.Name) {
 		return true
@@ -196,6 +200,18 @@ func bootProfileCommand(ctx android.PathContext, globalSoong *GlobalSoongConfig,
 	return profilePath
 }
 
+// dexLocationPartition returns the partition a dex file is installed on from its location on the
+// device, treating the partitions that are installed as subdirectories of the system partition,
+// for example /system/product, as separate partitions.
+func dexLocationPartition(dexLocation string) string {
+	parts := strings.Split(strings.TrimPrefix(dexLocation, "/"), "/")
+	if len(parts) > 2 && parts[0] == "system" &&
+		contains([]string{"system_ext", "product", "vendor", "odm"}, parts[1]) {
+		return parts[1]
+	}
+	return parts[0]
+}
+
 // Returns the dex location of a system server java library.
 func GetSystemServerDexLocation(ctx android.PathContext, global *GlobalConfig, lib string) string {
 	if apex := global.AllApexSystemServerJars(ctx).ApexOfJar(lib); apex != "" {
@@ -486,10 +502,42 @@ func dexpreoptCommand(ctx android.PathContext, globalSoong *GlobalSoongConfig, g
 		cmd.FlagWithInput("--profile-file=", profile)
 	}
 
+	if global.VerifyOdexBootClassPath {
+		verifyOdexBootClassPath(ctx, globalSoong, global, module, rule, odexPath)
+	}
+
 	rule.Install(odexPath, odexInstallPath)
 	rule.Install(vdexPath, vdexInstallPath)
 }
 
+// verifyOdexBootClassPath adds commands to the rule that check that the boot class path recorded in
+// the odex file is a prefix of the boot class path of the product. Otherwise the runtime rejects the
+// odex file at boot and the mismatch only shows up on device as unexpected JIT or dexopt. The check
+// writes a report next to the odex file that lists each of the recorded boot class path entries.
+func verifyOdexBootClassPath(ctx android.PathContext, globalSoong *GlobalSoongConfig, global *GlobalConfig,
+	module *ModuleConfig, rule *android.RuleBuilder, odexPath android.OutputPath) {
+
+	// The runtime boot class path is made of the boot jars followed by the apex boot jars.
+	bootJars := global.BootJars.AppendList(&global.ApexBootJars)
+	bootClassPath := bootJars.DevicePaths(ctx.Config(), android.Android)
+
+	headerPath := odexPath.ReplaceExtension(ctx, "odex_header")
+	reportPath := odexPath.ReplaceExtension(ctx, "bcp_report")
+
+	rule.Command().
+		Tool(globalSoong.Oatdump).
+		Flag("--header-only").
+		FlagWithInput("--oat-file=", odexPath).
+		FlagWithOutput("--output=", headerPath)
+
+	rule.Command().
+		Tool(globalSoong.CheckOdexBootclasspath).
+		FlagWithInput("--header ", headerPath).
+		FlagWithArg("--dex-location ", module.DexLocation).
+		FlagWithList("--bootclasspath ", bootClassPath, ":").
+		FlagWithOutput("--report ", reportPath)
+}
+
 func shouldGenerateDM(module *ModuleConfig, global *GlobalConfig) bool {
 	// Generating DM files only makes sense for verify, avoid doing for non verify compiler filter APKs.
 	// No reason to use a dm file if the dex is already uncompressed.
@@ -17,6 +17,7 @@ package dexpreopt
 import (
 	"android/soong/android"
 	"fmt"
+	"strings"
 	"testing"
 )
 
@@ -107,6 +108,65 @@ func TestDexPreopt(t *testing.T) {
 	}
 }
 
+func TestDexPreoptVerifyBootClassPath(t *testing.T) {
+	config := android.TestConfig("out", nil, "", nil)
+	ctx := android.BuilderContextForTesting(config)
+	globalSoong := globalSoongConfigForTests()
+	global := GlobalConfigForTests(ctx)
+	module := testSystemModuleConfig(ctx, "test")
+
+	global.BootJars = android.CreateTestConfiguredJarList([]string{"platform:framework"})
+	global.ApexBootJars = android.CreateTestConfiguredJarList([]string{"com.android.foo:foo"})
+	global.VerifyOdexBootClassPath = true
+
+	rule, err := GenerateDexpreoptRule(ctx, globalSoong, global, module)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	android.AssertStringListContains(t, "outputs",
+		rule.Outputs().Strings(), android.PathForOutput(ctx, "test/oat/arm/package.bcp_report").String())
+	android.AssertStringDoesContain(t, "check command", strings.Join(rule.Commands(), "\n"),
+		"--bootclasspath /system/framework/framework.jar:/apex/com.android.foo/javalib/foo.jar")
+
+	// The check isn't run by default.
+	global.VerifyOdexBootClassPath = false
+	rule, err = GenerateDexpreoptRule(ctx, globalSoong, global, module)
+	if err != nil {
+		t.Fatal(err)
+	}
+	android.AssertStringDoesNotContain(t, "commands", strings.Join(rule.Commands(), "\n"), "check_odex_bootclasspath")
+}
+
+func TestDexPreoptDisablePartitions(t *testing.T) {
+	config := android.TestConfig("out", nil, "", nil)
+	ctx := android.BuilderContextForTesting(config)
+	globalSoong := globalSoongConfigForTests()
+	global := GlobalConfigForTests(ctx)
+
+	global.DisablePreoptPartitions = []string{"product"}
+
+	tests := []struct {
+		module  *ModuleConfig
+		enabled bool
+	}{
+		{module: testSystemModuleConfig(ctx, "Stest"), enabled: true},
+		{module: testSystemProductModuleConfig(ctx, "SPtest"), enabled: false},
+		{module: testProductModuleConfig(ctx, "Ptest"), enabled: false},
+	}
+
+	for _, test := range tests {
+		rule, err := GenerateDexpreoptRule(ctx, globalSoong, global, test.module)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if enabled := len(rule.Installs()) > 0; enabled != test.enabled {
+			t.Errorf("%s: want dexpreopt enabled %v, got %v", test.module.DexLocation, test.enabled, enabled)
+		}
+	}
+}
+
 func TestDexPreoptSystemOther(t *testing.T) {
 	config := android.TestConfig("out", nil, "", nil)
 	ctx := android.BuilderContextForTesting(config)
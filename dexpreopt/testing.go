@@ -168,9 +168,23 @@ func FixtureSetBootImageProfiles(profiles ...string) android.FixturePreparer {
 	})
 }
 
+// FixtureDisablePreoptPartitions sets the DisablePreoptPartitions property in the global config.
+func FixtureDisablePreoptPartitions(partitions ...string) android.FixturePreparer {
+	return FixtureModifyGlobalConfig(func(_ android.PathContext, dexpreoptConfig *GlobalConfig) {
+		dexpreoptConfig.DisablePreoptPartitions = partitions
+	})
+}
+
 // FixtureDisableGenerateProfile sets the DisableGenerateProfile property in the global config.
 func FixtureDisableGenerateProfile(disable bool) android.FixturePreparer {
 	return FixtureModifyGlobalConfig(func(_ android.PathContext, dexpreoptConfig *GlobalConfig) {
 		dexpreoptConfig.DisableGenerateProfile = disable
 	})
 }
+
+// FixtureSetUseApexImage sets the UseApexImage property in the global config.
+func FixtureSetUseApexImage(value bool) android.FixturePreparer {
+	return FixtureModifyGlobalConfig(func(_ android.PathContext, dexpreoptConfig *GlobalConfig) {
+		dexpreoptConfig.UseApexImage = value
+	})
+}
@@ -61,6 +61,9 @@ func RegisterPrebuiltEtcBuildComponents(ctx android.RegistrationContext) {
 	ctx.RegisterModuleType("prebuilt_firmware", PrebuiltFirmwareFactory)
 	ctx.RegisterModuleType("prebuilt_dsp", PrebuiltDSPFactory)
 	ctx.RegisterModuleType("prebuilt_rfsa", PrebuiltRFSAFactory)
+	ctx.RegisterModuleType("prebuilt_recovery_image", PrebuiltRecoveryImageFactory)
+	ctx.RegisterModuleType("prebuilt_recovery_fstab", PrebuiltRecoveryFstabFactory)
+	ctx.RegisterModuleType("prebuilt_recovery_sepolicy", PrebuiltRecoverySepolicyFactory)
 
 	ctx.RegisterModuleType("prebuilt_defaults", defaultsFactory)
 
@@ -105,6 +108,14 @@ type prebuiltEtcProperties struct {
 
 	// Install symlinks to the installed file.
 	Symlinks []string `android:"arch_variant"`
+
+	// Variables to expand in src, which is treated as a template when this is set. A variable
+	// is referenced as {{NAME}} in src. Every {{ must start a reference to one of the listed
+	// variables, and every listed variable must be referenced. Supported variables are
+	// PRODUCT_NAME, DEVICE_NAME, PLATFORM_VERSION, PLATFORM_SDK_VERSION,
+	// PLATFORM_SECURITY_PATCH and soong config variables written as
+	// soong_config:<namespace>:<variable>.
+	Template_variables []string
 }
 
 type prebuiltSubdirProperties struct {
@@ -337,13 +348,17 @@ func (p *PrebuiltEtc) GenerateAndroidBuildActions(ctx android.ModuleContext) {
 	}
 	p.installDirPath = android.PathForModuleInstall(ctx, installBaseDir, p.SubDir())
 
-	// This ensures that outputFilePath has the correct name for others to
-	// use, as the source file may have a different name.
-	ctx.Build(pctx, android.BuildParams{
-		Rule:   android.Cp,
-		Output: p.outputFilePath,
-		Input:  p.sourceFilePath,
-	})
+	if len(p.properties.Template_variables) > 0 {
+		p.expandTemplate(ctx)
+	} else {
+		// This ensures that outputFilePath has the correct name for others to
+		// use, as the source file may have a different name.
+		ctx.Build(pctx, android.BuildParams{
+			Rule:   android.Cp,
+			Output: p.outputFilePath,
+			Input:  p.sourceFilePath,
+		})
+	}
 
 	if !p.Installable() {
 		p.SkipInstall()
@@ -356,6 +371,69 @@ func (p *PrebuiltEtc) GenerateAndroidBuildActions(ctx android.ModuleContext) {
 	}
 }
 
+// templateVariable returns the value of a variable listed in template_variables, or false if the
+// variable isn't supported.
+func templateVariable(ctx android.ModuleContext, name string) (string, bool) {
+	switch name {
+	case "PRODUCT_NAME":
+		return ctx.Config().DeviceProduct(), true
+	case "DEVICE_NAME":
+		return ctx.Config().DeviceName(), true
+	case "PLATFORM_VERSION":
+		return ctx.Config().PlatformVersionName(), true
+	case "PLATFORM_SDK_VERSION":
+		return ctx.Config().PlatformSdkVersion().String(), true
+	case "PLATFORM_SECURITY_PATCH":
+		return ctx.Config().PlatformSecurityPatch(), true
+	}
+	if parts := strings.Split(name, ":"); len(parts) == 3 && parts[0] == "soong_config" {
+		vendorConfig := ctx.Config().VendorConfig(parts[1])
+		if !vendorConfig.IsSet(parts[2]) {
+			ctx.PropertyErrorf("template_variables", "soong config variable %q is not set", name)
+			return "", true
+		}
+		return vendorConfig.String(parts[2]), true
+	}
+	return "", false
+}
+
+// expandTemplate builds outputFilePath by expanding the template_variables in the source file.
+// The references in the source file are checked when it is built, as it may be generated.
+func (p *PrebuiltEtc) expandTemplate(ctx android.ModuleContext) {
+	variables := make(map[string]string)
+	for _, name := range p.properties.Template_variables {
+		if _, exists := variables[name]; exists {
+			ctx.PropertyErrorf("template_variables", "duplicate variable %q", name)
+			continue
+		}
+		value, ok := templateVariable(ctx, name)
+		if !ok {
+			ctx.PropertyErrorf("template_variables", "unknown variable %q", name)
+			continue
+		}
+		variables[name] = value
+	}
+	if ctx.Failed() {
+		return
+	}
+
+	content, err := json.Marshal(variables)
+	if err != nil {
+		ctx.ModuleErrorf("failed to marshal template variables: %s", err)
+		return
+	}
+	variablesFile := android.PathForModuleOut(ctx, "template_variables.json")
+	android.WriteFileRule(ctx, variablesFile, string(content))
+
+	builder := android.NewRuleBuilder(pctx, ctx)
+	builder.Command().
+		BuiltTool("expand_template").
+		FlagWithInput("--variables ", variablesFile).
+		Input(p.sourceFilePath).
+		Output(p.outputFilePath)
+	builder.Build("expand_template", "expand template "+p.outputFilePath.Base())
+}
+
 func (p *PrebuiltEtc) AndroidMkEntries() []android.AndroidMkEntries {
 	nameSuffix := ""
 	if p.inRamdisk() && !p.onlyInRamdisk() {
@@ -679,6 +757,11 @@ type bazelPrebuiltFileAttributes struct {
 // ConvertWithBp2build performs bp2build conversion of PrebuiltEtc
 // All prebuilt_* modules are PrebuiltEtc, which we treat uniformily as *PrebuiltFile*
 func (module *PrebuiltEtc) ConvertWithBp2build(ctx android.TopDownMutatorContext) {
+	// prebuilt_file doesn't support expanding templates
+	if len(module.properties.Template_variables) > 0 {
+		return
+	}
+
 	var src bazel.LabelAttribute
 	for axis, configToProps := range module.GetArchVariantProperties(ctx, &prebuiltEtcProperties{}) {
 		for config, p := range configToProps {
@@ -119,6 +119,58 @@ func TestPrebuiltEtcGlob(t *testing.T) {
 	android.AssertStringEquals(t, "my_bar output file path", "bar.conf", p.outputFilePath.Base())
 }
 
+func TestPrebuiltEtcTemplate(t *testing.T) {
+	result := android.GroupFixturePreparers(
+		prepareForPrebuiltEtcTest,
+		android.FixtureModifyProductVariables(func(variables android.FixtureProductVariables) {
+			variables.VendorVars = map[string]map[string]string{
+				"acme": {"board": "acme_board"},
+			}
+		}),
+	).RunTestWithBp(t, `
+		prebuilt_usr_share {
+			name: "foo.conf",
+			src: "foo.conf",
+			template_variables: [
+				"PRODUCT_NAME",
+				"PLATFORM_SDK_VERSION",
+				"soong_config:acme:board",
+			],
+		}
+	`)
+
+	m := result.ModuleForTests("foo.conf", "android_arm64_armv8-a")
+	variables := android.ContentFromFileRuleForTests(t, m.Output("template_variables.json"))
+	android.AssertStringEquals(t, "template variables",
+		`{"PLATFORM_SDK_VERSION":"30","PRODUCT_NAME":"test_product","soong_config:acme:board":"acme_board"}`+"\n",
+		variables)
+
+	cmd := m.Output("foo.conf").RuleParams.Command
+	android.AssertStringDoesContain(t, "expand_template command", cmd,
+		"expand_template --variables out/soong/.intermediates/foo.conf/android_arm64_armv8-a/template_variables.json foo.conf")
+}
+
+func TestPrebuiltEtcTemplateErrors(t *testing.T) {
+	prepareForPrebuiltEtcTest.
+		ExtendWithErrorHandler(android.FixtureExpectsAllErrorsToMatchAPattern([]string{
+			`template_variables: unknown variable "BOARD_NAME"`,
+			`template_variables: duplicate variable "PRODUCT_NAME"`,
+			`template_variables: soong config variable "soong_config:acme:board" is not set`,
+		})).
+		RunTestWithBp(t, `
+			prebuilt_etc {
+				name: "foo.conf",
+				src: "foo.conf",
+				template_variables: [
+					"PRODUCT_NAME",
+					"PRODUCT_NAME",
+					"BOARD_NAME",
+					"soong_config:acme:board",
+				],
+			}
+		`)
+}
+
 func TestPrebuiltEtcAndroidMk(t *testing.T) {
 	result := prepareForPrebuiltEtcTest.RunTestWithBp(t, `
 		prebuilt_etc {
@@ -0,0 +1,169 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package etc
+
+// This file implements module types that install resources to the recovery partition, which
+// device trees would otherwise install with PRODUCT_COPY_FILES.
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/google/blueprint/proptools"
+
+	"android/soong/android"
+)
+
+type PrebuiltRecovery struct {
+	PrebuiltEtc
+
+	// Whether installDirBase is relative to the root of the recovery partition rather than to
+	// recovery/root/system.
+	installInRoot bool
+
+	// Allowed suffixes of the installed file name, or nil if any name is allowed.
+	fileSuffixes []string
+
+	// Allowed prefixes of the installed file name, in addition to fileSuffixes.
+	filePrefixes []string
+}
+
+func (p *PrebuiltRecovery) InstallInRoot() bool {
+	return p.installInRoot
+}
+
+func (p *PrebuiltRecovery) GenerateAndroidBuildActions(ctx android.ModuleContext) {
+	partitions := []struct {
+		prop string
+		set  bool
+	}{
+		{"soc_specific", p.SocSpecific()},
+		{"device_specific", p.DeviceSpecific()},
+		{"product_specific", p.ProductSpecific()},
+		{"system_ext_specific", p.SystemExtSpecific()},
+	}
+	for _, partition := range partitions {
+		if partition.set {
+			ctx.PropertyErrorf(partition.prop, "recovery resources can only be installed to the recovery partition")
+		}
+	}
+	images := []struct {
+		prop string
+		set  *bool
+	}{
+		{"ramdisk_available", p.properties.Ramdisk_available},
+		{"vendor_ramdisk_available", p.properties.Vendor_ramdisk_available},
+		{"debug_ramdisk_available", p.properties.Debug_ramdisk_available},
+		{"recovery_available", p.properties.Recovery_available},
+	}
+	for _, image := range images {
+		if image.set != nil {
+			ctx.PropertyErrorf(image.prop, "can't be set on recovery resources")
+		}
+	}
+
+	prop := "sub_dir"
+	if p.subdirProperties.Sub_dir == nil {
+		prop = "relative_install_path"
+	}
+	if subDir := p.SubDir(); subDir != "" {
+		if filepath.IsAbs(subDir) || filepath.Clean(subDir) != subDir ||
+			subDir == ".." || strings.HasPrefix(subDir, "../") {
+			ctx.PropertyErrorf(prop, "%q must be a relative path within the recovery partition", subDir)
+		}
+	}
+	if ctx.Failed() {
+		return
+	}
+
+	p.PrebuiltEtc.GenerateAndroidBuildActions(ctx)
+	if ctx.Failed() {
+		return
+	}
+
+	fileName := p.outputFilePath.Base()
+	if p.fileSuffixes == nil || hasAnySuffix(fileName, p.fileSuffixes) || hasAnyPrefix(fileName, p.filePrefixes) {
+		return
+	}
+	if p.filePrefixes != nil {
+		ctx.ModuleErrorf("installed file name %q must start with one of %q or end with one of %q",
+			fileName, p.filePrefixes, p.fileSuffixes)
+	} else {
+		ctx.ModuleErrorf("installed file name %q must end with one of %q", fileName, p.fileSuffixes)
+	}
+}
+
+func hasAnyPrefix(s string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(s, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func hasAnySuffix(s string, suffixes []string) bool {
+	for _, suffix := range suffixes {
+		if strings.HasSuffix(s, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+func initPrebuiltRecoveryModule(p *PrebuiltRecovery, dirBase string) {
+	InitPrebuiltEtcModule(&p.PrebuiltEtc, dirBase)
+	// Recovery resources are only ever installed to the recovery partition.
+	android.AddLoadHook(p, func(ctx android.LoadHookContext) {
+		ctx.AppendProperties(&struct {
+			Recovery *bool
+		}{
+			Recovery: proptools.BoolPtr(true),
+		})
+	})
+	// This module is device-only
+	android.InitAndroidArchModule(p, android.DeviceSupported, android.MultilibFirst)
+	android.InitDefaultableModule(p)
+}
+
+// prebuilt_recovery_image installs a PNG image used by the recovery UI in
+// recovery/root/res/images/<sub_dir> directory.
+func PrebuiltRecoveryImageFactory() android.Module {
+	module := &PrebuiltRecovery{}
+	module.installInRoot = true
+	module.fileSuffixes = []string{".png"}
+	initPrebuiltRecoveryModule(module, "res/images")
+	return module
+}
+
+// prebuilt_recovery_fstab installs an fstab file in recovery/root/system/etc/<sub_dir> directory.
+// The installed file name must end with ".fstab" or start with "fstab.", e.g. "recovery.fstab" or
+// "fstab.qcom".
+func PrebuiltRecoveryFstabFactory() android.Module {
+	module := &PrebuiltRecovery{}
+	module.fileSuffixes = []string{".fstab"}
+	module.filePrefixes = []string{"fstab."}
+	initPrebuiltRecoveryModule(module, "etc")
+	return module
+}
+
+// prebuilt_recovery_sepolicy installs a compiled sepolicy fragment or a contexts file in
+// recovery/root/system/etc/selinux/<sub_dir> directory.
+func PrebuiltRecoverySepolicyFactory() android.Module {
+	module := &PrebuiltRecovery{}
+	module.fileSuffixes = []string{".cil", "_contexts"}
+	initPrebuiltRecoveryModule(module, "etc/selinux")
+	return module
+}
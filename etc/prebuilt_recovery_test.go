@@ -0,0 +1,158 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package etc
+
+import (
+	"path/filepath"
+	"testing"
+
+	"android/soong/android"
+)
+
+var prepareForPrebuiltRecoveryTest = android.GroupFixturePreparers(
+	prepareForPrebuiltEtcTest,
+	android.FixtureMergeMockFs(android.MockFS{
+		"icon.png":       nil,
+		"recovery.fstab": nil,
+		"file_contexts":  nil,
+	}),
+)
+
+func TestPrebuiltRecoveryInstallDirPath(t *testing.T) {
+	recoveryPath := "out/soong/target/product/test_device/recovery/root"
+	tests := []struct {
+		description  string
+		config       string
+		expectedPath string
+	}{{
+		description: "prebuilt_recovery_image",
+		config: `
+			prebuilt_recovery_image {
+				name: "foo",
+				src: "icon.png",
+				filename_from_src: true,
+				sub_dir: "sub_dir",
+			}`,
+		expectedPath: filepath.Join(recoveryPath, "res/images/sub_dir"),
+	}, {
+		description: "prebuilt_recovery_fstab",
+		config: `
+			prebuilt_recovery_fstab {
+				name: "foo",
+				src: "recovery.fstab",
+				filename: "recovery.fstab",
+			}`,
+		expectedPath: filepath.Join(recoveryPath, "system/etc"),
+	}, {
+		description: "prebuilt_recovery_fstab with a device name",
+		config: `
+			prebuilt_recovery_fstab {
+				name: "foo",
+				src: "recovery.fstab",
+				filename: "fstab.qcom",
+			}`,
+		expectedPath: filepath.Join(recoveryPath, "system/etc"),
+	}, {
+		description: "prebuilt_recovery_sepolicy",
+		config: `
+			prebuilt_recovery_sepolicy {
+				name: "foo",
+				src: "file_contexts",
+				filename: "plat_file_contexts",
+			}`,
+		expectedPath: filepath.Join(recoveryPath, "system/etc/selinux"),
+	}}
+	for _, tt := range tests {
+		t.Run(tt.description, func(t *testing.T) {
+			result := prepareForPrebuiltRecoveryTest.RunTestWithBp(t, tt.config)
+			android.AssertDeepEquals(t, "variants", []string{"android_recovery_arm64_armv8-a"},
+				result.ModuleVariantsForTests("foo"))
+			p := result.Module("foo", "android_recovery_arm64_armv8-a").(*PrebuiltRecovery)
+			android.AssertPathRelativeToTopEquals(t, "install dir", tt.expectedPath, p.installDirPath)
+		})
+	}
+}
+
+func TestPrebuiltRecoveryValidation(t *testing.T) {
+	tests := []struct {
+		description string
+		config      string
+		err         string
+	}{{
+		description: "partition",
+		config: `
+			prebuilt_recovery_fstab {
+				name: "foo",
+				src: "recovery.fstab",
+				filename: "recovery.fstab",
+				soc_specific: true,
+			}`,
+		err: `soc_specific: recovery resources can only be installed to the recovery partition`,
+	}, {
+		description: "image variant",
+		config: `
+			prebuilt_recovery_fstab {
+				name: "foo",
+				src: "recovery.fstab",
+				filename: "recovery.fstab",
+				ramdisk_available: true,
+			}`,
+		err: `ramdisk_available: can't be set on recovery resources`,
+	}, {
+		description: "sub_dir outside of the partition",
+		config: `
+			prebuilt_recovery_image {
+				name: "foo",
+				src: "icon.png",
+				filename_from_src: true,
+				sub_dir: "../../system",
+			}`,
+		err: `sub_dir: "../../system" must be a relative path within the recovery partition`,
+	}, {
+		description: "absolute relative_install_path",
+		config: `
+			prebuilt_recovery_sepolicy {
+				name: "foo",
+				src: "file_contexts",
+				filename: "plat_file_contexts",
+				relative_install_path: "/system",
+			}`,
+		err: `relative_install_path: "/system" must be a relative path within the recovery partition`,
+	}, {
+		description: "file name",
+		config: `
+			prebuilt_recovery_image {
+				name: "foo",
+				src: "icon.png",
+			}`,
+		err: `installed file name "foo" must end with one of \[".png"\]`,
+	}, {
+		description: "fstab file name",
+		config: `
+			prebuilt_recovery_fstab {
+				name: "foo",
+				src: "recovery.fstab",
+				filename: "recovery_fstab",
+			}`,
+		err: `installed file name "recovery_fstab" must start with one of \["fstab."\] or end with one of \[".fstab"\]`,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.description, func(t *testing.T) {
+			prepareForPrebuiltRecoveryTest.
+				ExtendWithErrorHandler(android.FixtureExpectsAtLeastOneErrorMatchingPattern(tt.err)).
+				RunTestWithBp(t, tt.config)
+		})
+	}
+}
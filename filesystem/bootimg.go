@@ -45,7 +45,7 @@ type bootimgProperties struct {
 	// Path to the linux kernel prebuilt file
 	Kernel_prebuilt *string `android:"arch_variant,path"`
 
-	// Filesystem module that is used as ramdisk
+	// android_filesystem or android_ramdisk module that is used as ramdisk
 	Ramdisk_module *string
 
 	// Path to the device tree blob (DTB) prebuilt file to add to this boot image
@@ -79,6 +79,14 @@ type bootimgProperties struct {
 
 	// Hash and signing algorithm for avbtool. Default is SHA256_RSA4096.
 	Avb_algorithm *string
+
+	// Rollback index of this image, passed as --rollback_index to avbtool. Must be 0, 1, 2, etc.
+	// Not set by default.
+	Avb_rollback_index *int64
+
+	// Rollback index location of this image. Must be 0, 1, 2, etc. Only needed when this image
+	// is chained from a vbmeta image, in which case it must match the location used there.
+	Avb_rollback_index_location *int64
 }
 
 // bootimg is the image for the boot partition. It consists of header, kernel, ramdisk, and dtb.
@@ -178,16 +186,21 @@ func (b *bootimg) buildBootImage(ctx android.ModuleContext, vendor bool) android
 	ramdiskName := proptools.String(b.properties.Ramdisk_module)
 	if ramdiskName != "" {
 		ramdisk := ctx.GetDirectDepWithTag(ramdiskName, bootimgRamdiskDep)
-		if filesystem, ok := ramdisk.(*filesystem); ok {
-			flag := "--ramdisk "
-			if vendor {
-				flag = "--vendor_ramdisk "
-			}
-			cmd.FlagWithInput(flag, filesystem.OutputPath())
-		} else {
-			ctx.PropertyErrorf("ramdisk", "%q is not android_filesystem module", ramdisk.Name())
+		var ramdiskOutput android.Path
+		switch m := ramdisk.(type) {
+		case *filesystem:
+			ramdiskOutput = m.OutputPath()
+		case *ramdiskImage:
+			ramdiskOutput = m.OutputPath()
+		default:
+			ctx.PropertyErrorf("ramdisk", "%q is not android_filesystem or android_ramdisk module", ramdisk.Name())
 			return output
 		}
+		flag := "--ramdisk "
+		if vendor {
+			flag = "--vendor_ramdisk "
+		}
+		cmd.FlagWithInput(flag, ramdiskOutput)
 	}
 
 	bootconfig := proptools.String(b.properties.Bootconfig)
@@ -245,7 +258,8 @@ func (b *bootimg) buildPropFile(ctx android.ModuleContext) (propFile android.Out
 	addStr("avb_algorithm", algorithm)
 	key := android.PathForModuleSrc(ctx, proptools.String(b.properties.Avb_private_key))
 	addPath("avb_key_path", key)
-	addStr("avb_add_hash_footer_args", "") // TODO(jiyong): add --rollback_index
+	addStr("avb_add_hash_footer_args", strings.TrimSpace(avbRollbackIndexArgs(ctx,
+		b.properties.Avb_rollback_index, b.properties.Avb_rollback_index_location)))
 	partitionName := proptools.StringDefault(b.properties.Partition_name, b.Name())
 	addStr("partition_name", partitionName)
 
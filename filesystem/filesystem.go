@@ -17,6 +17,8 @@ package filesystem
 import (
 	"fmt"
 	"path/filepath"
+	"regexp"
+	"strconv"
 	"strings"
 
 	"android/soong/android"
@@ -32,6 +34,7 @@ func init() {
 func registerBuildComponents(ctx android.RegistrationContext) {
 	ctx.RegisterModuleType("android_filesystem", filesystemFactory)
 	ctx.RegisterModuleType("android_system_image", systemImageFactory)
+	ctx.RegisterModuleType("android_ramdisk", ramdiskFactory)
 }
 
 type filesystem struct {
@@ -73,13 +76,24 @@ type filesystemProperties struct {
 	// avbtool. Default used by avbtool is sha1.
 	Avb_hash_algorithm *string
 
+	// Rollback index of this image, passed as --rollback_index to avbtool. Must be 0, 1, 2, etc.
+	// Not set by default.
+	Avb_rollback_index *int64
+
+	// Rollback index location of this image. Must be 0, 1, 2, etc. Only needed when this image
+	// is chained from a vbmeta image, in which case it must match the location used there.
+	Avb_rollback_index_location *int64
+
 	// Name of the partition stored in vbmeta desc. Defaults to the name of this module.
 	Partition_name *string
 
-	// Type of the filesystem. Currently, ext4, cpio, and compressed_cpio are supported. Default
-	// is ext4.
+	// Type of the filesystem. Currently, ext4, erofs, cpio, and compressed_cpio are supported.
+	// Default is ext4.
 	Type *string
 
+	// Properties for erofs images. Only used when type is erofs.
+	Erofs erofsProperties
+
 	// file_contexts file to make image. Currently, only ext4 is supported.
 	File_contexts *string `android:"path"`
 
@@ -94,6 +108,20 @@ type filesystemProperties struct {
 	Symlinks []symlinkDefinition
 }
 
+type erofsProperties struct {
+	// Compressor and compression level passed to mkfs.erofs, e.g. "lz4hc,9". Images are not
+	// compressed when this isn't set.
+	Compressor *string
+
+	// Size in bytes of the physical clusters used for compressed files. Must be a multiple of
+	// the block size. Default is the block size.
+	Pcluster_size *int64
+
+	// Glob patterns, relative to the root of the image, of files that shouldn't be compressed,
+	// e.g. "system/app/**/*.apk".
+	Uncompressed_files []string
+}
+
 // android_filesystem packages a set of modules and their transitive dependencies into a filesystem
 // image. The filesystem images are expected to be mounted in the target device, which means the
 // modules in the filesystem image are built for the target device (i.e. Android, not Linux host).
@@ -124,6 +152,7 @@ type fsType int
 
 const (
 	ext4Type fsType = iota
+	erofsType
 	compressedCpioType
 	cpioType // uncompressed
 	unknown
@@ -134,6 +163,8 @@ func (f *filesystem) fsType(ctx android.ModuleContext) fsType {
 	switch typeStr {
 	case "ext4":
 		return ext4Type
+	case "erofs":
+		return erofsType
 	case "compressed_cpio":
 		return compressedCpioType
 	case "cpio":
@@ -152,7 +183,7 @@ var pctx = android.NewPackageContext("android/soong/filesystem")
 
 func (f *filesystem) GenerateAndroidBuildActions(ctx android.ModuleContext) {
 	switch f.fsType(ctx) {
-	case ext4Type:
+	case ext4Type, erofsType:
 		f.output = f.buildImageUsingBuildImage(ctx)
 	case compressedCpioType:
 		f.output = f.buildCpioImage(ctx, true)
@@ -280,6 +311,54 @@ func (f *filesystem) buildFileContexts(ctx android.ModuleContext) android.Output
 	return fcBin.OutputPath
 }
 
+// Block size of erofs images, which pcluster sizes must be a multiple of.
+const erofsBlockSize = 4096
+
+// buildErofsCompressHints writes a compress hints file for mkfs.erofs that disables compression of
+// the files matching erofs.uncompressed_files. Each line of the file is a pcluster size, 0 meaning
+// uncompressed, followed by a regular expression matched against the path of the file in the
+// root directory.
+func (f *filesystem) buildErofsCompressHints(ctx android.ModuleContext) android.Path {
+	var lines []string
+	for _, glob := range f.properties.Erofs.Uncompressed_files {
+		if strings.HasPrefix(glob, "/") || strings.ContainsAny(glob, " \n") {
+			ctx.PropertyErrorf("erofs.uncompressed_files", "%q must be a relative path without whitespace", glob)
+			continue
+		}
+		lines = append(lines, "0 /"+globToRegexp(glob)+"$")
+	}
+	hints := android.PathForModuleOut(ctx, "erofs_compress_hints.txt")
+	android.WriteFileRule(ctx, hints, strings.Join(lines, "\n"))
+	return hints
+}
+
+// globToRegexp converts a glob pattern, where "**" matches any number of directories, to an
+// equivalent regular expression.
+func globToRegexp(glob string) string {
+	var sb strings.Builder
+	for i := 0; i < len(glob); i++ {
+		switch c := glob[i]; c {
+		case '*':
+			if i+1 < len(glob) && glob[i+1] == '*' {
+				sb.WriteString(".*")
+				i++
+				// "**/" also matches no directories at all.
+				if i+1 < len(glob) && glob[i+1] == '/' {
+					sb.WriteString("/?")
+					i++
+				}
+			} else {
+				sb.WriteString("[^/]*")
+			}
+		case '?':
+			sb.WriteString("[^/]")
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	return sb.String()
+}
+
 func (f *filesystem) buildPropFile(ctx android.ModuleContext) (propFile android.OutputPath, toolDeps android.Paths) {
 	type prop struct {
 		name  string
@@ -299,20 +378,41 @@ func (f *filesystem) buildPropFile(ctx android.ModuleContext) (propFile android.
 	// Type string that build_image.py accepts.
 	fsTypeStr := func(t fsType) string {
 		switch t {
-		// TODO(jiyong): add more types like f2fs, erofs, etc.
+		// TODO(jiyong): add more types like f2fs, etc.
 		case ext4Type:
 			return "ext4"
+		case erofsType:
+			return "erofs"
 		}
 		panic(fmt.Errorf("unsupported fs type %v", t))
 	}
 
-	addStr("fs_type", fsTypeStr(f.fsType(ctx)))
+	fst := f.fsType(ctx)
+	addStr("fs_type", fsTypeStr(fst))
 	addStr("mount_point", "/")
 	addStr("use_dynamic_partition_size", "true")
-	addPath("ext_mkuserimg", ctx.Config().HostToolPath(ctx, "mkuserimg_mke2fs"))
-	// b/177813163 deps of the host tools have to be added. Remove this.
-	for _, t := range []string{"mke2fs", "e2fsdroid", "tune2fs"} {
-		deps = append(deps, ctx.Config().HostToolPath(ctx, t))
+	switch fst {
+	case ext4Type:
+		addPath("ext_mkuserimg", ctx.Config().HostToolPath(ctx, "mkuserimg_mke2fs"))
+		// b/177813163 deps of the host tools have to be added. Remove this.
+		for _, t := range []string{"mke2fs", "e2fsdroid", "tune2fs"} {
+			deps = append(deps, ctx.Config().HostToolPath(ctx, t))
+		}
+	case erofsType:
+		deps = append(deps, ctx.Config().HostToolPath(ctx, "mkfs.erofs"))
+		if compressor := proptools.String(f.properties.Erofs.Compressor); compressor != "" {
+			addStr("erofs_default_compressor", compressor)
+		}
+		if pclusterSize := f.properties.Erofs.Pcluster_size; pclusterSize != nil {
+			if *pclusterSize <= 0 || *pclusterSize%erofsBlockSize != 0 {
+				ctx.PropertyErrorf("erofs.pcluster_size", "must be a positive multiple of %d, got %d",
+					erofsBlockSize, *pclusterSize)
+			}
+			addStr("erofs_pcluster_size", strconv.FormatInt(*pclusterSize, 10))
+		}
+		if len(f.properties.Erofs.Uncompressed_files) > 0 {
+			addPath("erofs_default_compress_hints", f.buildErofsCompressHints(ctx))
+		}
 	}
 
 	if proptools.Bool(f.properties.Use_avb) {
@@ -326,6 +426,8 @@ func (f *filesystem) buildPropFile(ctx android.ModuleContext) (propFile android.
 		if hashAlgorithm := proptools.String(f.properties.Avb_hash_algorithm); hashAlgorithm != "" {
 			avb_add_hashtree_footer_args += " --hash_algorithm " + hashAlgorithm
 		}
+		avb_add_hashtree_footer_args += avbRollbackIndexArgs(ctx, f.properties.Avb_rollback_index,
+			f.properties.Avb_rollback_index_location)
 		addStr("avb_add_hashtree_footer_args", avb_add_hashtree_footer_args)
 		partitionName := proptools.StringDefault(f.properties.Partition_name, f.Name())
 		addStr("partition_name", partitionName)
@@ -459,3 +561,22 @@ func (f *filesystem) gatherFilteredPackagingSpecs(ctx android.ModuleContext) map
 	}
 	return specs
 }
+
+// avbRollbackIndexArgs returns the avbtool arguments for signing an image with the given rollback
+// index and rollback index location. Each argument is omitted if its value isn't set.
+func avbRollbackIndexArgs(ctx android.ModuleContext, index, location *int64) string {
+	var args string
+	if index != nil {
+		if *index < 0 {
+			ctx.PropertyErrorf("avb_rollback_index", "must be 0, 1, 2, ...")
+		}
+		args += " --rollback_index " + strconv.FormatInt(*index, 10)
+	}
+	if location != nil {
+		if *location < 0 {
+			ctx.PropertyErrorf("avb_rollback_index_location", "must be 0, 1, 2, ...")
+		}
+		args += " --rollback_index_location " + strconv.FormatInt(*location, 10)
+	}
+	return args
+}
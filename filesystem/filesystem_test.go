@@ -18,6 +18,8 @@ import (
 	"os"
 	"testing"
 
+	"github.com/google/blueprint/proptools"
+
 	"android/soong/android"
 	"android/soong/cc"
 )
@@ -125,3 +127,166 @@ func TestFileSystemGathersItemsOnlyInSystemPartition(t *testing.T) {
 	module := result.ModuleForTests("myfilesystem", "android_common").Module().(*systemImage)
 	android.AssertDeepEquals(t, "entries should have foo only", []string{"components/foo"}, module.entries)
 }
+
+func TestFileSystemErofs(t *testing.T) {
+	result := fixture.RunTestWithBp(t, `
+		android_filesystem {
+			name: "myfilesystem",
+			type: "erofs",
+			erofs: {
+				compressor: "lz4hc,9",
+				pcluster_size: 65536,
+				uncompressed_files: ["app/**/*.apk", "etc/?.txt"],
+			},
+		}
+	`)
+
+	module := result.ModuleForTests("myfilesystem", "android_common")
+	props := module.Rule("build_filesystem_prop").RuleParams.Command
+	for _, prop := range []string{"fs_type=erofs", "erofs_default_compressor=lz4hc,9", "erofs_pcluster_size=65536",
+		"erofs_default_compress_hints="} {
+		android.AssertStringDoesContain(t, "prop file", props, prop)
+	}
+	android.AssertStringDoesNotContain(t, "prop file", props, "ext_mkuserimg")
+
+	hints := android.ContentFromFileRuleForTests(t, module.Output("erofs_compress_hints.txt"))
+	android.AssertStringEquals(t, "compress hints", "0 /app/.*/?[^/]*\\.apk$\n0 /etc/[^/]\\.txt$\n", hints)
+}
+
+func TestFileSystemErofsInvalidPclusterSize(t *testing.T) {
+	fixture.ExtendWithErrorHandler(android.FixtureExpectsAtLeastOneErrorMatchingPattern(
+		`erofs.pcluster_size: must be a positive multiple of 4096, got 1000`)).
+		RunTestWithBp(t, `
+			android_filesystem {
+				name: "myfilesystem",
+				type: "erofs",
+				erofs: {
+					pcluster_size: 1000,
+				},
+			}
+		`)
+}
+
+var prepareForTestWithVbmeta = android.FixtureRegisterWithContext(func(ctx android.RegistrationContext) {
+	ctx.RegisterModuleType("vbmeta", vbmetaFactory)
+})
+
+func TestFileSystemAvbRollbackIndex(t *testing.T) {
+	result := android.GroupFixturePreparers(
+		fixture,
+		prepareForTestWithVbmeta,
+		android.FixtureAddTextFile("testkey.pem", ""),
+	).RunTestWithBp(t, `
+		android_filesystem {
+			name: "myfilesystem",
+			use_avb: true,
+			avb_private_key: "testkey.pem",
+			avb_rollback_index: 5,
+			avb_rollback_index_location: 2,
+		}
+
+		vbmeta {
+			name: "myvbmeta",
+			private_key: "testkey.pem",
+			rollback_index: 3,
+		}
+	`)
+
+	props := result.ModuleForTests("myfilesystem", "android_common").Rule("build_filesystem_prop").RuleParams.Command
+	android.AssertStringDoesContain(t, "avb_add_hashtree_footer_args", props,
+		"avb_add_hashtree_footer_args=--do_not_generate_fec --rollback_index 5 --rollback_index_location 2")
+
+	cmd := result.ModuleForTests("myvbmeta", "android_arm64_armv8-a").Rule("vbmeta").RuleParams.Command
+	android.AssertStringDoesContain(t, "vbmeta rollback index", cmd, "--rollback_index 3 ")
+}
+
+func TestVbmetaRollbackIndexConflict(t *testing.T) {
+	android.GroupFixturePreparers(
+		fixture,
+		prepareForTestWithVbmeta,
+		android.FixtureMergeMockFs(android.MockFS{
+			"testkey.pem":    nil,
+			"rollback_index": nil,
+		}),
+	).ExtendWithErrorHandler(android.FixtureExpectsAtLeastOneErrorMatchingPattern(
+		`rollback_index: can't be used together with rollback_index_file`)).
+		RunTestWithBp(t, `
+			vbmeta {
+				name: "myvbmeta",
+				private_key: "testkey.pem",
+				rollback_index: 3,
+				rollback_index_file: "rollback_index",
+			}
+		`)
+}
+
+func TestRamdiskGathersRamdiskVariants(t *testing.T) {
+	result := fixture.RunTestWithBp(t, `
+		android_ramdisk {
+			name: "myramdisk",
+			deps: ["foo"],
+		}
+
+		android_ramdisk {
+			name: "myvendorramdisk",
+			vendor_ramdisk: true,
+			deps: ["foo"],
+		}
+
+		cc_binary {
+			name: "foo",
+			ramdisk_available: true,
+			vendor_ramdisk_available: true,
+			nocrt: true,
+			system_shared_libs: [],
+			stl: "none",
+		}
+	`)
+
+	ramdisk := result.ModuleForTests("myramdisk", "android_common")
+	android.AssertDeepEquals(t, "ramdisk entries", []string{"system/bin/foo"},
+		ramdisk.Module().(*ramdiskImage).entries)
+	ramdisk.Output("myramdisk.img")
+
+	vendorRamdisk := result.ModuleForTests("myvendorramdisk", "android_common")
+	android.AssertDeepEquals(t, "vendor ramdisk entries", []string{"system/bin/foo"},
+		vendorRamdisk.Module().(*ramdiskImage).entries)
+}
+
+func TestRamdiskRecoveryAsBoot(t *testing.T) {
+	result := android.GroupFixturePreparers(
+		fixture,
+		android.FixtureModifyProductVariables(func(variables android.FixtureProductVariables) {
+			variables.BoardUsesRecoveryAsBoot = proptools.BoolPtr(true)
+		}),
+	).RunTestWithBp(t, `
+		android_ramdisk {
+			name: "myramdisk",
+			deps: ["foo"],
+		}
+
+		cc_binary {
+			name: "foo",
+			ramdisk_available: true,
+			nocrt: true,
+			system_shared_libs: [],
+			stl: "none",
+		}
+	`)
+
+	// The ramdisk variants are installed in recovery/root/first_stage_ramdisk.
+	ramdisk := result.ModuleForTests("myramdisk", "android_common")
+	android.AssertDeepEquals(t, "ramdisk entries", []string{"first_stage_ramdisk/system/bin/foo"},
+		ramdisk.Module().(*ramdiskImage).entries)
+}
+
+func TestRamdiskRejectsImageTypes(t *testing.T) {
+	fixture.ExtendWithErrorHandler(android.FixtureExpectsAtLeastOneErrorMatchingPattern(
+		`type: must be either cpio or compressed_cpio`)).
+		RunTestWithBp(t, `
+			android_ramdisk {
+				name: "myramdisk",
+				type: "ext4",
+			}
+		`)
+}
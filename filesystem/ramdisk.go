@@ -0,0 +1,109 @@
+// Copyright (C) 2022 The Android Open Source Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package filesystem
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/google/blueprint"
+	"github.com/google/blueprint/proptools"
+
+	"android/soong/android"
+)
+
+type ramdiskImage struct {
+	filesystem
+
+	properties ramdiskProperties
+
+	// The directory of the product out directory that the deps are installed to, which is the root
+	// of the ramdisk.
+	root string
+}
+
+type ramdiskProperties struct {
+	// When set to true, the ramdisk is assembled from the vendor_ramdisk variants of deps, which
+	// are the ones installed to the ramdisk of the vendor_boot partition. Otherwise, the ramdisk
+	// variants of deps are used. Default is false.
+	Vendor_ramdisk *bool
+}
+
+// android_ramdisk is a specialization of android_filesystem for ramdisks. The ramdisk (or
+// vendor_ramdisk) variants of deps are placed in the image at the paths they are installed to
+// under the ramdisk directory of the product out directory. The image is an lz4 compressed cpio
+// archive by default, which can be used as the ramdisk_module of a bootimg module.
+func ramdiskFactory() android.Module {
+	module := &ramdiskImage{}
+	module.AddProperties(&module.properties)
+	module.filesystem.filterPackagingSpecs = module.filterPackagingSpecs
+	module.filesystem.properties.Type = proptools.StringPtr("compressed_cpio")
+	initFilesystemModule(&module.filesystem)
+	return module
+}
+
+func (r *ramdiskImage) imageVariation() string {
+	if proptools.Bool(r.properties.Vendor_ramdisk) {
+		return android.VendorRamdiskVariation
+	}
+	return android.RamdiskVariation
+}
+
+// ramdiskRoot returns the directory of the product out directory that the deps are installed to.
+// On devices that use the recovery partition as boot, the ramdisk variants are installed in the
+// root of the recovery ramdisk, under first_stage_ramdisk.
+func (r *ramdiskImage) ramdiskRoot(ctx android.ModuleContext) string {
+	if !proptools.Bool(r.properties.Vendor_ramdisk) && ctx.DeviceConfig().BoardUsesRecoveryAsBoot() {
+		return "recovery/root"
+	}
+	return r.imageVariation()
+}
+
+func (r *ramdiskImage) DepsMutator(ctx android.BottomUpMutatorContext) {
+	r.AddDepsWithVariations(ctx, dependencyTag, []blueprint.Variation{
+		{Mutator: "image", Variation: r.imageVariation()},
+	})
+}
+
+func (r *ramdiskImage) GenerateAndroidBuildActions(ctx android.ModuleContext) {
+	if t := r.fsType(ctx); t != compressedCpioType && t != cpioType {
+		ctx.PropertyErrorf("type", "must be either cpio or compressed_cpio")
+		return
+	}
+	r.root = r.ramdiskRoot(ctx)
+	r.filesystem.GenerateAndroidBuildActions(ctx)
+}
+
+// Move the items installed to the ramdisk partition to their paths relative to the root of the
+// ramdisk, and discard the rest. Modules are installed to subdirectories of the ramdisk, e.g.
+// ramdisk/system, vendor_ramdisk/first_stage_ramdisk or recovery/root/first_stage_ramdisk, which
+// are kept in the image.
+func (r *ramdiskImage) filterPackagingSpecs(specs map[string]android.PackagingSpec) {
+	root := r.root
+	var moved []android.PackagingSpec
+	for k, ps := range specs {
+		delete(specs, k)
+		partition := ps.Partition()
+		if partition != root && !strings.HasPrefix(partition, root+"/") {
+			continue
+		}
+		subdir := strings.TrimPrefix(strings.TrimPrefix(partition, root), "/")
+		ps.SetRelPathInPackage(filepath.Join(subdir, ps.RelPathInPackage()))
+		moved = append(moved, ps)
+	}
+	for _, ps := range moved {
+		specs[ps.RelPathInPackage()] = ps
+	}
+}
@@ -50,6 +50,10 @@ type vbmetaProperties struct {
 	// Algorithm that avbtool will use to sign this vbmeta image. Default is SHA256_RSA4096.
 	Algorithm *string
 
+	// Rollback index of this vbmeta image. Must be 0, 1, 2, etc. If unspecified, the rollback
+	// index is from rollback_index_file. Can't be set together with rollback_index_file.
+	Rollback_index *int64
+
 	// File whose content will provide the rollback index. If unspecified, the rollback index
 	// is from PLATFORM_SECURITY_PATCH
 	Rollback_index_file *string `android:"path"`
@@ -190,6 +194,16 @@ func (v *vbmeta) GenerateAndroidBuildActions(ctx android.ModuleContext) {
 
 // Returns the embedded shell command that prints the rollback index
 func (v *vbmeta) rollbackIndexCommand(ctx android.ModuleContext) string {
+	if v.properties.Rollback_index != nil {
+		if v.properties.Rollback_index_file != nil {
+			ctx.PropertyErrorf("rollback_index", "can't be used together with rollback_index_file")
+		}
+		if *v.properties.Rollback_index < 0 {
+			ctx.PropertyErrorf("rollback_index", "must be 0, 1, 2, ...")
+		}
+		return strconv.FormatInt(*v.properties.Rollback_index, 10)
+	}
+
 	var cmd string
 	if v.properties.Rollback_index_file != nil {
 		f := android.PathForModuleSrc(ctx, proptools.String(v.properties.Rollback_index_file))
@@ -78,12 +78,21 @@ type FuzzConfig struct {
 	Acknowledgement []string `json:"acknowledgement,omitempty"`
 	// Additional options to be passed to libfuzzer when run in Haiku.
 	Libfuzzer_options []string `json:"libfuzzer_options,omitempty"`
-	// Additional options to be passed to HWASAN when running on-device in Haiku.
+	// Additional options to be passed to HWASAN when running on-device in Haiku, in the form
+	// option=value.  They override the default HWASAN_OPTIONS of the fuzzing infrastructure.
 	Hwasan_options []string `json:"hwasan_options,omitempty"`
-	// Additional options to be passed to HWASAN when running on host in Haiku.
+	// Additional options to be passed to ASAN when running on host in Haiku, in the form
+	// option=value.  They override the default ASAN_OPTIONS of the fuzzing infrastructure.
 	Asan_options []string `json:"asan_options,omitempty"`
+	// Classes of devices that the fuzz target should be run on, any of "phone", "tablet", "tv",
+	// "wear" and "auto".  Defaults to all classes of devices.
+	Target_device_classes []string `json:"target_device_classes,omitempty"`
+	// Team or person that owns the fuzz target.  Defaults to the owner of the module.
+	Owner *string `json:"owner,omitempty"`
 }
 
+var fuzzTargetDeviceClasses = []string{"phone", "tablet", "tv", "wear", "auto"}
+
 type FuzzProperties struct {
 	// Optional list of seed files to be installed to the fuzz target's output
 	// directory.
@@ -205,6 +214,80 @@ func (f *FuzzConfig) String() string {
 	return string(b)
 }
 
+// StageArtifacts copies the corpus and the data files of a fuzz target to intermediate directories,
+// checks its dictionary and writes its config.json, so that they can be packaged with the fuzz
+// target.
+func (m *FuzzPackagedModule) StageArtifacts(ctx android.ModuleContext, pctx android.PackageContext) {
+	m.Corpus = android.PathsForModuleSrc(ctx, m.FuzzProperties.Corpus)
+	builder := android.NewRuleBuilder(pctx, ctx)
+	intermediateDir := android.PathForModuleOut(ctx, "corpus")
+	for _, entry := range m.Corpus {
+		builder.Command().Text("cp").
+			Input(entry).
+			Output(intermediateDir.Join(ctx, entry.Base()))
+	}
+	builder.Build("copy_corpus", "copy corpus")
+	m.CorpusIntermediateDir = intermediateDir
+
+	m.Data = android.PathsForModuleSrc(ctx, m.FuzzProperties.Data)
+	builder = android.NewRuleBuilder(pctx, ctx)
+	intermediateDir = android.PathForModuleOut(ctx, "data")
+	for _, entry := range m.Data {
+		builder.Command().Text("cp").
+			Input(entry).
+			Output(intermediateDir.Join(ctx, entry.Rel()))
+	}
+	builder.Build("copy_data", "copy data")
+	m.DataIntermediateDir = intermediateDir
+
+	if m.FuzzProperties.Dictionary != nil {
+		m.Dictionary = android.PathForModuleSrc(ctx, *m.FuzzProperties.Dictionary)
+		if m.Dictionary.Ext() != ".dict" {
+			ctx.PropertyErrorf("dictionary",
+				"Fuzzer dictionary %q does not have '.dict' extension",
+				m.Dictionary.String())
+		}
+	}
+
+	m.WriteConfig(ctx)
+}
+
+// WriteConfig checks the fuzz_config property of a fuzz target and writes it to config.json, the
+// metadata that is packaged with the fuzz target for the fuzzing infrastructure.
+func (m *FuzzPackagedModule) WriteConfig(ctx android.ModuleContext) {
+	config := m.FuzzProperties.Fuzz_config
+	if config == nil {
+		return
+	}
+
+	checkSanitizerOptions(ctx, "fuzz_config.asan_options", config.Asan_options)
+	checkSanitizerOptions(ctx, "fuzz_config.hwasan_options", config.Hwasan_options)
+	for _, class := range config.Target_device_classes {
+		if !android.InList(class, fuzzTargetDeviceClasses) {
+			ctx.PropertyErrorf("fuzz_config.target_device_classes", "unknown device class %q, expected one of %q",
+				class, fuzzTargetDeviceClasses)
+		}
+	}
+
+	if config.Owner == nil && ctx.Module().Owner() != "" {
+		withOwner := *config
+		withOwner.Owner = proptools.StringPtr(ctx.Module().Owner())
+		config = &withOwner
+	}
+
+	configPath := android.PathForModuleOut(ctx, "config").Join(ctx, "config.json")
+	android.WriteFileRule(ctx, configPath, config.String())
+	m.Config = configPath
+}
+
+func checkSanitizerOptions(ctx android.ModuleContext, property string, options []string) {
+	for _, option := range options {
+		if strings.Index(option, "=") <= 0 {
+			ctx.PropertyErrorf(property, "option %q is not in the form option=value", option)
+		}
+	}
+}
+
 func (s *FuzzPackager) CreateFuzzPackage(ctx android.SingletonContext, archDirs map[ArchOs][]FileToZip, lang Lang, pctx android.PackageContext) {
 	var archOsList []ArchOs
 	for archOs := range archDirs {
@@ -76,6 +76,11 @@ type appProperties struct {
 	// sdk_version.
 	Jni_uses_sdk_apis *bool
 
+	// list of sanitizers (address or hwaddress) whose variants of the JNI libraries should be
+	// packaged when the product is built with the sanitizer. The sanitized variants are only used
+	// by this app, so use_embedded_native_libs must be set.
+	Jni_sanitizers []string
+
 	// STL library to use for JNI libraries.
 	Stl *string `android:"arch_variant"`
 
@@ -237,6 +242,15 @@ func (a *AndroidApp) DepsMutator(ctx android.BottomUpMutatorContext) {
 			"can only be set for modules that set sdk_version")
 	}
 
+	for _, sanitizer := range a.appProperties.Jni_sanitizers {
+		if sanitizer != "address" && sanitizer != "hwaddress" {
+			ctx.PropertyErrorf("jni_sanitizers", "unsupported sanitizer %q, must be address or hwaddress", sanitizer)
+		}
+	}
+	if len(a.appProperties.Jni_sanitizers) > 0 && !Bool(a.appProperties.Use_embedded_native_libs) {
+		ctx.PropertyErrorf("jni_sanitizers", "can only be set for modules that set use_embedded_native_libs")
+	}
+
 	for _, jniTarget := range ctx.MultiTargets() {
 		variation := append(jniTarget.Variations(),
 			blueprint.Variation{Mutator: "link", Variation: "shared"})
@@ -304,6 +318,21 @@ func (a *AndroidApp) checkAppSdkVersions(ctx android.ModuleContext) {
 	a.checkSdkVersions(ctx)
 }
 
+// Implements cc.JniSanitizeable. The sanitized variants of the JNI libraries are only used when the
+// product is built with the sanitizer, which provides its runtime on the device. The app is then
+// split into the sanitizer's variation, which depends on the sanitized variants.
+func (a *AndroidApp) IsSanitizerEnabledForJni(ctx android.BaseModuleContext, sanitizerName string) bool {
+	return android.InList(sanitizerName, a.appProperties.Jni_sanitizers) &&
+		android.InList(sanitizerName, ctx.Config().SanitizeDevice())
+}
+
+// Implements cc.JniSanitizeable.
+func (a *AndroidApp) IsJniDependencyTag(tag blueprint.DependencyTag) bool {
+	return IsJniDepTag(tag)
+}
+
+var _ cc.JniSanitizeable = (*AndroidApp)(nil)
+
 // If an updatable APK sets min_sdk_version, min_sdk_vesion of JNI libs should match with it.
 // This check is enforced for "updatable" APKs (including APK-in-APEX).
 func (a *AndroidApp) checkJniLibsSdkVersion(ctx android.ModuleContext, minSdkVersion android.ApiLevel) {
@@ -618,7 +647,7 @@ func (a *AndroidApp) generateAndroidBuildActions(ctx android.ModuleContext) {
 
 	// Check that the <uses-library> list is coherent with the manifest.
 	if a.usesLibrary.enforceUsesLibraries() {
-		manifestCheckFile := a.usesLibrary.verifyUsesLibrariesManifest(ctx, a.mergedManifestFile)
+		manifestCheckFile := a.usesLibrary.verifyUsesLibrariesManifest(ctx, a.mergedManifestFile, a.classLoaderContexts)
 		apkDeps = append(apkDeps, manifestCheckFile)
 	}
 
@@ -657,7 +686,7 @@ func (a *AndroidApp) generateAndroidBuildActions(ctx android.ModuleContext) {
 
 	// Build a final signed app package.
 	packageFile := android.PathForModuleOut(ctx, a.installApkName+".apk")
-	v4SigningRequested := Bool(a.Module.deviceProperties.V4_signature)
+	v4SigningRequested := BoolDefault(a.Module.deviceProperties.V4_signature, ctx.Config().ApkV4Signature())
 	var v4SignatureFile android.WritablePath = nil
 	if v4SigningRequested {
 		v4SignatureFile = android.PathForModuleOut(ctx, a.installApkName+".apk.idsig")
@@ -971,6 +1000,7 @@ func AndroidAppFactory() android.Module {
 	module := &AndroidApp{}
 
 	module.Module.dexProperties.Optimize.EnabledByDefault = true
+	module.Module.dexProperties.Main_dex.LegacyMultidexByDefault = true
 	module.Module.dexProperties.Optimize.Shrink = proptools.BoolPtr(true)
 
 	module.Module.properties.Instrument = true
@@ -1041,6 +1071,21 @@ func (a *AndroidTest) GenerateAndroidBuildActions(ctx android.ModuleContext) {
 	a.testConfig = a.FixTestConfig(ctx, testConfig)
 	a.extraTestConfigs = android.PathsForModuleSrc(ctx, a.testProperties.Test_options.Extra_test_configs)
 	a.data = android.PathsForModuleSrc(ctx, a.testProperties.Data)
+
+	if len(a.testProperties.Test_suites) > 0 {
+		data := make(map[string]android.Path)
+		for _, d := range a.data {
+			data[d.Rel()] = d
+		}
+		ctx.SetProvider(android.TestSuiteInfoProvider, android.TestSuiteInfo{
+			TestSuites:       a.testProperties.Test_suites,
+			OutputFile:       a.outputFile,
+			OutputFileName:   a.installApkName + ".apk",
+			Data:             data,
+			TestConfig:       a.testConfig,
+			ExtraTestConfigs: a.extraTestConfigs,
+		})
+	}
 }
 
 func (a *AndroidTest) FixTestConfig(ctx android.ModuleContext, testConfig android.Path) android.Path {
@@ -1092,6 +1137,7 @@ func AndroidTestFactory() android.Module {
 	module := &AndroidTest{}
 
 	module.Module.dexProperties.Optimize.EnabledByDefault = true
+	module.Module.dexProperties.Main_dex.LegacyMultidexByDefault = true
 
 	module.Module.properties.Instrument = true
 	module.Module.properties.Supports_static_instrumentation = true
@@ -1146,6 +1192,7 @@ func AndroidTestHelperAppFactory() android.Module {
 	module := &AndroidTestHelperApp{}
 
 	module.Module.dexProperties.Optimize.EnabledByDefault = true
+	module.Module.dexProperties.Main_dex.LegacyMultidexByDefault = true
 
 	module.Module.properties.Installable = proptools.BoolPtr(true)
 	module.appProperties.Use_embedded_native_libs = proptools.BoolPtr(true)
@@ -1412,8 +1459,13 @@ func (u *usesLibrary) freezeEnforceUsesLibraries() {
 // in the `uses_libs`/`optional_uses_libs` properties. The input can be either an XML manifest, or
 // an APK with the manifest embedded in it (manifest_check will know which one it is by the file
 // extension: APKs are supposed to end with '.apk').
+//
+// Besides the status file read by dexpreopt, the check writes a JSON report of the <uses-library>
+// lists in the build system, in the manifest and in the on-device class loader context, and a
+// file with the `uses_libs`/`optional_uses_libs` properties that agree with the manifest, which
+// the error message points developers to when the check fails.
 func (u *usesLibrary) verifyUsesLibraries(ctx android.ModuleContext, inputFile android.Path,
-	outputFile android.WritablePath) android.Path {
+	outputFile android.WritablePath, clcMap dexpreopt.ClassLoaderContextMap) android.Path {
 
 	statusFile := dexpreopt.UsesLibrariesStatusFile(ctx)
 
@@ -1437,6 +1489,9 @@ func (u *usesLibrary) verifyUsesLibraries(ctx android.ModuleContext, inputFile a
 		cmd.FlagWithOutput("-o ", outputFile)
 	}
 
+	cmd.FlagWithOutput("--enforce-uses-libraries-report ", usesLibrariesReportFile(ctx)).
+		FlagWithOutput("--enforce-uses-libraries-fix ", usesLibrariesFixFile(ctx))
+
 	if dexpreopt.GetGlobalConfig(ctx).RelaxUsesLibraryCheck {
 		cmd.Flag("--enforce-uses-libraries-relax")
 	}
@@ -1449,21 +1504,39 @@ func (u *usesLibrary) verifyUsesLibraries(ctx android.ModuleContext, inputFile a
 		cmd.FlagWithArg("--optional-uses-library ", lib)
 	}
 
+	if clcMap != nil {
+		for _, clc := range clcMap[dexpreopt.AnySdkVersion] {
+			cmd.FlagWithArg("--on-device-library ", clc.Name+"="+clc.Device)
+		}
+	}
+
 	rule.Build("verify_uses_libraries", "verify <uses-library>")
 	return outputFile
 }
 
+// usesLibrariesReportFile returns the path to the JSON report written by verifyUsesLibraries.
+func usesLibrariesReportFile(ctx android.ModuleContext) android.WritablePath {
+	return android.PathForModuleOut(ctx, "enforce_uses_libraries.report.json")
+}
+
+// usesLibrariesFixFile returns the path to the properties suggested by verifyUsesLibraries.
+func usesLibrariesFixFile(ctx android.ModuleContext) android.WritablePath {
+	return android.PathForModuleOut(ctx, "enforce_uses_libraries.fix.bp")
+}
+
 // verifyUsesLibrariesManifest checks the <uses-library> tags in an AndroidManifest.xml against
 // the build system and returns the path to a copy of the manifest.
-func (u *usesLibrary) verifyUsesLibrariesManifest(ctx android.ModuleContext, manifest android.Path) android.Path {
+func (u *usesLibrary) verifyUsesLibrariesManifest(ctx android.ModuleContext, manifest android.Path,
+	clcMap dexpreopt.ClassLoaderContextMap) android.Path {
 	outputFile := android.PathForModuleOut(ctx, "manifest_check", "AndroidManifest.xml")
-	return u.verifyUsesLibraries(ctx, manifest, outputFile)
+	return u.verifyUsesLibraries(ctx, manifest, outputFile, clcMap)
 }
 
 // verifyUsesLibrariesAPK checks the <uses-library> tags in the manifest of an APK against the build
 // system and returns the path to a copy of the APK.
-func (u *usesLibrary) verifyUsesLibrariesAPK(ctx android.ModuleContext, apk android.Path) android.Path {
-	u.verifyUsesLibraries(ctx, apk, nil) // for APKs manifest_check does not write output file
+func (u *usesLibrary) verifyUsesLibrariesAPK(ctx android.ModuleContext, apk android.Path,
+	clcMap dexpreopt.ClassLoaderContextMap) android.Path {
+	u.verifyUsesLibraries(ctx, apk, nil, clcMap) // for APKs manifest_check does not write output file
 	outputFile := android.PathForModuleOut(ctx, "verify_uses_libraries", apk.Base())
 	return outputFile
 }
@@ -1369,6 +1369,82 @@ func TestJNIPackaging(t *testing.T) {
 	}
 }
 
+func TestJNISanitizers(t *testing.T) {
+	result := android.GroupFixturePreparers(
+		prepareForJavaTest,
+		android.FixtureModifyProductVariables(func(variables android.FixtureProductVariables) {
+			variables.SanitizeDevice = []string{"hwaddress"}
+			// Only sanitize the x86_64 variants globally, so that the arm64 variants of the JNI
+			// libraries are only sanitized when an app requires it.
+			variables.SanitizeDeviceArch = []string{"x86_64"}
+		}),
+	).RunTestWithBp(t, cc.GatherRequiredDepsForTest(android.Android)+`
+		cc_library {
+			name: "libjni",
+			system_shared_libs: [],
+			stl: "none",
+		}
+
+		android_app {
+			name: "app",
+			platform_apis: true,
+			jni_libs: ["libjni"],
+			jni_sanitizers: ["hwaddress"],
+			use_embedded_native_libs: true,
+		}
+
+		android_app {
+			name: "app_unsanitized",
+			platform_apis: true,
+			jni_libs: ["libjni"],
+			use_embedded_native_libs: true,
+		}
+		`)
+
+	testCases := []struct {
+		name    string
+		variant string
+		jniLibs []string
+	}{
+		{"app", "android_common_hwasan", []string{"out/soong/.intermediates/libjni/android_arm64_armv8-a_shared_hwasan/libjni.so"}},
+		// The sanitized variant of libjni is only used by the app that requires it.
+		{"app_unsanitized", "android_common", []string{"out/soong/.intermediates/libjni/android_arm64_armv8-a_shared/libjni.so"}},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.name, func(t *testing.T) {
+			app := result.ModuleForTests(test.name, test.variant)
+			jniLibZip := app.Output("jnilibs.zip")
+			android.AssertPathsRelativeToTopEquals(t, "jni libs", test.jniLibs, jniLibZip.Implicits)
+		})
+	}
+
+	android.GroupFixturePreparers(
+		prepareForJavaTest,
+	).ExtendWithErrorHandler(android.FixtureExpectsAtLeastOneErrorMatchingPattern(
+		`jni_sanitizers: unsupported sanitizer "cfi", must be address or hwaddress`)).
+		RunTestWithBp(t, `
+			android_app {
+				name: "app",
+				platform_apis: true,
+				jni_sanitizers: ["cfi"],
+				use_embedded_native_libs: true,
+			}
+		`)
+
+	android.GroupFixturePreparers(
+		prepareForJavaTest,
+	).ExtendWithErrorHandler(android.FixtureExpectsAtLeastOneErrorMatchingPattern(
+		`jni_sanitizers: can only be set for modules that set use_embedded_native_libs`)).
+		RunTestWithBp(t, `
+			android_app {
+				name: "app",
+				platform_apis: true,
+				jni_sanitizers: ["hwaddress"],
+			}
+		`)
+}
+
 func TestJNISDK(t *testing.T) {
 	ctx, _ := testJava(t, cc.GatherRequiredDepsForTest(android.Android)+`
 		cc_library {
@@ -1633,9 +1709,10 @@ func TestCertificates(t *testing.T) {
 
 func TestRequestV4SigningFlag(t *testing.T) {
 	testCases := []struct {
-		name     string
-		bp       string
-		expected string
+		name           string
+		bp             string
+		productDefault bool
+		expected       string
 	}{
 		{
 			name: "default",
@@ -1672,12 +1749,40 @@ func TestRequestV4SigningFlag(t *testing.T) {
 			`,
 			expected: "--enable-v4",
 		},
+		{
+			name: "product default",
+			bp: `
+				android_app {
+					name: "foo",
+					srcs: ["a.java"],
+					sdk_version: "current",
+				}
+			`,
+			productDefault: true,
+			expected:       "--enable-v4",
+		},
+		{
+			name: "module overrides product default",
+			bp: `
+				android_app {
+					name: "foo",
+					srcs: ["a.java"],
+					sdk_version: "current",
+					v4_signature: false,
+				}
+			`,
+			productDefault: true,
+			expected:       "",
+		},
 	}
 
 	for _, test := range testCases {
 		t.Run(test.name, func(t *testing.T) {
 			result := android.GroupFixturePreparers(
 				PrepareForTestWithJavaDefaultModules,
+				android.FixtureModifyProductVariables(func(variables android.FixtureProductVariables) {
+					variables.ApkV4Signature = proptools.BoolPtr(test.productDefault)
+				}),
 			).RunTestWithBp(t, test.bp)
 
 			foo := result.ModuleForTests("foo", "android_common")
@@ -1685,6 +1790,9 @@ func TestRequestV4SigningFlag(t *testing.T) {
 			signapk := foo.Output("foo.apk")
 			signFlags := signapk.Args["flags"]
 			android.AssertStringEquals(t, "signing flags", test.expected, signFlags)
+
+			idsig := foo.MaybeOutput("foo.apk.idsig")
+			android.AssertBoolEquals(t, "idsig generated", test.expected != "", idsig.Rule != nil)
 		})
 	}
 }
@@ -2360,6 +2468,35 @@ func TestAndroidTest_FixTestConfig(t *testing.T) {
 	}
 }
 
+func TestAndroidTestSuiteInfo(t *testing.T) {
+	ctx, _ := testJava(t, `
+		android_test {
+			name: "foo_test",
+			srcs: ["a.java"],
+			sdk_version: "current",
+			test_suites: ["device-tests"],
+		}
+
+		override_android_test {
+			name: "bar_test",
+			base: "foo_test",
+		}
+		`)
+
+	foo := ctx.ModuleForTests("foo_test", "android_common")
+	info := ctx.ModuleProvider(foo.Module(), android.TestSuiteInfoProvider).(android.TestSuiteInfo)
+	android.AssertArrayString(t, "test suites", []string{"device-tests"}, info.TestSuites)
+	android.AssertPathRelativeToTopEquals(t, "output file",
+		"out/soong/.intermediates/foo_test/android_common/foo_test.apk", info.OutputFile)
+	android.AssertStringEquals(t, "output file name", "foo_test.apk", info.OutputFileName)
+	android.AssertPathRelativeToTopEquals(t, "test config",
+		"out/soong/.intermediates/foo_test/android_common/foo_test.config", info.TestConfig)
+
+	bar := ctx.ModuleForTests("foo_test", "android_common_bar_test")
+	info = ctx.ModuleProvider(bar.Module(), android.TestSuiteInfoProvider).(android.TestSuiteInfo)
+	android.AssertStringEquals(t, "overridden output file name", "bar_test.apk", info.OutputFileName)
+}
+
 func TestInstrumentationTargetPrebuilt(t *testing.T) {
 	bp := `
 		android_app_import {
@@ -2627,6 +2764,14 @@ func TestUsesLibraries(t *testing.T) {
 		`--optional-uses-library runtime-optional-x ` +
 		`--optional-uses-library runtime-optional-y `
 	android.AssertStringDoesContain(t, "verify cmd args", verifyCmd, verifyArgs)
+	android.AssertStringDoesContain(t, "verify cmd on-device libraries", verifyCmd,
+		`--on-device-library foo=/system/framework/foo.jar `)
+
+	// Test that the structured report and the suggested fix are written by the check.
+	android.AssertStringDoesContain(t, "verify cmd report", verifyCmd,
+		"--enforce-uses-libraries-report out/soong/.intermediates/app/android_common/enforce_uses_libraries.report.json")
+	android.AssertStringDoesContain(t, "verify cmd fix", verifyCmd,
+		"--enforce-uses-libraries-fix out/soong/.intermediates/app/android_common/enforce_uses_libraries.fix.bp")
 
 	// Test that all libraries are verified for an APK (library order matters).
 	verifyApkCmd := prebuilt.Rule("verify_uses_libraries").RuleParams.Command
@@ -102,6 +102,13 @@ type CommonProperties struct {
 	// List of modules to use as annotation processors
 	Plugins []string
 
+	// If true, run the annotation processors twice and fail the build if they generate different
+	// sources, and write a report of the generated files with the annotation processors named by
+	// their @Generated annotations.  Only the processors named by the processor_class of the
+	// plugins are checked, as those are the only ones javac runs; processors that are only listed
+	// in the META-INF/services of the plugins are not discovered.  Defaults to false.
+	Annotation_processor_check *bool
+
 	// List of modules to export to libraries that directly depend on this library as annotation
 	// processors.  Note that if the plugins set generates_api: true this will disable the turbine
 	// optimization on modules that depend on this module, which will reduce parallelism and cause
@@ -257,7 +264,7 @@ type DeviceProperties struct {
 	IsSDKLibrary bool `blueprint:"mutated"`
 
 	// If true, generate the signature file of APK Signing Scheme V4, along side the signed APK file.
-	// Defaults to false.
+	// Defaults to the product's PRODUCT_APK_V4_SIGNATURE setting, which defaults to false.
 	V4_signature *bool
 
 	// Only for libraries created by a sysprop_library module, SyspropPublicStub is the name of the
@@ -576,6 +583,11 @@ func (j *Module) OutputFiles(tag string) (android.Paths, error) {
 			return android.Paths{j.dexer.proguardDictionary.Path()}, nil
 		}
 		return nil, fmt.Errorf("%q was requested, but no output file was found.", tag)
+	case ".main_dex_list":
+		if j.dexer.mainDexList.Valid() {
+			return android.Paths{j.dexer.mainDexList.Path()}, nil
+		}
+		return nil, fmt.Errorf("%q was requested, but no output file was found.", tag)
 	default:
 		return nil, fmt.Errorf("unsupported module reference tag %q", tag)
 	}
@@ -1108,6 +1120,15 @@ func (j *Module) compile(ctx android.ModuleContext, aaptSrcJar android.Path) {
 		flags.kotlincClasspath = append(flags.kotlincClasspath, flags.bootClasspath...)
 		flags.kotlincClasspath = append(flags.kotlincClasspath, flags.classpath...)
 
+		// The kotlinc command line has no incremental mode, its incremental compiler is only
+		// available to build systems through the Kotlin daemon, so kotlinc and kapt still compile
+		// the whole module.  With SOONG_KOTLIN_CLASSPATH_SNAPSHOT=true they only do so when the
+		// classes on the classpath change, not whenever a dependency rewrites its jar.
+		if ctx.Config().IsEnvTrue("SOONG_KOTLIN_CLASSPATH_SNAPSHOT") {
+			flags.kotlincClasspathSnapshot = android.OptionalPathForPath(
+				kotlinClasspathSnapshot(ctx, flags.kotlincClasspath))
+		}
+
 		if len(flags.processorPath) > 0 {
 			// Use kapt for annotation processing
 			kaptSrcJar := android.PathForModuleOut(ctx, "kapt", "kapt-sources.jar")
@@ -1190,6 +1211,14 @@ func (j *Module) compile(ctx android.ModuleContext, aaptSrcJar android.Path) {
 			extraJarDeps = append(extraJarDeps, errorprone)
 		}
 
+		if Bool(j.properties.Annotation_processor_check) && len(flags.processors) > 0 {
+			// Make the javac rule depend on the check so that nondeterministic annotation
+			// processors fail the build.
+			annotationProcessorReport := android.PathForModuleOut(ctx, "annotation_processors", "report.txt")
+			checkAnnotationProcessors(ctx, annotationProcessorReport, uniqueSrcFiles, srcJars, flags)
+			extraJarDeps = append(extraJarDeps, annotationProcessorReport)
+		}
+
 		if enableSharding {
 			if headerJarFileWithoutDepsOrJarjar != nil {
 				flags.classpath = append(classpath{headerJarFileWithoutDepsOrJarjar}, flags.classpath...)
@@ -1463,6 +1492,9 @@ func (j *Module) compile(ctx android.ModuleContext, aaptSrcJar android.Path) {
 			// Encode hidden API flags in dex file, if needed.
 			dexOutputFile = j.hiddenAPIEncodeDex(ctx, dexOutputFile)
 
+			// Lay out the dex files using the profile, if needed.
+			dexOutputFile = j.dexlayout(ctx, dexOutputFile)
+
 			j.dexJarFile = makeDexJarPathFromPath(dexOutputFile)
 
 			// Dexpreopting
@@ -129,6 +129,34 @@ var (
 		},
 		"abis", "allow-prereleased", "screen-densities", "sdk-version", "stem", "apkcerts", "partition", "zip")
 
+	// Runs the annotation processors together twice and fails if they generate different sources,
+	// then reports the generated files with the processor named by their @Generated annotation.
+	annotationProcessorCheck = pctx.AndroidStaticRule("annotationProcessorCheck",
+		blueprint.RuleParams{
+			Command: `rm -rf "$outDir" "$srcJarDir" "$out" && mkdir -p "$srcJarDir" && ` +
+				`${config.ZipSyncCmd} -d $srcJarDir -l $srcJarDir/list -f "*.java" $srcJars && ` +
+				`for run in run1 run2; do ` +
+				`mkdir -p "$outDir/$$run/classes" "$outDir/$$run/anno" && ` +
+				`${config.JavacCmd} ${config.JavacHeapFlags} ${config.JavacVmFlags} ${config.CommonJdkFlags} ` +
+				`$processorpath $processor -proc:only $javacFlags $bootClasspath $classpath ` +
+				`-source $javaVersion -target $javaVersion ` +
+				`-d "$outDir/$$run/classes" -s "$outDir/$$run/anno" @$out.rsp @$srcJarDir/list || exit 1; done && ` +
+				`if ! diff -r "$outDir/run1/anno" "$outDir/run2/anno"; then ` +
+				`echo "error: annotation processors generated different sources when run twice" >&2; exit 1; fi && ` +
+				`(cd "$outDir/run1/anno" && find . -type f | sort | while read f; do ` +
+				`p=$$(grep -o -m1 'Generated([^"]*"[^"]*"' "$$f" | sed 's/.*"\([^"]*\)"$$/\1/'); ` +
+				`echo "$${p:-unknown}: $${f#./}"; done) > $out && ` +
+				`rm -rf "$srcJarDir"`,
+			CommandDeps: []string{
+				"${config.JavacCmd}",
+				"${config.ZipSyncCmd}",
+			},
+			Rspfile:        "$out.rsp",
+			RspfileContent: "$in",
+		},
+		"javacFlags", "bootClasspath", "classpath", "processorpath", "processor", "srcJars",
+		"srcJarDir", "outDir", "javaVersion")
+
 	turbine, turbineRE = pctx.RemoteStaticRules("turbine",
 		blueprint.RuleParams{
 			Command: `$reTemplate${config.JavaCmd} ${config.JavaVmFlags} -jar ${config.TurbineJar} --output $out.tmp ` +
@@ -278,6 +306,10 @@ type javaBuilderFlags struct {
 	kotlincClasspath classpath
 	kotlincDeps      android.Paths
 
+	// Snapshot of the contents of kotlincClasspath, if kotlinc and kapt should only rerun when
+	// the classes on the classpath change instead of whenever the jars are rewritten.
+	kotlincClasspathSnapshot android.OptionalPath
+
 	proto android.ProtoFlags
 }
 
@@ -481,6 +513,60 @@ func transformJavaToClasses(ctx android.ModuleContext, outputFile android.Writab
 	})
 }
 
+// checkAnnotationProcessors generates a rule that runs the annotation processors together on the
+// given sources twice, fails if they generate different sources, and writes a report of the
+// generated files to outputFile, attributed to the annotation processors named by their @Generated
+// annotations.  The processors are not run on their own, as they may depend on each other.  Like
+// the compile, only the processors in flags.processors are run, so processors that are only
+// registered in the META-INF/services of the processor path are not checked.
+func checkAnnotationProcessors(ctx android.ModuleContext, outputFile android.WritablePath,
+	srcFiles, srcJars android.Paths, flags javaBuilderFlags) {
+
+	var deps android.Paths
+	deps = append(deps, srcJars...)
+
+	classpath := flags.classpath
+
+	var bootClasspath string
+	if flags.javaVersion.usesJavaModules() {
+		var systemModuleDeps android.Paths
+		bootClasspath, systemModuleDeps = flags.systemModules.FormJavaSystemModulesPath(ctx.Device())
+		deps = append(deps, systemModuleDeps...)
+		classpath = append(flags.java9Classpath, classpath...)
+	} else {
+		deps = append(deps, flags.bootClasspath...)
+		if len(flags.bootClasspath) == 0 && ctx.Device() {
+			// explicitly specify -bootclasspath "" if the bootclasspath is empty to
+			// ensure java does not fall back to the default bootclasspath.
+			bootClasspath = `-bootclasspath ""`
+		} else {
+			bootClasspath = flags.bootClasspath.FormJavaClassPath("-bootclasspath")
+		}
+	}
+
+	deps = append(deps, classpath...)
+	deps = append(deps, flags.processorPath...)
+
+	ctx.Build(pctx, android.BuildParams{
+		Rule:        annotationProcessorCheck,
+		Description: "check annotation processors",
+		Output:      outputFile,
+		Inputs:      srcFiles,
+		Implicits:   deps,
+		Args: map[string]string{
+			"javacFlags":    flags.javacFlags,
+			"bootClasspath": bootClasspath,
+			"classpath":     classpath.FormJavaClassPath("-classpath"),
+			"processorpath": flags.processorPath.FormJavaClassPath("-processorpath"),
+			"processor":     "-processor " + strings.Join(flags.processors, ","),
+			"srcJars":       strings.Join(srcJars.Strings(), " "),
+			"srcJarDir":     android.PathForModuleOut(ctx, "annotation_processors", "srcjars").String(),
+			"outDir":        android.PathForModuleOut(ctx, "annotation_processors", "runs").String(),
+			"javaVersion":   flags.javaVersion.String(),
+		},
+	})
+}
+
 func TransformResourcesToJar(ctx android.ModuleContext, outputFile android.WritablePath,
 	jarArgs []string, deps android.Paths) {
 
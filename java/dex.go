@@ -35,6 +35,21 @@ type DexProperties struct {
 	// A list of files containing rules that specify the classes to keep in the main dex file.
 	Main_dex_rules []string `android:"path"`
 
+	Main_dex struct {
+		// If true and min_sdk_version is below 21, where only the classes in the main dex file
+		// can be loaded until the app installs its secondary dex files, keep the default entry
+		// points of apps and main_dex.entry_points in the main dex file and generate the list of
+		// its classes, which can be referenced with the ":<module>{.main_dex_list}" syntax.
+		// Defaults to true for android_app, android_test and android_test_helper_app modules.
+		Legacy_multidex *bool
+		// True if the module containing this has it set by default.
+		LegacyMultidexByDefault bool `blueprint:"mutated"`
+
+		// Names of classes to keep in the main dex file with all of their members, for example
+		// the classes that run before the app installs its secondary dex files.
+		Entry_points []string
+	}
+
 	Optimize struct {
 		// If false, disable all optimization.  Defaults to true for android_app and android_test
 		// modules, false for java_library and java_test modules.
@@ -84,12 +99,48 @@ type dexer struct {
 	extraProguardFlagFiles android.Paths
 	proguardDictionary     android.OptionalPath
 	proguardUsageZip       android.OptionalPath
+	mainDexList            android.OptionalPath
 }
 
 func (d *dexer) effectiveOptimizeEnabled() bool {
 	return BoolDefault(d.dexProperties.Optimize.Enabled, d.dexProperties.Optimize.EnabledByDefault)
 }
 
+// The rules that keep the entry points of apps that may run before the secondary dex files are
+// installed in the main dex file, matching the mainDexClasses.rules of dx.
+var legacyMultidexMainDexRules = []string{
+	"-keep public class * extends android.app.Instrumentation { <init>(); }",
+	"-keep public class * extends android.app.Application { <init>(); void attachBaseContext(android.content.Context); }",
+	"-keep public class * extends android.app.backup.BackupAgent { <init>(); }",
+	"-keep public class * implements java.lang.annotation.Annotation { *; }",
+	"-keep public class * extends android.test.InstrumentationTestCase { <init>(); }",
+}
+
+// legacyMultidex returns true if the main dex list should be generated for legacy multidex.
+func (d *dexer) legacyMultidex(ctx android.ModuleContext, minSdkVersion android.SdkSpec) bool {
+	if !BoolDefault(d.dexProperties.Main_dex.Legacy_multidex, d.dexProperties.Main_dex.LegacyMultidexByDefault) {
+		return false
+	}
+	// An invalid min_sdk_version is reported by dexCommonFlags.
+	effectiveVersion, err := minSdkVersion.EffectiveVersion(ctx)
+	return err == nil && effectiveVersion.LessThan(android.FirstNativeMultidexVersion)
+}
+
+// legacyMultidexFlags writes the rules of the default entry points and main_dex.entry_points, and
+// returns the flags that pass them to R8 or D8 and generate the main dex list.
+func (d *dexer) legacyMultidexFlags(ctx android.ModuleContext, mainDexList android.WritablePath) (flags []string, deps android.Paths) {
+	rules := append([]string(nil), legacyMultidexMainDexRules...)
+	for _, entryPoint := range d.dexProperties.Main_dex.Entry_points {
+		rules = append(rules, "-keep class "+entryPoint+" { *; }")
+	}
+	rulesFile := android.PathForModuleOut(ctx, "main_dex", "main_dex.rules")
+	android.WriteFileRule(ctx, rulesFile, strings.Join(rules, "\n"))
+
+	flags = append(flags, "--main-dex-rules", rulesFile.String(),
+		"--main-dex-list-output", mainDexList.String())
+	return flags, android.Paths{rulesFile}
+}
+
 var d8, d8RE = pctx.MultiCommandRemoteStaticRules("d8",
 	blueprint.RuleParams{
 		Command: `rm -rf "$outDir" && mkdir -p "$outDir" && ` +
@@ -313,6 +364,16 @@ func (d *dexer) compileDex(ctx android.ModuleContext, flags javaBuilderFlags, mi
 
 	commonFlags, commonDeps := d.dexCommonFlags(ctx, minSdkVersion)
 
+	var implicitOutputs android.WritablePaths
+	if d.legacyMultidex(ctx, minSdkVersion) {
+		mainDexList := android.PathForModuleOut(ctx, "dex", "main_dex_list.txt")
+		mainDexFlags, mainDexDeps := d.legacyMultidexFlags(ctx, mainDexList)
+		commonFlags = append(commonFlags, mainDexFlags...)
+		commonDeps = append(commonDeps, mainDexDeps...)
+		implicitOutputs = append(implicitOutputs, mainDexList)
+		d.mainDexList = android.OptionalPathForPath(mainDexList)
+	}
+
 	// Exclude kotlinc generated files when "exclude_kotlinc_generated_files" is set to true.
 	mergeZipsFlags := ""
 	if proptools.BoolDefault(d.dexProperties.Exclude_kotlinc_generated_files, false) {
@@ -342,7 +403,8 @@ func (d *dexer) compileDex(ctx android.ModuleContext, flags javaBuilderFlags, mi
 			"tmpJar":         tmpJar.String(),
 			"mergeZipsFlags": mergeZipsFlags,
 		}
-		if ctx.Config().UseRBE() && ctx.Config().IsEnvTrue("RBE_R8") {
+		// The remote rule doesn't download the main dex list.
+		if ctx.Config().UseRBE() && ctx.Config().IsEnvTrue("RBE_R8") && !d.mainDexList.Valid() {
 			rule = r8RE
 			args["implicits"] = strings.Join(r8Deps.Strings(), ",")
 		}
@@ -350,7 +412,7 @@ func (d *dexer) compileDex(ctx android.ModuleContext, flags javaBuilderFlags, mi
 			Rule:            rule,
 			Description:     "r8",
 			Output:          javalibJar,
-			ImplicitOutputs: android.WritablePaths{proguardDictionary, proguardUsageZip},
+			ImplicitOutputs: append(android.WritablePaths{proguardDictionary, proguardUsageZip}, implicitOutputs...),
 			Input:           classesJar,
 			Implicits:       r8Deps,
 			Args:            args,
@@ -359,15 +421,16 @@ func (d *dexer) compileDex(ctx android.ModuleContext, flags javaBuilderFlags, mi
 		d8Flags, d8Deps := d8Flags(flags)
 		d8Deps = append(d8Deps, commonDeps...)
 		rule := d8
-		if ctx.Config().UseRBE() && ctx.Config().IsEnvTrue("RBE_D8") {
+		if ctx.Config().UseRBE() && ctx.Config().IsEnvTrue("RBE_D8") && !d.mainDexList.Valid() {
 			rule = d8RE
 		}
 		ctx.Build(pctx, android.BuildParams{
-			Rule:        rule,
-			Description: "d8",
-			Output:      javalibJar,
-			Input:       classesJar,
-			Implicits:   d8Deps,
+			Rule:            rule,
+			Description:     "d8",
+			Output:          javalibJar,
+			ImplicitOutputs: implicitOutputs,
+			Input:           classesJar,
+			Implicits:       d8Deps,
 			Args: map[string]string{
 				"d8Flags":        strings.Join(append(commonFlags, d8Flags...), " "),
 				"zipFlags":       zipFlags,
@@ -101,3 +101,63 @@ func TestD8(t *testing.T) {
 	android.AssertStringDoesNotContain(t, "expected no  static_lib header jar in foo javac classpath",
 		fooD8.Args["d8Flags"], staticLibHeader.String())
 }
+
+func TestLegacyMultidexMainDexList(t *testing.T) {
+	result := PrepareForTestWithJavaDefaultModulesWithoutFakeDex2oatd.RunTestWithBp(t, `
+		android_app {
+			name: "legacy_app",
+			srcs: ["foo.java"],
+			sdk_version: "current",
+			min_sdk_version: "19",
+			main_dex: {
+				entry_points: ["com.android.foo.EarlyInit"],
+			},
+		}
+
+		android_app {
+			name: "app",
+			srcs: ["foo.java"],
+			sdk_version: "current",
+			min_sdk_version: "21",
+		}
+
+		java_library {
+			name: "legacy_lib",
+			srcs: ["foo.java"],
+			sdk_version: "current",
+			min_sdk_version: "19",
+			installable: true,
+			main_dex: {
+				legacy_multidex: true,
+			},
+		}
+	`)
+
+	legacyApp := result.ModuleForTests("legacy_app", "android_common")
+	rules := android.ContentFromFileRuleForTests(t, legacyApp.Output("main_dex/main_dex.rules"))
+	android.AssertStringDoesContain(t, "default main dex rules", rules,
+		"-keep public class * extends android.app.Application { <init>(); void attachBaseContext(android.content.Context); }")
+	android.AssertStringDoesContain(t, "entry point main dex rules", rules,
+		"-keep class com.android.foo.EarlyInit { *; }")
+
+	r8 := legacyApp.Rule("r8")
+	android.AssertStringDoesContain(t, "r8 main dex flags", r8.Args["r8Flags"],
+		"--main-dex-rules out/soong/.intermediates/legacy_app/android_common/main_dex/main_dex.rules "+
+			"--main-dex-list-output out/soong/.intermediates/legacy_app/android_common/dex/main_dex_list.txt")
+	android.AssertStringListContains(t, "r8 main dex list output",
+		android.PathsRelativeToTop(r8.ImplicitOutputs.Paths()),
+		"out/soong/.intermediates/legacy_app/android_common/dex/main_dex_list.txt")
+
+	outputFiles, err := legacyApp.Module().(android.OutputFileProducer).OutputFiles(".main_dex_list")
+	android.FailIfErrored(t, []error{err})
+	android.AssertPathsRelativeToTopEquals(t, "main dex list",
+		[]string{"out/soong/.intermediates/legacy_app/android_common/dex/main_dex_list.txt"}, outputFiles)
+
+	app := result.ModuleForTests("app", "android_common")
+	android.AssertStringDoesNotContain(t, "r8 main dex flags", app.Rule("r8").Args["r8Flags"],
+		"--main-dex-list-output")
+
+	legacyLib := result.ModuleForTests("legacy_lib", "android_common")
+	android.AssertStringDoesContain(t, "d8 main dex flags", legacyLib.Rule("d8").Args["d8Flags"],
+		"--main-dex-list-output out/soong/.intermediates/legacy_lib/android_common/dex/main_dex_list.txt")
+}
@@ -25,8 +25,10 @@ import (
 type DexpreopterInterface interface {
 	IsInstallable() bool // Structs that embed dexpreopter must implement this.
 	dexpreoptDisabled(ctx android.BaseModuleContext) bool
+	verificationMetadataEnabled(ctx android.BaseModuleContext) bool
 	DexpreoptBuiltInstalledForApex() []dexpreopterInstall
 	AndroidMkEntriesForApex() []android.AndroidMkEntries
+	appBootImageProfileInput() (android.Path, android.WritablePath)
 }
 
 type dexpreopterInstall struct {
@@ -103,6 +105,18 @@ type dexpreopter struct {
 	// - Dexpreopt post-processing (using dexpreopt artifacts from a prebuilt system image to incrementally
 	//   dexpreopt another partition).
 	configPath android.WritablePath
+
+	// The text profile of an app preinstalled on the system partition. Its boot classpath entries
+	// are an input to the boot image profile candidate generated from app profiles.
+	appProfile android.Path
+
+	// Where the boot classpath entries of appProfile are written for the boot image profile
+	// candidate.
+	appBootProfile android.WritablePath
+
+	// The .dm and .vdex files of an app that isn't dexpreopted, generated with the verify compiler
+	// filter when dex_preopt.verification_metadata is set. See verification_metadata.go.
+	verificationMetadata android.Paths
 }
 
 type DexpreoptProperties struct {
@@ -122,6 +136,17 @@ type DexpreoptProperties struct {
 		// defaults to searching for a file that matches the name of this module in the default
 		// profile location set by PRODUCT_DEX_PREOPT_PROFILE_DIR, or empty if not found.
 		Profile *string `android:"path"`
+
+		// If true, lay out the dex files of the app with dexlayout using the profile before the
+		// app is packaged and signed, to improve its cold startup.  Requires a profile.  Defaults
+		// to false.
+		Dexlayout *bool
+
+		// If true and the app isn't dexpreopted, for example because it is updatable and
+		// dex_preopt.enabled is false, still verify its dex files with dex2oat and dist the
+		// resulting .dm and .vdex files, to be delivered along with the app instead of being
+		// installed.  Defaults to false.
+		Verification_metadata *bool
 	}
 }
 
@@ -178,6 +203,10 @@ func (d *dexpreopter) dexpreoptDisabled(ctx android.BaseModuleContext) bool {
 		return true
 	}
 
+	if inList(dexpreoptPartition(ctx), global.DisablePreoptPartitions) {
+		return true
+	}
+
 	isApexSystemServerJar := global.AllApexSystemServerJars(ctx).ContainsJar(moduleName(ctx))
 	if isApexVariant(ctx) {
 		// Don't preopt APEX variant module unless the module is an APEX system server jar and we are
@@ -197,8 +226,27 @@ func (d *dexpreopter) dexpreoptDisabled(ctx android.BaseModuleContext) bool {
 	return false
 }
 
+// dexpreoptPartition returns the partition the module is installed on for the purpose of
+// DisablePreoptPartitions, regardless of whether the partition is installed as a subdirectory
+// of the system partition.
+func dexpreoptPartition(ctx android.BaseModuleContext) string {
+	switch {
+	case ctx.SocSpecific():
+		return "vendor"
+	case ctx.DeviceSpecific():
+		return "odm"
+	case ctx.ProductSpecific():
+		return "product"
+	case ctx.SystemExtSpecific():
+		return "system_ext"
+	default:
+		return "system"
+	}
+}
+
 func dexpreoptToolDepsMutator(ctx android.BottomUpMutatorContext) {
-	if d, ok := ctx.Module().(DexpreopterInterface); !ok || d.dexpreoptDisabled(ctx) {
+	d, ok := ctx.Module().(DexpreopterInterface)
+	if !ok || (d.dexpreoptDisabled(ctx) && !d.verificationMetadataEnabled(ctx)) {
 		return
 	}
 	dexpreopt.RegisterToolDeps(ctx)
@@ -258,10 +306,7 @@ func (d *dexpreopter) dexpreopt(ctx android.ModuleContext, dexJarFile android.Wr
 
 	isSystemServerJar := global.AllSystemServerJars(ctx).ContainsJar(moduleName(ctx))
 
-	bootImage := defaultBootImageConfig(ctx)
-	if global.UseArtImage {
-		bootImage = artBootImageConfig(ctx)
-	}
+	bootImage := dexpreoptBootImageConfig(ctx)
 
 	dexFiles, dexLocations := bcpForDexpreopt(ctx, global.PreoptWithUpdatableBcp)
 
@@ -283,31 +328,20 @@ func (d *dexpreopter) dexpreopt(ctx android.ModuleContext, dexJarFile android.Wr
 	var images android.Paths
 	var imagesDeps []android.OutputPaths
 	for _, target := range targets {
-		archs = append(archs, target.Arch.ArchType)
 		variant := bootImage.getVariant(target)
+		if variant == nil {
+			// The product doesn't build the boot image for this architecture, so the module can't
+			// be dexpreopted for it.
+			continue
+		}
+		archs = append(archs, target.Arch.ArchType)
 		images = append(images, variant.imagePathOnHost)
 		imagesDeps = append(imagesDeps, variant.imagesDeps)
 	}
 	// The image locations for all Android variants are identical.
 	hostImageLocations, deviceImageLocations := bootImage.getAnyAndroidVariant().imageLocations()
 
-	var profileClassListing android.OptionalPath
-	var profileBootListing android.OptionalPath
-	profileIsTextListing := false
-	if BoolDefault(d.dexpreoptProperties.Dex_preopt.Profile_guided, true) {
-		// If dex_preopt.profile_guided is not set, default it based on the existence of the
-		// dexprepot.profile option or the profile class listing.
-		if String(d.dexpreoptProperties.Dex_preopt.Profile) != "" {
-			profileClassListing = android.OptionalPathForPath(
-				android.PathForModuleSrc(ctx, String(d.dexpreoptProperties.Dex_preopt.Profile)))
-			profileBootListing = android.ExistentPathForSource(ctx,
-				ctx.ModuleDir(), String(d.dexpreoptProperties.Dex_preopt.Profile)+"-boot")
-			profileIsTextListing = true
-		} else if global.ProfileDir != "" {
-			profileClassListing = android.ExistentPathForSource(ctx,
-				global.ProfileDir, moduleName(ctx)+".prof")
-		}
-	}
+	profileClassListing, profileBootListing, profileIsTextListing := d.profile(ctx, global)
 
 	// Full dexpreopt config, used to create dexpreopt build rules.
 	dexpreoptConfig := &dexpreopt.ModuleConfig{
@@ -349,6 +383,9 @@ func (d *dexpreopter) dexpreopt(ctx android.ModuleContext, dexJarFile android.Wr
 	dexpreopt.WriteModuleConfig(ctx, dexpreoptConfig, d.configPath)
 
 	if d.dexpreoptDisabled(ctx) {
+		if d.verificationMetadataEnabled(ctx) {
+			d.generateVerificationMetadata(ctx, global, dexpreoptConfig)
+		}
 		return
 	}
 
@@ -393,6 +430,116 @@ func (d *dexpreopter) dexpreopt(ctx android.ModuleContext, dexJarFile android.Wr
 	if !isApexSystemServerJar {
 		d.builtInstalled = dexpreoptRule.Installs().String()
 	}
+
+	if d.isApp && profileClassListing.Valid() && !global.DisableGenerateProfile &&
+		ctx.Module().(android.Module).PartitionTag(ctx.DeviceConfig()) == "system" {
+		d.appProfile = profileClassListing.Path()
+		d.appBootProfile = android.PathForModuleOut(ctx, "dexpreopt", "app-boot-image-profile.prof")
+	}
+}
+
+// profile returns the class listing and the boot listing of the profile of the module, and whether
+// they are text listings rather than binary profiles.
+func (d *dexpreopter) profile(ctx android.ModuleContext, global *dexpreopt.GlobalConfig) (
+	classListing, bootListing android.OptionalPath, isTextListing bool) {
+
+	if BoolDefault(d.dexpreoptProperties.Dex_preopt.Profile_guided, true) {
+		// If dex_preopt.profile_guided is not set, default it based on the existence of the
+		// dexprepot.profile option or the profile class listing.
+		if String(d.dexpreoptProperties.Dex_preopt.Profile) != "" {
+			classListing = android.OptionalPathForPath(
+				android.PathForModuleSrc(ctx, String(d.dexpreoptProperties.Dex_preopt.Profile)))
+			bootListing = android.ExistentPathForSource(ctx,
+				ctx.ModuleDir(), String(d.dexpreoptProperties.Dex_preopt.Profile)+"-boot")
+			isTextListing = true
+		} else if global.ProfileDir != "" {
+			classListing = android.ExistentPathForSource(ctx,
+				global.ProfileDir, moduleName(ctx)+".prof")
+		}
+	}
+	return classListing, bootListing, isTextListing
+}
+
+// dexlayout lays out the dex files in the dex jar of an app with dexlayout using the profile of
+// the app when dex_preopt.dexlayout is set, and returns the dex jar with the laid out dex files.
+// Otherwise it returns the dex jar unchanged.
+func (d *dexpreopter) dexlayout(ctx android.ModuleContext, dexJarFile android.OutputPath) android.OutputPath {
+	if !Bool(d.dexpreoptProperties.Dex_preopt.Dexlayout) {
+		return dexJarFile
+	}
+	if !d.isApp {
+		ctx.PropertyErrorf("dex_preopt.dexlayout", "is only supported by apps")
+		return dexJarFile
+	}
+
+	profile, _, isTextListing := d.profile(ctx, dexpreopt.GetGlobalConfig(ctx))
+	if !profile.Valid() {
+		ctx.PropertyErrorf("dex_preopt.dexlayout", "requires a profile, set dex_preopt.profile")
+		return dexJarFile
+	}
+
+	binaryProfile := android.PathForModuleOut(ctx, "dexlayout", "profile.prof")
+	inDir := android.PathForModuleOut(ctx, "dexlayout", "in")
+	outDir := android.PathForModuleOut(ctx, "dexlayout", "out")
+	laidOutDex := android.PathForModuleOut(ctx, "dexlayout", "dex.zip")
+	output := android.PathForModuleOut(ctx, "dexlayout", dexJarFile.Base()).OutputPath
+
+	rule := android.NewRuleBuilder(pctx, ctx)
+
+	// The profile has to be keyed by the dex location of the app for dexlayout to match it
+	// with the dex files.
+	cmd := rule.Command().
+		Text(`ANDROID_LOG_TAGS="*:e"`).
+		BuiltTool("profman")
+	if isTextListing {
+		cmd.FlagWithInput("--create-profile-from=", profile.Path())
+	} else {
+		cmd.Flag("--copy-and-update-profile-key").
+			FlagWithInput("--profile-file=", profile.Path())
+	}
+	cmd.Flag("--output-profile-type=app").
+		FlagWithInput("--apk=", dexJarFile).
+		Flag("--dex-location="+android.InstallPathToOnDevicePath(ctx, d.installPath)).
+		FlagWithOutput("--reference-profile-file=", binaryProfile)
+
+	rule.Command().Text("rm -rf").Text(inDir.String()).Text(outDir.String())
+	rule.Command().Text("mkdir -p").Text(inDir.String()).Text(outDir.String())
+	rule.Command().Text("unzip -qoDD -d").Text(inDir.String()).Input(dexJarFile).Text("'classes*.dex'")
+	rule.Command().
+		BuiltTool("dexlayout").
+		FlagWithInput("-p ", binaryProfile).
+		FlagWithArg("-w ", outDir.String()).
+		Text(inDir.String() + "/classes*.dex")
+
+	zipCmd := rule.Command().
+		BuiltTool("soong_zip").
+		FlagWithOutput("-o ", laidOutDex).
+		FlagWithArg("-C ", outDir.String()).
+		FlagWithArg("-D ", outDir.String())
+	if d.uncompressedDex {
+		zipCmd.Flag("-L 0")
+	}
+
+	// Take the laid out dex files from the first zip, and everything else from the dex jar.
+	rule.Command().
+		BuiltTool("merge_zips").
+		Flag("-ignore-duplicates").
+		Output(output).
+		Input(laidOutDex).
+		Input(dexJarFile)
+
+	rule.Build("dexlayout", "dexlayout")
+
+	if d.uncompressedDex {
+		alignedOutput := android.PathForModuleOut(ctx, "dexlayout-aligned", dexJarFile.Base()).OutputPath
+		TransformZipAlign(ctx, alignedOutput, output)
+		return alignedOutput
+	}
+	return output
+}
+
+func (d *dexpreopter) appBootImageProfileInput() (android.Path, android.WritablePath) {
+	return d.appProfile, d.appBootProfile
 }
 
 func (d *dexpreopter) DexpreoptBuiltInstalledForApex() []dexpreopterInstall {
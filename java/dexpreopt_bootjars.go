@@ -307,6 +307,9 @@ type bootImageVariant struct {
 	// All the files that constitute this image variant, i.e. .art, .oat and .vdex files.
 	imagesDeps android.OutputPaths
 
+	// The compiler filter to pass to dex2oat, or empty to use the default one.
+	compilerFilter string
+
 	// The path to the primary image variant's imagePathOnHost field, where primary image variant
 	// means the image variant that this extends.
 	//
@@ -465,6 +468,12 @@ type dexpreoptBootJars struct {
 	// Build path to a config file that Soong writes for Make (to be used in makefiles that install
 	// the default boot image).
 	dexpreoptConfigForMake android.WritablePath
+
+	// Build paths to the boot image profile candidate generated from the profiles of the apps
+	// preinstalled on the system partition, and to the report of its differences with the
+	// checked-in boot image profiles.
+	appBootImageProfile       android.WritablePath
+	appBootImageProfileReport android.WritablePath
 }
 
 // Provide paths to boot images for use by modules that depend upon them.
@@ -496,6 +505,14 @@ func (d *dexpreoptBootJars) GenerateSingletonBuildActions(ctx android.SingletonC
 	d.defaultBootImage = defaultImageConfig
 	artBootImageConfig := artBootImageConfig(ctx)
 	d.otherImages = []*bootImageConfig{artBootImageConfig}
+	if global.UseApexImage {
+		d.otherImages = append(d.otherImages, jitzygoteBootImageConfig(ctx))
+	}
+
+	d.appBootImageProfile, d.appBootImageProfileReport = appBootImageProfileRule(ctx, defaultImageConfig)
+	if d.appBootImageProfile != nil {
+		ctx.Phony("app-boot-image-profile", d.appBootImageProfile, d.appBootImageProfileReport)
+	}
 }
 
 // shouldBuildBootImages determines whether boot images should be built.
@@ -720,6 +737,10 @@ func buildBootImageVariant(ctx android.ModuleContext, image *bootImageVariant, p
 		cmd.FlagWithArg("--instruction-set-features=", global.InstructionSetFeatures[arch])
 	}
 
+	if image.compilerFilter != "" {
+		cmd.FlagWithArg("--compiler-filter=", image.compilerFilter)
+	}
+
 	if global.BootFlags != "" {
 		cmd.Flag(global.BootFlags)
 	}
@@ -790,19 +811,17 @@ func bootImageProfileRule(ctx android.ModuleContext, image *bootImageConfig) and
 		return nil
 	}
 
-	defaultProfile := "frameworks/base/config/boot-image-profile.txt"
-
 	rule := android.NewRuleBuilder(pctx, ctx)
 
+	checkedInProfiles := checkedInBootImageProfiles(ctx)
+
 	var bootImageProfile android.Path
-	if len(global.BootImageProfiles) > 1 {
+	if len(checkedInProfiles) > 1 {
 		combinedBootImageProfile := image.dir.Join(ctx, "boot-image-profile.txt")
-		rule.Command().Text("cat").Inputs(global.BootImageProfiles).Text(">").Output(combinedBootImageProfile)
+		rule.Command().Text("cat").Inputs(checkedInProfiles).Text(">").Output(combinedBootImageProfile)
 		bootImageProfile = combinedBootImageProfile
-	} else if len(global.BootImageProfiles) == 1 {
-		bootImageProfile = global.BootImageProfiles[0]
-	} else if path := android.ExistentPathForSource(ctx, defaultProfile); path.Valid() {
-		bootImageProfile = path.Path()
+	} else if len(checkedInProfiles) == 1 {
+		bootImageProfile = checkedInProfiles[0]
 	} else {
 		// No profile (not even a default one, which is the case on some branches
 		// like master-art-host that don't have frameworks/base).
@@ -827,13 +846,95 @@ func bootImageProfileRule(ctx android.ModuleContext, image *bootImageConfig) and
 		image.profileLicenseMetadataFile = android.OptionalPathForPath(ctx.LicenseMetadataFile())
 	}
 
-	rule.Build("bootJarsProfile", "profile boot jars")
+	// The rule is named after the image, as the JIT-zygote boot image is profiled by the same module
+	// as the framework boot image.
+	rule.Build(image.name+"JarsProfile", "profile "+image.name+" jars")
 
 	image.profilePathOnHost = profile
 
 	return profile
 }
 
+// checkedInBootImageProfiles returns the text boot image profiles checked into the source tree,
+// either the ones set in the global config or the default one if it exists.
+func checkedInBootImageProfiles(ctx android.PathContext) android.Paths {
+	global := dexpreopt.GetGlobalConfig(ctx)
+	if len(global.BootImageProfiles) > 0 {
+		return global.BootImageProfiles
+	}
+
+	defaultProfile := "frameworks/base/config/boot-image-profile.txt"
+	if path := android.ExistentPathForSource(ctx, defaultProfile); path.Valid() {
+		return android.Paths{path.Path()}
+	}
+	return nil
+}
+
+// appBootImageProfileRule generates the rules to create a boot image profile candidate from the
+// profiles of the apps preinstalled on the system partition, and to report its differences with
+// the checked-in boot image profiles. Only the boot classpath entries of the app profiles are
+// used, the entries for the apps' own classes are dropped. It returns the paths to the candidate
+// and to the report, or nil if no app has a profile.
+func appBootImageProfileRule(ctx android.SingletonContext, image *bootImageConfig) (android.WritablePath, android.WritablePath) {
+	globalSoong := dexpreopt.GetGlobalSoongConfig(ctx)
+	global := dexpreopt.GetGlobalConfig(ctx)
+
+	if global.DisableGenerateProfile || ctx.Config().UnbundledBuild() {
+		return nil, nil
+	}
+
+	rule := android.NewRuleBuilder(pctx, ctx)
+
+	// Profman keeps only the entries of the text profile for classes in the dex files it is given,
+	// so creating the profiles against the boot jars restricts them to the boot classpath.
+	var appProfiles android.Paths
+	ctx.VisitAllModules(func(module android.Module) {
+		if d, ok := module.(DexpreopterInterface); ok && module.Enabled() {
+			if profile, bootProfile := d.appBootImageProfileInput(); profile != nil {
+				rule.Command().
+					Text(`ANDROID_LOG_TAGS="*:e"`).
+					Tool(globalSoong.Profman).
+					FlagWithInput("--create-profile-from=", profile).
+					FlagForEachInput("--apk=", image.dexPathsDeps.Paths()).
+					FlagForEachArg("--dex-location=", image.getAnyAndroidVariant().dexLocationsDeps).
+					FlagWithOutput("--reference-profile-file=", bootProfile)
+				rule.Temporary(bootProfile)
+				appProfiles = append(appProfiles, bootProfile)
+			}
+		}
+	})
+	if len(appProfiles) == 0 {
+		return nil, nil
+	}
+
+	candidate := image.dir.Join(ctx, "app-boot-image-profile.txt")
+	report := image.dir.Join(ctx, "app-boot-image-profile.diff")
+
+	rule.Command().
+		Text(`ANDROID_LOG_TAGS="*:e"`).
+		Tool(globalSoong.Profman).
+		Flag("--generate-boot-image-profile").
+		FlagForEachInput("--profile-file=", appProfiles).
+		FlagForEachInput("--apk=", image.dexPathsDeps.Paths()).
+		FlagForEachArg("--dex-location=", image.getAnyAndroidVariant().dexLocationsDeps).
+		FlagWithOutput("--out-profile-path=", candidate)
+
+	// The report is informational, differences with the checked-in profiles don't fail the build.
+	// /dev/null keeps sort from reading its stdin when there are no checked-in profiles.
+	sortedCheckedIn := image.dir.Join(ctx, "app-boot-image-profile.checked-in.sorted.txt")
+	sortedCandidate := image.dir.Join(ctx, "app-boot-image-profile.sorted.txt")
+	rule.Command().Text("sort -u").Inputs(checkedInBootImageProfiles(ctx)).Text("/dev/null >").Output(sortedCheckedIn)
+	rule.Command().Text("sort -u").Input(candidate).Text(">").Output(sortedCandidate)
+	rule.Command().Text("(diff -u").Input(sortedCheckedIn).Input(sortedCandidate).Text("|| true) >").Output(report)
+	rule.Temporary(sortedCheckedIn)
+	rule.Temporary(sortedCandidate)
+	rule.DeleteTemporaryFiles()
+
+	rule.Build("appBootImageProfile", "boot image profile from app profiles")
+
+	return candidate, report
+}
+
 // bootFrameworkProfileRule generates the rule to create the boot framework profile and
 // returns a path to the generated file.
 func bootFrameworkProfileRule(ctx android.ModuleContext, image *bootImageConfig) android.WritablePath {
@@ -925,6 +1026,10 @@ func (d *dexpreoptBootJars) MakeVars(ctx android.MakeVarsContext) {
 		ctx.Strict("DEX_PREOPT_SOONG_CONFIG_FOR_MAKE", android.PathForOutput(ctx, "dexpreopt_soong.config").String())
 	}
 
+	if d.appBootImageProfile != nil {
+		ctx.DistForGoal("app-boot-image-profile", d.appBootImageProfile, d.appBootImageProfileReport)
+	}
+
 	image := d.defaultBootImage
 	if image != nil {
 		ctx.Strict("DEXPREOPT_IMAGE_PROFILE_BUILT_INSTALLED", image.profileInstalls.String())
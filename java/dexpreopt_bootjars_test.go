@@ -20,6 +20,7 @@ import (
 	"testing"
 
 	"android/soong/android"
+	"android/soong/dexpreopt"
 )
 
 func testDexpreoptBoot(t *testing.T, ruleFile string, expectedInputs, expectedOutputs []string) {
@@ -128,3 +129,154 @@ func TestDexpreoptBootZip(t *testing.T) {
 
 	testDexpreoptBoot(t, ruleFile, expectedInputs, expectedOutputs)
 }
+
+func TestDexpreoptBootSecondaryArch(t *testing.T) {
+	bp := `
+		java_library {
+			name: "foo",
+			srcs: ["a.java"],
+			installable: true,
+		}
+
+		platform_bootclasspath {
+			name: "platform-bootclasspath",
+		}
+	`
+
+	preparers := android.GroupFixturePreparers(
+		prepareForJavaTest,
+		FixtureConfigureBootJars("platform:foo"),
+	)
+
+	t.Run("compiler filter", func(t *testing.T) {
+		result := android.GroupFixturePreparers(
+			preparers,
+			dexpreopt.FixtureModifyGlobalConfig(func(_ android.PathContext, config *dexpreopt.GlobalConfig) {
+				config.SecondaryArchBootImageFilter = "space-profile"
+			}),
+		).RunTestWithBp(t, bp)
+
+		platformBootclasspath := result.ModuleForTests("platform-bootclasspath", "android_common")
+		primary := platformBootclasspath.Output("out/soong/test_device/dex_bootjars/android/system/framework/arm64/boot-foo.art")
+		android.AssertStringDoesNotContain(t, "primary arch compiler filter",
+			primary.RuleParams.Command, "--compiler-filter=")
+		secondary := platformBootclasspath.Output("out/soong/test_device/dex_bootjars/android/system/framework/arm/boot-foo.art")
+		android.AssertStringDoesContain(t, "secondary arch compiler filter",
+			secondary.RuleParams.Command, "--compiler-filter=space-profile")
+	})
+
+	t.Run("skip", func(t *testing.T) {
+		result := android.GroupFixturePreparers(
+			preparers,
+			dexpreopt.FixtureModifyGlobalConfig(func(_ android.PathContext, config *dexpreopt.GlobalConfig) {
+				config.SkipSecondaryArchBootImage = true
+			}),
+		).RunTestWithBp(t, bp)
+
+		platformBootclasspath := result.ModuleForTests("platform-bootclasspath", "android_common")
+		platformBootclasspath.Output("out/soong/test_device/dex_bootjars/android/system/framework/arm64/boot-foo.art")
+		if platformBootclasspath.MaybeOutput("out/soong/test_device/dex_bootjars/android/system/framework/arm/boot-foo.art").Rule != nil {
+			t.Errorf("expected the boot image for the secondary arch not to be built")
+		}
+	})
+}
+
+func TestDexpreoptBootJitzygote(t *testing.T) {
+	bp := `
+		java_library {
+			name: "foo",
+			srcs: ["a.java"],
+			installable: true,
+		}
+
+		java_library {
+			name: "bar",
+			srcs: ["b.java"],
+			installable: true,
+		}
+
+		platform_bootclasspath {
+			name: "platform-bootclasspath",
+		}
+	`
+
+	result := android.GroupFixturePreparers(
+		prepareForJavaTest,
+		FixtureConfigureBootJars("platform:foo"),
+		dexpreopt.FixtureSetUseApexImage(true),
+		android.FixtureAddTextFile("frameworks/base/config/boot-image-profile.txt", ""),
+	).RunTestWithBp(t, bp)
+
+	// The JIT-zygote boot image is built alongside the framework boot image extension.
+	platformBootclasspath := result.ModuleForTests("platform-bootclasspath", "android_common")
+	platformBootclasspath.Output("out/soong/test_device/dex_bootjars/boot.prof")
+	platformBootclasspath.Output("out/soong/test_device/dex_jitzygotejars/boot.prof")
+	platformBootclasspath.Output("out/soong/test_device/dex_bootjars/android/system/framework/arm64/boot-foo.art")
+	jitzygote := platformBootclasspath.Output("out/soong/test_device/dex_jitzygotejars/android/system/framework/arm64/apex.art")
+	android.AssertPathsRelativeToTopEquals(t, "jitzygote inputs",
+		[]string{"out/soong/test_device/dex_bootjars_input/foo.jar"}, jitzygote.Implicits)
+	platformBootclasspath.Output("out/soong/test_device/dex_jitzygotejars/jitzygote.zip")
+
+	// Libraries are dexpreopted against the JIT-zygote boot image.
+	rule := result.ModuleForTests("bar", "android_common").Rule("dexpreopt")
+	android.AssertStringDoesContain(t, "boot image",
+		rule.RuleParams.Command, "dex_jitzygotejars/android/system/framework/apex.art")
+	android.AssertStringDoesNotContain(t, "boot image",
+		rule.RuleParams.Command, "dex_bootjars/android/system/framework/boot.art")
+}
+
+func TestAppBootImageProfile(t *testing.T) {
+	bp := `
+		android_app {
+			name: "SystemApp",
+			srcs: ["a.java"],
+			sdk_version: "current",
+			dex_preopt: {
+				profile: "system.prof",
+			},
+		}
+
+		android_app {
+			name: "VendorApp",
+			srcs: ["a.java"],
+			sdk_version: "current",
+			vendor: true,
+			dex_preopt: {
+				profile: "vendor.prof",
+			},
+		}
+
+		android_app {
+			name: "NoProfileApp",
+			srcs: ["a.java"],
+			sdk_version: "current",
+		}
+	`
+
+	result := android.GroupFixturePreparers(
+		prepareForJavaTest,
+		android.FixtureAddTextFile("frameworks/base/config/boot-image-profile.txt", ""),
+		android.FixtureMergeMockFs(android.MockFS{
+			"system.prof": nil,
+			"vendor.prof": nil,
+		}),
+	).RunTestWithBp(t, bp)
+
+	dexBootJars := result.SingletonForTests("dex_bootjars")
+	profman := dexBootJars.Output("out/soong/test_device/dex_bootjars/app-boot-image-profile.txt")
+	android.AssertStringDoesContain(t, "profman command", profman.RuleParams.Command, "--generate-boot-image-profile")
+	android.AssertStringDoesContain(t, "profman command", profman.RuleParams.Command,
+		"--create-profile-from=system.prof")
+	android.AssertStringDoesContain(t, "profman command", profman.RuleParams.Command,
+		"--reference-profile-file=out/soong/.intermediates/SystemApp/android_common/dexpreopt/app-boot-image-profile.prof")
+	android.AssertStringDoesContain(t, "profman command", profman.RuleParams.Command,
+		"--profile-file=out/soong/.intermediates/SystemApp/android_common/dexpreopt/app-boot-image-profile.prof")
+	android.AssertStringDoesNotContain(t, "profman command", profman.RuleParams.Command,
+		"out/soong/.intermediates/SystemApp/android_common/dexpreopt/profile.prof")
+	android.AssertStringDoesNotContain(t, "profman command", profman.RuleParams.Command, "VendorApp")
+	android.AssertStringDoesNotContain(t, "profman command", profman.RuleParams.Command, "NoProfileApp")
+	android.AssertStringDoesContain(t, "diff command", profman.RuleParams.Command,
+		"sort -u frameworks/base/config/boot-image-profile.txt /dev/null")
+
+	dexBootJars.Output("out/soong/test_device/dex_bootjars/app-boot-image-profile.diff")
+}
@@ -39,11 +39,38 @@ func dexpreoptTargets(ctx android.PathContext) []android.Target {
 	return targets
 }
 
+// bootImageTargets returns the list of targets that boot images are built for, which are the
+// dexpreopt targets without the secondary architecture of the device when the product skips its
+// boot images.
+func bootImageTargets(ctx android.PathContext) []android.Target {
+	targets := dexpreoptTargets(ctx)
+	if !dexpreopt.GetGlobalConfig(ctx).SkipSecondaryArchBootImage {
+		return targets
+	}
+
+	var filtered []android.Target
+	for _, target := range targets {
+		if !isSecondaryArchTarget(ctx, target) {
+			filtered = append(filtered, target)
+		}
+	}
+	return filtered
+}
+
+// isSecondaryArchTarget returns true if the target is a device target for an architecture other
+// than the primary architecture of the device.
+func isSecondaryArchTarget(ctx android.PathContext, target android.Target) bool {
+	deviceTargets := ctx.Config().Targets[android.Android]
+	return target.Os == android.Android && len(deviceTargets) > 0 &&
+		target.Arch.ArchType != deviceTargets[0].Arch.ArchType
+}
+
 var (
 	bootImageConfigKey     = android.NewOnceKey("bootImageConfig")
 	bootImageConfigRawKey  = android.NewOnceKey("bootImageConfigRaw")
 	artBootImageName       = "art"
 	frameworkBootImageName = "boot"
+	jitzygoteBootImageName = "jitzygote"
 )
 
 func genBootImageConfigRaw(ctx android.PathContext) map[string]*bootImageConfig {
@@ -78,9 +105,22 @@ func genBootImageConfigRaw(ctx android.PathContext) map[string]*bootImageConfig
 			preloadedClassesFile: "frameworks/base/config/preloaded-classes",
 		}
 
+		// JIT-zygote config for the apex boot image used by products that compile the framework
+		// with the JIT in the zygote (see UseApexImage). Unlike the framework config, it is a
+		// primary boot image that includes both the Core Libraries and the framework libraries.
+		jitzygoteCfg := bootImageConfig{
+			name:                 jitzygoteBootImageName,
+			stem:                 "apex",
+			installDirOnHost:     frameworkSubdir,
+			installDirOnDevice:   frameworkSubdir,
+			modules:              artModules.AppendList(&frameworkModules),
+			preloadedClassesFile: "frameworks/base/config/preloaded-classes",
+		}
+
 		return map[string]*bootImageConfig{
 			artBootImageName:       &artCfg,
 			frameworkBootImageName: &frameworkCfg,
+			jitzygoteBootImageName: &jitzygoteCfg,
 		}
 	}).(map[string]*bootImageConfig)
 }
@@ -88,12 +128,14 @@ func genBootImageConfigRaw(ctx android.PathContext) map[string]*bootImageConfig
 // Construct the global boot image configs.
 func genBootImageConfigs(ctx android.PathContext) map[string]*bootImageConfig {
 	return ctx.Config().Once(bootImageConfigKey, func() interface{} {
-		targets := dexpreoptTargets(ctx)
+		global := dexpreopt.GetGlobalConfig(ctx)
+		targets := bootImageTargets(ctx)
 		deviceDir := android.PathForOutput(ctx, ctx.Config().DeviceName())
 
 		configs := genBootImageConfigRaw(ctx)
 		artCfg := configs[artBootImageName]
 		frameworkCfg := configs[frameworkBootImageName]
+		jitzygoteCfg := configs[jitzygoteBootImageName]
 
 		// common to all configs
 		for _, c := range configs {
@@ -125,6 +167,9 @@ func genBootImageConfigs(ctx android.PathContext) map[string]*bootImageConfig {
 					dexLocations:      c.modules.DevicePaths(ctx.Config(), target.Os),
 				}
 				variant.dexLocationsDeps = variant.dexLocations
+				if isSecondaryArchTarget(ctx, target) {
+					variant.compilerFilter = global.SecondaryArchBootImageFilter
+				}
 				c.variants = append(c.variants, variant)
 			}
 
@@ -139,6 +184,17 @@ func genBootImageConfigs(ctx android.PathContext) map[string]*bootImageConfig {
 			frameworkCfg.variants[i].dexLocationsDeps = append(artCfg.variants[i].dexLocations, frameworkCfg.variants[i].dexLocationsDeps...)
 		}
 
+		// specific to the JIT-zygote config: the dex jars are the ones copied to the predefined
+		// paths of the ART and framework configs, rather than copies of their own.
+		jitzygoteCfg.dexPaths = append(append(android.WritablePaths(nil), artCfg.dexPaths...), frameworkCfg.dexPaths...)
+		jitzygoteCfg.dexPathsDeps = jitzygoteCfg.dexPaths
+		jitzygoteCfg.dexPathsByModule = make(map[string]android.WritablePath)
+		for _, c := range []*bootImageConfig{artCfg, frameworkCfg} {
+			for module, path := range c.dexPathsByModule {
+				jitzygoteCfg.dexPathsByModule[module] = path
+			}
+		}
+
 		return configs
 	}).(map[string]*bootImageConfig)
 }
@@ -151,6 +207,23 @@ func defaultBootImageConfig(ctx android.PathContext) *bootImageConfig {
 	return genBootImageConfigs(ctx)[frameworkBootImageName]
 }
 
+func jitzygoteBootImageConfig(ctx android.PathContext) *bootImageConfig {
+	return genBootImageConfigs(ctx)[jitzygoteBootImageName]
+}
+
+// dexpreoptBootImageConfig returns the boot image that apps and libraries are dexpreopted against:
+// the ART boot image with UseArtImage, the JIT-zygote boot image with UseApexImage, and the
+// framework boot image extension otherwise.
+func dexpreoptBootImageConfig(ctx android.PathContext) *bootImageConfig {
+	global := dexpreopt.GetGlobalConfig(ctx)
+	if global.UseArtImage {
+		return artBootImageConfig(ctx)
+	} else if global.UseApexImage {
+		return jitzygoteBootImageConfig(ctx)
+	}
+	return defaultBootImageConfig(ctx)
+}
+
 // Apex boot config allows to access build/install paths of apex boot jars without going
 // through the usual trouble of registering dependencies on those modules and extracting build paths
 // from those dependencies.
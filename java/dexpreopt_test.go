@@ -193,6 +193,28 @@ func TestDexpreoptEnabled(t *testing.T) {
 			apexVariant: true,
 			enabled:     true,
 		},
+		{
+			name: "app on a partition with preopt disabled",
+			bp: `
+				android_app {
+					name: "foo",
+					srcs: ["a.java"],
+					sdk_version: "current",
+					product_specific: true,
+				}`,
+			enabled: false,
+		},
+		{
+			name: "app on a partition with preopt enabled",
+			bp: `
+				android_app {
+					name: "foo",
+					srcs: ["a.java"],
+					sdk_version: "current",
+					system_ext_specific: true,
+				}`,
+			enabled: true,
+		},
 		{
 			name: "platform variant of apex system server jar",
 			bp: `
@@ -214,6 +236,7 @@ func TestDexpreoptEnabled(t *testing.T) {
 				PrepareForTestWithJavaDefaultModules,
 				PrepareForTestWithFakeApexMutator,
 				dexpreopt.FixtureSetApexSystemServerJars("com.android.apex1:service-foo"),
+				dexpreopt.FixtureDisablePreoptPartitions("product"),
 			)
 
 			result := preparers.RunTestWithBp(t, test.bp)
@@ -248,6 +271,132 @@ func enabledString(enabled bool) string {
 	}
 }
 
+func TestDexpreoptDexlayout(t *testing.T) {
+	result := android.GroupFixturePreparers(
+		PrepareForTestWithJavaDefaultModules,
+		android.FixtureAddTextFile("profile.txt", ""),
+	).RunTestWithBp(t, `
+		android_app {
+			name: "foo",
+			srcs: ["a.java"],
+			sdk_version: "current",
+			dex_preopt: {
+				profile: "profile.txt",
+				dexlayout: true,
+			},
+		}`)
+
+	foo := result.ModuleForTests("foo", "android_common")
+
+	dexlayout := foo.Rule("dexlayout").RuleParams.Command
+	android.AssertStringDoesContain(t, "dexlayout profile", dexlayout, "--create-profile-from=profile.txt")
+	android.AssertStringDoesContain(t, "dexlayout dex location", dexlayout, "--dex-location=/system/app/foo/foo.apk")
+	android.AssertStringDoesContain(t, "dexlayout merge", dexlayout,
+		"-ignore-duplicates out/soong/.intermediates/foo/android_common/dexlayout/foo.jar")
+
+	// The preopted dex jar is the one with the laid out dex files, aligned because the dex files of
+	// preopted apps are stored uncompressed.
+	android.AssertStringDoesContain(t, "dexlayout uncompressed", dexlayout, "-L 0")
+	dexpreopt := foo.Rule("dexpreopt").RuleParams.Command
+	android.AssertStringDoesContain(t, "dexpreopt dex file", dexpreopt,
+		"--dex-file=out/soong/.intermediates/foo/android_common/dexlayout-aligned/foo.jar")
+}
+
+func TestDexpreoptDexlayoutErrors(t *testing.T) {
+	android.GroupFixturePreparers(
+		PrepareForTestWithJavaDefaultModules,
+	).ExtendWithErrorHandler(android.FixtureExpectsAtLeastOneErrorMatchingPattern(
+		`dex_preopt.dexlayout: requires a profile`)).
+		RunTestWithBp(t, `
+			android_app {
+				name: "foo",
+				srcs: ["a.java"],
+				sdk_version: "current",
+				dex_preopt: {
+					dexlayout: true,
+				},
+			}`)
+
+	android.GroupFixturePreparers(
+		PrepareForTestWithJavaDefaultModules,
+	).ExtendWithErrorHandler(android.FixtureExpectsAtLeastOneErrorMatchingPattern(
+		`dex_preopt.dexlayout: is only supported by apps`)).
+		RunTestWithBp(t, `
+			java_library {
+				name: "foo",
+				srcs: ["a.java"],
+				installable: true,
+				dex_preopt: {
+					dexlayout: true,
+				},
+			}`)
+}
+
+func TestDexpreoptVerificationMetadata(t *testing.T) {
+	result := android.GroupFixturePreparers(
+		PrepareForTestWithJavaDefaultModules,
+	).RunTestWithBp(t, `
+		android_app {
+			name: "foo",
+			srcs: ["a.java"],
+			sdk_version: "current",
+			updatable: true,
+			min_sdk_version: "29",
+			dex_preopt: {
+				enabled: false,
+				verification_metadata: true,
+			},
+		}
+
+		android_app {
+			name: "bar",
+			srcs: ["a.java"],
+			sdk_version: "current",
+			dex_preopt: {
+				enabled: false,
+			},
+		}`)
+
+	foo := result.ModuleForTests("foo", "android_common")
+	android.AssertBoolEquals(t, "foo dexpreopted", false, foo.MaybeRule("dexpreopt").Rule != nil)
+
+	rule := foo.Rule("verification_metadata")
+	android.AssertStringDoesContain(t, "compiler filter", rule.RuleParams.Command, "--compiler-filter=verify")
+	android.AssertStringDoesNotContain(t, "app image", rule.RuleParams.Command, "--app-image-file=")
+	foo.Output("verification_metadata/generated.dm")
+
+	metadata := foo.Module().(*AndroidApp).verificationMetadataFiles()
+	android.AssertPathsRelativeToTopEquals(t, "verification metadata", []string{
+		"out/soong/.intermediates/foo/android_common/verification_metadata/generated.dm",
+		"out/soong/.intermediates/foo/android_common/verification_metadata/oat/arm64/package.vdex",
+	}, metadata)
+
+	// The verification metadata isn't installed.
+	for _, install := range foo.Module().(*AndroidApp).FilesToInstall() {
+		android.AssertStringDoesNotContain(t, "installed", install.String(), "/oat/")
+	}
+
+	bar := result.ModuleForTests("bar", "android_common")
+	android.AssertBoolEquals(t, "bar verified", false, bar.MaybeRule("verification_metadata").Rule != nil)
+}
+
+func TestDexpreoptVerificationMetadataErrors(t *testing.T) {
+	android.GroupFixturePreparers(
+		PrepareForTestWithJavaDefaultModules,
+	).ExtendWithErrorHandler(android.FixtureExpectsAtLeastOneErrorMatchingPattern(
+		`dex_preopt.verification_metadata: is only supported by apps`)).
+		RunTestWithBp(t, `
+			java_library {
+				name: "foo",
+				srcs: ["a.java"],
+				installable: true,
+				dex_preopt: {
+					enabled: false,
+					verification_metadata: true,
+				},
+			}`)
+}
+
 func TestDex2oatToolDeps(t *testing.T) {
 	if runtime.GOOS != "linux" {
 		// The host binary paths checked below are build OS dependent.
@@ -1164,6 +1164,21 @@ func (j *Test) generateAndroidBuildActionsWithConfig(ctx android.ModuleContext,
 	})
 
 	j.Library.GenerateAndroidBuildActions(ctx)
+
+	if len(j.testProperties.Test_suites) > 0 && j.installFile != nil {
+		data := make(map[string]android.Path)
+		for _, d := range j.data {
+			data[d.Rel()] = d
+		}
+		ctx.SetProvider(android.TestSuiteInfoProvider, android.TestSuiteInfo{
+			TestSuites:       j.testProperties.Test_suites,
+			OutputFile:       j.outputFile,
+			OutputFileName:   j.installFile.Base(),
+			Data:             data,
+			TestConfig:       j.testConfig,
+			ExtraTestConfigs: j.extraTestConfigs,
+		})
+	}
 }
 
 func (j *TestHelperLibrary) GenerateAndroidBuildActions(ctx android.ModuleContext) {
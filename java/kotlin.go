@@ -64,6 +64,38 @@ var kotlinc = pctx.AndroidRemoteStaticRule("kotlinc", android.RemoteRuleSupports
 	"kotlincFlags", "classpath", "srcJars", "commonSrcFilesArg", "srcJarDir", "classesDir",
 	"headerClassesDir", "headerJar", "kotlinJvmTarget", "kotlinBuildFile", "emptyDir", "name")
 
+var kotlinClasspathSnapshotRule = pctx.AndroidStaticRule("kotlinClasspathSnapshot",
+	blueprint.RuleParams{
+		Command:        `${config.KotlinClasspathSnapshotCmd} --output $out @$out.rsp`,
+		CommandDeps:    []string{"${config.KotlinClasspathSnapshotCmd}"},
+		Rspfile:        "$out.rsp",
+		RspfileContent: `$in`,
+		Restat:         true,
+	})
+
+// kotlinClasspathSnapshot writes a snapshot of the classes in the classpath of kotlinc that is
+// persisted between builds and only rewritten when they change.
+func kotlinClasspathSnapshot(ctx android.ModuleContext, classpath classpath) android.Path {
+	snapshot := android.PathForModuleOut(ctx, "kotlinc", "classpath.snapshot")
+	ctx.Build(pctx, android.BuildParams{
+		Rule:        kotlinClasspathSnapshotRule,
+		Description: "kotlinc classpath snapshot",
+		Output:      snapshot,
+		Inputs:      android.Paths(classpath),
+	})
+	return snapshot
+}
+
+// kotlincClasspathDeps returns the dependencies of kotlinc and kapt on the classpath.  With a
+// classpath snapshot the jars are only order-only dependencies, so that kotlinc and kapt only
+// rerun when the snapshot changes.
+func kotlincClasspathDeps(flags javaBuilderFlags) (deps, orderOnlyDeps android.Paths) {
+	if flags.kotlincClasspathSnapshot.Valid() {
+		return android.Paths{flags.kotlincClasspathSnapshot.Path()}, android.Paths(flags.kotlincClasspath)
+	}
+	return android.Paths(flags.kotlincClasspath), nil
+}
+
 func kotlinCommonSrcsList(ctx android.ModuleContext, commonSrcFiles android.Paths) android.OptionalPath {
 	if len(commonSrcFiles) > 0 {
 		// The list of common_srcs may be too long to put on the command line, but
@@ -85,8 +117,7 @@ func kotlinCompile(ctx android.ModuleContext, outputFile, headerOutputFile andro
 	srcFiles, commonSrcFiles, srcJars android.Paths,
 	flags javaBuilderFlags) {
 
-	var deps android.Paths
-	deps = append(deps, flags.kotlincClasspath...)
+	deps, orderOnlyDeps := kotlincClasspathDeps(flags)
 	deps = append(deps, flags.kotlincDeps...)
 	deps = append(deps, srcJars...)
 	deps = append(deps, commonSrcFiles...)
@@ -108,6 +139,7 @@ func kotlinCompile(ctx android.ModuleContext, outputFile, headerOutputFile andro
 		ImplicitOutput: headerOutputFile,
 		Inputs:         srcFiles,
 		Implicits:      deps,
+		OrderOnly:      orderOnlyDeps,
 		Args: map[string]string{
 			"classpath":         flags.kotlincClasspath.FormJavaClassPath(""),
 			"kotlincFlags":      flags.kotlincFlags,
@@ -173,8 +205,7 @@ func kotlinKapt(ctx android.ModuleContext, srcJarOutputFile, resJarOutputFile an
 
 	srcFiles = append(android.Paths(nil), srcFiles...)
 
-	var deps android.Paths
-	deps = append(deps, flags.kotlincClasspath...)
+	deps, orderOnlyDeps := kotlincClasspathDeps(flags)
 	deps = append(deps, flags.kotlincDeps...)
 	deps = append(deps, srcJars...)
 	deps = append(deps, flags.processorPath...)
@@ -212,6 +243,7 @@ func kotlinKapt(ctx android.ModuleContext, srcJarOutputFile, resJarOutputFile an
 		ImplicitOutput: resJarOutputFile,
 		Inputs:         srcFiles,
 		Implicits:      deps,
+		OrderOnly:      orderOnlyDeps,
 		Args: map[string]string{
 			"classpath":         flags.kotlincClasspath.FormJavaClassPath(""),
 			"kotlincFlags":      flags.kotlincFlags,
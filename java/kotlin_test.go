@@ -246,6 +246,65 @@ func TestKapt(t *testing.T) {
 	})
 }
 
+func TestKotlinClasspathSnapshot(t *testing.T) {
+	bp := `
+		java_library {
+			name: "foo",
+			srcs: ["a.java", "b.kt"],
+			libs: ["bar"],
+			plugins: ["baz"],
+		}
+
+		java_library {
+			name: "bar",
+			srcs: ["c.java"],
+		}
+
+		java_plugin {
+			name: "baz",
+			processor_class: "com.baz",
+			srcs: ["d.java"],
+		}
+	`
+
+	t.Run("disabled", func(t *testing.T) {
+		result := PrepareForTestWithJavaDefaultModules.RunTestWithBp(t, bp)
+		foo := result.ModuleForTests("foo", "android_common")
+		if foo.MaybeOutput("kotlinc/classpath.snapshot").Rule != nil {
+			t.Errorf("unexpected kotlinc classpath snapshot")
+		}
+		barHeaderJar := result.ModuleForTests("bar", "android_common").Output("turbine-combined/bar.jar").Output
+		android.AssertPathsRelativeToTopEquals(t, "kotlinc order-only deps", nil, foo.Rule("kotlinc").OrderOnly)
+		android.AssertStringListContains(t, "kotlinc deps",
+			android.PathsRelativeToTop(foo.Rule("kotlinc").Implicits), android.PathRelativeToTop(barHeaderJar))
+	})
+
+	t.Run("enabled", func(t *testing.T) {
+		result := android.GroupFixturePreparers(
+			PrepareForTestWithJavaDefaultModules,
+			android.FixtureMergeEnv(map[string]string{"SOONG_KOTLIN_CLASSPATH_SNAPSHOT": "true"}),
+		).RunTestWithBp(t, bp)
+
+		foo := result.ModuleForTests("foo", "android_common")
+		barHeaderJar := android.PathRelativeToTop(
+			result.ModuleForTests("bar", "android_common").Output("turbine-combined/bar.jar").Output)
+
+		snapshot := foo.Output("kotlinc/classpath.snapshot")
+		android.AssertStringListContains(t, "snapshot inputs",
+			android.PathsRelativeToTop(snapshot.Inputs), barHeaderJar)
+
+		for _, rule := range []string{"kotlinc", "kapt"} {
+			params := foo.Rule(rule)
+			android.AssertStringListContains(t, rule+" deps",
+				android.PathsRelativeToTop(params.Implicits), android.PathRelativeToTop(snapshot.Output))
+			android.AssertStringListDoesNotContain(t, rule+" deps",
+				android.PathsRelativeToTop(params.Implicits), barHeaderJar)
+			android.AssertStringListContains(t, rule+" order-only deps",
+				android.PathsRelativeToTop(params.OrderOnly), barHeaderJar)
+		}
+	})
+}
+
 func TestKaptEncodeFlags(t *testing.T) {
 	// Compares the kaptEncodeFlags against the results of the example implementation at
 	// https://kotlinlang.org/docs/reference/kapt.html#apjavac-options-encoding
@@ -445,4 +445,14 @@ func (b *platformBootclasspathModule) generateBootImageBuildActions(ctx android.
 	buildBootImageVariantsForBuildOs(ctx, imageConfig, profile)
 
 	dumpOatRules(ctx, imageConfig)
+
+	// The JIT-zygote boot image is built from the dex jars copied to the predefined locations of
+	// the ART and framework boot images.
+	if global.UseApexImage {
+		jitzygoteConfig := jitzygoteBootImageConfig(ctx)
+		jitzygoteProfile := bootImageProfileRule(ctx, jitzygoteConfig)
+		jitzygoteFilesByArch := buildBootImageVariantsForAndroidOs(ctx, jitzygoteConfig, jitzygoteProfile)
+		buildBootImageZipInPredefinedLocation(ctx, jitzygoteConfig, jitzygoteFilesByArch)
+		buildBootImageVariantsForBuildOs(ctx, jitzygoteConfig, jitzygoteProfile)
+	}
 }
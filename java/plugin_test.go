@@ -120,3 +120,55 @@ func TestPluginGeneratesApi(t *testing.T) {
 		t.Errorf("foo processor %q != '-processor com.bar'", javac.Args["processor"])
 	}
 }
+
+func TestPluginAnnotationProcessorCheck(t *testing.T) {
+	ctx, _ := testJava(t, `
+		java_library {
+			name: "foo",
+			srcs: ["a.java"],
+			plugins: ["bar", "baz"],
+			annotation_processor_check: true,
+		}
+
+		java_library {
+			name: "qux",
+			srcs: ["a.java"],
+			plugins: ["bar"],
+		}
+
+		java_plugin {
+			name: "bar",
+			processor_class: "com.bar",
+			srcs: ["b.java"],
+		}
+
+		java_plugin {
+			name: "baz",
+			processor_class: "com.baz",
+			srcs: ["b.java"],
+		}
+	`)
+
+	buildOS := ctx.Config().BuildOS.String()
+
+	foo := ctx.ModuleForTests("foo", "android_common")
+	javac := foo.Rule("javac")
+	check := foo.Rule("annotationProcessorCheck")
+
+	if !inList(check.Output.String(), javac.Implicits.Strings()) {
+		t.Errorf("foo javac implicits %v does not contain %q", javac.Implicits.Strings(), check.Output.String())
+	}
+
+	bar := ctx.ModuleForTests("bar", buildOS+"_common").Rule("javac").Output.String()
+	if !inList(bar, check.Implicits.Strings()) {
+		t.Errorf("foo annotation processor check implicits %v does not contain %q", check.Implicits.Strings(), bar)
+	}
+
+	if check.Args["processor"] != "-processor com.bar,com.baz" {
+		t.Errorf("foo processor %q != '-processor com.bar,com.baz'", check.Args["processor"])
+	}
+
+	if rule := ctx.ModuleForTests("qux", "android_common").MaybeRule("annotationProcessorCheck"); rule.Rule != nil {
+		t.Errorf("expected no annotation processor check for qux")
+	}
+}
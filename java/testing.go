@@ -315,6 +315,7 @@ func registerRequiredBuildComponentsForTest(ctx android.RegistrationContext) {
 	RegisterSystemModulesBuildComponents(ctx)
 	registerSystemserverClasspathBuildComponents(ctx)
 	registerLintBuildComponents(ctx)
+	registerVerificationMetadataBuildComponents(ctx)
 }
 
 // gatherRequiredDepsForTest gathers the module definitions used by
@@ -0,0 +1,146 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package java
+
+import (
+	"path/filepath"
+
+	"android/soong/android"
+	"android/soong/dexpreopt"
+)
+
+// Apps that aren't dexpreopted, typically updatable apps that are delivered through the store, can
+// still have their dex files verified at build time when dex_preopt.verification_metadata is set.
+// The dexpreopt rule is generated with the verify compiler filter for the primary architecture
+// only, as verification doesn't depend on it, and its .dm and .vdex files aren't installed but
+// disted as <app>.dm and <app>.vdex for the verification-metadata goal, so that they can be
+// delivered along with the app.
+
+func init() {
+	registerVerificationMetadataBuildComponents(android.InitRegistrationContext)
+}
+
+func registerVerificationMetadataBuildComponents(ctx android.RegistrationContext) {
+	ctx.RegisterSingletonType("verification_metadata", verificationMetadataSingletonFactory)
+}
+
+// verificationMetadataEnabled returns true if the module generates the verification metadata of its
+// dex files although it isn't dexpreopted.
+func (d *dexpreopter) verificationMetadataEnabled(ctx android.BaseModuleContext) bool {
+	if !Bool(d.dexpreoptProperties.Dex_preopt.Verification_metadata) {
+		return false
+	}
+	if !ctx.Device() || d.isTest || isApexVariant(ctx) {
+		return false
+	}
+	// The boot image that the dex files are verified against isn't built when dexpreopting is
+	// disabled globally.
+	return !dexpreopt.GetGlobalConfig(ctx).DisablePreopt
+}
+
+// generateVerificationMetadata generates the .dm and .vdex files of the module from the dexpreopt
+// rule of the module, with the verify compiler filter and without installing the outputs.
+func (d *dexpreopter) generateVerificationMetadata(ctx android.ModuleContext,
+	global *dexpreopt.GlobalConfig, config *dexpreopt.ModuleConfig) {
+
+	if !d.isApp {
+		ctx.PropertyErrorf("dex_preopt.verification_metadata", "is only supported by apps")
+		return
+	}
+	if len(config.Archs) == 0 {
+		return
+	}
+
+	verifyConfig := *config
+	verifyConfig.BuildPath = android.PathForModuleOut(ctx, "verification_metadata", moduleName(ctx)+".jar").OutputPath
+	verifyConfig.PreoptFlags = []string{"--compiler-filter=verify"}
+	verifyConfig.ProfileClassListing = android.OptionalPath{}
+	verifyConfig.ProfileBootListing = android.OptionalPath{}
+	verifyConfig.Archs = config.Archs[:1]
+	verifyConfig.DexPreoptImagesDeps = config.DexPreoptImagesDeps[:1]
+	verifyConfig.NoCreateAppImage = true
+	verifyConfig.ForceCreateAppImage = false
+
+	// The module is verified regardless of the product settings that disable dexpreopting it.
+	verifyGlobal := *global
+	verifyGlobal.DisablePreoptModules = nil
+	verifyGlobal.DisablePreoptPartitions = nil
+	verifyGlobal.OnlyPreoptBootImageAndSystemServer = false
+	verifyGlobal.GenerateDMFiles = true
+	verifyGlobal.DefaultAppImages = false
+	verifyGlobal.VerifyOdexBootClassPath = false
+
+	rule, err := dexpreopt.GenerateDexpreoptRule(ctx, dexpreopt.GetGlobalSoongConfig(ctx), &verifyGlobal, &verifyConfig)
+	if err != nil {
+		ctx.ModuleErrorf("error generating verification metadata rule: %s", err.Error())
+		return
+	}
+
+	rule.Build("verification_metadata", "verification metadata")
+
+	for _, install := range rule.Installs() {
+		if ext := filepath.Ext(install.To); ext == ".dm" || ext == ".vdex" {
+			d.verificationMetadata = append(d.verificationMetadata, install.From)
+		}
+	}
+}
+
+func (d *dexpreopter) verificationMetadataFiles() android.Paths {
+	return d.verificationMetadata
+}
+
+type verificationMetadataProvider interface {
+	verificationMetadataFiles() android.Paths
+}
+
+func verificationMetadataSingletonFactory() android.Singleton {
+	return &verificationMetadataSingleton{}
+}
+
+// verificationMetadataSingleton dists the verification metadata of all apps for the
+// verification-metadata goal.
+type verificationMetadataSingleton struct {
+	files map[string]android.Path
+}
+
+func (s *verificationMetadataSingleton) GenerateBuildActions(ctx android.SingletonContext) {
+	s.files = make(map[string]android.Path)
+	ctx.VisitAllModules(func(module android.Module) {
+		p, ok := module.(verificationMetadataProvider)
+		if !ok || !module.Enabled() {
+			return
+		}
+		name := android.RemoveOptionalPrebuiltPrefix(ctx.ModuleName(module))
+		for _, file := range p.verificationMetadataFiles() {
+			s.files[name+file.Ext()] = file
+		}
+	})
+
+	if len(s.files) == 0 {
+		return
+	}
+
+	var files android.Paths
+	for _, name := range android.SortedStringKeys(s.files) {
+		files = append(files, s.files[name])
+	}
+	ctx.Phony("verification-metadata", files...)
+}
+
+func (s *verificationMetadataSingleton) MakeVars(ctx android.MakeVarsContext) {
+	for _, name := range android.SortedStringKeys(s.files) {
+		ctx.DistForGoalWithFilename("verification-metadata", s.files[name], name)
+	}
+}
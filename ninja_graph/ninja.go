@@ -0,0 +1,465 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package ninja_graph parses ninja manifests into the build actions they declare, attributed to the
+// modules and singletons that created them, for tools that analyze the build graph without
+// running ninja.
+package ninja_graph
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+)
+
+// Graph is the set of build actions declared by a ninja manifest and the manifests it
+// includes, with the commands fully evaluated.
+type Graph struct {
+	Actions []*Action
+}
+
+// Action is a single build statement.
+type Action struct {
+	// The module and variant that created the action, from the comments blueprint writes before
+	// the build statements of each module.  Actions created by singletons have the name of the
+	// singleton as module and an empty variant.
+	Module  string
+	Variant string
+
+	Rule      string
+	Outputs   []string
+	Inputs    []string
+	Implicits []string
+	OrderOnly []string
+	Command   string
+}
+
+// AllInputs returns the explicit, implicit and order-only inputs of the action.
+func (a *Action) AllInputs() []string {
+	var ret []string
+	ret = append(ret, a.Inputs...)
+	ret = append(ret, a.Implicits...)
+	ret = append(ret, a.OrderOnly...)
+	return ret
+}
+
+type ninjaRule struct {
+	vars map[string]string
+}
+
+// ninjaScope holds the variables and rules of a manifest.  Manifests loaded with subninja get a
+// child scope, manifests loaded with include share the scope of the including manifest.
+type ninjaScope struct {
+	parent *ninjaScope
+	vars   map[string]string
+	rules  map[string]*ninjaRule
+}
+
+func newNinjaScope(parent *ninjaScope) *ninjaScope {
+	return &ninjaScope{
+		parent: parent,
+		vars:   make(map[string]string),
+		rules:  make(map[string]*ninjaRule),
+	}
+}
+
+func (s *ninjaScope) lookupVar(name string) string {
+	for ; s != nil; s = s.parent {
+		if v, ok := s.vars[name]; ok {
+			return v
+		}
+	}
+	return ""
+}
+
+func (s *ninjaScope) lookupRule(name string) *ninjaRule {
+	for ; s != nil; s = s.parent {
+		if r, ok := s.rules[name]; ok {
+			return r
+		}
+	}
+	return nil
+}
+
+// ninjaParser parses ninja manifests, resolving include and subninja statements relative to
+// topDir, the directory ninja runs in.
+type ninjaParser struct {
+	topDir string
+	graph  *Graph
+
+	module  string
+	variant string
+}
+
+// Parse parses the ninja manifest at path and all the manifests it includes, resolving include and
+// subninja paths relative to topDir.
+func Parse(path, topDir string) (*Graph, error) {
+	p := &ninjaParser{
+		topDir: topDir,
+		graph:  &Graph{},
+	}
+	if err := p.parseFile(path, newNinjaScope(nil)); err != nil {
+		return nil, err
+	}
+	return p.graph, nil
+}
+
+func (p *ninjaParser) parseFile(path string, scope *ninjaScope) error {
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(p.topDir, path)
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if err := p.parse(string(data), scope); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
+
+// ninjaLine is a logical line of a manifest, with continuations joined.
+type ninjaLine struct {
+	number   int
+	indented bool
+	text     string
+}
+
+// splitNinjaLines splits the manifest into logical lines, joining lines ending in an unescaped $
+// with the next line.
+func splitNinjaLines(data string) []ninjaLine {
+	var lines []ninjaLine
+	var cur *ninjaLine
+	for i, text := range strings.Split(data, "\n") {
+		text = strings.TrimRight(text, "\r")
+		if cur == nil {
+			trimmed := strings.TrimLeft(text, " ")
+			cur = &ninjaLine{
+				number:   i + 1,
+				indented: len(trimmed) < len(text),
+				text:     trimmed,
+			}
+		} else {
+			cur.text += strings.TrimLeft(text, " ")
+		}
+
+		trailingDollars := len(cur.text) - len(strings.TrimRight(cur.text, "$"))
+		if trailingDollars%2 == 1 {
+			cur.text = cur.text[:len(cur.text)-1]
+			continue
+		}
+		lines = append(lines, *cur)
+		cur = nil
+	}
+	if cur != nil {
+		lines = append(lines, *cur)
+	}
+	return lines
+}
+
+func (p *ninjaParser) parse(data string, scope *ninjaScope) error {
+	lines := splitNinjaLines(data)
+
+	// indentedVars consumes the variable assignments indented under a rule or build statement.
+	indentedVars := func(i int) (map[string]string, int, error) {
+		vars := make(map[string]string)
+		for ; i < len(lines) && lines[i].indented; i++ {
+			if lines[i].text == "" || strings.HasPrefix(lines[i].text, "#") {
+				continue
+			}
+			name, value, err := parseAssignment(lines[i].text)
+			if err != nil {
+				return nil, i, fmt.Errorf("line %d: %w", lines[i].number, err)
+			}
+			vars[name] = value
+		}
+		return vars, i, nil
+	}
+
+	for i := 0; i < len(lines); {
+		line := lines[i]
+		i++
+
+		if line.text == "" {
+			continue
+		}
+		if strings.HasPrefix(line.text, "#") {
+			p.parseComment(line.text)
+			continue
+		}
+
+		keyword, rest := splitKeyword(line.text)
+		switch keyword {
+		case "rule":
+			vars, next, err := indentedVars(i)
+			if err != nil {
+				return err
+			}
+			i = next
+			scope.rules[strings.TrimSpace(rest)] = &ninjaRule{vars: vars}
+
+		case "build":
+			vars, next, err := indentedVars(i)
+			if err != nil {
+				return err
+			}
+			i = next
+			if err := p.parseBuild(rest, vars, scope); err != nil {
+				return fmt.Errorf("line %d: %w", line.number, err)
+			}
+
+		case "pool":
+			_, next, err := indentedVars(i)
+			if err != nil {
+				return err
+			}
+			i = next
+
+		case "default":
+			// Default targets don't affect the commands of the actions.
+
+		case "include", "subninja":
+			path := evaluate(strings.TrimSpace(rest), scope.lookupVar)
+			fileScope := scope
+			if keyword == "subninja" {
+				fileScope = newNinjaScope(scope)
+			}
+			module, variant := p.module, p.variant
+			if err := p.parseFile(path, fileScope); err != nil {
+				return err
+			}
+			p.module, p.variant = module, variant
+
+		default:
+			name, value, err := parseAssignment(line.text)
+			if err != nil {
+				return fmt.Errorf("line %d: %w", line.number, err)
+			}
+			scope.vars[name] = evaluate(value, scope.lookupVar)
+		}
+	}
+	return nil
+}
+
+// parseComment tracks the module or singleton that the following build statements belong to
+// from the comments blueprint writes before them:
+//
+//	# Module:  libfoo
+//	# Variant: android_arm64_armv8-a_shared
+//
+//	# Singleton: installed_files
+func (p *ninjaParser) parseComment(text string) {
+	text = strings.TrimSpace(strings.TrimPrefix(text, "#"))
+	if v := strings.TrimPrefix(text, "Module:"); v != text {
+		p.module = strings.TrimSpace(v)
+		p.variant = ""
+	} else if v := strings.TrimPrefix(text, "Variant:"); v != text {
+		p.variant = strings.TrimSpace(v)
+	} else if v := strings.TrimPrefix(text, "Singleton:"); v != text {
+		p.module = strings.TrimSpace(v)
+		p.variant = ""
+	}
+}
+
+func (p *ninjaParser) parseBuild(text string, vars map[string]string, scope *ninjaScope) error {
+	colon := findUnescaped(text, ':')
+	if colon < 0 {
+		return fmt.Errorf("expected ':' in build statement")
+	}
+
+	outputGroups := splitPathGroups(splitPaths(text[:colon]), "|")
+	outputs, implicitOutputs := outputGroups["outputs"], outputGroups["|"]
+
+	paths := splitPaths(text[colon+1:])
+	if len(paths) == 0 {
+		return fmt.Errorf("expected rule name in build statement")
+	}
+	rule := paths[0]
+	inputGroups := splitPathGroups(paths[1:], "|", "||", "|@")
+
+	eval := func(list []string) []string {
+		var ret []string
+		for _, s := range list {
+			ret = append(ret, evaluate(s, scope.lookupVar))
+		}
+		return ret
+	}
+
+	action := &Action{
+		Module:    p.module,
+		Variant:   p.variant,
+		Rule:      rule,
+		Outputs:   eval(append(outputs, implicitOutputs...)),
+		Inputs:    eval(inputGroups["outputs"]),
+		Implicits: eval(inputGroups["|"]),
+		OrderOnly: eval(inputGroups["||"]),
+	}
+
+	// Build statement variables are evaluated in the scope of the manifest.
+	buildVars := make(map[string]string)
+	for name, value := range vars {
+		buildVars[name] = evaluate(value, scope.lookupVar)
+	}
+
+	if rule != "phony" {
+		r := scope.lookupRule(rule)
+		if r == nil {
+			return fmt.Errorf("unknown rule %q", rule)
+		}
+		explicitOutputs := action.Outputs[:len(outputs)]
+
+		// Rule variables are evaluated lazily in the scope of the build statement.
+		var lookup func(name string) string
+		expanding := make(map[string]bool)
+		lookup = func(name string) string {
+			switch name {
+			case "in":
+				return strings.Join(action.Inputs, " ")
+			case "in_newline":
+				return strings.Join(action.Inputs, "\n")
+			case "out":
+				return strings.Join(explicitOutputs, " ")
+			}
+			if v, ok := buildVars[name]; ok {
+				return v
+			}
+			if v, ok := r.vars[name]; ok && !expanding[name] {
+				expanding[name] = true
+				defer delete(expanding, name)
+				return evaluate(v, lookup)
+			}
+			return scope.lookupVar(name)
+		}
+		action.Command = lookup("command")
+	}
+
+	p.graph.Actions = append(p.graph.Actions, action)
+	return nil
+}
+
+// splitKeyword returns the first word of a line and the rest of it.
+func splitKeyword(text string) (string, string) {
+	if i := strings.IndexAny(text, " \t"); i >= 0 {
+		return text[:i], text[i+1:]
+	}
+	return text, ""
+}
+
+// parseAssignment parses a "name = value" line.
+func parseAssignment(text string) (string, string, error) {
+	i := strings.Index(text, "=")
+	if i < 0 {
+		return "", "", fmt.Errorf("expected variable assignment, got %q", text)
+	}
+	name := strings.TrimSpace(text[:i])
+	if name == "" {
+		return "", "", fmt.Errorf("expected variable name, got %q", text)
+	}
+	return name, strings.TrimLeft(text[i+1:], " "), nil
+}
+
+// findUnescaped returns the index of the first c in text that isn't escaped with $, or -1.
+func findUnescaped(text string, c byte) int {
+	for i := 0; i < len(text); i++ {
+		if text[i] == '$' {
+			i++
+			continue
+		}
+		if text[i] == c {
+			return i
+		}
+	}
+	return -1
+}
+
+// splitPaths splits text on spaces that aren't escaped with $, leaving the escapes in place.
+func splitPaths(text string) []string {
+	var paths []string
+	for len(text) > 0 {
+		text = strings.TrimLeft(text, " ")
+		if text == "" {
+			break
+		}
+		end := findUnescaped(text, ' ')
+		if end < 0 {
+			end = len(text)
+		}
+		paths = append(paths, text[:end])
+		text = text[end:]
+	}
+	return paths
+}
+
+// splitPathGroups splits a list of paths on the given separators.  The paths before the first
+// separator are returned as "outputs" for outputs and explicit inputs alike.
+func splitPathGroups(paths []string, separators ...string) map[string][]string {
+	groups := make(map[string][]string)
+	group := "outputs"
+	for _, path := range paths {
+		isSeparator := false
+		for _, sep := range separators {
+			if path == sep {
+				group = sep
+				isSeparator = true
+			}
+		}
+		if !isSeparator {
+			groups[group] = append(groups[group], path)
+		}
+	}
+	return groups
+}
+
+func isSimpleVarChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '-'
+}
+
+// evaluate expands the variable references and escapes in a ninja string.
+func evaluate(text string, lookup func(string) string) string {
+	if !strings.Contains(text, "$") {
+		return text
+	}
+	var sb strings.Builder
+	for i := 0; i < len(text); i++ {
+		if text[i] != '$' || i+1 == len(text) {
+			sb.WriteByte(text[i])
+			continue
+		}
+		i++
+		switch c := text[i]; {
+		case c == '$' || c == ' ' || c == ':':
+			sb.WriteByte(c)
+		case c == '{':
+			end := strings.IndexByte(text[i:], '}')
+			if end < 0 {
+				sb.WriteString(text[i-1:])
+				return sb.String()
+			}
+			sb.WriteString(lookup(text[i+1 : i+end]))
+			i += end
+		case isSimpleVarChar(c):
+			end := i
+			for end < len(text) && isSimpleVarChar(text[end]) {
+				end++
+			}
+			sb.WriteString(lookup(text[i:end]))
+			i = end - 1
+		default:
+			sb.WriteByte('$')
+			sb.WriteByte(c)
+		}
+	}
+	return sb.String()
+}
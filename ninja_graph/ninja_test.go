@@ -0,0 +1,167 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ninja_graph
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ninja_graph")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	writeFile := func(name, content string) {
+		t.Helper()
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	writeFile("build.ninja", `
+ninja_required_version = 1.7.0
+g.cc.clang = prebuilts/clang/bin/clang
+cflags = -O2
+
+rule g.cc.cc
+    command = ${g.cc.clang} $cflags $extra -c $in -o $out
+    description = cc $out
+
+# # # # # # # # # # # # # # # # # # # # # # # # # # # # # # # # # # # # # # # #
+# Module:  libfoo
+# Variant: android_arm64_armv8-a_shared
+# Type:    cc_library
+
+build out/foo.o | out/foo.d: g.cc.cc foo.c | foo.h || out/gen $
+        out/other
+    extra = -DFOO$ BAR -Werror
+
+# # # # # # # # # # # # # # # # # # # # # # # # # # # # # # # # # # # # # # # #
+# Singleton: installed_files
+
+build installed-files-json: phony out/installed-files.json
+
+include other.ninja
+`)
+
+	writeFile("other.ninja", `
+rule touch
+    command = touch $out $$HOME
+
+build out/bar: touch
+`)
+
+	graph, err := Parse("build.ninja", dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []*Action{
+		{
+			Module:    "libfoo",
+			Variant:   "android_arm64_armv8-a_shared",
+			Rule:      "g.cc.cc",
+			Outputs:   []string{"out/foo.o", "out/foo.d"},
+			Inputs:    []string{"foo.c"},
+			Implicits: []string{"foo.h"},
+			OrderOnly: []string{"out/gen", "out/other"},
+			Command:   "prebuilts/clang/bin/clang -O2 -DFOO BAR -Werror -c foo.c -o out/foo.o",
+		},
+		{
+			Module:  "installed_files",
+			Rule:    "phony",
+			Outputs: []string{"installed-files-json"},
+			Inputs:  []string{"out/installed-files.json"},
+		},
+		{
+			Module:  "installed_files",
+			Rule:    "touch",
+			Outputs: []string{"out/bar"},
+			Command: "touch out/bar $HOME",
+		},
+	}
+
+	if !reflect.DeepEqual(graph.Actions, want) {
+		for i := range graph.Actions {
+			t.Errorf("got action %d: %+v", i, *graph.Actions[i])
+		}
+		for i := range want {
+			t.Errorf("want action %d: %+v", i, *want[i])
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	testCases := []struct {
+		name  string
+		ninja string
+	}{
+		{
+			name:  "unknown rule",
+			ninja: "build out: missing in\n",
+		},
+		{
+			name:  "missing colon",
+			ninja: "rule r\n    command = true\nbuild out r in\n",
+		},
+		{
+			name:  "bad assignment",
+			ninja: "foo bar\n",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			p := &ninjaParser{graph: &Graph{}}
+			if err := p.parse(tc.ninja, newNinjaScope(nil)); err == nil {
+				t.Errorf("expected error")
+			}
+		})
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	vars := map[string]string{
+		"a":     "A",
+		"a.b":   "AB",
+		"a-b_c": "ABC",
+	}
+	lookup := func(name string) string { return vars[name] }
+
+	testCases := []struct {
+		in, out string
+	}{
+		{"plain", "plain"},
+		{"$a/x", "A/x"},
+		{"${a}x", "Ax"},
+		{"${a.b}", "AB"},
+		{"$a.b", "A.b"},
+		{"$a-b_c", "ABC"},
+		{"$$a $ b$:c", "$a  b:c"},
+		{"$missing", ""},
+	}
+
+	for _, tc := range testCases {
+		if got := evaluate(tc.in, lookup); got != tc.out {
+			t.Errorf("evaluate(%q) = %q, want %q", tc.in, got, tc.out)
+		}
+	}
+}
@@ -28,6 +28,7 @@ import (
 	"github.com/google/blueprint/proptools"
 
 	"android/soong/android"
+	"android/soong/cc"
 )
 
 func init() {
@@ -63,6 +64,11 @@ type VersionProperties struct {
 
 	// whether the binary is required to be built with embedded launcher for this version, defaults to false.
 	Embedded_launcher *bool // TODO(b/174041232): Remove this property
+
+	// the version of the hermetic CPython interpreter to embed when embedded_launcher is true, either
+	// "3.10" or "3.11". Native extensions in data must be built for the same version. Only supported
+	// for Python 3, defaults to "3.10".
+	Hermetic_version *string
 }
 
 // properties that apply to all python modules
@@ -98,6 +104,10 @@ type BaseProperties struct {
 	// list of the Python libraries compatible both with Python2 and Python3.
 	Libs []string `android:"arch_variant"`
 
+	// list of python_cext modules whose native extensions should be packaged at the root of
+	// pkg_path alongside the source files.
+	Cexts []string `android:"arch_variant"`
+
 	Version struct {
 		// Python2-specific properties, including whether Python2 is supported for this module
 		// and version-specific sources, exclusions and dependencies.
@@ -120,6 +130,10 @@ type BaseProperties struct {
 	// whether the binary is required to be built with embedded launcher for this actual_version.
 	// this is set by the python version mutator based on version-specific properties
 	Embedded_launcher *bool `blueprint:"mutated"`
+
+	// the version of the hermetic CPython interpreter embedded for this actual_version.
+	// this is set by the python version mutator based on version-specific properties
+	Hermetic_version *string `blueprint:"mutated"`
 }
 
 type baseAttributes struct {
@@ -289,6 +303,7 @@ type installDependencyTag struct {
 var (
 	pythonLibTag         = dependencyTag{name: "pythonLib"}
 	javaDataTag          = dependencyTag{name: "javaData"}
+	cextTag              = dependencyTag{name: "cext"}
 	launcherTag          = dependencyTag{name: "launcher"}
 	launcherSharedLibTag = installDependencyTag{name: "launcherSharedLib"}
 	pathComponentRegexp  = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_-]*$`)
@@ -303,6 +318,9 @@ var (
 	internalPath         = "internal"
 )
 
+// nativeExtensionRegexp matches the ABI tag of a native extension, e.g. foo.cpython-311-x86_64-linux-gnu.so
+var nativeExtensionRegexp = regexp.MustCompile(`\.cpython-(\d)(\d+)[^/]*\.so$`)
+
 // versionSplitMutator creates version variants for modules and appends the version-specific
 // properties for a given variant to the properties in the variant module
 func versionSplitMutator() func(android.BottomUpMutatorContext) {
@@ -372,6 +390,21 @@ func (p *Module) isEmbeddedLauncherEnabled() bool {
 	return p.installer != nil && Bool(p.properties.Embedded_launcher)
 }
 
+// hermeticVersion returns the version of the hermetic CPython interpreter embedded by a Python 3
+// module with embedded_launcher.
+func (p *Module) hermeticVersion() string {
+	return proptools.StringDefault(p.properties.Hermetic_version, cc.DefaultHermeticPythonVersion)
+}
+
+// nativeExtensionVersion returns the CPython version a native extension was built for based on
+// its ABI tag, or false if the path is not a tagged native extension.
+func nativeExtensionVersion(path string) (string, bool) {
+	if m := nativeExtensionRegexp.FindStringSubmatch(path); m != nil {
+		return m[1] + "." + m[2], true
+	}
+	return "", false
+}
+
 func anyHasExt(paths []string, ext string) bool {
 	for _, p := range paths {
 		if filepath.Ext(p) == ext {
@@ -405,6 +438,22 @@ func (p *Module) DepsMutator(ctx android.BottomUpMutatorContext) {
 	// Add python library dependencies for this python version variation
 	ctx.AddVariationDependencies(versionVariation, pythonLibTag, android.LastUniqueStrings(p.properties.Libs)...)
 
+	if p.properties.Hermetic_version != nil {
+		if p.properties.Actual_version != pyVersion3 {
+			ctx.PropertyErrorf("version.py2.hermetic_version", "is only supported for Python 3")
+			return
+		}
+		if !p.isEmbeddedLauncherEnabled() {
+			ctx.PropertyErrorf("version.py3.hermetic_version", "requires embedded_launcher")
+			return
+		}
+		if _, ok := cc.HermeticPythonVersions[p.hermeticVersion()]; !ok {
+			ctx.PropertyErrorf("version.py3.hermetic_version", "unsupported version %q, must be one of %q",
+				p.hermeticVersion(), android.SortedStringKeys(cc.HermeticPythonVersions))
+			return
+		}
+	}
+
 	// If this module will be installed and has an embedded launcher, we need to add dependencies for:
 	//   * standard library
 	//   * launcher
@@ -439,11 +488,12 @@ func (p *Module) DepsMutator(ctx android.BottomUpMutatorContext) {
 			launcherSharedLibDeps = append(launcherSharedLibDeps, "libc++")
 
 		case pyVersion3:
-			stdLib = "py3-stdlib"
+			prefix := cc.HermeticPythonVersions[p.hermeticVersion()]
+			stdLib = prefix + "-stdlib"
 
-			launcherModule = "py3-launcher"
+			launcherModule = prefix + "-launcher"
 			if p.bootstrapper.autorun() {
-				launcherModule = "py3-launcher-autorun"
+				launcherModule = prefix + "-launcher-autorun"
 			}
 			if ctx.Config().HostStaticBinaries() && ctx.Target().Os == android.LinuxMusl {
 				launcherModule += "-static"
@@ -461,6 +511,10 @@ func (p *Module) DepsMutator(ctx android.BottomUpMutatorContext) {
 		ctx.AddFarVariationDependencies(ctx.Target().Variations(), launcherSharedLibTag, launcherSharedLibDeps...)
 	}
 
+	// Native extensions are shared libraries built for the same target as this module.
+	cextVariations := append(ctx.Target().Variations(), blueprint.Variation{Mutator: "link", Variation: "shared"})
+	ctx.AddFarVariationDependencies(cextVariations, cextTag, p.properties.Cexts...)
+
 	// Emulate the data property for java_data but with the arch variation overridden to "common"
 	// so that it can point to java modules.
 	javaDataVariation := []blueprint.Variation{{"arch", android.Common.String()}}
@@ -519,6 +573,23 @@ func (p *Module) generatePythonBuildActions(ctx android.ModuleContext) {
 		expandedData = append(expandedData, android.OutputFilesForModule(ctx, javaData, "")...)
 	}
 
+	// Copy native extensions so that they are placed at the root of pkg_path.
+	for _, cext := range ctx.GetDirectDepsWithTag(cextTag) {
+		for _, src := range android.OutputFilesForModule(ctx, cext, "") {
+			if !strings.HasSuffix(src.Base(), ".so") {
+				ctx.PropertyErrorf("cexts", "module %q is not a native extension", ctx.OtherModuleName(cext))
+				continue
+			}
+			out := android.PathForModuleOut(ctx, "cexts", src.Base()).OutputPath.WithoutRel()
+			ctx.Build(pctx, android.BuildParams{
+				Rule:   android.Cp,
+				Input:  src,
+				Output: out,
+			})
+			expandedData = append(expandedData, out)
+		}
+	}
+
 	// Validate pkg_path property
 	pkgPath := String(p.properties.Pkg_path)
 	if pkgPath != "" {
@@ -709,6 +780,8 @@ func (p *Module) collectPathsFromTransitiveDeps(ctx android.ModuleContext) {
 		destToPyData[path.dest] = path.src.String()
 	}
 
+	p.checkNativeExtensions(ctx, p.dataPathMappings, ctx.ModuleName())
+
 	seen := make(map[android.Module]bool)
 
 	// visit all its dependencies in depth first.
@@ -739,12 +812,28 @@ func (p *Module) collectPathsFromTransitiveDeps(ctx android.ModuleContext) {
 				checkForDuplicateOutputPath(ctx, destToPyData,
 					path.dest, path.src.String(), ctx.ModuleName(), ctx.OtherModuleName(child))
 			}
+			p.checkNativeExtensions(ctx, data, ctx.OtherModuleName(child))
 			p.depsSrcsZips = append(p.depsSrcsZips, dep.getSrcsZip())
 		}
 		return true
 	})
 }
 
+// checkNativeExtensions checks that native extensions in the data files of module depName were built
+// for the hermetic CPython interpreter embedded in this module, as an extension built against a
+// different version's ABI would fail to import at runtime.
+func (p *Module) checkNativeExtensions(ctx android.ModuleContext, data []pathMapping, depName string) {
+	if !p.isEmbeddedLauncherEnabled() || p.properties.Actual_version != pyVersion3 {
+		return
+	}
+	for _, path := range data {
+		if version, ok := nativeExtensionVersion(path.dest); ok && version != p.hermeticVersion() {
+			ctx.ModuleErrorf("native extension %q in module %s is built for Python %s, but the embedded interpreter is Python %s",
+				path.dest, depName, version, p.hermeticVersion())
+		}
+	}
+}
+
 // chckForDuplicateOutputPath checks whether outputPath has already been included in map m, which
 // would result in two files being placed in the same location.
 // If there is a duplicate path, an error is thrown and true is returned
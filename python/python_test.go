@@ -22,6 +22,7 @@ import (
 	"testing"
 
 	"android/soong/android"
+	"android/soong/cc"
 )
 
 type pyModule struct {
@@ -378,3 +379,148 @@ func expectModule(t *testing.T, ctx *android.TestContext, name, variant, expecte
 func TestMain(m *testing.M) {
 	os.Exit(m.Run())
 }
+
+func TestPythonHermeticVersionErrors(t *testing.T) {
+	testCases := []struct {
+		desc string
+		bp   string
+		err  string
+	}{
+		{
+			desc: "unsupported version",
+			bp: `
+				python_binary_host {
+					name: "bin",
+					srcs: ["bin.py"],
+					version: {
+						py3: {
+							embedded_launcher: true,
+							hermetic_version: "3.9",
+						},
+					},
+				}`,
+			err: `version.py3.hermetic_version: unsupported version "3.9"`,
+		},
+		{
+			desc: "without embedded launcher",
+			bp: `
+				python_binary_host {
+					name: "bin",
+					srcs: ["bin.py"],
+					version: {
+						py3: {
+							hermetic_version: "3.11",
+						},
+					},
+				}`,
+			err: `version.py3.hermetic_version: requires embedded_launcher`,
+		},
+		{
+			desc: "python 2",
+			bp: `
+				python_binary_host {
+					name: "bin",
+					srcs: ["bin.py"],
+					version: {
+						py2: {
+							enabled: true,
+							embedded_launcher: true,
+							hermetic_version: "3.11",
+						},
+						py3: {
+							enabled: false,
+						},
+					},
+				}`,
+			err: `version.py2.hermetic_version: is only supported for Python 3`,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			android.GroupFixturePreparers(
+				android.PrepareForTestWithDefaults,
+				PrepareForTestWithPythonBuildComponents,
+				android.FixtureAddFile("bin.py", nil),
+			).ExtendWithErrorHandler(android.FixtureExpectsAtLeastOneErrorMatchingPattern(regexp.QuoteMeta(tc.err))).
+				RunTestWithBp(t, tc.bp)
+		})
+	}
+}
+
+func TestPythonTestSuiteInfo(t *testing.T) {
+	result := android.GroupFixturePreparers(
+		android.PrepareForTestWithDefaults,
+		PrepareForTestWithPythonBuildComponents,
+		android.FixtureAddFile("test.py", nil),
+		android.FixtureAddFile("data.txt", nil),
+	).RunTestWithBp(t, `
+		python_test_host {
+			name: "test",
+			srcs: ["test.py"],
+			data: ["data.txt"],
+			test_suites: ["general-tests"],
+		}`)
+
+	test := result.ModuleForTests("test", "PY3").Module()
+	info := result.ModuleProvider(test, android.TestSuiteInfoProvider).(android.TestSuiteInfo)
+	android.AssertArrayString(t, "test suites", []string{"general-tests"}, info.TestSuites)
+	android.AssertPathRelativeToTopEquals(t, "output file",
+		android.PathRelativeToTop(test.(*Module).installSource.Path()), info.OutputFile)
+	android.AssertPathRelativeToTopEquals(t, "data", "data.txt", info.Data["data.txt"])
+	android.AssertPathRelativeToTopEquals(t, "test config", "out/soong/.intermediates/test/PY3/test.config",
+		info.TestConfig)
+}
+
+func TestNativeExtensionVersion(t *testing.T) {
+	testCases := []struct {
+		path    string
+		version string
+		ok      bool
+	}{
+		{"a/foo.cpython-310-x86_64-linux-gnu.so", "3.10", true},
+		{"foo.cpython-311-x86_64-linux-gnu.so", "3.11", true},
+		{"foo.cpython-39.so", "3.9", true},
+		{"foo.so", "", false},
+		{"foo.cpython-311.so/bar.py", "", false},
+	}
+	for _, tc := range testCases {
+		version, ok := nativeExtensionVersion(tc.path)
+		if version != tc.version || ok != tc.ok {
+			t.Errorf("nativeExtensionVersion(%q) = %q, %v; want %q, %v", tc.path, version, ok, tc.version, tc.ok)
+		}
+	}
+}
+
+func TestPythonCexts(t *testing.T) {
+	result := android.GroupFixturePreparers(
+		android.PrepareForTestWithDefaults,
+		PrepareForTestWithPythonBuildComponents,
+		cc.PrepareForTestWithCcDefaultModules,
+		android.FixtureRegisterWithContext(cc.RegisterPythonCextBuildComponents),
+		android.FixtureAddFile("lib.py", nil),
+		android.FixtureAddFile("foo.c", nil),
+	).RunTestWithBp(t, `
+		python_library_host {
+			name: "lib",
+			pkg_path: "a",
+			srcs: ["lib.py"],
+			cexts: ["foo"],
+		}
+		python_cext {
+			name: "foo",
+			srcs: ["foo.c"],
+			host_supported: true,
+		}
+		cc_library_headers {
+			name: "py3-headers",
+			host_supported: true,
+		}
+	`)
+
+	lib := result.ModuleForTests("lib", "PY3").Module().(*Module)
+	var data []string
+	for _, path := range lib.dataPathMappings {
+		data = append(data, path.dest)
+	}
+	android.AssertDeepEquals(t, "data", []string{"a/foo.cpython-310-x86_64-linux-gnu.so"}, data)
+}
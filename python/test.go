@@ -96,6 +96,15 @@ func (test *testDecorator) install(ctx android.ModuleContext, file android.Path)
 			test.data = append(test.data, android.DataPath{SrcPath: javaDataSrcPath})
 		}
 	}
+
+	if testSuites := test.binaryDecorator.binaryProperties.Test_suites; len(testSuites) > 0 {
+		ctx.SetProvider(android.TestSuiteInfoProvider, android.TestSuiteInfo{
+			TestSuites: testSuites,
+			OutputFile: file,
+			Data:       android.TestSuiteData(test.data),
+			TestConfig: test.testConfig,
+		})
+	}
 }
 
 func NewTest(hod android.HostOrDeviceSupported) *Module {
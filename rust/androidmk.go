@@ -201,6 +201,9 @@ func (compiler *baseCompiler) AndroidMk(ctx AndroidMkContext, ret *android.Andro
 			entries.SetString("LOCAL_MODULE_SUFFIX", suffix)
 			entries.SetString("LOCAL_MODULE_PATH", path)
 			entries.SetString("LOCAL_MODULE_STEM", stem)
+			if compiler.coverageOutputFile.Valid() {
+				entries.SetString("LOCAL_PREBUILT_COVERAGE_ARCHIVE", compiler.coverageOutputFile.String())
+			}
 		})
 }
 
@@ -149,6 +149,10 @@ func (binary *binaryDecorator) compile(ctx ModuleContext, flags Flags, deps Path
 
 	TransformSrcToBinary(ctx, srcPath, deps, flags, outputFile)
 
+	if flags.Coverage {
+		binary.baseCompiler.coverageOutputFile = transformCoverageMappingToZip(ctx, outputFile, binary.getStem(ctx))
+	}
+
 	return ret
 }
 
@@ -81,6 +81,11 @@ type BindgenProperties struct {
 	//
 	// "my_bindgen [flags] wrapper_header.h -o [output_path] -- [clang flags]"
 	Custom_bindgen string
+
+	// module name of a cc module whose compiler flags should be passed to clang, e.g. the library wrapped by these
+	// bindings. This includes its cflags, include directories, sanitizer flags and exported include directories, and
+	// its c_std or cpp_std unless c_std or cpp_std is set on this module.
+	Cflags_from *string
 }
 
 type bindgenDecorator struct {
@@ -129,6 +134,20 @@ func (b *bindgenDecorator) getStdVersion(ctx ModuleContext, src android.Path) (s
 	return stdVersion, isCpp
 }
 
+// splitStdFlag returns the version from the last -std flag in flags, along with the remaining flags.
+func splitStdFlag(flags []string) (string, []string) {
+	stdVersion := ""
+	var rest []string
+	for _, flag := range flags {
+		if strings.HasPrefix(flag, "-std=") {
+			stdVersion = strings.TrimPrefix(flag, "-std=")
+		} else {
+			rest = append(rest, flag)
+		}
+	}
+	return stdVersion, rest
+}
+
 func (b *bindgenDecorator) GenerateSource(ctx ModuleContext, deps PathDeps) android.Path {
 	ccToolchain := ctx.RustModule().ccToolchain(ctx)
 
@@ -182,6 +201,30 @@ func (b *bindgenDecorator) GenerateSource(ctx ModuleContext, deps PathDeps) andr
 		cflags = append(cflags, "-isystem "+include.String())
 	}
 
+	// Clang flags from the cc module named in cflags_from
+	var ccFlags cc.CompilerFlagsInfo
+	if name := String(b.Properties.Cflags_from); name != "" {
+		if dep := ctx.GetDirectDepWithTag(name, bindgenCflagsDepTag); dep != nil {
+			if !ctx.OtherModuleHasProvider(dep, cc.CompilerFlagsInfoProvider) {
+				ctx.PropertyErrorf("cflags_from", "%q is not a cc module with sources", name)
+			} else {
+				ccFlags = ctx.OtherModuleProvider(dep, cc.CompilerFlagsInfoProvider).(cc.CompilerFlagsInfo)
+				exportedInfo := ctx.OtherModuleProvider(dep, cc.FlagExporterInfoProvider).(cc.FlagExporterInfo)
+
+				cflags = append(cflags, ccFlags.CommonFlags...)
+				cflags = append(cflags, exportedInfo.Flags...)
+				for _, include := range exportedInfo.IncludeDirs {
+					cflags = append(cflags, "-I"+include.String())
+				}
+				for _, include := range exportedInfo.SystemIncludeDirs {
+					cflags = append(cflags, "-isystem "+include.String())
+				}
+				implicits = append(implicits, ccFlags.Deps...)
+				implicits = append(implicits, exportedInfo.GeneratedHeaders...)
+			}
+		}
+	}
+
 	esc := proptools.NinjaAndShellEscapeList
 
 	// Filter out invalid cflags
@@ -213,17 +256,30 @@ func (b *bindgenDecorator) GenerateSource(ctx ModuleContext, deps PathDeps) andr
 
 	// Add C std version flag
 	stdVersion, isCpp := b.getStdVersion(ctx, wrapperFile.Path())
+	ccLangFlags := ccFlags.ConlyFlags
+	if isCpp {
+		ccLangFlags = ccFlags.CppFlags
+	}
+	ccStdVersion, ccLangFlags := splitStdFlag(ccLangFlags)
+	if ccStdVersion != "" && b.ClangProperties.C_std == nil && b.ClangProperties.Cpp_std == nil {
+		stdVersion = ccStdVersion
+	}
 	cflags = append(cflags, "-std="+stdVersion)
 
 	// Specify the header source language to avoid ambiguity.
 	if isCpp {
 		cflags = append(cflags, "-x c++")
-		// Add any C++ only flags.
-		cflags = append(cflags, esc(b.ClangProperties.Cppflags)...)
 	} else {
 		cflags = append(cflags, "-x c")
 	}
 
+	// Add any language specific flags from cflags_from.
+	cflags = append(cflags, ccLangFlags...)
+	if isCpp {
+		// Add any C++ only flags.
+		cflags = append(cflags, esc(b.ClangProperties.Cppflags)...)
+	}
+
 	outputFile := android.PathForModuleOut(ctx, b.BaseSourceProvider.getStem(ctx)+".rs")
 
 	var cmd, cmdDesc string
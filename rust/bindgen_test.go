@@ -143,6 +143,56 @@ func TestRustBindgenStdVersions(t *testing.T) {
 	}
 }
 
+func TestRustBindgenCflagsFrom(t *testing.T) {
+	ctx := testRust(t, `
+		rust_bindgen {
+			name: "libbindgen",
+			wrapper_src: "src/any.h",
+			crate_name: "bindgen",
+			stem: "libbindgen",
+			source_stem: "bindings",
+			cflags_from: "libfoo",
+		}
+		rust_bindgen {
+			name: "libbindgen_cstd",
+			wrapper_src: "src/any.h",
+			crate_name: "bindgen",
+			stem: "libbindgen",
+			source_stem: "bindings",
+			cflags_from: "libfoo",
+			c_std: "local_std",
+		}
+		cc_library {
+			name: "libfoo",
+			cflags: ["-DFOO"],
+			conlyflags: ["-DFOO_C"],
+			cppflags: ["-DFOO_CPP"],
+			c_std: "foo_std",
+			local_include_dirs: ["foo_local_include"],
+			export_include_dirs: ["foo_include"],
+		}
+	`)
+
+	libbindgen := ctx.ModuleForTests("libbindgen", "android_arm64_armv8-a_source").Output("bindings.rs")
+	cflags := libbindgen.Args["cflags"]
+	for _, flag := range []string{"-DFOO", "-DFOO_C", "-Ifoo_local_include", "-Ifoo_include", "-std=foo_std"} {
+		if !strings.Contains(cflags, flag) {
+			t.Errorf("missing %q from cflags_from module in rust_bindgen rule: cflags %#v", flag, cflags)
+		}
+	}
+	if strings.Contains(cflags, "-DFOO_CPP") {
+		t.Errorf("unexpected C++ flag from cflags_from module for C header: cflags %#v", cflags)
+	}
+
+	libbindgenCstd := ctx.ModuleForTests("libbindgen_cstd", "android_arm64_armv8-a_source").Output("bindings.rs")
+	if !strings.Contains(libbindgenCstd.Args["cflags"], "-std=local_std") {
+		t.Errorf("c_std value not passed in to rust_bindgen as a clang flag: cflags %#v", libbindgenCstd.Args["cflags"])
+	}
+	if strings.Contains(libbindgenCstd.Args["cflags"], "-std=foo_std") {
+		t.Errorf("c_std from cflags_from module should be overridden by c_std: cflags %#v", libbindgenCstd.Args["cflags"])
+	}
+}
+
 func TestBindgenDisallowedFlags(t *testing.T) {
 	// Make sure passing '-x c++' to cflags generates an error
 	testRustError(t, "cflags: -x c\\+\\+ should not be specified in cflags.*", `
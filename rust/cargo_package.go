@@ -0,0 +1,455 @@
+// Copyright 2022 The Android Open Source Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rust
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/google/blueprint/proptools"
+
+	"android/soong/android"
+)
+
+func init() {
+	android.RegisterModuleType("rust_cargo_package", CargoPackageFactory)
+}
+
+type cargoPackageProperties struct {
+	// path to the Cargo.toml manifest of the root package, relative to this module's directory.
+	// Defaults to "Cargo.toml".
+	Cargo_toml *string
+
+	// path to the Cargo.lock file pinning the versions of the dependencies of the root package,
+	// relative to this module's directory. The locked versions are used to locate the vendored
+	// sources of each dependency.
+	Cargo_lock *string
+
+	// directory containing the vendored sources of the dependencies, as produced by `cargo vendor`,
+	// relative to this module's directory. Defaults to "vendor".
+	Vendor_dir *string
+
+	// list of additional features to enable for the root package. The "default" feature is always
+	// enabled.
+	Features []string
+
+	// list of rust_defaults modules applied to every generated module.
+	Module_defaults []string
+}
+
+// cargoPackageModule creates the rust_library and rust_proc_macro modules for a Cargo package and
+// its vendored dependencies. It does not generate any build actions itself.
+type cargoPackageModule struct {
+	android.ModuleBase
+
+	properties cargoPackageProperties
+}
+
+func (c *cargoPackageModule) GenerateAndroidBuildActions(ctx android.ModuleContext) {
+	// no need to implement
+}
+
+// rust_cargo_package reads a Cargo.toml manifest at analysis time and creates a module for the
+// library crate of the package, along with a module for each dependency whose sources have been
+// vendored alongside it. Generated modules are named "lib<crate_name>", matching the names used by
+// cargo2android, so dependencies that are not vendored resolve to existing modules in the tree.
+//
+// Features are resolved the same way Cargo resolves them, starting from the "default" feature of
+// the root package. Dev-dependencies, build-dependencies and target-specific dependencies are not
+// supported, nor are build scripts.
+func CargoPackageFactory() android.Module {
+	module := &cargoPackageModule{}
+	module.AddProperties(&module.properties)
+	android.InitAndroidModule(module)
+	android.AddLoadHook(module, func(ctx android.LoadHookContext) { cargoPackageHook(ctx, module) })
+	return module
+}
+
+// cargoPackage is the subset of a Cargo.toml manifest needed to generate a module for a package.
+type cargoPackage struct {
+	name      string
+	version   string
+	edition   string
+	crateName string
+	procMacro bool
+
+	// directory of the package, relative to the directory of the rust_cargo_package module.
+	dir     string
+	libPath string
+
+	deps     []cargoDependency
+	features map[string][]string
+}
+
+type cargoDependency struct {
+	// name of the dependency in the [dependencies] table, which is the name of the extern crate.
+	name string
+	// name of the package, which differs from name if the dependency is renamed.
+	pkg string
+	// path to the package, relative to the directory of the depending package, for path dependencies.
+	path string
+
+	optional        bool
+	defaultFeatures bool
+	features        []string
+}
+
+func (p *cargoPackage) dep(name string) *cargoDependency {
+	for i := range p.deps {
+		if p.deps[i].name == name {
+			return &p.deps[i]
+		}
+	}
+	return nil
+}
+
+// cargoCrateName returns the name of the crate built from a package, which replaces dashes with
+// underscores.
+func cargoCrateName(pkg string) string {
+	return strings.Replace(pkg, "-", "_", -1)
+}
+
+func newCargoPackage(manifest tomlTable, dir string) (*cargoPackage, error) {
+	pkg := manifest.getTable("package")
+	if pkg == nil || pkg.getString("name") == "" {
+		return nil, fmt.Errorf("missing [package] name")
+	}
+
+	p := &cargoPackage{
+		name:      pkg.getString("name"),
+		version:   pkg.getString("version"),
+		edition:   pkg.getString("edition"),
+		dir:       dir,
+		libPath:   "src/lib.rs",
+		crateName: cargoCrateName(pkg.getString("name")),
+		features:  make(map[string][]string),
+	}
+	if p.edition == "" {
+		// Cargo defaults to the 2015 edition when none is specified.
+		p.edition = "2015"
+	}
+
+	if lib := manifest.getTable("lib"); lib != nil {
+		if path := lib.getString("path"); path != "" {
+			p.libPath = path
+		}
+		if name := lib.getString("name"); name != "" {
+			p.crateName = cargoCrateName(name)
+		}
+		p.procMacro = lib.getBool("proc-macro", false) || lib.getBool("proc_macro", false)
+	}
+
+	features := manifest.getTable("features")
+	for name := range features {
+		p.features[name] = features.getStrings(name)
+	}
+
+	deps := manifest.getTable("dependencies")
+	for _, name := range android.SortedStringKeys(deps) {
+		d := cargoDependency{
+			name:            name,
+			pkg:             name,
+			defaultFeatures: true,
+		}
+		switch v := deps[name].(type) {
+		case string:
+		case tomlTable:
+			if pkg := v.getString("package"); pkg != "" {
+				d.pkg = pkg
+			}
+			d.path = v.getString("path")
+			d.optional = v.getBool("optional", false)
+			d.defaultFeatures = v.getBool("default-features", true) && v.getBool("default_features", true)
+			d.features = v.getStrings("features")
+		default:
+			return nil, fmt.Errorf("invalid dependency %q", name)
+		}
+		if cargoCrateName(d.pkg) != cargoCrateName(d.name) {
+			return nil, fmt.Errorf("renamed dependency %q of package %q is not supported", d.name, d.pkg)
+		}
+		p.deps = append(p.deps, d)
+	}
+
+	return p, nil
+}
+
+// cargoPackageLoader loads the manifest of a package and, transitively, the manifests of all of its
+// path and vendored dependencies.
+type cargoPackageLoader struct {
+	ctx       android.LoadHookContext
+	vendorDir string
+
+	// versions of each package in Cargo.lock.
+	lockedVersions map[string][]string
+
+	packages   map[string]*cargoPackage
+	loadedDirs map[string]*cargoPackage
+}
+
+func (l *cargoPackageLoader) readToml(property, path string) tomlTable {
+	// Glob for the file rather than reading it directly so that the module is reloaded once the
+	// file is added.
+	fullPath := filepath.Join(l.ctx.ModuleDir(), path)
+	if matches, err := l.ctx.GlobWithDeps(fullPath, nil); err != nil {
+		l.ctx.PropertyErrorf(property, "%s", err)
+		return nil
+	} else if len(matches) == 0 {
+		l.ctx.PropertyErrorf(property, "%q does not exist", fullPath)
+		return nil
+	}
+	data, err := l.ctx.Config().ReadSourceFile(l.ctx, fullPath)
+	if err != nil {
+		l.ctx.PropertyErrorf(property, "failed to read %q: %s", path, err)
+		return nil
+	}
+	table, err := parseToml(data)
+	if err != nil {
+		l.ctx.PropertyErrorf(property, "failed to parse %q: %s", path, err)
+		return nil
+	}
+	return table
+}
+
+func (l *cargoPackageLoader) loadLock(path string) {
+	lock := l.readToml("cargo_lock", path)
+	for _, pkg := range lock.getTables("package") {
+		name := pkg.getString("name")
+		l.lockedVersions[name] = append(l.lockedVersions[name], pkg.getString("version"))
+	}
+}
+
+// vendoredDir returns the directory containing the vendored sources of a package, or "" if the
+// package has not been vendored.
+func (l *cargoPackageLoader) vendoredDir(pkg string) string {
+	versions := l.lockedVersions[pkg]
+	if len(versions) > 1 {
+		l.ctx.PropertyErrorf("cargo_lock", "multiple versions of package %q are not supported: %q",
+			pkg, versions)
+		return ""
+	}
+
+	// `cargo vendor` only appends the version to the directory name when multiple versions of a
+	// package are vendored, but check for both.
+	candidates := []string{filepath.Join(l.vendorDir, pkg)}
+	for _, version := range versions {
+		candidates = append(candidates, filepath.Join(l.vendorDir, pkg+"-"+version))
+	}
+	for _, dir := range candidates {
+		matches, err := l.ctx.GlobWithDeps(filepath.Join(l.ctx.ModuleDir(), dir, "Cargo.toml"), nil)
+		if err != nil {
+			l.ctx.PropertyErrorf("vendor_dir", "%s", err)
+			return ""
+		}
+		if len(matches) > 0 {
+			return dir
+		}
+	}
+	return ""
+}
+
+// load reads the manifest of a package and of its path and vendored dependencies.
+func (l *cargoPackageLoader) load(property, manifestPath string) *cargoPackage {
+	dir := filepath.Dir(filepath.Clean(manifestPath))
+	if p, ok := l.loadedDirs[dir]; ok {
+		return p
+	}
+	l.loadedDirs[dir] = nil
+
+	manifest := l.readToml(property, manifestPath)
+	if manifest == nil {
+		return nil
+	}
+	p, err := newCargoPackage(manifest, dir)
+	if err != nil {
+		l.ctx.PropertyErrorf(property, "%q: %s", manifestPath, err)
+		return nil
+	}
+	if existing, ok := l.packages[p.name]; ok {
+		l.ctx.PropertyErrorf(property, "package %q is found in both %q and %q", p.name, existing.dir, dir)
+		return nil
+	}
+	l.packages[p.name] = p
+	l.loadedDirs[dir] = p
+
+	for _, d := range p.deps {
+		if d.path != "" {
+			l.load(property, filepath.Join(dir, d.path, "Cargo.toml"))
+		} else if vendored := l.vendoredDir(d.pkg); vendored != "" {
+			l.load("vendor_dir", filepath.Join(vendored, "Cargo.toml"))
+		}
+	}
+	return p
+}
+
+// cargoFeatureResolver computes the features and optional dependencies enabled for each package
+// reachable from the root package, following the rules Cargo applies for a single build.
+type cargoFeatureResolver struct {
+	packages map[string]*cargoPackage
+
+	features map[string]map[string]bool
+	deps     map[string]map[string]bool
+}
+
+func (r *cargoFeatureResolver) enablePackage(name string, features []string, defaultFeatures bool) {
+	p := r.packages[name]
+	if p == nil {
+		// Not vendored; the dependency is provided by an existing module.
+		return
+	}
+	if r.features[name] == nil {
+		r.features[name] = make(map[string]bool)
+		r.deps[name] = make(map[string]bool)
+		for _, d := range p.deps {
+			if !d.optional {
+				r.enableDep(p, d.name)
+			}
+		}
+	}
+	if _, ok := p.features["default"]; ok && defaultFeatures {
+		r.enableFeature(p, "default")
+	}
+	for _, f := range features {
+		r.enableFeature(p, f)
+	}
+}
+
+func (r *cargoFeatureResolver) enableDep(p *cargoPackage, name string) {
+	d := p.dep(name)
+	if d == nil || r.deps[p.name][name] {
+		return
+	}
+	r.deps[p.name][name] = true
+	r.enablePackage(d.pkg, d.features, d.defaultFeatures)
+}
+
+func (r *cargoFeatureResolver) enableFeature(p *cargoPackage, feature string) {
+	if strings.HasPrefix(feature, "dep:") {
+		r.enableDep(p, strings.TrimPrefix(feature, "dep:"))
+		return
+	}
+
+	if i := strings.Index(feature, "/"); i >= 0 {
+		depName, depFeature := feature[:i], feature[i+1:]
+		// "dep?/feature" only enables the feature if the dependency is otherwise enabled.
+		if strings.HasSuffix(depName, "?") {
+			depName = strings.TrimSuffix(depName, "?")
+		} else {
+			r.enableDep(p, depName)
+			if d := p.dep(depName); d != nil && d.optional {
+				r.enableFeature(p, depName)
+			}
+		}
+		if d := p.dep(depName); d != nil && r.deps[p.name][depName] {
+			r.enablePackage(d.pkg, []string{depFeature}, d.defaultFeatures)
+		}
+		return
+	}
+
+	if r.features[p.name][feature] {
+		return
+	}
+	r.features[p.name][feature] = true
+
+	if implied, ok := p.features[feature]; ok {
+		for _, f := range implied {
+			r.enableFeature(p, f)
+		}
+	} else if d := p.dep(feature); d != nil && d.optional {
+		// Optional dependencies define an implicit feature of the same name.
+		r.enableDep(p, feature)
+	}
+}
+
+func cargoPackageHook(ctx android.LoadHookContext, c *cargoPackageModule) {
+	loader := &cargoPackageLoader{
+		ctx:            ctx,
+		vendorDir:      proptools.StringDefault(c.properties.Vendor_dir, "vendor"),
+		lockedVersions: make(map[string][]string),
+		packages:       make(map[string]*cargoPackage),
+		loadedDirs:     make(map[string]*cargoPackage),
+	}
+
+	if lock := proptools.String(c.properties.Cargo_lock); lock != "" {
+		loader.loadLock(lock)
+	}
+
+	root := loader.load("cargo_toml", proptools.StringDefault(c.properties.Cargo_toml, "Cargo.toml"))
+	if root == nil || ctx.Failed() {
+		return
+	}
+
+	resolver := &cargoFeatureResolver{
+		packages: loader.packages,
+		features: make(map[string]map[string]bool),
+		deps:     make(map[string]map[string]bool),
+	}
+	resolver.enablePackage(root.name, c.properties.Features, true)
+
+	for _, name := range android.SortedStringKeys(resolver.features) {
+		createCargoPackageModule(ctx, c, loader.packages[name], resolver)
+	}
+}
+
+func createCargoPackageModule(ctx android.LoadHookContext, c *cargoPackageModule, p *cargoPackage,
+	resolver *cargoFeatureResolver) {
+
+	props := struct {
+		Name              *string
+		Crate_name        string
+		Srcs              []string
+		Edition           *string
+		Features          []string
+		Rustlibs          []string
+		Proc_macros       []string
+		Cargo_env_compat  *bool
+		Cargo_pkg_version *string
+		Defaults          []string
+	}{}
+	props.Name = proptools.StringPtr("lib" + p.crateName)
+	props.Crate_name = p.crateName
+	props.Srcs = []string{filepath.Join(p.dir, p.libPath)}
+	props.Edition = proptools.StringPtr(p.edition)
+	props.Features = android.SortedStringKeys(resolver.features[p.name])
+	props.Cargo_env_compat = proptools.BoolPtr(true)
+	props.Cargo_pkg_version = proptools.StringPtr(p.version)
+	props.Defaults = c.properties.Module_defaults
+
+	for _, name := range android.SortedStringKeys(resolver.deps[p.name]) {
+		d := p.dep(name)
+		if dep, ok := resolver.packages[d.pkg]; ok {
+			if dep.procMacro {
+				props.Proc_macros = append(props.Proc_macros, "lib"+dep.crateName)
+			} else {
+				props.Rustlibs = append(props.Rustlibs, "lib"+dep.crateName)
+			}
+		} else {
+			props.Rustlibs = append(props.Rustlibs, "lib"+cargoCrateName(d.pkg))
+		}
+	}
+
+	if p.procMacro {
+		ctx.CreateModule(ProcMacroFactory, &props)
+	} else {
+		// Crates from crates.io are generally usable on both host and device, and libraries used by
+		// proc macros need a host variant.
+		hostProps := struct {
+			Host_supported *bool
+		}{
+			Host_supported: proptools.BoolPtr(true),
+		}
+		ctx.CreateModule(RustLibraryFactory, &props, &hostProps)
+	}
+}
@@ -0,0 +1,279 @@
+// Copyright 2022 The Android Open Source Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rust
+
+import (
+	"strings"
+	"testing"
+
+	"android/soong/android"
+)
+
+func TestParseToml(t *testing.T) {
+	table, err := parseToml([]byte(`
+# comment
+[package]
+name = "foo-bar" # trailing comment
+version = '1.2.3'
+description = """
+multi \
+  line"""
+
+[lib]
+proc-macro = true
+
+[dependencies]
+bar = "1.0"
+baz = { version = "2", features = ["a", "b"], default-features = false }
+quux.optional = true
+
+[features]
+default = [
+  "baz",
+  "bar/x",
+]
+
+[[bin]]
+name = "a"
+
+[[bin]]
+name = "b"
+`))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	pkg := table.getTable("package")
+	android.AssertStringEquals(t, "package name", "foo-bar", pkg.getString("name"))
+	android.AssertStringEquals(t, "package version", "1.2.3", pkg.getString("version"))
+	android.AssertStringEquals(t, "package description", "multi line", pkg.getString("description"))
+	android.AssertBoolEquals(t, "proc-macro", true, table.getTable("lib").getBool("proc-macro", false))
+
+	deps := table.getTable("dependencies")
+	android.AssertStringEquals(t, "bar", "1.0", deps.getString("bar"))
+	android.AssertDeepEquals(t, "baz features", []string{"a", "b"}, deps.getTable("baz").getStrings("features"))
+	android.AssertBoolEquals(t, "baz default-features", false, deps.getTable("baz").getBool("default-features", true))
+	android.AssertBoolEquals(t, "quux optional", true, deps.getTable("quux").getBool("optional", false))
+
+	android.AssertDeepEquals(t, "default features", []string{"baz", "bar/x"},
+		table.getTable("features").getStrings("default"))
+
+	var bins []string
+	for _, bin := range table.getTables("bin") {
+		bins = append(bins, bin.getString("name"))
+	}
+	android.AssertDeepEquals(t, "bins", []string{"a", "b"}, bins)
+}
+
+func TestParseTomlErrors(t *testing.T) {
+	testCases := []struct {
+		name, toml, err string
+	}{
+		{"duplicate key", "a = 1\na = 2\n", "line 2: duplicate key \"a\""},
+		{"unterminated string", "a = \"b\n", "line 1: newline in string"},
+		{"missing equals", "a 1\n", "line 1: expected '=' after key \"a\""},
+		{"table redefined as array", "[a]\n[[a]]\n", "line 2: \"a\" is already defined as a table"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := parseToml([]byte(tc.toml))
+			if err == nil || err.Error() != tc.err {
+				t.Errorf("expected error %q, got %v", tc.err, err)
+			}
+		})
+	}
+}
+
+var cargoPackageMockedFiles = android.MockFS{
+	"cargo/Cargo.toml": []byte(`
+[package]
+name = "foo"
+version = "0.1.0"
+edition = "2021"
+
+[dependencies]
+bar = { version = "1", features = ["extra"] }
+foo-derive = "0.2"
+serde = { version = "1", optional = true }
+
+[features]
+default = ["std"]
+std = []
+`),
+	"cargo/Cargo.lock": []byte(`
+version = 3
+
+[[package]]
+name = "bar"
+version = "1.0.2"
+source = "registry+https://github.com/rust-lang/crates.io-index"
+
+[[package]]
+name = "foo"
+version = "0.1.0"
+dependencies = [
+ "bar",
+ "foo-derive",
+]
+
+[[package]]
+name = "foo-derive"
+version = "0.2.0"
+source = "registry+https://github.com/rust-lang/crates.io-index"
+`),
+	"cargo/src/lib.rs": nil,
+	"cargo/vendor/bar/Cargo.toml": []byte(`
+[package]
+name = "bar"
+version = "1.0.2"
+
+[dependencies]
+itoa = "1"
+
+[features]
+default = []
+extra = ["itoa/std"]
+`),
+	"cargo/vendor/bar/src/lib.rs": nil,
+	"cargo/vendor/foo-derive-0.2.0/Cargo.toml": []byte(`
+[package]
+name = "foo-derive"
+version = "0.2.0"
+edition = "2018"
+
+[lib]
+proc-macro = true
+path = "lib.rs"
+`),
+	"cargo/vendor/foo-derive-0.2.0/lib.rs": nil,
+}
+
+func TestCargoPackage(t *testing.T) {
+	fs := android.MockFS{
+		"cargo/Android.bp": []byte(`
+			rust_cargo_package {
+				name: "foo_cargo",
+				cargo_lock: "Cargo.lock",
+			}
+		`),
+	}
+	fs.Merge(cargoPackageMockedFiles)
+	fs.Merge(rustMockedFiles)
+
+	ctx := testRustVndkFs(t, `
+		rust_library {
+			name: "libitoa",
+			crate_name: "itoa",
+			srcs: ["foo.rs"],
+			host_supported: true,
+		}
+	`, fs)
+
+	libfoo := ctx.ModuleForTests("libfoo", "android_arm64_armv8-a_dylib").Module().(*Module)
+	android.AssertStringEquals(t, "libfoo crate_name", "foo", libfoo.CrateName())
+	android.AssertDeepEquals(t, "libfoo features", []string{"default", "std"},
+		libfoo.compiler.(*libraryDecorator).baseCompiler.Properties.Features)
+	android.AssertStringEquals(t, "libfoo edition", "2021", libfoo.compiler.(*libraryDecorator).edition())
+	android.AssertStringEquals(t, "libfoo cargo_pkg_version", "0.1.0", libfoo.compiler.CargoPkgVersion())
+
+	libfooRustc := ctx.ModuleForTests("libfoo", "android_arm64_armv8-a_dylib").Rule("rustc")
+	if !strings.Contains(libfooRustc.Args["libFlags"], "--extern bar=") {
+		t.Errorf("missing vendored dependency on bar in libFlags: %#v", libfooRustc.Args["libFlags"])
+	}
+	if !strings.Contains(libfooRustc.Args["libFlags"], "--extern foo_derive=") {
+		t.Errorf("missing vendored proc macro dependency on foo_derive in libFlags: %#v", libfooRustc.Args["libFlags"])
+	}
+	if strings.Contains(libfooRustc.Args["libFlags"], "serde") {
+		t.Errorf("unexpected dependency on disabled optional dependency serde in libFlags: %#v", libfooRustc.Args["libFlags"])
+	}
+
+	libbar := ctx.ModuleForTests("libbar", "android_arm64_armv8-a_dylib").Module().(*Module)
+	android.AssertDeepEquals(t, "libbar features", []string{"default", "extra"},
+		libbar.compiler.(*libraryDecorator).baseCompiler.Properties.Features)
+	android.AssertStringEquals(t, "libbar edition", "2015", libbar.compiler.(*libraryDecorator).edition())
+	android.AssertDeepEquals(t, "libbar srcs", []string{"vendor/bar/src/lib.rs"},
+		libbar.compiler.(*libraryDecorator).baseCompiler.Properties.Srcs)
+
+	libfooDerive := ctx.ModuleForTests("libfoo_derive", "linux_glibc_x86_64").Module().(*Module)
+	if _, ok := libfooDerive.compiler.(*procMacroDecorator); !ok {
+		t.Errorf("expected libfoo_derive to be a proc macro")
+	}
+	android.AssertDeepEquals(t, "libfoo_derive srcs", []string{"vendor/foo-derive-0.2.0/lib.rs"},
+		libfooDerive.compiler.(*procMacroDecorator).baseCompiler.Properties.Srcs)
+}
+
+func TestCargoPackageErrors(t *testing.T) {
+	fs := android.MockFS{
+		"cargo/Android.bp": []byte(`
+			rust_cargo_package {
+				name: "foo_cargo",
+				cargo_lock: "Cargo.lock",
+			}
+		`),
+		"cargo/Cargo.toml": []byte(`
+[package]
+name = "foo"
+
+[dependencies]
+bar = "1"
+`),
+		"cargo/Cargo.lock": []byte(`
+[[package]]
+name = "bar"
+version = "1.0.0"
+
+[[package]]
+name = "bar"
+version = "2.0.0"
+`),
+	}
+
+	android.GroupFixturePreparers(
+		prepareForRustTest,
+		rustMockedFiles.AddToFixture(),
+		fs.AddToFixture(),
+	).ExtendWithErrorHandler(android.FixtureExpectsAtLeastOneErrorMatchingPattern(
+		`multiple versions of package "bar" are not supported`)).
+		RunTest(t)
+}
+
+func TestCargoPackageManifestPath(t *testing.T) {
+	fs := android.MockFS{
+		"cargo/Android.bp": []byte(`
+			rust_cargo_package {
+				name: "foo_cargo",
+				cargo_toml: "Cargo.android.toml",
+			}
+
+			rust_cargo_package {
+				name: "missing_cargo",
+				cargo_toml: "missing/Cargo.toml",
+			}
+		`),
+		"cargo/Cargo.android.toml": []byte(`
+[package]
+name = "foo"
+`),
+		"cargo/src/lib.rs": nil,
+	}
+
+	android.GroupFixturePreparers(
+		prepareForRustTest,
+		rustMockedFiles.AddToFixture(),
+		fs.AddToFixture(),
+	).ExtendWithErrorHandler(android.FixtureExpectsAllErrorsToMatchAPattern([]string{
+		`module "missing_cargo": cargo_toml: "cargo/missing/Cargo.toml" does not exist`,
+	})).RunTest(t)
+}
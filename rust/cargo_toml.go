@@ -0,0 +1,472 @@
+// Copyright 2022 The Android Open Source Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rust
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// tomlTable is a parsed TOML table. Values are one of string, bool, int64, []interface{} or
+// tomlTable. Arrays of tables ([[name]]) are stored as []interface{} of tomlTable.
+type tomlTable map[string]interface{}
+
+// parseToml parses the subset of TOML used by Cargo.toml and Cargo.lock files. Floats and
+// date-times are not needed by Cargo and are returned as unparsed strings.
+func parseToml(data []byte) (tomlTable, error) {
+	p := &tomlParser{data: data, line: 1}
+	root := tomlTable{}
+	current := root
+
+	for {
+		p.skipWhitespaceAndNewlines()
+		if p.eof() {
+			return root, nil
+		}
+
+		if p.peek() == '[' {
+			p.pos++
+			arrayOfTables := false
+			if p.peek() == '[' {
+				p.pos++
+				arrayOfTables = true
+			}
+			keys, err := p.parseKeyPath()
+			if err != nil {
+				return nil, err
+			}
+			p.skipWhitespace()
+			if !p.consume(']') || (arrayOfTables && !p.consume(']')) {
+				return nil, p.errorf("expected ']' after table name")
+			}
+			if current, err = tableForHeader(root, keys, arrayOfTables); err != nil {
+				return nil, p.errorf("%s", err)
+			}
+		} else {
+			keys, err := p.parseKeyPath()
+			if err != nil {
+				return nil, err
+			}
+			p.skipWhitespace()
+			if !p.consume('=') {
+				return nil, p.errorf("expected '=' after key %q", strings.Join(keys, "."))
+			}
+			p.skipWhitespace()
+			value, err := p.parseValue()
+			if err != nil {
+				return nil, err
+			}
+			if err := setTomlValue(current, keys, value); err != nil {
+				return nil, p.errorf("%s", err)
+			}
+		}
+
+		p.skipWhitespace()
+		if !p.eof() && p.peek() != '\n' && p.peek() != '\r' {
+			return nil, p.errorf("unexpected %q at end of line", p.peek())
+		}
+	}
+}
+
+// tableForHeader returns the table that a [a.b.c] or [[a.b.c]] header refers to, creating it and
+// any intermediate tables if necessary.
+func tableForHeader(root tomlTable, keys []string, arrayOfTables bool) (tomlTable, error) {
+	table := root
+	for i, key := range keys {
+		last := i == len(keys)-1
+		switch existing := table[key].(type) {
+		case nil:
+			if last && arrayOfTables {
+				next := tomlTable{}
+				table[key] = []interface{}{next}
+				return next, nil
+			}
+			next := tomlTable{}
+			table[key] = next
+			table = next
+		case tomlTable:
+			if last && arrayOfTables {
+				return nil, fmt.Errorf("%q is already defined as a table", strings.Join(keys, "."))
+			}
+			table = existing
+		case []interface{}:
+			if last && arrayOfTables {
+				next := tomlTable{}
+				table[key] = append(existing, next)
+				return next, nil
+			}
+			if len(existing) == 0 {
+				return nil, fmt.Errorf("%q is not a table", strings.Join(keys[:i+1], "."))
+			}
+			next, ok := existing[len(existing)-1].(tomlTable)
+			if !ok {
+				return nil, fmt.Errorf("%q is not a table", strings.Join(keys[:i+1], "."))
+			}
+			table = next
+		default:
+			return nil, fmt.Errorf("%q is not a table", strings.Join(keys[:i+1], "."))
+		}
+	}
+	return table, nil
+}
+
+// setTomlValue sets a possibly dotted key in a table.
+func setTomlValue(table tomlTable, keys []string, value interface{}) error {
+	for i, key := range keys[:len(keys)-1] {
+		switch existing := table[key].(type) {
+		case nil:
+			next := tomlTable{}
+			table[key] = next
+			table = next
+		case tomlTable:
+			table = existing
+		default:
+			return fmt.Errorf("%q is not a table", strings.Join(keys[:i+1], "."))
+		}
+	}
+	key := keys[len(keys)-1]
+	if _, exists := table[key]; exists {
+		return fmt.Errorf("duplicate key %q", strings.Join(keys, "."))
+	}
+	table[key] = value
+	return nil
+}
+
+type tomlParser struct {
+	data []byte
+	pos  int
+	line int
+}
+
+func (p *tomlParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("line %d: %s", p.line, fmt.Sprintf(format, args...))
+}
+
+func (p *tomlParser) eof() bool {
+	return p.pos >= len(p.data)
+}
+
+func (p *tomlParser) peek() byte {
+	return p.data[p.pos]
+}
+
+func (p *tomlParser) hasPrefix(s string) bool {
+	return strings.HasPrefix(string(p.data[p.pos:]), s)
+}
+
+func (p *tomlParser) consume(c byte) bool {
+	if !p.eof() && p.peek() == c {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+// skipWhitespace skips spaces, tabs and a trailing comment, but not newlines.
+func (p *tomlParser) skipWhitespace() {
+	for !p.eof() {
+		switch p.peek() {
+		case ' ', '\t':
+			p.pos++
+		case '#':
+			for !p.eof() && p.peek() != '\n' {
+				p.pos++
+			}
+		default:
+			return
+		}
+	}
+}
+
+func (p *tomlParser) skipWhitespaceAndNewlines() {
+	for {
+		p.skipWhitespace()
+		if p.eof() {
+			return
+		}
+		if p.peek() == '\n' {
+			p.line++
+		} else if p.peek() != '\r' {
+			return
+		}
+		p.pos++
+	}
+}
+
+func isBareKeyChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '-'
+}
+
+func (p *tomlParser) parseKeyPath() ([]string, error) {
+	var keys []string
+	for {
+		p.skipWhitespace()
+		if p.eof() {
+			return nil, p.errorf("unexpected end of file, expected key")
+		}
+		var key string
+		switch c := p.peek(); {
+		case c == '"' || c == '\'':
+			s, err := p.parseString()
+			if err != nil {
+				return nil, err
+			}
+			key = s
+		case isBareKeyChar(c):
+			start := p.pos
+			for !p.eof() && isBareKeyChar(p.peek()) {
+				p.pos++
+			}
+			key = string(p.data[start:p.pos])
+		default:
+			return nil, p.errorf("unexpected %q, expected key", c)
+		}
+		keys = append(keys, key)
+		p.skipWhitespace()
+		if !p.consume('.') {
+			return keys, nil
+		}
+	}
+}
+
+func (p *tomlParser) parseValue() (interface{}, error) {
+	if p.eof() {
+		return nil, p.errorf("unexpected end of file, expected value")
+	}
+	switch c := p.peek(); {
+	case c == '"' || c == '\'':
+		return p.parseString()
+	case c == '[':
+		return p.parseArray()
+	case c == '{':
+		return p.parseInlineTable()
+	case p.hasPrefix("true"):
+		p.pos += len("true")
+		return true, nil
+	case p.hasPrefix("false"):
+		p.pos += len("false")
+		return false, nil
+	case c == '+' || c == '-' || c >= '0' && c <= '9':
+		start := p.pos
+		for !p.eof() && strings.IndexByte(" \t\r\n#,]}", p.peek()) < 0 {
+			p.pos++
+		}
+		raw := string(p.data[start:p.pos])
+		if i, err := strconv.ParseInt(strings.Replace(raw, "_", "", -1), 0, 64); err == nil {
+			return i, nil
+		}
+		return raw, nil
+	default:
+		return nil, p.errorf("unexpected %q, expected value", c)
+	}
+}
+
+func (p *tomlParser) parseString() (string, error) {
+	quote := p.peek()
+	multiline := p.hasPrefix(strings.Repeat(string(quote), 3))
+	if multiline {
+		p.pos += 3
+		// A newline immediately following the opening delimiter is trimmed.
+		if p.hasPrefix("\r\n") {
+			p.pos += 2
+			p.line++
+		} else if p.consume('\n') {
+			p.line++
+		}
+	} else {
+		p.pos++
+	}
+
+	var sb strings.Builder
+	for {
+		if p.eof() {
+			return "", p.errorf("unterminated string")
+		}
+		c := p.peek()
+		if multiline && p.hasPrefix(strings.Repeat(string(quote), 3)) {
+			p.pos += 3
+			return sb.String(), nil
+		} else if !multiline && c == quote {
+			p.pos++
+			return sb.String(), nil
+		}
+		if c == '\n' {
+			if !multiline {
+				return "", p.errorf("newline in string")
+			}
+			p.line++
+		}
+		p.pos++
+		if c != '\\' || quote == '\'' {
+			sb.WriteByte(c)
+			continue
+		}
+
+		if p.eof() {
+			return "", p.errorf("unterminated string")
+		}
+		escape := p.peek()
+		p.pos++
+		switch escape {
+		case 'b':
+			sb.WriteByte('\b')
+		case 't':
+			sb.WriteByte('\t')
+		case 'n':
+			sb.WriteByte('\n')
+		case 'f':
+			sb.WriteByte('\f')
+		case 'r':
+			sb.WriteByte('\r')
+		case '"', '\\':
+			sb.WriteByte(escape)
+		case 'u', 'U':
+			n := 4
+			if escape == 'U' {
+				n = 8
+			}
+			if p.pos+n > len(p.data) {
+				return "", p.errorf("invalid unicode escape")
+			}
+			r, err := strconv.ParseUint(string(p.data[p.pos:p.pos+n]), 16, 32)
+			if err != nil {
+				return "", p.errorf("invalid unicode escape: %s", err)
+			}
+			sb.WriteRune(rune(r))
+			p.pos += n
+		case '\n', ' ', '\t', '\r':
+			if !multiline {
+				return "", p.errorf("invalid escape %q", escape)
+			}
+			// A line ending backslash trims all whitespace up to the next non-whitespace character.
+			p.pos--
+			for !p.eof() && strings.IndexByte(" \t\r\n", p.peek()) >= 0 {
+				if p.peek() == '\n' {
+					p.line++
+				}
+				p.pos++
+			}
+		default:
+			return "", p.errorf("invalid escape %q", escape)
+		}
+	}
+}
+
+func (p *tomlParser) parseArray() ([]interface{}, error) {
+	p.pos++
+	array := []interface{}{}
+	for {
+		p.skipWhitespaceAndNewlines()
+		if p.eof() {
+			return nil, p.errorf("unterminated array")
+		}
+		if p.consume(']') {
+			return array, nil
+		}
+		value, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		array = append(array, value)
+		p.skipWhitespaceAndNewlines()
+		if !p.consume(',') {
+			p.skipWhitespaceAndNewlines()
+			if !p.consume(']') {
+				return nil, p.errorf("expected ',' or ']' in array")
+			}
+			return array, nil
+		}
+	}
+}
+
+func (p *tomlParser) parseInlineTable() (tomlTable, error) {
+	p.pos++
+	table := tomlTable{}
+	p.skipWhitespace()
+	if p.consume('}') {
+		return table, nil
+	}
+	for {
+		keys, err := p.parseKeyPath()
+		if err != nil {
+			return nil, err
+		}
+		p.skipWhitespace()
+		if !p.consume('=') {
+			return nil, p.errorf("expected '=' after key %q", strings.Join(keys, "."))
+		}
+		p.skipWhitespace()
+		value, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		if err := setTomlValue(table, keys, value); err != nil {
+			return nil, p.errorf("%s", err)
+		}
+		p.skipWhitespace()
+		if p.consume('}') {
+			return table, nil
+		}
+		if !p.consume(',') {
+			return nil, p.errorf("expected ',' or '}' in inline table")
+		}
+		p.skipWhitespace()
+	}
+}
+
+// getString returns the string value of key in table, or "" if it is missing or not a string.
+func (table tomlTable) getString(key string) string {
+	s, _ := table[key].(string)
+	return s
+}
+
+// getBool returns the bool value of key in table, or def if it is missing or not a bool.
+func (table tomlTable) getBool(key string, def bool) bool {
+	if b, ok := table[key].(bool); ok {
+		return b
+	}
+	return def
+}
+
+// getTable returns the table value of key in table, or nil if it is missing or not a table.
+func (table tomlTable) getTable(key string) tomlTable {
+	t, _ := table[key].(tomlTable)
+	return t
+}
+
+// getStrings returns the string elements of the array value of key in table.
+func (table tomlTable) getStrings(key string) []string {
+	array, _ := table[key].([]interface{})
+	var ret []string
+	for _, v := range array {
+		if s, ok := v.(string); ok {
+			ret = append(ret, s)
+		}
+	}
+	return ret
+}
+
+// getTables returns the table elements of the array of tables value of key in table.
+func (table tomlTable) getTables(key string) []tomlTable {
+	array, _ := table[key].([]interface{})
+	var ret []tomlTable
+	for _, v := range array {
+		if t, ok := v.(tomlTable); ok {
+			ret = append(ret, t)
+		}
+	}
+	return ret
+}
@@ -22,9 +22,13 @@ import (
 	"github.com/google/blueprint/proptools"
 
 	"android/soong/android"
+	"android/soong/cc"
 	"android/soong/rust/config"
 )
 
+// The rustc equivalent of -mbranch-protection=standard.
+const branchProtectionFlag = "-Z branch-protection=bti,pac-ret"
+
 type RustLinkage int
 
 const (
@@ -172,6 +176,12 @@ type BaseCompilerProperties struct {
 
 	// If cargo_env_compat is true, sets the CARGO_PKG_VERSION env var to this value.
 	Cargo_pkg_version *string
+
+	// Whether the arm64 device variants of the module are compiled with branch protection
+	// (PAuth and BTI). Defaults to the product's policy for the partition the module is installed
+	// to, like the cc modules, set to false to opt out of it. The prebuilt standard crates are
+	// not rebuilt, so a module linking them only keeps the BTI property if they have it.
+	Branch_protection *bool `android:"arch_variant"`
 }
 
 type baseCompiler struct {
@@ -194,6 +204,9 @@ type baseCompiler struct {
 	// stripped output file.
 	strippedOutputFile android.OptionalPath
 
+	// zip of the unstripped output carrying the coverage mapping, if coverage is enabled.
+	coverageOutputFile android.OptionalPath
+
 	// If a crate has a source-generated dependency, a copy of the source file
 	// will be available in cargoOutDir (equivalent to Cargo OUT_DIR).
 	cargoOutDir android.ModuleOutPath
@@ -208,7 +221,7 @@ func (compiler *baseCompiler) SetDisabled() {
 }
 
 func (compiler *baseCompiler) coverageOutputZipPath() android.OptionalPath {
-	panic("baseCompiler does not implement coverageOutputZipPath()")
+	return compiler.coverageOutputFile
 }
 
 func (compiler *baseCompiler) preferRlib() bool {
@@ -305,6 +318,10 @@ func (compiler *baseCompiler) compilerFlags(ctx ModuleContext, flags Flags) Flag
 	flags.GlobalRustFlags = append(flags.GlobalRustFlags, ctx.toolchain().ToolchainRustFlags())
 	flags.GlobalLinkFlags = append(flags.GlobalLinkFlags, ctx.toolchain().ToolchainLinkFlags())
 
+	if cc.BranchProtectionEnabled(ctx, ctx.RustModule(), compiler.Properties.Branch_protection) {
+		flags.RustFlags = append(flags.RustFlags, branchProtectionFlag)
+	}
+
 	if ctx.Host() && !ctx.Windows() {
 		rpathPrefix := `\$$ORIGIN/`
 		if ctx.Darwin() {
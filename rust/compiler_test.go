@@ -337,3 +337,44 @@ func TestManualLinkageRejection(t *testing.T) {
 		}
 	`)
 }
+
+func TestBranchProtection(t *testing.T) {
+	ctx := android.GroupFixturePreparers(
+		prepareForRustTest,
+		rustMockedFiles.AddToFixture(),
+		android.FixtureModifyProductVariables(func(variables android.FixtureProductVariables) {
+			variables.BranchProtectionIncludePartitions = []string{"vendor"}
+		}),
+	).RunTestWithBp(t, `
+		rust_binary {
+			name: "fizz_vendor",
+			srcs: ["foo.rs"],
+			vendor: true,
+		}
+		rust_binary {
+			name: "fizz_vendor_opt_out",
+			srcs: ["foo.rs"],
+			vendor: true,
+			branch_protection: false,
+		}
+		rust_binary {
+			name: "fizz_system",
+			srcs: ["foo.rs"],
+		}
+		rust_binary {
+			name: "fizz_system_opt_in",
+			srcs: ["foo.rs"],
+			branch_protection: true,
+		}`).TestContext
+
+	checkBranchProtection := func(module, variant string, expected bool) {
+		t.Helper()
+		rustcFlags := ctx.ModuleForTests(module, variant).Rule("rustc").Args["rustcFlags"]
+		android.AssertBoolEquals(t, module+" ["+variant+"] branch protection", expected,
+			strings.Contains(rustcFlags, branchProtectionFlag))
+	}
+	checkBranchProtection("fizz_vendor", "android_vendor.29_arm64_armv8-a", true)
+	checkBranchProtection("fizz_vendor_opt_out", "android_vendor.29_arm64_armv8-a", false)
+	checkBranchProtection("fizz_system", "android_arm64_armv8-a", false)
+	checkBranchProtection("fizz_system_opt_in", "android_arm64_armv8-a", true)
+}
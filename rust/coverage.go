@@ -17,6 +17,7 @@ package rust
 import (
 	"github.com/google/blueprint"
 
+	"android/soong/android"
 	"android/soong/cc"
 )
 
@@ -56,7 +57,7 @@ func (cov *coverage) flags(ctx ModuleContext, flags Flags, deps PathDeps) (Flags
 		flags.Coverage = true
 		coverage := ctx.GetDirectDepWithTag(CovLibraryName, cc.CoverageDepTag).(cc.LinkableInterface)
 		flags.RustFlags = append(flags.RustFlags,
-			"-Z instrument-coverage", "-g")
+			"-C instrument-coverage", "-g")
 		flags.LinkFlags = append(flags.LinkFlags,
 			profileInstrFlag, "-g", coverage.OutputFile().Path().String(), "-Wl,--wrap,open")
 		deps.StaticLibs = append(deps.StaticLibs, coverage.OutputFile().Path())
@@ -77,3 +78,21 @@ func (cov *coverage) begin(ctx BaseModuleContext) {
 		cov.Properties = cc.SetCoverageProperties(ctx, cov.Properties, ctx.RustModule().nativeCoverage(), false, "")
 	}
 }
+
+// Registers build statement to zip the unstripped output of a coverage-instrumented module. Unlike
+// gcov, source-based coverage stores its mapping in the __llvm_covmap section of the binary itself,
+// so the unstripped binary is needed to turn the collected .profraw files into a report.
+func transformCoverageMappingToZip(ctx ModuleContext, unstrippedOutputFile android.Path,
+	baseName string) android.OptionalPath {
+
+	outputFile := android.PathForModuleOut(ctx, "coverage", baseName+".zip")
+
+	ctx.Build(pctx, android.BuildParams{
+		Rule:        zip,
+		Description: "zip " + outputFile.Base(),
+		Input:       unstrippedOutputFile,
+		Output:      outputFile,
+	})
+
+	return android.OptionalPathForPath(outputFile)
+}
@@ -56,7 +56,7 @@ func TestCoverageFlags(t *testing.T) {
 	fizzCov := ctx.ModuleForTests("fizz_cov", "android_arm64_armv8-a_cov").Rule("rustc")
 	buzzNoCov := ctx.ModuleForTests("buzzNoCov", "android_arm64_armv8-a").Rule("rustc")
 
-	rustcCoverageFlags := []string{"-Z instrument-coverage", " -g "}
+	rustcCoverageFlags := []string{"-C instrument-coverage", " -g "}
 	for _, flag := range rustcCoverageFlags {
 		missingErrorStr := "missing rustc flag '%s' for '%s' module with coverage enabled; rustcFlags: %#v"
 		containsErrorStr := "contains rustc flag '%s' for '%s' module with coverage disabled; rustcFlags: %#v"
@@ -108,3 +108,36 @@ func TestCoverageDeps(t *testing.T) {
 		t.Fatalf("missing expected coverage 'libprofile-clang-extras' dependency in linkFlags: %#v", fizz.Args["linkFlags"])
 	}
 }
+
+// Test that the unstripped outputs of coverage variants are packaged for coverage reports.
+func TestCoverageZip(t *testing.T) {
+	ctx := testRustCov(t, `
+		rust_library {
+			name: "libfoo_cov",
+			srcs: ["foo.rs"],
+			crate_name: "foo",
+		}
+		rust_binary {
+			name: "fizz_cov",
+			srcs: ["foo.rs"],
+		}`)
+
+	fizzCov := ctx.ModuleForTests("fizz_cov", "android_arm64_armv8-a_cov")
+	fizzZip := fizzCov.Output("coverage/fizz_cov.zip")
+	if fizzZip.Input.String() != fizzCov.Rule("rustc").Output.String() {
+		t.Errorf("expected coverage zip input %q, got %q", fizzCov.Rule("rustc").Output.String(), fizzZip.Input.String())
+	}
+
+	fizzMod := fizzCov.Module().(*Module)
+	if !fizzMod.CoverageOutputFile().Valid() {
+		t.Errorf("expected a coverage output file for 'fizz_cov'")
+	}
+
+	libfooCov := ctx.ModuleForTests("libfoo_cov", "android_arm64_armv8-a_dylib_cov")
+	libfooCov.Output("coverage/libfoo_cov.zip")
+
+	libfooRlib := ctx.ModuleForTests("libfoo_cov", "android_arm64_armv8-a_rlib_dylib-std_cov")
+	if libfooRlib.MaybeOutput("coverage/libfoo_cov.zip").Rule != nil {
+		t.Errorf("unexpected coverage zip for rlib variant of 'libfoo_cov'")
+	}
+}
@@ -61,3 +61,98 @@ func TestRustFuzz(t *testing.T) {
 		t.Errorf("rust_fuzz dependent library does not contain the expected flags (sancov-module, cfg fuzzing, hwaddress sanitizer).")
 	}
 }
+
+func TestRustFuzzPackagedFiles(t *testing.T) {
+	fs := android.MockFS{
+		"corpus/seed1":  nil,
+		"corpus/seed2":  nil,
+		"fuzz.dict":     nil,
+		"fuzz_data.txt": nil,
+	}
+	fs.Merge(rustMockedFiles)
+
+	ctx := testRustVndkFs(t, `
+			rust_fuzz {
+				name: "fuzz_libtest",
+				srcs: ["foo.rs"],
+				corpus: ["corpus/*"],
+				data: ["fuzz_data.txt"],
+				dictionary: "fuzz.dict",
+				fuzz_config: {
+					cc: ["foo@example.com"],
+					componentid: 1234,
+				},
+			}
+	`, fs)
+
+	fuzzMod := ctx.ModuleForTests("fuzz_libtest", "android_arm64_armv8-a_fuzzer")
+	fuzzMod.Output("seed1")
+	fuzzMod.Output("seed2")
+	fuzzMod.Output("fuzz_data.txt")
+
+	config := android.ContentFromFileRuleForTests(t, fuzzMod.Output("config.json"))
+	android.AssertStringEquals(t, "fuzz config", `{"cc":["foo@example.com"],"componentid":1234}`+"\n", config)
+
+	entries := android.AndroidMkEntriesForTest(t, ctx, fuzzMod.Module())[0]
+	android.AssertBoolEquals(t, "LOCAL_IS_FUZZ_TARGET", true, entries.EntryMap["LOCAL_IS_FUZZ_TARGET"][0] == "true")
+	testData := strings.Join(entries.EntryMap["LOCAL_TEST_DATA"], " ")
+	for _, want := range []string{":corpus/seed1", ":corpus/seed2", ":data/fuzz_data.txt", ":fuzz.dict", ":config.json"} {
+		if !strings.Contains(testData, want) {
+			t.Errorf("missing %q in LOCAL_TEST_DATA: %q", want, testData)
+		}
+	}
+}
+
+func TestRustFuzzDictionaryExtension(t *testing.T) {
+	testRustError(t, `dictionary: Fuzzer dictionary "data.txt" does not have '.dict' extension`, `
+			rust_fuzz {
+				name: "fuzz_libtest",
+				srcs: ["foo.rs"],
+				dictionary: "data.txt",
+			}
+	`)
+}
+
+func TestRustFuzzConfig(t *testing.T) {
+	ctx := testRust(t, `
+			rust_fuzz {
+				name: "fuzz_libtest",
+				srcs: ["foo.rs"],
+				owner: "fuzz_team",
+				fuzz_config: {
+					componentid: 1234,
+					asan_options: ["detect_leaks=0"],
+					hwasan_options: ["malloc_fill_byte=0"],
+					target_device_classes: ["phone", "tv"],
+				},
+			}
+	`)
+
+	fuzzMod := ctx.ModuleForTests("fuzz_libtest", "android_arm64_armv8-a_fuzzer")
+	config := android.ContentFromFileRuleForTests(t, fuzzMod.Output("config.json"))
+	android.AssertStringEquals(t, "fuzz config",
+		`{"componentid":1234,"hwasan_options":["malloc_fill_byte=0"],"asan_options":["detect_leaks=0"],`+
+			`"target_device_classes":["phone","tv"],"owner":"fuzz_team"}`+"\n", config)
+}
+
+func TestRustFuzzConfigErrors(t *testing.T) {
+	testRustError(t, `fuzz_config.asan_options: option "detect_leaks" is not in the form option=value`, `
+			rust_fuzz {
+				name: "fuzz_libtest",
+				srcs: ["foo.rs"],
+				fuzz_config: {
+					asan_options: ["detect_leaks"],
+				},
+			}
+	`)
+
+	testRustError(t, `fuzz_config.target_device_classes: unknown device class "fridge"`, `
+			rust_fuzz {
+				name: "fuzz_libtest",
+				srcs: ["foo.rs"],
+				fuzz_config: {
+					target_device_classes: ["fridge"],
+				},
+			}
+	`)
+}
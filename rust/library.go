@@ -533,6 +533,10 @@ func (library *libraryDecorator) compile(ctx ModuleContext, flags Flags, deps Pa
 		TransformSrctoShared(ctx, srcPath, deps, flags, outputFile)
 	}
 
+	if flags.Coverage && (library.dylib() || library.shared()) {
+		library.baseCompiler.coverageOutputFile = transformCoverageMappingToZip(ctx, outputFile, library.getStem(ctx))
+	}
+
 	if library.rlib() || library.dylib() {
 		library.flagExporter.exportLinkDirs(deps.linkDirs...)
 		library.flagExporter.exportLinkObjects(deps.linkObjects...)
@@ -80,6 +80,17 @@ func (procMacro *procMacroDecorator) compile(ctx ModuleContext, flags Flags, dep
 	return outputFile
 }
 
+// Dependencies on proc macros always use the BuildOSTarget variant, as proc macros are loaded by
+// rustc on the build machine regardless of the target of the dependent module. Any other host
+// variant would be compiled without ever being used, so disable it to ensure that each proc macro
+// is only compiled once and shared by every device and host variant that uses it.
+func (procMacro *procMacroDecorator) begin(ctx BaseModuleContext) {
+	buildOSTarget := ctx.Config().BuildOSTarget
+	if ctx.Os() != buildOSTarget.Os || ctx.Arch().ArchType != buildOSTarget.Arch.ArchType {
+		ctx.RustModule().Disable()
+	}
+}
+
 func (procMacro *procMacroDecorator) getStem(ctx ModuleContext) string {
 	stem := procMacro.baseCompiler.getStemWithoutSuffix(ctx)
 	validateLibraryStem(ctx, stem, procMacro.crateName())
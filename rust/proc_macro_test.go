@@ -34,3 +34,36 @@ func TestRustProcMacro(t *testing.T) {
 		t.Errorf("--extern proc_macro flag not being passed to rustc for proc macro %#v", libprocmacro.Args["rustcFlags"])
 	}
 }
+
+func TestRustProcMacroBuildOSOnly(t *testing.T) {
+	ctx := testRust(t, `
+		rust_proc_macro {
+			name: "libprocmacro",
+			srcs: ["foo.rs"],
+			crate_name: "procmacro",
+			compile_multilib: "both",
+		}
+		rust_library {
+			name: "libfoo",
+			srcs: ["foo.rs"],
+			crate_name: "foo",
+			proc_macros: ["libprocmacro"],
+			compile_multilib: "both",
+		}
+	`)
+
+	if !ctx.ModuleForTests("libprocmacro", "linux_glibc_x86_64").Module().Enabled() {
+		t.Errorf("expected the build OS variant of libprocmacro to be enabled")
+	}
+	if ctx.ModuleForTests("libprocmacro", "linux_glibc_x86").Module().Enabled() {
+		t.Errorf("expected non build OS variants of libprocmacro to be disabled")
+	}
+
+	procMacroOutput := ctx.ModuleForTests("libprocmacro", "linux_glibc_x86_64").Rule("rustc").Output.String()
+	for _, variant := range []string{"android_arm64_armv8-a_dylib", "android_arm_armv7-a-neon_dylib"} {
+		libFlags := ctx.ModuleForTests("libfoo", variant).Rule("rustc").Args["libFlags"]
+		if !strings.Contains(libFlags, "--extern procmacro="+procMacroOutput) {
+			t.Errorf("expected %s variant of libfoo to use %q, libFlags: %#v", variant, procMacroOutput, libFlags)
+		}
+	}
+}
@@ -53,6 +53,9 @@ func init() {
 	})
 	pctx.Import("android/soong/rust/config")
 	pctx.ImportAs("cc_config", "android/soong/cc/config")
+
+	// Every rust module adds its output to the rust phony target.
+	android.RegisterAggregatePhony("rust")
 }
 
 type Flags struct {
@@ -465,6 +468,7 @@ type compiler interface {
 	everInstallable() bool
 
 	nativeCoverage() bool
+	coverageOutputZipPath() android.OptionalPath
 
 	Disabled() bool
 	SetDisabled()
@@ -669,6 +673,13 @@ func (mod *Module) CoverageFiles() android.Paths {
 	panic(fmt.Errorf("CoverageFiles called on non-library module: %q", mod.BaseModuleName()))
 }
 
+func (mod *Module) CoverageOutputFile() android.OptionalPath {
+	if mod.compiler != nil {
+		return mod.compiler.coverageOutputZipPath()
+	}
+	return android.OptionalPath{}
+}
+
 func (mod *Module) installable(apexInfo android.ApexInfo) bool {
 	if !proptools.BoolDefault(mod.Installable(), mod.EverInstallable()) {
 		return false
@@ -1011,6 +1022,7 @@ var _ android.LicenseAnnotationsDependencyTag = dependencyTag{}
 
 var (
 	customBindgenDepTag = dependencyTag{name: "customBindgenTag"}
+	bindgenCflagsDepTag = dependencyTag{name: "bindgenCflagsTag"}
 	rlibDepTag          = dependencyTag{name: "rlibTag", library: true}
 	dylibDepTag         = dependencyTag{name: "dylib", library: true, dynamic: true}
 	procMacroDepTag     = dependencyTag{name: "procMacro", procMacro: true}
@@ -1047,6 +1059,9 @@ type autoDeppable interface {
 }
 
 func (mod *Module) begin(ctx BaseModuleContext) {
+	if procMacro, ok := mod.compiler.(*procMacroDecorator); ok {
+		procMacro.begin(ctx)
+	}
 	if mod.coverage != nil {
 		mod.coverage.begin(ctx)
 	}
@@ -1330,6 +1345,10 @@ func (mod *Module) InstallInData() bool {
 	return mod.compiler.inData()
 }
 
+func (mod *Module) InstallInSanitizerDir() bool {
+	return mod.sanitize != nil && mod.sanitize.Properties.InSanitizerDir
+}
+
 func (mod *Module) InstallInRamdisk() bool {
 	return mod.InRamdisk()
 }
@@ -1466,10 +1485,24 @@ func (mod *Module) DepsMutator(actx android.BottomUpMutatorContext) {
 	}
 
 	if mod.sourceProvider != nil {
-		if bindgen, ok := mod.sourceProvider.(*bindgenDecorator); ok &&
-			bindgen.Properties.Custom_bindgen != "" {
-			actx.AddFarVariationDependencies(ctx.Config().BuildOSTarget.Variations(), customBindgenDepTag,
-				bindgen.Properties.Custom_bindgen)
+		if bindgen, ok := mod.sourceProvider.(*bindgenDecorator); ok {
+			if bindgen.Properties.Custom_bindgen != "" {
+				actx.AddFarVariationDependencies(ctx.Config().BuildOSTarget.Variations(), customBindgenDepTag,
+					bindgen.Properties.Custom_bindgen)
+			}
+			if lib := String(bindgen.Properties.Cflags_from); lib != "" {
+				// Prefer the static variant of libraries, falling back to the shared variant for
+				// shared-only libraries and to no link variation for modules such as binaries.
+				var variations []blueprint.Variation
+				for _, link := range []string{"static", "shared"} {
+					linkVariations := []blueprint.Variation{{Mutator: "link", Variation: link}}
+					if actx.OtherModuleDependencyVariantExists(linkVariations, lib) {
+						variations = linkVariations
+						break
+					}
+				}
+				actx.AddVariationDependencies(variations, bindgenCflagsDepTag, lib)
+			}
 		}
 	}
 
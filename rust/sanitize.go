@@ -139,15 +139,19 @@ func (sanitize *sanitize) begin(ctx BaseModuleContext) {
 		}
 	}
 
+	// Rust modules in the excluded paths opt out of the address sanitizers enabled by
+	// SANITIZE_TARGET, like modules that set sanitize: { never: true }.
+	if ctx.Config().RustSanitizeDisabledForPath(ctx.ModuleDir()) {
+		_, globalSanitizers = android.RemoveFromList("hwaddress", globalSanitizers)
+		_, globalSanitizers = android.RemoveFromList("address", globalSanitizers)
+	}
+
 	if len(globalSanitizers) > 0 {
 		var found bool
 
 		// Global Sanitizers
 		if found, globalSanitizers = android.RemoveFromList("hwaddress", globalSanitizers); found && s.Hwaddress == nil {
-			// TODO(b/204776996): HWASan for static Rust binaries isn't supported yet.
-			if !ctx.RustModule().StaticExecutable() {
-				s.Hwaddress = proptools.BoolPtr(true)
-			}
+			s.Hwaddress = proptools.BoolPtr(true)
 		}
 
 		if found, globalSanitizers = android.RemoveFromList("memtag_heap", globalSanitizers); found && s.Memtag_heap == nil {
@@ -161,10 +165,7 @@ func (sanitize *sanitize) begin(ctx BaseModuleContext) {
 		}
 
 		if found, globalSanitizers = android.RemoveFromList("fuzzer", globalSanitizers); found && s.Fuzzer == nil {
-			// TODO(b/204776996): HWASan for static Rust binaries isn't supported yet, and fuzzer enables HWAsan
-			if !ctx.RustModule().StaticExecutable() {
-				s.Fuzzer = proptools.BoolPtr(true)
-			}
+			s.Fuzzer = proptools.BoolPtr(true)
 		}
 
 		// Global Diag Sanitizers
@@ -211,6 +212,12 @@ func (sanitize *sanitize) begin(ctx BaseModuleContext) {
 		s.Hwaddress = nil
 	}
 
+	// ASan and the fuzzer runtime are only available as shared libraries.
+	if ctx.RustModule().StaticExecutable() {
+		s.Address = nil
+		s.Fuzzer = nil
+	}
+
 	if Bool(s.Hwaddress) {
 		s.Address = nil
 	}
@@ -278,35 +285,60 @@ func rustSanitizerRuntimeMutator(mctx android.BottomUpMutatorContext) {
 			mctx.AddFarVariationDependencies(variations, depTag, noteDep)
 		}
 
-		variations := mctx.Target().Variations()
-		var depTag blueprint.DependencyTag
-		var deps []string
-
+		// Determine the runtime library required
+		toolchain := mod.toolchain(mctx)
+		runtimeLibrary := ""
+		var extraStaticDeps []string
 		if mod.IsSanitizerEnabled(cc.Asan) ||
 			(mod.IsSanitizerEnabled(cc.Fuzzer) && mctx.Arch().ArchType != android.Arm64) {
-			variations = append(variations,
-				blueprint.Variation{Mutator: "link", Variation: "shared"})
-			depTag = cc.SharedDepTag()
-			deps = []string{config.LibclangRuntimeLibrary(mod.toolchain(mctx), "asan")}
+			runtimeLibrary = config.LibclangRuntimeLibrary(toolchain, "asan")
 		} else if mod.IsSanitizerEnabled(cc.Hwasan) ||
 			(mod.IsSanitizerEnabled(cc.Fuzzer) && mctx.Arch().ArchType == android.Arm64) {
-			// TODO(b/204776996): HWASan for static Rust binaries isn't supported yet.
-			if binary, ok := mod.compiler.(binaryInterface); ok {
-				if binary.staticallyLinked() {
-					mctx.ModuleErrorf("HWASan is not supported for static Rust executables yet.")
-				}
+			if mod.StaticExecutable() {
+				runtimeLibrary = config.LibclangRuntimeLibrary(toolchain, "hwasan_static")
+				extraStaticDeps = []string{"libdl"}
+			} else {
+				runtimeLibrary = config.LibclangRuntimeLibrary(toolchain, "hwasan")
 			}
+		}
 
-			// Always link against the shared library -- static binaries will pull in the static
-			// library during final link if necessary
-			variations = append(variations,
-				blueprint.Variation{Mutator: "link", Variation: "shared"})
-			depTag = cc.SharedDepTag()
-			deps = []string{config.LibclangRuntimeLibrary(mod.toolchain(mctx), "hwasan")}
+		// rlibs and static libraries don't depend on the runtime library, it is linked into the
+		// binaries and shared libraries that use them.
+		if lib, ok := mod.compiler.(libraryInterface); ok && (lib.rlib() || lib.static()) {
+			return
+		}
+		if runtimeLibrary == "" {
+			return
 		}
 
-		if len(deps) > 0 {
-			mctx.AddFarVariationDependencies(variations, depTag, deps...)
+		snapshot := mctx.Provider(cc.SnapshotInfoProvider).(cc.SnapshotInfo)
+		if mod.StaticExecutable() {
+			// static executable gets static runtime libs
+			deps := append([]string{runtimeLibrary}, extraStaticDeps...)
+			// If we're using snapshots, redirect to snapshot whenever possible
+			for idx, dep := range deps {
+				if lib, ok := snapshot.StaticLibs[dep]; ok {
+					deps[idx] = lib
+				}
+			}
+			variations := append(mctx.Target().Variations(),
+				blueprint.Variation{Mutator: "link", Variation: "static"})
+			if mod.Device() {
+				variations = append(variations, mod.ImageVariation())
+			}
+			mctx.AddFarVariationDependencies(variations, cc.StaticDepTag(false), deps...)
+		} else {
+			// If we're using snapshots, redirect to snapshot whenever possible
+			if lib, ok := snapshot.SharedLibs[runtimeLibrary]; ok {
+				runtimeLibrary = lib
+			}
+			// dynamic executable and shared libs get shared runtime libs
+			variations := append(mctx.Target().Variations(),
+				blueprint.Variation{Mutator: "link", Variation: "shared"})
+			if mod.Device() {
+				variations = append(variations, mod.ImageVariation())
+			}
+			mctx.AddFarVariationDependencies(variations, cc.SharedDepTag(), runtimeLibrary)
 		}
 	}
 }
@@ -412,10 +444,6 @@ func (mod *Module) SanitizerSupported(t cc.SanitizerType) bool {
 	case cc.Asan:
 		return true
 	case cc.Hwasan:
-		// TODO(b/180495975): HWASan for static Rust binaries isn't supported yet.
-		if mod.StaticExecutable() {
-			return false
-		}
 		return true
 	case cc.Memtag_heap:
 		return true
@@ -5,6 +5,8 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/google/blueprint"
+
 	"android/soong/android"
 )
 
@@ -363,3 +365,90 @@ func TestSanitizeMemtagHeapWithSanitizeDeviceDiag(t *testing.T) {
 	checkHasMemtagNote(t, ctx.ModuleForTests("unset_test_override_default_disable", variant), Sync)
 	checkHasMemtagNote(t, ctx.ModuleForTests("unset_test_override_default_sync", variant), Sync)
 }
+
+func TestSanitizerRuntimeDeps(t *testing.T) {
+	ctx := testRust(t, `
+		rust_binary {
+			name: "bin_hwasan",
+			srcs: ["foo.rs"],
+			sanitize: { hwaddress: true },
+		}
+		rust_binary {
+			name: "bin_static_hwasan",
+			srcs: ["foo.rs"],
+			static_executable: true,
+			sanitize: { hwaddress: true },
+		}
+		rust_library_rlib {
+			name: "librlib_hwasan",
+			crate_name: "rlib_hwasan",
+			srcs: ["foo.rs"],
+			sanitize: { hwaddress: true },
+		}
+	`)
+
+	directDeps := func(module, variant string) []string {
+		t.Helper()
+		var deps []string
+		ctx.VisitDirectDeps(ctx.ModuleForTests(module, variant).Module(), func(m blueprint.Module) {
+			deps = append(deps, m.Name())
+		})
+		return deps
+	}
+
+	android.AssertStringListContains(t, "bin_hwasan deps",
+		directDeps("bin_hwasan", "android_arm64_armv8-a_hwasan"), "libclang_rt.hwasan")
+
+	staticDeps := directDeps("bin_static_hwasan", "android_arm64_armv8-a_hwasan")
+	android.AssertStringListContains(t, "bin_static_hwasan deps", staticDeps, "libclang_rt.hwasan_static")
+	android.AssertStringListContains(t, "bin_static_hwasan deps", staticDeps, "libdl")
+	android.AssertStringListDoesNotContain(t, "bin_static_hwasan deps", staticDeps, "libclang_rt.hwasan")
+
+	for _, variant := range ctx.ModuleVariantsForTests("librlib_hwasan") {
+		android.AssertStringListDoesNotContain(t, "librlib_hwasan "+variant+" deps",
+			directDeps("librlib_hwasan", variant), "libclang_rt.hwasan")
+	}
+}
+
+func TestSanitizeDeviceExcludePaths(t *testing.T) {
+	skipTestIfOsNotSupported(t)
+	result := android.GroupFixturePreparers(
+		prepareForRustTest,
+		rustMockedFiles.AddToFixture(),
+		android.MockFS{
+			"excluded/foo.rs": nil,
+			"excluded/Android.bp": []byte(`
+				rust_binary {
+					name: "bin_excluded",
+					srcs: ["foo.rs"],
+				}
+			`),
+		}.AddToFixture(),
+		android.FixtureModifyProductVariables(func(variables android.FixtureProductVariables) {
+			variables.SanitizeDevice = []string{"hwaddress"}
+			variables.RustSanitizeExcludePaths = []string{"excluded"}
+		}),
+	).RunTestWithBp(t, `
+		rust_binary {
+			name: "bin_included",
+			srcs: ["foo.rs"],
+		}
+	`)
+
+	hasHwasanFlags := func(module string) bool {
+		t.Helper()
+		for _, variant := range result.ModuleVariantsForTests(module) {
+			if !strings.HasPrefix(variant, "android_arm64_") {
+				continue
+			}
+			rule := result.ModuleForTests(module, variant).MaybeRule("rustc")
+			if strings.Contains(rule.Args["rustcFlags"], "-Z sanitizer=hwaddress") {
+				return true
+			}
+		}
+		return false
+	}
+
+	android.AssertBoolEquals(t, "bin_included is sanitized", true, hasHwasanFlags("bin_included"))
+	android.AssertBoolEquals(t, "bin_excluded is sanitized", false, hasHwasanFlags("bin_excluded"))
+}
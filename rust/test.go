@@ -185,6 +185,15 @@ func (test *testDecorator) install(ctx ModuleContext) {
 		test.Properties.Test_options.Unit_test = proptools.BoolPtr(true)
 	}
 	test.binaryDecorator.install(ctx)
+
+	if len(test.Properties.Test_suites) > 0 {
+		ctx.SetProvider(android.TestSuiteInfoProvider, android.TestSuiteInfo{
+			TestSuites: test.Properties.Test_suites,
+			OutputFile: ctx.RustModule().OutputFile().Path(),
+			Data:       android.TestSuiteData(test.data),
+			TestConfig: test.testConfig,
+		})
+	}
 }
 
 func (test *testDecorator) compilerFlags(ctx ModuleContext, flags Flags) Flags {
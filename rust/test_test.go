@@ -200,3 +200,31 @@ func TestDataLibsRelativeInstallPath(t *testing.T) {
 			" but was '%s'", entries.EntryMap["LOCAL_TEST_DATA"][2])
 	}
 }
+
+func TestRustTestSuiteInfo(t *testing.T) {
+	ctx := testRust(t, `
+		rust_binary {
+			name: "rusty",
+			srcs: ["foo.rs"],
+			relative_install_path: "foo/bar/baz",
+			compile_multilib: "64",
+		}
+
+		rust_test {
+			name: "main_test",
+			srcs: ["foo.rs"],
+			data_bins: ["rusty"],
+			test_suites: ["general-tests"],
+			compile_multilib: "64",
+		}`)
+
+	module := ctx.ModuleForTests("main_test", "android_arm64_armv8-a").Module()
+	info := ctx.ModuleProvider(module, android.TestSuiteInfoProvider).(android.TestSuiteInfo)
+	android.AssertArrayString(t, "test suites", []string{"general-tests"}, info.TestSuites)
+	android.AssertPathRelativeToTopEquals(t, "output file",
+		"out/soong/.intermediates/main_test/android_arm64_armv8-a/main_test", info.OutputFile)
+	android.AssertPathRelativeToTopEquals(t, "data bin",
+		"out/soong/.intermediates/rusty/android_arm64_armv8-a/rusty", info.Data["foo/bar/baz/rusty"])
+	android.AssertPathRelativeToTopEquals(t, "test config",
+		"out/soong/.intermediates/main_test/android_arm64_armv8-a/main_test.config", info.TestConfig)
+}
@@ -155,6 +155,14 @@ func GatherRequiredDepsForTest() string {
 			srcs:["foo.rs"],
 			host_supported: true,
 		}
+		rust_library {
+			name: "librustutils",
+			crate_name: "rustutils",
+			srcs: ["foo.rs"],
+			host_supported: true,
+			vendor_available: true,
+			product_available: true,
+		}
 `
 	return bp
 }
@@ -166,6 +174,7 @@ func registerRequiredBuildComponentsForTest(ctx android.RegistrationContext) {
 	ctx.RegisterModuleType("rust_binary_host", RustBinaryHostFactory)
 	ctx.RegisterModuleType("rust_bindgen", RustBindgenFactory)
 	ctx.RegisterModuleType("rust_bindgen_host", RustBindgenHostFactory)
+	ctx.RegisterModuleType("rust_cargo_package", CargoPackageFactory)
 	ctx.RegisterModuleType("rust_test", RustTestFactory)
 	ctx.RegisterModuleType("rust_test_host", RustTestHostFactory)
 	ctx.RegisterModuleType("rust_library", RustLibraryFactory)
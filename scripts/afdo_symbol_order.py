@@ -0,0 +1,59 @@
+#!/usr/bin/env python3
+#
+# Copyright (C) 2022 The Android Open Source Project
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#      http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+"""A tool to derive a symbol ordering file for lld from an afdo profile.
+
+The input is the output of `llvm-profdata show --sample --all-functions`. The
+functions are written one per line, from the most to the least sampled.
+"""
+
+import argparse
+import re
+
+# e.g. "Function: _Z3foov: 12345, 67, 8 sampled lines"
+FUNCTION_RE = re.compile(r'^Function: (\S+): (\d+), \d+, \d+ sampled lines$')
+
+
+def parse_args():
+  """Parse commandline arguments."""
+  parser = argparse.ArgumentParser(description=__doc__)
+  parser.add_argument('--output', required=True,
+                      help='path to the symbol ordering file to write')
+  parser.add_argument('input', help='output of llvm-profdata show --sample')
+  return parser.parse_args()
+
+
+def symbol_order(lines):
+  """Returns the functions in the profile sorted by their total samples."""
+  samples = {}
+  for line in lines:
+    match = FUNCTION_RE.match(line.strip())
+    if match:
+      name, total = match.group(1), int(match.group(2))
+      samples[name] = samples.get(name, 0) + total
+  return sorted(samples, key=lambda name: (-samples[name], name))
+
+
+def main():
+  args = parse_args()
+  with open(args.input) as f:
+    symbols = symbol_order(f)
+  with open(args.output, 'w') as f:
+    for symbol in symbols:
+      f.write(symbol + '\n')
+
+
+if __name__ == '__main__':
+  main()
@@ -0,0 +1,49 @@
+#!/usr/bin/env python3
+#
+# Copyright (C) 2022 The Android Open Source Project
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#      http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+#
+"""Unit tests for afdo_symbol_order.py."""
+
+import unittest
+
+import afdo_symbol_order
+
+
+class AfdoSymbolOrderTest(unittest.TestCase):
+  """Unit tests for afdo_symbol_order."""
+
+  def test_symbol_order(self):
+    symbols = afdo_symbol_order.symbol_order([
+        'Function: _Z4coldv: 10, 1, 2 sampled lines\n',
+        'Samples collected in the function\'s body {\n',
+        '  0: 10\n',
+        '}\n',
+        'Function: _Z3hotv: 5000, 20, 8 sampled lines\n',
+        'Function: _Z4warmv: 300, 5, 3 sampled lines\n',
+        'Function: _Z5warm2v: 300, 5, 3 sampled lines\n',
+    ])
+    self.assertEqual(symbols, ['_Z3hotv', '_Z4warmv', '_Z5warm2v', '_Z4coldv'])
+
+  def test_duplicate_functions_are_merged(self):
+    symbols = afdo_symbol_order.symbol_order([
+        'Function: a: 10, 0, 1 sampled lines',
+        'Function: b: 15, 0, 1 sampled lines',
+        'Function: a: 10, 0, 1 sampled lines',
+    ])
+    self.assertEqual(symbols, ['a', 'b'])
+
+
+if __name__ == '__main__':
+  unittest.main(verbosity=2)
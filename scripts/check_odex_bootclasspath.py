@@ -0,0 +1,131 @@
+#!/usr/bin/env python
+#
+# Copyright (C) 2022 The Android Open Source Project
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#      http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+#
+"""A tool for checking the boot class path that an odex file was compiled
+against.
+
+The runtime rejects an odex file unless the boot class path recorded in its
+header is a prefix of the boot class path of the device, and then falls back
+to JIT or to compiling the dex file on device. This tool reads the header of
+the odex file dumped by `oatdump --header-only`, writes a report of the
+recorded boot class path entries and fails if any of them don't resolve to the
+boot class path of the product.
+"""
+
+from __future__ import print_function
+
+import argparse
+import sys
+
+# Key of the boot class path in the key-value store of the oat header.
+bootclasspath_key = 'bootclasspath'
+
+
+def parse_args(args):
+    """Parse commandline arguments."""
+    parser = argparse.ArgumentParser()
+    parser.add_argument(
+        '--header',
+        dest='header',
+        required=True,
+        help='output of oatdump --header-only for the odex file')
+    parser.add_argument(
+        '--dex-location',
+        dest='dex_location',
+        default='',
+        help='location of the dex file on device, used in messages')
+    parser.add_argument(
+        '--bootclasspath',
+        dest='bootclasspath',
+        default='',
+        help='colon-separated boot class path locations of the product')
+    parser.add_argument(
+        '--report',
+        dest='report',
+        required=True,
+        help='file to write the report to')
+    return parser.parse_args(args)
+
+
+def recorded_bootclasspath(header):
+    """Returns the boot class path recorded in the dumped oat header."""
+    in_store = False
+    for line in header.splitlines():
+        if line.startswith('KEY VALUE STORE:'):
+            in_store = True
+            continue
+        if not in_store:
+            continue
+        if not line.strip():
+            break
+        key, sep, value = line.strip().partition(' = ')
+        if sep and key == bootclasspath_key:
+            return [entry for entry in value.split(':') if entry]
+    raise RuntimeError('no %s in the oat header' % bootclasspath_key)
+
+
+def check_bootclasspath(recorded, expected):
+    """Checks the recorded boot class path against the expected one.
+
+    Returns the report lines and the list of errors.
+    """
+    report = []
+    errors = []
+    for i, entry in enumerate(recorded):
+        if i < len(expected) and expected[i] == entry:
+            report.append('OK %s' % entry)
+        elif entry in expected:
+            report.append('MISORDERED %s' % entry)
+            errors.append(
+                '%s is at position %d of the boot class path of the odex file '
+                'but at position %d of the boot class path of the product' %
+                (entry, i, expected.index(entry)))
+        else:
+            report.append('UNRESOLVED %s' % entry)
+            errors.append(
+                '%s is not on the boot class path of the product' % entry)
+    return report, errors
+
+
+def main():
+    """Program entry point."""
+    try:
+        args = parse_args(sys.argv[1:])
+
+        with open(args.header) as f:
+            recorded = recorded_bootclasspath(f.read())
+        expected = [entry for entry in args.bootclasspath.split(':') if entry]
+
+        report, errors = check_bootclasspath(recorded, expected)
+        with open(args.report, 'w') as f:
+            f.write('\n'.join(report + ['ERROR ' + e for e in errors]) + '\n')
+
+        if errors:
+            print('error: odex file for %s was compiled against a boot class '
+                  'path that does not match the product and would be rejected '
+                  'on device:' % args.dex_location, file=sys.stderr)
+            for e in errors:
+                print('  ' + e, file=sys.stderr)
+            sys.exit(1)
+
+    # pylint: disable=broad-except
+    except Exception as err:
+        print('error: ' + str(err), file=sys.stderr)
+        sys.exit(-1)
+
+
+if __name__ == '__main__':
+    main()
@@ -0,0 +1,80 @@
+#!/usr/bin/env python
+#
+# Copyright (C) 2022 The Android Open Source Project
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#      http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+#
+"""Unit tests for check_odex_bootclasspath.py."""
+
+import sys
+import unittest
+
+import check_odex_bootclasspath as cob
+
+sys.dont_write_bytecode = True
+
+header = '''MAGIC:
+oat
+230
+
+LOCATION:
+out/soong/foo.odex
+
+KEY VALUE STORE:
+bootclasspath = /apex/com.android.art/javalib/core-oj.jar:/system/framework/framework.jar
+bootclasspath-checksums = i;1/abcdef01
+compiler-filter = speed-profile
+
+INSTRUCTION SET:
+Arm64
+'''
+
+expected = [
+    '/apex/com.android.art/javalib/core-oj.jar',
+    '/system/framework/framework.jar',
+    '/system/framework/ext.jar',
+]
+
+
+class CheckOdexBootclasspathTest(unittest.TestCase):
+
+    def test_recorded_bootclasspath(self):
+        self.assertEqual(
+            cob.recorded_bootclasspath(header), expected[:2])
+
+    def test_recorded_bootclasspath_missing(self):
+        with self.assertRaises(RuntimeError):
+            cob.recorded_bootclasspath('KEY VALUE STORE:\nfoo = bar\n')
+
+    def test_check_prefix(self):
+        report, errors = cob.check_bootclasspath(expected[:2], expected)
+        self.assertEqual(report, ['OK ' + e for e in expected[:2]])
+        self.assertEqual(errors, [])
+
+    def test_check_misordered(self):
+        report, errors = cob.check_bootclasspath(
+            [expected[0], expected[2]], expected)
+        self.assertEqual(report, ['OK ' + expected[0], 'MISORDERED ' + expected[2]])
+        self.assertEqual(len(errors), 1)
+
+    def test_check_unresolved(self):
+        report, errors = cob.check_bootclasspath(
+            [expected[0], '/system/framework/missing.jar'], expected)
+        self.assertEqual(
+            report,
+            ['OK ' + expected[0], 'UNRESOLVED /system/framework/missing.jar'])
+        self.assertEqual(len(errors), 1)
+
+
+if __name__ == '__main__':
+    unittest.main(verbosity=2)
@@ -0,0 +1,201 @@
+#!/usr/bin/env python3
+#
+# Copyright (C) 2022 The Android Open Source Project
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#      http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+"""A tool to find mismatched definitions of the same struct or class, which
+violate the one definition rule, in the debug info of objects and archives"""
+
+import argparse
+import re
+import subprocess
+import sys
+
+_DIE_RE = re.compile(r'^0x[0-9a-f]+:( *)(DW_TAG_\w+|NULL)')
+_ATTR_RE = re.compile(r'^\s+(DW_AT_\w+)\s+\((.*)\)$')
+_FILE_RE = re.compile(r'^(\S.*):\s+file format ')
+_TYPE_NAME_RE = re.compile(r'^0x[0-9a-f]+ "(.*)"$')
+
+_RECORD_TAGS = ('DW_TAG_structure_type', 'DW_TAG_class_type',
+                'DW_TAG_union_type')
+
+
+def parse_args():
+  """Parse commandline arguments."""
+  parser = argparse.ArgumentParser(
+      description=__doc__, fromfile_prefix_chars='@')
+  parser.add_argument('--dwarfdump', required=True,
+                      help='path to llvm-dwarfdump')
+  parser.add_argument('--output', required=True,
+                      help='path to the stamp file to write if there are no '
+                      'mismatches')
+  parser.add_argument('inputs', nargs='*', help='objects and archives to check')
+  return parser.parse_args()
+
+
+class Die(object):
+  """A debug info entry."""
+
+  def __init__(self, tag, parent):
+    self.tag = tag
+    self.parent = parent
+    self.attrs = {}
+    self.children = []
+
+  def name(self):
+    """Returns the unquoted DW_AT_name of the entry, or None."""
+    name = self.attrs.get('DW_AT_name')
+    if name is None:
+      return None
+    return name.strip('"')
+
+
+def parse_dwarfdump(lines, default_origin):
+  """Parses the output of llvm-dwarfdump --debug-info into a list of
+  (origin, compile unit) tuples, where origin is the object file the compile
+  unit comes from."""
+  units = []
+  origin = default_origin
+  stack = []  # (indent, die)
+  die = None
+  for line in lines:
+    line = line.rstrip('\n')
+    m = _FILE_RE.match(line)
+    if m:
+      origin = m.group(1)
+      stack = []
+      die = None
+      continue
+    m = _DIE_RE.match(line)
+    if m:
+      indent, tag = len(m.group(1)), m.group(2)
+      while stack and stack[-1][0] >= indent:
+        stack.pop()
+      if tag == 'NULL':
+        die = None
+        continue
+      parent = stack[-1][1] if stack else None
+      die = Die(tag, parent)
+      if parent:
+        parent.children.append(die)
+      elif tag == 'DW_TAG_compile_unit':
+        units.append((origin, die))
+      stack.append((indent, die))
+      continue
+    m = _ATTR_RE.match(line)
+    if m and die:
+      die.attrs[m.group(1)] = m.group(2)
+  return units
+
+
+def _qualified_name(die):
+  """Returns the qualified name of a record, or None if it has no linkage,
+  i.e. it is unnamed, local to a function or in an anonymous namespace."""
+  names = [die.name()]
+  parent = die.parent
+  while parent and parent.tag != 'DW_TAG_compile_unit':
+    if parent.tag not in ('DW_TAG_namespace',) + _RECORD_TAGS:
+      return None
+    names.append(parent.name())
+    parent = parent.parent
+  if None in names:
+    return None
+  return '::'.join(reversed(names))
+
+
+def _type_name(value):
+  if value is None:
+    return ''
+  m = _TYPE_NAME_RE.match(value)
+  return m.group(1) if m else value
+
+
+def _layout(die):
+  """Returns a description of the layout of a record."""
+  members = []
+  for child in die.children:
+    if child.tag in ('DW_TAG_member', 'DW_TAG_inheritance'):
+      members.append((child.name() or '<base>',
+                      _type_name(child.attrs.get('DW_AT_type')),
+                      child.attrs.get('DW_AT_data_member_location', '')))
+  return (die.attrs.get('DW_AT_byte_size'), tuple(members))
+
+
+def collect_definitions(units):
+  """Returns a map from the qualified name of each record defined in units to
+  a map from each of its layouts to the places it is defined with it."""
+  definitions = {}
+
+  def visit(die, origin):
+    for child in die.children:
+      if (child.tag in _RECORD_TAGS and
+          'DW_AT_declaration' not in child.attrs and
+          'DW_AT_byte_size' in child.attrs):
+        name = _qualified_name(child)
+        if name:
+          place = origin
+          decl_file = child.attrs.get('DW_AT_decl_file')
+          if decl_file:
+            place += ' (' + decl_file.strip('"')
+            if 'DW_AT_decl_line' in child.attrs:
+              place += ':' + child.attrs['DW_AT_decl_line']
+            place += ')'
+          layouts = definitions.setdefault(name, {})
+          places = layouts.setdefault(_layout(child), [])
+          if place not in places:
+            places.append(place)
+      visit(child, origin)
+
+  for origin, unit in units:
+    visit(unit, origin)
+  return definitions
+
+
+def find_mismatches(definitions):
+  """Returns the error messages for the records with more than one layout."""
+  errors = []
+  for name in sorted(definitions):
+    layouts = definitions[name]
+    if len(layouts) < 2:
+      continue
+    error = 'mismatched definitions of %s:' % name
+    for (size, members), places in sorted(layouts.items()):
+      error += '\n  size %s, members %s' % (
+          size, ', '.join('%s %s@%s' % (t, n, o) for n, t, o in members)
+          or 'none')
+      for place in places:
+        error += '\n    in ' + place
+    errors.append(error)
+  return errors
+
+
+def main():
+  args = parse_args()
+  units = []
+  for path in args.inputs:
+    result = subprocess.run([args.dwarfdump, '--debug-info', path],
+                            stdout=subprocess.PIPE, universal_newlines=True,
+                            check=False)
+    if result.returncode != 0:
+      sys.exit('%s: llvm-dwarfdump failed' % path)
+    units.extend(parse_dwarfdump(result.stdout.splitlines(), path))
+  errors = find_mismatches(collect_definitions(units))
+  if errors:
+    sys.exit('\n'.join(errors) + '\n'
+             'These one definition rule violations can break CFI and LTO.')
+  with open(args.output, 'w') as f:
+    f.write('')
+
+
+if __name__ == '__main__':
+  main()
@@ -0,0 +1,125 @@
+#!/usr/bin/env python3
+#
+# Copyright (C) 2022 The Android Open Source Project
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#      http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+#
+"""Unit tests for check_odr.py."""
+
+import unittest
+
+import check_odr
+
+
+def dwarfdump(origin, *records):
+  """Returns fake llvm-dwarfdump output for a compile unit with the records,
+  each a (name, size, [(member, type, offset)]) tuple."""
+  lines = [
+      '%s:\tfile format elf64-littleaarch64' % origin,
+      '',
+      '0x0000000b: DW_TAG_compile_unit',
+      '              DW_AT_name\t("foo.cpp")',
+      '',
+  ]
+  for name, size, members in records:
+    lines += [
+        '0x0000002a:   DW_TAG_structure_type',
+        '                DW_AT_name\t("%s")' % name,
+        '                DW_AT_byte_size\t(0x%02x)' % size,
+        '                DW_AT_decl_file\t("foo.h")',
+        '                DW_AT_decl_line\t(3)',
+        '',
+    ]
+    for member, typ, offset in members:
+      lines += [
+          '0x00000033:     DW_TAG_member',
+          '                  DW_AT_name\t("%s")' % member,
+          '                  DW_AT_type\t(0x0000004a "%s")' % typ,
+          '                  DW_AT_data_member_location\t(0x%02x)' % offset,
+          '',
+      ]
+    lines += ['0x00000048:     NULL', '']
+  lines += ['0x00000050:   NULL']
+  return lines
+
+
+class CheckOdrTest(unittest.TestCase):
+  """Unit tests for check_odr."""
+
+  def check(self, *dumps):
+    units = []
+    for dump in dumps:
+      units.extend(check_odr.parse_dwarfdump(dump, 'default.o'))
+    return check_odr.find_mismatches(check_odr.collect_definitions(units))
+
+  def test_parse_dwarfdump(self):
+    units = check_odr.parse_dwarfdump(
+        dwarfdump('libfoo.a(foo.o)', ('Foo', 8, [('a', 'int', 0)])),
+        'libfoo.a')
+    self.assertEqual(len(units), 1)
+    origin, unit = units[0]
+    self.assertEqual(origin, 'libfoo.a(foo.o)')
+    self.assertEqual(unit.name(), 'foo.cpp')
+    self.assertEqual([c.name() for c in unit.children], ['Foo'])
+    self.assertEqual([c.name() for c in unit.children[0].children], ['a'])
+
+  def test_matching_definitions(self):
+    self.assertEqual(self.check(
+        dwarfdump('a.o', ('Foo', 8, [('a', 'int', 0), ('b', 'int', 4)])),
+        dwarfdump('b.o', ('Foo', 8, [('a', 'int', 0), ('b', 'int', 4)])),
+    ), [])
+
+  def test_mismatched_definitions(self):
+    errors = self.check(
+        dwarfdump('a.o', ('Foo', 8, [('a', 'int', 0), ('b', 'int', 4)])),
+        dwarfdump('libbar.a(b.o)', ('Foo', 4, [('a', 'int', 0)])),
+    )
+    self.assertEqual(errors, [
+        'mismatched definitions of Foo:\n'
+        '  size 0x04, members int a@0x00\n'
+        '    in libbar.a(b.o) (foo.h:3)\n'
+        '  size 0x08, members int a@0x00, int b@0x04\n'
+        '    in a.o (foo.h:3)',
+    ])
+
+  def test_mismatched_member_type(self):
+    errors = self.check(
+        dwarfdump('a.o', ('Foo', 8, [('a', 'long', 0)])),
+        dwarfdump('b.o', ('Foo', 8, [('a', 'double', 0)])),
+    )
+    self.assertEqual(len(errors), 1)
+
+  def test_ignores_declarations_and_local_types(self):
+    lines = [
+        '0x0000000b: DW_TAG_compile_unit',
+        '0x0000002a:   DW_TAG_structure_type',
+        '                DW_AT_name\t("Foo")',
+        '                DW_AT_declaration\t(true)',
+        '',
+        '0x00000030:   DW_TAG_namespace',
+        '',
+        '0x00000032:     DW_TAG_structure_type',
+        '                  DW_AT_name\t("Foo")',
+        '                  DW_AT_byte_size\t(0x01)',
+        '',
+        '0x00000040:     NULL',
+        '0x00000041:   NULL',
+    ]
+    self.assertEqual(self.check(
+        lines,
+        dwarfdump('b.o', ('Foo', 8, [('a', 'int', 0)])),
+    ), [])
+
+
+if __name__ == '__main__':
+  unittest.main(verbosity=2)
@@ -0,0 +1,118 @@
+#!/usr/bin/env python3
+#
+# Copyright (C) 2022 The Android Open Source Project
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#      http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+"""A tool to check the total size of installed files against the budget of a
+size_budget module, using the installed-files.json written by Soong"""
+
+import argparse
+import json
+import sys
+
+
+def parse_args():
+  """Parse commandline arguments."""
+  parser = argparse.ArgumentParser(description=__doc__)
+  parser.add_argument('--name', required=True,
+                      help='name of the size_budget module')
+  parser.add_argument('--max-bytes', type=int, required=True,
+                      help='budget of the installed files in bytes')
+  parser.add_argument('--module', action='append', default=[],
+                      dest='modules',
+                      help='only count the files installed by the module')
+  parser.add_argument('--baseline', action='append', default=[],
+                      dest='baselines',
+                      help='installed-files.json of a previous build')
+  parser.add_argument('--top', type=int, default=10,
+                      help='number of the largest files to report')
+  parser.add_argument('--output', required=True,
+                      help='path to the report to write')
+  parser.add_argument('installed_files', nargs='+',
+                      help='installed-files.json to check')
+  return parser.parse_args()
+
+
+def load_sizes(paths, modules):
+  """Returns the sizes of the installed files listed in the installed-files.json
+  files, keyed by (name, module), optionally restricted to the modules."""
+  sizes = {}
+  for path in paths:
+    with open(path) as f:
+      for entry in json.load(f):
+        if modules and entry['Module'] not in modules:
+          continue
+        sizes[(entry['Name'], entry['Module'])] = entry.get('Size', 0)
+  return sizes
+
+
+def format_delta(delta):
+  """Formats a size delta with an explicit sign."""
+  return '%+d' % delta
+
+
+def check_size_budget(name, max_bytes, sizes, baseline, top):
+  """Returns whether the installed files fit in the budget, and the lines of
+  the report listing the largest files and the deltas from the baseline."""
+  total = sum(sizes.values())
+  ok = total <= max_bytes
+
+  report = ['size_budget %s: %d of %d bytes (%.1f%%)%s' %
+            (name, total, max_bytes, 100.0 * total / max_bytes if max_bytes else
+             0, '' if ok else ', OVER BUDGET by %d bytes' % (total - max_bytes))]
+  if baseline is not None:
+    report.append('delta from baseline: %s bytes' %
+                  format_delta(total - sum(baseline.values())))
+
+  largest = sorted(sizes.items(), key=lambda item: (-item[1], item[0]))[:top]
+  if largest:
+    report.append('')
+    report.append('largest files:')
+    for (file_name, module), size in largest:
+      line = '  %12d' % size
+      if baseline is not None:
+        line += ' %12s' % format_delta(size - baseline.get((file_name, module), 0))
+      report.append('%s  %s (%s)' % (line, file_name, module))
+
+  if baseline is not None:
+    grown = sorted(
+        ((size - baseline.get(key, 0), key) for key, size in sizes.items()
+         if size > baseline.get(key, 0)),
+        key=lambda item: (-item[0], item[1]))[:top]
+    if grown:
+      report.append('')
+      report.append('largest growth from baseline:')
+      for delta, (file_name, module) in grown:
+        report.append('  %12s  %s (%s)' % (format_delta(delta), file_name,
+                                            module))
+
+  return ok, report
+
+
+def main():
+  args = parse_args()
+  modules = set(args.modules)
+  sizes = load_sizes(args.installed_files, modules)
+  baseline = load_sizes(args.baselines, modules) if args.baselines else None
+
+  ok, report = check_size_budget(args.name, args.max_bytes, sizes, baseline,
+                                 args.top)
+  with open(args.output, 'w') as f:
+    f.write('\n'.join(report) + '\n')
+  if not ok:
+    sys.stderr.write('\n'.join(report) + '\n')
+    sys.exit(1)
+
+
+if __name__ == '__main__':
+  main()
@@ -0,0 +1,83 @@
+#!/usr/bin/env python3
+#
+# Copyright (C) 2022 The Android Open Source Project
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#      http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+#
+"""Unit tests for check_size_budget.py."""
+
+import json
+import os
+import tempfile
+import unittest
+
+import check_size_budget
+
+
+class CheckSizeBudgetTest(unittest.TestCase):
+
+  def test_load_sizes(self):
+    with tempfile.TemporaryDirectory() as tmp:
+      path = os.path.join(tmp, 'installed-files.json')
+      with open(path, 'w') as f:
+        json.dump([
+            {'Name': '/system/bin/foo', 'Module': 'foo', 'Size': 100},
+            {'Name': '/system/bin/foo_link', 'Module': 'foo',
+             'SymlinkTarget': 'foo'},
+            {'Name': '/system/bin/bar', 'Module': 'bar', 'Size': 50},
+        ], f)
+
+      self.assertEqual(check_size_budget.load_sizes([path], set()), {
+          ('/system/bin/foo', 'foo'): 100,
+          ('/system/bin/foo_link', 'foo'): 0,
+          ('/system/bin/bar', 'bar'): 50,
+      })
+      self.assertEqual(check_size_budget.load_sizes([path], {'bar'}), {
+          ('/system/bin/bar', 'bar'): 50,
+      })
+
+  def test_within_budget(self):
+    ok, report = check_size_budget.check_size_budget(
+        'budget', 200, {('/system/bin/foo', 'foo'): 150}, None, 10)
+    self.assertTrue(ok)
+    self.assertEqual(report[0], 'size_budget budget: 150 of 200 bytes (75.0%)')
+
+  def test_over_budget(self):
+    sizes = {
+        ('/system/bin/foo', 'foo'): 150,
+        ('/system/bin/bar', 'bar'): 100,
+        ('/system/bin/baz', 'baz'): 10,
+    }
+    baseline = {
+        ('/system/bin/foo', 'foo'): 100,
+        ('/system/bin/bar', 'bar'): 100,
+    }
+    ok, report = check_size_budget.check_size_budget(
+        'budget', 200, sizes, baseline, 2)
+    self.assertFalse(ok)
+    self.assertEqual(report, [
+        'size_budget budget: 260 of 200 bytes (130.0%), OVER BUDGET by 60 bytes',
+        'delta from baseline: +60 bytes',
+        '',
+        'largest files:',
+        '           150          +50  /system/bin/foo (foo)',
+        '           100           +0  /system/bin/bar (bar)',
+        '',
+        'largest growth from baseline:',
+        '           +50  /system/bin/foo (foo)',
+        '           +10  /system/bin/baz (baz)',
+    ])
+
+
+if __name__ == '__main__':
+  unittest.main(verbosity=2)
@@ -0,0 +1,120 @@
+#!/usr/bin/env python3
+#
+# Copyright (C) 2022 The Android Open Source Project
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#      http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+"""A tool to find the shared and static libraries that a linked binary or
+shared library depends on but that contributed no symbols to it"""
+
+import argparse
+import subprocess
+import sys
+
+
+def parse_args():
+  """Parse commandline arguments."""
+  parser = argparse.ArgumentParser(
+      description=__doc__, fromfile_prefix_chars='@')
+  parser.add_argument('--readelf', required=True,
+                      help='path to llvm-readelf')
+  parser.add_argument('--link-map', required=True,
+                      help='path to the link map written by lld with -Map')
+  parser.add_argument('--linked', required=True,
+                      help='path to the linked binary or shared library')
+  parser.add_argument('--shared-lib', action='append', default=[],
+                      dest='shared_libs', help='shared library to check')
+  parser.add_argument('--static-lib', action='append', default=[],
+                      dest='static_libs', help='static library to check')
+  parser.add_argument('--error', action='store_true',
+                      help='fail if there are unused libraries')
+  parser.add_argument('--output', required=True,
+                      help='path to the report of the unused libraries')
+  return parser.parse_args()
+
+
+def parse_dyn_syms(lines):
+  """Parses the output of llvm-readelf --dyn-syms --wide into the sets of
+  the names of the defined and the undefined global symbols."""
+  defined = set()
+  undefined = set()
+  for line in lines:
+    fields = line.split()
+    # Num: Value Size Type Bind Vis Ndx Name
+    if len(fields) < 8 or not fields[0].endswith(':'):
+      continue
+    bind, ndx, name = fields[4], fields[6], fields[7]
+    if bind not in ('GLOBAL', 'WEAK'):
+      continue
+    name = name.split('@')[0]
+    if ndx == 'UND':
+      undefined.add(name)
+    else:
+      defined.add(name)
+  return defined, undefined
+
+
+def used_static_libs(link_map_lines, static_libs):
+  """Returns the static libraries that have members listed as inputs in the
+  link map."""
+  used = set()
+  for line in link_map_lines:
+    for lib in static_libs:
+      if lib + '(' in line:
+        used.add(lib)
+  return used
+
+
+def find_unused_deps(link_map_lines, linked_syms, shared_lib_syms,
+                     static_libs):
+  """Returns the shared libraries that define none of the undefined symbols
+  of the linked output and the static libraries that contributed no members
+  to it, in the order they were given."""
+  _, undefined = linked_syms
+  unused = []
+  for lib, (defined, _) in shared_lib_syms:
+    if not defined & undefined:
+      unused.append(lib)
+  used = used_static_libs(link_map_lines, static_libs)
+  unused.extend(lib for lib in static_libs if lib not in used)
+  return unused
+
+
+def read_dyn_syms(readelf, path):
+  result = subprocess.run([readelf, '--dyn-syms', '--wide', path],
+                          stdout=subprocess.PIPE, universal_newlines=True,
+                          check=False)
+  if result.returncode != 0:
+    sys.exit('%s: llvm-readelf failed' % path)
+  return parse_dyn_syms(result.stdout.splitlines())
+
+
+def main():
+  args = parse_args()
+  with open(args.link_map) as f:
+    link_map_lines = f.read().splitlines()
+  linked_syms = read_dyn_syms(args.readelf, args.linked)
+  shared_lib_syms = [(lib, read_dyn_syms(args.readelf, lib))
+                     for lib in args.shared_libs]
+  unused = find_unused_deps(link_map_lines, linked_syms, shared_lib_syms,
+                            args.static_libs)
+  report = ''.join('%s: no symbols used\n' % lib for lib in unused)
+  if unused and args.error:
+    sys.exit('%s: unused dependencies:\n%s'
+             'Remove them from shared_libs or static_libs.' %
+             (args.linked, report))
+  with open(args.output, 'w') as f:
+    f.write(report)
+
+
+if __name__ == '__main__':
+  main()
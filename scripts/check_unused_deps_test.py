@@ -0,0 +1,88 @@
+#!/usr/bin/env python3
+#
+# Copyright (C) 2022 The Android Open Source Project
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#      http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+#
+"""Unit tests for check_unused_deps.py."""
+
+import unittest
+
+import check_unused_deps
+
+
+def dyn_syms(*symbols):
+  """Returns fake llvm-readelf --dyn-syms --wide output for the symbols, each
+  a (name, bind, ndx) tuple."""
+  lines = [
+      '',
+      "Symbol table '.dynsym' contains %d entries:" % (len(symbols) + 1),
+      '   Num:    Value          Size Type    Bind   Vis       Ndx Name',
+      '     0: 0000000000000000     0 NOTYPE  LOCAL  DEFAULT   UND ',
+  ]
+  for i, (name, bind, ndx) in enumerate(symbols):
+    lines.append('     %d: 0000000000001000     8 FUNC    %s %s DEFAULT %s %s' %
+                 (i + 1, bind, '' if bind == 'GLOBAL' else ' ', ndx, name))
+  return lines
+
+
+LINK_MAP = [
+    '             VMA              LMA     Size Align Out     In      Symbol',
+    '             2a8              2a8       15     1 .interp',
+    '           1c000            1c000       1c     4 .text',
+    '           1c000            1c000        8     4         '
+    'out/foo.o:(.text)',
+    '           1c008            1c008       14     4         '
+    'out/libbar.a(bar.o):(.text.bar)',
+]
+
+
+class CheckUnusedDepsTest(unittest.TestCase):
+  """Unit tests for check_unused_deps."""
+
+  def test_parse_dyn_syms(self):
+    defined, undefined = check_unused_deps.parse_dyn_syms(dyn_syms(
+        ('foo', 'GLOBAL', '12'),
+        ('bar@LIBBAR', 'WEAK', '12'),
+        ('malloc@LIBC', 'GLOBAL', 'UND'),
+        ('local', 'LOCAL', '12'),
+    ))
+    self.assertEqual(defined, {'foo', 'bar'})
+    self.assertEqual(undefined, {'malloc'})
+
+  def test_all_used(self):
+    linked = check_unused_deps.parse_dyn_syms(dyn_syms(
+        ('baz', 'GLOBAL', 'UND')))
+    libbaz = check_unused_deps.parse_dyn_syms(dyn_syms(
+        ('baz', 'GLOBAL', '12')))
+    unused = check_unused_deps.find_unused_deps(
+        LINK_MAP, linked, [('out/libbaz.so', libbaz)], ['out/libbar.a'])
+    self.assertEqual(unused, [])
+
+  def test_unused(self):
+    linked = check_unused_deps.parse_dyn_syms(dyn_syms(
+        ('baz', 'GLOBAL', 'UND')))
+    libbaz = check_unused_deps.parse_dyn_syms(dyn_syms(
+        ('baz', 'GLOBAL', '12')))
+    libqux = check_unused_deps.parse_dyn_syms(dyn_syms(
+        ('qux', 'GLOBAL', '12'),
+        ('baz', 'GLOBAL', 'UND')))
+    unused = check_unused_deps.find_unused_deps(
+        LINK_MAP, linked,
+        [('out/libbaz.so', libbaz), ('out/libqux.so', libqux)],
+        ['out/libbar.a', 'out/libquux.a'])
+    self.assertEqual(unused, ['out/libqux.so', 'out/libquux.a'])
+
+
+if __name__ == '__main__':
+  unittest.main(verbosity=2)
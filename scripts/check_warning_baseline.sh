@@ -0,0 +1,64 @@
+#!/bin/bash -eu
+
+# Copyright 2022 Google Inc. All rights reserved.
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+# Script to check the counts of the warnings in the clang output of the sources of a module
+# against the warning_baseline of the module.
+# Arguments:
+#  ${stamp}: output file touched if the check passes
+#  ${warning}:${count}...: baseline counts of the warnings, without -W
+#  --: end of the baselines
+#  ${file}...: clang output of the sources of the module
+
+if [ $# -lt 2 ]; then
+    echo "usage: $0 <stamp> <warning>:<count>... -- <clang output>..." >&2
+    exit 1
+fi
+
+stamp=$1
+shift
+
+baselines=()
+while [ $# -gt 0 ] && [ "$1" != "--" ]; do
+    baselines+=("$1")
+    shift
+done
+if [ $# -gt 0 ]; then
+    shift
+fi
+
+failed=false
+for baseline in "${baselines[@]}"; do
+    warning=${baseline%%:*}
+    limit=${baseline#*:}
+    # The warnings in headers are reported for each source that includes them, count each
+    # file:line:column once.
+    warnings=$(cat /dev/null "$@" | grep -F -e "[-W${warning}]" | sort -u -t: -k1,3 || true)
+    count=$(echo -n "${warnings}" | grep -c -E '^[^:]+:[0-9]+:[0-9]+:' || true)
+    if [ "${count}" -gt "${limit}" ]; then
+        echo "error: found ${count} -W${warning} warnings, more than the baseline of ${limit}." >&2
+        echo "${warnings}" >&2
+        failed=true
+    elif [ "${count}" -lt "${limit}" ]; then
+        echo "note: found ${count} -W${warning} warnings, lower the baseline of ${limit} to ${count}."
+    fi
+done
+
+if [ "${failed}" = true ]; then
+    echo "Fix the new warnings, or update warning_baseline if they are expected." >&2
+    exit 1
+fi
+
+touch "${stamp}"
@@ -0,0 +1,93 @@
+#!/usr/bin/env python3
+#
+# Copyright (C) 2022 The Android Open Source Project
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#      http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+"""A tool to expand the variables referenced by a template.
+
+A variable is referenced as {{NAME}}, optionally with spaces around the name.
+Every {{ must start a reference to one of the given variables, and every given
+variable must be referenced at least once.
+"""
+
+import argparse
+import json
+import re
+import sys
+
+REFERENCE_RE = re.compile(r'\{\{\s*([A-Za-z0-9_.:-]+)\s*\}\}')
+
+
+class TemplateError(Exception):
+  """An error in a template."""
+
+
+def parse_args():
+  """Parse commandline arguments."""
+  parser = argparse.ArgumentParser(description=__doc__)
+  parser.add_argument('--variables', required=True,
+                      help='JSON file with a map of the variables to expand')
+  parser.add_argument('input', help='template to expand')
+  parser.add_argument('output', help='path to the expanded file to write')
+  return parser.parse_args()
+
+
+def expand(template, variables):
+  """Returns template with the references to variables replaced by their values."""
+  used = set()
+  lines = []
+  for lineno, line in enumerate(template.splitlines(keepends=True), 1):
+    out = []
+    pos = 0
+    while True:
+      start = line.find('{{', pos)
+      if start < 0:
+        out.append(line[pos:])
+        break
+      match = REFERENCE_RE.match(line, start)
+      if not match:
+        raise TemplateError('line %d: malformed variable reference: %s' %
+                            (lineno, line[start:].rstrip('\n')))
+      name = match.group(1)
+      if name not in variables:
+        raise TemplateError('line %d: reference to undeclared variable %s' %
+                            (lineno, name))
+      used.add(name)
+      out.append(line[pos:start])
+      out.append(variables[name])
+      pos = match.end()
+    lines.append(''.join(out))
+
+  unused = sorted(set(variables) - used)
+  if unused:
+    raise TemplateError('declared variables not referenced by the template: %s' %
+                        ', '.join(unused))
+  return ''.join(lines)
+
+
+def main():
+  args = parse_args()
+  with open(args.variables) as f:
+    variables = json.load(f)
+  with open(args.input) as f:
+    template = f.read()
+  try:
+    expanded = expand(template, variables)
+  except TemplateError as e:
+    sys.exit('%s: %s' % (args.input, e))
+  with open(args.output, 'w') as f:
+    f.write(expanded)
+
+
+if __name__ == '__main__':
+  main()
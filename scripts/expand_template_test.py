@@ -0,0 +1,56 @@
+#!/usr/bin/env python3
+#
+# Copyright (C) 2022 The Android Open Source Project
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#      http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+#
+"""Unit tests for expand_template.py."""
+
+import unittest
+
+import expand_template
+
+
+class ExpandTemplateTest(unittest.TestCase):
+  """Unit tests for expand_template."""
+
+  def test_expand(self):
+    expanded = expand_template.expand(
+        'product={{PRODUCT_NAME}}\nsdk={{ PLATFORM_SDK_VERSION }}\n'
+        'board={{soong_config:acme:board}} {{PRODUCT_NAME}}\n',
+        {
+            'PRODUCT_NAME': 'aosp_arm64',
+            'PLATFORM_SDK_VERSION': '33',
+            'soong_config:acme:board': 'x{{y}}',
+        })
+    self.assertEqual(expanded,
+                     'product=aosp_arm64\nsdk=33\nboard=x{{y}} aosp_arm64\n')
+
+  def test_undeclared_variable(self):
+    with self.assertRaisesRegex(expand_template.TemplateError,
+                                'line 2: reference to undeclared variable FOO'):
+      expand_template.expand('a={{A}}\nfoo={{FOO}}\n', {'A': 'a'})
+
+  def test_malformed_reference(self):
+    with self.assertRaisesRegex(expand_template.TemplateError,
+                                'line 1: malformed variable reference: {{A'):
+      expand_template.expand('a={{A\n', {'A': 'a'})
+
+  def test_unused_variable(self):
+    with self.assertRaisesRegex(expand_template.TemplateError,
+                                'not referenced by the template: B, C'):
+      expand_template.expand('a={{A}}\n', {'A': 'a', 'B': 'b', 'C': 'c'})
+
+
+if __name__ == '__main__':
+  unittest.main(verbosity=2)
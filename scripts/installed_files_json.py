@@ -0,0 +1,67 @@
+#!/usr/bin/env python3
+#
+# Copyright (C) 2022 The Android Open Source Project
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#      http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+"""A tool to add the size and the SHA-256 hash of every installed file to the
+manifest of the installed files of a partition written by Soong"""
+
+import argparse
+import hashlib
+import json
+import os
+
+
+def parse_args():
+  """Parse commandline arguments."""
+  parser = argparse.ArgumentParser(description=__doc__)
+  parser.add_argument('--manifest', required=True,
+                      help='path to the manifest of the installed files')
+  parser.add_argument('--output', required=True,
+                      help='path to the installed-files.json to write')
+  return parser.parse_args()
+
+
+def sha256(path):
+  """Returns the hex SHA-256 hash of the contents of the file."""
+  h = hashlib.sha256()
+  with open(path, 'rb') as f:
+    for chunk in iter(lambda: f.read(1 << 20), b''):
+      h.update(chunk)
+  return h.hexdigest()
+
+
+def add_sizes_and_hashes(files):
+  """Adds the Size and SHA256 of the source of each installed file that is
+  not a symlink."""
+  for f in files:
+    source = f.get('Source')
+    if not source:
+      continue
+    f['Size'] = os.path.getsize(source)
+    f['SHA256'] = sha256(source)
+  return files
+
+
+def main():
+  args = parse_args()
+  with open(args.manifest) as f:
+    files = json.load(f)
+  add_sizes_and_hashes(files)
+  with open(args.output, 'w') as f:
+    json.dump(files, f, indent=2, sort_keys=True)
+    f.write('\n')
+
+
+if __name__ == '__main__':
+  main()
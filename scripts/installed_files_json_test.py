@@ -0,0 +1,48 @@
+#!/usr/bin/env python3
+#
+# Copyright (C) 2022 The Android Open Source Project
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#      http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+#
+"""Unit tests for installed_files_json.py."""
+
+import hashlib
+import os
+import tempfile
+import unittest
+
+import installed_files_json
+
+
+class InstalledFilesJsonTest(unittest.TestCase):
+
+  def test_add_sizes_and_hashes(self):
+    with tempfile.TemporaryDirectory() as tmp:
+      source = os.path.join(tmp, 'foo')
+      with open(source, 'wb') as f:
+        f.write(b'foo contents')
+
+      files = installed_files_json.add_sizes_and_hashes([
+          {'Name': '/system/bin/foo', 'Module': 'foo', 'Source': source},
+          {'Name': '/system/bin/bar', 'Module': 'foo', 'SymlinkTarget': 'foo'},
+      ])
+
+      self.assertEqual(files[0]['Size'], len(b'foo contents'))
+      self.assertEqual(files[0]['SHA256'],
+                       hashlib.sha256(b'foo contents').hexdigest())
+      self.assertNotIn('Size', files[1])
+      self.assertNotIn('SHA256', files[1])
+
+
+if __name__ == '__main__':
+  unittest.main(verbosity=2)
@@ -0,0 +1,75 @@
+#!/usr/bin/env python3
+#
+# Copyright (C) 2022 The Android Open Source Project
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#      http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+"""A tool to write a snapshot of the contents of the jars on the classpath of
+kotlinc, which only changes when the classes or the Kotlin module metadata in
+the jars change, and not when the jars are rewritten with new timestamps or in
+a different order"""
+
+import argparse
+import hashlib
+import os
+import zipfile
+
+
+def parse_args():
+  """Parse commandline arguments."""
+  parser = argparse.ArgumentParser(
+      description=__doc__, fromfile_prefix_chars='@')
+  parser.add_argument('--output', required=True,
+                      help='path to the snapshot to write if it changed')
+  parser.add_argument('jars', nargs='*', help='classpath jars')
+  return parser.parse_args()
+
+
+def is_snapshot_entry(name):
+  """Returns whether a jar entry affects the compilation of Kotlin sources."""
+  return name.endswith('.class') or (name.startswith('META-INF/') and
+                                     name.endswith('.kotlin_module'))
+
+
+def snapshot(jars):
+  """Returns the lines of the snapshot of the jars, in classpath order."""
+  lines = []
+  for jar in jars:
+    lines.append(jar)
+    if not os.path.exists(jar):
+      continue
+    with zipfile.ZipFile(jar) as z:
+      for name in sorted(z.namelist()):
+        if is_snapshot_entry(name):
+          lines.append('  %s %s' % (hashlib.sha256(z.read(name)).hexdigest(),
+                                    name))
+  return lines
+
+
+def write_if_changed(path, content):
+  """Writes the content to the file unless it already has the content, to let
+  ninja skip the rules that depend on it."""
+  if os.path.exists(path):
+    with open(path) as f:
+      if f.read() == content:
+        return
+  with open(path, 'w') as f:
+    f.write(content)
+
+
+def main():
+  args = parse_args()
+  write_if_changed(args.output, '\n'.join(snapshot(args.jars)) + '\n')
+
+
+if __name__ == '__main__':
+  main()
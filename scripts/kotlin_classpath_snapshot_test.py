@@ -0,0 +1,77 @@
+#!/usr/bin/env python3
+#
+# Copyright (C) 2022 The Android Open Source Project
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#      http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+#
+"""Unit tests for kotlin_classpath_snapshot.py."""
+
+import os
+import tempfile
+import unittest
+import zipfile
+
+import kotlin_classpath_snapshot
+
+
+def write_jar(path, entries, date_time=(1980, 1, 1, 0, 0, 0)):
+  """Writes a jar with the (name, content) entries."""
+  with zipfile.ZipFile(path, 'w') as z:
+    for name, content in entries:
+      z.writestr(zipfile.ZipInfo(name, date_time), content)
+
+
+class KotlinClasspathSnapshotTest(unittest.TestCase):
+
+  def test_ignores_timestamps_order_and_resources(self):
+    with tempfile.TemporaryDirectory() as tmp:
+      jar = os.path.join(tmp, 'lib.jar')
+      write_jar(jar, [
+          ('com/foo/Foo.class', b'foo'),
+          ('com/foo/Bar.class', b'bar'),
+          ('META-INF/lib.kotlin_module', b'module'),
+      ])
+      before = kotlin_classpath_snapshot.snapshot([jar])
+
+      write_jar(jar, [
+          ('com/foo/Bar.class', b'bar'),
+          ('com/foo/Foo.class', b'foo'),
+          ('META-INF/lib.kotlin_module', b'module'),
+          ('res/values.txt', b'resource'),
+      ], date_time=(2022, 1, 1, 0, 0, 0))
+      after = kotlin_classpath_snapshot.snapshot([jar])
+
+      self.assertEqual(before, after)
+      self.assertEqual(len(before), 4)
+
+  def test_class_change(self):
+    with tempfile.TemporaryDirectory() as tmp:
+      jar = os.path.join(tmp, 'lib.jar')
+      write_jar(jar, [('com/foo/Foo.class', b'foo')])
+      before = kotlin_classpath_snapshot.snapshot([jar])
+      write_jar(jar, [('com/foo/Foo.class', b'changed')])
+      self.assertNotEqual(before, kotlin_classpath_snapshot.snapshot([jar]))
+
+  def test_write_if_changed(self):
+    with tempfile.TemporaryDirectory() as tmp:
+      path = os.path.join(tmp, 'snapshot')
+      kotlin_classpath_snapshot.write_if_changed(path, 'content\n')
+      os.utime(path, (0, 0))
+      kotlin_classpath_snapshot.write_if_changed(path, 'content\n')
+      self.assertEqual(os.path.getmtime(path), 0)
+      kotlin_classpath_snapshot.write_if_changed(path, 'changed\n')
+      self.assertNotEqual(os.path.getmtime(path), 0)
+
+
+if __name__ == '__main__':
+  unittest.main(verbosity=2)
@@ -67,6 +67,22 @@ def parse_args():
         '--enforce-uses-libraries-status',
         dest='enforce_uses_libraries_status',
         help='output file to store check status (error message)')
+    parser.add_argument(
+        '--enforce-uses-libraries-report',
+        dest='enforce_uses_libraries_report',
+        help='output JSON file to store the <uses-library> lists of the build '
+        'system, the manifest and the on-device class loader context')
+    parser.add_argument(
+        '--enforce-uses-libraries-fix',
+        dest='enforce_uses_libraries_fix',
+        help='output file to store the uses_libs and optional_uses_libs '
+        'properties that would make the build system agree with the manifest')
+    parser.add_argument(
+        '--on-device-library',
+        dest='on_device_libraries',
+        action='append',
+        help='specify a library in the on-device class loader context as '
+        'name=path')
     parser.add_argument(
         '--extract-target-sdk-version',
         dest='extract_target_sdk_version',
@@ -91,7 +107,8 @@ C_OFF = "\033[0m"
 C_BOLD = "\033[1m"
 
 
-def enforce_uses_libraries(manifest, required, optional, relax, is_apk, path):
+def enforce_uses_libraries(manifest, required, optional, relax, is_apk, path,
+                           fix_path=None):
     """Verify that the <uses-library> tags in the manifest match those provided
 
   by the build system.
@@ -102,13 +119,10 @@ def enforce_uses_libraries(manifest, required, optional, relax, is_apk, path):
     optional: optional libs known to the build system
     relax:    if true, suppress error on mismatch and just write it to file
     is_apk:   if the manifest comes from an APK or an XML file
+    fix_path: file with the properties that fix the mismatch, if any
     """
-    if is_apk:
-        manifest_required, manifest_optional, tags = extract_uses_libs_apk(
-            manifest)
-    else:
-        manifest_required, manifest_optional, tags = extract_uses_libs_xml(
-            manifest)
+    manifest_required, manifest_optional, tags = extract_uses_libs(
+        manifest, is_apk)
 
     # Trim namespace component. Normally Soong does that automatically when it
     # handles module names specified in Android.bp properties. However not all
@@ -139,6 +153,8 @@ def enforce_uses_libraries(manifest, required, optional, relax, is_apk, path):
         '\t- to temporarily disable the check for the whole product, set ',
         '%sPRODUCT_BROKEN_VERIFY_USES_LIBRARIES := true%s in the product makefiles\n' % (C_BOLD, C_OFF),
         '\t- to fix the check, make build system properties coherent with the manifest\n',
+        ('\t- the uses_libs and optional_uses_libs properties that match the manifest are in %s%s%s\n'
+         % (C_BOLD, fix_path, C_OFF)) if fix_path else '',
         '\t- for details, see %sbuild/make/Changes.md%s' % (C_GREEN, C_OFF),
         ' and %shttps://source.android.com/devices/tech/dalvik/art-class-loader-context%s\n' % (C_GREEN, C_OFF)
     ])
@@ -150,6 +166,78 @@ def enforce_uses_libraries(manifest, required, optional, relax, is_apk, path):
     return errmsg
 
 
+def uses_libraries_report(manifest, required, optional, on_device, is_apk):
+    """Describe the <uses-library> lists of the build system and the manifest.
+
+  Args:
+    manifest:  manifest (either parsed XML or aapt dump of APK)
+    required:  required libs known to the build system
+    optional:  optional libs known to the build system
+    on_device: map of library names in the on-device class loader context to
+               their paths on device
+    is_apk:    if the manifest comes from an APK or an XML file
+    """
+    manifest_required, manifest_optional, _ = extract_uses_libs(
+        manifest, is_apk)
+    required = trim_namespace_parts(required)
+    optional = trim_namespace_parts(optional)
+
+    def missing(libs, other_libs):
+        return [lib for lib in libs if lib not in other_libs]
+
+    return {
+        'match': (manifest_required == required and
+                  manifest_optional == optional),
+        'build_system': {
+            'required': required,
+            'optional': optional,
+        },
+        'manifest': {
+            'required': manifest_required,
+            'optional': manifest_optional,
+        },
+        'on_device': on_device,
+        'missing_from_manifest': {
+            'required': missing(required, manifest_required),
+            'optional': missing(optional, manifest_optional),
+        },
+        'missing_from_build_system': {
+            'required': missing(manifest_required, required),
+            'optional': missing(manifest_optional, optional),
+        },
+    }
+
+
+def uses_libraries_fix(report, libname_to_module):
+    """Return the uses_libs and optional_uses_libs properties that make the
+
+  build system agree with the manifest, or an empty string if they already
+  agree.
+
+  Args:
+    report:            the report returned by uses_libraries_report
+    libname_to_module: map of library names to the modules providing them
+    """
+    if report['match']:
+        return ''
+
+    def prop(name, libs):
+        modules = [libname_to_module.get(lib, lib) for lib in libs]
+        return '%s: [%s],\n' % (name, ', '.join('"%s"' % m for m in modules))
+
+    return (prop('uses_libs', report['manifest']['required']) +
+            prop('optional_uses_libs', report['manifest']['optional']))
+
+
+def parse_on_device_libraries(libs):
+    """Parse name=path pairs of the on-device class loader context."""
+    on_device = {}
+    for lib in libs or []:
+        name, _, path = lib.partition('=')
+        on_device[name] = path
+    return on_device
+
+
 MODULE_NAMESPACE = re.compile('^//[^:]+:')
 
 
@@ -165,6 +253,13 @@ def trim_namespace_parts(modules):
     return trimmed
 
 
+def extract_uses_libs(manifest, is_apk):
+    """Extract <uses-library> tags from the manifest (XML or aapt dump)."""
+    if is_apk:
+        return extract_uses_libs_apk(manifest)
+    return extract_uses_libs_xml(manifest)
+
+
 def extract_uses_libs_apk(badging):
     """Extract <uses-library> tags from the manifest of an APK."""
 
@@ -351,12 +446,30 @@ def main():
             optional = translate_libnames(args.optional_uses_libraries,
                                           mod_to_lib)
 
+            # Write the structured report and the suggested fix before the
+            # check, so that they are available when the check fails.
+            if (args.enforce_uses_libraries_report or
+                    args.enforce_uses_libraries_fix):
+                report = uses_libraries_report(
+                    manifest, required, optional,
+                    parse_on_device_libraries(args.on_device_libraries),
+                    is_apk)
+                if args.enforce_uses_libraries_report:
+                    with open(args.enforce_uses_libraries_report, 'w') as f:
+                        json.dump(report, f, indent=2, sort_keys=True)
+                        f.write('\n')
+                if args.enforce_uses_libraries_fix:
+                    lib_to_mod = {v: k for k, v in mod_to_lib.items()}
+                    with open(args.enforce_uses_libraries_fix, 'w') as f:
+                        f.write(uses_libraries_fix(report, lib_to_mod))
+
             # Check if the <uses-library> lists in the build system agree with
             # those in the manifest. Raise an exception on mismatch, unless the
             # script was passed a special parameter to suppress exceptions.
             errmsg = enforce_uses_libraries(manifest, required, optional,
                                             args.enforce_uses_libraries_relax,
-                                            is_apk, args.input)
+                                            is_apk, args.input,
+                                            args.enforce_uses_libraries_fix)
 
             # Create a status file that is empty on success, or contains an
             # error message on failure. When exceptions are suppressed,
@@ -224,6 +224,45 @@ class EnforceUsesLibrariesTest(unittest.TestCase):
         self.assertTrue(matches)
 
 
+class UsesLibrariesReportTest(unittest.TestCase):
+    """Unit tests for uses_libraries_report and uses_libraries_fix functions."""
+
+    xml_tmpl = EnforceUsesLibrariesTest.xml_tmpl
+
+    def test_match(self):
+        xml = self.xml_tmpl % (uses_library_xml('foo'))
+        report = manifest_check.uses_libraries_report(
+            minidom.parseString(xml), ['foo'], [], {}, False)
+        self.assertTrue(report['match'])
+        self.assertEqual(manifest_check.uses_libraries_fix(report, {}), '')
+
+    def test_mismatch(self):
+        xml = self.xml_tmpl % ('\n'.join([
+            uses_library_xml('foo'),
+            uses_library_xml('bar', required_xml(False))
+        ]))
+        report = manifest_check.uses_libraries_report(
+            minidom.parseString(xml), ['foo', 'baz'], [],
+            {'foo': '/system/framework/foo.jar'}, False)
+        self.assertFalse(report['match'])
+        self.assertEqual(report['on_device'],
+                         {'foo': '/system/framework/foo.jar'})
+        self.assertEqual(report['missing_from_manifest'],
+                         {'required': ['baz'], 'optional': []})
+        self.assertEqual(report['missing_from_build_system'],
+                         {'required': [], 'optional': ['bar']})
+        self.assertEqual(
+            manifest_check.uses_libraries_fix(report, {'foo': 'foo-module'}),
+            'uses_libs: ["foo-module"],\noptional_uses_libs: ["bar"],\n')
+
+    def test_parse_on_device_libraries(self):
+        self.assertEqual(
+            manifest_check.parse_on_device_libraries(
+                ['foo=/system/framework/foo.jar']),
+            {'foo': '/system/framework/foo.jar'})
+        self.assertEqual(manifest_check.parse_on_device_libraries(None), {})
+
+
 class ExtractTargetSdkVersionTest(unittest.TestCase):
 
     def run_test(self, xml, apk, version):
@@ -0,0 +1,120 @@
+#!/usr/bin/env python3
+#
+# Copyright (C) 2022 The Android Open Source Project
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#      http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+"""A tool to combine the .su files written by -fstack-usage into a JSON report"""
+
+import argparse
+import json
+import sys
+
+
+def parse_args():
+  """Parse commandline arguments."""
+  parser = argparse.ArgumentParser(
+      description=__doc__, fromfile_prefix_chars='@')
+  parser.add_argument('--output', required=True,
+                      help='path to the JSON report to write')
+  parser.add_argument('--memory-usage',
+                      help='path to the output of the linker with '
+                      '--print-memory-usage')
+  parser.add_argument('inputs', nargs='*', help='.su files to combine')
+  return parser.parse_args()
+
+
+def parse_stack_usage(lines):
+  """Parses the lines of a .su file into a list of function entries.
+
+  Each line is "<file>:<line>:<column>:<function>\t<bytes>\t<qualifiers>", where
+  the qualifiers are a comma separated list of static, dynamic and bounded.
+  """
+  functions = []
+  for line in lines:
+    line = line.rstrip('\n')
+    if not line:
+      continue
+    fields = line.split('\t')
+    if len(fields) != 3:
+      raise ValueError('malformed stack usage line: %r' % line)
+    location, function = fields[0].rsplit(':', 1)
+    functions.append({
+        'function': function,
+        'location': location,
+        'stack_size': int(fields[1]),
+        'qualifiers': fields[2].split(','),
+    })
+  return functions
+
+
+def parse_memory_usage(lines):
+  """Parses the output of the linker with --print-memory-usage into a list of
+  memory regions.
+
+  The output is a header followed by a line per region of the linker script:
+  "<region>: <used size> <unit> <region size> <unit> <percentage>%". Other
+  lines printed by the linker are ignored.
+  """
+  regions = []
+  for line in lines:
+    region, sep, usage = line.strip().partition(':')
+    fields = usage.split()
+    if not sep or len(fields) != 5 or not fields[4].endswith('%'):
+      continue
+    regions.append({
+        'region': region,
+        'used_size': ' '.join(fields[0:2]),
+        'region_size': ' '.join(fields[2:4]),
+        'percent_used': float(fields[4][:-1]),
+    })
+  return regions
+
+
+def make_report(functions, memory_regions=None):
+  """Returns the report for the given functions, sorted from the largest."""
+  functions = sorted(functions,
+                     key=lambda f: (-f['stack_size'], f['location'], f['function']))
+  report = {
+      'max_stack_size': functions[0]['stack_size'] if functions else 0,
+      # Functions using dynamic stack allocations have no fixed worst case size.
+      'unbounded_functions': [f['function'] for f in functions
+                              if 'dynamic' in f['qualifiers'] and
+                              'bounded' not in f['qualifiers']],
+      'functions': functions,
+  }
+  if memory_regions is not None:
+    report['memory_regions'] = memory_regions
+  return report
+
+
+def main():
+  args = parse_args()
+  functions = []
+  for path in args.inputs:
+    with open(path) as f:
+      try:
+        functions.extend(parse_stack_usage(f))
+      except ValueError as e:
+        sys.exit('%s: %s' % (path, e))
+  memory_regions = None
+  if args.memory_usage:
+    with open(args.memory_usage) as f:
+      memory_regions = parse_memory_usage(f)
+  with open(args.output, 'w') as f:
+    json.dump(make_report(functions, memory_regions), f, indent=2,
+              sort_keys=True)
+    f.write('\n')
+
+
+if __name__ == '__main__':
+  main()
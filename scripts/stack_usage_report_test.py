@@ -0,0 +1,82 @@
+#!/usr/bin/env python3
+#
+# Copyright (C) 2022 The Android Open Source Project
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#      http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+#
+"""Unit tests for stack_usage_report.py."""
+
+import unittest
+
+import stack_usage_report
+
+
+class StackUsageReportTest(unittest.TestCase):
+  """Unit tests for stack_usage_report."""
+
+  def test_parse_stack_usage(self):
+    functions = stack_usage_report.parse_stack_usage([
+        'foo.c:3:6:small\t16\tstatic\n',
+        '\n',
+        'foo.c:8:6:alloca_user\t32\tdynamic\n',
+    ])
+    self.assertEqual(functions, [
+        {'function': 'small', 'location': 'foo.c:3:6', 'stack_size': 16,
+         'qualifiers': ['static']},
+        {'function': 'alloca_user', 'location': 'foo.c:8:6', 'stack_size': 32,
+         'qualifiers': ['dynamic']},
+    ])
+
+  def test_parse_malformed_line(self):
+    with self.assertRaises(ValueError):
+      stack_usage_report.parse_stack_usage(['foo.c:3:6:small 16 static'])
+
+  def test_make_report(self):
+    report = stack_usage_report.make_report(
+        stack_usage_report.parse_stack_usage([
+            'foo.c:3:6:small\t16\tstatic',
+            'bar.c:1:6:big\t256\tstatic',
+            'foo.c:8:6:alloca_user\t32\tdynamic',
+            'foo.c:12:6:vla_user\t48\tdynamic,bounded',
+        ]))
+    self.assertEqual(report['max_stack_size'], 256)
+    self.assertEqual(report['unbounded_functions'], ['alloca_user'])
+    self.assertEqual([f['function'] for f in report['functions']],
+                     ['big', 'vla_user', 'alloca_user', 'small'])
+
+  def test_make_empty_report(self):
+    report = stack_usage_report.make_report([])
+    self.assertEqual(report['max_stack_size'], 0)
+    self.assertEqual(report['functions'], [])
+    self.assertNotIn('memory_regions', report)
+
+  def test_parse_memory_usage(self):
+    regions = stack_usage_report.parse_memory_usage([
+        'Memory region         Used Size  Region Size  %age Used\n',
+        '           FLASH:        4112 B       256 KB      1.57%\n',
+        '             RAM:         16 KB        64 KB     25.00%\n',
+    ])
+    self.assertEqual(regions, [
+        {'region': 'FLASH', 'used_size': '4112 B', 'region_size': '256 KB',
+         'percent_used': 1.57},
+        {'region': 'RAM', 'used_size': '16 KB', 'region_size': '64 KB',
+         'percent_used': 25.0},
+    ])
+
+  def test_make_report_with_memory_usage(self):
+    report = stack_usage_report.make_report([], [])
+    self.assertEqual(report['memory_regions'], [])
+
+
+if __name__ == '__main__':
+  unittest.main(verbosity=2)
@@ -0,0 +1,80 @@
+// Copyright (C) 2022 The Android Open Source Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sdk
+
+import (
+	"sort"
+	"strings"
+
+	"android/soong/android"
+	"android/soong/apex"
+)
+
+func sdkApexMembersCheckSingletonFactory() android.Singleton {
+	return &sdkApexMembersCheckSingleton{}
+}
+
+// sdkApexMembersCheckSingleton checks that the modules exported by the APEXes listed in the apexes
+// property of an sdk are all members of the sdk.
+type sdkApexMembersCheckSingleton struct{}
+
+func (c *sdkApexMembersCheckSingleton) GenerateBuildActions(ctx android.SingletonContext) {
+	apexMembers := make(map[string]apex.ApexSdkMembersInfo)
+	var sdks []*sdk
+	ctx.VisitAllModules(func(module android.Module) {
+		if ctx.ModuleHasProvider(module, apex.ApexSdkMembersInfoProvider) {
+			apexMembers[ctx.ModuleName(module)] = ctx.ModuleProvider(module, apex.ApexSdkMembersInfoProvider).(apex.ApexSdkMembersInfo)
+		}
+		// The properties are the same in all the variants, so only check the CommonOS variant.
+		if s, ok := module.(*sdk); ok && s.IsCommonOSVariant() && !s.snapshot() && len(s.properties.Apexes) > 0 {
+			sdks = append(sdks, s)
+		}
+	})
+
+	for _, s := range sdks {
+		sdkMembers := make(map[string][]string)
+		for _, memberListProperty := range s.memberTypeListProperties() {
+			if memberListProperty.getter == nil {
+				continue
+			}
+			property := memberListProperty.propertyName()
+			sdkMembers[property] = append(sdkMembers[property],
+				memberListProperty.getter(s.dynamicMemberTypeListProperties)...)
+		}
+
+		for _, apexName := range s.properties.Apexes {
+			info, ok := apexMembers[apexName]
+			if !ok {
+				ctx.ModuleErrorf(s, "apexes: %q is not an apex module", apexName)
+				continue
+			}
+
+			var missing []string
+			for property, names := range info.Members {
+				for _, name := range names {
+					if !android.InList(name, sdkMembers[property]) {
+						missing = append(missing, property+": "+name)
+					}
+				}
+			}
+			if len(missing) > 0 {
+				sort.Strings(missing)
+				ctx.ModuleErrorf(s, "apex %q exports modules that are not members of this sdk:\n    %s\n"+
+					"Add them to the sdk, build %s-sdk-members for the list of modules that the apex exports.",
+					apexName, strings.Join(missing, "\n    "), apexName)
+			}
+		}
+	}
+}
@@ -0,0 +1,127 @@
+// Copyright (C) 2022 The Android Open Source Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sdk
+
+import (
+	"testing"
+
+	"github.com/google/blueprint/proptools"
+
+	"android/soong/android"
+)
+
+const apexMembersTestBp = `
+	apex {
+		name: "myapex",
+		key: "myapex.key",
+		native_shared_libs: [
+			"mynativelib",
+			"myinternallib",
+		],
+		updatable: false,
+	}
+
+	cc_library {
+		name: "mynativelib",
+		srcs: ["Test.cpp"],
+		system_shared_libs: [],
+		stl: "none",
+		apex_available: ["myapex"],
+		stubs: {
+			symbol_file: "some/where/stubslib.map.txt",
+			versions: ["1"],
+		},
+	}
+
+	cc_library {
+		name: "myinternallib",
+		srcs: ["Test.cpp"],
+		system_shared_libs: [],
+		stl: "none",
+		apex_available: ["myapex"],
+	}
+`
+
+func TestSdkApexMembers(t *testing.T) {
+	result := testSdkWithCc(t, apexMembersTestBp+`
+		sdk {
+			name: "mysdk",
+			apexes: ["myapex"],
+			native_shared_libs: ["mynativelib"],
+		}
+	`)
+
+	// Only the libraries with stubs are exported by the apex.
+	apex := result.ModuleForTests("myapex", "android_common_myapex_image")
+	android.AssertStringEquals(t, "sdk_members.bp", `// Members of the sdk of myapex, generated from the modules that it exports.
+sdk {
+    name: "myapex-sdk",
+    native_shared_libs: [
+        "mynativelib",
+    ],
+}
+`, android.ContentFromFileRuleForTests(t, apex.Output("sdk_members.bp")))
+
+	// The check of the symbols exported by the apex variants of libraries is disabled by default.
+	apexVariant := result.ModuleForTests("mynativelib", "android_arm64_armv8-a_shared_apex10000")
+	android.AssertBoolEquals(t, "apex variant checks exported symbols", false,
+		apexVariant.MaybeRule("checkApexExportedSymbols").Rule != nil)
+}
+
+func TestApexExportedSymbolsCheck(t *testing.T) {
+	result := android.GroupFixturePreparers(
+		prepareForSdkTest,
+		ccTestFs.AddToFixture(),
+		android.FixtureModifyProductVariables(func(variables android.FixtureProductVariables) {
+			variables.EnforceApexExportedSymbols = proptools.BoolPtr(true)
+		}),
+	).RunTestWithBp(t, apexMembersTestBp)
+
+	// The apex variant of the exported library checks that it only exports the symbols of its stubs.
+	apexVariant := result.ModuleForTests("mynativelib", "android_arm64_armv8-a_shared_apex10000")
+	check := apexVariant.Rule("checkApexExportedSymbols")
+	android.AssertPathRelativeToTopEquals(t, "symbol list",
+		"out/soong/.intermediates/mynativelib/android_arm64_armv8-a_shared_apex10000/gen/abi_symbol_list.txt",
+		check.Implicit)
+	android.AssertStringListContains(t, "link validations",
+		android.PathsRelativeToTop(apexVariant.Rule("ld").Validations), android.PathRelativeToTop(check.Output))
+
+	platformVariant := result.ModuleForTests("mynativelib", "android_arm64_armv8-a_shared")
+	android.AssertBoolEquals(t, "platform variant checks exported symbols", false,
+		platformVariant.MaybeRule("checkApexExportedSymbols").Rule != nil)
+}
+
+func TestSdkApexMembersMissing(t *testing.T) {
+	android.GroupFixturePreparers(
+		prepareForSdkTest,
+		ccTestFs.AddToFixture(),
+	).ExtendWithErrorHandler(android.FixtureExpectsAtLeastOneErrorMatchingPattern(
+		`apex "myapex" exports modules that are not members of this sdk:\s+native_shared_libs: mynativelib`)).
+		RunTestWithBp(t, apexMembersTestBp+`
+			sdk {
+				name: "mysdk",
+				apexes: ["myapex"],
+			}
+		`)
+}
+
+func TestSdkApexMembersUnknownApex(t *testing.T) {
+	testSdkError(t, `apexes: "otherapex" is not an apex module`, `
+		sdk {
+			name: "mysdk",
+			apexes: ["otherapex"],
+		}
+	`)
+}
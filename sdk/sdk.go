@@ -38,6 +38,7 @@ func init() {
 func registerSdkBuildComponents(ctx android.RegistrationContext) {
 	ctx.RegisterModuleType("sdk", SdkModuleFactory)
 	ctx.RegisterModuleType("sdk_snapshot", SnapshotModuleFactory)
+	ctx.RegisterSingletonType("sdk_apex_members_check", sdkApexMembersCheckSingletonFactory)
 	ctx.PreDepsMutators(RegisterPreDepsMutators)
 }
 
@@ -98,6 +99,12 @@ type sdkProperties struct {
 	//   dropped. Adding a rule to members that have //visibility:private will
 	//   cause the //visibility:private to be discarded.
 	Prebuilt_visibility []string
+
+	// List of APEXes that this sdk publishes the snapshot of. The modules that the APEXes export to
+	// other modules, i.e. native libraries with stubs, java_sdk_library modules and classpath
+	// fragments, must all be members of this sdk, so that the snapshot doesn't drift from the
+	// APEXes. The <apex>-sdk-members target generates the list of the exported modules.
+	Apexes []string
 }
 
 // sdk defines an SDK which is a logical group of modules (e.g. native libs, headers, java libs, etc.)
@@ -317,14 +317,22 @@ var (
 
 var sharedLibVariations = []blueprint.Variation{{Mutator: "link", Variation: "shared"}}
 
+// dataVariations returns the variations to use for data dependencies built for target. Binaries and
+// shared libraries only have a single sanitizer variant, which is selected implicitly, except for
+// the fuzzer sanitizer which splits them when they are used by fuzz targets. Select the variant
+// that isn't built for fuzzing.
+func dataVariations(target android.Target) []blueprint.Variation {
+	return append(target.Variations(), blueprint.Variation{Mutator: "fuzzer", Variation: ""})
+}
+
 func (s *ShTest) DepsMutator(ctx android.BottomUpMutatorContext) {
 	s.ShBinary.DepsMutator(ctx)
 
-	ctx.AddFarVariationDependencies(ctx.Target().Variations(), shTestDataBinsTag, s.testProperties.Data_bins...)
-	ctx.AddFarVariationDependencies(append(ctx.Target().Variations(), sharedLibVariations...),
+	ctx.AddFarVariationDependencies(dataVariations(ctx.Target()), shTestDataBinsTag, s.testProperties.Data_bins...)
+	ctx.AddFarVariationDependencies(append(dataVariations(ctx.Target()), sharedLibVariations...),
 		shTestDataLibsTag, s.testProperties.Data_libs...)
 	if (ctx.Target().Os.Class == android.Host || ctx.BazelConversionMode()) && len(ctx.Config().Targets[android.Android]) > 0 {
-		deviceVariations := ctx.Config().AndroidFirstDeviceTarget.Variations()
+		deviceVariations := dataVariations(ctx.Config().AndroidFirstDeviceTarget)
 		ctx.AddFarVariationDependencies(deviceVariations, shTestDataDeviceBinsTag, s.testProperties.Data_device_bins...)
 		ctx.AddFarVariationDependencies(append(deviceVariations, sharedLibVariations...),
 			shTestDataDeviceLibsTag, s.testProperties.Data_device_libs...)
@@ -369,25 +377,6 @@ func (s *ShTest) GenerateAndroidBuildActions(ctx android.ModuleContext) {
 
 	s.data = android.PathsForModuleSrc(ctx, s.testProperties.Data)
 
-	var configs []tradefed.Config
-	if Bool(s.testProperties.Require_root) {
-		configs = append(configs, tradefed.Object{"target_preparer", "com.android.tradefed.targetprep.RootTargetPreparer", nil})
-	} else {
-		options := []tradefed.Option{{Name: "force-root", Value: "false"}}
-		configs = append(configs, tradefed.Object{"target_preparer", "com.android.tradefed.targetprep.RootTargetPreparer", options})
-	}
-	if len(s.testProperties.Data_device_bins) > 0 {
-		moduleName := s.Name()
-		remoteDir := "/data/local/tests/unrestricted/" + moduleName + "/"
-		options := []tradefed.Option{{Name: "cleanup", Value: "true"}}
-		for _, bin := range s.testProperties.Data_device_bins {
-			options = append(options, tradefed.Option{Name: "push-file", Key: bin, Value: remoteDir + bin})
-		}
-		configs = append(configs, tradefed.Object{"target_preparer", "com.android.tradefed.targetprep.PushFilePreparer", options})
-	}
-	s.testConfig = tradefed.AutoGenShellTestConfig(ctx, s.testProperties.Test_config,
-		s.testProperties.Test_config_template, s.testProperties.Test_suites, configs, s.testProperties.Auto_gen_config, s.outputFilePath.Base())
-
 	s.dataModules = make(map[string]android.Path)
 	ctx.VisitDirectDeps(func(dep android.Module) {
 		depTag := ctx.OtherModuleDependencyTag(dep)
@@ -418,12 +407,61 @@ func (s *ShTest) GenerateAndroidBuildActions(ctx android.ModuleContext) {
 				return
 			}
 			property := "data_libs"
-			if depTag == shTestDataDeviceBinsTag {
+			if depTag == shTestDataDeviceLibsTag {
 				property = "data_device_libs"
 			}
 			ctx.PropertyErrorf(property, "%q of type %q is not supported", dep.Name(), ctx.OtherModuleType(dep))
 		}
 	})
+
+	var configs []tradefed.Config
+	if Bool(s.testProperties.Require_root) {
+		configs = append(configs, tradefed.Object{"target_preparer", "com.android.tradefed.targetprep.RootTargetPreparer", nil})
+	} else {
+		options := []tradefed.Option{{Name: "force-root", Value: "false"}}
+		configs = append(configs, tradefed.Object{"target_preparer", "com.android.tradefed.targetprep.RootTargetPreparer", options})
+	}
+	if len(s.testProperties.Data_device_bins) > 0 {
+		moduleName := s.Name()
+		remoteDir := "/data/local/tests/unrestricted/" + moduleName + "/"
+		options := []tradefed.Option{{Name: "cleanup", Value: "true"}}
+		for _, bin := range s.testProperties.Data_device_bins {
+			options = append(options, tradefed.Option{Name: "push-file", Key: bin, Value: remoteDir + bin})
+		}
+		configs = append(configs, tradefed.Object{"target_preparer", "com.android.tradefed.targetprep.PushFilePreparer", options})
+	}
+	if !ctx.Host() && (len(s.data) > 0 || len(s.dataModules) > 0) {
+		// The shell test config template runs the test from /data/local/tmp, push the data files
+		// and modules alongside it at the same relative paths they are installed with so that the
+		// test can be run from the test suite, e.g. by TEST_MAPPING.
+		remoteDir := "/data/local/tmp/"
+		options := []tradefed.Option{{Name: "cleanup", Value: "true"}}
+		for _, d := range s.data {
+			options = append(options, tradefed.Option{Name: "push-file", Key: d.Rel(), Value: remoteDir + d.Rel()})
+		}
+		for _, relPath := range android.SortedStringKeys(s.dataModules) {
+			options = append(options, tradefed.Option{Name: "push-file", Key: relPath, Value: remoteDir + relPath})
+		}
+		configs = append(configs, tradefed.Object{"target_preparer", "com.android.tradefed.targetprep.PushFilePreparer", options})
+	}
+	s.testConfig = tradefed.AutoGenShellTestConfig(ctx, s.testProperties.Test_config,
+		s.testProperties.Test_config_template, s.testProperties.Test_suites, configs, s.testProperties.Auto_gen_config, s.outputFilePath.Base())
+
+	if len(s.testProperties.Test_suites) > 0 {
+		data := make(map[string]android.Path)
+		for _, d := range s.data {
+			data[d.Rel()] = d
+		}
+		for relPath, path := range s.dataModules {
+			data[relPath] = path
+		}
+		ctx.SetProvider(android.TestSuiteInfoProvider, android.TestSuiteInfo{
+			TestSuites: s.testProperties.Test_suites,
+			OutputFile: s.outputFilePath,
+			Data:       data,
+			TestConfig: s.testConfig,
+		})
+	}
 }
 
 func (s *ShTest) InstallInData() bool {
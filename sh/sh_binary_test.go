@@ -255,3 +255,73 @@ func TestShTestHost_dataDeviceModulesAutogenTradefedConfig(t *testing.T) {
 		t.Errorf("foo extraConfings %v does not contain %q", autogen.Args["extraConfigs"], expectedBinAutogenConfig)
 	}
 }
+
+func TestShTest_dataModulesAutogenTradefedConfig(t *testing.T) {
+	ctx, _ := testShBinary(t, `
+		sh_test {
+			name: "foo",
+			src: "test.sh",
+			data: ["testdata/data1"],
+			data_bins: ["bar"],
+			data_libs: ["libbar"],
+		}
+
+		cc_binary {
+			name: "bar",
+			shared_libs: ["libbar"],
+			no_libcrt: true,
+			nocrt: true,
+			system_shared_libs: [],
+			stl: "none",
+		}
+
+		cc_library {
+			name: "libbar",
+			no_libcrt: true,
+			nocrt: true,
+			system_shared_libs: [],
+			stl: "none",
+		}
+	`)
+
+	fooModule := ctx.ModuleForTests("foo", "android_arm64_armv8-a")
+
+	autogen := fooModule.Rule("autogen")
+	for _, expected := range []string{
+		`<option name="push-file" key="testdata/data1" value="/data/local/tmp/testdata/data1" />`,
+		`<option name="push-file" key="bar" value="/data/local/tmp/bar" />`,
+		`<option name="push-file" key="lib64/libbar.so" value="/data/local/tmp/lib64/libbar.so" />`,
+	} {
+		if !strings.Contains(autogen.Args["extraConfigs"], expected) {
+			t.Errorf("foo extraConfigs %v does not contain %q", autogen.Args["extraConfigs"], expected)
+		}
+	}
+}
+
+func TestShTest_dataModulesFuzzerVariant(t *testing.T) {
+	ctx, _ := testShBinary(t, `
+		sh_test {
+			name: "foo",
+			src: "test.sh",
+			data_libs: ["libbar"],
+		}
+
+		cc_fuzz {
+			name: "bar_fuzzer",
+			srcs: ["foo.c"],
+			shared_libs: ["libbar"],
+		}
+
+		cc_library {
+			name: "libbar",
+			no_libcrt: true,
+			nocrt: true,
+			system_shared_libs: [],
+			stl: "none",
+		}
+	`)
+
+	relocated := ctx.ModuleForTests("foo", "android_arm64_armv8-a").Output("relocated/lib64/libbar.so")
+	expectedInput := "out/soong/.intermediates/libbar/android_arm64_armv8-a_shared/libbar.so"
+	android.AssertPathRelativeToTopEquals(t, "relocation input", expectedInput, relocated.Input)
+}
@@ -0,0 +1,148 @@
+// Copyright 2022 The Android Open Source Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package snapshot
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/google/blueprint/proptools"
+
+	"android/soong/android"
+)
+
+//
+// The host_snapshot_binary module provides a host tool captured by a
+// host_snapshot module of an older platform release.  The version and
+// host_arch properties are copied from host_snapshot.json, and are
+// verified against the current build when the tool is used:
+//
+//   - host_arch must match the ABI of the build host.
+//   - version must not be newer than the platform version, and must match
+//     HostSnapshotVersion if the product sets it.
+//
+// A tool that fails the verification doesn't fail the analysis, so
+// snapshots for several versions can coexist in a source tree.  Instead,
+// building the tool fails with a description of the mismatch.
+
+func init() {
+	registerHostPrebuiltBuildComponents(android.InitRegistrationContext)
+}
+
+func registerHostPrebuiltBuildComponents(ctx android.RegistrationContext) {
+	ctx.RegisterModuleType("host_snapshot_binary", hostSnapshotBinaryFactory)
+}
+
+type hostSnapshotBinaryProperties struct {
+	// The prebuilt host tool.
+	Src *string `android:"path"`
+
+	// Name of the installed tool.  Defaults to the name of the module.
+	Stem *string
+
+	// Install path relative to out/host/<os>/bin.
+	Relative_install_path *string
+
+	// Platform version the tool was captured from.
+	Version *string
+
+	// Host ABI the tool was built for, e.g. linux_glibc_x86_64.
+	Host_arch *string
+}
+
+type hostSnapshotBinary struct {
+	android.ModuleBase
+
+	properties hostSnapshotBinaryProperties
+
+	toolPath android.OptionalPath
+}
+
+var _ android.HostToolProvider = (*hostSnapshotBinary)(nil)
+
+func hostSnapshotBinaryFactory() android.Module {
+	module := &hostSnapshotBinary{}
+	module.AddProperties(&module.properties)
+	android.InitAndroidArchModule(module, android.HostSupported, android.MultilibFirst)
+	return module
+}
+
+func (p *hostSnapshotBinary) stem() string {
+	return proptools.StringDefault(p.properties.Stem, p.BaseModuleName())
+}
+
+// verify returns the reasons the tool can't be used in the current build.
+func (p *hostSnapshotBinary) verify(ctx android.ModuleContext) []string {
+	var problems []string
+
+	hostArch := hostSnapshotArch(ctx.Target())
+	if arch := proptools.String(p.properties.Host_arch); arch != hostArch {
+		problems = append(problems,
+			fmt.Sprintf("captured for host ABI %q, but the build host is %q", arch, hostArch))
+	}
+
+	version, err := android.ApiLevelFromUser(ctx, proptools.String(p.properties.Version))
+	if err != nil {
+		problems = append(problems, err.Error())
+		return problems
+	}
+	if platformVersion := ctx.Config().PlatformSdkVersion(); version.GreaterThan(platformVersion) {
+		problems = append(problems,
+			fmt.Sprintf("captured from version %s, which is newer than the platform version %s",
+				version, platformVersion))
+	}
+	if want := ctx.DeviceConfig().HostSnapshotVersion(); want != "" && want != version.String() {
+		problems = append(problems,
+			fmt.Sprintf("captured from version %s, but HostSnapshotVersion is %s", version, want))
+	}
+	return problems
+}
+
+func (p *hostSnapshotBinary) GenerateAndroidBuildActions(ctx android.ModuleContext) {
+	if p.properties.Src == nil {
+		ctx.PropertyErrorf("src", "missing prebuilt source file")
+		return
+	}
+	if p.properties.Version == nil {
+		ctx.PropertyErrorf("version", "missing snapshot version")
+		return
+	}
+
+	src := android.PathForModuleSrc(ctx, *p.properties.Src)
+	installDir := android.PathForModuleInstall(ctx, "bin", proptools.String(p.properties.Relative_install_path))
+
+	if problems := p.verify(ctx); len(problems) > 0 {
+		// Replace the tool with a file whose build fails, so that only the users of the tool
+		// are broken.
+		errorFile := android.PathForModuleOut(ctx, p.stem())
+		ctx.Build(pctx, android.BuildParams{
+			Rule:   android.ErrorRule,
+			Output: errorFile,
+			Args: map[string]string{
+				"error": fmt.Sprintf("host snapshot tool %s can't be used: %s",
+					ctx.ModuleName(), strings.Join(problems, "; ")),
+			},
+		})
+		p.toolPath = android.OptionalPathForPath(ctx.InstallExecutable(installDir, p.stem(), errorFile))
+		return
+	}
+
+	p.toolPath = android.OptionalPathForPath(ctx.InstallExecutable(installDir, p.stem(), src))
+}
+
+// Implements android.HostToolProvider
+func (p *hostSnapshotBinary) HostToolPath() android.OptionalPath {
+	return p.toolPath
+}
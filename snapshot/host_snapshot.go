@@ -102,6 +102,10 @@ func (f *hostSnapshot) CreateMetaData(ctx android.ModuleContext, fileName string
 	ctx.VisitDirectDeps(func(dep android.Module) {
 		desc := hostJsonDesc(dep)
 		if desc != nil {
+			// Record the platform version and the host ABI, which are verified by
+			// host_snapshot_binary when the snapshot is used.
+			desc.Version = ctx.Config().PlatformSdkVersion().String()
+			desc.HostArch = hostSnapshotArch(dep.Target())
 			jsonData = append(jsonData, *desc)
 		}
 		if len(dep.EffectiveLicenseFiles()) > 0 {
@@ -188,6 +192,11 @@ func (f *hostSnapshot) AndroidMkEntries() []android.AndroidMkEntries {
 	}}
 }
 
+// hostSnapshotArch returns the host ABI a module was built for, e.g. linux_glibc_x86_64.
+func hostSnapshotArch(target android.Target) string {
+	return target.Os.String() + "_" + target.Arch.ArchType.String()
+}
+
 // Get host tools path and relative install string helpers
 func hostToolPath(m android.Module) android.OptionalPath {
 	if provider, ok := m.(android.HostToolProvider); ok {
@@ -15,6 +15,7 @@
 package snapshot
 
 import (
+	"fmt"
 	"path/filepath"
 	"testing"
 
@@ -168,3 +169,76 @@ func TestFakeHostSnapshotDisable(t *testing.T) {
 	}
 
 }
+
+var hostSnapshotBinaryBp = `
+		host_snapshot_binary {
+			name: "foo_snapshot",
+			src: "foo",
+			version: "29",
+			host_arch: "%s",
+		}
+		`
+
+func prepareForHostSnapshotBinaryTest(hostArch string) android.FixturePreparer {
+	return android.GroupFixturePreparers(
+		android.PrepareForTestWithAndroidBuildComponents,
+		android.FixtureRegisterWithContext(registerHostPrebuiltBuildComponents),
+		android.FixtureAddFile("foo", nil),
+		android.FixtureWithRootAndroidBp(fmt.Sprintf(hostSnapshotBinaryBp, hostArch)),
+	)
+}
+
+func hostSnapshotBinaryForTests(t *testing.T, result *android.TestResult) android.TestingModule {
+	t.Helper()
+	return result.ModuleForTests("foo_snapshot", result.Config.BuildOSTarget.String())
+}
+
+// Validate that a host_snapshot_binary matching the build host and platform version is installed
+func TestHostSnapshotBinary(t *testing.T) {
+	result := android.GroupFixturePreparers(
+		prepareForHostSnapshotBinaryTest("linux_glibc_x86_64"),
+	).RunTest(t)
+	mod := hostSnapshotBinaryForTests(t, result)
+	path := mod.Module().(*hostSnapshotBinary).HostToolPath()
+	if !path.Valid() {
+		t.Fatal("host tool path not valid")
+	}
+	android.AssertPathRelativeToTopEquals(t, "host tool input", "foo", mod.Output(path.String()).Input)
+}
+
+// Validate that a host_snapshot_binary failing the verification is replaced with an error rule
+func TestHostSnapshotBinaryVerification(t *testing.T) {
+	testCases := []struct {
+		name     string
+		hostArch string
+		version  string
+		err      string
+	}{
+		{
+			name:     "host arch mismatch",
+			hostArch: "darwin_x86_64",
+			err:      `captured for host ABI "darwin_x86_64", but the build host is "linux_glibc_x86_64"`,
+		},
+		{
+			name:     "host snapshot version mismatch",
+			hostArch: "linux_glibc_x86_64",
+			version:  "28",
+			err:      "captured from version 29, but HostSnapshotVersion is 28",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result := android.GroupFixturePreparers(
+				prepareForHostSnapshotBinaryTest(tc.hostArch),
+				android.FixtureModifyProductVariables(func(variables android.FixtureProductVariables) {
+					variables.HostSnapshotVersion = tc.version
+				}),
+			).RunTest(t)
+			errorFile := hostSnapshotBinaryForTests(t, result).Output("foo_snapshot")
+			if errorFile.Rule != android.ErrorRule {
+				t.Fatalf("expected error rule, got %s", errorFile.Rule)
+			}
+			android.AssertStringDoesContain(t, "error", errorFile.Args["error"], tc.err)
+		})
+	}
+}
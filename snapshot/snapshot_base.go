@@ -119,4 +119,8 @@ type SnapshotJsonFlags struct {
 
 	// dependencies
 	Required []string `json:",omitempty"`
+
+	// platform version and host ABI the module was built for, only set for host snapshots
+	Version  string `json:",omitempty"`
+	HostArch string `json:",omitempty"`
 }
@@ -21,6 +21,7 @@ import (
 	"io"
 	"os"
 	"path"
+	"strings"
 	"sync"
 
 	"github.com/google/blueprint"
@@ -29,6 +30,7 @@ import (
 	"android/soong/android"
 	"android/soong/cc"
 	"android/soong/java"
+	"android/soong/rust"
 )
 
 type dependencyTag struct {
@@ -122,6 +124,103 @@ func syspropJavaGenFactory() android.Module {
 	return g
 }
 
+type syspropRustGenProperties struct {
+	Srcs  []string `android:"path"`
+	Scope string
+
+	// Reference to the API check timestamp of the sysprop_library.
+	Check_api *string `android:"path"`
+}
+
+// syspropRustGenRule generates a Rust crate with a module of typed accessors for each .sysprop
+// file, which is compiled into the library variants of the rust module.
+type syspropRustGenRule struct {
+	*rust.BaseSourceProvider
+
+	properties syspropRustGenProperties
+}
+
+var _ rust.SourceProvider = (*syspropRustGenRule)(nil)
+
+var (
+	syspropRust = pctx.AndroidStaticRule("syspropRust",
+		blueprint.RuleParams{
+			Command: `rm -rf $outDir && mkdir -p $outDir && ` +
+				`$syspropRustCmd --scope $scope --rust-output-dir $outDir $in`,
+			CommandDeps: []string{"$syspropRustCmd"},
+		}, "scope", "outDir")
+)
+
+func init() {
+	pctx.HostBinToolVariable("syspropRustCmd", "sysprop_rust")
+}
+
+// syspropPathToRustModule returns the name of the Rust module of the accessors of a .sysprop file.
+func syspropPathToRustModule(syspropFile android.Path) string {
+	name := strings.TrimSuffix(syspropFile.Base(), syspropFile.Ext())
+	return strings.ToLower(strings.NewReplacer("-", "_", ".", "_").Replace(name))
+}
+
+func (g *syspropRustGenRule) GenerateSource(ctx rust.ModuleContext, deps rust.PathDeps) android.Path {
+	// The API check has to pass to use the generated code.
+	checkApiFileTimeStamp := android.PathForModuleSrc(ctx, proptools.String(g.properties.Check_api))
+
+	libFile := android.PathForModuleOut(ctx, "src", "lib.rs")
+	libFileSource := []string{"//! Autogenerated system property accessors."}
+	var modulePaths android.Paths
+	var moduleNames []string
+
+	for _, syspropFile := range android.PathsForModuleSrc(ctx, g.properties.Srcs) {
+		moduleName := syspropPathToRustModule(syspropFile)
+		if android.InList(moduleName, moduleNames) {
+			ctx.PropertyErrorf("srcs", "%q and another .sysprop file both generate the Rust module %q",
+				syspropFile.String(), moduleName)
+			continue
+		}
+		moduleNames = append(moduleNames, moduleName)
+
+		modulePath := android.PathForModuleOut(ctx, "src", moduleName, "mod.rs")
+		ctx.Build(pctx, android.BuildParams{
+			Rule:        syspropRust,
+			Description: "sysprop_rust " + syspropFile.Rel(),
+			Output:      modulePath,
+			Input:       syspropFile,
+			Implicit:    checkApiFileTimeStamp,
+			Args: map[string]string{
+				"scope":  g.properties.Scope,
+				"outDir": android.PathForModuleOut(ctx, "src", moduleName).String(),
+			},
+		})
+
+		modulePaths = append(modulePaths, modulePath)
+		libFileSource = append(libFileSource, "pub mod "+moduleName+";")
+	}
+
+	android.WriteFileRule(ctx, libFile, strings.Join(libFileSource, "\n"))
+
+	// lib.rs must be first as the first path in BaseSourceProvider.OutputFiles is the library entry-point.
+	g.BaseSourceProvider.OutputFiles = append(android.Paths{libFile}, modulePaths...)
+	return libFile
+}
+
+func (g *syspropRustGenRule) SourceProviderProps() []interface{} {
+	return append(g.BaseSourceProvider.SourceProviderProps(), &g.properties)
+}
+
+func (g *syspropRustGenRule) SourceProviderDeps(ctx rust.DepsContext, deps rust.Deps) rust.Deps {
+	deps = g.BaseSourceProvider.SourceProviderDeps(ctx, deps)
+	deps.Rustlibs = append(deps.Rustlibs, "librustutils")
+	return deps
+}
+
+func syspropRustGenFactory() android.Module {
+	g := &syspropRustGenRule{
+		BaseSourceProvider: rust.NewSourceProvider(),
+	}
+	module := rust.NewSourceProviderModule(android.HostAndDeviceSupported, g, false)
+	return module.Init()
+}
+
 type syspropLibrary struct {
 	android.ModuleBase
 	android.ApexModuleBase
@@ -174,6 +273,16 @@ type syspropLibraryProperties struct {
 		// Forwarded to java_library.min_sdk_version
 		Min_sdk_version *string
 	}
+
+	Rust struct {
+		// If set to true, generate a Rust library lib<name>_rust, with crate name <name> in snake
+		// case, that provides typed accessors for the properties. Defaults to false.
+		Enabled *bool
+
+		// Minimum sdk version that the artifact should support when it runs as part of mainline modules(APEX).
+		// Forwarded to the min_sdk_version of the Rust library
+		Min_sdk_version *string
+	}
 }
 
 var (
@@ -227,6 +336,14 @@ func (m *syspropLibrary) javaGenPublicStubName() string {
 	return m.BaseModuleName() + "_java_gen_public"
 }
 
+func (m *syspropLibrary) rustCrateName() string {
+	return strings.ToLower(strings.NewReplacer("-", "_", ".", "_").Replace(m.BaseModuleName()))
+}
+
+func (m *syspropLibrary) rustLibraryName() string {
+	return "lib" + m.rustCrateName() + "_rust"
+}
+
 func (m *syspropLibrary) BaseModuleName() string {
 	return m.ModuleBase.Name()
 }
@@ -352,6 +469,18 @@ func (m *syspropLibrary) AndroidMk() android.AndroidMkData {
 		}}
 }
 
+var _ android.OutputFileProducer = (*syspropLibrary)(nil)
+
+// Implements android.OutputFileProducer
+func (m *syspropLibrary) OutputFiles(tag string) (android.Paths, error) {
+	switch tag {
+	case ".check_api":
+		return android.Paths{m.checkApiFileTimeStamp}, nil
+	default:
+		return nil, fmt.Errorf("unsupported module reference tag %q", tag)
+	}
+}
+
 var _ android.ApexModule = (*syspropLibrary)(nil)
 
 // Implements android.ApexModule
@@ -361,7 +490,8 @@ func (m *syspropLibrary) ShouldSupportSdkVersion(ctx android.BaseModuleContext,
 }
 
 // sysprop_library creates schematized APIs from sysprop description files (.sysprop).
-// Both Java and C++ modules can link against sysprop_library, and API stability check
+// Both Java and C++ modules can link against sysprop_library, as well as Rust modules when
+// rust.enabled is set, and API stability check
 // against latest APIs (see build/soong/scripts/freeze-sysprop-api-files.sh)
 // is performed.
 func syspropLibraryFactory() android.Module {
@@ -421,6 +551,24 @@ type javaLibraryProperties struct {
 	Min_sdk_version   *string
 }
 
+type rustLibraryProperties struct {
+	Name               *string
+	Srcs               []string
+	Scope              string
+	Check_api          *string
+	Crate_name         string
+	Soc_specific       *bool
+	Device_specific    *bool
+	Product_specific   *bool
+	Recovery_available *bool
+	Vendor_available   *bool
+	Product_available  *bool
+	Ramdisk_available  *bool
+	Host_supported     *bool
+	Apex_available     []string
+	Min_sdk_version    *string
+}
+
 func syspropLibraryHook(ctx android.LoadHookContext, m *syspropLibrary) {
 	if len(m.properties.Srcs) == 0 {
 		ctx.PropertyErrorf("srcs", "sysprop_library must specify srcs")
@@ -547,6 +695,27 @@ func syspropLibraryHook(ctx android.LoadHookContext, m *syspropLibrary) {
 		})
 	}
 
+	// Generate a Rust implementation library.
+	if proptools.Bool(m.properties.Rust.Enabled) {
+		ctx.CreateModule(syspropRustGenFactory, &rustLibraryProperties{
+			Name:               proptools.StringPtr(m.rustLibraryName()),
+			Srcs:               m.properties.Srcs,
+			Scope:              scope,
+			Check_api:          proptools.StringPtr(":" + ctx.ModuleName() + "{.check_api}"),
+			Crate_name:         m.rustCrateName(),
+			Soc_specific:       proptools.BoolPtr(ctx.SocSpecific()),
+			Device_specific:    proptools.BoolPtr(ctx.DeviceSpecific()),
+			Product_specific:   proptools.BoolPtr(ctx.ProductSpecific()),
+			Recovery_available: m.properties.Recovery_available,
+			Vendor_available:   m.properties.Vendor_available,
+			Product_available:  m.properties.Product_available,
+			Ramdisk_available:  m.properties.Ramdisk_available,
+			Host_supported:     m.properties.Host_supported,
+			Apex_available:     m.ApexProperties.Apex_available,
+			Min_sdk_version:    m.properties.Rust.Min_sdk_version,
+		})
+	}
+
 	// syspropLibraries will be used by property_contexts to check types.
 	// Record absolute paths of sysprop_library to prevent soong_namespace problem.
 	if m.ExportedToMake() {
@@ -22,6 +22,7 @@ import (
 	"android/soong/android"
 	"android/soong/cc"
 	"android/soong/java"
+	"android/soong/rust"
 
 	"github.com/google/blueprint/proptools"
 )
@@ -32,6 +33,11 @@ func TestMain(m *testing.M) {
 
 func test(t *testing.T, bp string) *android.TestResult {
 	t.Helper()
+	return testWithPreparer(t, android.NullFixturePreparer, bp)
+}
+
+func testWithPreparer(t *testing.T, preparer android.FixturePreparer, bp string) *android.TestResult {
+	t.Helper()
 
 	bp += `
 		cc_library {
@@ -120,6 +126,7 @@ func test(t *testing.T, bp string) *android.TestResult {
 		cc.PrepareForTestWithCcDefaultModules,
 		java.PrepareForTestWithJavaDefaultModules,
 		PrepareForTestWithSyspropBuildComponents,
+		preparer,
 		android.FixtureModifyProductVariables(func(variables android.FixtureProductVariables) {
 			variables.DeviceSystemSdkVersions = []string{"28"}
 			variables.DeviceVndkVersion = proptools.StringPtr("current")
@@ -368,3 +375,57 @@ func TestMinSdkVersionIsForwarded(t *testing.T) {
 	propFromJava := javaModule.MinSdkVersionString()
 	android.AssertStringEquals(t, "min_sdk_version forwarding to java module", "30", propFromJava)
 }
+
+func TestSyspropLibraryRust(t *testing.T) {
+	result := testWithPreparer(t, rust.PrepareForTestWithRustDefaultModules, `
+		sysprop_library {
+			name: "sysprop-platform",
+			srcs: ["android/sysprop/PlatformProperties.sysprop"],
+			api_packages: ["android.sysprop"],
+			property_owner: "Platform",
+			vendor_available: true,
+			rust: {
+				enabled: true,
+			},
+		}
+
+		sysprop_library {
+			name: "sysprop-vendor",
+			srcs: ["com/android/VendorProperties.sysprop"],
+			api_packages: ["com.android"],
+			property_owner: "Vendor",
+			vendor: true,
+		}
+
+		rust_binary {
+			name: "rust-client-platform",
+			srcs: ["foo.rs"],
+			rustlibs: ["libsysprop_platform_rust"],
+		}
+	`)
+
+	gen := result.ModuleForTests("libsysprop_platform_rust", "android_arm64_armv8-a_source")
+
+	accessors := gen.Output("src/platformproperties/mod.rs")
+	android.AssertPathRelativeToTopEquals(t, "sysprop file",
+		"android/sysprop/PlatformProperties.sysprop", accessors.Input)
+	android.AssertStringDoesContain(t, "check api timestamp", accessors.Implicit.String(),
+		"sysprop-platform_sysprop_library")
+	android.AssertStringDoesContain(t, "check api timestamp", accessors.Implicit.String(),
+		"check_api.timestamp")
+	android.AssertStringEquals(t, "scope", "internal", accessors.Args["scope"])
+
+	libFile := gen.Output("src/lib.rs")
+	android.AssertStringEquals(t, "lib.rs",
+		"//! Autogenerated system property accessors.\npub mod platformproperties;\n",
+		android.ContentFromFileRuleForTests(t, libFile))
+
+	client := result.ModuleForTests("rust-client-platform", "android_arm64_armv8-a").Module().(*rust.Module)
+	android.AssertStringListContains(t, "client crate dependencies", client.Properties.AndroidMkRlibs,
+		"libsysprop_platform_rust.rlib-std")
+
+	// The Rust library is only generated when it is enabled.
+	if len(result.ModuleVariantsForTests("libsysprop_vendor_rust")) > 0 {
+		t.Errorf("unexpected Rust library for sysprop-vendor")
+	}
+}
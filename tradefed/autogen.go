@@ -137,26 +137,46 @@ func autogenTemplateWithNameAndOutputFile(ctx android.ModuleContext, name string
 	})
 }
 
+// AutoGenNativeTestConfig generates the test config of a native test that runs testName, the
+// binary of the test or a wrapper installed next to it.
 func AutoGenNativeTestConfig(ctx android.ModuleContext, testConfigProp *string,
-	testConfigTemplateProp *string, testSuites []string, config []Config, autoGenConfig *bool, testInstallBase string) android.Path {
+	testConfigTemplateProp *string, testSuites []string, config []Config, autoGenConfig *bool, testInstallBase string,
+	testName string) android.Path {
 
 	path, autogenPath := testConfigPath(ctx, testConfigProp, testSuites, autoGenConfig, testConfigTemplateProp)
 	if autogenPath != nil {
-		templatePath := getTestConfigTemplate(ctx, testConfigTemplateProp)
-		if templatePath.Valid() {
-			autogenTemplate(ctx, autogenPath, templatePath.String(), config, testInstallBase)
-		} else {
-			if ctx.Device() {
-				autogenTemplate(ctx, autogenPath, "${NativeTestConfigTemplate}", config, testInstallBase)
-			} else {
-				autogenTemplate(ctx, autogenPath, "${NativeHostTestConfigTemplate}", config, testInstallBase)
-			}
-		}
+		autogenTemplateWithName(ctx, testName, autogenPath, nativeTestConfigTemplate(ctx, testConfigTemplateProp), config, testInstallBase)
 		return autogenPath
 	}
 	return path
 }
 
+// AutoGenNativeTestShardConfig generates <shardName>.config, the test config of one shard of a
+// native test, from the same template as the test config of the whole test.  It returns nil if
+// the test config of the module isn't auto generated.
+func AutoGenNativeTestShardConfig(ctx android.ModuleContext, testConfigProp *string,
+	testConfigTemplateProp *string, testSuites []string, shardName string, config []Config, autoGenConfig *bool, testInstallBase string,
+	testName string) android.Path {
+
+	_, autogenPath := testConfigPath(ctx, testConfigProp, testSuites, autoGenConfig, testConfigTemplateProp)
+	if autogenPath == nil {
+		return nil
+	}
+	shardPath := android.PathForModuleOut(ctx, shardName+".config")
+	autogenTemplateWithName(ctx, testName, shardPath, nativeTestConfigTemplate(ctx, testConfigTemplateProp), config, testInstallBase)
+	return shardPath
+}
+
+func nativeTestConfigTemplate(ctx android.ModuleContext, testConfigTemplateProp *string) string {
+	if templatePath := getTestConfigTemplate(ctx, testConfigTemplateProp); templatePath.Valid() {
+		return templatePath.String()
+	} else if ctx.Device() {
+		return "${NativeTestConfigTemplate}"
+	} else {
+		return "${NativeHostTestConfigTemplate}"
+	}
+}
+
 func AutoGenShellTestConfig(ctx android.ModuleContext, testConfigProp *string,
 	testConfigTemplateProp *string, testSuites []string, config []Config, autoGenConfig *bool, outputFileName string) android.Path {
 	path, autogenPath := testConfigPath(ctx, testConfigProp, testSuites, autoGenConfig, testConfigTemplateProp)
@@ -172,15 +192,21 @@ func AutoGenShellTestConfig(ctx android.ModuleContext, testConfigProp *string,
 	return path
 }
 
+// AutoGenNativeBenchmarkTestConfig generates the test config of a native benchmark.  On hosts the
+// config runs testName, the binary of the benchmark or a wrapper installed next to it; on devices
+// the benchmark runner runs the executables of the install directory named after the module.
 func AutoGenNativeBenchmarkTestConfig(ctx android.ModuleContext, testConfigProp *string,
-	testConfigTemplateProp *string, testSuites []string, configs []Config, autoGenConfig *bool) android.Path {
+	testConfigTemplateProp *string, testSuites []string, configs []Config, autoGenConfig *bool,
+	testName string) android.Path {
 	path, autogenPath := testConfigPath(ctx, testConfigProp, testSuites, autoGenConfig, testConfigTemplateProp)
 	if autogenPath != nil {
 		templatePath := getTestConfigTemplate(ctx, testConfigTemplateProp)
 		if templatePath.Valid() {
 			autogenTemplate(ctx, autogenPath, templatePath.String(), configs, "")
-		} else {
+		} else if ctx.Device() {
 			autogenTemplate(ctx, autogenPath, "${NativeBenchmarkTestConfigTemplate}", configs, "")
+		} else {
+			autogenTemplateWithName(ctx, testName, autogenPath, "${NativeHostBenchmarkTestConfigTemplate}", configs, "")
 		}
 		return autogenPath
 	}
@@ -327,13 +327,27 @@ func Build(ctx Context, config Config) {
 			installCleanIfNecessary(ctx, config)
 		}
 
+		var declared *declaredOutputs
+		if config.EnforceDeclaredOutputs() {
+			declared = snapshotDeclaredOutputs(ctx, config)
+		}
+
 		runNinjaForBuild(ctx, config)
+
+		if declared != nil {
+			testForUndeclaredOutputs(ctx, config, declared)
+		}
 	}
 
 	// Currently, using Bazel requires Kati and Soong to run first, so check whether to run Bazel last.
 	if what&RunBazel != 0 {
 		runBazel(ctx, config)
 	}
+
+	// Only a build that ran ninja produced the dist artifacts of its goals.
+	if what&RunNinja != 0 {
+		uploadDist(ctx, config)
+	}
 }
 
 var distWaitGroup sync.WaitGroup
@@ -70,6 +70,10 @@ type configImpl struct {
 	skipSoong       bool
 	skipNinja       bool
 	skipSoongTests  bool
+	explain         bool
+
+	// The environment variable changes that made soong_build re-run, for the --explain report.
+	explainedEnvironment []string
 
 	// From the product config
 	katiArgs        []string
@@ -100,6 +104,9 @@ type configImpl struct {
 	metricsUploader string
 
 	includeTags []string
+
+	// The directories of a partial build, given on the command line as //path/to/dir/....
+	partialBuildDirs []string
 }
 
 const srcDirFileCheck = "build/soong/root.bp"
@@ -249,6 +256,13 @@ func NewConfig(ctx Context, args ...string) Config {
 
 	ret.parseArgs(ctx, args)
 
+	// Partial builds don't analyze the modules that are only added as dependencies by mutators,
+	// see partial_build.go.  Kati and Make reference these modules too, so the missing
+	// dependencies are allowed for the whole build and not just for soong_build.
+	if len(ret.partialBuildDirs) > 0 {
+		ret.environ.Set("ALLOW_MISSING_DEPENDENCIES", "true")
+	}
+
 	// Make sure OUT_DIR is set appropriately
 	if outDir, ok := ret.environ.Get("OUT_DIR"); ok {
 		outDir := filepath.Clean(outDir)
@@ -739,6 +753,8 @@ func (c *configImpl) parseArgs(ctx Context, args []string) {
 			c.skipSoongTests = true
 		} else if arg == "--mk-metrics" {
 			c.reportMkMetrics = true
+		} else if arg == "--explain" {
+			c.explain = true
 		} else if len(arg) > 0 && arg[0] == '-' {
 			parseArgNum := func(def int) int {
 				if len(arg) > 2 {
@@ -779,6 +795,15 @@ func (c *configImpl) parseArgs(ctx Context, args []string) {
 			c.queryview = true
 		} else if arg == "soong_docs" {
 			c.soongDocs = true
+		} else if dir, ok := parsePartialBuildArg(arg); ok {
+			if dir == "." {
+				ctx.Fatalf("%s builds the whole tree, drop it to do a full build", arg)
+			}
+			if _, err := os.Stat(dir); err != nil {
+				ctx.Fatalf("couldn't find directory %s", dir)
+			}
+			c.partialBuildDirs = append(c.partialBuildDirs, dir)
+			c.arguments = append(c.arguments, convertToTarget(dir, "MODULES-IN-"))
 		} else {
 			if arg == "checkbuild" {
 				c.checkbuild = true
@@ -980,6 +1005,11 @@ func (c *configImpl) JsonModuleGraph() bool {
 	return c.jsonModuleGraph
 }
 
+// PartialBuildDirs returns the directories of a partial build, or nil for a full build.
+func (c *configImpl) PartialBuildDirs() []string {
+	return c.partialBuildDirs
+}
+
 func (c *configImpl) Bp2Build() bool {
 	return c.bp2build
 }
@@ -1016,6 +1046,35 @@ func (c *configImpl) SetSkipNinja(v bool) {
 	c.skipNinja = v
 }
 
+// Explain returns whether to report why ninja rebuilt each output, in terms of modules.
+func (c *configImpl) Explain() bool {
+	return c.explain
+}
+
+// ExplainedEnvironment returns the environment variable changes that made soong_build re-run.
+func (c *configImpl) ExplainedEnvironment() []string {
+	return c.explainedEnvironment
+}
+
+func (c *configImpl) addExplainedEnvironment(changes ...string) {
+	c.explainedEnvironment = append(c.explainedEnvironment, changes...)
+}
+
+// EnforceDeclaredOutputs returns whether the build should fail when actions leave files next to
+// their outputs that aren't declared outputs of any action.
+func (c *configImpl) EnforceDeclaredOutputs() bool {
+	return c.Environment().IsEnvTrue("SOONG_ENFORCE_DECLARED_OUTPUTS")
+}
+
+// DistUploadURL returns the URL of the artifact service the dist artifacts are uploaded to at the
+// end of a dist build, or an empty string if they aren't uploaded.
+func (c *configImpl) DistUploadURL() string {
+	if url, ok := c.environ.Get("DIST_UPLOAD_URL"); ok {
+		return strings.TrimSuffix(url, "/")
+	}
+	return ""
+}
+
 func (c *configImpl) SkipConfig() bool {
 	return c.skipConfig
 }
@@ -0,0 +1,182 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package build
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"android/soong/ui/metrics"
+	"android/soong/ui/status"
+)
+
+// Checks for files in the Soong intermediates directories that were written by actions but aren't
+// declared outputs of any action, for example a map file that a tool writes next to its declared
+// output.  Ninja doesn't know about these files, so they aren't cleaned or rebuilt when they should
+// be, which makes incremental builds flaky.
+//
+// Only the directories that contain declared outputs are checked, because many actions use
+// scratch directories of their own (for example for the classes compiled by javac) that don't
+// contain any declared output.  The directories are snapshotted before ninja runs, and only the
+// files that ninja created or modified are reported, so that the stale files left by previous
+// builds aren't blamed on the actions of this one.
+
+// declaredOutputs is a snapshot of the directories containing the declared outputs of the actions,
+// taken before ninja runs.
+type declaredOutputs struct {
+	root     string
+	declared map[string]bool
+
+	// The modification times of the files in the directories of the declared outputs.
+	snapshot map[string]time.Time
+}
+
+// snapshotDeclaredOutputs lists the declared outputs of the actions and snapshots the directories
+// that contain them.
+func snapshotDeclaredOutputs(ctx Context, config Config) *declaredOutputs {
+	ctx.BeginTrace(metrics.TestRun, "snapshot declared outputs")
+	defer ctx.EndTrace()
+
+	// Get the list of outputs of all the actions from ninja.
+	executable := config.PrebuiltBuildTool("ninja")
+	cmd := Command(ctx, config, "ninja", executable,
+		"-f", config.CombinedNinjaFile(), "-t", "targets", "all")
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		ctx.Fatal(err)
+	}
+
+	cmd.StartOrFatal()
+
+	declared := make(map[string]bool)
+	scanner := bufio.NewScanner(stdout)
+	for scanner.Scan() {
+		// Each line is "<output>: <rule>".
+		if i := strings.LastIndex(scanner.Text(), ": "); i > 0 {
+			declared[filepath.Clean(scanner.Text()[:i])] = true
+		}
+	}
+
+	cmd.WaitOrFatal()
+
+	root := filepath.Join(config.SoongOutDir(), ".intermediates")
+	return newDeclaredOutputs(root, declared, ioutil.ReadDir)
+}
+
+func newDeclaredOutputs(root string, declared map[string]bool,
+	readDir func(dir string) ([]os.FileInfo, error)) *declaredOutputs {
+
+	d := &declaredOutputs{
+		root:     root,
+		declared: declared,
+		snapshot: make(map[string]time.Time),
+	}
+	d.walk(readDir, func(path string, file os.FileInfo) {
+		d.snapshot[path] = file.ModTime()
+	})
+	return d
+}
+
+// walk calls f for each file in the directories that contain declared outputs under root.
+func (d *declaredOutputs) walk(readDir func(dir string) ([]os.FileInfo, error),
+	f func(path string, file os.FileInfo)) {
+
+	dirs := make(map[string]bool)
+	for output := range d.declared {
+		if strings.HasPrefix(output, d.root+"/") {
+			dirs[filepath.Dir(output)] = true
+		}
+	}
+
+	for dir := range dirs {
+		files, err := readDir(dir)
+		if err != nil {
+			// The directory doesn't exist when none of its outputs were built.
+			continue
+		}
+		for _, file := range files {
+			if !file.IsDir() {
+				f(filepath.Join(dir, file.Name()), file)
+			}
+		}
+	}
+}
+
+// testForUndeclaredOutputs fails the build if ninja created or modified files that aren't declared
+// outputs in the directories of the snapshot.
+func testForUndeclaredOutputs(ctx Context, config Config, d *declaredOutputs) {
+	ctx.BeginTrace(metrics.TestRun, "test for undeclared outputs")
+	defer ctx.EndTrace()
+
+	ts := ctx.Status.StartTool()
+	action := &status.Action{
+		Description: "Test for undeclared outputs",
+	}
+	ts.StartAction(action)
+
+	undeclared := d.findUndeclaredOutputs(ioutil.ReadDir)
+
+	if len(undeclared) > 0 {
+		mapper := loadExplainPathMapper(ctx, config)
+
+		sb := &strings.Builder{}
+		title := "Files in out written next to declared outputs with no rule to create them:"
+		fmt.Fprintln(sb, title)
+		for i, file := range undeclared {
+			if i == 20 {
+				fmt.Fprintf(sb, "  ... and %d more\n", len(undeclared)-i)
+				break
+			}
+			fmt.Fprintln(sb, " ", mapper.describe(file))
+		}
+
+		ts.FinishAction(status.ActionResult{
+			Action: action,
+			Error:  fmt.Errorf(title),
+			Output: sb.String(),
+		})
+		ctx.Fatal("stopping")
+	}
+	ts.FinishAction(status.ActionResult{Action: action})
+}
+
+// findUndeclaredOutputs returns the sorted list of files in the directories containing declared
+// outputs that aren't declared outputs themselves, and that were created or modified since the
+// snapshot.  The depfiles and response files that ninja keeps next to the outputs are not
+// reported.
+func (d *declaredOutputs) findUndeclaredOutputs(readDir func(dir string) ([]os.FileInfo, error)) []string {
+	var undeclared []string
+	d.walk(readDir, func(path string, file os.FileInfo) {
+		if d.declared[path] {
+			return
+		}
+		if strings.HasSuffix(path, ".d") || strings.HasSuffix(path, ".rsp") {
+			return
+		}
+		if modTime, ok := d.snapshot[path]; ok && modTime.Equal(file.ModTime()) {
+			return
+		}
+		undeclared = append(undeclared, path)
+	})
+
+	sort.Strings(undeclared)
+	return undeclared
+}
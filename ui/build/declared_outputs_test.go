@@ -0,0 +1,89 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package build
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestFindUndeclaredOutputs(t *testing.T) {
+	root, err := ioutil.TempDir("", "undeclared_outputs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	intermediates := filepath.Join(root, ".intermediates")
+	write := func(files ...string) {
+		t.Helper()
+		for _, file := range files {
+			path := filepath.Join(intermediates, file)
+			if err := os.MkdirAll(filepath.Dir(path), 0777); err != nil {
+				t.Fatal(err)
+			}
+			if err := ioutil.WriteFile(path, nil, 0666); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	declared := map[string]bool{
+		filepath.Join(intermediates, "foo/android_arm64/foo.so"):        true,
+		filepath.Join(intermediates, "bar/android_arm64/bar.so"):        true,
+		filepath.Join(intermediates, "baz/android_arm64/baz.so"):        true,
+		filepath.Join(root, "soong.variables"):                          true,
+		filepath.Join(intermediates, "foo/android_arm64/javac/foo.jar"): true,
+	}
+
+	// Files left by a previous build, including a stale one that is rewritten by this build.
+	write(
+		"foo/android_arm64/foo.so",
+		"foo/android_arm64/old.txt",
+		"bar/android_arm64/bar.so.map")
+	past := time.Now().Add(-time.Hour)
+	for _, file := range []string{"foo/android_arm64/old.txt", "bar/android_arm64/bar.so.map"} {
+		if err := os.Chtimes(filepath.Join(intermediates, file), past, past); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	d := newDeclaredOutputs(intermediates, declared, ioutil.ReadDir)
+
+	// The files written by this build.
+	write(
+		"foo/android_arm64/foo.so",
+		"foo/android_arm64/foo.so.d",
+		"foo/android_arm64/foo.so.rsp",
+		"foo/android_arm64/foo.so.map",
+		"foo/android_arm64/obj/foo.o",
+		"foo/android_arm64/javac/classes/Foo.class",
+		"bar/android_arm64/bar.so",
+		"bar/android_arm64/bar.so.map",
+		"other.txt")
+
+	got := d.findUndeclaredOutputs(ioutil.ReadDir)
+	want := []string{
+		filepath.Join(intermediates, "bar/android_arm64/bar.so.map"),
+		filepath.Join(intermediates, "foo/android_arm64/foo.so.map"),
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
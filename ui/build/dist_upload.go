@@ -0,0 +1,254 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package build
+
+// This file contains the post-build dist phase that uploads the dist artifacts
+// to an artifact service.
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"android/soong/ui/metrics"
+)
+
+const (
+	// The name of the manifest of the uploaded dist artifacts.  It is uploaded
+	// last, so the artifact service can tell when the upload is complete.
+	distManifestFilename = "dist_manifest.json"
+
+	// The number of dist artifacts uploaded concurrently.
+	distUploadParallelism = 8
+
+	// The maximum time to upload one dist artifact, including the response of
+	// the artifact service.
+	distUploadTimeout = 30 * time.Minute
+
+	// The variable of dist.mk listing the dist outputs of each goal, as
+	// goal:path pairs with paths relative to the dist directory.
+	distGoalOutputPairsVar = "DIST_GOAL_OUTPUT_PAIRS"
+)
+
+// distArtifact is an entry of the dist manifest.
+type distArtifact struct {
+	// The path of the artifact relative to the dist directory.
+	Path   string `json:"path"`
+	Size   int64  `json:"size"`
+	Sha256 string `json:"sha256"`
+}
+
+type distManifest struct {
+	Artifacts []distArtifact `json:"artifacts"`
+}
+
+// distUploader uploads a dist artifact to an artifact service.
+type distUploader interface {
+	// Upload streams the content of the artifact with the given path relative to
+	// the dist directory.
+	Upload(path string, size int64, content io.Reader) error
+}
+
+// httpDistUploader uploads each dist artifact with a PUT request to
+// <url>/<path>.
+type httpDistUploader struct {
+	url    string
+	client *http.Client
+}
+
+func newHttpDistUploader(url string) *httpDistUploader {
+	return &httpDistUploader{
+		url:    url,
+		client: &http.Client{Timeout: distUploadTimeout},
+	}
+}
+
+func (u *httpDistUploader) Upload(path string, size int64, content io.Reader) error {
+	req, err := http.NewRequest(http.MethodPut, u.url+"/"+filepath.ToSlash(path), content)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = size
+	req.Header.Set("Content-Type", "application/octet-stream")
+
+	resp, err := u.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("%s", resp.Status)
+	}
+	return nil
+}
+
+// uploadDist uploads the dist artifacts of the goals of this build to the
+// artifact service set by DIST_UPLOAD_URL at the end of a dist build, followed
+// by a manifest with the size and the SHA-256 digest of each of them.  The
+// digests are computed while the artifacts are streamed, so they are only read
+// once.
+func uploadDist(ctx Context, config Config) {
+	if !config.Dist() || config.DistUploadURL() == "" {
+		return
+	}
+
+	ctx.BeginTrace(metrics.RunShutdownTool, "upload_dist")
+	defer ctx.EndTrace()
+
+	// The files that soong_ui copies to the dist directory in the background
+	// must be complete before they are uploaded.
+	distWaitGroup.Wait()
+
+	paths, err := distGoalOutputs(filepath.Join(config.KatiPackageMkDir(), "dist.mk"), distGoals(config))
+	if err != nil {
+		ctx.Fatalf("failed to find the dist artifacts: %v", err)
+	}
+
+	manifest, err := uploadDistArtifacts(config.RealDistDir(), paths, newHttpDistUploader(config.DistUploadURL()))
+	if err != nil {
+		ctx.Fatalf("failed to upload the dist artifacts to %s: %v", config.DistUploadURL(), err)
+	}
+	ctx.Verbosef("Uploaded %d dist artifacts to %s", len(manifest.Artifacts), config.DistUploadURL())
+}
+
+// distGoals returns the goals of this build whose dist artifacts are uploaded,
+// droid if no goal other than dist was given, like Make.
+func distGoals(config Config) []string {
+	var goals []string
+	for _, arg := range config.NinjaArgs() {
+		if arg != "dist" && !strings.HasPrefix(arg, "-") {
+			goals = append(goals, arg)
+		}
+	}
+	if len(goals) == 0 {
+		goals = []string{"droid"}
+	}
+	return goals
+}
+
+// distGoalOutputs returns the paths relative to the dist directory of the dist
+// artifacts of the goals, from the dist.mk file written by the dist-for-goals
+// calls of Make.
+func distGoalOutputs(distMk string, goals []string) ([]string, error) {
+	data, err := ioutil.ReadFile(distMk)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	var paths []string
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 || fields[0] != distGoalOutputPairsVar || fields[1] != ":=" {
+			continue
+		}
+		for _, pair := range fields[2:] {
+			i := strings.Index(pair, ":")
+			if i < 0 {
+				return nil, fmt.Errorf("%s: invalid %s entry %q", distMk, distGoalOutputPairsVar, pair)
+			}
+			goal, path := pair[:i], pair[i+1:]
+			if inList(goal, goals) && path != distManifestFilename && !seen[path] {
+				seen[path] = true
+				paths = append(paths, path)
+			}
+		}
+	}
+	return paths, nil
+}
+
+// uploadDistArtifacts uploads the files with the given paths relative to
+// distDir, and then their manifest.
+func uploadDistArtifacts(distDir string, paths []string, uploader distUploader) (*distManifest, error) {
+	paths = append([]string(nil), paths...)
+	sort.Strings(paths)
+
+	manifest := &distManifest{Artifacts: make([]distArtifact, len(paths))}
+	errs := make([]error, len(paths))
+
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < distUploadParallelism; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				manifest.Artifacts[i], errs[i] = uploadDistArtifact(distDir, paths[i], uploader)
+			}
+		}()
+	}
+	for i := range paths {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+
+	var failed []string
+	for i, err := range errs {
+		if err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %v", paths[i], err))
+		}
+	}
+	if len(failed) > 0 {
+		return nil, fmt.Errorf("failed to upload %d artifacts:\n%s", len(failed), strings.Join(failed, "\n"))
+	}
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := uploader.Upload(distManifestFilename, int64(len(data)), bytes.NewReader(data)); err != nil {
+		return nil, fmt.Errorf("%s: %v", distManifestFilename, err)
+	}
+
+	return manifest, nil
+}
+
+func uploadDistArtifact(distDir, path string, uploader distUploader) (distArtifact, error) {
+	f, err := os.Open(filepath.Join(distDir, path))
+	if err != nil {
+		return distArtifact{}, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return distArtifact{}, err
+	}
+
+	hash := sha256.New()
+	if err := uploader.Upload(path, info.Size(), io.TeeReader(f, hash)); err != nil {
+		return distArtifact{}, err
+	}
+
+	return distArtifact{
+		Path:   filepath.ToSlash(path),
+		Size:   info.Size(),
+		Sha256: hex.EncodeToString(hash.Sum(nil)),
+	}, nil
+}
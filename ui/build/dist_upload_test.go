@@ -0,0 +1,153 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package build
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestUploadDistArtifacts(t *testing.T) {
+	distDir := t.TempDir()
+	for file, content := range map[string]string{
+		"foo.zip":          "foo",
+		"bar/bar.img":      "bar",
+		"logs/soong.log":   "log",
+		"soong_ui/out.txt": "",
+	} {
+		path := filepath.Join(distDir, file)
+		if err := os.MkdirAll(filepath.Dir(path), 0777); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var lock sync.Mutex
+	uploaded := make(map[string]string)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPut {
+			t.Errorf("got method %q, want PUT", r.Method)
+		}
+		data, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			t.Error(err)
+		}
+		lock.Lock()
+		defer lock.Unlock()
+		uploaded[r.URL.Path] = string(data)
+	}))
+	defer server.Close()
+
+	// logs/soong.log isn't a dist artifact of the goals of the build.
+	paths := []string{"soong_ui/out.txt", "foo.zip", "bar/bar.img"}
+	manifest, err := uploadDistArtifacts(distDir, paths, newHttpDistUploader(server.URL+"/builds/1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []distArtifact{
+		{
+			Path:   "bar/bar.img",
+			Size:   3,
+			Sha256: "fcde2b2edba56bf408601fb721fe9b5c338d10ee429ea04fae5511b68fbf8fb9",
+		},
+		{
+			Path:   "foo.zip",
+			Size:   3,
+			Sha256: "2c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae",
+		},
+		{
+			Path:   "soong_ui/out.txt",
+			Size:   0,
+			Sha256: "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855",
+		},
+	}
+	if !reflect.DeepEqual(manifest.Artifacts, want) {
+		t.Errorf("got manifest %v, want %v", manifest.Artifacts, want)
+	}
+
+	var gotManifest distManifest
+	if err := json.Unmarshal([]byte(uploaded["/builds/1/"+distManifestFilename]), &gotManifest); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(gotManifest.Artifacts, want) {
+		t.Errorf("got uploaded manifest %v, want %v", gotManifest.Artifacts, want)
+	}
+
+	wantUploaded := map[string]string{
+		"/builds/1/bar/bar.img":      "bar",
+		"/builds/1/foo.zip":          "foo",
+		"/builds/1/soong_ui/out.txt": "",
+	}
+	delete(uploaded, "/builds/1/"+distManifestFilename)
+	if !reflect.DeepEqual(uploaded, wantUploaded) {
+		t.Errorf("got uploaded %q, want %q", uploaded, wantUploaded)
+	}
+}
+
+func TestUploadDistArtifactsFailure(t *testing.T) {
+	distDir := t.TempDir()
+	if err := ioutil.WriteFile(filepath.Join(distDir, "foo.zip"), []byte("foo"), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	var manifestUploaded bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, distManifestFilename) {
+			manifestUploaded = true
+		}
+		http.Error(w, "denied", http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	_, err := uploadDistArtifacts(distDir, []string{"foo.zip"}, newHttpDistUploader(server.URL))
+	if err == nil || !strings.Contains(err.Error(), "foo.zip: 403 Forbidden") {
+		t.Errorf("got error %v, want foo.zip upload failure", err)
+	}
+	if manifestUploaded {
+		t.Errorf("manifest uploaded after a failed upload")
+	}
+}
+
+func TestDistGoalOutputs(t *testing.T) {
+	distMk := filepath.Join(t.TempDir(), "dist.mk")
+	content := "DIST_GOAL_OUTPUT_PAIRS := droid:foo.zip droid:bar/bar.img sdk:sdk.zip tests:foo.zip\n" +
+		"DIST_SRC_DST_PAIRS := out/foo.zip:foo.zip out/bar.img:bar/bar.img out/sdk.zip:sdk.zip\n"
+	if err := ioutil.WriteFile(distMk, []byte(content), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := distGoalOutputs(distMk, []string{"droid", "tests"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"foo.zip", "bar/bar.img"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	if uploader := newHttpDistUploader("http://localhost"); uploader.client.Timeout == 0 {
+		t.Errorf("dist uploads have no timeout")
+	}
+}
@@ -36,6 +36,9 @@ type Cmd struct {
 	config Config
 	name   string
 
+	// If set, lines of output of RunAndStreamOrFatal for which it returns true are not printed.
+	outputFilter func(line string) bool
+
 	started time.Time
 }
 
@@ -190,7 +193,10 @@ func (c *Cmd) RunAndStreamOrFatal() {
 		// Attempt to read whole lines, but write partial lines that are too long to fit in the buffer or hit EOF
 		line, err := buf.ReadString('\n')
 		if line != "" {
-			st.Print(strings.TrimSuffix(line, "\n"))
+			line = strings.TrimSuffix(line, "\n")
+			if c.outputFilter == nil || !c.outputFilter(line) {
+				st.Print(line)
+			}
 		} else if err == io.EOF {
 			break
 		} else if err != nil {
@@ -0,0 +1,294 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package build
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"android/soong/shared"
+)
+
+// This file implements the --explain option of soong_ui, which reports why outputs were rebuilt.
+// Ninja is run with -d explain, and the reasons it prints for each rebuilt output are translated
+// from paths into the modules that own them, using the module-info.json file of the product and
+// the layout of the Soong intermediates directory. Changes to the environment variables that
+// soong_build read, which make it re-run and regenerate the build graph, are reported as well.
+
+const ninjaExplainPrefix = "ninja explain: "
+
+// The number of modules listed in the summary printed at the end of the build. The full report
+// is written to explain.txt in the logs directory.
+const explainSummaryModules = 20
+
+// ninjaExplanation is a reason ninja gave for rebuilding an output.
+type ninjaExplanation struct {
+	output string
+	reason string
+	// The input that caused the rebuild, if any.
+	input string
+}
+
+var ninjaExplainPatterns = []struct {
+	re     *regexp.Regexp
+	reason string
+}{
+	{regexp.MustCompile(`^output (\S+) older than most recent input (\S+) `), "input changed"},
+	{regexp.MustCompile(`^restat of output (\S+) older than most recent input (\S+) `), "input changed"},
+	{regexp.MustCompile(`^recorded mtime of (\S+) older than most recent input (\S+) `), "input changed"},
+	{regexp.MustCompile(`^output (\S+) doesn't exist$`), "output missing"},
+	{regexp.MustCompile(`^command line changed for (\S+)$`), "command line changed"},
+	{regexp.MustCompile(`^depfile '(\S+)' is missing$`), "depfile missing"},
+	{regexp.MustCompile(`^deps for '(\S+)' are missing$`), "deps missing"},
+}
+
+// parseNinjaExplain parses a line of ninja -d explain output. It returns false for lines that
+// aren't explanations, and for explanations that aren't about a specific output.
+func parseNinjaExplain(line string) (ninjaExplanation, bool) {
+	if !strings.HasPrefix(line, ninjaExplainPrefix) {
+		return ninjaExplanation{}, false
+	}
+	line = strings.TrimPrefix(line, ninjaExplainPrefix)
+	for _, p := range ninjaExplainPatterns {
+		if m := p.re.FindStringSubmatch(line); m != nil {
+			e := ninjaExplanation{output: m[1], reason: p.reason}
+			if len(m) > 2 {
+				e.input = m[2]
+			}
+			return e, true
+		}
+	}
+	return ninjaExplanation{}, false
+}
+
+// moduleInfoJsonEntry is the part of an entry of module-info.json that is used to map paths to
+// modules.
+type moduleInfoJsonEntry struct {
+	ModuleName string   `json:"module_name"`
+	Path       []string `json:"path"`
+	Installed  []string `json:"installed"`
+}
+
+// explainPathMapper describes the paths ninja reports in terms of modules.
+type explainPathMapper struct {
+	outDir          string
+	soongOutDir     string
+	intermediates   string
+	installedFiles  map[string]string
+	intermediateDir map[string]string
+}
+
+func newExplainPathMapper(outDir, soongOutDir string, moduleInfo map[string]moduleInfoJsonEntry) *explainPathMapper {
+	m := &explainPathMapper{
+		outDir:          filepath.Clean(outDir),
+		soongOutDir:     filepath.Clean(soongOutDir),
+		intermediates:   filepath.Join(soongOutDir, ".intermediates"),
+		installedFiles:  make(map[string]string),
+		intermediateDir: make(map[string]string),
+	}
+	for name, info := range moduleInfo {
+		if info.ModuleName != "" {
+			name = info.ModuleName
+		}
+		for _, installed := range info.Installed {
+			m.installedFiles[filepath.Clean(installed)] = name
+		}
+		for _, dir := range info.Path {
+			m.intermediateDir[filepath.Join(m.intermediates, dir, name)] = name
+		}
+	}
+	return m
+}
+
+// loadExplainPathMapper returns an explainPathMapper for the modules of the last build.  Paths
+// are described without modules if module-info.json can't be read.
+func loadExplainPathMapper(ctx Context, config Config) *explainPathMapper {
+	moduleInfo := make(map[string]moduleInfoJsonEntry)
+	moduleInfoFile := filepath.Join(config.ProductOut(), "module-info.json")
+	if data, err := ioutil.ReadFile(moduleInfoFile); err != nil {
+		ctx.Verbosef("can't read %s, reporting paths instead of modules: %s", moduleInfoFile, err)
+	} else if err := json.Unmarshal(data, &moduleInfo); err != nil {
+		ctx.Verbosef("can't parse %s, reporting paths instead of modules: %s", moduleInfoFile, err)
+	}
+	return newExplainPathMapper(config.OutDir(), config.SoongOutDir(), moduleInfo)
+}
+
+// describe returns a description of a path in terms of the module that owns it.
+func (m *explainPathMapper) describe(path string) string {
+	path = filepath.Clean(path)
+	if module, ok := m.installedFiles[path]; ok {
+		return fmt.Sprintf("module %s (installed file %s)", module, path)
+	}
+	if strings.HasPrefix(path, m.intermediates+"/") {
+		// Soong intermediates are in .intermediates/<module dir>/<module>/<variant>/.
+		for dir := filepath.Dir(path); dir != m.intermediates && dir != "."; dir = filepath.Dir(dir) {
+			if module, ok := m.intermediateDir[dir]; ok {
+				rel := strings.TrimPrefix(path, dir+"/")
+				variant := strings.SplitN(rel, "/", 2)[0]
+				if variant == rel {
+					return fmt.Sprintf("module %s (%s)", module, path)
+				}
+				return fmt.Sprintf("module %s variant %s (%s)", module, variant, path)
+			}
+		}
+	}
+	switch filepath.Base(path) {
+	case "soong.variables":
+		if filepath.Dir(path) == m.soongOutDir {
+			return "product variables (" + path + ")"
+		}
+	case "build.ninja":
+		if filepath.Dir(path) == m.soongOutDir {
+			return "Soong build graph (" + path + ")"
+		}
+	}
+	if path != m.outDir && !strings.HasPrefix(path, m.outDir+"/") {
+		return "source file " + path
+	}
+	return path
+}
+
+// changedEnvironment returns the environment variables recorded in the used environment file
+// envFile whose values have changed, as NAME ("old" -> "new").
+func changedEnvironment(envFile string, getenv func(string) string) []string {
+	recorded, err := shared.EnvFromFile(envFile)
+	if err != nil {
+		return nil
+	}
+	var changed []string
+	for key, old := range recorded {
+		if cur := getenv(key); cur != old {
+			changed = append(changed, fmt.Sprintf("%s (%q -> %q)", key, old, cur))
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
+
+// ninjaExplainer collects the explanations ninja prints during the build.
+type ninjaExplainer struct {
+	lock         sync.Mutex
+	explanations []ninjaExplanation
+}
+
+// consume records the line if it is ninja -d explain output, and returns whether it was.
+func (e *ninjaExplainer) consume(line string) bool {
+	if !strings.HasPrefix(line, ninjaExplainPrefix) {
+		return false
+	}
+	if explanation, ok := parseNinjaExplain(line); ok {
+		e.lock.Lock()
+		e.explanations = append(e.explanations, explanation)
+		e.lock.Unlock()
+	}
+	return true
+}
+
+// explainReport returns the lines of the report of the explanations, grouped by module in the
+// order the modules were first rebuilt, and the number of modules.
+func explainReport(mapper *explainPathMapper, explanations []ninjaExplanation) ([]string, int) {
+	type group struct {
+		lines []string
+		seen  map[string]bool
+	}
+	var order []string
+	groups := make(map[string]*group)
+
+	for _, e := range explanations {
+		owner := mapper.describe(e.output)
+		if strings.HasPrefix(owner, "module ") {
+			// Group by module, regardless of the variant or the file.
+			owner = strings.SplitN(owner, " ", 3)[1]
+		}
+		g := groups[owner]
+		if g == nil {
+			g = &group{seen: make(map[string]bool)}
+			groups[owner] = g
+			order = append(order, owner)
+		}
+		line := fmt.Sprintf("  %s: %s", e.output, e.reason)
+		if e.input != "" {
+			line += ": " + mapper.describe(e.input)
+		}
+		if !g.seen[line] {
+			g.seen[line] = true
+			g.lines = append(g.lines, line)
+		}
+	}
+
+	var report []string
+	for _, owner := range order {
+		report = append(report, owner+":")
+		report = append(report, groups[owner].lines...)
+	}
+	return report, len(order)
+}
+
+// environmentReport returns the lines of the report of the environment variable changes that
+// made soong_build re-run.
+func environmentReport(environment []string) []string {
+	if len(environment) == 0 {
+		return nil
+	}
+	report := []string{"environment variables changed:"}
+	for _, change := range environment {
+		report = append(report, "  "+change)
+	}
+	return report
+}
+
+// report writes the report of why outputs were rebuilt to explain.txt in the logs directory, and
+// prints a summary.
+func (e *ninjaExplainer) report(ctx Context, config Config) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	mapper := loadExplainPathMapper(ctx, config)
+
+	environment := environmentReport(config.ExplainedEnvironment())
+	report, modules := explainReport(mapper, e.explanations)
+	reportFile := filepath.Join(config.LogsDir(), "explain.txt")
+	contents := strings.Join(append(append([]string(nil), environment...), report...), "\n") + "\n"
+	if err := ioutil.WriteFile(reportFile, []byte(contents), 0666); err != nil {
+		ctx.Println("Failed to write explain report:", err)
+		return
+	}
+
+	for _, line := range environment {
+		ctx.Println(line)
+	}
+	if modules == 0 {
+		ctx.Println("explain: nothing was rebuilt")
+		return
+	}
+	ctx.Printf("explain: %d outputs of %d modules or paths were rebuilt, see %s", len(e.explanations), modules, reportFile)
+	printed := 0
+	for _, line := range report {
+		if !strings.HasPrefix(line, " ") {
+			if printed == explainSummaryModules {
+				ctx.Printf("  ... and %d more", modules-printed)
+				break
+			}
+			printed++
+		}
+		ctx.Println(line)
+	}
+}
@@ -0,0 +1,204 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package build
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"android/soong/shared"
+)
+
+func TestParseNinjaExplain(t *testing.T) {
+	testCases := []struct {
+		line string
+		want ninjaExplanation
+		ok   bool
+	}{
+		{
+			line: "ninja explain: output out/a.o older than most recent input foo/a.c (1234 vs 5678)",
+			want: ninjaExplanation{output: "out/a.o", reason: "input changed", input: "foo/a.c"},
+			ok:   true,
+		},
+		{
+			line: "ninja explain: restat of output out/a.o older than most recent input foo/a.c (1234 vs 5678)",
+			want: ninjaExplanation{output: "out/a.o", reason: "input changed", input: "foo/a.c"},
+			ok:   true,
+		},
+		{
+			line: "ninja explain: output out/a.o doesn't exist",
+			want: ninjaExplanation{output: "out/a.o", reason: "output missing"},
+			ok:   true,
+		},
+		{
+			line: "ninja explain: command line changed for out/a.o",
+			want: ninjaExplanation{output: "out/a.o", reason: "command line changed"},
+			ok:   true,
+		},
+		{
+			line: "ninja explain: depfile 'out/a.d' is missing",
+			want: ninjaExplanation{output: "out/a.d", reason: "depfile missing"},
+			ok:   true,
+		},
+		{
+			line: "ninja explain: out/a.o is dirty",
+			ok:   false,
+		},
+		{
+			line: "[1/2] CC a.c",
+			ok:   false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.line, func(t *testing.T) {
+			got, ok := parseNinjaExplain(tc.line)
+			if ok != tc.ok {
+				t.Fatalf("expected ok %v, got %v", tc.ok, ok)
+			}
+			if got != tc.want {
+				t.Errorf("expected %+v, got %+v", tc.want, got)
+			}
+		})
+	}
+}
+
+func testExplainPathMapper() *explainPathMapper {
+	return newExplainPathMapper("out", "out/soong", map[string]moduleInfoJsonEntry{
+		"libfoo": {
+			ModuleName: "libfoo",
+			Path:       []string{"external/foo"},
+			Installed:  []string{"out/target/product/test/system/lib64/libfoo.so"},
+		},
+	})
+}
+
+func TestExplainPathMapperDescribe(t *testing.T) {
+	mapper := testExplainPathMapper()
+
+	testCases := []struct {
+		path string
+		want string
+	}{
+		{
+			path: "out/target/product/test/system/lib64/libfoo.so",
+			want: "module libfoo (installed file out/target/product/test/system/lib64/libfoo.so)",
+		},
+		{
+			path: "out/soong/.intermediates/external/foo/libfoo/android_arm64_armv8-a_shared/libfoo.so",
+			want: "module libfoo variant android_arm64_armv8-a_shared (out/soong/.intermediates/external/foo/libfoo/android_arm64_armv8-a_shared/libfoo.so)",
+		},
+		{
+			path: "out/soong/soong.variables",
+			want: "product variables (out/soong/soong.variables)",
+		},
+		{
+			path: "out/soong/build.ninja",
+			want: "Soong build graph (out/soong/build.ninja)",
+		},
+		{
+			path: "external/foo/foo.c",
+			want: "source file external/foo/foo.c",
+		},
+		{
+			path: "out/soong/.intermediates/external/bar/libbar/android_arm64_armv8-a_shared/libbar.so",
+			want: "out/soong/.intermediates/external/bar/libbar/android_arm64_armv8-a_shared/libbar.so",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.path, func(t *testing.T) {
+			if got := mapper.describe(tc.path); got != tc.want {
+				t.Errorf("expected %q, got %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestExplainReport(t *testing.T) {
+	explainer := &ninjaExplainer{}
+	for _, line := range []string{
+		"ninja explain: output out/soong/.intermediates/external/foo/libfoo/android_arm64_armv8-a_shared/obj/foo.o older than most recent input external/foo/foo.c (1 vs 2)",
+		"ninja explain: output out/soong/.intermediates/external/foo/libfoo/android_arm64_armv8-a_shared/obj/foo.o older than most recent input external/foo/foo.c (1 vs 2)",
+		"ninja explain: output out/soong/.intermediates/external/foo/libfoo/android_arm64_armv8-a_static/obj/foo.o doesn't exist",
+		"ninja explain: command line changed for out/other.txt",
+		"ninja explain: out/other.txt is dirty",
+	} {
+		if !explainer.consume(line) {
+			t.Errorf("expected explain line %q to be consumed", line)
+		}
+	}
+	if explainer.consume("[1/2] CC foo.c") {
+		t.Errorf("expected status line not to be consumed")
+	}
+
+	report, modules := explainReport(testExplainPathMapper(), explainer.explanations)
+	want := []string{
+		"libfoo:",
+		"  out/soong/.intermediates/external/foo/libfoo/android_arm64_armv8-a_shared/obj/foo.o: input changed: source file external/foo/foo.c",
+		"  out/soong/.intermediates/external/foo/libfoo/android_arm64_armv8-a_static/obj/foo.o: output missing",
+		"out/other.txt:",
+		"  out/other.txt: command line changed",
+	}
+	if !reflect.DeepEqual(report, want) {
+		t.Errorf("expected report:\n%q\ngot:\n%q", want, report)
+	}
+	if modules != 2 {
+		t.Errorf("expected 2 modules, got %d", modules)
+	}
+}
+
+func TestChangedEnvironment(t *testing.T) {
+	dir, err := ioutil.TempDir("", "testchangedenvironment")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	data, err := shared.EnvFileContents(map[string]string{
+		"SAME":    "a",
+		"CHANGED": "b",
+		"UNSET":   "c",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	envFile := filepath.Join(dir, "soong.environment.used.build")
+	if err := ioutil.WriteFile(envFile, data, 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	env := map[string]string{
+		"SAME":    "a",
+		"CHANGED": "d",
+		"UNUSED":  "e",
+	}
+	got := environmentReport(changedEnvironment(envFile, func(k string) string { return env[k] }))
+	want := []string{
+		"environment variables changed:",
+		`  CHANGED ("b" -> "d")`,
+		`  UNSET ("c" -> "")`,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected report:\n%q\ngot:\n%q", want, got)
+	}
+
+	if got := changedEnvironment(filepath.Join(dir, "missing"), func(string) string { return "" }); got != nil {
+		t.Errorf("expected no changes for a missing environment file, got %q", got)
+	}
+}
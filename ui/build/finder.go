@@ -191,6 +191,14 @@ func FindSources(ctx Context, config Config, f *finder.Finder) {
 	if len(androidBps) == 0 {
 		ctx.Fatalf("No Android.bp found")
 	}
+	if dirs := config.PartialBuildDirs(); len(dirs) > 0 {
+		androidBps, err = partialBuildAndroidBps(dirs, androidBps, ioutil.ReadFile)
+		if err != nil {
+			ctx.Fatalf("Could not find the modules of the partial build: %v", err)
+		}
+		ctx.Verbosef("Partial build of %s analyzes %d Android.bp files",
+			strings.Join(dirs, ", "), len(androidBps))
+	}
 	err = dumpListToFile(ctx, config, androidBps, filepath.Join(dumpDir, "Android.bp.list"))
 	if err != nil {
 		ctx.Fatalf("Could not find modules: %v", err)
@@ -49,6 +49,12 @@ func runNinjaForBuild(ctx Context, config Config) {
 		"--frontend_file", fifo,
 	}
 
+	var explainer *ninjaExplainer
+	if config.Explain() {
+		args = append(args, "-d", "explain")
+		explainer = &ninjaExplainer{}
+	}
+
 	args = append(args, config.NinjaArgs()...)
 
 	var parallel int
@@ -207,6 +213,12 @@ func runNinjaForBuild(ctx Context, config Config) {
 		}
 	}()
 
+	if explainer != nil {
+		// Report why outputs were rebuilt even when the build fails.
+		cmd.outputFilter = explainer.consume
+		defer explainer.report(ctx, config)
+	}
+
 	ctx.Status.Status("Starting ninja...")
 	cmd.RunAndStreamOrFatal()
 }
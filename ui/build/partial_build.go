@@ -0,0 +1,181 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package build
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/google/blueprint/parser"
+)
+
+// This file implements partial builds of directory subtrees, requested on the command line as
+// //path/to/dir/.... A partial build only hands soong_build the Android.bp files in the requested
+// subtrees, the Android.bp files that define the modules they reference (transitively), and the
+// Android.bp files of a few core directories whose modules Soong adds as implicit dependencies.
+// The Android.bp files are only parsed here, not evaluated, so finding them is much cheaper than
+// analyzing the whole tree.
+//
+// Dependencies that are added by mutators rather than listed in an Android.bp file can't be found
+// this way, so partial builds run with ALLOW_MISSING_DEPENDENCIES=true in the environment of
+// soong_build, Kati and Make: a module that actually needs a missing dependency fails when it is
+// built rather than when it is analyzed.
+
+// partialBuildCoreDirs are always analyzed in partial builds, as they contain the modules that are
+// added as implicit dependencies of most modules, e.g. the licenses, crt objects and libc++.
+var partialBuildCoreDirs = []string{
+	"bionic",
+	"build/soong",
+	"external/libcxx",
+	"external/libcxxabi",
+	"prebuilts/clang/host",
+}
+
+// parsePartialBuildArg returns the directory of a //path/to/dir/... command line argument.
+func parsePartialBuildArg(arg string) (dir string, ok bool) {
+	if !strings.HasPrefix(arg, "//") || !strings.HasSuffix(arg, "/...") {
+		return "", false
+	}
+	return filepath.Clean(strings.TrimSuffix(strings.TrimPrefix(arg, "//"), "/...")), true
+}
+
+// inPartialBuildDir returns true if path is in one of dirs.
+func inPartialBuildDir(path string, dirs []string) bool {
+	for _, dir := range dirs {
+		if path == dir || strings.HasPrefix(path, dir+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// partialBuildAndroidBps returns the Android.bp files of androidBps that a partial build of dirs
+// analyzes, in the order of androidBps. readFile is used to read the Android.bp files.
+func partialBuildAndroidBps(dirs []string, androidBps []string,
+	readFile func(string) ([]byte, error)) ([]string, error) {
+
+	// Index the modules defined by each Android.bp file and the names each one references.
+	definedIn := make(map[string][]string)
+	references := make(map[string][]string)
+	isAndroidBp := make(map[string]bool)
+	for _, bp := range androidBps {
+		isAndroidBp[bp] = true
+		contents, err := readFile(bp)
+		if err != nil {
+			return nil, err
+		}
+		file, errs := parser.Parse(bp, bytes.NewReader(contents), parser.NewScope(nil))
+		if len(errs) > 0 {
+			// soong_build reports the syntax errors if the file is analyzed.
+			continue
+		}
+		for _, def := range file.Defs {
+			switch def := def.(type) {
+			case *parser.Module:
+				for _, prop := range def.Properties {
+					if s, ok := prop.Value.(*parser.String); ok && prop.Name == "name" {
+						definedIn[s.Value] = append(definedIn[s.Value], bp)
+					} else {
+						references[bp] = appendReferencedNames(references[bp], prop.Value)
+					}
+				}
+			case *parser.Assignment:
+				references[bp] = appendReferencedNames(references[bp], def.Value)
+			}
+		}
+	}
+
+	included := make(map[string]bool)
+	var queue []string
+	include := func(bp string) {
+		// The Android.bp files of the ancestor directories may define the package, license and
+		// namespace of the modules in bp.
+		for dir := filepath.Dir(bp); ; dir = filepath.Dir(dir) {
+			if ancestor := filepath.Join(dir, "Android.bp"); isAndroidBp[ancestor] && !included[ancestor] {
+				included[ancestor] = true
+				queue = append(queue, ancestor)
+			}
+			if dir == "." {
+				break
+			}
+		}
+	}
+
+	found := false
+	for _, bp := range androidBps {
+		if inPartialBuildDir(filepath.Dir(bp), dirs) {
+			found = true
+			include(bp)
+		} else if inPartialBuildDir(filepath.Dir(bp), partialBuildCoreDirs) {
+			include(bp)
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("no Android.bp files found in %s", strings.Join(dirs, ", "))
+	}
+
+	for len(queue) > 0 {
+		bp := queue[0]
+		queue = queue[1:]
+		for _, name := range references[bp] {
+			for _, dep := range definedIn[name] {
+				include(dep)
+			}
+		}
+	}
+
+	var ret []string
+	for _, bp := range androidBps {
+		if included[bp] {
+			ret = append(ret, bp)
+		}
+	}
+	return ret, nil
+}
+
+// appendReferencedNames appends the module names that value may reference to names. Every string
+// in value is treated as a potential module name, so more Android.bp files may be analyzed than
+// strictly necessary, but none that are needed are missed.
+func appendReferencedNames(names []string, value parser.Expression) []string {
+	switch v := value.(type) {
+	case *parser.String:
+		name := v.Value
+		// Strip the ":" of module references in srcs and the {tag} of output file references.
+		if i := strings.Index(name, "{"); i >= 0 {
+			name = name[:i]
+		}
+		// Strip the //namespace of fully qualified names.
+		if i := strings.LastIndex(name, ":"); i >= 0 {
+			name = name[i+1:]
+		}
+		if name != "" {
+			names = append(names, name)
+		}
+	case *parser.List:
+		for _, e := range v.Values {
+			names = appendReferencedNames(names, e)
+		}
+	case *parser.Map:
+		for _, prop := range v.Properties {
+			names = appendReferencedNames(names, prop.Value)
+		}
+	case *parser.Operator:
+		names = appendReferencedNames(names, v.Args[0])
+		names = appendReferencedNames(names, v.Args[1])
+	}
+	return names
+}
@@ -0,0 +1,129 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package build
+
+import (
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestParsePartialBuildArg(t *testing.T) {
+	testCases := []struct {
+		arg string
+		dir string
+		ok  bool
+	}{
+		{arg: "//vendor/foo/...", dir: "vendor/foo", ok: true},
+		{arg: "//vendor/foo/bar/...", dir: "vendor/foo/bar", ok: true},
+		{arg: "//vendor/foo/", ok: false},
+		{arg: "vendor/foo/...", ok: false},
+		{arg: "droid", ok: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.arg, func(t *testing.T) {
+			dir, ok := parsePartialBuildArg(tc.arg)
+			if ok != tc.ok || dir != tc.dir {
+				t.Errorf("expected %q, %v, got %q, %v", tc.dir, tc.ok, dir, ok)
+			}
+		})
+	}
+}
+
+func TestPartialBuildAndroidBps(t *testing.T) {
+	files := map[string]string{
+		"Android.bp": ``,
+		"build/soong/Android.bp": `
+			license { name: "Android-Apache-2.0" }
+		`,
+		"vendor/Android.bp": `
+			package { default_applicable_licenses: ["Android-Apache-2.0"] }
+		`,
+		"vendor/foo/Android.bp": `
+			cc_binary {
+				name: "foo",
+				shared_libs: ["libbar"],
+				srcs: [":baz_srcs{.c}"],
+				target: { android: { static_libs: ["//external/qux:libqux"] } },
+			}
+		`,
+		"vendor/foo/sub/Android.bp": `
+			cc_library { name: "libfoo_sub" }
+		`,
+		"system/bar/Android.bp": `
+			cc_library { name: "libbar", shared_libs: ["libbar_dep"] }
+		`,
+		"system/bar_dep/Android.bp": `
+			cc_library { name: "libbar_dep" }
+		`,
+		"external/baz/Android.bp": `
+			filegroup { name: "baz_srcs" }
+		`,
+		"external/qux/Android.bp": `
+			cc_library { name: "libqux" }
+		`,
+		"external/unrelated/Android.bp": `
+			cc_library { name: "libunrelated", shared_libs: ["libfoo_sub"] }
+		`,
+		"external/broken/Android.bp": `
+			cc_library {
+		`,
+	}
+
+	androidBps := []string{
+		"Android.bp",
+		"build/soong/Android.bp",
+		"external/baz/Android.bp",
+		"external/broken/Android.bp",
+		"external/qux/Android.bp",
+		"external/unrelated/Android.bp",
+		"system/bar/Android.bp",
+		"system/bar_dep/Android.bp",
+		"vendor/Android.bp",
+		"vendor/foo/Android.bp",
+		"vendor/foo/sub/Android.bp",
+	}
+
+	readFile := func(path string) ([]byte, error) {
+		if contents, ok := files[path]; ok {
+			return []byte(contents), nil
+		}
+		return nil, os.ErrNotExist
+	}
+
+	got, err := partialBuildAndroidBps([]string{"vendor/foo"}, androidBps, readFile)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	expected := []string{
+		"Android.bp",
+		"build/soong/Android.bp",
+		"external/baz/Android.bp",
+		"external/qux/Android.bp",
+		"system/bar/Android.bp",
+		"system/bar_dep/Android.bp",
+		"vendor/Android.bp",
+		"vendor/foo/Android.bp",
+		"vendor/foo/sub/Android.bp",
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected Android.bp files:\n%q\ngot:\n%q", expected, got)
+	}
+
+	if _, err := partialBuildAndroidBps([]string{"vendor/missing"}, androidBps, readFile); err == nil {
+		t.Errorf("expected an error for a directory without Android.bp files")
+	}
+}
@@ -358,12 +358,19 @@ func bootstrapBlueprint(ctx Context, config Config) {
 	}
 }
 
-func checkEnvironmentFile(currentEnv *Environment, envFile string) {
+func checkEnvironmentFile(config Config, currentEnv *Environment, tag string) {
+	envFile := config.UsedEnvFile(tag)
 	getenv := func(k string) string {
 		v, _ := currentEnv.Get(k)
 		return v
 	}
 
+	if config.Explain() {
+		for _, change := range changedEnvironment(envFile, getenv) {
+			config.addExplainedEnvironment(fmt.Sprintf("soong_build (%s): %s", tag, change))
+		}
+	}
+
 	if stale, _ := shared.StaleEnvFile(envFile, getenv); stale {
 		os.Remove(envFile)
 	}
@@ -409,22 +416,22 @@ func runSoong(ctx Context, config Config) {
 		ctx.BeginTrace(metrics.RunSoong, "environment check")
 		defer ctx.EndTrace()
 
-		checkEnvironmentFile(soongBuildEnv, config.UsedEnvFile(soongBuildTag))
+		checkEnvironmentFile(config, soongBuildEnv, soongBuildTag)
 
 		if integratedBp2Build || config.Bp2Build() {
-			checkEnvironmentFile(soongBuildEnv, config.UsedEnvFile(bp2buildTag))
+			checkEnvironmentFile(config, soongBuildEnv, bp2buildTag)
 		}
 
 		if config.JsonModuleGraph() {
-			checkEnvironmentFile(soongBuildEnv, config.UsedEnvFile(jsonModuleGraphTag))
+			checkEnvironmentFile(config, soongBuildEnv, jsonModuleGraphTag)
 		}
 
 		if config.Queryview() {
-			checkEnvironmentFile(soongBuildEnv, config.UsedEnvFile(queryviewTag))
+			checkEnvironmentFile(config, soongBuildEnv, queryviewTag)
 		}
 
 		if config.SoongDocs() {
-			checkEnvironmentFile(soongBuildEnv, config.UsedEnvFile(soongDocsTag))
+			checkEnvironmentFile(config, soongBuildEnv, soongDocsTag)
 		}
 	}()
 
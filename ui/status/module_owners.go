@@ -0,0 +1,87 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package status
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"android/soong/ui/logger"
+)
+
+// moduleOwners is the attribution of a module in the module_owners.json file written by
+// soong_build.
+type moduleOwners struct {
+	Name         string   `json:"name"`
+	Path         string   `json:"path"`
+	OwnersFile   string   `json:"owners_file"`
+	Owners       []string `json:"owners"`
+	BugComponent string   `json:"bug_component"`
+}
+
+// NewModuleOwnersAnnotator returns an ErrorAnnotator that attributes failed actions to the owners
+// and the bug component of the Soong module that their outputs belong to, using the
+// module_owners.json file written by soong_build.  The file is read when an action fails, as it
+// is written during the build.
+func NewModuleOwnersAnnotator(log logger.Logger, filename string) ErrorAnnotator {
+	var owners map[string]moduleOwners
+	return func(result ActionResult) string {
+		if owners == nil {
+			data, err := ioutil.ReadFile(filename)
+			if err != nil {
+				log.Verbosef("Failed to read %s: %v", filename, err)
+				return ""
+			}
+			if err := json.Unmarshal(data, &owners); err != nil {
+				log.Printf("Failed to parse %s: %v", filename, err)
+				return ""
+			}
+		}
+
+		for _, output := range result.Outputs {
+			if info, ok := lookupModuleOwners(owners, output); ok {
+				return formatModuleOwners(info)
+			}
+		}
+		return ""
+	}
+}
+
+// lookupModuleOwners returns the attribution of the module whose intermediates directory
+// contains the output.
+func lookupModuleOwners(owners map[string]moduleOwners, output string) (moduleOwners, bool) {
+	for dir := filepath.Dir(output); dir != "." && dir != "/"; dir = filepath.Dir(dir) {
+		if info, ok := owners[dir]; ok {
+			return info, true
+		}
+	}
+	return moduleOwners{}, false
+}
+
+func formatModuleOwners(info moduleOwners) string {
+	s := fmt.Sprintf("Module: %s (%s)\n", info.Name, info.Path)
+	if len(info.Owners) > 0 {
+		s += fmt.Sprintf("Owners: %s (%s)\n", strings.Join(info.Owners, ", "), info.OwnersFile)
+	} else if info.OwnersFile != "" {
+		s += fmt.Sprintf("Owners: %s\n", info.OwnersFile)
+	}
+	if info.BugComponent != "" {
+		s += fmt.Sprintf("Bug component: %s\n", info.BugComponent)
+	}
+	return s
+}
@@ -0,0 +1,93 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package status
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"android/soong/ui/logger"
+)
+
+type resultOutput struct {
+	counterOutput
+	results []ActionResult
+}
+
+func (r *resultOutput) FinishAction(result ActionResult, counts Counts) {
+	r.results = append(r.results, result)
+}
+
+func TestModuleOwnersAnnotator(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "module_owners")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	ownersFile := filepath.Join(tempDir, "module_owners.json")
+	status := &Status{}
+	output := &resultOutput{}
+	status.AddOutput(output)
+	status.AddErrorAnnotator(NewModuleOwnersAnnotator(logger.New(ioutil.Discard), ownersFile))
+	s := status.StartTool()
+
+	finish := func(output string, err error) {
+		a := &Action{Outputs: []string{output}}
+		s.StartAction(a)
+		s.FinishAction(ActionResult{Action: a, Output: "compile error", Error: err})
+	}
+
+	// The actions that fail before module_owners.json is written are not attributed.
+	finish("out/soong/.intermediates/foo/libfoo/android_arm64_armv8-a_shared/obj/foo.o", errors.New("exit status 1"))
+
+	err = ioutil.WriteFile(ownersFile, []byte(`{
+		"out/soong/.intermediates/foo/libfoo": {
+			"name": "libfoo",
+			"path": "foo",
+			"owners_file": "foo/OWNERS",
+			"owners": ["alice@example.com", "bob@example.com"],
+			"bug_component": "12345"
+		}
+	}`), 0666)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	finish("out/soong/.intermediates/foo/libfoo/android_arm64_armv8-a_shared/obj/foo.o", errors.New("exit status 1"))
+	finish("out/soong/.intermediates/foo/libfoo/android_arm64_armv8-a_shared/obj/bar.o", nil)
+	finish("out/soong/.intermediates/bar/libbar/android_arm64_armv8-a_shared/obj/bar.o", errors.New("exit status 1"))
+
+	expected := []string{
+		"compile error",
+		"compile error\n" +
+			"Module: libfoo (foo)\n" +
+			"Owners: alice@example.com, bob@example.com (foo/OWNERS)\n" +
+			"Bug component: 12345\n",
+		"compile error",
+		"compile error",
+	}
+	if len(output.results) != len(expected) {
+		t.Fatalf("expected %d results, got %d", len(expected), len(output.results))
+	}
+	for i, result := range output.results {
+		if result.Output != expected[i] {
+			t.Errorf("result %d: expected output %q, got %q", i, expected[i], result.Output)
+		}
+	}
+}
@@ -18,6 +18,7 @@
 package status
 
 import (
+	"strings"
 	"sync"
 )
 
@@ -221,6 +222,8 @@ type Status struct {
 	counts  Counts
 	outputs []StatusOutput
 
+	errorAnnotators []ErrorAnnotator
+
 	// Protects counts and outputs, and allows each output to
 	// expect only a single caller at a time.
 	lock sync.Mutex
@@ -239,6 +242,23 @@ func (s *Status) AddOutput(output StatusOutput) {
 	s.outputs = append(s.outputs, output)
 }
 
+// ErrorAnnotator returns extra information about a failed action, for example who owns it, to be
+// appended to its output, or an empty string if it has none.
+type ErrorAnnotator func(result ActionResult) string
+
+// AddErrorAnnotator attaches an annotator whose information about failed actions is appended to
+// their output before it is passed to the outputs.
+func (s *Status) AddErrorAnnotator(annotator ErrorAnnotator) {
+	if annotator == nil {
+		return
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.errorAnnotators = append(s.errorAnnotators, annotator)
+}
+
 // StartTool returns a new ToolStatus instance to report the status of a tool.
 func (s *Status) StartTool() ToolStatus {
 	return &toolStatus{
@@ -284,6 +304,17 @@ func (s *Status) finishAction(result ActionResult) {
 	s.counts.RunningActions -= 1
 	s.counts.FinishedActions += 1
 
+	if result.Error != nil {
+		for _, annotate := range s.errorAnnotators {
+			if annotation := annotate(result); annotation != "" {
+				if result.Output != "" && !strings.HasSuffix(result.Output, "\n") {
+					result.Output += "\n"
+				}
+				result.Output += annotation
+			}
+		}
+	}
+
 	for _, o := range s.outputs {
 		o.FinishAction(result, s.counts)
 	}
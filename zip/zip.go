@@ -72,6 +72,7 @@ type FileArg struct {
 	SourceFiles                          []string
 	JunkPaths                            bool
 	GlobDir                              string
+	ExplicitPathInZip                    string
 }
 
 type FileArgsBuilder struct {
@@ -105,6 +106,12 @@ func (b *FileArgsBuilder) PathPrefixInZip(rootPrefix string) *FileArgsBuilder {
 	return b
 }
 
+// ExplicitPathInZip sets the path in the zip of the file added by the next call to File.
+func (b *FileArgsBuilder) ExplicitPathInZip(s string) *FileArgsBuilder {
+	b.state.ExplicitPathInZip = s
+	return b
+}
+
 func (b *FileArgsBuilder) File(name string) *FileArgsBuilder {
 	if b.err != nil {
 		return b
@@ -113,11 +120,24 @@ func (b *FileArgsBuilder) File(name string) *FileArgsBuilder {
 	arg := b.state
 	arg.SourceFiles = []string{name}
 	b.fileArgs = append(b.fileArgs, arg)
+
+	b.state.ExplicitPathInZip = ""
 	return b
 }
 
+// checkNoExplicitPathInZip returns an error if an explicit path in the zip was set for a
+// directory or a list of files, which it can't apply to.
+func (b *FileArgsBuilder) checkNoExplicitPathInZip(name string) bool {
+	if b.state.ExplicitPathInZip != "" {
+		b.err = fmt.Errorf("explicit path in zip %q can only be used with a single file, not %q",
+			b.state.ExplicitPathInZip, name)
+		return false
+	}
+	return true
+}
+
 func (b *FileArgsBuilder) Dir(name string) *FileArgsBuilder {
-	if b.err != nil {
+	if b.err != nil || !b.checkNoExplicitPathInZip(name) {
 		return b
 	}
 
@@ -129,7 +149,7 @@ func (b *FileArgsBuilder) Dir(name string) *FileArgsBuilder {
 
 // List reads the file names from the given file and adds them to the source files list.
 func (b *FileArgsBuilder) List(name string) *FileArgsBuilder {
-	if b.err != nil {
+	if b.err != nil || !b.checkNoExplicitPathInZip(name) {
 		return b
 	}
 
@@ -154,7 +174,7 @@ func (b *FileArgsBuilder) List(name string) *FileArgsBuilder {
 
 // RspFile reads the file names from given .rsp file and adds them to the source files list.
 func (b *FileArgsBuilder) RspFile(name string) *FileArgsBuilder {
-	if b.err != nil {
+	if b.err != nil || !b.checkNoExplicitPathInZip(name) {
 		return b
 	}
 
@@ -400,7 +420,9 @@ func fillPathPairs(fa FileArg, src string, pathMappings *[]pathMapping,
 
 	var dest string
 
-	if fa.JunkPaths {
+	if fa.ExplicitPathInZip != "" {
+		dest = fa.ExplicitPathInZip
+	} else if fa.JunkPaths {
 		dest = filepath.Base(src)
 	} else {
 		var err error
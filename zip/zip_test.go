@@ -373,6 +373,20 @@ func TestZip(t *testing.T) {
 				fh("b", fileB, zip.Deflate),
 			},
 		},
+		{
+			name: "explicit path in zip",
+			args: fileArgsBuilder().
+				PathPrefixInZip("foo").
+				ExplicitPathInZip("bar/baz").
+				File("a/a/a").
+				File("a/a/b"),
+			compressionLevel: 9,
+
+			files: []zip.FileHeader{
+				fh("foo/bar/baz", fileA, zip.Deflate),
+				fh("foo/a/a/b", fileB, zip.Deflate),
+			},
+		},
 		{
 			name: "non deflated files",
 			args: fileArgsBuilder().
@@ -535,6 +549,15 @@ func TestZip(t *testing.T) {
 	}
 }
 
+func TestExplicitPathInZipError(t *testing.T) {
+	args := fileArgsBuilder().
+		ExplicitPathInZip("foo").
+		Dir("a")
+	if args.Error() == nil {
+		t.Error("want error for an explicit path in zip used with a directory")
+	}
+}
+
 func TestSrcJar(t *testing.T) {
 	mockFs := pathtools.MockFs(map[string][]byte{
 		"wrong_package.java":       []byte("package foo;"),